@@ -8,12 +8,15 @@
 package application
 
 import (
+	"time"
+
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"gopkg.in/juju/charm.v6-unstable"
 	"gopkg.in/juju/names.v2"
 
 	"github.com/juju/juju/api/base"
+	apiwatcher "github.com/juju/juju/api/watcher"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/charmstore"
 	"github.com/juju/juju/constraints"
@@ -21,6 +24,7 @@ import (
 	"github.com/juju/juju/core/relation"
 	"github.com/juju/juju/instance"
 	"github.com/juju/juju/storage"
+	"github.com/juju/juju/watcher"
 )
 
 var logger = loggo.GetLogger("juju.api.application")
@@ -239,6 +243,9 @@ func (c *Client) SetCharm(cfg SetCharmConfig) error {
 // Update updates the application attributes, including charm URL,
 // minimum number of units, settings and constraints.
 func (c *Client) Update(args params.ApplicationUpdate) error {
+	if args.RedactInLogs && c.BestAPIVersion() < 8 {
+		return errors.NotSupportedf("redacting application settings in controller logs")
+	}
 	return c.facade.FacadeCall("Update", args, nil)
 }
 
@@ -442,6 +449,83 @@ func (c *Client) Get(application string) (*params.ApplicationGetResults, error)
 	return &results, err
 }
 
+// ConfigChangeTimes returns the time at which each of the named
+// application's configuration settings was last changed, for settings
+// changed since the controller began recording the times. It returns a
+// NotSupported error if the controller does not record them.
+func (c *Client) ConfigChangeTimes(application string) (map[string]time.Time, error) {
+	if c.BestAPIVersion() < 9 {
+		return nil, errors.NotSupportedf("recording when application settings change")
+	}
+	results, err := c.Get(application)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if results.ConfigChanged == nil {
+		return make(map[string]time.Time), nil
+	}
+	return results.ConfigChanged, nil
+}
+
+// WatchConfig returns a watcher that notifies of changes to the
+// application's configuration settings. It returns a NotSupported
+// error if the controller does not support watching application
+// config.
+func (c *Client) WatchConfig(application string) (watcher.NotifyWatcher, error) {
+	if c.BestAPIVersion() < 6 {
+		return nil, errors.NotSupportedf("watching application config")
+	}
+	if !names.IsValidApplication(application) {
+		return nil, errors.NotValidf("application name %q", application)
+	}
+	var results params.NotifyWatchResults
+	args := params.Entities{
+		Entities: []params.Entity{{Tag: names.NewApplicationTag(application).String()}},
+	}
+	if err := c.facade.FacadeCall("WatchConfig", args, &results); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return apiwatcher.NewNotifyWatcher(c.facade.RawAPICaller(), result), nil
+}
+
+// PreviousConfig returns the application's configuration settings as
+// they were before the most recent change to them. It returns a
+// NotFound error if there has been no change, and a NotSupported error
+// if the controller does not retain previous application config.
+func (c *Client) PreviousConfig(application string) (map[string]interface{}, error) {
+	if c.BestAPIVersion() < 7 {
+		return nil, errors.NotSupportedf("getting previous application config")
+	}
+	if !names.IsValidApplication(application) {
+		return nil, errors.NotValidf("application name %q", application)
+	}
+	var results params.ConfigSettingsResults
+	args := params.Entities{
+		Entities: []params.Entity{{Tag: names.NewApplicationTag(application).String()}},
+	}
+	if err := c.facade.FacadeCall("PreviousConfig", args, &results); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		if params.IsCodeNotFound(result.Error) {
+			return nil, errors.NewNotFound(result.Error, "")
+		}
+		return nil, result.Error
+	}
+	return result.Settings, nil
+}
+
 // Set sets configuration options on an application.
 func (c *Client) Set(application string, options map[string]string) error {
 	p := params.ApplicationSet{
@@ -451,6 +535,21 @@ func (c *Client) Set(application string, options map[string]string) error {
 	return c.facade.FacadeCall("Set", p, nil)
 }
 
+// SetRedacted sets configuration options on an application, as Set
+// does, asking the controller not to write their values to its logs. It
+// returns a NotSupported error if the controller cannot redact them.
+func (c *Client) SetRedacted(application string, options map[string]string) error {
+	if c.BestAPIVersion() < 8 {
+		return errors.NotSupportedf("redacting application settings in controller logs")
+	}
+	p := params.ApplicationSet{
+		ApplicationName: application,
+		Options:         options,
+		RedactInLogs:    true,
+	}
+	return c.facade.FacadeCall("Set", p, nil)
+}
+
 // Unset resets configuration options on an application.
 func (c *Client) Unset(application string, options []string) error {
 	p := params.ApplicationUnset{
@@ -460,6 +559,21 @@ func (c *Client) Unset(application string, options []string) error {
 	return c.facade.FacadeCall("Unset", p, nil)
 }
 
+// SetConfigLocked locks or unlocks the application's configuration
+// settings. While they are locked, the controller refuses to change
+// them. It returns a NotSupported error if the controller cannot lock
+// application config.
+func (c *Client) SetConfigLocked(application string, locked bool) error {
+	if c.BestAPIVersion() < 10 {
+		return errors.NotSupportedf("locking application config")
+	}
+	p := params.ApplicationSetConfigLocked{
+		ApplicationName: application,
+		Locked:          locked,
+	}
+	return c.facade.FacadeCall("SetConfigLocked", p, nil)
+}
+
 // CharmRelations returns the application's charms relation names.
 func (c *Client) CharmRelations(application string) ([]string, error) {
 	var results params.ApplicationCharmRelationsResults
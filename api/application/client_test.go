@@ -4,6 +4,9 @@
 package application_test
 
 import (
+	"time"
+
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
@@ -559,3 +562,215 @@ func (s *applicationSuite) TestAddRelation(c *gc.C) {
 		"ep1": {Name: "foo"},
 	})
 }
+
+func (s *applicationSuite) TestWatchConfig(c *gc.C) {
+	var called bool
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				called = true
+				c.Check(objType, gc.Equals, "Application")
+				c.Check(id, gc.Equals, "")
+				c.Check(request, gc.Equals, "WatchConfig")
+				c.Check(a, jc.DeepEquals, params.Entities{
+					Entities: []params.Entity{{Tag: "application-wordpress"}},
+				})
+				result := response.(*params.NotifyWatchResults)
+				result.Results = []params.NotifyWatchResult{{
+					Error: &params.Error{Message: "boom"},
+				}}
+				return nil
+			},
+		),
+		BestVersion: 6,
+	})
+	_, err := client.WatchConfig("wordpress")
+	c.Assert(err, gc.ErrorMatches, "boom")
+	c.Assert(called, jc.IsTrue)
+}
+
+func (s *applicationSuite) TestWatchConfigV5(c *gc.C) {
+	var called bool
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				called = true
+				return nil
+			},
+		),
+		BestVersion: 5, // v5 does not support WatchConfig
+	})
+	_, err := client.WatchConfig("wordpress")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(called, jc.IsFalse)
+}
+
+func (s *applicationSuite) TestPreviousConfig(c *gc.C) {
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				c.Check(objType, gc.Equals, "Application")
+				c.Check(id, gc.Equals, "")
+				c.Check(request, gc.Equals, "PreviousConfig")
+				c.Check(a, jc.DeepEquals, params.Entities{
+					Entities: []params.Entity{{Tag: "application-wordpress"}},
+				})
+				result := response.(*params.ConfigSettingsResults)
+				result.Results = []params.ConfigSettingsResult{{
+					Settings: params.ConfigSettings{"blog-title": "sauceror central"},
+				}}
+				return nil
+			},
+		),
+		BestVersion: 7,
+	})
+	settings, err := client.PreviousConfig("wordpress")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(settings, jc.DeepEquals, map[string]interface{}{"blog-title": "sauceror central"})
+}
+
+func (s *applicationSuite) TestPreviousConfigNotFound(c *gc.C) {
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				result := response.(*params.ConfigSettingsResults)
+				result.Results = []params.ConfigSettingsResult{{
+					Error: &params.Error{Code: params.CodeNotFound, Message: "not found"},
+				}}
+				return nil
+			},
+		),
+		BestVersion: 7,
+	})
+	_, err := client.PreviousConfig("wordpress")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *applicationSuite) TestPreviousConfigV6(c *gc.C) {
+	var called bool
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				called = true
+				return nil
+			},
+		),
+		BestVersion: 6, // v6 does not support PreviousConfig
+	})
+	_, err := client.PreviousConfig("wordpress")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(called, jc.IsFalse)
+}
+
+func (s *applicationSuite) TestSetRedacted(c *gc.C) {
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				c.Check(objType, gc.Equals, "Application")
+				c.Check(request, gc.Equals, "Set")
+				c.Check(a, jc.DeepEquals, params.ApplicationSet{
+					ApplicationName: "wordpress",
+					Options:         map[string]string{"password": "s3cret"},
+					RedactInLogs:    true,
+				})
+				return nil
+			},
+		),
+		BestVersion: 8,
+	})
+	err := client.SetRedacted("wordpress", map[string]string{"password": "s3cret"})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *applicationSuite) TestSetRedactedV7(c *gc.C) {
+	var called bool
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				called = true
+				return nil
+			},
+		),
+		BestVersion: 7, // v7 cannot redact settings in logs
+	})
+	err := client.SetRedacted("wordpress", map[string]string{"password": "s3cret"})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	err = client.Update(params.ApplicationUpdate{
+		ApplicationName: "wordpress",
+		SettingsYAML:    "wordpress:\n  password: s3cret\n",
+		RedactInLogs:    true,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(called, jc.IsFalse)
+}
+
+func (s *applicationSuite) TestConfigChangeTimes(c *gc.C) {
+	changed := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				c.Check(objType, gc.Equals, "Application")
+				c.Check(request, gc.Equals, "Get")
+				c.Check(a, jc.DeepEquals, params.ApplicationGet{ApplicationName: "wordpress"})
+				result := response.(*params.ApplicationGetResults)
+				result.ConfigChanged = map[string]time.Time{"blog-title": changed}
+				return nil
+			},
+		),
+		BestVersion: 9,
+	})
+	times, err := client.ConfigChangeTimes("wordpress")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(times, jc.DeepEquals, map[string]time.Time{"blog-title": changed})
+}
+
+func (s *applicationSuite) TestConfigChangeTimesV8(c *gc.C) {
+	var called bool
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				called = true
+				return nil
+			},
+		),
+		BestVersion: 8, // v8 does not record when settings change
+	})
+	_, err := client.ConfigChangeTimes("wordpress")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(called, jc.IsFalse)
+}
+
+func (s *applicationSuite) TestSetConfigLocked(c *gc.C) {
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				c.Check(objType, gc.Equals, "Application")
+				c.Check(request, gc.Equals, "SetConfigLocked")
+				c.Check(a, jc.DeepEquals, params.ApplicationSetConfigLocked{
+					ApplicationName: "wordpress",
+					Locked:          true,
+				})
+				return nil
+			},
+		),
+		BestVersion: 10,
+	})
+	err := client.SetConfigLocked("wordpress", true)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *applicationSuite) TestSetConfigLockedV9(c *gc.C) {
+	var called bool
+	client := application.NewClient(basetesting.BestVersionCaller{
+		APICallerFunc: basetesting.APICallerFunc(
+			func(objType string, version int, id, request string, a, response interface{}) error {
+				called = true
+				return nil
+			},
+		),
+		BestVersion: 9, // v9 cannot lock application config
+	})
+	err := client.SetConfigLocked("wordpress", true)
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(called, jc.IsFalse)
+}
@@ -83,6 +83,20 @@ func (c *Client) ModelConfig() (map[string]interface{}, error) {
 	return values, err
 }
 
+// ConfigSet sets and removes the given controller config attributes.
+// Only the attributes that may be changed after bootstrap, such as
+// firewall-panic-close, are accepted.
+func (c *Client) ConfigSet(values map[string]interface{}, unset []string) error {
+	if c.BestAPIVersion() < 5 {
+		return errors.NotSupportedf("changing controller config on this controller")
+	}
+	args := params.ControllerConfigSet{
+		Config: values,
+		Unset:  unset,
+	}
+	return errors.Trace(c.facade.FacadeCall("ConfigSet", args, nil))
+}
+
 // HostedConfig contains the model config and the cloud spec for that
 // model such that direct access to the provider can be used.
 type HostedConfig struct {
@@ -271,3 +271,32 @@ func makeSpec() controller.MigrationSpec {
 func randomUUID() string {
 	return utils.MustNewUUID().String()
 }
+
+func (s *Suite) TestConfigSet(c *gc.C) {
+	var stub jujutesting.Stub
+	apiCaller := apitesting.BestVersionCaller{
+		BestVersion: 5,
+		APICallerFunc: func(objType string, version int, id, request string, arg, result interface{}) error {
+			stub.AddCall(objType+"."+request, arg)
+			return stub.NextErr()
+		},
+	}
+	client := controller.NewClient(apiCaller)
+	err := client.ConfigSet(map[string]interface{}{
+		"firewall-panic-close": true,
+	}, []string{"firewall-essential-rules"})
+	c.Assert(err, jc.ErrorIsNil)
+	stub.CheckCalls(c, []jujutesting.StubCall{
+		{"Controller.ConfigSet", []interface{}{params.ControllerConfigSet{
+			Config: map[string]interface{}{"firewall-panic-close": true},
+			Unset:  []string{"firewall-essential-rules"},
+		}}},
+	})
+}
+
+func (s *Suite) TestConfigSetNotSupported(c *gc.C) {
+	apiCaller := apitesting.BestVersionCaller{BestVersion: 4}
+	client := controller.NewClient(apiCaller)
+	err := client.ConfigSet(map[string]interface{}{"firewall-panic-close": true}, nil)
+	c.Assert(err, gc.ErrorMatches, "changing controller config on this controller not supported")
+}
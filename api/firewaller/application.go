@@ -6,6 +6,7 @@ package firewaller
 import (
 	"fmt"
 
+	"github.com/juju/errors"
 	"gopkg.in/juju/names.v2"
 
 	"github.com/juju/juju/api/common"
@@ -75,3 +76,30 @@ func (s *Application) IsExposed() (bool, error) {
 	}
 	return result.Result, nil
 }
+
+// ExposedSpaces returns the spaces that the application is exposed to,
+// and the CIDRs of their subnets. If there are no spaces, an exposed
+// application is exposed to everywhere. It returns an error satisfying
+// errors.IsNotSupported if the controller cannot expose applications to
+// spaces.
+func (s *Application) ExposedSpaces() (spaces, cidrs []string, err error) {
+	if s.st.facade.BestAPIVersion() < 8 {
+		return nil, nil, errors.NotSupportedf("exposing applications to spaces on this controller")
+	}
+	var results params.ExposedSpacesResults
+	args := params.Entities{
+		Entities: []params.Entity{{Tag: s.tag.String()}},
+	}
+	err = s.st.facade.FacadeCall("GetExposedSpaces", args, &results)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, nil, fmt.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	return result.Spaces, result.CIDRs, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/juju/juju/api/firewaller"
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/state"
 	"github.com/juju/juju/watcher/watchertest"
 )
 
@@ -91,3 +92,25 @@ func (s *applicationSuite) TestIsExposed(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(isExposed, jc.IsFalse)
 }
+
+func (s *applicationSuite) TestExposedSpaces(c *gc.C) {
+	_, err := s.State.AddSubnet(state.SubnetInfo{CIDR: "10.0.1.0/24"})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = s.State.AddSpace("dmz", "", []string{"10.0.1.0/24"}, true)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.application.SetExposedSpaces([]string{"dmz"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	spaces, cidrs, err := s.apiApplication.ExposedSpaces()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spaces, jc.DeepEquals, []string{"dmz"})
+	c.Assert(cidrs, jc.DeepEquals, []string{"10.0.1.0/24"})
+
+	err = s.application.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	spaces, cidrs, err = s.apiApplication.ExposedSpaces()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spaces, gc.HasLen, 0)
+	c.Assert(cidrs, gc.HasLen, 0)
+}
@@ -14,6 +14,7 @@ import (
 	apiwatcher "github.com/juju/juju/api/watcher"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/core/relation"
+	"github.com/juju/juju/network"
 	"github.com/juju/juju/watcher"
 	"gopkg.in/macaroon.v1"
 )
@@ -24,6 +25,7 @@ const firewallerFacade = "Firewaller"
 type Client struct {
 	facade base.FacadeCaller
 	*common.ModelWatcher
+	*common.ControllerConfigAPI
 	*cloudspec.CloudSpecAPI
 }
 
@@ -35,9 +37,10 @@ func NewClient(caller base.APICaller) (*Client, error) {
 	}
 	facadeCaller := base.NewFacadeCaller(caller, firewallerFacade)
 	return &Client{
-		facade:       facadeCaller,
-		ModelWatcher: common.NewModelWatcher(facadeCaller),
-		CloudSpecAPI: cloudspec.NewCloudSpecAPI(facadeCaller, modelTag),
+		facade:              facadeCaller,
+		ModelWatcher:        common.NewModelWatcher(facadeCaller),
+		ControllerConfigAPI: common.NewControllerConfig(facadeCaller),
+		CloudSpecAPI:        cloudspec.NewCloudSpecAPI(facadeCaller, modelTag),
 	}, nil
 }
 
@@ -238,3 +241,87 @@ func (c *Client) SetRelationStatus(relationKey string, status relation.Status, m
 	}
 	return results.OneError()
 }
+
+// MachineSubnetPorts holds the port ranges opened by units on a
+// machine for a subnet.
+type MachineSubnetPorts struct {
+	Machine     names.MachineTag
+	Subnet      names.SubnetTag
+	Ports       map[network.PortRange]names.UnitTag
+	EgressPorts map[network.PortRange]names.UnitTag
+}
+
+// WatchControllerConfig returns a NotifyWatcher that notifies when the
+// controller's config changes. It returns an error satisfying
+// errors.IsNotSupported if the controller does not support watching
+// its config.
+func (c *Client) WatchControllerConfig() (watcher.NotifyWatcher, error) {
+	if c.BestAPIVersion() < 10 {
+		return nil, errors.NotSupportedf("watching controller config on this controller")
+	}
+	var result params.NotifyWatchResult
+	if err := c.facade.FacadeCall("WatchControllerConfig", nil, &result); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return apiwatcher.NewNotifyWatcher(c.facade.RawAPICaller(), result), nil
+}
+
+// AllMachinePorts returns the port ranges opened on every machine in the
+// model, for each subnet, in a single call. It returns an error
+// satisfying errors.IsNotSupported if the controller does not support
+// loading them all at once.
+func (c *Client) AllMachinePorts() ([]MachineSubnetPorts, error) {
+	if c.BestAPIVersion() < 9 {
+		return nil, errors.NotSupportedf("loading all machine ports on this controller")
+	}
+	var result params.AllMachinePortsResult
+	if err := c.facade.FacadeCall("GetAllMachinePorts", nil, &result); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	all := make([]MachineSubnetPorts, len(result.Machines))
+	for i, m := range result.Machines {
+		machineTag, err := names.ParseMachineTag(m.MachineTag)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		var subnetTag names.SubnetTag
+		if m.SubnetTag != "" {
+			if subnetTag, err = names.ParseSubnetTag(m.SubnetTag); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		ports, err := unitPortRanges(m.Ports)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		egressPorts, err := unitPortRanges(m.EgressPorts)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		all[i] = MachineSubnetPorts{
+			Machine:     machineTag,
+			Subnet:      subnetTag,
+			Ports:       ports,
+			EgressPorts: egressPorts,
+		}
+	}
+	return all, nil
+}
+
+func unitPortRanges(ports []params.MachinePortRange) (map[network.PortRange]names.UnitTag, error) {
+	result := make(map[network.PortRange]names.UnitTag)
+	for _, p := range ports {
+		unitTag, err := names.ParseUnitTag(p.UnitTag)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result[p.PortRange.NetworkPortRange()] = unitTag
+	}
+	return result, nil
+}
@@ -4,6 +4,7 @@
 package firewaller_test
 
 import (
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
 	gc "gopkg.in/check.v1"
@@ -15,6 +16,7 @@ import (
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/core/relation"
 	jujutesting "github.com/juju/juju/juju/testing"
+	"github.com/juju/juju/network"
 	"github.com/juju/juju/state"
 	coretesting "github.com/juju/juju/testing"
 )
@@ -217,3 +219,89 @@ func (s *firewallerSuite) TestSetRelationStatus(c *gc.C) {
 	c.Check(err, gc.ErrorMatches, "FAIL")
 	c.Check(callCount, gc.Equals, 1)
 }
+
+func (s *firewallerSuite) TestAllMachinePorts(c *gc.C) {
+	var callCount int
+	apiCaller := testing.BestVersionCaller{
+		APICallerFunc: testing.APICallerFunc(func(objType string, version int, id, request string, arg, result interface{}) error {
+			c.Check(objType, gc.Equals, "Firewaller")
+			c.Check(version, gc.Equals, 9)
+			c.Check(id, gc.Equals, "")
+			c.Check(request, gc.Equals, "GetAllMachinePorts")
+			c.Check(arg, gc.IsNil)
+			c.Assert(result, gc.FitsTypeOf, &params.AllMachinePortsResult{})
+			*(result.(*params.AllMachinePortsResult)) = params.AllMachinePortsResult{
+				Machines: []params.MachineSubnetPorts{{
+					MachineTag: "machine-0",
+					Ports: []params.MachinePortRange{{
+						UnitTag:   "unit-wordpress-0",
+						PortRange: params.PortRange{FromPort: 80, ToPort: 80, Protocol: "tcp"},
+					}},
+					EgressPorts: []params.MachinePortRange{{
+						UnitTag:   "unit-wordpress-0",
+						PortRange: params.PortRange{FromPort: 443, ToPort: 443, Protocol: "tcp"},
+					}},
+				}, {
+					MachineTag: "machine-1",
+					SubnetTag:  "subnet-10.20.30.0/24",
+					Ports: []params.MachinePortRange{{
+						UnitTag:   "unit-mysql-0",
+						PortRange: params.PortRange{FromPort: 3306, ToPort: 3306, Protocol: "tcp"},
+					}},
+				}},
+			}
+			callCount++
+			return nil
+		}),
+		BestVersion: 9,
+	}
+	client, err := firewaller.NewClient(apiCaller)
+	c.Assert(err, jc.ErrorIsNil)
+	all, err := client.AllMachinePorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(callCount, gc.Equals, 1)
+	c.Assert(all, jc.DeepEquals, []firewaller.MachineSubnetPorts{{
+		Machine: names.NewMachineTag("0"),
+		Ports: map[network.PortRange]names.UnitTag{
+			{FromPort: 80, ToPort: 80, Protocol: "tcp"}: names.NewUnitTag("wordpress/0"),
+		},
+		EgressPorts: map[network.PortRange]names.UnitTag{
+			{FromPort: 443, ToPort: 443, Protocol: "tcp"}: names.NewUnitTag("wordpress/0"),
+		},
+	}, {
+		Machine: names.NewMachineTag("1"),
+		Subnet:  names.NewSubnetTag("10.20.30.0/24"),
+		Ports: map[network.PortRange]names.UnitTag{
+			{FromPort: 3306, ToPort: 3306, Protocol: "tcp"}: names.NewUnitTag("mysql/0"),
+		},
+		EgressPorts: map[network.PortRange]names.UnitTag{},
+	}})
+}
+
+func (s *firewallerSuite) TestAllMachinePortsNotSupported(c *gc.C) {
+	apiCaller := testing.BestVersionCaller{
+		APICallerFunc: testing.APICallerFunc(func(objType string, version int, id, request string, arg, result interface{}) error {
+			c.Fatalf("unexpected call to %s", request)
+			return nil
+		}),
+		BestVersion: 8,
+	}
+	client, err := firewaller.NewClient(apiCaller)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.AllMachinePorts()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *firewallerSuite) TestWatchControllerConfigNotSupported(c *gc.C) {
+	apiCaller := testing.BestVersionCaller{
+		APICallerFunc: testing.APICallerFunc(func(objType string, version int, id, request string, arg, result interface{}) error {
+			c.Fatalf("unexpected call to %s", request)
+			return nil
+		}),
+		BestVersion: 9,
+	}
+	client, err := firewaller.NewClient(apiCaller)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = client.WatchControllerConfig()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
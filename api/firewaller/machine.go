@@ -6,8 +6,10 @@ package firewaller
 import (
 	"fmt"
 
+	"github.com/juju/errors"
 	"gopkg.in/juju/names.v2"
 
+	"github.com/juju/juju/api/common"
 	apiwatcher "github.com/juju/juju/api/watcher"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/instance"
@@ -49,6 +51,33 @@ func (m *Machine) WatchUnits() (watcher.StringsWatcher, error) {
 	return w, nil
 }
 
+// Watch returns a watcher for observing changes to the machine,
+// including changes to its firewall lockdown flag.
+func (m *Machine) Watch() (watcher.NotifyWatcher, error) {
+	return common.Watch(m.st.facade, "Watch", m.tag)
+}
+
+// FirewallLockdown returns whether all ports on the machine should be
+// closed, regardless of the ports opened by its units.
+func (m *Machine) FirewallLockdown() (bool, error) {
+	var results params.BoolResults
+	args := params.Entities{
+		Entities: []params.Entity{{Tag: m.tag.String()}},
+	}
+	err := m.st.facade.FacadeCall("GetMachineFirewallLockdown", args, &results)
+	if err != nil {
+		return false, err
+	}
+	if len(results.Results) != 1 {
+		return false, fmt.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.Result, nil
+}
+
 // InstanceId returns the provider specific instance id for this
 // machine, or a CodeNotProvisioned error, if not set.
 func (m *Machine) InstanceId() (instance.Id, error) {
@@ -111,6 +140,21 @@ func (m *Machine) ActiveSubnets() ([]names.SubnetTag, error) {
 // OpenedPorts returns a map of network.PortRange to unit tag for all opened
 // port ranges on the machine for the subnet matching given subnetTag.
 func (m *Machine) OpenedPorts(subnetTag names.SubnetTag) (map[network.PortRange]names.UnitTag, error) {
+	return m.machinePorts("GetMachinePorts", subnetTag)
+}
+
+// OpenedEgressPorts returns a map of network.PortRange to unit tag for
+// all egress port ranges opened on the machine for the subnet matching
+// given subnetTag. It returns an error satisfying errors.IsNotSupported
+// if the controller does not support egress ports.
+func (m *Machine) OpenedEgressPorts(subnetTag names.SubnetTag) (map[network.PortRange]names.UnitTag, error) {
+	if m.st.facade.BestAPIVersion() < 6 {
+		return nil, errors.NotSupportedf("egress ports on this controller")
+	}
+	return m.machinePorts("GetMachineEgressPorts", subnetTag)
+}
+
+func (m *Machine) machinePorts(method string, subnetTag names.SubnetTag) (map[network.PortRange]names.UnitTag, error) {
 	var results params.MachinePortsResults
 	var subnetTagAsString string
 	if subnetTag.Id() != "" {
@@ -121,7 +165,7 @@ func (m *Machine) OpenedPorts(subnetTag names.SubnetTag) (map[network.PortRange]
 			{MachineTag: m.tag.String(), SubnetTag: subnetTagAsString},
 		},
 	}
-	err := m.st.facade.FacadeCall("GetMachinePorts", args, &results)
+	err := m.st.facade.FacadeCall(method, args, &results)
 	if err != nil {
 		return nil, err
 	}
@@ -143,3 +187,54 @@ func (m *Machine) OpenedPorts(subnetTag names.SubnetTag) (map[network.PortRange]
 	}
 	return endResult, nil
 }
+
+// ClassifiedIngressRule holds an ingress rule found on a machine's
+// instance, along with its origin: one of params.IngressRuleJuju,
+// params.IngressRuleOrphaned or params.IngressRuleExternal.
+type ClassifiedIngressRule struct {
+	network.IngressRule
+	Origin string
+}
+
+// ClassifyIngressRules returns the origin of each of the given ingress
+// rules, found on the machine's instance, in the same order. It
+// returns an error satisfying errors.IsNotSupported if the controller
+// cannot classify ingress rules.
+func (m *Machine) ClassifyIngressRules(rules []network.IngressRule) ([]ClassifiedIngressRule, error) {
+	if m.st.facade.BestAPIVersion() < 7 {
+		return nil, errors.NotSupportedf("classifying ingress rules on this controller")
+	}
+	param := params.MachineIngressRules{
+		MachineTag: m.tag.String(),
+		Rules:      make([]params.IngressRule, len(rules)),
+	}
+	for i, rule := range rules {
+		param.Rules[i] = params.FromNetworkIngressRule(rule)
+	}
+	args := params.MachineIngressRulesParams{
+		Params: []params.MachineIngressRules{param},
+	}
+	var results params.ClassifiedIngressRulesResults
+	err := m.st.facade.FacadeCall("ClassifyIngressRules", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, fmt.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(result.Rules) != len(rules) {
+		return nil, fmt.Errorf("expected %d rules, got %d", len(rules), len(result.Rules))
+	}
+	classified := make([]ClassifiedIngressRule, len(result.Rules))
+	for i, rule := range result.Rules {
+		classified[i] = ClassifiedIngressRule{
+			IngressRule: rule.Rule.NetworkIngressRule(),
+			Origin:      rule.Origin,
+		}
+	}
+	return classified, nil
+}
@@ -67,6 +67,34 @@ func (s *machineSuite) TestInstanceId(c *gc.C) {
 	c.Assert(instanceId, gc.Equals, instance.Id("i-manager"))
 }
 
+func (s *machineSuite) TestWatch(c *gc.C) {
+	w, err := s.apiMachine.Watch()
+	c.Assert(err, jc.ErrorIsNil)
+	wc := watchertest.NewNotifyWatcherC(c, w, s.BackingState.StartSync)
+	defer wc.AssertStops()
+
+	// Initial event.
+	wc.AssertOneChange()
+
+	// Lock down the machine and check it's detected.
+	err = s.machines[0].SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertOneChange()
+}
+
+func (s *machineSuite) TestFirewallLockdown(c *gc.C) {
+	lockdown, err := s.apiMachine.FirewallLockdown()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(lockdown, jc.IsFalse)
+
+	err = s.machines[0].SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	lockdown, err = s.apiMachine.FirewallLockdown()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(lockdown, jc.IsTrue)
+}
+
 func (s *machineSuite) TestWatchUnits(c *gc.C) {
 	w, err := s.apiMachine.WatchUnits()
 	c.Assert(err, jc.ErrorIsNil)
@@ -134,3 +162,39 @@ func (s *machineSuite) TestOpenedPorts(c *gc.C) {
 		network.PortRange{FromPort: 1234, ToPort: 1234, Protocol: "tcp"}: unitTag,
 	})
 }
+
+func (s *machineSuite) TestOpenedEgressPorts(c *gc.C) {
+	unitTag := s.units[0].Tag().(names.UnitTag)
+	err := s.units[0].OpenPort("tcp", 1234)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Ingress ports are not reported.
+	ports, err := s.apiMachine.OpenedEgressPorts(names.SubnetTag{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ports, gc.HasLen, 0)
+
+	err = s.units[0].OpenEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+	ports, err = s.apiMachine.OpenedEgressPorts(names.SubnetTag{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ports, jc.DeepEquals, map[network.PortRange]names.UnitTag{
+		network.PortRange{FromPort: 443, ToPort: 443, Protocol: "tcp"}: unitTag,
+	})
+}
+
+func (s *machineSuite) TestClassifyIngressRules(c *gc.C) {
+	err := s.units[0].OpenPort("tcp", 1234)
+	c.Assert(err, jc.ErrorIsNil)
+
+	rules := []network.IngressRule{
+		network.MustNewIngressRule("tcp", 1234, 1234, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 4321, 4321, "0.0.0.0/0"),
+	}
+	classified, err := s.apiMachine.ClassifyIngressRules(rules)
+	c.Assert(err, jc.ErrorIsNil)
+	// The application is not exposed, so its port is not wanted.
+	c.Assert(classified, jc.DeepEquals, []firewaller.ClassifiedIngressRule{
+		{IngressRule: rules[0], Origin: params.IngressRuleOrphaned},
+		{IngressRule: rules[1], Origin: params.IngressRuleExternal},
+	})
+}
@@ -135,3 +135,23 @@ func (client *Client) UpdateMachineSeries(machineName, series string, force bool
 	}
 	return results.OneError()
 }
+
+// SetFirewallLockdown sets or clears the firewall lockdown of the machine.
+// While a machine is locked down, all of its ports are closed, regardless
+// of the ports opened by its units. Requires MachineManager facade
+// version 5 or greater.
+func (client *Client) SetFirewallLockdown(machineName string, lockdown bool) error {
+	args := params.FirewallLockdownArgs{
+		Args: []params.FirewallLockdownArg{{
+			Entity:   params.Entity{Tag: names.NewMachineTag(machineName).String()},
+			Lockdown: lockdown,
+		}},
+	}
+
+	results := new(params.ErrorResults)
+	err := client.facade.FacadeCall("SetFirewallLockdown", args, results)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return results.OneError()
+}
@@ -210,3 +210,37 @@ func (s *MachinemanagerSuite) TestDestroyMachinesWithParams(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(results, jc.DeepEquals, expectedResults)
 }
+
+func (s *MachinemanagerSuite) TestSetFirewallLockdown(c *gc.C) {
+	var called bool
+	client := newClient(func(objType string, version int, id, request string, arg, result interface{}) error {
+		c.Check(objType, gc.Equals, "MachineManager")
+		c.Check(request, gc.Equals, "SetFirewallLockdown")
+		c.Check(arg, jc.DeepEquals, params.FirewallLockdownArgs{
+			Args: []params.FirewallLockdownArg{{
+				Entity:   params.Entity{Tag: "machine-0"},
+				Lockdown: true,
+			}},
+		})
+		c.Assert(result, gc.FitsTypeOf, &params.ErrorResults{})
+		*(result.(*params.ErrorResults)) = params.ErrorResults{
+			Results: []params.ErrorResult{{}},
+		}
+		called = true
+		return nil
+	})
+	err := client.SetFirewallLockdown("0", true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(called, jc.IsTrue)
+}
+
+func (s *MachinemanagerSuite) TestSetFirewallLockdownError(c *gc.C) {
+	client := newClient(func(objType string, version int, id, request string, arg, result interface{}) error {
+		*(result.(*params.ErrorResults)) = params.ErrorResults{
+			Results: []params.ErrorResult{{Error: &params.Error{Message: "boom"}}},
+		}
+		return nil
+	})
+	err := client.SetFirewallLockdown("0", false)
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
@@ -493,6 +493,38 @@ func (u *Unit) ClosePorts(protocol string, fromPort, toPort int) error {
 	return result.OneError()
 }
 
+// OpenEgressPorts records that the unit connects out to the port range
+// with protocol on other hosts.
+func (u *Unit) OpenEgressPorts(protocol string, fromPort, toPort int) error {
+	return u.updateEgressPorts("OpenEgressPorts", protocol, fromPort, toPort)
+}
+
+// CloseEgressPorts removes the egress port range with protocol of the
+// unit.
+func (u *Unit) CloseEgressPorts(protocol string, fromPort, toPort int) error {
+	return u.updateEgressPorts("CloseEgressPorts", protocol, fromPort, toPort)
+}
+
+func (u *Unit) updateEgressPorts(method, protocol string, fromPort, toPort int) error {
+	if u.st.BestAPIVersion() < 8 {
+		return errors.NotSupportedf("egress ports on this controller")
+	}
+	var result params.ErrorResults
+	args := params.EntitiesPortRanges{
+		Entities: []params.EntityPortRange{{
+			Tag:      u.tag.String(),
+			Protocol: protocol,
+			FromPort: fromPort,
+			ToPort:   toPort,
+		}},
+	}
+	err := u.st.facade.FacadeCall(method, args, &result)
+	if err != nil {
+		return err
+	}
+	return result.OneError()
+}
+
 var ErrNoCharmURLSet = errors.New("unit has no charm url set")
 
 // CharmURL returns the charm URL this unit is currently using.
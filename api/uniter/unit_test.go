@@ -448,6 +448,27 @@ func (s *unitSuite) TestOpenClosePortRanges(c *gc.C) {
 	c.Assert(ports, gc.HasLen, 0)
 }
 
+func (s *unitSuite) TestOpenCloseEgressPorts(c *gc.C) {
+	err := s.apiUnit.OpenEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+
+	ports, err := s.wordpressUnit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ports, gc.DeepEquals, []network.PortRange{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443},
+	})
+	ports, err = s.wordpressUnit.OpenedPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ports, gc.HasLen, 0)
+
+	err = s.apiUnit.CloseEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+
+	ports, err = s.wordpressUnit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ports, gc.HasLen, 0)
+}
+
 func (s *unitSuite) TestGetSetCharmURL(c *gc.C) {
 	// No charm URL set yet.
 	curl, ok := s.wordpressUnit.CharmURL()
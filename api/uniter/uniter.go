@@ -69,12 +69,12 @@ func newStateForVersionFn(version int) func(base.APICaller, names.UnitTag) *Stat
 	}
 }
 
-// newStateV7 creates a new client-side Uniter facade, version 7
-var newStateV7 = newStateForVersionFn(7)
+// newStateV8 creates a new client-side Uniter facade, version 8
+var newStateV8 = newStateForVersionFn(8)
 
 // NewState creates a new client-side Uniter facade.
 // Defined like this to allow patching during tests.
-var NewState = newStateV7
+var NewState = newStateV8
 
 // BestAPIVersion returns the API version that we were able to
 // determine is supported by both the client and the API Server.
@@ -128,7 +128,12 @@ func AllFacades() *facade.Registry {
 	reg("Application", 2, application.NewFacadeV4)
 	reg("Application", 3, application.NewFacadeV4)
 	reg("Application", 4, application.NewFacadeV4)
-	reg("Application", 5, application.NewFacade) // adds AttachStorage & UpdateApplicationSeries & SetRelationStatus
+	reg("Application", 5, application.NewFacadeV5) // adds AttachStorage & UpdateApplicationSeries & SetRelationStatus
+	reg("Application", 6, application.NewFacadeV6) // adds WatchConfig
+	reg("Application", 7, application.NewFacadeV7) // adds PreviousConfig
+	reg("Application", 8, application.NewFacadeV9) // adds RedactInLogs to Set and Update
+	reg("Application", 9, application.NewFacadeV9) // adds ConfigChanged to Get
+	reg("Application", 10, application.NewFacade)  // adds SetConfigLocked, and ConfigLocked to Get
 
 	reg("ApplicationOffers", 1, applicationoffers.NewOffersAPI)
 	reg("ApplicationScaler", 1, applicationscaler.NewAPI)
@@ -146,12 +151,19 @@ func AllFacades() *facade.Registry {
 
 	reg("Controller", 3, controller.NewControllerAPIv3)
 	reg("Controller", 4, controller.NewControllerAPIv4)
+	reg("Controller", 5, controller.NewControllerAPIv5) // Version 5 adds ConfigSet.
 	reg("CrossModelRelations", 1, crossmodelrelations.NewStateCrossModelRelationsAPI)
 
 	reg("Deployer", 1, deployer.NewDeployerAPI)
 	reg("DiskManager", 2, diskmanager.NewDiskManagerAPI)
 	reg("Firewaller", 3, firewaller.NewStateFirewallerAPIV3)
 	reg("Firewaller", 4, firewaller.NewStateFirewallerAPIV4)
+	reg("Firewaller", 5, firewaller.NewStateFirewallerAPIV5)   // Version 5 adds GetMachineFirewallLockdown.
+	reg("Firewaller", 6, firewaller.NewStateFirewallerAPIV6)   // Version 6 adds GetMachineEgressPorts.
+	reg("Firewaller", 7, firewaller.NewStateFirewallerAPIV7)   // Version 7 adds ClassifyIngressRules.
+	reg("Firewaller", 8, firewaller.NewStateFirewallerAPIV8)   // Version 8 adds GetExposedSpaces.
+	reg("Firewaller", 9, firewaller.NewStateFirewallerAPIV9)   // Version 9 adds GetAllMachinePorts.
+	reg("Firewaller", 10, firewaller.NewStateFirewallerAPIV10) // Version 10 adds WatchControllerConfig.
 	reg("FirewallRules", 1, firewallrules.NewFacade)
 	reg("HighAvailability", 2, highavailability.NewHighAvailabilityAPI)
 	reg("HostKeyReporter", 1, hostkeyreporter.NewFacade)
@@ -174,6 +186,7 @@ func AllFacades() *facade.Registry {
 	reg("MachineManager", 2, machinemanager.NewFacade)
 	reg("MachineManager", 3, machinemanager.NewFacade)   // Version 3 adds DestroyMachine and ForceDestroyMachine.
 	reg("MachineManager", 4, machinemanager.NewFacadeV4) // Version 4 adds DestroyMachineWithParams.
+	reg("MachineManager", 5, machinemanager.NewFacadeV5) // Version 5 adds SetFirewallLockdown.
 
 	reg("MachineUndertaker", 1, machineundertaker.NewFacade)
 	reg("Machiner", 1, machine.NewMachinerAPI)
@@ -239,7 +252,8 @@ func AllFacades() *facade.Registry {
 	reg("Uniter", 4, uniter.NewUniterAPIV4)
 	reg("Uniter", 5, uniter.NewUniterAPIV5)
 	reg("Uniter", 6, uniter.NewUniterAPIV6)
-	reg("Uniter", 7, uniter.NewUniterAPI)
+	reg("Uniter", 7, uniter.NewUniterAPIV7)
+	reg("Uniter", 8, uniter.NewUniterAPI) // Version 8 adds OpenEgressPorts and CloseEgressPorts.
 
 	reg("Upgrader", 1, upgrader.NewUpgraderFacade)
 	reg("UserManager", 1, usermanager.NewUserManagerAPI)
@@ -0,0 +1,38 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/apiserver/facade"
+	"github.com/juju/juju/environs/config"
+)
+
+// ClientAddress returns the address, as host:port, from which the
+// client of the connection with the given resources connected, or ""
+// if it is not known.
+func ClientAddress(resources facade.Resources) string {
+	addr, _ := resources.Get("clientAddress").(StringResource)
+	return addr.String()
+}
+
+// CheckControllerAdminCIDRs returns an error if the given model config
+// attributes, set by the client with the given address, restrict the
+// controller's API and SSH ports to sources that do not include the
+// client, which would then be locked out of the controller.
+func CheckControllerAdminCIDRs(attrs map[string]interface{}, clientAddress string) error {
+	value, ok := attrs[config.ControllerAdminCIDRs]
+	if !ok {
+		return nil
+	}
+	// Values of the wrong type are rejected when the config is
+	// validated.
+	raw, _ := value.(string)
+	cidrs, err := config.ParseControllerAdminCIDRs(raw)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(config.CheckControllerAdminCIDRs(cidrs, clientAddress))
+}
@@ -8,6 +8,7 @@ import (
 	"gopkg.in/juju/names.v2"
 
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/storage"
@@ -19,7 +20,8 @@ import (
 func FilesystemParams(
 	f state.Filesystem,
 	storageInstance state.StorageInstance,
-	modelUUID, controllerUUID string,
+	modelUUID string,
+	controllerConfig controller.Config,
 	environConfig *config.Config,
 	poolManager poolmanager.PoolManager,
 	registry storage.ProviderRegistry,
@@ -39,7 +41,7 @@ func FilesystemParams(
 		size = filesystemInfo.Size
 	}
 
-	filesystemTags, err := storageTags(storageInstance, modelUUID, controllerUUID, environConfig)
+	filesystemTags, err := storageTags(storageInstance, modelUUID, controllerConfig, environConfig)
 	if err != nil {
 		return params.FilesystemParams{}, errors.Annotate(err, "computing storage tags")
 	}
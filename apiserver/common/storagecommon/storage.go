@@ -11,6 +11,7 @@ import (
 
 	"github.com/juju/juju/apiserver/common"
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/environs/tags"
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/storage"
@@ -271,15 +272,18 @@ func MaybeAssignedStorageInstance(
 }
 
 // storageTags returns the tags that should be set on a volume or filesystem,
-// if the provider supports them.
+// if the provider supports them. The tagger's tags take precedence over the
+// controller's default resource tags.
 func storageTags(
 	storageInstance state.StorageInstance,
-	modelUUID, controllerUUID string,
+	modelUUID string,
+	controllerConfig controller.Config,
 	tagger tags.ResourceTagger,
 ) (map[string]string, error) {
 	storageTags := tags.ResourceTags(
 		names.NewModelTag(modelUUID),
-		names.NewControllerTag(controllerUUID),
+		names.NewControllerTag(controllerConfig.ControllerUUID()),
+		controllerConfig,
 		tagger,
 	)
 	if storageInstance != nil {
@@ -8,6 +8,7 @@ import (
 	"gopkg.in/juju/names.v2"
 
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/storage"
@@ -19,7 +20,8 @@ import (
 func VolumeParams(
 	v state.Volume,
 	storageInstance state.StorageInstance,
-	modelUUID, controllerUUID string,
+	modelUUID string,
+	controllerConfig controller.Config,
 	environConfig *config.Config,
 	poolManager poolmanager.PoolManager,
 	registry storage.ProviderRegistry,
@@ -39,7 +41,7 @@ func VolumeParams(
 		size = volumeInfo.Size
 	}
 
-	volumeTags, err := storageTags(storageInstance, modelUUID, controllerUUID, environConfig)
+	volumeTags, err := storageTags(storageInstance, modelUUID, controllerConfig, environConfig)
 	if err != nil {
 		return params.VolumeParams{}, errors.Annotate(err, "computing storage tags")
 	}
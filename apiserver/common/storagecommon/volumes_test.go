@@ -10,6 +10,7 @@ import (
 
 	"github.com/juju/juju/apiserver/common/storagecommon"
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/environs/tags"
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/storage/provider"
@@ -40,7 +41,7 @@ func (*volumesSuite) testVolumeParams(c *gc.C, volumeParams *state.VolumeParams,
 		&fakeVolume{tag: tag, params: volumeParams, info: info},
 		nil, // StorageInstance
 		testing.ModelTag.Id(),
-		testing.ControllerTag.Id(),
+		testing.FakeControllerConfig(),
 		testing.CustomModelConfig(c, testing.Attrs{
 			"resource-tags": "a=b c=",
 		}),
@@ -71,7 +72,7 @@ func (*volumesSuite) TestVolumeParamsStorageTags(c *gc.C) {
 		}},
 		&fakeStorageInstance{tag: storageTag, owner: unitTag},
 		testing.ModelTag.Id(),
-		testing.ControllerTag.Id(),
+		testing.FakeControllerConfig(),
 		testing.CustomModelConfig(c, nil),
 		&fakePoolManager{},
 		provider.CommonStorageProviders(),
@@ -89,3 +90,28 @@ func (*volumesSuite) TestVolumeParamsStorageTags(c *gc.C) {
 		},
 	})
 }
+
+func (*volumesSuite) TestVolumeParamsControllerDefaultTags(c *gc.C) {
+	controllerConfig := testing.FakeControllerConfig()
+	controllerConfig[controller.DefaultResourceTagsKey] = "Environment=prod a=controller"
+	p, err := storagecommon.VolumeParams(
+		&fakeVolume{tag: names.NewVolumeTag("100"), params: &state.VolumeParams{
+			Pool: "loop", Size: 1024,
+		}},
+		nil, // StorageInstance
+		testing.ModelTag.Id(),
+		controllerConfig,
+		testing.CustomModelConfig(c, testing.Attrs{
+			"resource-tags": "a=b",
+		}),
+		&fakePoolManager{},
+		provider.CommonStorageProviders(),
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(p.Tags, jc.DeepEquals, map[string]string{
+		tags.JujuController: testing.ControllerTag.Id(),
+		tags.JujuModel:      testing.ModelTag.Id(),
+		"Environment":       "prod",
+		"a":                 "b",
+	})
+}
@@ -105,7 +105,7 @@ func TestingAPIHandler(c *gc.C, pool *state.StatePool, st *state.State) (*apiHan
 		statePool:     pool,
 		tag:           names.NewMachineTag("0"),
 	}
-	h, err := newAPIHandler(srv, st, nil, st.ModelUUID(), "testing.invalid:1234")
+	h, err := newAPIHandler(srv, st, nil, st.ModelUUID(), "testing.invalid:1234", "127.0.0.1:54321")
 	c.Assert(err, jc.ErrorIsNil)
 	return h, h.getResources()
 }
@@ -606,7 +606,7 @@ func (s *StorageProvisionerAPIv3) VolumeParams(args params.Entities) (params.Vol
 			return params.VolumeParams{}, err
 		}
 		volumeParams, err := storagecommon.VolumeParams(
-			volume, storageInstance, modelCfg.UUID(), controllerCfg.ControllerUUID(),
+			volume, storageInstance, modelCfg.UUID(), controllerCfg,
 			modelCfg, s.poolManager, s.registry,
 		)
 		if err != nil {
@@ -750,7 +750,7 @@ func (s *StorageProvisionerAPIv3) FilesystemParams(args params.Entities) (params
 			return params.FilesystemParams{}, err
 		}
 		filesystemParams, err := storagecommon.FilesystemParams(
-			filesystem, storageInstance, modelConfig.UUID(), controllerCfg.ControllerUUID(),
+			filesystem, storageInstance, modelConfig.UUID(), controllerCfg,
 			modelConfig, s.poolManager, s.registry,
 		)
 		if err != nil {
@@ -54,9 +54,15 @@ type UniterAPI struct {
 	StorageAPI
 }
 
+// UniterAPIV7 doesn't have the OpenEgressPorts and CloseEgressPorts
+// methods.
+type UniterAPIV7 struct {
+	UniterAPI
+}
+
 // UniterAPIV6 adds NetworkInfo as a preferred method to calling NetworkConfig.
 type UniterAPIV6 struct {
-	UniterAPI
+	UniterAPIV7
 }
 
 // UniterAPIV5 returns a RelationResultsV5 instead of RelationResults
@@ -164,14 +170,25 @@ func NewUniterAPI(st *state.State, resources facade.Resources, authorizer facade
 	}, nil
 }
 
+// NewUniterAPIV7 creates an instance of the V7 uniter API.
+func NewUniterAPIV7(st *state.State, resources facade.Resources, authorizer facade.Authorizer) (*UniterAPIV7, error) {
+	uniterAPI, err := NewUniterAPI(st, resources, authorizer)
+	if err != nil {
+		return nil, err
+	}
+	return &UniterAPIV7{
+		UniterAPI: *uniterAPI,
+	}, nil
+}
+
 // NewUniterAPIV6 creates an instance of the V6 uniter API.
 func NewUniterAPIV6(st *state.State, resources facade.Resources, authorizer facade.Authorizer) (*UniterAPIV6, error) {
-	uniterAPI, err := NewUniterAPI(st, resources, authorizer)
+	uniterAPI, err := NewUniterAPIV7(st, resources, authorizer)
 	if err != nil {
 		return nil, err
 	}
 	return &UniterAPIV6{
-		UniterAPI: *uniterAPI,
+		UniterAPIV7: *uniterAPI,
 	}, nil
 }
 
@@ -774,35 +791,33 @@ func (u *UniterAPI) SetWorkloadVersion(args params.EntityWorkloadVersions) (para
 // OpenPorts sets the policy of the port range with protocol to be
 // opened, for all given units.
 func (u *UniterAPI) OpenPorts(args params.EntitiesPortRanges) (params.ErrorResults, error) {
-	result := params.ErrorResults{
-		Results: make([]params.ErrorResult, len(args.Entities)),
-	}
-	canAccess, err := u.accessUnit()
-	if err != nil {
-		return params.ErrorResults{}, err
-	}
-	for i, entity := range args.Entities {
-		tag, err := names.ParseUnitTag(entity.Tag)
-		if err != nil {
-			result.Results[i].Error = common.ServerError(common.ErrPerm)
-			continue
-		}
-		err = common.ErrPerm
-		if canAccess(tag) {
-			var unit *state.Unit
-			unit, err = u.getUnit(tag)
-			if err == nil {
-				err = unit.OpenPorts(entity.Protocol, entity.FromPort, entity.ToPort)
-			}
-		}
-		result.Results[i].Error = common.ServerError(err)
-	}
-	return result, nil
+	return u.updatePorts(args, (*state.Unit).OpenPorts)
 }
 
 // ClosePorts sets the policy of the port range with protocol to be
 // closed, for all given units.
 func (u *UniterAPI) ClosePorts(args params.EntitiesPortRanges) (params.ErrorResults, error) {
+	return u.updatePorts(args, (*state.Unit).ClosePorts)
+}
+
+// OpenEgressPorts records that each given unit connects out to the port
+// range with protocol on other hosts.
+func (u *UniterAPI) OpenEgressPorts(args params.EntitiesPortRanges) (params.ErrorResults, error) {
+	return u.updatePorts(args, (*state.Unit).OpenEgressPorts)
+}
+
+// CloseEgressPorts removes the egress port range with protocol for all
+// given units.
+func (u *UniterAPI) CloseEgressPorts(args params.EntitiesPortRanges) (params.ErrorResults, error) {
+	return u.updatePorts(args, (*state.Unit).CloseEgressPorts)
+}
+
+// updatePorts calls update with the port range with protocol of each
+// given unit that can be accessed.
+func (u *UniterAPI) updatePorts(
+	args params.EntitiesPortRanges,
+	update func(unit *state.Unit, protocol string, fromPort, toPort int) error,
+) (params.ErrorResults, error) {
 	result := params.ErrorResults{
 		Results: make([]params.ErrorResult, len(args.Entities)),
 	}
@@ -821,7 +836,7 @@ func (u *UniterAPI) ClosePorts(args params.EntitiesPortRanges) (params.ErrorResu
 			var unit *state.Unit
 			unit, err = u.getUnit(tag)
 			if err == nil {
-				err = unit.ClosePorts(entity.Protocol, entity.FromPort, entity.ToPort)
+				err = update(unit, entity.Protocol, entity.FromPort, entity.ToPort)
 			}
 		}
 		result.Results[i].Error = common.ServerError(err)
@@ -2148,3 +2163,9 @@ func (u *UniterAPIV4) NetworkInfo(_, _ struct{}) {}
 
 // WatchUnitRelations isn't on the V4 API.
 func (u *UniterAPIV4) WatchUnitRelations(_, _ struct{}) {}
+
+// OpenEgressPorts isn't on the V7 API.
+func (u *UniterAPIV7) OpenEgressPorts(_, _ struct{}) {}
+
+// CloseEgressPorts isn't on the V7 API.
+func (u *UniterAPIV7) CloseEgressPorts(_, _ struct{}) {}
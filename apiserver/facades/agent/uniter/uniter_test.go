@@ -921,6 +921,41 @@ func (s *uniterSuite) TestOpenPorts(c *gc.C) {
 	})
 }
 
+func (s *uniterSuite) TestOpenCloseEgressPorts(c *gc.C) {
+	args := params.EntitiesPortRanges{Entities: []params.EntityPortRange{
+		{Tag: "unit-mysql-0", Protocol: "tcp", FromPort: 443, ToPort: 443},
+		{Tag: "unit-wordpress-0", Protocol: "tcp", FromPort: 443, ToPort: 443},
+		{Tag: "unit-foo-42", Protocol: "tcp", FromPort: 42, ToPort: 42},
+	}}
+	expected := params.ErrorResults{
+		Results: []params.ErrorResult{
+			{apiservertesting.ErrUnauthorized},
+			{nil},
+			{apiservertesting.ErrUnauthorized},
+		},
+	}
+	result, err := s.uniter.OpenEgressPorts(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, gc.DeepEquals, expected)
+
+	egressPorts, err := s.wordpressUnit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(egressPorts, gc.DeepEquals, []network.PortRange{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443},
+	})
+	openedPorts, err := s.wordpressUnit.OpenedPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(openedPorts, gc.HasLen, 0)
+
+	result, err = s.uniter.CloseEgressPorts(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, gc.DeepEquals, expected)
+
+	egressPorts, err = s.wordpressUnit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(egressPorts, gc.HasLen, 0)
+}
+
 func (s *uniterSuite) TestClosePorts(c *gc.C) {
 	// Open port udp:4321 in advance on wordpressUnit.
 	err := s.wordpressUnit.OpenPorts("udp", 4321, 5000)
@@ -7,8 +7,12 @@
 package application
 
 import (
+	"bytes"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -37,15 +41,36 @@ var logger = loggo.GetLogger("juju.apiserver.application")
 
 // APIv4 provides the Application API facade for versions 1-4.
 type APIv4 struct {
+	*APIv5
+}
+
+// APIv5 provides the Application API facade for version 5.
+type APIv5 struct {
+	*APIv6
+}
+
+// APIv6 provides the Application API facade for version 6.
+type APIv6 struct {
+	*APIv7
+}
+
+// APIv7 provides the Application API facade for version 7.
+type APIv7 struct {
+	*APIv9
+}
+
+// APIv9 provides the Application API facade for versions 8 and 9.
+type APIv9 struct {
 	*API
 }
 
 // API implements the application interface and is the concrete
 // implementation of the api end point. API provides the
-// Application API facades for versions 1-4.
+// Application API facade for version 10.
 type API struct {
 	backend    Backend
 	authorizer facade.Authorizer
+	resources  facade.Resources
 	check      BlockChecker
 
 	// TODO(axw) stateCharm only exists because I ran out
@@ -65,7 +90,47 @@ func NewFacadeV4(ctx facade.Context) (*APIv4, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	return &APIv4{api}, nil
+	return &APIv4{&APIv5{&APIv6{&APIv7{&APIv9{api}}}}}, nil
+}
+
+// NewFacadeV5 provides the signature required for facade registration
+// for version 5.
+func NewFacadeV5(ctx facade.Context) (*APIv5, error) {
+	api, err := NewFacade(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &APIv5{&APIv6{&APIv7{&APIv9{api}}}}, nil
+}
+
+// NewFacadeV6 provides the signature required for facade registration
+// for version 6.
+func NewFacadeV6(ctx facade.Context) (*APIv6, error) {
+	api, err := NewFacade(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &APIv6{&APIv7{&APIv9{api}}}, nil
+}
+
+// NewFacadeV7 provides the signature required for facade registration
+// for version 7.
+func NewFacadeV7(ctx facade.Context) (*APIv7, error) {
+	api, err := NewFacade(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &APIv7{&APIv9{api}}, nil
+}
+
+// NewFacadeV9 provides the signature required for facade registration
+// for versions 8 and 9.
+func NewFacadeV9(ctx facade.Context) (*APIv9, error) {
+	api, err := NewFacade(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &APIv9{api}, nil
 }
 
 // NewFacade provides the signature required for facade registration.
@@ -79,6 +144,7 @@ func NewFacade(ctx facade.Context) (*API, error) {
 	return NewAPI(
 		backend,
 		ctx.Auth(),
+		ctx.Resources(),
 		blockChecker,
 		stateCharm,
 		DeployApplication,
@@ -89,6 +155,7 @@ func NewFacade(ctx facade.Context) (*API, error) {
 func NewAPI(
 	backend Backend,
 	authorizer facade.Authorizer,
+	resources facade.Resources,
 	blockChecker BlockChecker,
 	stateCharm func(Charm) *state.Charm,
 	deployApplication func(ApplicationDeployer, DeployApplicationParams) (Application, error),
@@ -99,6 +166,7 @@ func NewAPI(
 	return &API{
 		backend:               backend,
 		authorizer:            authorizer,
+		resources:             resources,
 		check:                 blockChecker,
 		stateCharm:            stateCharm,
 		deployApplicationFunc: deployApplication,
@@ -330,6 +398,12 @@ func (api *API) Update(args params.ApplicationUpdate) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	// Nothing is updated if the settings cannot be.
+	if args.SettingsYAML != "" || len(args.SettingsStrings) > 0 {
+		if err := checkConfigUnlocked(args.ApplicationName, app); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	// Set the charm for the given application.
 	if args.CharmURL != "" {
 		// For now we do not support changing the channel through Update().
@@ -359,10 +433,16 @@ func (api *API) Update(args params.ApplicationUpdate) error {
 	// Set up application's settings.
 	if args.SettingsYAML != "" {
 		if err = applicationSetSettingsYAML(args.ApplicationName, app, args.SettingsYAML); err != nil {
+			err = redactSettingsError(err, redactedSettingValues(
+				app, yamlSettingValues(args.ApplicationName, args.SettingsYAML), args.RedactInLogs,
+			))
 			return errors.Annotate(err, "setting configuration from YAML")
 		}
 	} else if len(args.SettingsStrings) > 0 {
 		if err = ApplicationSetSettingsStrings(app, args.SettingsStrings); err != nil {
+			err = redactSettingsError(err, redactedSettingValues(
+				app, stringSettingValues(args.SettingsStrings), args.RedactInLogs,
+			))
 			return errors.Trace(err)
 		}
 	}
@@ -462,6 +542,12 @@ func (api *API) applicationSetCharm(
 	resourceIDs map[string]string,
 	storageConstraints map[string]params.StorageConstraints,
 ) error {
+	// Nothing is upgraded if the settings cannot be changed.
+	if configSettingsYAML != "" || len(configSettingsStrings) > 0 {
+		if err := checkConfigUnlocked(appName, application); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	curl, err := charm.ParseURL(url)
 	if err != nil {
 		return errors.Trace(err)
@@ -562,6 +648,154 @@ func applicationSetSettingsYAML(appName string, application Application, setting
 	return errors.Annotate(application.UpdateConfigSettings(changes), "updating settings")
 }
 
+// sensitiveOptionMarker marks a charm config option as holding a
+// secret, such as a password, whose value the controller must keep out
+// of the errors it returns, and so out of its logs. The charm config
+// schema has no field for this, so an option is marked by starting its
+// description with the marker.
+const sensitiveOptionMarker = "[sensitive]"
+
+// isSensitiveOption reports whether the charm config schema marks the
+// given option as sensitive.
+func isSensitiveOption(option charm.Option) bool {
+	return strings.HasPrefix(strings.TrimSpace(option.Description), sensitiveOptionMarker)
+}
+
+// redactedSettingValues returns the values of the given settings,
+// which are keyed by option, that must be kept out of errors: those of the options
+// marked sensitive in the application's charm config schema, or all of
+// them if all is true. If the schema cannot be read, all of the values
+// are returned.
+func redactedSettingValues(app Application, settings map[string][]string, all bool) []string {
+	var options map[string]charm.Option
+	if !all {
+		ch, _, err := app.Charm()
+		if err != nil {
+			all = true
+		} else if cfg := ch.Config(); cfg != nil {
+			options = cfg.Options
+		}
+	}
+	var values []string
+	for key, keyValues := range settings {
+		if all || isSensitiveOption(options[key]) {
+			values = append(values, keyValues...)
+		}
+	}
+	return values
+}
+
+// redactSettingsError returns err with each of the given setting values
+// replaced in its message by params.RedactedValue. Validation errors
+// quote the values they reject, and errors are logged by the API server,
+// so values that must not be logged must not appear in them. A value is
+// only replaced where it appears whole, so that short values such as
+// "1" or "true" do not mangle the rest of the message.
+func redactSettingsError(err error, values []string) error {
+	msg := err.Error()
+	redacted := msg
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		redacted = strings.Replace(redacted, strconv.Quote(value), strconv.Quote(params.RedactedValue), -1)
+		redacted = replaceWhole(redacted, value, params.RedactedValue)
+	}
+	if redacted == msg {
+		return err
+	}
+	return errors.New(redacted)
+}
+
+// replaceWhole returns s with each occurrence of old that is not part
+// of a longer word, number or name replaced by new.
+func replaceWhole(s, old, new string) string {
+	var buf bytes.Buffer
+	i := 0
+	for {
+		j := strings.Index(s[i:], old)
+		if j < 0 {
+			break
+		}
+		start, end := i+j, i+j+len(old)
+		if (start > 0 && isWordByte(s[start-1])) || (end < len(s) && isWordByte(s[end])) {
+			// Look for the next occurrence from the following byte.
+			buf.WriteString(s[i : start+1])
+			i = start + 1
+			continue
+		}
+		buf.WriteString(s[i:start])
+		buf.WriteString(new)
+		i = end
+	}
+	buf.WriteString(s[i:])
+	return buf.String()
+}
+
+// isWordByte reports whether b may be part of a word, number or name.
+// Bytes of multi-byte UTF-8 characters are treated as letters.
+func isWordByte(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	case b == '_', b == '-', b == '.', b >= utf8.RuneSelf:
+		return true
+	}
+	return false
+}
+
+// stringSettingValues returns the values of the given settings, keyed
+// by option.
+func stringSettingValues(settings map[string]string) map[string][]string {
+	values := make(map[string][]string, len(settings))
+	for key, value := range settings {
+		values[key] = []string{value}
+	}
+	return values
+}
+
+// yamlSettingValues returns the scalar values found in the given
+// settings YAML for the named application, formatted as strings and
+// keyed by option. The YAML may also be in the format output by get.
+// Invalid YAML holds no values.
+func yamlSettingValues(appName, settings string) map[string][]string {
+	var all map[string]interface{}
+	if err := goyaml.Unmarshal([]byte(settings), &all); err != nil {
+		return nil
+	}
+	options, ok := all[appName].(map[interface{}]interface{})
+	if !ok {
+		// Each option's value is held under "value".
+		options = make(map[interface{}]interface{})
+		for key, setting := range all {
+			if setting, ok := setting.(map[interface{}]interface{}); ok {
+				options[key] = setting["value"]
+			}
+		}
+	}
+	values := make(map[string][]string)
+	for key, value := range options {
+		var collect func(interface{})
+		collect = func(v interface{}) {
+			switch v := v.(type) {
+			case map[interface{}]interface{}:
+				for _, item := range v {
+					collect(item)
+				}
+			case []interface{}:
+				for _, item := range v {
+					collect(item)
+				}
+			case nil:
+			default:
+				values[fmt.Sprint(key)] = append(values[fmt.Sprint(key)], fmt.Sprint(v))
+			}
+		}
+		collect(value)
+	}
+	return values
+}
+
 // GetCharmURL returns the charm URL the given application is
 // running at present.
 func (api *API) GetCharmURL(args params.ApplicationGet) (params.StringResult, error) {
@@ -590,6 +824,9 @@ func (api *API) Set(p params.ApplicationSet) error {
 	if err != nil {
 		return err
 	}
+	if err := checkConfigUnlocked(p.ApplicationName, app); err != nil {
+		return errors.Trace(err)
+	}
 	ch, _, err := app.Charm()
 	if err != nil {
 		return err
@@ -597,6 +834,9 @@ func (api *API) Set(p params.ApplicationSet) error {
 	// Validate the settings.
 	changes, err := ch.Config().ParseSettingsStrings(p.Options)
 	if err != nil {
+		err = redactSettingsError(err, redactedSettingValues(
+			app, stringSettingValues(p.Options), p.RedactInLogs,
+		))
 		return err
 	}
 
@@ -616,6 +856,9 @@ func (api *API) Unset(p params.ApplicationUnset) error {
 	if err != nil {
 		return err
 	}
+	if err := checkConfigUnlocked(p.ApplicationName, app); err != nil {
+		return errors.Trace(err)
+	}
 	settings := make(charm.Settings)
 	for _, option := range p.Options {
 		settings[option] = nil
@@ -623,6 +866,31 @@ func (api *API) Unset(p params.ApplicationUnset) error {
 	return app.UpdateConfigSettings(settings)
 }
 
+// SetConfigLocked locks or unlocks an application's settings. While
+// they are locked, Set, Unset and Update refuse to change them.
+func (api *API) SetConfigLocked(args params.ApplicationSetConfigLocked) error {
+	if err := api.checkCanWrite(); err != nil {
+		return err
+	}
+	if err := api.check.ChangeAllowed(); err != nil {
+		return errors.Trace(err)
+	}
+	app, err := api.backend.Application(args.ApplicationName)
+	if err != nil {
+		return err
+	}
+	return app.SetConfigLocked(args.Locked)
+}
+
+// checkConfigUnlocked returns an error if the named application's
+// settings are locked against change.
+func checkConfigUnlocked(appName string, app Application) error {
+	if app.IsConfigLocked() {
+		return errors.Errorf("cannot change settings of application %q: config locked", appName)
+	}
+	return nil
+}
+
 // CharmRelations implements the server side of Application.CharmRelations.
 func (api *API) CharmRelations(p params.ApplicationCharmRelations) (params.ApplicationCharmRelationsResults, error) {
 	var results params.ApplicationCharmRelationsResults
@@ -93,6 +93,7 @@ func (s *applicationSuite) makeAPI(c *gc.C) *application.API {
 	api, err := application.NewAPI(
 		backend,
 		s.authorizer,
+		resources,
 		blockChecker,
 		application.CharmToStateCharm,
 		application.DeployApplication,
@@ -1547,6 +1548,31 @@ func (s *applicationSuite) TestApplicationSet(c *gc.C) {
 	})
 }
 
+func (s *applicationSuite) TestApplicationSetRedactInLogs(c *gc.C) {
+	s.AddTestingApplication(c, "dummy", s.AddTestingCharm(c, "dummy"))
+
+	err := s.applicationAPI.Set(params.ApplicationSet{
+		ApplicationName: "dummy",
+		Options:         map[string]string{"skill-level": "s3cret"},
+		RedactInLogs:    true,
+	})
+	c.Assert(err, gc.ErrorMatches, `.*"skill-level".*`)
+	c.Assert(err, gc.Not(gc.ErrorMatches), ".*s3cret.*")
+	c.Assert(err, gc.ErrorMatches, ".*<redacted>.*")
+}
+
+func (s *applicationSuite) TestApplicationUpdateRedactInLogs(c *gc.C) {
+	s.AddTestingApplication(c, "dummy", s.AddTestingCharm(c, "dummy"))
+
+	err := s.applicationAPI.Update(params.ApplicationUpdate{
+		ApplicationName: "dummy",
+		SettingsYAML:    "dummy:\n  skill-level: s3cret\n",
+		RedactInLogs:    true,
+	})
+	c.Assert(err, gc.ErrorMatches, `setting configuration from YAML: .*"skill-level".*`)
+	c.Assert(err, gc.Not(gc.ErrorMatches), ".*s3cret.*")
+}
+
 func (s *applicationSuite) assertApplicationSetBlocked(c *gc.C, dummy *state.Application, msg string) {
 	err := s.applicationAPI.Set(params.ApplicationSet{
 		ApplicationName: "dummy",
@@ -1671,6 +1697,65 @@ func (s *applicationSuite) TestBlockChangesServerUnset(c *gc.C) {
 	s.assertServerUnsetBlocked(c, dummy, "TestBlockChangesServerUnset")
 }
 
+func (s *applicationSuite) TestSetConfigLocked(c *gc.C) {
+	dummy := s.AddTestingApplication(c, "dummy", s.AddTestingCharm(c, "dummy"))
+	err := s.applicationAPI.Set(params.ApplicationSet{ApplicationName: "dummy", Options: map[string]string{
+		"title": "foobar",
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = s.applicationAPI.SetConfigLocked(params.ApplicationSetConfigLocked{ApplicationName: "dummy", Locked: true})
+	c.Assert(err, jc.ErrorIsNil)
+	err = dummy.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dummy.IsConfigLocked(), jc.IsTrue)
+
+	// Set, Unset and Update all refuse to change the settings, and
+	// Update changes nothing else either.
+	err = s.applicationAPI.Set(params.ApplicationSet{ApplicationName: "dummy", Options: map[string]string{
+		"username": "user name",
+	}})
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "dummy": config locked`)
+	err = s.applicationAPI.Unset(params.ApplicationUnset{ApplicationName: "dummy", Options: []string{"title"}})
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "dummy": config locked`)
+	minUnits := 2
+	err = s.applicationAPI.Update(params.ApplicationUpdate{
+		ApplicationName: "dummy",
+		MinUnits:        &minUnits,
+		SettingsStrings: map[string]string{"username": "user name"},
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "dummy": config locked`)
+	settings, err := dummy.ConfigSettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(settings, gc.DeepEquals, charm.Settings{"title": "foobar"})
+	err = dummy.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dummy.MinUnits(), gc.Equals, 0)
+
+	// Getting the settings still works, and reports the lock.
+	results, err := s.applicationAPI.Get(params.ApplicationGet{ApplicationName: "dummy"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results.ConfigLocked, jc.IsTrue)
+
+	err = s.applicationAPI.SetConfigLocked(params.ApplicationSetConfigLocked{ApplicationName: "dummy", Locked: false})
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.applicationAPI.Unset(params.ApplicationUnset{ApplicationName: "dummy", Options: []string{"title"}})
+	c.Assert(err, jc.ErrorIsNil)
+	settings, err = dummy.ConfigSettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(settings, gc.HasLen, 0)
+	results, err = s.applicationAPI.Get(params.ApplicationGet{ApplicationName: "dummy"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results.ConfigLocked, jc.IsFalse)
+}
+
+func (s *applicationSuite) TestBlockChangesSetConfigLocked(c *gc.C) {
+	s.AddTestingApplication(c, "dummy", s.AddTestingCharm(c, "dummy"))
+	s.BlockAllChanges(c, "TestBlockChangesSetConfigLocked")
+	err := s.applicationAPI.SetConfigLocked(params.ApplicationSetConfigLocked{ApplicationName: "dummy", Locked: true})
+	s.AssertBlocked(c, err, "TestBlockChangesSetConfigLocked")
+}
+
 var clientAddApplicationUnitsTests = []struct {
 	about       string
 	application string // if not set, defaults to 'dummy'
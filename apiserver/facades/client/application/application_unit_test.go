@@ -13,6 +13,7 @@ import (
 	"gopkg.in/juju/names.v2"
 	"gopkg.in/macaroon.v1"
 
+	"github.com/juju/juju/apiserver/common"
 	"github.com/juju/juju/apiserver/facades/client/application"
 	"github.com/juju/juju/apiserver/params"
 	apiservertesting "github.com/juju/juju/apiserver/testing"
@@ -44,6 +45,7 @@ func (s *ApplicationSuite) setAPIUser(c *gc.C, user names.UserTag) {
 	api, err := application.NewAPI(
 		&s.backend,
 		s.authorizer,
+		common.NewResources(),
 		&s.blockChecker,
 		func(application.Charm) *state.Charm {
 			return &state.Charm{}
@@ -151,6 +153,7 @@ func (s *ApplicationSuite) SetUpTest(c *gc.C) {
 	api, err := application.NewAPI(
 		&s.backend,
 		s.authorizer,
+		common.NewResources(),
 		&s.blockChecker,
 		func(application.Charm) *state.Charm {
 			return &state.Charm{}
@@ -207,8 +210,8 @@ func (s *ApplicationSuite) TestSetCharmConfigSettings(c *gc.C) {
 	s.backend.CheckCallNames(c, "ModelTag", "Application", "Charm")
 	s.backend.charm.CheckCallNames(c, "Config")
 	app := s.backend.applications["postgresql"].(*mockApplication)
-	app.CheckCallNames(c, "SetCharm")
-	app.CheckCall(c, 0, "SetCharm", state.SetCharmConfig{
+	app.CheckCallNames(c, "IsConfigLocked", "SetCharm")
+	app.CheckCall(c, 1, "SetCharm", state.SetCharmConfig{
 		Charm:          &state.Charm{},
 		ConfigSettings: charm.Settings{"stringOption": "value"},
 	})
@@ -227,13 +230,82 @@ postgresql:
 	s.backend.CheckCallNames(c, "ModelTag", "Application", "Charm")
 	s.backend.charm.CheckCallNames(c, "Config")
 	app := s.backend.applications["postgresql"].(*mockApplication)
-	app.CheckCallNames(c, "SetCharm")
-	app.CheckCall(c, 0, "SetCharm", state.SetCharmConfig{
+	app.CheckCallNames(c, "IsConfigLocked", "SetCharm")
+	app.CheckCall(c, 1, "SetCharm", state.SetCharmConfig{
 		Charm:          &state.Charm{},
 		ConfigSettings: charm.Settings{"stringOption": "value"},
 	})
 }
 
+func (s *ApplicationSuite) TestSetCharmConfigSettingsConfigLocked(c *gc.C) {
+	app := s.backend.applications["postgresql"].(*mockApplication)
+	app.configLocked = true
+	err := s.api.SetCharm(params.ApplicationSetCharm{
+		ApplicationName: "postgresql",
+		CharmURL:        "cs:postgresql",
+		ConfigSettings:  map[string]string{"stringOption": "value"},
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "postgresql": config locked`)
+	s.backend.CheckCallNames(c, "ModelTag", "Application")
+	app.CheckCallNames(c, "IsConfigLocked")
+}
+
+func (s *ApplicationSuite) TestSetCharmConfigLockedWithoutSettings(c *gc.C) {
+	app := s.backend.applications["postgresql"].(*mockApplication)
+	app.configLocked = true
+	err := s.api.SetCharm(params.ApplicationSetCharm{
+		ApplicationName: "postgresql",
+		CharmURL:        "cs:postgresql",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	app.CheckCallNames(c, "SetCharm")
+}
+
+func (s *ApplicationSuite) TestSetRedactsSensitiveValuesInErrors(c *gc.C) {
+	app := s.backend.applications["postgresql"].(*mockApplication)
+	app.charm.config.Options["secretOption"] = charm.Option{
+		Type:        "int",
+		Description: "[sensitive] A secret number.",
+	}
+
+	// The values of options marked sensitive in the charm's config
+	// schema are kept out of errors without the client asking; a
+	// short value does not mangle the rest of the message.
+	err := s.api.Set(params.ApplicationSet{
+		ApplicationName: "postgresql",
+		Options:         map[string]string{"secretOption": "t"},
+	})
+	c.Assert(err, gc.ErrorMatches, `option "secretOption" expected int, got "<redacted>"`)
+
+	// Those of other options are not, unless the client asks.
+	err = s.api.Set(params.ApplicationSet{
+		ApplicationName: "postgresql",
+		Options:         map[string]string{"intOption": "t"},
+	})
+	c.Assert(err, gc.ErrorMatches, `option "intOption" expected int, got "t"`)
+	err = s.api.Set(params.ApplicationSet{
+		ApplicationName: "postgresql",
+		Options:         map[string]string{"intOption": "t"},
+		RedactInLogs:    true,
+	})
+	c.Assert(err, gc.ErrorMatches, `option "intOption" expected int, got "<redacted>"`)
+}
+
+func (s *ApplicationSuite) TestUpdateRedactsSensitiveValuesInErrors(c *gc.C) {
+	app := s.backend.applications["postgresql"].(*mockApplication)
+	app.charm.config.Options["secretOption"] = charm.Option{
+		Type:        "int",
+		Description: "[sensitive] A secret number.",
+	}
+
+	err := s.api.Update(params.ApplicationUpdate{
+		ApplicationName: "postgresql",
+		SettingsYAML:    "postgresql:\n  secretOption: s3cret\n",
+	})
+	c.Assert(err, gc.ErrorMatches, `setting configuration from YAML: .*"secretOption".*`)
+	c.Assert(err, gc.Not(gc.ErrorMatches), ".*s3cret.*")
+}
+
 func (s *ApplicationSuite) TestDestroyRelation(c *gc.C) {
 	err := s.api.DestroyRelation(params.DestroyRelation{Endpoints: []string{"a", "b"}})
 	c.Assert(err, jc.ErrorIsNil)
@@ -450,6 +522,7 @@ func (s *ApplicationSuite) TestSetRelationStatusPermissionDenied(c *gc.C) {
 	api, err := application.NewAPI(
 		&s.backend,
 		s.authorizer,
+		common.NewResources(),
 		&s.blockChecker,
 		func(application.Charm) *state.Charm {
 			return &state.Charm{}
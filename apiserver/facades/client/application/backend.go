@@ -4,6 +4,8 @@
 package application
 
 import (
+	"time"
+
 	"gopkg.in/juju/charm.v6-unstable"
 	csparams "gopkg.in/juju/charmrepo.v2-unstable/csclient/params"
 	"gopkg.in/juju/names.v2"
@@ -62,19 +64,24 @@ type Application interface {
 	CharmURL() (*charm.URL, bool)
 	Channel() csparams.Channel
 	ClearExposed() error
+	ConfigChangeTimes() map[string]time.Time
 	ConfigSettings() (charm.Settings, error)
 	Constraints() (constraints.Value, error)
 	Destroy() error
 	Endpoints() ([]state.Endpoint, error)
+	IsConfigLocked() bool
 	IsPrincipal() bool
+	PreviousConfigSettings() (charm.Settings, error)
 	Series() string
 	SetCharm(state.SetCharmConfig) error
+	SetConfigLocked(bool) error
 	SetConstraints(constraints.Value) error
 	SetExposed() error
 	SetMetricCredentials([]byte) error
 	SetMinUnits(int) error
 	UpdateApplicationSeries(string, bool) error
 	UpdateConfigSettings(charm.Settings) error
+	WatchConfigSettings() state.NotifyWatcher
 }
 
 // Charm defines a subset of the functionality provided by the
@@ -6,4 +6,5 @@ package application
 var (
 	ParseSettingsCompatible = parseSettingsCompatible
 	NewStateStorage         = &newStateStorage
+	UnknownSettings         = unknownSettings
 )
@@ -4,10 +4,17 @@
 package application
 
 import (
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
 	"gopkg.in/juju/charm.v6-unstable"
+	"gopkg.in/juju/names.v2"
 
+	"github.com/juju/juju/apiserver/common"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/constraints"
+	"github.com/juju/juju/state/watcher"
 )
 
 // Get returns the configuration for a service.
@@ -35,15 +42,122 @@ func (api *API) Get(args params.ApplicationGet) (params.ApplicationGetResults, e
 			return params.ApplicationGetResults{}, err
 		}
 	}
+	var configChanged map[string]time.Time
+	if changed := app.ConfigChangeTimes(); len(changed) > 0 {
+		configChanged = changed
+	}
 	return params.ApplicationGetResults{
-		Application: args.ApplicationName,
-		Charm:       charm.Meta().Name,
-		Config:      configInfo,
-		Constraints: constraints,
-		Series:      app.Series(),
+		Application:     args.ApplicationName,
+		Charm:           charm.Meta().Name,
+		Config:          configInfo,
+		Constraints:     constraints,
+		Series:          app.Series(),
+		UnknownSettings: unknownSettings(settings, charm.Config()),
+		ConfigChanged:   configChanged,
+		ConfigLocked:    app.IsConfigLocked(),
 	}, nil
 }
 
+// WatchConfig returns a NotifyWatcher for each given application, which
+// notifies of changes to the application's configuration settings.
+func (api *API) WatchConfig(args params.Entities) (params.NotifyWatchResults, error) {
+	if err := api.checkCanRead(); err != nil {
+		return params.NotifyWatchResults{}, errors.Trace(err)
+	}
+	result := params.NotifyWatchResults{
+		Results: make([]params.NotifyWatchResult, len(args.Entities)),
+	}
+	for i, entity := range args.Entities {
+		id, err := api.watchConfig(entity.Tag)
+		result.Results[i].NotifyWatcherId = id
+		result.Results[i].Error = common.ServerError(err)
+	}
+	return result, nil
+}
+
+func (api *API) watchConfig(tagString string) (string, error) {
+	tag, err := names.ParseApplicationTag(tagString)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	app, err := api.backend.Application(tag.Id())
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	w := app.WatchConfigSettings()
+	// Consume the initial event. Technically, API
+	// calls to Watch 'transmit' the initial event
+	// in the Watch response. But NotifyWatchers
+	// have no state to transmit.
+	if _, ok := <-w.Changes(); ok {
+		return api.resources.Register(w), nil
+	}
+	return "", watcher.EnsureErr(w)
+}
+
+// PreviousConfig returns, for each given application, its config
+// settings as they were before the most recent change to them.
+func (api *API) PreviousConfig(args params.Entities) (params.ConfigSettingsResults, error) {
+	if err := api.checkCanRead(); err != nil {
+		return params.ConfigSettingsResults{}, errors.Trace(err)
+	}
+	result := params.ConfigSettingsResults{
+		Results: make([]params.ConfigSettingsResult, len(args.Entities)),
+	}
+	for i, entity := range args.Entities {
+		settings, err := api.previousConfig(entity.Tag)
+		result.Results[i].Settings = params.ConfigSettings(settings)
+		result.Results[i].Error = common.ServerError(err)
+	}
+	return result, nil
+}
+
+func (api *API) previousConfig(tagString string) (charm.Settings, error) {
+	tag, err := names.ParseApplicationTag(tagString)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	app, err := api.backend.Application(tag.Id())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return app.PreviousConfigSettings()
+}
+
+// Mask the new methods from the V9 API. The API reflection code in
+// rpc/rpcreflect/type.go:newMethod skips 2-argument methods, so this
+// removes the method as far as the RPC machinery is concerned.
+
+// SetConfigLocked isn't on the V9 API.
+func (*APIv9) SetConfigLocked(_, _ struct{}) {}
+
+// Mask the new methods from the V6 API. The API reflection code in
+// rpc/rpcreflect/type.go:newMethod skips 2-argument methods, so this
+// removes the method as far as the RPC machinery is concerned.
+
+// PreviousConfig isn't on the V6 API.
+func (*APIv6) PreviousConfig(_, _ struct{}) {}
+
+// Mask the new methods from the V5 API. The API reflection code in
+// rpc/rpcreflect/type.go:newMethod skips 2-argument methods, so this
+// removes the method as far as the RPC machinery is concerned.
+
+// WatchConfig isn't on the V5 API.
+func (*APIv5) WatchConfig(_, _ struct{}) {}
+
+// unknownSettings returns the sorted names of any settings that are not
+// defined by the charm config.
+func unknownSettings(settings charm.Settings, config *charm.Config) []string {
+	var unknown []string
+	for name := range settings {
+		if _, ok := config.Options[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
 func describe(settings charm.Settings, config *charm.Config) map[string]interface{} {
 	results := make(map[string]interface{})
 	for name, option := range config.Options {
@@ -51,6 +165,9 @@ func describe(settings charm.Settings, config *charm.Config) map[string]interfac
 			"description": option.Description,
 			"type":        option.Type,
 		}
+		if option.Default != nil {
+			info["default"] = option.Default
+		}
 		if value := settings[name]; value != nil {
 			info["value"] = value
 		} else {
@@ -9,6 +9,7 @@ import (
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 	"gopkg.in/juju/charm.v6-unstable"
+	"gopkg.in/juju/names.v2"
 
 	apiapplication "github.com/juju/juju/api/application"
 	"github.com/juju/juju/apiserver/common"
@@ -17,6 +18,8 @@ import (
 	apiservertesting "github.com/juju/juju/apiserver/testing"
 	"github.com/juju/juju/constraints"
 	jujutesting "github.com/juju/juju/juju/testing"
+	"github.com/juju/juju/state"
+	statetesting "github.com/juju/juju/state/testing"
 )
 
 type getSuite struct {
@@ -24,6 +27,7 @@ type getSuite struct {
 
 	serviceAPI *application.API
 	authorizer apiservertesting.FakeAuthorizer
+	resources  *common.Resources
 }
 
 var _ = gc.Suite(&getSuite{})
@@ -37,9 +41,12 @@ func (s *getSuite) SetUpTest(c *gc.C) {
 	backend, err := application.NewStateBackend(s.State)
 	c.Assert(err, jc.ErrorIsNil)
 	blockChecker := common.NewBlockChecker(s.State)
+	s.resources = common.NewResources()
+	s.AddCleanup(func(*gc.C) { s.resources.StopAll() })
 	s.serviceAPI, err = application.NewAPI(
 		backend,
 		s.authorizer,
+		s.resources,
 		blockChecker,
 		application.CharmToStateCharm,
 		application.DeployApplication,
@@ -58,6 +65,7 @@ func (s *getSuite) TestClientServiceGetSmoketest(c *gc.C) {
 			"blog-title": map[string]interface{}{
 				"type":        "string",
 				"value":       "My Title",
+				"default":     "My Title",
 				"description": "A descriptive title used for the blog.",
 				"is_default":  true,
 			},
@@ -76,6 +84,7 @@ var getTests = []struct {
 	charm       string
 	constraints string
 	config      charm.Settings
+	changed     []string
 	expect      params.ApplicationGetResults
 }{{
 	about:       "deployed service",
@@ -90,11 +99,13 @@ var getTests = []struct {
 		"skill-level": nil,
 		// Outlook is left unset.
 	},
+	changed: []string{"title", "username"},
 	expect: params.ApplicationGetResults{
 		Config: map[string]interface{}{
 			"title": map[string]interface{}{
 				"description": "A descriptive title used for the application.",
 				"type":        "string",
+				"default":     "My Title",
 				"value":       "Look To Windward",
 			},
 			"outlook": map[string]interface{}{
@@ -105,6 +116,7 @@ var getTests = []struct {
 			"username": map[string]interface{}{
 				"description": "The name of the initial account (given admin permissions).",
 				"type":        "string",
+				"default":     "admin001",
 				"value":       "admin001",
 				"is_default":  true,
 			},
@@ -129,11 +141,13 @@ var getTests = []struct {
 		// String value.
 		"outlook": "phlegmatic",
 	},
+	changed: []string{"outlook", "skill-level", "username"},
 	expect: params.ApplicationGetResults{
 		Config: map[string]interface{}{
 			"title": map[string]interface{}{
 				"description": "A descriptive title used for the application.",
 				"type":        "string",
+				"default":     "My Title",
 				"value":       "My Title",
 				"is_default":  true,
 			},
@@ -145,6 +159,7 @@ var getTests = []struct {
 			"username": map[string]interface{}{
 				"description": "The name of the initial account (given admin permissions).",
 				"type":        "string",
+				"default":     "admin001",
 				"value":       "foobie",
 			},
 			"skill-level": map[string]interface{}{
@@ -192,10 +207,30 @@ func (s *getSuite) TestServiceGet(c *gc.C) {
 		client := apiapplication.NewClient(s.APIState)
 		got, err := client.Get(app.Name())
 		c.Assert(err, jc.ErrorIsNil)
+		var changed []string
+		for key := range got.ConfigChanged {
+			changed = append(changed, key)
+		}
+		c.Assert(changed, jc.SameContents, t.changed)
+		got.ConfigChanged = nil
 		c.Assert(*got, gc.DeepEquals, expect)
 	}
 }
 
+func (s *getSuite) TestUnknownSettings(c *gc.C) {
+	config := charm.NewConfig()
+	config.Options["title"] = charm.Option{Type: "string"}
+	unknown := application.UnknownSettings(charm.Settings{
+		"title":   "sir",
+		"outlook": "positive",
+		"colour":  "blue",
+	}, config)
+	c.Assert(unknown, jc.DeepEquals, []string{"colour", "outlook"})
+
+	unknown = application.UnknownSettings(charm.Settings{"title": "sir"}, config)
+	c.Assert(unknown, gc.HasLen, 0)
+}
+
 func (s *getSuite) TestGetMaxResolutionInt(c *gc.C) {
 	// See the bug http://pad.lv/1217742
 	// Get ends up pushing a map[string]interface{} which containts
@@ -221,3 +256,107 @@ func (s *getSuite) TestGetMaxResolutionInt(c *gc.C) {
 		"value":       asFloat,
 	})
 }
+
+func (s *getSuite) TestWatchConfig(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.AddTestingCharm(c, "wordpress"))
+	results, err := s.serviceAPI.WatchConfig(params.Entities{
+		Entities: []params.Entity{
+			{Tag: "application-wordpress"},
+			{Tag: "application-mysql"},
+			{Tag: "unit-wordpress-0"},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, jc.DeepEquals, params.NotifyWatchResults{
+		Results: []params.NotifyWatchResult{
+			{NotifyWatcherId: "1"},
+			{Error: &params.Error{
+				Message: `application "mysql" not found`,
+				Code:    params.CodeNotFound,
+			}},
+			{Error: &params.Error{
+				Message: `"unit-wordpress-0" is not a valid application tag`,
+			}},
+		},
+	})
+
+	// Verify the resource was registered and stop when done.
+	c.Assert(s.resources.Count(), gc.Equals, 1)
+	resource := s.resources.Get("1")
+	defer statetesting.AssertStop(c, resource)
+
+	// Check that the watcher has consumed the initial event, and
+	// reports subsequent config changes.
+	wc := statetesting.NewNotifyWatcherC(c, s.State, resource.(state.NotifyWatcher))
+	wc.AssertNoChange()
+	err = app.UpdateConfigSettings(charm.Settings{"blog-title": "sauceror central"})
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertOneChange()
+}
+
+func (s *getSuite) TestWatchConfigPermissionDenied(c *gc.C) {
+	s.AddTestingApplication(c, "wordpress", s.AddTestingCharm(c, "wordpress"))
+	s.authorizer.Tag = names.NewUserTag("fred")
+	backend, err := application.NewStateBackend(s.State)
+	c.Assert(err, jc.ErrorIsNil)
+	api, err := application.NewAPI(
+		backend,
+		s.authorizer,
+		s.resources,
+		common.NewBlockChecker(s.State),
+		application.CharmToStateCharm,
+		application.DeployApplication,
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = api.WatchConfig(params.Entities{
+		Entities: []params.Entity{{Tag: "application-wordpress"}},
+	})
+	c.Assert(err, gc.ErrorMatches, "permission denied")
+	c.Assert(s.resources.Count(), gc.Equals, 0)
+}
+
+func (s *getSuite) TestPreviousConfig(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.AddTestingCharm(c, "wordpress"))
+	s.AddTestingApplication(c, "mysql", s.AddTestingCharm(c, "mysql"))
+	err := app.UpdateConfigSettings(charm.Settings{"blog-title": "sauceror central"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.UpdateConfigSettings(charm.Settings{"blog-title": "sauceror south"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	results, err := s.serviceAPI.PreviousConfig(params.Entities{
+		Entities: []params.Entity{
+			{Tag: "application-wordpress"},
+			{Tag: "application-mysql"},
+			{Tag: "unit-wordpress-0"},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, jc.DeepEquals, params.ConfigSettingsResults{
+		Results: []params.ConfigSettingsResult{
+			{Settings: params.ConfigSettings{"blog-title": "sauceror central"}},
+			{Error: &params.Error{
+				Message: `previous config settings for application "mysql" not found`,
+				Code:    params.CodeNotFound,
+			}},
+			{Error: &params.Error{
+				Message: `"unit-wordpress-0" is not a valid application tag`,
+			}},
+		},
+	})
+}
+
+func (s *getSuite) TestGetConfigChanged(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.AddTestingCharm(c, "wordpress"))
+	results, err := s.serviceAPI.Get(params.ApplicationGet{"wordpress"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results.ConfigChanged, gc.IsNil)
+
+	err = app.UpdateConfigSettings(charm.Settings{"blog-title": "sauceror central"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	results, err = s.serviceAPI.Get(params.ApplicationGet{"wordpress"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results.ConfigChanged, gc.HasLen, 1)
+	c.Assert(results.ConfigChanged["blog-title"].Equal(app.ConfigChangeTimes()["blog-title"]), jc.IsTrue)
+}
@@ -90,6 +90,8 @@ type mockApplication struct {
 	subordinate bool
 	series      string
 	units       []mockUnit
+
+	configLocked bool
 }
 
 func (m *mockApplication) Name() string {
@@ -129,6 +131,11 @@ func (a *mockApplication) SetCharm(cfg state.SetCharmConfig) error {
 	return a.NextErr()
 }
 
+func (a *mockApplication) IsConfigLocked() bool {
+	a.MethodCall(a, "IsConfigLocked")
+	return a.configLocked
+}
+
 func (a *mockApplication) Destroy() error {
 	a.MethodCall(a, "Destroy")
 	return a.NextErr()
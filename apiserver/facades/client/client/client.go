@@ -135,7 +135,7 @@ func NewFacade(ctx facade.Context) (*Client, error) {
 		return environs.GetEnviron(configGetter, environs.New)
 	}
 	blockChecker := common.NewBlockChecker(st)
-	modelConfigAPI, err := modelconfig.NewModelConfigAPI(st, authorizer)
+	modelConfigAPI, err := modelconfig.NewModelConfigAPI(st, authorizer, common.ClientAddress(resources))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -29,6 +29,11 @@ import (
 
 var logger = loggo.GetLogger("juju.apiserver.controller")
 
+// ControllerAPIv5 provides the v5 Controller API. It adds ConfigSet.
+type ControllerAPIv5 struct {
+	*ControllerAPIv4
+}
+
 // ControllerAPIv4 provides the v4 Controller API.
 type ControllerAPIv4 struct {
 	*ControllerAPIv3
@@ -47,6 +52,15 @@ type ControllerAPIv3 struct {
 	resources  facade.Resources
 }
 
+// NewControllerAPIv5 creates a new ControllerAPIv5.
+func NewControllerAPIv5(ctx facade.Context) (*ControllerAPIv5, error) {
+	v4, err := NewControllerAPIv4(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &ControllerAPIv5{v4}, nil
+}
+
 // NewControllerAPIv4 creates a new ControllerAPIv4.
 func NewControllerAPIv4(ctx facade.Context) (*ControllerAPIv4, error) {
 	v3, err := NewControllerAPIv3(ctx)
@@ -98,6 +112,16 @@ func (s *ControllerAPIv3) checkHasAdmin() error {
 	return nil
 }
 
+// ConfigSet changes the controller config attributes that may be
+// changed after bootstrap, such as firewall-panic-close. Only
+// controller administrators may change them.
+func (s *ControllerAPIv5) ConfigSet(args params.ControllerConfigSet) error {
+	if err := s.checkHasAdmin(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.state.UpdateControllerConfig(args.Config, args.Unset))
+}
+
 // AllModels allows controller administrators to get the list of all the
 // models in the controller.
 func (s *ControllerAPIv3) AllModels() (params.UserModelList, error) {
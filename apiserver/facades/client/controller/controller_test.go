@@ -827,3 +827,47 @@ func (s *controllerSuite) TestGetControllerAccessPermissions(c *gc.C) {
 		Message: "permission denied", Code: "unauthorized access",
 	})
 }
+
+func (s *controllerSuite) TestConfigSet(c *gc.C) {
+	api := &controller.ControllerAPIv5{s.controller}
+	err := api.ConfigSet(params.ControllerConfigSet{Config: map[string]interface{}{
+		"firewall-panic-close":     true,
+		"firewall-essential-rules": "22/tcp",
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	cfg, err := s.State.ControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cfg.FirewallPanicClose(), jc.IsTrue)
+
+	err = api.ConfigSet(params.ControllerConfigSet{Unset: []string{"firewall-panic-close"}})
+	c.Assert(err, jc.ErrorIsNil)
+	cfg, err = s.State.ControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cfg.FirewallPanicClose(), jc.IsFalse)
+}
+
+func (s *controllerSuite) TestConfigSetImmutable(c *gc.C) {
+	api := &controller.ControllerAPIv5{s.controller}
+	err := api.ConfigSet(params.ControllerConfigSet{Config: map[string]interface{}{
+		"api-port": 1234,
+	}})
+	c.Assert(err, gc.ErrorMatches, `cannot change controller attribute "api-port"`)
+}
+
+func (s *controllerSuite) TestConfigSetRequiresSuperUser(c *gc.C) {
+	user := s.Factory.MakeUser(c, &factory.UserParams{NoModelUser: true})
+	anAuthoriser := apiservertesting.FakeAuthorizer{
+		Tag: user.Tag(),
+	}
+	endpoint, err := controller.NewControllerAPIv5(
+		facadetest.Context{
+			State_:     s.State,
+			Resources_: s.resources,
+			Auth_:      anAuthoriser,
+		})
+	c.Assert(err, jc.ErrorIsNil)
+	err = endpoint.ConfigSet(params.ControllerConfigSet{Config: map[string]interface{}{
+		"firewall-panic-close": true,
+	}})
+	c.Assert(err, gc.ErrorMatches, "permission denied")
+}
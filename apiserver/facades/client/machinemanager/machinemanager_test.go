@@ -313,6 +313,56 @@ func (s *MachineManagerSuite) TestUpdateMachineSeriesPermissionDenied(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, "permission denied")
 }
 
+func (s *MachineManagerSuite) TestSetFirewallLockdown(c *gc.C) {
+	s.st.machines = map[string]*mockMachine{
+		"0": &mockMachine{},
+		"1": &mockMachine{},
+	}
+	apiV5 := machinemanager.MachineManagerAPIV5{&machinemanager.MachineManagerAPIV4{s.api}}
+	results, err := apiV5.SetFirewallLockdown(
+		params.FirewallLockdownArgs{
+			Args: []params.FirewallLockdownArg{
+				{
+					Entity:   params.Entity{Tag: names.NewMachineTag("0").String()},
+					Lockdown: true,
+				}, {
+					Entity: params.Entity{Tag: names.NewMachineTag("1").String()},
+				}, {
+					Entity:   params.Entity{Tag: names.NewMachineTag("76").String()},
+					Lockdown: true,
+				}, {
+					Entity:   params.Entity{Tag: names.NewUnitTag("mysql/0").String()},
+					Lockdown: true,
+				},
+			}},
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, jc.DeepEquals, params.ErrorResults{
+		Results: []params.ErrorResult{
+			{}, {},
+			{Error: &params.Error{Message: "machine 76 not found", Code: "not found"}},
+			{Error: &params.Error{Message: "\"unit-mysql-0\" is not a valid machine tag", Code: ""}},
+		}})
+
+	s.st.machines["0"].CheckCalls(c, []jtesting.StubCall{{"SetFirewallLockdown", []interface{}{true}}})
+	s.st.machines["1"].CheckCalls(c, []jtesting.StubCall{{"SetFirewallLockdown", []interface{}{false}}})
+}
+
+func (s *MachineManagerSuite) TestSetFirewallLockdownPermissionDenied(c *gc.C) {
+	user := names.NewUserTag("fred")
+	s.setAPIUser(c, user)
+	apiV5 := machinemanager.MachineManagerAPIV5{&machinemanager.MachineManagerAPIV4{s.api}}
+	_, err := apiV5.SetFirewallLockdown(
+		params.FirewallLockdownArgs{
+			Args: []params.FirewallLockdownArg{{
+				Entity:   params.Entity{Tag: names.NewMachineTag("0").String()},
+				Lockdown: true,
+			}},
+		},
+	)
+	c.Assert(err, gc.ErrorMatches, "permission denied")
+}
+
 type mockState struct {
 	machinemanager.Backend
 	calls            int
@@ -450,6 +500,11 @@ func (m *mockMachine) UpdateMachineSeries(series string, force bool) error {
 	return m.NextErr()
 }
 
+func (m *mockMachine) SetFirewallLockdown(lockdown bool) error {
+	m.MethodCall(m, "SetFirewallLockdown", lockdown)
+	return m.NextErr()
+}
+
 type mockUnit struct {
 	tag names.UnitTag
 }
@@ -47,6 +47,7 @@ type Machine interface {
 	Units() ([]Unit, error)
 	SetKeepInstance(keepInstance bool) error
 	UpdateMachineSeries(string, bool) error
+	SetFirewallLockdown(bool) error
 }
 
 type stateShim struct {
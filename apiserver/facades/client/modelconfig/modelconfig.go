@@ -16,26 +16,29 @@ import (
 )
 
 // NewFacade is used for API registration.
-func NewFacade(st *state.State, _ facade.Resources, auth facade.Authorizer) (*ModelConfigAPI, error) {
-	return NewModelConfigAPI(NewStateBackend(st), auth)
+func NewFacade(st *state.State, resources facade.Resources, auth facade.Authorizer) (*ModelConfigAPI, error) {
+	return NewModelConfigAPI(NewStateBackend(st), auth, common.ClientAddress(resources))
 }
 
 // ModelConfigAPI is the endpoint which implements the model config facade.
 type ModelConfigAPI struct {
-	backend Backend
-	auth    facade.Authorizer
-	check   *common.BlockChecker
+	backend       Backend
+	auth          facade.Authorizer
+	check         *common.BlockChecker
+	clientAddress string
 }
 
 // NewModelConfigAPI creates a new instance of the ModelConfig Facade.
-func NewModelConfigAPI(backend Backend, authorizer facade.Authorizer) (*ModelConfigAPI, error) {
+// The clientAddress is the address from which the client connected.
+func NewModelConfigAPI(backend Backend, authorizer facade.Authorizer, clientAddress string) (*ModelConfigAPI, error) {
 	if !authorizer.AuthClient() {
 		return nil, common.ErrPerm
 	}
 	client := &ModelConfigAPI{
-		backend: backend,
-		auth:    authorizer,
-		check:   common.NewBlockChecker(backend),
+		backend:       backend,
+		auth:          authorizer,
+		check:         common.NewBlockChecker(backend),
+		clientAddress: clientAddress,
 	}
 	return client, nil
 }
@@ -157,9 +160,15 @@ func (c *ModelConfigAPI) ModelSet(args params.ModelSet) error {
 		return nil
 	}
 
+	// The controller's API and SSH ports may not be restricted to
+	// sources that exclude this client.
+	checkAdminCIDRs := func(updateAttrs map[string]interface{}, removeAttrs []string, oldConfig *config.Config) error {
+		return common.CheckControllerAdminCIDRs(updateAttrs, c.clientAddress)
+	}
+
 	// Replace any deprecated attributes with their new values.
 	attrs := config.ProcessDeprecatedAttributes(args.Config)
-	return c.backend.UpdateModelConfig(attrs, nil, checkAgentVersion, checkLogTrace)
+	return c.backend.UpdateModelConfig(attrs, nil, checkAgentVersion, checkLogTrace, checkAdminCIDRs)
 }
 
 // ModelUnset implements the server-side part of the
@@ -44,7 +44,7 @@ func (s *modelconfigSuite) SetUpTest(c *gc.C) {
 		},
 	}
 	var err error
-	s.api, err = modelconfig.NewModelConfigAPI(s.backend, &s.authorizer)
+	s.api, err = modelconfig.NewModelConfigAPI(s.backend, &s.authorizer, "10.0.0.1:54321")
 	c.Assert(err, jc.ErrorIsNil)
 }
 
@@ -138,6 +138,31 @@ func (s *modelconfigSuite) TestAdminCanSetLogTrace(c *gc.C) {
 	c.Assert(result.Config["logging-config"].Value, gc.Equals, "<root>=DEBUG;somepackage=TRACE")
 }
 
+func (s *modelconfigSuite) TestModelSetControllerAdminCIDRs(c *gc.C) {
+	args := params.ModelSet{
+		map[string]interface{}{"controller-admin-cidrs": "10.0.0.0/8"},
+	}
+	err := s.api.ModelSet(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := s.api.ModelGet()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Config["controller-admin-cidrs"].Value, gc.Equals, "10.0.0.0/8")
+}
+
+func (s *modelconfigSuite) TestModelSetControllerAdminCIDRsLockout(c *gc.C) {
+	args := params.ModelSet{
+		map[string]interface{}{"controller-admin-cidrs": "192.168.0.0/16"},
+	}
+	err := s.api.ModelSet(args)
+	c.Assert(err, gc.ErrorMatches, `controller admin CIDRs 192.168.0.0/16 do not include this client's address 10.0.0.1, which would be locked out of the controller`)
+
+	result, err := s.api.ModelGet()
+	c.Assert(err, jc.ErrorIsNil)
+	_, ok := result.Config["controller-admin-cidrs"]
+	c.Assert(ok, jc.IsFalse)
+}
+
 func (s *modelconfigSuite) TestUserCanSetLogNoTrace(c *gc.C) {
 	args := params.ModelSet{
 		map[string]interface{}{"logging-config": "<root>=DEBUG;somepackage=ERROR"},
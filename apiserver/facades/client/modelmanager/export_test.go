@@ -12,3 +12,9 @@ func AuthCheck(c *gc.C, mm *ModelManagerAPI, user names.UserTag) bool {
 	mm.authCheck(user)
 	return mm.isAdmin
 }
+
+// SetClientAddress sets the address from which the client of the
+// given API connected.
+func SetClientAddress(mm *ModelManagerAPI, addr string) {
+	mm.clientAddress = addr
+}
@@ -78,6 +78,9 @@ type ModelManagerAPI struct {
 	toolsFinder *common.ToolsFinder
 	apiUser     names.UserTag
 	isAdmin     bool
+
+	// clientAddress is the address from which the client connected.
+	clientAddress string
 }
 
 // ModelManagerAPIV2 provides a way to wrap the different calls between
@@ -106,12 +109,17 @@ func NewFacadeV4(ctx facade.Context) (*ModelManagerAPI, error) {
 	auth := ctx.Auth()
 	configGetter := stateenvirons.EnvironConfigGetter{st}
 
-	return NewModelManagerAPI(
+	api, err := NewModelManagerAPI(
 		common.NewModelManagerBackend(st, pool),
 		common.NewModelManagerBackend(ctlrSt, pool),
 		configGetter,
 		auth,
 	)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	api.clientAddress = common.ClientAddress(ctx.Resources())
+	return api, nil
 }
 
 // NewFacadeV3 is used for API registration.
@@ -1131,6 +1139,11 @@ func (m *ModelManagerAPI) setModelDefaults(args params.ModelDefaultValues) error
 	if _, found := args.Config["agent-version"]; found {
 		return errors.New("agent-version cannot have a default value")
 	}
+	// The controller's API and SSH ports may not be restricted to
+	// sources that exclude this client.
+	if err := common.CheckControllerAdminCIDRs(args.Config, m.clientAddress); err != nil {
+		return errors.Trace(err)
+	}
 
 	var rspec *environs.RegionSpec
 	if args.CloudRegion != "" {
@@ -504,6 +504,23 @@ func (s *modelManagerSuite) TestSetModelDefaults(c *gc.C) {
 	})
 }
 
+func (s *modelManagerSuite) TestSetModelDefaultsControllerAdminCIDRs(c *gc.C) {
+	modelmanager.SetClientAddress(s.api, "10.0.0.1:54321")
+	args := params.SetModelDefaults{
+		Config: []params.ModelDefaultValues{{
+			Config: map[string]interface{}{"controller-admin-cidrs": "10.0.0.0/8"},
+		}, {
+			Config: map[string]interface{}{"controller-admin-cidrs": "192.168.0.0/16"},
+		}}}
+	result, err := s.api.SetModelDefaults(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Results, gc.HasLen, 2)
+	c.Assert(result.Results[0].Error, gc.IsNil)
+	c.Assert(result.Results[1].Error, gc.ErrorMatches,
+		`controller admin CIDRs 192.168.0.0/16 do not include this client's address 10.0.0.1, which would be locked out of the controller`)
+	c.Assert(s.st.cfgDefaults["controller-admin-cidrs"], jc.DeepEquals, config.AttributeDefaultValues{Controller: "10.0.0.0/8"})
+}
+
 func (s *modelManagerSuite) blockAllChanges(c *gc.C, msg string) {
 	s.st.blockMsg = msg
 	s.st.block = state.ChangeBlock
@@ -4,6 +4,8 @@
 package firewaller
 
 import (
+	"time"
+
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"gopkg.in/juju/names.v2"
@@ -46,6 +48,44 @@ type FirewallerAPIV4 struct {
 	*common.ControllerConfigAPI
 }
 
+// FirewallerAPIV5 provides access to the Firewaller v5 API facade.
+// It adds GetMachineFirewallLockdown, and extends Watch to machines so
+// that the firewaller can react to lockdown changes.
+type FirewallerAPIV5 struct {
+	*FirewallerAPIV4
+	entityWatcher *common.AgentEntityWatcher
+}
+
+// FirewallerAPIV6 provides access to the Firewaller v6 API facade.
+// It adds GetMachineEgressPorts.
+type FirewallerAPIV6 struct {
+	*FirewallerAPIV5
+}
+
+// FirewallerAPIV7 provides access to the Firewaller v7 API facade.
+// It adds ClassifyIngressRules.
+type FirewallerAPIV7 struct {
+	*FirewallerAPIV6
+}
+
+// FirewallerAPIV8 provides access to the Firewaller v8 API facade.
+// It adds GetExposedSpaces.
+type FirewallerAPIV8 struct {
+	*FirewallerAPIV7
+}
+
+// FirewallerAPIV9 provides access to the Firewaller v9 API facade.
+// It adds GetAllMachinePorts.
+type FirewallerAPIV9 struct {
+	*FirewallerAPIV8
+}
+
+// FirewallerAPIV10 provides access to the Firewaller v10 API facade.
+// It adds WatchControllerConfig.
+type FirewallerAPIV10 struct {
+	*FirewallerAPIV9
+}
+
 // NewStateFirewallerAPIv3 creates a new server-side FirewallerAPIV3 facade.
 func NewStateFirewallerAPIV3(context facade.Context) (*FirewallerAPIV3, error) {
 	st := context.State()
@@ -69,6 +109,75 @@ func NewStateFirewallerAPIV4(context facade.Context) (*FirewallerAPIV4, error) {
 	}, nil
 }
 
+// NewStateFirewallerAPIV5 creates a new server-side FirewallerAPIV5 facade.
+func NewStateFirewallerAPIV5(context facade.Context) (*FirewallerAPIV5, error) {
+	facadev4, err := NewStateFirewallerAPIV4(context)
+	if err != nil {
+		return nil, err
+	}
+	return NewFirewallerAPIV5(facadev4), nil
+}
+
+// NewStateFirewallerAPIV6 creates a new server-side FirewallerAPIV6 facade.
+func NewStateFirewallerAPIV6(context facade.Context) (*FirewallerAPIV6, error) {
+	facadev5, err := NewStateFirewallerAPIV5(context)
+	if err != nil {
+		return nil, err
+	}
+	return &FirewallerAPIV6{FirewallerAPIV5: facadev5}, nil
+}
+
+// NewStateFirewallerAPIV7 creates a new server-side FirewallerAPIV7 facade.
+func NewStateFirewallerAPIV7(context facade.Context) (*FirewallerAPIV7, error) {
+	facadev6, err := NewStateFirewallerAPIV6(context)
+	if err != nil {
+		return nil, err
+	}
+	return &FirewallerAPIV7{FirewallerAPIV6: facadev6}, nil
+}
+
+// NewStateFirewallerAPIV8 creates a new server-side FirewallerAPIV8 facade.
+func NewStateFirewallerAPIV8(context facade.Context) (*FirewallerAPIV8, error) {
+	facadev7, err := NewStateFirewallerAPIV7(context)
+	if err != nil {
+		return nil, err
+	}
+	return &FirewallerAPIV8{FirewallerAPIV7: facadev7}, nil
+}
+
+// NewStateFirewallerAPIV9 creates a new server-side FirewallerAPIV9 facade.
+func NewStateFirewallerAPIV9(context facade.Context) (*FirewallerAPIV9, error) {
+	facadev8, err := NewStateFirewallerAPIV8(context)
+	if err != nil {
+		return nil, err
+	}
+	return &FirewallerAPIV9{FirewallerAPIV8: facadev8}, nil
+}
+
+// NewStateFirewallerAPIV10 creates a new server-side FirewallerAPIV10 facade.
+func NewStateFirewallerAPIV10(context facade.Context) (*FirewallerAPIV10, error) {
+	facadev9, err := NewStateFirewallerAPIV9(context)
+	if err != nil {
+		return nil, err
+	}
+	return &FirewallerAPIV10{FirewallerAPIV9: facadev9}, nil
+}
+
+// NewFirewallerAPIV5 creates a new server-side FirewallerAPIV5 facade
+// wrapping the given FirewallerAPIV4.
+func NewFirewallerAPIV5(facadev4 *FirewallerAPIV4) *FirewallerAPIV5 {
+	// Watch() is supported for applications and machines.
+	entityWatcher := common.NewAgentEntityWatcher(
+		facadev4.st,
+		facadev4.resources,
+		common.AuthAny(facadev4.accessApplication, facadev4.accessMachine),
+	)
+	return &FirewallerAPIV5{
+		FirewallerAPIV4: facadev4,
+		entityWatcher:   entityWatcher,
+	}
+}
+
 // NewFirewallerAPI creates a new server-side FirewallerAPIV3 facade.
 func NewFirewallerAPI(
 	st State,
@@ -190,6 +299,81 @@ func (f *FirewallerAPIV3) watchOneEnvironOpenedPorts(tag names.Tag) (string, []s
 // subnet as a map mapping port ranges to the tags of the units that opened
 // them.
 func (f *FirewallerAPIV3) GetMachinePorts(args params.MachinePortsParams) (params.MachinePortsResults, error) {
+	return f.getMachinePorts(args, (*state.Ports).AllPortRanges)
+}
+
+// GetMachineEgressPorts returns the egress port ranges opened on a
+// machine for the specified subnet, along with the tags of the units
+// that opened them.
+func (f *FirewallerAPIV6) GetMachineEgressPorts(args params.MachinePortsParams) (params.MachinePortsResults, error) {
+	return f.getMachinePorts(args, (*state.Ports).AllEgressPortRanges)
+}
+
+// GetAllMachinePorts returns the ingress and egress port ranges opened
+// on every machine in the model, for each subnet, along with the tags
+// of the units that opened them. It lets the firewaller load the ports
+// of all machines in a single call when it starts.
+func (f *FirewallerAPIV9) GetAllMachinePorts() (params.AllMachinePortsResult, error) {
+	allPorts, err := f.st.AllOpenedPorts()
+	if err != nil {
+		return params.AllMachinePortsResult{Error: common.ServerError(err)}, nil
+	}
+	result := params.AllMachinePortsResult{
+		Machines: make([]params.MachineSubnetPorts, len(allPorts)),
+	}
+	for i, ports := range allPorts {
+		var subnetTag string
+		if ports.SubnetID() != "" {
+			subnetTag = names.NewSubnetTag(ports.SubnetID()).String()
+		}
+		result.Machines[i] = params.MachineSubnetPorts{
+			MachineTag:  names.NewMachineTag(ports.MachineID()).String(),
+			SubnetTag:   subnetTag,
+			Ports:       machinePortRanges(ports.AllPortRanges()),
+			EgressPorts: machinePortRanges(ports.AllEgressPortRanges()),
+		}
+	}
+	return result, nil
+}
+
+// WatchControllerConfig returns a NotifyWatcher that notifies when the
+// controller's config changes, so that the firewaller can apply a
+// firewall panic close set for the whole controller.
+func (f *FirewallerAPIV10) WatchControllerConfig() (params.NotifyWatchResult, error) {
+	result := params.NotifyWatchResult{}
+	watch := f.st.WatchControllerConfig()
+	// Consume the initial event; NotifyWatchers have no state to
+	// transmit.
+	if _, ok := <-watch.Changes(); ok {
+		result.NotifyWatcherId = f.resources.Register(watch)
+	} else {
+		return result, watcher.EnsureErr(watch)
+	}
+	return result, nil
+}
+
+// machinePortRanges returns the given port ranges, mapped to the names
+// of the units that opened them, sorted by port range.
+func machinePortRanges(portRangeMap map[network.PortRange]string) []params.MachinePortRange {
+	var portRanges []network.PortRange
+	for portRange := range portRangeMap {
+		portRanges = append(portRanges, portRange)
+	}
+	network.SortPortRanges(portRanges)
+	var result []params.MachinePortRange
+	for _, portRange := range portRanges {
+		result = append(result, params.MachinePortRange{
+			UnitTag:   names.NewUnitTag(portRangeMap[portRange]).String(),
+			PortRange: params.FromNetworkPortRange(portRange),
+		})
+	}
+	return result
+}
+
+func (f *FirewallerAPIV3) getMachinePorts(
+	args params.MachinePortsParams,
+	allPortRanges func(*state.Ports) map[network.PortRange]string,
+) (params.MachinePortsResults, error) {
 	result := params.MachinePortsResults{
 		Results: make([]params.MachinePortsResult, len(args.Params)),
 	}
@@ -222,21 +406,7 @@ func (f *FirewallerAPIV3) GetMachinePorts(args params.MachinePortsParams) (param
 			continue
 		}
 		if ports != nil {
-			portRangeMap := ports.AllPortRanges()
-			var portRanges []network.PortRange
-			for portRange := range portRangeMap {
-				portRanges = append(portRanges, portRange)
-			}
-			network.SortPortRanges(portRanges)
-
-			for _, portRange := range portRanges {
-				unitTag := names.NewUnitTag(portRangeMap[portRange]).String()
-				result.Results[i].Ports = append(result.Results[i].Ports,
-					params.MachinePortRange{
-						UnitTag:   unitTag,
-						PortRange: params.FromNetworkPortRange(portRange),
-					})
-			}
+			result.Results[i].Ports = machinePortRanges(allPortRanges(ports))
 		}
 	}
 	return result, nil
@@ -313,6 +483,177 @@ func (f *FirewallerAPIV3) GetExposed(args params.Entities) (params.BoolResults,
 	return result, nil
 }
 
+// GetExposedSpaces returns, for each given application, the spaces it
+// is exposed to and the CIDRs of their subnets. An application exposed
+// to no particular spaces is exposed to everywhere.
+func (f *FirewallerAPIV8) GetExposedSpaces(args params.Entities) (params.ExposedSpacesResults, error) {
+	result := params.ExposedSpacesResults{
+		Results: make([]params.ExposedSpacesResult, len(args.Entities)),
+	}
+	canAccess, err := f.accessApplication()
+	if err != nil {
+		return params.ExposedSpacesResults{}, err
+	}
+	for i, entity := range args.Entities {
+		tag, err := names.ParseApplicationTag(entity.Tag)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(common.ErrPerm)
+			continue
+		}
+		application, err := f.getApplication(canAccess, tag)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(err)
+			continue
+		}
+		spaces := application.ExposedSpaces()
+		cidrs, err := f.spacesSubnetCIDRs(spaces)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(err)
+			continue
+		}
+		result.Results[i].Spaces = spaces
+		result.Results[i].CIDRs = cidrs
+	}
+	return result, nil
+}
+
+// spacesSubnetCIDRs returns the CIDRs of the subnets in the given spaces.
+func (f *FirewallerAPIV8) spacesSubnetCIDRs(spaces []string) ([]string, error) {
+	var cidrs []string
+	for _, space := range spaces {
+		spaceCIDRs, err := f.st.SpaceSubnetCIDRs(space)
+		if err != nil {
+			return nil, errors.Annotatef(err, "getting subnets of space %q", space)
+		}
+		cidrs = append(cidrs, spaceCIDRs...)
+	}
+	return cidrs, nil
+}
+
+// Watch starts a NotifyWatcher for each given application or machine.
+func (f *FirewallerAPIV5) Watch(args params.Entities) (params.NotifyWatchResults, error) {
+	return f.entityWatcher.Watch(args)
+}
+
+// GetMachineFirewallLockdown returns the firewall lockdown flag value
+// for each given machine.
+func (f *FirewallerAPIV5) GetMachineFirewallLockdown(args params.Entities) (params.BoolResults, error) {
+	result := params.BoolResults{
+		Results: make([]params.BoolResult, len(args.Entities)),
+	}
+	canAccess, err := f.accessMachine()
+	if err != nil {
+		return params.BoolResults{}, err
+	}
+	for i, entity := range args.Entities {
+		tag, err := names.ParseMachineTag(entity.Tag)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(common.ErrPerm)
+			continue
+		}
+		machine, err := f.getMachine(canAccess, tag)
+		if err == nil {
+			result.Results[i].Result = machine.FirewallLockdown()
+		}
+		result.Results[i].Error = common.ServerError(err)
+	}
+	return result, nil
+}
+
+// ClassifyIngressRules reports the origin of each of the ingress rules
+// found on each given machine's instance. Rules opened by Juju for the
+// ports of the machine's units, or for the model's enabled firewall
+// rule groups, are reported as Juju's. Rules for ports Juju knows of,
+// but which are no longer wanted because an application was unexposed,
+// a rule group disabled or the machine locked down, are reported as
+// orphaned. All other rules must have been added outside of Juju, and
+// are reported as external.
+func (f *FirewallerAPIV7) ClassifyIngressRules(args params.MachineIngressRulesParams) (params.ClassifiedIngressRulesResults, error) {
+	result := params.ClassifiedIngressRulesResults{
+		Results: make([]params.ClassifiedIngressRulesResult, len(args.Params)),
+	}
+	canAccess, err := f.accessMachine()
+	if err != nil {
+		return params.ClassifiedIngressRulesResults{}, err
+	}
+	cfg, err := f.st.ModelConfig()
+	if err != nil {
+		return params.ClassifiedIngressRulesResults{}, errors.Trace(err)
+	}
+	enabledRules, _ := cfg.EnabledFirewallRules(time.Now())
+	var groupRules []network.IngressRule
+	for _, rules := range cfg.FirewallRuleGroups() {
+		groupRules = append(groupRules, rules...)
+	}
+	for i, param := range args.Params {
+		machineTag, err := names.ParseMachineTag(param.MachineTag)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(err)
+			continue
+		}
+		machine, err := f.getMachine(canAccess, machineTag)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(err)
+			continue
+		}
+		unitPorts, err := f.exposedUnitPorts(machine)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(err)
+			continue
+		}
+		classifier := ingressRuleClassifier{
+			lockdown:     machine.FirewallLockdown(),
+			unitPorts:    unitPorts,
+			enabledRules: enabledRules,
+			groupRules:   groupRules,
+		}
+		rules := make([]params.ClassifiedIngressRule, len(param.Rules))
+		for j, rule := range param.Rules {
+			rules[j] = params.ClassifiedIngressRule{
+				Rule:   rule,
+				Origin: classifier.classify(rule.NetworkIngressRule()),
+			}
+		}
+		result.Results[i].Rules = rules
+	}
+	return result, nil
+}
+
+// exposedUnitPorts returns the ingress port ranges opened by the units
+// of the given machine, on any subnet, mapped to whether the unit's
+// application is exposed.
+func (f *FirewallerAPIV3) exposedUnitPorts(machine *state.Machine) (map[network.PortRange]bool, error) {
+	canAccess, err := f.accessApplication()
+	if err != nil {
+		return nil, err
+	}
+	allPorts, err := machine.AllPorts()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	exposed := make(map[string]bool)
+	unitPorts := make(map[network.PortRange]bool)
+	for _, ports := range allPorts {
+		for portRange, unitName := range ports.AllPortRanges() {
+			appName, err := names.UnitApplication(unitName)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			appExposed, ok := exposed[appName]
+			if !ok {
+				app, err := f.getApplication(canAccess, names.NewApplicationTag(appName))
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				appExposed = app.IsExposed()
+				exposed[appName] = appExposed
+			}
+			unitPorts[portRange] = unitPorts[portRange] || appExposed
+		}
+	}
+	return unitPorts, nil
+}
+
 // GetAssignedMachine returns the assigned machine tag (if any) for
 // each given unit.
 func (f *FirewallerAPIV3) GetAssignedMachine(args params.Entities) (params.StringResults, error) {
@@ -467,3 +808,107 @@ func (f *FirewallerAPIV4) SetRelationsStatus(args params.SetStatus) (params.Erro
 	}
 	return result, nil
 }
+
+// ingressRuleClassifier classifies the ingress rules found on a
+// machine's instance by origin, by matching them against the rules
+// that Juju opens.
+type ingressRuleClassifier struct {
+	// lockdown is true if all ports on the machine must be closed.
+	lockdown bool
+
+	// unitPorts holds the port ranges opened by the machine's units,
+	// mapped to whether the unit's application is exposed.
+	unitPorts map[network.PortRange]bool
+
+	// enabledRules holds the rules of the model's enabled firewall
+	// rule groups.
+	enabledRules []network.IngressRule
+
+	// groupRules holds the rules of all of the model's firewall rule
+	// groups, whether enabled or not.
+	groupRules []network.IngressRule
+}
+
+// classify returns the origin of the given rule, one of
+// params.IngressRuleJuju, params.IngressRuleOrphaned or
+// params.IngressRuleExternal.
+func (c *ingressRuleClassifier) classify(rule network.IngressRule) string {
+	if ingressRuleCovered(rule, c.enabledRules) {
+		return c.origin(true)
+	}
+	if exposed, ok := c.unitPorts[rule.PortRange]; ok {
+		// The ports of exposed applications are opened to anywhere,
+		// while those of other applications are only opened to the
+		// networks of the remote relations requiring them.
+		return c.origin(exposed == ingressFromAnywhere(rule))
+	}
+	if ingressRuleCovered(rule, c.groupRules) {
+		return params.IngressRuleOrphaned
+	}
+	return params.IngressRuleExternal
+}
+
+// origin returns the origin of a rule that Juju opens, depending on
+// whether it is currently wanted.
+func (c *ingressRuleClassifier) origin(wanted bool) string {
+	if wanted && !c.lockdown {
+		return params.IngressRuleJuju
+	}
+	return params.IngressRuleOrphaned
+}
+
+// anywhereCIDRs holds the CIDRs from which ingress is allowed when a
+// rule has no source CIDRs.
+var anywhereCIDRs = []string{"0.0.0.0/0", "::/0"}
+
+// ingressRuleSources returns the source CIDRs of the given rule.
+func ingressRuleSources(rule network.IngressRule) []string {
+	if len(rule.SourceCIDRs) == 0 {
+		return anywhereCIDRs
+	}
+	return rule.SourceCIDRs
+}
+
+// ingressFromAnywhere reports whether the given rule allows ingress
+// from anywhere, and from nowhere else.
+func ingressFromAnywhere(rule network.IngressRule) bool {
+	for _, cidr := range ingressRuleSources(rule) {
+		if cidr != anywhereCIDRs[0] && cidr != anywhereCIDRs[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// ingressRuleCovered reports whether one of the given rules is for the
+// same port range as the given rule, and allows ingress from each of
+// its sources. The firewaller may open only some of a rule's sources,
+// such as those of the model's allowed ingress address families.
+func ingressRuleCovered(rule network.IngressRule, rules []network.IngressRule) bool {
+	for _, r := range rules {
+		if r.PortRange != rule.PortRange {
+			continue
+		}
+		sources := ingressRuleSources(r)
+		covered := true
+		for _, cidr := range ingressRuleSources(rule) {
+			if !containsString(sources, cidr) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/juju/juju/apiserver/facades/controller/firewaller"
 	"github.com/juju/juju/apiserver/params"
 	apiservertesting "github.com/juju/juju/apiserver/testing"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/state"
 	statetesting "github.com/juju/juju/state/testing"
 )
@@ -193,6 +194,193 @@ func (s *firewallerSuite) TestGetMachinePorts(c *gc.C) {
 
 }
 
+func (s *firewallerSuite) TestGetMachineEgressPorts(c *gc.C) {
+	s.openPorts(c)
+	err := s.units[0].OpenEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+
+	facadev6 := &firewaller.FirewallerAPIV6{
+		FirewallerAPIV5: firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller}),
+	}
+	args := params.MachinePortsParams{
+		Params: []params.MachinePorts{
+			{MachineTag: s.machines[0].Tag().String(), SubnetTag: ""},
+			{MachineTag: s.machines[2].Tag().String(), SubnetTag: ""},
+			{MachineTag: "machine-42", SubnetTag: ""},
+		},
+	}
+	result, err := facadev6.GetMachineEgressPorts(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, params.MachinePortsResults{
+		Results: []params.MachinePortsResult{
+			{Ports: []params.MachinePortRange{
+				{UnitTag: s.units[0].Tag().String(), PortRange: params.PortRange{
+					FromPort: 443, ToPort: 443, Protocol: "tcp",
+				}},
+			}},
+			{Error: nil, Ports: nil},
+			{Error: apiservertesting.NotFoundError("machine 42")},
+		},
+	})
+}
+
+func (s *firewallerSuite) TestClassifyIngressRules(c *gc.C) {
+	err := s.units[0].OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.units[0].OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.application.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "monitoring=9100/tcp@10.0.0.0/8 ssh=22/tcp",
+		"enabled-firewall-rule-groups": "monitoring",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	facadev7 := &firewaller.FirewallerAPIV7{
+		FirewallerAPIV6: &firewaller.FirewallerAPIV6{
+			FirewallerAPIV5: firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller}),
+		},
+	}
+	rule := func(from, to int, cidrs ...string) params.IngressRule {
+		return params.IngressRule{
+			PortRange:   params.PortRange{FromPort: from, ToPort: to, Protocol: "tcp"},
+			SourceCIDRs: cidrs,
+		}
+	}
+	rules := []params.IngressRule{
+		rule(80, 80, "0.0.0.0/0"),
+		rule(8080, 8080),
+		rule(8080, 8080, "192.168.0.0/16"),
+		rule(9100, 9100, "10.0.0.0/8"),
+		rule(9100, 9100, "0.0.0.0/0"),
+		rule(22, 22, "0.0.0.0/0"),
+		rule(443, 443, "0.0.0.0/0"),
+	}
+	args := params.MachineIngressRulesParams{
+		Params: []params.MachineIngressRules{
+			{MachineTag: s.machines[0].Tag().String(), Rules: rules},
+			{MachineTag: s.machines[1].Tag().String(), Rules: rules[:1]},
+			{MachineTag: "machine-42", Rules: rules[:1]},
+		},
+	}
+	result, err := facadev7.ClassifyIngressRules(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, params.ClassifiedIngressRulesResults{
+		Results: []params.ClassifiedIngressRulesResult{
+			{Rules: []params.ClassifiedIngressRule{
+				{Rule: rules[0], Origin: params.IngressRuleJuju},
+				{Rule: rules[1], Origin: params.IngressRuleJuju},
+				{Rule: rules[2], Origin: params.IngressRuleOrphaned},
+				{Rule: rules[3], Origin: params.IngressRuleJuju},
+				{Rule: rules[4], Origin: params.IngressRuleExternal},
+				{Rule: rules[5], Origin: params.IngressRuleOrphaned},
+				{Rule: rules[6], Origin: params.IngressRuleExternal},
+			}},
+			{Rules: []params.ClassifiedIngressRule{
+				{Rule: rules[0], Origin: params.IngressRuleExternal},
+			}},
+			{Error: apiservertesting.NotFoundError("machine 42")},
+		},
+	})
+
+	// Once the machine is locked down, none of Juju's rules are wanted.
+	err = s.machines[0].SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+	result, err = facadev7.ClassifyIngressRules(params.MachineIngressRulesParams{
+		Params: []params.MachineIngressRules{
+			{MachineTag: s.machines[0].Tag().String(), Rules: rules[:1]},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Results[0].Rules, jc.DeepEquals, []params.ClassifiedIngressRule{
+		{Rule: rules[0], Origin: params.IngressRuleOrphaned},
+	})
+}
+
+func (s *firewallerSuite) TestGetExposedSpaces(c *gc.C) {
+	_, err := s.State.AddSubnet(state.SubnetInfo{CIDR: "10.0.1.0/24"})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = s.State.AddSpace("dmz", "", []string{"10.0.1.0/24"}, true)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.application.SetExposedSpaces([]string{"dmz"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	facadev8 := &firewaller.FirewallerAPIV8{
+		FirewallerAPIV7: &firewaller.FirewallerAPIV7{
+			FirewallerAPIV6: &firewaller.FirewallerAPIV6{
+				FirewallerAPIV5: firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller}),
+			},
+		},
+	}
+	args := addFakeEntities(params.Entities{Entities: []params.Entity{
+		{Tag: s.application.Tag().String()},
+	}})
+	result, err := facadev8.GetExposedSpaces(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, params.ExposedSpacesResults{
+		Results: []params.ExposedSpacesResult{
+			{Spaces: []string{"dmz"}, CIDRs: []string{"10.0.1.0/24"}},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.NotFoundError(`application "bar"`)},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+		},
+	})
+
+	// An application exposed to everywhere has no spaces.
+	err = s.application.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	result, err = facadev8.GetExposedSpaces(params.Entities{Entities: []params.Entity{
+		{Tag: s.application.Tag().String()},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Results, jc.DeepEquals, []params.ExposedSpacesResult{{}})
+}
+
+func (s *firewallerSuite) TestGetAllMachinePorts(c *gc.C) {
+	s.openPorts(c)
+	err := s.units[0].OpenEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+
+	facadev9 := &firewaller.FirewallerAPIV9{
+		FirewallerAPIV8: &firewaller.FirewallerAPIV8{
+			FirewallerAPIV7: &firewaller.FirewallerAPIV7{
+				FirewallerAPIV6: &firewaller.FirewallerAPIV6{
+					FirewallerAPIV5: firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller}),
+				},
+			},
+		},
+	}
+	result, err := facadev9.GetAllMachinePorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Error, gc.IsNil)
+	unit0Tag := s.units[0].Tag().String()
+	unit2Tag := s.units[2].Tag().String()
+	c.Assert(result.Machines, jc.SameContents, []params.MachineSubnetPorts{{
+		MachineTag: s.machines[0].Tag().String(),
+		Ports: []params.MachinePortRange{
+			{UnitTag: unit0Tag, PortRange: params.PortRange{FromPort: 4321, ToPort: 4321, Protocol: "tcp"}},
+		},
+		EgressPorts: []params.MachinePortRange{
+			{UnitTag: unit0Tag, PortRange: params.PortRange{FromPort: 443, ToPort: 443, Protocol: "tcp"}},
+		},
+	}, {
+		MachineTag: s.machines[0].Tag().String(),
+		SubnetTag:  names.NewSubnetTag("10.20.30.0/24").String(),
+		Ports: []params.MachinePortRange{
+			{UnitTag: unit0Tag, PortRange: params.PortRange{FromPort: 1234, ToPort: 1400, Protocol: "tcp"}},
+		},
+	}, {
+		MachineTag: s.machines[2].Tag().String(),
+		Ports: []params.MachinePortRange{
+			{UnitTag: unit2Tag, PortRange: params.PortRange{FromPort: 1111, ToPort: 2222, Protocol: "udp"}},
+		},
+	}})
+}
+
 func (s *firewallerSuite) TestGetMachineActiveSubnets(c *gc.C) {
 	s.openPorts(c)
 
@@ -224,3 +412,84 @@ func (s *firewallerSuite) TestGetMachineActiveSubnets(c *gc.C) {
 		},
 	})
 }
+
+func (s *firewallerSuite) TestGetMachineFirewallLockdown(c *gc.C) {
+	err := s.machines[1].SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	facadev5 := firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller})
+	args := addFakeEntities(params.Entities{Entities: []params.Entity{
+		{Tag: s.machines[0].Tag().String()},
+		{Tag: s.machines[1].Tag().String()},
+		{Tag: s.application.Tag().String()},
+	}})
+	result, err := facadev5.GetMachineFirewallLockdown(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, params.BoolResults{
+		Results: []params.BoolResult{
+			{Result: false},
+			{Result: true},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.NotFoundError("machine 42")},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+			{Error: apiservertesting.ErrUnauthorized},
+		},
+	})
+}
+
+func (s *firewallerSuite) TestWatchV5AllowsMachines(c *gc.C) {
+	facadev5 := firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller})
+	args := params.Entities{Entities: []params.Entity{
+		{Tag: s.machines[0].Tag().String()},
+		{Tag: s.units[0].Tag().String()},
+	}}
+	result, err := facadev5.Watch(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, params.NotifyWatchResults{
+		Results: []params.NotifyWatchResult{
+			{NotifyWatcherId: "1"},
+			{Error: apiservertesting.ErrUnauthorized},
+		},
+	})
+	c.Assert(s.resources.Count(), gc.Equals, 1)
+	watcher1 := s.resources.Get("1")
+	defer statetesting.AssertStop(c, watcher1)
+
+	wc := statetesting.NewNotifyWatcherC(c, s.State, watcher1.(state.NotifyWatcher))
+	wc.AssertNoChange()
+	err = s.machines[0].SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertOneChange()
+}
+
+func (s *firewallerSuite) TestWatchControllerConfig(c *gc.C) {
+	facadev10 := &firewaller.FirewallerAPIV10{
+		FirewallerAPIV9: &firewaller.FirewallerAPIV9{
+			FirewallerAPIV8: &firewaller.FirewallerAPIV8{
+				FirewallerAPIV7: &firewaller.FirewallerAPIV7{
+					FirewallerAPIV6: &firewaller.FirewallerAPIV6{
+						FirewallerAPIV5: firewaller.NewFirewallerAPIV5(&firewaller.FirewallerAPIV4{FirewallerAPIV3: s.firewaller}),
+					},
+				},
+			},
+		},
+	}
+	c.Assert(s.resources.Count(), gc.Equals, 0)
+	result, err := facadev10.WatchControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, params.NotifyWatchResult{NotifyWatcherId: "1"})
+	c.Assert(s.resources.Count(), gc.Equals, 1)
+	w := s.resources.Get("1")
+	defer statetesting.AssertStop(c, w)
+
+	wc := statetesting.NewNotifyWatcherC(c, s.State, w.(state.NotifyWatcher))
+	wc.AssertNoChange()
+	err = s.State.UpdateControllerConfig(map[string]interface{}{
+		controller.FirewallPanicClose: true,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertOneChange()
+}
@@ -110,6 +110,24 @@ func (st *mockState) FindEntity(tag names.Tag) (state.Entity, error) {
 	return nil, errors.NotImplementedf("FindEntity")
 }
 
+func (st *mockState) SpaceSubnetCIDRs(space string) ([]string, error) {
+	st.MethodCall(st, "SpaceSubnetCIDRs", space)
+	// TODO - implement when remaining firewaller tests become unit tests
+	return nil, errors.NotImplementedf("SpaceSubnetCIDRs")
+}
+
+func (st *mockState) AllOpenedPorts() ([]*state.Ports, error) {
+	st.MethodCall(st, "AllOpenedPorts")
+	// TODO - implement when remaining firewaller tests become unit tests
+	return nil, errors.NotImplementedf("AllOpenedPorts")
+}
+
+func (st *mockState) WatchControllerConfig() state.NotifyWatcher {
+	st.MethodCall(st, "WatchControllerConfig")
+	// TODO - implement when remaining firewaller tests become unit tests
+	return nil
+}
+
 type mockWatcher struct {
 	testing.Stub
 	tomb.Tomb
@@ -4,6 +4,7 @@
 package firewaller
 
 import (
+	"github.com/juju/errors"
 	"gopkg.in/juju/names.v2"
 	"gopkg.in/macaroon.v1"
 
@@ -23,6 +24,12 @@ type State interface {
 	WatchOpenedPorts() state.StringsWatcher
 
 	FindEntity(tag names.Tag) (state.Entity, error)
+
+	SpaceSubnetCIDRs(space string) ([]string, error)
+
+	AllOpenedPorts() ([]*state.Ports, error)
+
+	WatchControllerConfig() state.NotifyWatcher
 }
 
 // TODO(wallyworld) - for tests, remove when remaining firewaller tests become unit tests.
@@ -51,3 +58,27 @@ func (st stateShim) FindEntity(tag names.Tag) (state.Entity, error) {
 func (st stateShim) WatchOpenedPorts() state.StringsWatcher {
 	return st.st.WatchOpenedPorts()
 }
+
+func (st stateShim) AllOpenedPorts() ([]*state.Ports, error) {
+	return st.st.AllOpenedPorts()
+}
+
+func (st stateShim) WatchControllerConfig() state.NotifyWatcher {
+	return st.st.WatchControllerConfig()
+}
+
+func (st stateShim) SpaceSubnetCIDRs(space string) ([]string, error) {
+	sp, err := st.st.Space(space)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnets, err := sp.Subnets()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	cidrs := make([]string, len(subnets))
+	for i, subnet := range subnets {
+		cidrs[i] = subnet.CIDR()
+	}
+	return cidrs, nil
+}
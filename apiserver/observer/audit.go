@@ -104,7 +104,7 @@ func (a *AuditRPCObserver) ServerRequest(hdr *rpc.Header, body interface{}) {
 
 	auditEntry.OriginType = "API request"
 	auditEntry.Operation = rpcRequestToOperation(hdr.Request)
-	auditEntry.Data = map[string]interface{}{"request-body": body}
+	auditEntry.Data = map[string]interface{}{"request-body": redactedForLogs(body)}
 	err := a.handleAuditEntry(auditEntry)
 	if err != nil {
 		a.errorHandler(errors.Trace(err))
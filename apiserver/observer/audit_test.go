@@ -0,0 +1,67 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package observer_test
+
+import (
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/apiserver/observer"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/audit"
+	"github.com/juju/juju/rpc"
+)
+
+type auditSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&auditSuite{})
+
+func (*auditSuite) serverRequest(c *gc.C, body interface{}) interface{} {
+	var entries []audit.AuditEntry
+	a := observer.NewAudit(&observer.AuditContext{}, func(entry audit.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}, func(err error) {
+		c.Errorf("unexpected error: %v", err)
+	})
+	hdr := &rpc.Header{Request: rpc.Request{Type: "Application", Version: 8, Action: "Set"}}
+	a.RPCObserver().ServerRequest(hdr, body)
+	c.Assert(entries, gc.HasLen, 1)
+	c.Assert(entries[0].Operation, gc.Equals, "Application:v8 - Set")
+	return entries[0].Data["request-body"]
+}
+
+func (s *auditSuite) TestServerRequestRedactsSettings(c *gc.C) {
+	body := s.serverRequest(c, params.ApplicationSet{
+		ApplicationName: "mysql",
+		Options:         map[string]string{"password": "s3cret"},
+		RedactInLogs:    true,
+	})
+	c.Assert(body, jc.DeepEquals, params.ApplicationSet{
+		ApplicationName: "mysql",
+		Options:         map[string]string{"password": params.RedactedValue},
+		RedactInLogs:    true,
+	})
+}
+
+func (s *auditSuite) TestServerRequestRedactsSettingsWithoutOptIn(c *gc.C) {
+	body := s.serverRequest(c, params.ApplicationUpdate{
+		ApplicationName: "mysql",
+		SettingsStrings: map[string]string{"password": "s3cret"},
+		SettingsYAML:    "mysql:\n  password: s3cret\n",
+	})
+	c.Assert(body, jc.DeepEquals, params.ApplicationUpdate{
+		ApplicationName: "mysql",
+		SettingsStrings: map[string]string{"password": params.RedactedValue},
+		SettingsYAML:    params.RedactedValue,
+	})
+}
+
+func (s *auditSuite) TestServerRequestWithoutSettings(c *gc.C) {
+	args := params.ApplicationExpose{ApplicationName: "mysql"}
+	c.Assert(s.serverRequest(c, args), jc.DeepEquals, args)
+}
@@ -173,7 +173,7 @@ func (n *rpcObserver) ServerReply(req rpc.Request, hdr *rpc.Header, body interfa
 }
 
 func (n *rpcObserver) logRequestTrace(logger loggo.Logger, hdr *rpc.Header, body interface{}) {
-	n.logTrace(logger, "<-", hdr, body)
+	n.logTrace(logger, "<-", hdr, redactedForLogs(body))
 }
 
 func (n *rpcObserver) logReplyTrace(logger loggo.Logger, hdr *rpc.Header, body interface{}) {
@@ -183,3 +183,18 @@ func (n *rpcObserver) logReplyTrace(logger loggo.Logger, hdr *rpc.Header, body i
 func (n *rpcObserver) logTrace(logger loggo.Logger, prefix string, hdr *rpc.Header, body interface{}) {
 	logger.Tracef("%s [%X] %s %s", prefix, n.id, n.tag, jsoncodec.DumpRequest(hdr, body))
 }
+
+// logRedacter is implemented by request parameters that hold values,
+// such as secrets, which must not be logged as given.
+type logRedacter interface {
+	// RedactedForLogs returns the parameters as they may be logged.
+	RedactedForLogs() interface{}
+}
+
+// redactedForLogs returns the given request body as it may be logged.
+func redactedForLogs(body interface{}) interface{} {
+	if r, ok := body.(logRedacter); ok {
+		return r.RedactedForLogs()
+	}
+	return body
+}
@@ -41,6 +41,13 @@ type RemoveBlocksArgs struct {
 	All bool `json:"all"`
 }
 
+// ControllerConfigSet holds the controller config attributes to set
+// and remove in a single call to ConfigSet.
+type ControllerConfigSet struct {
+	Config map[string]interface{} `json:"config,omitempty"`
+	Unset  []string               `json:"unset,omitempty"`
+}
+
 // ModelStatus holds information about the status of a juju model.
 type ModelStatus struct {
 	ModelTag           string                `json:"model-tag"`
@@ -530,6 +530,115 @@ type MachinePortsResults struct {
 	Results []MachinePortsResult `json:"results"`
 }
 
+// MachineSubnetPorts holds the ingress and egress port ranges opened
+// on a machine for a subnet, along with the tags of the units that
+// opened them. SubnetTag is empty for the machine's default subnet.
+type MachineSubnetPorts struct {
+	MachineTag  string             `json:"machine-tag"`
+	SubnetTag   string             `json:"subnet-tag"`
+	Ports       []MachinePortRange `json:"ports"`
+	EgressPorts []MachinePortRange `json:"egress-ports,omitempty"`
+}
+
+// AllMachinePortsResult holds the result of the
+// FirewallerAPIV9.GetAllMachinePorts() API call: the port ranges
+// opened on every machine in the model.
+type AllMachinePortsResult struct {
+	Error    *Error               `json:"error,omitempty"`
+	Machines []MachineSubnetPorts `json:"machines"`
+}
+
+// IngressRule is a rule allowing ingress to a range of ports from the
+// given source CIDRs; if there are none, ingress is allowed from
+// anywhere.
+type IngressRule struct {
+	PortRange   PortRange `json:"port-range"`
+	SourceCIDRs []string  `json:"source-cidrs,omitempty"`
+}
+
+// FromNetworkIngressRule is a convenience helper to create a parameter
+// out of the network type, here for IngressRule.
+func FromNetworkIngressRule(rule network.IngressRule) IngressRule {
+	return IngressRule{
+		PortRange:   FromNetworkPortRange(rule.PortRange),
+		SourceCIDRs: rule.SourceCIDRs,
+	}
+}
+
+// NetworkIngressRule is a convenience helper to return the parameter
+// as network type, here for IngressRule.
+func (r IngressRule) NetworkIngressRule() network.IngressRule {
+	return network.IngressRule{
+		PortRange:   r.PortRange.NetworkPortRange(),
+		SourceCIDRs: r.SourceCIDRs,
+	}
+}
+
+// MachineIngressRules holds a machine tag and the ingress rules found
+// on the machine's instance.
+type MachineIngressRules struct {
+	MachineTag string        `json:"machine-tag"`
+	Rules      []IngressRule `json:"rules"`
+}
+
+// MachineIngressRulesParams holds the arguments for making a
+// FirewallerAPIV7.ClassifyIngressRules() API call.
+type MachineIngressRulesParams struct {
+	Params []MachineIngressRules `json:"params"`
+}
+
+// The origins an ingress rule found on a provider's firewall may be
+// classified as having.
+const (
+	// IngressRuleJuju is the origin of rules that Juju opened, and
+	// which remain wanted.
+	IngressRuleJuju = "juju"
+
+	// IngressRuleOrphaned is the origin of rules for ports that Juju
+	// knows of, but which are no longer wanted.
+	IngressRuleOrphaned = "orphaned"
+
+	// IngressRuleExternal is the origin of rules that Juju knows
+	// nothing of, and which must have been added outside of Juju.
+	IngressRuleExternal = "external"
+)
+
+// ClassifiedIngressRule holds an ingress rule and its origin, which is
+// one of IngressRuleJuju, IngressRuleOrphaned or IngressRuleExternal.
+type ClassifiedIngressRule struct {
+	Rule   IngressRule `json:"rule"`
+	Origin string      `json:"origin"`
+}
+
+// ClassifiedIngressRulesResult holds a single result of the
+// FirewallerAPIV7.ClassifyIngressRules() API call.
+type ClassifiedIngressRulesResult struct {
+	Error *Error                  `json:"error,omitempty"`
+	Rules []ClassifiedIngressRule `json:"rules"`
+}
+
+// ClassifiedIngressRulesResults holds all the results of the
+// FirewallerAPIV7.ClassifyIngressRules() API call.
+type ClassifiedIngressRulesResults struct {
+	Results []ClassifiedIngressRulesResult `json:"results"`
+}
+
+// ExposedSpacesResult holds a single result of the
+// FirewallerAPIV8.GetExposedSpaces() API call: the spaces an exposed
+// application is restricted to, and the CIDRs of their subnets. If
+// Spaces is empty, the application is exposed to everywhere.
+type ExposedSpacesResult struct {
+	Error  *Error   `json:"error,omitempty"`
+	Spaces []string `json:"spaces,omitempty"`
+	CIDRs  []string `json:"cidrs,omitempty"`
+}
+
+// ExposedSpacesResults holds all the results of the
+// FirewallerAPIV8.GetExposedSpaces() API call.
+type ExposedSpacesResults struct {
+	Results []ExposedSpacesResult `json:"results"`
+}
+
 // APIHostPortsResult holds the result of an APIHostPorts
 // call. Each element in the top level slice holds
 // the addresses for one API server.
@@ -263,6 +263,24 @@ type ApplicationUpdate struct {
 	SettingsStrings map[string]string  `json:"settings,omitempty"`
 	SettingsYAML    string             `json:"settings-yaml"` // Takes precedence over SettingsStrings if both are present.
 	Constraints     *constraints.Value `json:"constraints,omitempty"`
+
+	// RedactInLogs asks the controller to keep all of the settings'
+	// values out of the errors it returns, and so out of its logs,
+	// rather than only those of options the charm marks as
+	// sensitive. This field is only understood by Application facade
+	// version 8 and greater.
+	RedactInLogs bool `json:"redact-in-logs,omitempty"`
+}
+
+// RedactedForLogs returns the arguments as they may be logged, with
+// the values of the settings redacted. The settings' schema is not
+// known where requests are logged, so no value is ever logged.
+func (p ApplicationUpdate) RedactedForLogs() interface{} {
+	p.SettingsStrings = redactedValues(p.SettingsStrings)
+	if p.SettingsYAML != "" {
+		p.SettingsYAML = RedactedValue
+	}
+	return p
 }
 
 // UpdateSeriesArg holds the parameters for updating the series for the
@@ -283,6 +301,21 @@ type UpdateSeriesArgs struct {
 	Args []UpdateSeriesArg `json:"args"`
 }
 
+// FirewallLockdownArg holds the parameters for setting or clearing the
+// firewall lockdown of a machine. Only known by MachineManager facade
+// version 5 or greater.
+type FirewallLockdownArg struct {
+	Entity   Entity `json:"tag"`
+	Lockdown bool   `json:"lockdown"`
+}
+
+// FirewallLockdownArgs holds the parameters for setting or clearing the
+// firewall lockdown of one or more machines. Only known by MachineManager
+// facade version 5 or greater.
+type FirewallLockdownArgs struct {
+	Args []FirewallLockdownArg `json:"args"`
+}
+
 // ApplicationSetCharm sets the charm for a given application.
 type ApplicationSetCharm struct {
 	// ApplicationName is the name of the application to set the charm on.
@@ -327,11 +360,49 @@ type ApplicationExpose struct {
 	ApplicationName string `json:"application"`
 }
 
+// ApplicationSetConfigLocked holds the parameters for making the
+// application SetConfigLocked call.
+type ApplicationSetConfigLocked struct {
+	ApplicationName string `json:"application"`
+	Locked          bool   `json:"locked"`
+}
+
 // ApplicationSet holds the parameters for an application Set
 // command. Options contains the configuration data.
 type ApplicationSet struct {
 	ApplicationName string            `json:"application"`
 	Options         map[string]string `json:"options"`
+
+	// RedactInLogs asks the controller to keep all of the options'
+	// values out of the errors it returns, and so out of its logs,
+	// rather than only those of options the charm marks as
+	// sensitive. This field is only understood by Application facade
+	// version 8 and greater.
+	RedactInLogs bool `json:"redact-in-logs,omitempty"`
+}
+
+// RedactedForLogs returns the arguments as they may be logged, with
+// the values of the options redacted. The options' schema is not
+// known where requests are logged, so no value is ever logged.
+func (p ApplicationSet) RedactedForLogs() interface{} {
+	p.Options = redactedValues(p.Options)
+	return p
+}
+
+// RedactedValue replaces values that must not be logged.
+const RedactedValue = "<redacted>"
+
+// redactedValues returns a copy of the given settings with each value
+// replaced by RedactedValue.
+func redactedValues(settings map[string]string) map[string]string {
+	if settings == nil {
+		return nil
+	}
+	result := make(map[string]string, len(settings))
+	for k := range settings {
+		result[k] = RedactedValue
+	}
+	return result
 }
 
 // ApplicationUnset holds the parameters for an application Unset
@@ -355,6 +426,22 @@ type ApplicationGetResults struct {
 	Config      map[string]interface{} `json:"config"`
 	Constraints constraints.Value      `json:"constraints"`
 	Series      string                 `json:"series"`
+
+	// UnknownSettings holds the names of settings that are stored
+	// for the application but not defined by its current charm,
+	// such as those left behind by a charm upgrade.
+	UnknownSettings []string `json:"unknown-settings,omitempty"`
+
+	// ConfigChanged holds the time at which each setting was last
+	// changed, for settings changed since the controller began
+	// recording the times. It is only reported by version 9 of the
+	// Application facade and later.
+	ConfigChanged map[string]time.Time `json:"config-changed,omitempty"`
+
+	// ConfigLocked is true if changes to the application's settings
+	// are refused until it is unlocked. It is only reported by
+	// version 10 of the Application facade and later.
+	ConfigLocked bool `json:"config-locked,omitempty"`
 }
 
 // ApplicationCharmRelations holds parameters for making the application CharmRelations call.
@@ -63,8 +63,9 @@ type apiHandler struct {
 
 var _ = (*apiHandler)(nil)
 
-// newAPIHandler returns a new apiHandler.
-func newAPIHandler(srv *Server, st *state.State, rpcConn *rpc.Conn, modelUUID string, serverHost string) (*apiHandler, error) {
+// newAPIHandler returns a new apiHandler. The clientAddress is the
+// host:port from which the client connected.
+func newAPIHandler(srv *Server, st *state.State, rpcConn *rpc.Conn, modelUUID string, serverHost, clientAddress string) (*apiHandler, error) {
 	r := &apiHandler{
 		state:      st,
 		resources:  common.NewResources(),
@@ -81,6 +82,11 @@ func newAPIHandler(srv *Server, st *state.State, rpcConn *rpc.Conn, modelUUID st
 	if err := r.resources.RegisterNamed("logDir", common.StringResource(srv.logDir)); err != nil {
 		return nil, errors.Trace(err)
 	}
+	// Facades that change the sources from which the controller may be
+	// reached need the client's address, so as not to lock it out.
+	if err := r.resources.RegisterNamed("clientAddress", common.StringResource(clientAddress)); err != nil {
+		return nil, errors.Trace(err)
+	}
 	// Facades involved with managing application offers need the auth context
 	// to mint and validate macaroons.
 	localOfferAccessEndpoint := url.URL{
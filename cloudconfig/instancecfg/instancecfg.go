@@ -837,12 +837,13 @@ func FinishInstanceConfig(icfg *InstanceConfig, cfg *config.Config) (err error)
 }
 
 // InstanceTags returns the minimum set of tags that should be set on a
-// machine instance, if the provider supports them.
-func InstanceTags(modelUUID, controllerUUID string, tagger tags.ResourceTagger, jobs []multiwatcher.MachineJob) map[string]string {
+// machine instance, if the provider supports them. The taggers' tags
+// are merged in order, so later taggers take precedence.
+func InstanceTags(modelUUID, controllerUUID string, jobs []multiwatcher.MachineJob, taggers ...tags.ResourceTagger) map[string]string {
 	instanceTags := tags.ResourceTags(
 		names.NewModelTag(modelUUID),
 		names.NewControllerTag(controllerUUID),
-		tagger,
+		taggers...,
 	)
 	if multiwatcher.AnyJobNeedsState(jobs...) {
 		instanceTags[tags.JujuIsController] = "true"
@@ -9,6 +9,7 @@ import (
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/juju/cloudconfig/instancecfg"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/state/multiwatcher"
 	"github.com/juju/juju/testing"
@@ -48,8 +49,24 @@ func (*instancecfgSuite) TestInstanceTagsUserSpecified(c *gc.C) {
 	})
 }
 
+func (*instancecfgSuite) TestInstanceTagsControllerDefaults(c *gc.C) {
+	cfg := testing.CustomModelConfig(c, testing.Attrs{
+		"resource-tags": "a=b Environment=staging",
+	})
+	controllerCfg := testing.FakeControllerConfig()
+	controllerCfg[controller.DefaultResourceTagsKey] = "Environment=prod CostCentre=42"
+	tags := instancecfg.InstanceTags(testing.ModelTag.Id(), testing.ControllerTag.Id(), nil, controllerCfg, cfg)
+	c.Assert(tags, jc.DeepEquals, map[string]string{
+		"juju-model-uuid":      testing.ModelTag.Id(),
+		"juju-controller-uuid": testing.ControllerTag.Id(),
+		"a":                    "b",
+		"Environment":          "staging",
+		"CostCentre":           "42",
+	})
+}
+
 func testInstanceTags(c *gc.C, cfg *config.Config, jobs []multiwatcher.MachineJob, expectTags map[string]string) {
-	tags := instancecfg.InstanceTags(testing.ModelTag.Id(), testing.ControllerTag.Id(), cfg, jobs)
+	tags := instancecfg.InstanceTags(testing.ModelTag.Id(), testing.ControllerTag.Id(), jobs, cfg)
 	c.Assert(tags, jc.DeepEquals, expectTags)
 }
 
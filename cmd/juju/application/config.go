@@ -4,43 +4,264 @@ package application
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/juju/cmd"
 	"github.com/juju/errors"
 	"github.com/juju/gnuflag"
+	"github.com/juju/utils/clock"
 	"github.com/juju/utils/keyvalues"
+	"github.com/juju/utils/set"
+	"gopkg.in/juju/charm.v6-unstable"
+	"gopkg.in/juju/charmrepo.v2-unstable"
+	"gopkg.in/juju/worker.v1"
+	"gopkg.in/yaml.v2"
 
+	"github.com/juju/juju/api"
 	"github.com/juju/juju/api/application"
 	"github.com/juju/juju/apiserver/params"
+	jujucmd "github.com/juju/juju/cmd"
 	"github.com/juju/juju/cmd/juju/block"
 	"github.com/juju/juju/cmd/modelcmd"
 	"github.com/juju/juju/cmd/output"
+	"github.com/juju/juju/juju/osenv"
+	"github.com/juju/juju/jujuclient"
+	"github.com/juju/juju/status"
+	"github.com/juju/juju/watcher"
 )
 
 const maxValueSize = 5242880 // Max size for a config file.
 
+// Bounds on the interval between checks for units having applied new
+// settings when --max-wait is given; the interval doubles after each check.
+const (
+	minConvergeInterval = time.Second
+	maxConvergeInterval = 30 * time.Second
+)
+
+// The strategies for combining the settings in a file given with --file
+// with an application's current settings.
+const (
+	mergeStrategyReplace      = "replace"
+	mergeStrategyMerge        = "merge"
+	mergeStrategyResetMissing = "merge-reset-missing"
+)
+
+// pruneUnknownThreshold is the number of unknown settings above which
+// --prune-unknown requires --force.
+const pruneUnknownThreshold = 5
+
 const (
 	configSummary = `Gets, sets, or resets configuration for a deployed application.`
 	configDetails = `By default, all configuration (keys, values, metadata) for the application are
 displayed if a key is not specified.
 
 Output includes the name of the charm used to deploy the application and a
-listing of the application-specific configuration settings.
+listing of the application-specific configuration settings, sorted by key
+in every output format so that the output of successive runs can be compared.
 See ` + "`juju status`" + ` for application names.
 
 Examples:
     juju config apache2
     juju config --format=json apache2
     juju config mysql dataset-size
+    juju config --only-changed apache2
     juju config mysql --reset dataset-size,backup_dir
     juju config apache2 --file path/to/config.yaml
     juju config mysql dataset-size=80% backup_dir=/vol1/mysql/backups
     juju config apache2 --model mymodel --file /home/ubuntu/mysql.yaml
+    juju config mysql --backup mysql-backup.yaml dataset-size=80%
+    juju config mysql --prune-unknown
+    juju config apache2 --file path/to/config.yaml --ignore-errors
+    juju config apache2 --file path/to/config.yaml --count-changes --confirm
+    juju config haproxy --key-file ssl_cert=cert.pem --key-file ssl_key=key.pem
+    juju config mysql --watch
+    juju config mysql dataset-size --watch
+    juju config mysql dataset-size=80% --max-wait 10m
+    juju config mysql --explain dataset-size
+    juju config haproxy --get-file ssl_cert=cert.pem --get-file ssl_key=key.pem
+    juju config myapp --expand-vars 'endpoint=https://${model-name}.internal'
+    juju config mysql --revert-last
+    juju config mysql dataset-size=80% --audit-log ~/juju-config-audit.log
+    juju config mysql --no-charm-check dataset-size=80%
+    juju config myapp --redact-in-logs password=s3cret
+    juju config mysql --diff-against-file known-good.yaml
+    juju config mysql --diff-against-file known-good.yaml --format=json
+    juju config mysql --file path/to/config.yaml --strict
+    juju config mysql --plan dataset-size=80% --reset backup_dir
+    juju config mysql --template production dataset-size=60%
+    juju config mysql --from-bundle bundle.yaml
+    juju config mysql --file path/to/config.yaml --merge-strategy merge-reset-missing
+    juju config mysql --lock
+    juju config mysql --unlock
+
+When --backup is specified with a set or reset, the current non-default
+settings are written to the given file before any change is made. The file
+can later be passed to --file to restore them.
+
+After a charm upgrade removes configuration options, settings for them may
+remain stored. --prune-unknown resets all settings that the charm no longer
+defines, reporting each one. If more than five settings would be removed,
+--force is also required.
+
+With --count-changes, the settings in the file given with --file are
+compared with the application's current settings before they are applied,
+and a one-line summary is printed of how many would change, how many would
+be reset to their defaults (those given an empty value, or with --reset),
+how many keys the charm does not define, and how many are unchanged. With
+--confirm as well, the settings are only applied, and --reset only
+resets any, once the summary has been confirmed.
+
+Each --key-file key=path sets the key to the contents of the file at path,
+as with key=@path. It may be repeated, and combined with key=value
+arguments, but a key may only be given once.
+
+By default, setting values is all-or-nothing: if any value is rejected, none
+are applied. With --ignore-errors, values that are rejected are skipped and
+the rest are applied; the skipped keys and the reasons are reported at the
+end, and the command exits with an error.
+
+With --watch, the command does not exit after getting the settings. Instead,
+each change to the application's settings (or to the given key) is printed
+as it occurs, as "key: old -> new", until the command is interrupted. The
+controller must support watching application settings.
+
+With --max-wait, after setting or resetting values the command waits up to
+the given duration for every unit of the application to apply the new
+settings, which it does by running its config-changed hook. The number of
+units that have done so is reported at increasing intervals. The command
+succeeds as soon as all units have applied the settings; otherwise it exits
+with an error listing the units that have not. Units only run the hook when
+a setting actually changes.
+
+With --explain, the command describes how the application interprets the
+given key before it is changed: its type, its charm default, its
+description and its current value, and whether that value was set by the
+user or is the charm default.
+
+Each --get-file key=path writes the current value of the key, or its charm
+default, to the file at path, as it is without any yaml or json formatting,
+so that a value set with --key-file can be retrieved unchanged. The file is
+only readable by its owner, as values such as keys and passwords may be
+secret. It may be repeated, but cannot be combined with setting, resetting,
+watching or getting values on stdout.
+
+With --expand-vars, model variables of the form ${name} in key=value
+arguments are replaced before the values are set. The variables are:
+    ${controller-name}  the name of the controller
+    ${model-name}       the name of the model, without its owner
+    ${model-uuid}       the UUID of the model
+Any other variable is an error. Without --expand-vars, values are set
+exactly as given.
+
+With --revert-last, the most recent change to the application's settings is
+undone: the settings are restored to the values they had before it, and each
+setting that changes back is reported as "key: old -> new". Only the most
+recent change is retained by the controller, so reverting twice undoes the
+revert. It is an error if the settings have never been changed, or if the
+controller does not retain previous settings.
+
+With --audit-log, each change made to the application's settings is
+appended to the given file as a line of JSON, recording the time, the user,
+the application, and the old and new value of each setting that changed.
+The file is created if it does not exist. Nothing is recorded if applying
+the settings fails, or if they are already set as given.
+
+With --no-charm-check, key=value arguments are sent to the controller as
+given, without first fetching the charm's configuration schema. It is meant
+for recovery, when the charm's metadata is unavailable and the schema cannot
+be fetched; the controller still validates the values it is able to. It can
+only be used when setting values as key=value arguments, and not with
+options that need the schema, such as --backup or --audit-log.
+
+The controller always redacts the values being set from the requests it
+writes to its logs and audit log, whether or not --redact-in-logs is given.
+The errors it returns when it rejects values may quote them, and are shown
+by this command and may be logged, except for the values of options that
+the charm marks as sensitive by starting their description with
+"[sensitive]". --redact-in-logs only affects these errors: it asks the
+controller to keep every value being set out of them. Use it when setting
+secrets that the charm does not mark. It can only be used when setting
+values, and the controller must support redacting them.
+
+With --diff-against-file, the settings in the given file are compared with
+the application's current settings, without applying them. Keys whose values
+differ are reported with both values, as are keys set only in the file and
+keys set only on the application; settings at their charm default count as
+not set. An empty value in the file stands for the default, as it does with
+--file. The file may be in the format accepted by --file, such as one
+written by --backup, and the report is written in the format given with
+--format.
+
+With --strict, the keys being set are checked against the charm's
+configuration schema before any is applied, and nothing is applied if any
+key is one the charm does not define. The offending keys are listed.
+Without --strict, unknown keys are left for the controller to reject;
+--prune-unknown resets settings for keys the charm no longer defines.
+
+With --since, only the settings last changed after the given time, such as
+2017-06-01T12:00:00Z, are listed, each with the time it was changed. The
+controller must record when settings change; settings last changed before it
+began recording are not listed.
+
+With --plan, nothing is applied. Instead, a JSON document describes what
+setting or resetting the given values would do, for tooling such as CI
+gates. It holds the application's name and, sorted by key, an entry for
+each key given, with its action ("set", "reset", "no-op" or "unknown"), its
+current value ("old") and the value it would have ("new"). An absent value
+is null. Keys the charm does not define are "unknown", and keys that would
+keep their current value are "no-op". The document is always JSON,
+whatever --format is given.
+
+With --template, the settings of the named config template are applied, as
+with --file. Config templates are reusable presets kept as yaml files in the
+config-templates directory of the Juju client's data directory, such as
+~/.local/share/juju/config-templates/production.yaml for the template
+"production". A template holds a map of option names to values; the output of
+"juju config", and files in the format accepted by --file, may also be used.
+key=value and --key-file arguments override the template's values. Before
+anything is applied, every setting is checked against the charm's
+configuration schema, and nothing is applied if any key is one the charm does
+not define or any value is not of the option's type. The settings to be
+applied are then reported. As with --file, an empty value resets an option
+to its default.
+
+With --from-bundle, the options given to the application in the named bundle
+file are applied, in the same way as a config template's settings, without
+redeploying anything. The bundle must deploy the application with the charm
+it already has; nothing is applied if the application is not in the bundle
+or its charm differs.
+
+With --merge-strategy, it is made explicit how the settings in the file given
+with --file combine with the application's current settings:
+    replace              only the file's settings are sent, and settings
+                         absent from the file are left unchanged; this is
+                         the default
+    merge                the file's settings are overlaid onto the current
+                         settings; the controller already leaves settings
+                         absent from the file unchanged, so this is the
+                         same as replace
+    merge-reset-missing  as with merge, and settings absent from the file
+                         are reset to their defaults, so the application
+                         ends up configured exactly as the file describes
+In every case, an empty value in the file resets an option to its default.
+
+With --lock, the application's settings are locked on the controller, which
+then refuses any change to them, from this or any other client, with a
+"config locked" error until they are unlocked with --unlock. Getting the
+settings still works, and their output includes "config-locked: true" while
+they are locked. The lock is kept by the controller, so it lasts until it is
+removed. The controller must support locking application settings.
 
 See also:
     deploy
@@ -51,13 +272,14 @@ See also:
 // NewConfigCommand returns a command used to get, reset, and set application
 // attributes.
 func NewConfigCommand() cmd.Command {
-	return modelcmd.Wrap(&configCommand{})
+	return modelcmd.Wrap(&configCommand{clock: clock.WallClock})
 }
 
 // NewConfigCommandForTest returns a SetCommand with the api provided as specified.
 func NewConfigCommandForTest(api configCommandAPI) modelcmd.ModelCommand {
 	return modelcmd.Wrap(&configCommand{
-		api: api,
+		api:   api,
+		clock: clock.WallClock,
 	})
 }
 
@@ -67,16 +289,45 @@ type attributes map[string]string
 type configCommand struct {
 	api configCommandAPI
 	modelcmd.ModelCommandBase
-	out cmd.Output
+	out   cmd.Output
+	clock clock.Clock
 
 	action          func(configCommandAPI, *cmd.Context) error // get, set, or reset action set in  Init
 	applicationName string
+	auditLog        string
+	backupPath      string
 	configFile      cmd.FileVar
+	confirm         bool
+	countChanges    bool
+	diffFile        cmd.FileVar
+	expandVars      bool
+	explainKey      string
+	force           bool
+	getFiles        []string          // Holds the key=path pairs given with --get-file.
+	getFilePaths    map[string]string // Holds the paths to write keys to once parsed.
+	ignoreErrors    bool
+	keyFiles        []string // Holds the key=path pairs given with --key-file.
 	keys            []string
+	lock            bool
+	maxWait         time.Duration
+	mergeStrategy   string
+	noCharmCheck    bool
+	onlyChanged     bool
+	plan            bool
+	pruneUnknown    bool
+	redactInLogs    bool
+	revertLast      bool
+	since           string
+	sinceTime       time.Time
+	strict          bool
+	template        string
+	unlock          bool
+	bundleFile      string
 	reset           []string // Holds the keys to be reset until parsed.
 	resetKeys       []string // Holds the keys to be reset once parsed.
 	useFile         bool
 	values          attributes
+	watch           bool
 }
 
 // configCommandAPI is an interface to allow passing in a fake implementation under test.
@@ -85,14 +336,33 @@ type configCommandAPI interface {
 	Update(args params.ApplicationUpdate) error
 	Get(application string) (*params.ApplicationGetResults, error)
 	Set(application string, options map[string]string) error
+	SetRedacted(application string, options map[string]string) error
+	SetConfigLocked(application string, locked bool) error
 	Unset(application string, options []string) error
+	WatchConfig(application string) (watcher.NotifyWatcher, error)
+	PreviousConfig(application string) (map[string]interface{}, error)
+	ConfigChangeTimes(application string) (map[string]time.Time, error)
+	Status(patterns []string) (*params.FullStatus, error)
+}
+
+// configAPI implements configCommandAPI, adding the model status, used
+// to wait for units to apply settings, to the application client.
+type configAPI struct {
+	*application.Client
+	statusClient *api.Client
+}
+
+// Status returns the status of the model's entities matching the given
+// patterns.
+func (a *configAPI) Status(patterns []string) (*params.FullStatus, error) {
+	return a.statusClient.Status(patterns)
 }
 
 // Info is part of the cmd.Command interface.
 func (c *configCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "config",
-		Args:    "<application name> [--reset <key[,key]>] [<attribute-key>][=<value>] ...]",
+		Args:    "<application name> [--reset <key[,key]>] [--prune-unknown] [<attribute-key>][=<value>] ...]",
 		Purpose: configSummary,
 		Doc:     configDetails,
 	}
@@ -104,6 +374,32 @@ func (c *configCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.out.AddFlags(f, "yaml", output.DefaultFormatters)
 	f.Var(&c.configFile, "file", "path to yaml-formatted application config")
 	f.Var(cmd.NewAppendStringsValue(&c.reset), "reset", "Reset the provided comma delimited keys")
+	f.Var(cmd.NewAppendStringsValue(&c.keyFiles), "key-file", "Set a key to the contents of a file, given as key=path; may be repeated")
+	f.Var(cmd.NewAppendStringsValue(&c.getFiles), "get-file", "Write the value of a key to a file, given as key=path; may be repeated")
+	f.StringVar(&c.backupPath, "backup", "", "Before setting or resetting, write the current non-default settings to this yaml file")
+	f.BoolVar(&c.onlyChanged, "only-changed", false, "When getting all settings, only show those that differ from the charm defaults")
+	f.BoolVar(&c.pruneUnknown, "prune-unknown", false, "Reset settings for keys that the charm no longer defines")
+	f.BoolVar(&c.force, "force", false, "Allow --prune-unknown to remove more than "+fmt.Sprint(pruneUnknownThreshold)+" settings")
+	f.BoolVar(&c.ignoreErrors, "ignore-errors", false, "When setting values, skip those that are rejected and apply the rest")
+	f.BoolVar(&c.watch, "watch", false, "Print changes to the settings as they occur, until interrupted")
+	f.DurationVar(&c.maxWait, "max-wait", 0, "After setting or resetting, wait up to this long for all units to apply the settings")
+	f.StringVar(&c.explainKey, "explain", "", "Describe the type, default, description and current value of the given key")
+	f.BoolVar(&c.expandVars, "expand-vars", false, "Replace model variables such as ${model-name} in key=value arguments")
+	f.BoolVar(&c.revertLast, "revert-last", false, "Restore the settings as they were before the most recent change")
+	f.BoolVar(&c.countChanges, "count-changes", false, "Before applying --file, summarise how many settings would change, be reset or are unknown")
+	f.BoolVar(&c.confirm, "confirm", false, "With --count-changes, ask for confirmation before applying the settings")
+	f.StringVar(&c.auditLog, "audit-log", "", "Append the changes made to the settings to this JSON-lines file")
+	f.BoolVar(&c.noCharmCheck, "no-charm-check", false, "Set key=value arguments without fetching the charm's config schema, for recovery")
+	f.BoolVar(&c.redactInLogs, "redact-in-logs", false, "Ask the controller to keep all of the values being set out of the errors it returns, which may be logged (requests are always redacted)")
+	f.Var(&c.diffFile, "diff-against-file", "Compare the current settings with those in this yaml file, without applying them")
+	f.BoolVar(&c.strict, "strict", false, "When setting values, apply none if any key is unknown to the charm")
+	f.StringVar(&c.since, "since", "", "When getting all settings, only show those changed after this RFC3339 time")
+	f.BoolVar(&c.plan, "plan", false, "Print a JSON plan of what setting or resetting the values would do, without applying them")
+	f.StringVar(&c.template, "template", "", "Apply the named config template, overridden by any key=value arguments")
+	f.StringVar(&c.bundleFile, "from-bundle", "", "Apply the application's options from this bundle file, overridden by any key=value arguments")
+	f.BoolVar(&c.lock, "lock", false, "Lock the settings on the controller, so that they cannot be changed until unlocked")
+	f.BoolVar(&c.unlock, "unlock", false, "Unlock settings locked with --lock")
+	f.StringVar(&c.mergeStrategy, "merge-strategy", mergeStrategyReplace, "How the settings from --file combine with the current ones: replace, merge or merge-reset-missing")
 }
 
 // getAPI either uses the fake API set at test time or that is nil, gets a real
@@ -116,8 +412,10 @@ func (c *configCommand) getAPI() (configCommandAPI, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	client := application.NewClient(root)
-	return client, nil
+	return &configAPI{
+		Client:       application.NewClient(root),
+		statusClient: root.Client(),
+	}, nil
 }
 
 // Init is part of the cmd.Command interface.
@@ -137,14 +435,187 @@ func (c *configCommand) Init(args []string) error {
 	c.applicationName = args[0]
 	args = args[1:]
 
+	var err error
 	switch len(args) {
 	case 0:
-		return c.handleZeroArgs()
+		err = c.handleZeroArgs()
 	case 1:
-		return c.handleOneArg(args)
+		err = c.handleOneArg(args)
+	default:
+		err = c.handleArgs(args)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.parseKeyFiles(); err != nil {
+		return errors.Trace(err)
+	}
+	if c.template != "" {
+		if !validTemplateName.MatchString(c.template) {
+			return errors.Errorf("--template: invalid config template name %q", c.template)
+		}
+		if c.bundleFile != "" {
+			return errors.New("--template cannot be combined with --from-bundle")
+		}
+		if err := c.checkSettingsSource("--template"); err != nil {
+			return errors.Trace(err)
+		}
+		c.action = c.templateConfig
+	}
+	if c.bundleFile != "" {
+		if err := c.checkSettingsSource("--from-bundle"); err != nil {
+			return errors.Trace(err)
+		}
+		c.action = c.bundleConfig
+	}
+	if c.pruneUnknown {
+		if c.changesConfig() || len(c.keys) > 0 {
+			return errors.New("--prune-unknown cannot be combined with getting, setting or resetting values")
+		}
+		c.action = c.pruneConfig
+	} else if c.force {
+		return errors.New("--force can only be used with --prune-unknown")
+	}
+	if c.explainKey != "" {
+		if c.changesConfig() || len(c.keys) > 0 || c.pruneUnknown || c.watch {
+			return errors.New("--explain cannot be combined with getting, setting, resetting or watching values")
+		}
+		c.action = c.explainConfig
+	}
+	if c.revertLast {
+		if len(c.resetKeys) > 0 || c.useFile || len(c.values) > 0 || len(c.keys) > 0 || c.pruneUnknown || c.explainKey != "" {
+			return errors.New("--revert-last cannot be combined with getting, setting or resetting values")
+		}
+		c.action = c.revertConfig
+	}
+	if c.diffFile.Path != "" {
+		if c.changesConfig() || len(c.keys) > 0 || c.pruneUnknown || c.explainKey != "" || c.watch || c.onlyChanged {
+			return errors.New("--diff-against-file cannot be combined with getting, setting, resetting or watching values")
+		}
+		c.action = c.diffConfig
+	}
+	if err := c.parseGetFiles(); err != nil {
+		return errors.Trace(err)
+	}
+	if c.lock || c.unlock {
+		if c.lock && c.unlock {
+			return errors.New("--lock and --unlock cannot be combined")
+		}
+		if c.changesConfig() || len(c.keys) > 0 || c.pruneUnknown || c.explainKey != "" || c.watch ||
+			c.diffFile.Path != "" || c.since != "" || c.onlyChanged || len(c.getFiles) > 0 {
+			return errors.New("--lock and --unlock cannot be combined with getting, setting, resetting or watching values")
+		}
+		c.action = c.setConfigLocked
+	}
+	switch c.mergeStrategy {
+	case mergeStrategyReplace:
+	case mergeStrategyMerge, mergeStrategyResetMissing:
+		if !c.useFile {
+			return errors.New("--merge-strategy can only be used with --file")
+		}
 	default:
-		return c.handleArgs(args)
+		return errors.Errorf("--merge-strategy: %q is not valid, expected %q, %q or %q",
+			c.mergeStrategy, mergeStrategyReplace, mergeStrategyMerge, mergeStrategyResetMissing)
+	}
+	if c.countChanges && !c.useFile {
+		return errors.New("--count-changes can only be used with --file")
+	}
+	if c.confirm {
+		if !c.countChanges {
+			return errors.New("--confirm can only be used with --count-changes")
+		}
+		if c.configFile.Path == "-" {
+			return errors.New("--confirm cannot be used when reading settings from stdin")
+		}
 	}
+	if c.ignoreErrors && !c.useFile && len(c.values) == 0 {
+		return errors.New("--ignore-errors can only be used when setting values")
+	}
+	if c.backupPath != "" && !c.changesConfig() && !c.pruneUnknown {
+		return errors.New("--backup can only be used when setting or resetting values")
+	}
+	if c.auditLog != "" && !c.changesConfig() && !c.pruneUnknown {
+		return errors.New("--audit-log can only be used when setting or resetting values")
+	}
+	if c.watch {
+		if c.changesConfig() || c.pruneUnknown {
+			return errors.New("--watch can only be used when getting values")
+		}
+		if c.onlyChanged {
+			return errors.New("--watch cannot be combined with --only-changed")
+		}
+		c.action = c.watchConfig
+	}
+	if c.expandVars && len(c.values) == 0 {
+		return errors.New("--expand-vars can only be used when setting values as key=value arguments")
+	}
+	if c.noCharmCheck {
+		if len(c.values) == 0 || c.useFile {
+			return errors.New("--no-charm-check can only be used when setting values as key=value arguments")
+		}
+		if c.backupPath != "" || c.auditLog != "" {
+			return errors.New("--no-charm-check cannot be combined with --backup or --audit-log, which need the charm's config schema")
+		}
+	}
+	if c.strict {
+		if !c.useFile && len(c.values) == 0 && c.template == "" && c.bundleFile == "" {
+			return errors.New("--strict can only be used when setting values")
+		}
+		if c.noCharmCheck {
+			return errors.New("--strict cannot be combined with --no-charm-check, which skips the charm's config schema")
+		}
+	}
+	if c.since != "" {
+		if c.changesConfig() || len(c.keys) > 0 || c.pruneUnknown || c.explainKey != "" || c.watch || c.diffFile.Path != "" {
+			return errors.New("--since can only be used when getting all values")
+		}
+		since, err := time.Parse(time.RFC3339, c.since)
+		if err != nil {
+			return errors.Errorf("--since: %q is not a valid time, expected one such as 2017-06-01T12:00:00Z", c.since)
+		}
+		c.sinceTime = since
+	}
+	if c.redactInLogs && !c.useFile && len(c.values) == 0 && c.template == "" && c.bundleFile == "" {
+		return errors.New("--redact-in-logs can only be used when setting values")
+	}
+	if c.maxWait < 0 {
+		return errors.New("--max-wait must not be negative")
+	}
+	if c.maxWait > 0 && !c.changesConfig() {
+		return errors.New("--max-wait can only be used when setting or resetting values")
+	}
+	if c.plan {
+		if !c.changesConfig() || c.revertLast {
+			return errors.New("--plan can only be used when setting or resetting values")
+		}
+		if c.backupPath != "" || c.auditLog != "" || c.maxWait > 0 || c.countChanges || c.ignoreErrors || c.noCharmCheck {
+			return errors.New("--plan cannot be combined with --backup, --audit-log, --max-wait, --count-changes, --ignore-errors or --no-charm-check")
+		}
+		c.action = c.planConfig
+	}
+	return nil
+}
+
+// checkSettingsSource returns an error if the flags given cannot be
+// combined with the given flag, which applies settings from a config
+// template or a bundle.
+func (c *configCommand) checkSettingsSource(flag string) error {
+	if c.useFile || len(c.resetKeys) > 0 || len(c.keys) > 0 {
+		return errors.Errorf("%s cannot be combined with --file, --reset or getting values", flag)
+	}
+	if c.pruneUnknown || c.explainKey != "" || c.revertLast || c.watch || c.diffFile.Path != "" || c.since != "" || c.plan {
+		return errors.Errorf("%s cannot be combined with --prune-unknown, --explain, --revert-last, --watch, --diff-against-file, --since or --plan", flag)
+	}
+	if c.countChanges || c.ignoreErrors || c.noCharmCheck {
+		return errors.Errorf("%s cannot be combined with --count-changes, --ignore-errors or --no-charm-check", flag)
+	}
+	return nil
+}
+
+// changesConfig reports whether the command will change the application's
+// configuration, rather than only retrieve it.
+func (c *configCommand) changesConfig() bool {
+	return len(c.resetKeys) > 0 || c.useFile || len(c.values) > 0 || c.revertLast || c.template != "" || c.bundleFile != ""
 }
 
 // handleZeroArgs handles the case where there are no positional args.
@@ -239,6 +710,60 @@ func (c *configCommand) parseSet(args []string) error {
 	return nil
 }
 
+// parseKeyFiles adds the keys given with --key-file to the values to be
+// set, each to be read from its file.
+func (c *configCommand) parseKeyFiles() error {
+	if len(c.keyFiles) == 0 {
+		return nil
+	}
+	if c.useFile {
+		return errors.New("cannot specify --file and --key-file simultaneously")
+	}
+	if len(c.keys) > 0 {
+		return errors.New("cannot set and retrieve values simultaneously")
+	}
+	keyFiles, err := keyvalues.Parse(c.keyFiles, false)
+	if err != nil {
+		return errors.Annotate(err, "invalid --key-file")
+	}
+	if c.values == nil {
+		c.values = make(attributes)
+	}
+	for key, path := range keyFiles {
+		if _, ok := c.values[key]; ok {
+			return errors.Errorf("key %q specified both as an argument and with --key-file", key)
+		}
+		c.values[key] = "@" + path
+	}
+	c.action = c.setConfig
+	return nil
+}
+
+// parseGetFiles parses the keys given with --get-file, and the paths
+// their values are to be written to.
+func (c *configCommand) parseGetFiles() error {
+	if len(c.getFiles) == 0 {
+		return nil
+	}
+	if c.changesConfig() || len(c.keys) > 0 || c.pruneUnknown || c.explainKey != "" || c.revertLast || c.watch || c.diffFile.Path != "" || c.onlyChanged || c.since != "" {
+		return errors.New("--get-file cannot be combined with getting, setting, resetting or watching values")
+	}
+	getFiles, err := keyvalues.Parse(c.getFiles, false)
+	if err != nil {
+		return errors.Annotate(err, "invalid --get-file")
+	}
+	paths := set.NewStrings()
+	for _, path := range getFiles {
+		if paths.Contains(path) {
+			return errors.Errorf("invalid --get-file: path %q given for more than one key", path)
+		}
+		paths.Add(path)
+	}
+	c.getFilePaths = getFiles
+	c.action = c.getConfigFiles
+	return nil
+}
+
 // Run implements the cmd.Command interface.
 func (c *configCommand) Run(ctx *cmd.Context) error {
 	client, err := c.getAPI()
@@ -246,7 +771,30 @@ func (c *configCommand) Run(ctx *cmd.Context) error {
 		return errors.Trace(err)
 	}
 	defer client.Close()
-	if len(c.resetKeys) > 0 {
+	var agentStatus map[string]params.DetailedStatus
+	if c.maxWait > 0 {
+		// Record the units' agent status before changing the settings,
+		// so that their response to the change can be recognised.
+		if agentStatus, err = c.unitAgentStatus(client); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if c.backupPath != "" {
+		if err := c.backupConfig(client, ctx); err != nil {
+			return errors.Annotate(err, "cannot back up application config")
+		}
+	}
+	var audit *configAuditEntry
+	if c.auditLog != "" {
+		// Record the settings before changing them, so that the
+		// changes can be recorded once they have been applied.
+		if audit, err = c.startAudit(client); err != nil {
+			return errors.Annotate(err, "cannot audit application config")
+		}
+	}
+	// With --count-changes, the settings to reset are counted along
+	// with those in the file, and reset only once they are confirmed.
+	if len(c.resetKeys) > 0 && !c.plan && !c.countChanges {
 		if err := c.resetConfig(client, ctx); err != nil {
 			// We return this error naked as it is almost certainly going to be
 			// cmd.ErrSilent and the cmd.Command framework expects that back
@@ -254,102 +802,1500 @@ func (c *configCommand) Run(ctx *cmd.Context) error {
 			return err
 		}
 	}
-	if c.action == nil {
-		// If we are reset only we end up here, only we've already done that.
-		return nil
+	if c.action != nil {
+		// If we are reset only there is no action, as we've already
+		// done that.
+		if err := c.action(client, ctx); err != nil {
+			return err
+		}
+	}
+	if audit != nil {
+		if err := c.finishAudit(client, ctx, audit); err != nil {
+			return errors.Annotate(err, "cannot write application config audit log")
+		}
+	}
+	if c.maxWait > 0 {
+		return c.waitForUnits(client, ctx, agentStatus)
 	}
+	return nil
+}
 
-	return c.action(client, ctx)
+// unitAgentStatus returns the agent status of each of the application's
+// units, keyed by unit name.
+func (c *configCommand) unitAgentStatus(client configCommandAPI) (map[string]params.DetailedStatus, error) {
+	fullStatus, err := client.Status([]string{c.applicationName})
+	if err != nil {
+		return nil, errors.Annotate(err, "cannot get unit status")
+	}
+	app, ok := fullStatus.Applications[c.applicationName]
+	if !ok {
+		return nil, errors.NotFoundf("application %q", c.applicationName)
+	}
+	result := make(map[string]params.DetailedStatus)
+	for name, unit := range app.Units {
+		result[name] = unit.AgentStatus
+	}
+	return result, nil
 }
 
-// resetConfig is the run action when we are resetting attributes.
-func (c *configCommand) resetConfig(client configCommandAPI, ctx *cmd.Context) error {
-	return block.ProcessBlockedError(client.Unset(c.applicationName, c.resetKeys), block.BlockChange)
+// waitForUnits waits up to the maximum wait for each of the
+// application's units to apply the new settings, checking at
+// exponentially increasing intervals and reporting progress after each
+// check. A unit has applied the settings once its agent has been busy
+// since the settings were changed and is idle again. The given agent
+// status is that of the units before the change.
+func (c *configCommand) waitForUnits(client configCommandAPI, ctx *cmd.Context, before map[string]params.DetailedStatus) error {
+	deadline := c.clock.Now().Add(c.maxWait)
+	interval := minConvergeInterval
+	for {
+		current, err := c.unitAgentStatus(client)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		var laggards []string
+		for name, agentStatus := range current {
+			if !appliedSettings(before[name], agentStatus) {
+				laggards = append(laggards, name)
+			}
+		}
+		if len(current) == 0 {
+			ctx.Infof("No units to wait for")
+			return nil
+		}
+		if len(laggards) == 0 {
+			ctx.Infof("All %d units have applied the settings", len(current))
+			return nil
+		}
+		ctx.Infof("%d of %d units have applied the settings", len(current)-len(laggards), len(current))
+
+		remaining := deadline.Sub(c.clock.Now())
+		if remaining <= 0 {
+			sort.Strings(laggards)
+			return errors.Errorf(
+				"timed out after %v waiting for units to apply the settings: %s",
+				c.maxWait, strings.Join(laggards, ", "),
+			)
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		<-c.clock.After(interval)
+		if interval *= 2; interval > maxConvergeInterval {
+			interval = maxConvergeInterval
+		}
+	}
 }
 
-// setConfig is the run action when we are setting new attribute values as args
-// or as a file passed in.
-func (c *configCommand) setConfig(client configCommandAPI, ctx *cmd.Context) error {
-	if c.useFile {
-		return c.setConfigFromFile(client, ctx)
+// appliedSettings reports whether a unit whose agent status was before
+// when its settings were changed, and is now current, has since applied
+// them. Units that did not exist before the change start with the
+// current settings, and so have applied them once they are idle.
+func appliedSettings(before, current params.DetailedStatus) bool {
+	if current.Status != string(status.Idle) {
+		return false
+	}
+	if before.Status == "" {
+		return true
+	}
+	if before.Since == nil || current.Since == nil {
+		return before.Status != string(status.Idle)
 	}
+	return !current.Since.Equal(*before.Since)
+}
 
-	settings, err := c.validateValues(ctx)
+// backupConfig writes the application's current non-default settings to
+// the backup path, in a format that can be applied again with --file.
+func (c *configCommand) backupConfig(client configCommandAPI, ctx *cmd.Context) error {
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	options := make(map[string]interface{})
+	for k, v := range changedSettings(results.Config) {
+		info, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := info["value"]; ok {
+			options[k] = value
+		}
+	}
+	data, err := yaml.Marshal(map[string]map[string]interface{}{c.applicationName: options})
 	if err != nil {
 		return errors.Trace(err)
 	}
+	// The settings may hold secrets, so the backup is readable only by
+	// its owner.
+	path := ctx.AbsPath(c.backupPath)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return errors.Trace(err)
+	}
+	// WriteFile only sets the permissions of new files.
+	if err := os.Chmod(path, 0600); err != nil {
+		return errors.Trace(err)
+	}
+	ctx.Infof("Current settings backed up to %s", path)
+	return nil
+}
 
-	result, err := client.Get(c.applicationName)
+// configAuditEntry is a line of the file given with --audit-log,
+// recording the changes made to an application's settings.
+type configAuditEntry struct {
+	Timestamp   time.Time                    `json:"timestamp"`
+	User        string                       `json:"user"`
+	Application string                       `json:"application"`
+	Changes     map[string]configAuditChange `json:"changes"`
+
+	// before holds the values of the settings before the changes.
+	before map[string]interface{}
+}
+
+// configAuditChange records the old and new value of a setting; a
+// value that is not set is recorded as null.
+type configAuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// startAudit returns an audit entry holding the application's current
+// settings, to be completed by finishAudit once they have been changed.
+func (c *configCommand) startAudit(client configCommandAPI) (*configAuditEntry, error) {
+	account, err := c.CurrentAccountDetails()
 	if err != nil {
-		return err
+		return nil, errors.Annotate(err, "getting account details")
 	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &configAuditEntry{
+		User:        account.User,
+		Application: c.applicationName,
+		before:      settingValues(results.Config),
+	}, nil
+}
 
-	for k, v := range settings {
-		configValue := result.Config[k]
-
-		configValueMap, ok := configValue.(map[string]interface{})
-		if ok {
-			// convert the value to string and compare
-			if fmt.Sprintf("%v", configValueMap["value"]) == v {
-				logger.Warningf("the configuration setting %q already has the value %q", k, v)
-			}
-		}
+// finishAudit appends the changes made to the application's settings
+// since the audit was started to the audit log. Nothing is written if
+// the settings have not changed.
+func (c *configCommand) finishAudit(client configCommandAPI, ctx *cmd.Context, audit *configAuditEntry) error {
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	after := settingValues(results.Config)
+	changed := changedSettingKeys(audit.before, after)
+	if len(changed) == 0 {
+		return nil
+	}
+	audit.Timestamp = c.clock.Now().UTC()
+	audit.Changes = make(map[string]configAuditChange)
+	for _, k := range changed {
+		audit.Changes[k] = configAuditChange{Old: audit.before[k], New: after[k]}
+	}
+	data, err := json.Marshal(audit)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// The log records setting values, which may be secrets, so it is
+	// created readable only by its owner.
+	f, err := os.OpenFile(ctx.AbsPath(c.auditLog), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Trace(err)
 	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(f.Close())
+}
 
-	return block.ProcessBlockedError(client.Set(c.applicationName, settings), block.BlockChange)
+// resetConfig is the run action when we are resetting attributes.
+func (c *configCommand) resetConfig(client configCommandAPI, ctx *cmd.Context) error {
+	return block.ProcessBlockedError(client.Unset(c.applicationName, c.resetKeys), block.BlockChange)
 }
 
-// setConfigFromFile sets the application configuration from settings passed
-// in a YAML file.
-func (c *configCommand) setConfigFromFile(client configCommandAPI, ctx *cmd.Context) error {
-	var (
-		b   []byte
-		err error
-	)
-	if c.configFile.Path == "-" {
-		buf := bytes.Buffer{}
-		buf.ReadFrom(ctx.Stdin)
-		b = buf.Bytes()
+// setConfigLocked is the run action when we are locking or unlocking
+// the settings.
+func (c *configCommand) setConfigLocked(client configCommandAPI, ctx *cmd.Context) error {
+	err := client.SetConfigLocked(c.applicationName, c.lock)
+	if errors.IsNotSupported(err) {
+		return errors.New("cannot lock or unlock settings: the controller does not support locking application settings")
+	} else if err != nil {
+		return block.ProcessBlockedError(err, block.BlockChange)
+	}
+	if c.lock {
+		ctx.Infof("Locked settings of application %q", c.applicationName)
 	} else {
-		b, err = c.configFile.Read(ctx)
-		if err != nil {
-			return err
-		}
+		ctx.Infof("Unlocked settings of application %q", c.applicationName)
 	}
-	return block.ProcessBlockedError(
-		client.Update(
-			params.ApplicationUpdate{
-				ApplicationName: c.applicationName,
-				SettingsYAML:    string(b)}), block.BlockChange)
+	return nil
 }
 
-// getConfig is the run action to return one or all configuration values.
-func (c *configCommand) getConfig(client configCommandAPI, ctx *cmd.Context) error {
-	results, err := client.Get(c.applicationName)
+// revertConfig is the run action when we are restoring the settings as they
+// were before the most recent change, reporting those that change back.
+func (c *configCommand) revertConfig(client configCommandAPI, ctx *cmd.Context) error {
+	previous, err := client.PreviousConfig(c.applicationName)
+	if errors.IsNotFound(err) {
+		return errors.Errorf("no previous settings for application %q to revert to", c.applicationName)
+	} else if errors.IsNotSupported(err) {
+		return errors.New("cannot revert settings: the controller does not retain previous application settings")
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	before, err := client.Get(c.applicationName)
 	if err != nil {
 		return err
 	}
-	if len(c.keys) == 1 {
-		key := c.keys[0]
-		info, found := results.Config[key].(map[string]interface{})
-		if !found {
-			return errors.Errorf("key %q not found in %q application settings.", key, c.applicationName)
+	current := settingValues(before.Config)
+	toSet := make(map[string]interface{})
+	for k, v := range previous {
+		if formatSettingValue(current, k) != formatSettingValue(previous, k) {
+			toSet[k] = v
 		}
-		out := &bytes.Buffer{}
-		err := cmd.FormatYaml(out, info["value"])
-		if err != nil {
-			return err
+	}
+	var toReset []string
+	for k := range changedSettings(before.Config) {
+		if _, ok := previous[k]; !ok {
+			toReset = append(toReset, k)
 		}
-		fmt.Fprint(ctx.Stdout, out.String())
+	}
+	for _, k := range before.UnknownSettings {
+		if _, ok := previous[k]; !ok {
+			toReset = append(toReset, k)
+		}
+	}
+	if len(toSet) == 0 && len(toReset) == 0 {
+		ctx.Infof("No settings to revert")
 		return nil
 	}
-
-	resultsMap := map[string]interface{}{
-		"application": results.Application,
-		"charm":       results.Charm,
-		"settings":    results.Config,
+	if len(toSet) > 0 {
+		data, err := yaml.Marshal(map[string]map[string]interface{}{c.applicationName: toSet})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		err = client.Update(params.ApplicationUpdate{
+			ApplicationName: c.applicationName,
+			SettingsYAML:    string(data),
+		})
+		if err != nil {
+			return block.ProcessBlockedError(err, block.BlockChange)
+		}
 	}
-	return c.out.Write(ctx, resultsMap)
+	if len(toReset) > 0 {
+		sort.Strings(toReset)
+		if err := client.Unset(c.applicationName, toReset); err != nil {
+			return block.ProcessBlockedError(err, block.BlockChange)
+		}
+	}
+	after, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	writeSettingChanges(ctx, current, settingValues(after.Config))
+	return nil
+}
+
+// pruneConfig is the run action when we are resetting settings for keys the
+// charm no longer defines.
+func (c *configCommand) pruneConfig(client configCommandAPI, ctx *cmd.Context) error {
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	unknown := results.UnknownSettings
+	if len(unknown) == 0 {
+		ctx.Infof("No unknown settings to prune")
+		return nil
+	}
+	if len(unknown) > pruneUnknownThreshold && !c.force {
+		return errors.Errorf(
+			"would prune %d unknown settings (%s), more than %d; use --force to proceed",
+			len(unknown), strings.Join(unknown, ", "), pruneUnknownThreshold,
+		)
+	}
+	if err := block.ProcessBlockedError(client.Unset(c.applicationName, unknown), block.BlockChange); err != nil {
+		return err
+	}
+	for _, key := range unknown {
+		ctx.Infof("Pruned %q", key)
+	}
+	return nil
+}
+
+// setConfig is the run action when we are setting new attribute values as args
+// or as a file passed in.
+func (c *configCommand) setConfig(client configCommandAPI, ctx *cmd.Context) error {
+	if c.useFile {
+		return c.setConfigFromFile(client, ctx)
+	}
+
+	settings, err := c.validateValues(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if c.expandVars {
+		if err := c.expandModelVariables(settings); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if c.noCharmCheck {
+		fmt.Fprintf(ctx.Stderr, "WARNING: --no-charm-check given: setting values for %q without checking them against the charm's config schema\n", c.applicationName)
+	} else {
+		result, err := client.Get(c.applicationName)
+		if err != nil {
+			return err
+		}
+		if c.strict {
+			keys := make([]string, 0, len(settings))
+			for k := range settings {
+				keys = append(keys, k)
+			}
+			if err := checkStrictKeys(keys, result.Config); err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		for k, v := range settings {
+			configValue := result.Config[k]
+
+			configValueMap, ok := configValue.(map[string]interface{})
+			if ok {
+				// convert the value to string and compare
+				if fmt.Sprintf("%v", configValueMap["value"]) == v {
+					if c.redactInLogs {
+						logger.Warningf("the configuration setting %q already has the given value", k)
+					} else {
+						logger.Warningf("the configuration setting %q already has the value %q", k, v)
+					}
+				}
+			}
+		}
+	}
+
+	if c.ignoreErrors {
+		keys := make([]string, 0, len(settings))
+		for k := range settings {
+			keys = append(keys, k)
+		}
+		return applyBestEffort(ctx, keys, func(keys []string) error {
+			subset := make(map[string]string)
+			for _, k := range keys {
+				subset[k] = settings[k]
+			}
+			return c.setValues(client, subset)
+		})
+	}
+	return block.ProcessBlockedError(c.setValues(client, settings), block.BlockChange)
+}
+
+// setValues sets the given values, asking the controller to keep them
+// out of the errors it reports if --redact-in-logs was given.
+func (c *configCommand) setValues(client configCommandAPI, settings map[string]string) error {
+	if !c.redactInLogs {
+		return client.Set(c.applicationName, settings)
+	}
+	return redactError(client.SetRedacted(c.applicationName, settings))
+}
+
+// redactError returns err, replacing the error returned when the
+// controller cannot keep settings out of its errors with one explaining
+// that nothing was set.
+func redactError(err error) error {
+	if errors.IsNotSupported(err) {
+		return errors.New("cannot set values with --redact-in-logs: the controller does not support redacting them; no values were set")
+	}
+	return err
+}
+
+// applyBestEffort applies the settings for the given keys using apply.
+// If they cannot all be applied together, each key is applied on its own
+// and those that fail are skipped. The skipped keys are reported, and
+// cmd.ErrSilent returned, once all keys have been tried.
+func applyBestEffort(ctx *cmd.Context, keys []string, apply func(keys []string) error) error {
+	err := apply(keys)
+	if err == nil || params.IsCodeOperationBlocked(err) {
+		return block.ProcessBlockedError(err, block.BlockChange)
+	}
+	sort.Strings(keys)
+	var skipped []string
+	reasons := make(map[string]error)
+	for _, key := range keys {
+		err := apply([]string{key})
+		if params.IsCodeOperationBlocked(err) {
+			return block.ProcessBlockedError(err, block.BlockChange)
+		}
+		if err != nil {
+			skipped = append(skipped, key)
+			reasons[key] = err
+		}
+	}
+	if len(skipped) == 0 {
+		return nil
+	}
+	ctx.Infof("skipped %d of %d settings:", len(skipped), len(keys))
+	for _, key := range skipped {
+		ctx.Infof("  %s: %v", key, reasons[key])
+	}
+	return cmd.ErrSilent
+}
+
+// setConfigFromFile sets the application configuration from settings passed
+// in a YAML file.
+func (c *configCommand) setConfigFromFile(client configCommandAPI, ctx *cmd.Context) error {
+	b, err := readSettingsFile(ctx, c.configFile)
+	if err != nil {
+		return err
+	}
+	if c.strict {
+		if err := c.checkStrictFile(client, b); err != nil {
+			return err
+		}
+	}
+	missing, err := c.missingFileSettings(client, b)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if c.countChanges {
+		resets := append(append([]string(nil), c.resetKeys...), missing...)
+		if err := c.countFileChanges(client, ctx, b, resets); err != nil {
+			return err
+		}
+		if len(c.resetKeys) > 0 {
+			if err := c.resetConfig(client, ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if c.ignoreErrors {
+		// Settings absent from the file are reset even if some
+		// of those in it were skipped.
+		err = c.setConfigFromYAMLBestEffort(client, ctx, b)
+		if err != nil && err != cmd.ErrSilent {
+			return err
+		}
+	} else {
+		err = block.ProcessBlockedError(
+			redactError(client.Update(
+				params.ApplicationUpdate{
+					ApplicationName: c.applicationName,
+					SettingsYAML:    string(b),
+					RedactInLogs:    c.redactInLogs})), block.BlockChange)
+		if err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		if err := client.Unset(c.applicationName, missing); err != nil {
+			return block.ProcessBlockedError(err, block.BlockChange)
+		}
+	}
+	return err
+}
+
+// missingFileSettings returns the keys of the application's current
+// non-default settings that are absent from the given file, which are
+// reset with --merge-strategy merge-reset-missing. With the other
+// strategies, no settings are reset: the controller leaves settings
+// absent from the file unchanged.
+func (c *configCommand) missingFileSettings(client configCommandAPI, b []byte) ([]string, error) {
+	if c.mergeStrategy != mergeStrategyResetMissing {
+		return nil, nil
+	}
+	_, fileSettings, err := c.parseSettingsYAML(b)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for k, v := range results.Config {
+		info, _ := v.(map[string]interface{})
+		if isDefault, _ := info["is_default"].(bool); isDefault {
+			continue
+		}
+		if _, ok := fileSettings[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// readSettingsFile reads the given settings file, or stdin if its path
+// is "-".
+func readSettingsFile(ctx *cmd.Context, file cmd.FileVar) ([]byte, error) {
+	if file.Path == "-" {
+		buf := bytes.Buffer{}
+		buf.ReadFrom(ctx.Stdin)
+		return buf.Bytes(), nil
+	}
+	return file.Read(ctx)
+}
+
+// setConfigFromYAMLBestEffort applies the application's settings from the
+// given YAML one key at a time, skipping those that are rejected. Both the
+// file format keyed by application name and the output of "juju config"
+// are accepted, as they are by the API server.
+func (c *configCommand) setConfigFromYAMLBestEffort(client configCommandAPI, ctx *cmd.Context, b []byte) error {
+	section, settings, err := c.parseSettingsYAML(b)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, fmt.Sprint(k))
+	}
+	return applyBestEffort(ctx, keys, func(keys []string) error {
+		subset := make(map[string]interface{})
+		for _, k := range keys {
+			subset[k] = settings[k]
+		}
+		data, err := yaml.Marshal(map[string]interface{}{section: subset})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		return redactError(client.Update(params.ApplicationUpdate{
+			ApplicationName: c.applicationName,
+			SettingsYAML:    string(data),
+			RedactInLogs:    c.redactInLogs,
+		}))
+	})
+}
+
+// parseSettingsYAML returns the application's settings from the given
+// YAML, and the name of the section they were found in. Both the file
+// format keyed by application name and the output of "juju config" are
+// accepted, as they are by the API server.
+func (c *configCommand) parseSettingsYAML(b []byte) (string, map[interface{}]interface{}, error) {
+	var all map[string]interface{}
+	if err := yaml.Unmarshal(b, &all); err != nil {
+		return "", nil, errors.Annotate(err, "cannot parse settings data")
+	}
+	section := c.applicationName
+	if _, ok := all[section]; !ok {
+		section = "settings"
+	}
+	settings, ok := all[section].(map[interface{}]interface{})
+	if !ok {
+		return "", nil, errors.Errorf("no settings found for %q", c.applicationName)
+	}
+	return section, settings, nil
+}
+
+// settingChangeCounts holds the number of settings in a file that would
+// change, be reset, are unknown to the charm or are unchanged.
+type settingChangeCounts struct {
+	changed, reset, unknown, unchanged int
+}
+
+// String returns the one-line summary printed by --count-changes.
+func (counts settingChangeCounts) String() string {
+	return fmt.Sprintf(
+		"%d to change, %d to reset, %d unknown, %d unchanged",
+		counts.changed, counts.reset, counts.unknown, counts.unchanged,
+	)
+}
+
+// countSettingChanges compares the settings from a file with the
+// application's described settings. As when the file is applied, an
+// empty or null value resets a setting to its default.
+func countSettingChanges(fileSettings map[interface{}]interface{}, current map[string]interface{}) settingChangeCounts {
+	var counts settingChangeCounts
+	for k, v := range fileSettings {
+		info, ok := current[fmt.Sprint(k)].(map[string]interface{})
+		if !ok {
+			counts.unknown++
+			continue
+		}
+		// The output of "juju config" describes each setting.
+		if described, ok := v.(map[interface{}]interface{}); ok {
+			v = described["value"]
+		}
+		if v == nil || v == "" {
+			if isDefault, _ := info["is_default"].(bool); isDefault {
+				counts.unchanged++
+			} else {
+				counts.reset++
+			}
+			continue
+		}
+		if fmt.Sprint(v) == fmt.Sprint(info["value"]) {
+			counts.unchanged++
+		} else {
+			counts.changed++
+		}
+	}
+	return counts
+}
+
+// countResets counts the settings with the given keys that would be
+// reset, or are unknown or already at their defaults. Settings in the
+// file are not counted again, as the file sets them after the reset.
+func countResets(keys []string, fileSettings map[interface{}]interface{}, current map[string]interface{}) settingChangeCounts {
+	var counts settingChangeCounts
+	counted := make(map[string]bool)
+	for k := range fileSettings {
+		counted[fmt.Sprint(k)] = true
+	}
+	for _, key := range keys {
+		if counted[key] {
+			continue
+		}
+		counted[key] = true
+		info, ok := current[key].(map[string]interface{})
+		if !ok {
+			counts.unknown++
+			continue
+		}
+		if isDefault, _ := info["is_default"].(bool); isDefault {
+			counts.unchanged++
+		} else {
+			counts.reset++
+		}
+	}
+	return counts
+}
+
+// countFileChanges prints a summary of the changes the settings in the
+// given file, and resetting the settings with the given keys, would
+// make, and with --confirm asks whether to go ahead.
+func (c *configCommand) countFileChanges(client configCommandAPI, ctx *cmd.Context, b []byte, resets []string) error {
+	_, settings, err := c.parseSettingsYAML(b)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	counts := countSettingChanges(settings, results.Config)
+	resetCounts := countResets(resets, settings, results.Config)
+	counts.reset += resetCounts.reset
+	counts.unknown += resetCounts.unknown
+	counts.unchanged += resetCounts.unchanged
+	fmt.Fprintln(ctx.Stdout, counts)
+	if !c.confirm {
+		return nil
+	}
+	fmt.Fprint(ctx.Stdout, "Apply these settings? (y/N): ")
+	if err := jujucmd.UserConfirmYes(ctx); err != nil {
+		return errors.Annotate(err, "applying settings")
+	}
+	return nil
+}
+
+// checkStrictFile checks the keys of the settings in the given file for
+// --strict.
+func (c *configCommand) checkStrictFile(client configCommandAPI, b []byte) error {
+	_, settings, err := c.parseSettingsYAML(b)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, fmt.Sprint(k))
+	}
+	return errors.Trace(checkStrictKeys(keys, results.Config))
+}
+
+// checkStrictKeys returns an error listing the given keys that are not
+// in the application's described settings, for --strict.
+func checkStrictKeys(keys []string, current map[string]interface{}) error {
+	var unknown []string
+	for _, k := range keys {
+		if _, ok := current[k].(map[string]interface{}); !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return errors.Errorf("no settings applied with --strict; unknown keys: %s", strings.Join(unknown, ", "))
+}
+
+// settingsDiff is the report written by --diff-against-file.
+type settingsDiff struct {
+	Differ     map[string]settingValuePair `yaml:"differ,omitempty" json:"differ,omitempty"`
+	OnlyInFile map[string]interface{}      `yaml:"only-in-file,omitempty" json:"only-in-file,omitempty"`
+	OnlyLive   map[string]interface{}      `yaml:"only-live,omitempty" json:"only-live,omitempty"`
+}
+
+// settingValuePair holds the value of a setting in a file and on the
+// application.
+type settingValuePair struct {
+	File interface{} `yaml:"file" json:"file"`
+	Live interface{} `yaml:"live" json:"live"`
+}
+
+// diffSettings compares the settings from a file with the application's
+// described settings. Settings at their charm default count as not set,
+// and, as when the file is applied, an empty or null value in the file
+// stands for the default.
+func diffSettings(fileSettings map[interface{}]interface{}, current map[string]interface{}) settingsDiff {
+	diff := settingsDiff{
+		Differ:     make(map[string]settingValuePair),
+		OnlyInFile: make(map[string]interface{}),
+		OnlyLive:   make(map[string]interface{}),
+	}
+	live := settingValues(changedSettings(current))
+	for k, v := range fileSettings {
+		key := fmt.Sprint(k)
+		// The output of "juju config" describes each setting.
+		if described, ok := v.(map[interface{}]interface{}); ok {
+			v = described["value"]
+		}
+		if v == "" {
+			v = nil
+		}
+		liveValue, set := live[key]
+		switch {
+		case !set && v == nil:
+		case !set:
+			info, _ := current[key].(map[string]interface{})
+			if info == nil || fmt.Sprint(v) != fmt.Sprint(info["value"]) {
+				diff.OnlyInFile[key] = v
+			}
+		case v == nil || fmt.Sprint(v) != fmt.Sprint(liveValue):
+			diff.Differ[key] = settingValuePair{File: v, Live: liveValue}
+		}
+	}
+	for k, v := range live {
+		if _, ok := fileSettings[k]; !ok {
+			diff.OnlyLive[k] = v
+		}
+	}
+	return diff
+}
+
+// diffConfig is the run action to compare the application's settings
+// with those in a file, without applying them.
+func (c *configCommand) diffConfig(client configCommandAPI, ctx *cmd.Context) error {
+	b, err := readSettingsFile(ctx, c.diffFile)
+	if err != nil {
+		return err
+	}
+	_, settings, err := c.parseSettingsYAML(b)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	diff := diffSettings(settings, results.Config)
+	if len(diff.Differ) == 0 && len(diff.OnlyInFile) == 0 && len(diff.OnlyLive) == 0 {
+		ctx.Infof("No differences")
+	}
+	return c.out.Write(ctx, diff)
+}
+
+// The actions of the settings in a --plan.
+const (
+	planActionSet     = "set"
+	planActionReset   = "reset"
+	planActionNoOp    = "no-op"
+	planActionUnknown = "unknown"
+)
+
+// configPlan is the document written by --plan.
+type configPlan struct {
+	Application string             `json:"application"`
+	Changes     []configPlanChange `json:"changes"`
+}
+
+// configPlanChange describes what --plan would do to a single setting.
+type configPlanChange struct {
+	Key    string      `json:"key"`
+	Action string      `json:"action"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+}
+
+// planSettingChange returns the change that setting the given key to
+// value would make to the application's described settings. A nil
+// value stands for resetting the key to its default.
+func planSettingChange(key string, value interface{}, current map[string]interface{}) configPlanChange {
+	change := configPlanChange{Key: key, New: value}
+	info, ok := current[key].(map[string]interface{})
+	if !ok {
+		change.Action = planActionUnknown
+		return change
+	}
+	change.Old = info["value"]
+	switch {
+	case value == nil:
+		change.New = info["default"]
+		if isDefault, _ := info["is_default"].(bool); isDefault {
+			change.Action = planActionNoOp
+		} else {
+			change.Action = planActionReset
+		}
+	case fmt.Sprint(value) == fmt.Sprint(change.Old):
+		change.Action = planActionNoOp
+	default:
+		change.Action = planActionSet
+	}
+	return change
+}
+
+// planConfig is the run action to print the plan of the changes that
+// setting and resetting the values would make, without applying them.
+func (c *configCommand) planConfig(client configCommandAPI, ctx *cmd.Context) error {
+	changes := make(map[string]interface{})
+	for _, k := range c.resetKeys {
+		changes[k] = nil
+	}
+	if c.useFile {
+		b, err := readSettingsFile(ctx, c.configFile)
+		if err != nil {
+			return err
+		}
+		_, settings, err := c.parseSettingsYAML(b)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for k, v := range settings {
+			// The output of "juju config" describes each setting.
+			if described, ok := v.(map[interface{}]interface{}); ok {
+				v = described["value"]
+			}
+			// As when the file is applied, an empty value resets
+			// the setting to its default.
+			if v == "" {
+				v = nil
+			}
+			changes[fmt.Sprint(k)] = v
+		}
+	} else {
+		settings, err := c.validateValues(ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if c.expandVars {
+			if err := c.expandModelVariables(settings); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		for k, v := range settings {
+			changes[k] = v
+		}
+	}
+
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	if c.useFile && c.mergeStrategy == mergeStrategyResetMissing {
+		// Settings absent from the file would be reset.
+		for k, v := range results.Config {
+			info, _ := v.(map[string]interface{})
+			if isDefault, _ := info["is_default"].(bool); isDefault {
+				continue
+			}
+			if _, ok := changes[k]; !ok {
+				changes[k] = nil
+			}
+		}
+	}
+	if c.strict {
+		keys := make([]string, 0, len(changes))
+		for k := range changes {
+			keys = append(keys, k)
+		}
+		if err := checkStrictKeys(keys, results.Config); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	keys := make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	plan := configPlan{
+		Application: c.applicationName,
+		Changes:     make([]configPlanChange, len(keys)),
+	}
+	for i, k := range keys {
+		plan.Changes[i] = planSettingChange(k, changes[k], results.Config)
+	}
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintf(ctx.Stdout, "%s\n", b)
+	return nil
+}
+
+// validTemplateName matches the names of config templates, which must
+// not refer to files outside the config templates directory.
+var validTemplateName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// configTemplatesDir returns the directory holding the config templates
+// that may be applied with --template.
+func configTemplatesDir() string {
+	return osenv.JujuXDGDataHomePath("config-templates")
+}
+
+// readConfigTemplate returns the settings of the named config template.
+// The template may hold a map of option names to values, the output of
+// "juju config", or the format accepted by --file.
+func (c *configCommand) readConfigTemplate(name string) (map[string]interface{}, error) {
+	path := filepath.Join(configTemplatesDir(), name+".yaml")
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFoundf("config template %q (%s)", name, path)
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "cannot read config template %q", name)
+	}
+	var all map[string]interface{}
+	if err := yaml.Unmarshal(b, &all); err != nil {
+		return nil, errors.Annotatef(err, "cannot parse config template %q", name)
+	}
+	for _, section := range []string{c.applicationName, "settings"} {
+		if sectionSettings, ok := all[section].(map[interface{}]interface{}); ok {
+			all = make(map[string]interface{})
+			for k, v := range sectionSettings {
+				all[fmt.Sprint(k)] = v
+			}
+			break
+		}
+	}
+	settings := make(map[string]interface{})
+	for k, v := range all {
+		// The output of "juju config" describes each setting.
+		if described, ok := v.(map[interface{}]interface{}); ok {
+			v = described["value"]
+		}
+		settings[k] = v
+	}
+	return settings, nil
+}
+
+// templateSettingValue checks the given value against the type of the
+// charm option described by info, as given by the application's
+// described settings, returning the value to apply. A string, such as a
+// key=value argument, is converted to the option's type. A nil or empty
+// value stands for resetting the option to its default, and is returned
+// as nil.
+func templateSettingValue(info map[string]interface{}, value interface{}) (interface{}, error) {
+	if value == nil || value == "" {
+		return nil, nil
+	}
+	optionType, _ := info["type"].(string)
+	s, isString := value.(string)
+	switch optionType {
+	case "int":
+		switch value.(type) {
+		case int, int64:
+			return value, nil
+		}
+		if isString {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+	case "float":
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		}
+		if isString {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, nil
+			}
+		}
+	case "boolean":
+		if _, ok := value.(bool); ok {
+			return value, nil
+		}
+		if isString {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b, nil
+			}
+		}
+	default:
+		if isString {
+			return value, nil
+		}
+	}
+	if optionType == "" {
+		optionType = "string"
+	}
+	return nil, errors.Errorf("expected %s, got %v", optionType, value)
+}
+
+// templateConfig is the run action to apply a config template, with the
+// values given as arguments overriding the template's. The merged
+// settings are checked against the charm's configuration schema and
+// reported before they are applied.
+func (c *configCommand) templateConfig(client configCommandAPI, ctx *cmd.Context) error {
+	settings, err := c.readConfigTemplate(c.template)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	return c.applyCheckedSettings(client, ctx, results, fmt.Sprintf("config template %q", c.template), settings)
+}
+
+// readBundleOptions returns the options of the application in the
+// bundle file given with --from-bundle, and the name of the charm the
+// bundle deploys the application with.
+func (c *configCommand) readBundleOptions(ctx *cmd.Context) (map[string]interface{}, string, error) {
+	path := ctx.AbsPath(c.bundleFile)
+	data, err := charmrepo.ReadBundleFile(path)
+	if err != nil {
+		return nil, "", errors.Annotatef(err, "cannot read bundle %q", c.bundleFile)
+	}
+	baseDir := filepath.Dir(path)
+	if err := processBundleIncludes(baseDir, data); err != nil {
+		return nil, "", errors.Annotatef(err, "cannot read bundle %q", c.bundleFile)
+	}
+	spec, ok := data.Applications[c.applicationName]
+	if !ok {
+		return nil, "", errors.NotFoundf("application %q in bundle %q", c.applicationName, c.bundleFile)
+	}
+	charmName, err := bundleCharmName(baseDir, spec.Charm)
+	if err != nil {
+		return nil, "", errors.Annotatef(err, "cannot read charm of application %q in bundle %q", c.applicationName, c.bundleFile)
+	}
+	options := make(map[string]interface{})
+	for k, v := range spec.Options {
+		options[k] = v
+	}
+	return options, charmName, nil
+}
+
+// bundleCharmName returns the name of the charm a bundle refers to,
+// either with a charm URL or with the path of a local charm, relative
+// to the bundle's directory.
+func bundleCharmName(baseDir, ref string) (string, error) {
+	if strings.HasPrefix(ref, ".") || filepath.IsAbs(ref) {
+		path := ref
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		ch, err := charm.ReadCharm(path)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		return ch.Meta().Name, nil
+	}
+	curl, err := charm.ParseURL(ref)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return curl.Name, nil
+}
+
+// bundleConfig is the run action to apply the options given to the
+// application in a bundle, with the values given as arguments
+// overriding the bundle's. The bundle must deploy the application with
+// the charm it has. As with templateConfig, the merged settings are
+// checked against the charm's configuration schema and reported before
+// they are applied.
+func (c *configCommand) bundleConfig(client configCommandAPI, ctx *cmd.Context) error {
+	settings, charmName, err := c.readBundleOptions(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	if charmName != results.Charm {
+		return errors.Errorf(
+			"cannot apply bundle %q; application %q uses charm %q, not %q as in the bundle",
+			c.bundleFile, c.applicationName, results.Charm, charmName,
+		)
+	}
+	return c.applyCheckedSettings(client, ctx, results, fmt.Sprintf("bundle %q", c.bundleFile), settings)
+}
+
+// applyCheckedSettings applies the given settings, from the given source,
+// overridden by the values given as arguments. Nothing is applied unless
+// every setting is one the application's charm defines, with a value of
+// the option's type. The settings are reported before they are applied.
+func (c *configCommand) applyCheckedSettings(
+	client configCommandAPI,
+	ctx *cmd.Context,
+	results *params.ApplicationGetResults,
+	source string,
+	settings map[string]interface{},
+) error {
+	overrides, err := c.validateValues(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if c.expandVars {
+		if err := c.expandModelVariables(overrides); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for k, v := range overrides {
+		settings[k] = v
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if c.strict {
+		if err := checkStrictKeys(keys, results.Config); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	var unknown, invalid []string
+	for _, k := range keys {
+		info, ok := results.Config[k].(map[string]interface{})
+		if !ok {
+			unknown = append(unknown, k)
+			continue
+		}
+		value, err := templateSettingValue(info, settings[k])
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", k, err))
+			continue
+		}
+		settings[k] = value
+	}
+	if len(unknown) > 0 || len(invalid) > 0 {
+		var problems []string
+		if len(unknown) > 0 {
+			problems = append(problems, "unknown keys: "+strings.Join(unknown, ", "))
+		}
+		if len(invalid) > 0 {
+			problems = append(problems, "invalid values: "+strings.Join(invalid, ", "))
+		}
+		return errors.Errorf("cannot apply %s; %s", source, strings.Join(problems, "; "))
+	}
+
+	ctx.Infof("Applying %s to %s:", source, c.applicationName)
+	for _, k := range keys {
+		switch {
+		case settings[k] == nil:
+			ctx.Infof("  %s: (default)", k)
+		case c.redactInLogs:
+			ctx.Infof("  %s: (redacted)", k)
+		default:
+			ctx.Infof("  %s: %v", k, settings[k])
+		}
+	}
+	data, err := yaml.Marshal(map[string]interface{}{c.applicationName: settings})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return block.ProcessBlockedError(
+		redactError(client.Update(params.ApplicationUpdate{
+			ApplicationName: c.applicationName,
+			SettingsYAML:    string(data),
+			RedactInLogs:    c.redactInLogs,
+		})), block.BlockChange)
+}
+
+// getConfig is the run action to return one or all configuration values.
+func (c *configCommand) getConfig(client configCommandAPI, ctx *cmd.Context) error {
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	if len(c.keys) == 1 {
+		key := c.keys[0]
+		info, found := results.Config[key].(map[string]interface{})
+		if !found {
+			return errors.Errorf("key %q not found in %q application settings.", key, c.applicationName)
+		}
+		out := &bytes.Buffer{}
+		err := cmd.FormatYaml(out, info["value"])
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(ctx.Stdout, out.String())
+		return nil
+	}
+
+	settings := results.Config
+	if c.onlyChanged {
+		settings = changedSettings(settings)
+	}
+	if c.since != "" {
+		changed, err := client.ConfigChangeTimes(c.applicationName)
+		if errors.IsNotSupported(err) {
+			return errors.New("--since is not supported by this controller, which does not record when settings change")
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+		settings = settingsChangedSince(settings, changed, c.sinceTime)
+	}
+	resultsMap := map[string]interface{}{
+		"application": results.Application,
+		"charm":       results.Charm,
+		"settings":    settings,
+	}
+	if results.ConfigLocked {
+		resultsMap["config-locked"] = true
+	}
+	return c.out.Write(ctx, resultsMap)
+}
+
+// getConfigFiles is the run action to write the values of the keys
+// given with --get-file to their files.
+func (c *configCommand) getConfigFiles(client configCommandAPI, ctx *cmd.Context) error {
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(c.getFilePaths))
+	for key := range c.getFilePaths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// Check every key before writing any file, so that a mistyped
+	// key does not leave some files written.
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		info, found := results.Config[key].(map[string]interface{})
+		if !found {
+			return errors.Errorf("key %q not found in %q application settings.", key, c.applicationName)
+		}
+		value, ok := info["value"]
+		if !ok || value == nil {
+			return errors.Errorf("key %q has no value in %q application settings.", key, c.applicationName)
+		}
+		values[key] = rawSettingValue(value)
+	}
+	for _, key := range keys {
+		path := ctx.AbsPath(c.getFilePaths[key])
+		if err := ioutil.WriteFile(path, values[key], 0600); err != nil {
+			return errors.Annotatef(err, "writing value of %q", key)
+		}
+		// WriteFile only sets the permissions of new files.
+		if err := os.Chmod(path, 0600); err != nil {
+			return errors.Annotatef(err, "writing value of %q", key)
+		}
+		ctx.Infof("Wrote %q to %s (%d bytes).", key, path, len(values[key]))
+	}
+	return nil
+}
+
+// rawSettingValue returns a setting value as it would be given on the
+// command line: strings as they are, and other values as printed by fmt.
+// Numbers may arrive as floats over the API, and are written without an
+// exponent.
+func rawSettingValue(value interface{}) []byte {
+	switch value := value.(type) {
+	case string:
+		return []byte(value)
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return []byte(fmt.Sprint(value))
+}
+
+// settingExplanation describes how an application interprets one of
+// its settings, as reported with --explain.
+type settingExplanation struct {
+	Key         string      `yaml:"key" json:"key"`
+	Type        string      `yaml:"type" json:"type"`
+	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
+	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+	Value       interface{} `yaml:"value,omitempty" json:"value,omitempty"`
+	Source      string      `yaml:"source" json:"source"`
+}
+
+const (
+	// settingSourceDefault and settingSourceUser are the sources
+	// reported by --explain for a setting's current value.
+	settingSourceDefault = "charm default"
+	settingSourceUser    = "user-set"
+)
+
+// explainConfig is the run action to describe a single configuration
+// key in detail.
+func (c *configCommand) explainConfig(client configCommandAPI, ctx *cmd.Context) error {
+	results, err := client.Get(c.applicationName)
+	if err != nil {
+		return err
+	}
+	info, found := results.Config[c.explainKey].(map[string]interface{})
+	if !found {
+		return errors.Errorf("key %q not found in %q application settings.", c.explainKey, c.applicationName)
+	}
+	explanation := settingExplanation{
+		Key:     c.explainKey,
+		Default: info["default"],
+		Value:   info["value"],
+		Source:  settingSourceUser,
+	}
+	explanation.Type, _ = info["type"].(string)
+	explanation.Description, _ = info["description"].(string)
+	if isDefault, _ := info["is_default"].(bool); isDefault {
+		explanation.Source = settingSourceDefault
+		// Older controllers do not report defaults, but a
+		// setting at its default shows it anyway.
+		if explanation.Default == nil {
+			explanation.Default = explanation.Value
+		}
+	}
+	return c.out.Write(ctx, explanation)
+}
+
+// watchConfig is the run action to print changes to one or all
+// configuration values as they occur, until interrupted.
+func (c *configCommand) watchConfig(client configCommandAPI, ctx *cmd.Context) error {
+	w, err := client.WatchConfig(c.applicationName)
+	if errors.IsNotSupported(err) {
+		return errors.New("this juju controller does not support watching application settings")
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer worker.Stop(w)
+
+	interrupted := make(chan os.Signal, 1)
+	ctx.InterruptNotify(interrupted)
+	defer ctx.StopInterruptNotify(interrupted)
+
+	ctx.Infof("Watching settings for %q; press Ctrl-C to stop.", c.applicationName)
+	var current map[string]interface{}
+	for {
+		select {
+		case <-interrupted:
+			return nil
+		case _, ok := <-w.Changes():
+			if !ok {
+				return errors.Trace(w.Wait())
+			}
+			results, err := client.Get(c.applicationName)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			values := settingValues(results.Config)
+			if len(c.keys) == 1 {
+				key := c.keys[0]
+				if _, ok := results.Config[key]; !ok {
+					return errors.Errorf("key %q not found in %q application settings.", key, c.applicationName)
+				}
+				values = map[string]interface{}{key: values[key]}
+			}
+			// The first event reports the initial settings,
+			// against which later changes are compared.
+			if current != nil {
+				writeSettingChanges(ctx, current, values)
+			}
+			current = values
+		}
+	}
+}
+
+// settingValues returns the values of the described settings, omitting
+// those without a value.
+func settingValues(settings map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	for k, v := range settings {
+		info, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := info["value"]; ok {
+			values[k] = value
+		}
+	}
+	return values
+}
+
+// writeSettingChanges writes a line, sorted by key, for each setting
+// whose value differs between old and new.
+func writeSettingChanges(ctx *cmd.Context, old, new map[string]interface{}) {
+	for _, k := range changedSettingKeys(old, new) {
+		fmt.Fprintf(ctx.Stdout, "%s: %s -> %s\n", k, formatSettingValue(old, k), formatSettingValue(new, k))
+	}
+}
+
+// changedSettingKeys returns the sorted keys of the settings whose value
+// differs between old and new.
+func changedSettingKeys(old, new map[string]interface{}) []string {
+	keys := set.NewStrings()
+	for k := range old {
+		keys.Add(k)
+	}
+	for k := range new {
+		keys.Add(k)
+	}
+	var changed []string
+	for _, k := range keys.SortedValues() {
+		if formatSettingValue(old, k) != formatSettingValue(new, k) {
+			changed = append(changed, k)
+		}
+	}
+	return changed
+}
+
+// formatSettingValue formats the value of the named setting for
+// printing on a single line.
+func formatSettingValue(values map[string]interface{}, key string) string {
+	value, ok := values[key]
+	if !ok || value == nil {
+		return "(unset)"
+	}
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(value)
+}
+
+// changedSettings returns the subset of the described settings whose current
+// value differs from the charm default. The API marks settings that match
+// their default with "is_default".
+func changedSettings(settings map[string]interface{}) map[string]interface{} {
+	changed := make(map[string]interface{})
+	for k, v := range settings {
+		info, ok := v.(map[string]interface{})
+		if ok {
+			if isDefault, _ := info["is_default"].(bool); isDefault {
+				continue
+			}
+		}
+		changed[k] = v
+	}
+	return changed
+}
+
+// settingsChangedSince returns the settings last changed after the given
+// time, adding the time of the change to each.
+func settingsChangedSince(settings map[string]interface{}, changed map[string]time.Time, since time.Time) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range settings {
+		t, ok := changed[k]
+		if !ok || !t.After(since) {
+			continue
+		}
+		info := make(map[string]interface{})
+		if v, ok := v.(map[string]interface{}); ok {
+			for ik, iv := range v {
+				info[ik] = iv
+			}
+		}
+		info["changed"] = t.UTC().Format(time.RFC3339)
+		result[k] = info
+	}
+	return result
 }
 
 // validateValues reads the values provided as args and validates that they are
@@ -382,6 +2328,63 @@ func (c *configCommand) validateValues(ctx *cmd.Context) (map[string]string, err
 	return settings, nil
 }
 
+// modelVariablePattern matches the model variables that may be expanded
+// in values with --expand-vars.
+var modelVariablePattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// modelVariables returns the values of the variables that may be
+// expanded in values with --expand-vars.
+func (c *configCommand) modelVariables() (map[string]string, error) {
+	controllerName, err := c.ControllerName()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	modelName, details, err := c.ModelDetails()
+	if err != nil {
+		return nil, errors.Annotate(err, "getting model details")
+	}
+	if jujuclient.IsQualifiedModelName(modelName) {
+		modelName, _, err = jujuclient.SplitModelName(modelName)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return map[string]string{
+		"controller-name": controllerName,
+		"model-name":      modelName,
+		"model-uuid":      details.ModelUUID,
+	}, nil
+}
+
+// expandModelVariables replaces the model variables in the given
+// settings. Unknown variables are an error, rather than being replaced
+// with nothing.
+func (c *configCommand) expandModelVariables(settings map[string]string) error {
+	vars, err := c.modelVariables()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for k, v := range settings {
+		for _, match := range modelVariablePattern.FindAllStringSubmatch(v, -1) {
+			if _, ok := vars[match[1]]; !ok {
+				known := make([]string, 0, len(vars))
+				for name := range vars {
+					known = append(known, "${"+name+"}")
+				}
+				sort.Strings(known)
+				return errors.Errorf(
+					"unknown variable %q in value for option %q; expected one of %s",
+					match[0], k, strings.Join(known, ", "),
+				)
+			}
+		}
+		settings[k] = modelVariablePattern.ReplaceAllStringFunc(v, func(match string) string {
+			return vars[match[2:len(match)-1]]
+		})
+	}
+	return nil
+}
+
 // readValue reads the value of an option out of the named file.
 // An empty content is valid, like in parsing the options. The upper
 // size is 5M.
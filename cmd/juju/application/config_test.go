@@ -4,9 +4,13 @@ package application_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/juju/cmd"
@@ -14,11 +18,15 @@ import (
 	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
+	"github.com/juju/utils/clock"
 	gc "gopkg.in/check.v1"
 	goyaml "gopkg.in/yaml.v2"
 
 	"github.com/juju/juju/apiserver/common"
+	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/cmd/juju/application"
+	"github.com/juju/juju/juju/osenv"
+	"github.com/juju/juju/jujuclient"
 	coretesting "github.com/juju/juju/testing"
 )
 
@@ -131,6 +139,27 @@ func (s *configCommandSuite) TestGetConfig(c *gc.C) {
 	}
 }
 
+func (s *configCommandSuite) TestGetConfigSortedByKey(c *gc.C) {
+	for _, format := range []string{"yaml", "json"} {
+		var outputs []string
+		for i := 0; i < 5; i++ {
+			ctx := cmdtesting.Context(c)
+			code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{"dummy-application", "--format", format})
+			c.Assert(code, gc.Equals, 0)
+			outputs = append(outputs, ctx.Stdout.(*bytes.Buffer).String())
+		}
+		for _, output := range outputs[1:] {
+			c.Assert(output, gc.Equals, outputs[0], gc.Commentf("format %s", format))
+		}
+		last := -1
+		for _, key := range []string{"outlook", "skill-level", "title", "username"} {
+			i := strings.Index(outputs[0], key)
+			c.Assert(i > last, jc.IsTrue, gc.Commentf("format %s: %q out of order in\n%s", format, key, outputs[0]))
+			last = i
+		}
+	}
+}
+
 func (s *configCommandSuite) TestGetConfigKey(c *gc.C) {
 	ctx := cmdtesting.Context(c)
 	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{"dummy-application", "title"})
@@ -139,11 +168,197 @@ func (s *configCommandSuite) TestGetConfigKey(c *gc.C) {
 	c.Assert(ctx.Stdout.(*bytes.Buffer).String(), gc.Equals, "Nearly There\n")
 }
 
+func (s *configCommandSuite) TestGetConfigOnlyChanged(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{
+		"title":    "Nearly There",
+		"username": "admin",
+		"outlook":  "true",
+	}
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{"dummy-application", "--only-changed"})
+	c.Check(code, gc.Equals, 0)
+	c.Assert(ctx.Stderr.(*bytes.Buffer).String(), gc.Equals, "")
+
+	actual := make(map[string]interface{})
+	err := goyaml.Unmarshal(ctx.Stdout.(*bytes.Buffer).Bytes(), &actual)
+	c.Assert(err, jc.ErrorIsNil)
+	settings, ok := actual["settings"].(map[interface{}]interface{})
+	c.Assert(ok, jc.IsTrue)
+	var keys []string
+	for k := range settings {
+		keys = append(keys, k.(string))
+	}
+	c.Assert(keys, jc.SameContents, []string{"skill-level", "username"})
+}
+
+func (s *configCommandSuite) TestGetConfigSince(c *gc.C) {
+	since := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.fake.changed = map[string]time.Time{
+		"title":    since.Add(-time.Hour),
+		"username": since.Add(time.Minute),
+	}
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{"dummy-application", "--since", "2017-06-01T12:00:00Z"})
+	c.Check(code, gc.Equals, 0)
+	c.Assert(ctx.Stderr.(*bytes.Buffer).String(), gc.Equals, "")
+
+	actual := make(map[string]interface{})
+	err := goyaml.Unmarshal(ctx.Stdout.(*bytes.Buffer).Bytes(), &actual)
+	c.Assert(err, jc.ErrorIsNil)
+	settings, ok := actual["settings"].(map[interface{}]interface{})
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(settings, gc.HasLen, 1)
+	username, ok := settings["username"].(map[interface{}]interface{})
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(username["value"], gc.Equals, "admin001")
+	c.Assert(username["changed"], gc.Equals, "2017-06-01T12:01:00Z")
+}
+
+func (s *configCommandSuite) TestGetConfigSinceNotSupported(c *gc.C) {
+	s.fake.changedErr = errors.NotSupportedf("recording when application settings change")
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--since", "2017-06-01T12:00:00Z")
+	c.Assert(err, gc.ErrorMatches, "--since is not supported by this controller, which does not record when settings change")
+}
+
+func (s *configCommandSuite) TestGetConfigSinceInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--since", "yesterday"},
+		err:  `--since: "yesterday" is not a valid time, expected one such as 2017-06-01T12:00:00Z`,
+	}, {
+		args: []string{"app", "--since", "2017-06-01T12:00:00Z", "username"},
+		err:  "--since can only be used when getting all values",
+	}, {
+		args: []string{"app", "--since", "2017-06-01T12:00:00Z", "username=hello"},
+		err:  "--since can only be used when getting all values",
+	}, {
+		args: []string{"app", "--since", "2017-06-01T12:00:00Z", "--watch"},
+		err:  "--since can only be used when getting all values",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
 func (s *configCommandSuite) TestGetConfigKeyNotFound(c *gc.C) {
 	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "invalid")
 	c.Assert(err, gc.ErrorMatches, `key "invalid" not found in "dummy-application" application settings.`, gc.Commentf("details: %v", errors.Details(err)))
 }
 
+func (s *configCommandSuite) TestExplainUserSet(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{
+		"username": "admin",
+	}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--explain", "username")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, `
+key: username
+type: string
+description: Specifies username
+default: admin
+value: admin001
+source: user-set
+`[1:])
+}
+
+func (s *configCommandSuite) TestExplainDefault(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{
+		"skill-level": 100,
+	}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--explain", "skill-level", "--format", "json")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals,
+		`{"key":"skill-level","type":"int","description":"Specifies skill-level","default":100,"value":100,"source":"charm default"}`+"\n")
+}
+
+func (s *configCommandSuite) TestExplainKeyNotFound(c *gc.C) {
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--explain", "invalid")
+	c.Assert(err, gc.ErrorMatches, `key "invalid" not found in "dummy-application" application settings.`)
+}
+
+func (s *configCommandSuite) TestGetFiles(c *gc.C) {
+	big := strings.Repeat("0123456789abcdef\n", 1024*1024)
+	s.fake.values["title"] = big
+	s.fake.values["username"] = validSetTestValue
+	// An existing file is overwritten, and made private.
+	err := ioutil.WriteFile(filepath.Join(s.dir, "username.txt"), []byte("old value that is longer"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	ctx, err := cmdtesting.RunCommandInDir(c, application.NewConfigCommandForTest(s.fake), []string{
+		"dummy-application",
+		"--get-file", "title=title.txt",
+		"--get-file", "username=username.txt",
+		"--get-file", "skill-level=skill-level.txt",
+	}, s.dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "")
+
+	for path, expect := range map[string]string{
+		"title.txt":       big,
+		"username.txt":    validSetTestValue,
+		"skill-level.txt": "100",
+	} {
+		path = filepath.Join(s.dir, path)
+		data, err := ioutil.ReadFile(path)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(string(data), gc.Equals, expect, gc.Commentf("%s", path))
+		info, err := os.Stat(path)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(info.Mode().Perm(), gc.Equals, os.FileMode(0600), gc.Commentf("%s", path))
+	}
+}
+
+func (s *configCommandSuite) TestGetFilesKeyNotFound(c *gc.C) {
+	_, err := cmdtesting.RunCommandInDir(c, application.NewConfigCommandForTest(s.fake), []string{
+		"dummy-application",
+		"--get-file", "title=title.txt",
+		"--get-file", "invalid=invalid-key.txt",
+	}, s.dir)
+	c.Assert(err, gc.ErrorMatches, `key "invalid" not found in "dummy-application" application settings.`)
+	// No file is written if any key is not found.
+	_, err = os.Stat(filepath.Join(s.dir, "title.txt"))
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
+
+func (s *configCommandSuite) TestLockConfig(c *gc.C) {
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--lock")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Locked settings of application \"dummy-application\"\n")
+	c.Assert(s.fake.locked, jc.IsTrue)
+
+	// Changes are refused while the settings are locked.
+	_, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "username=hello")
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "dummy-application": config locked`)
+	_, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--reset", "username")
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "dummy-application": config locked`)
+	c.Assert(s.fake.values["username"], gc.Equals, "admin001")
+
+	// Getting the settings still works, and reports the lock.
+	ctx, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application")
+	c.Assert(err, jc.ErrorIsNil)
+	var output map[string]interface{}
+	err = goyaml.Unmarshal(ctx.Stdout.(*bytes.Buffer).Bytes(), &output)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(output["config-locked"], jc.IsTrue)
+
+	ctx, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--unlock")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Unlocked settings of application \"dummy-application\"\n")
+	c.Assert(s.fake.locked, jc.IsFalse)
+	_, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "username=hello")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+}
+
+func (s *configCommandSuite) TestLockConfigNotSupported(c *gc.C) {
+	s.fake.lockErr = errors.NotSupportedf("locking application config")
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--lock")
+	c.Assert(err, gc.ErrorMatches, "cannot lock or unlock settings: the controller does not support locking application settings")
+}
+
 var setCommandInitErrorTests = []struct {
 	about       string
 	args        []string
@@ -179,6 +394,126 @@ var setCommandInitErrorTests = []struct {
 	about:       "invalid reset keys",
 	args:        []string{"application", "--reset", "reset,bad=key"},
 	expectError: `--reset accepts a comma delimited set of keys "a,b,c", received: "bad=key"`,
+}, {
+	about:       "--backup when getting values",
+	args:        []string{"application", "--backup", "backup.yaml"},
+	expectError: "--backup can only be used when setting or resetting values",
+}, {
+	about:       "--prune-unknown with set",
+	args:        []string{"application", "--prune-unknown", "key=value"},
+	expectError: "--prune-unknown cannot be combined with getting, setting or resetting values",
+}, {
+	about:       "--prune-unknown with get",
+	args:        []string{"application", "--prune-unknown", "key"},
+	expectError: "--prune-unknown cannot be combined with getting, setting or resetting values",
+}, {
+	about:       "--force without --prune-unknown",
+	args:        []string{"application", "--force", "key=value"},
+	expectError: "--force can only be used with --prune-unknown",
+}, {
+	about:       "--ignore-errors when getting values",
+	args:        []string{"application", "--ignore-errors"},
+	expectError: "--ignore-errors can only be used when setting values",
+}, {
+	about:       "--ignore-errors when resetting values",
+	args:        []string{"application", "--ignore-errors", "--reset", "key"},
+	expectError: "--ignore-errors can only be used when setting values",
+}, {
+	about:       "--key-file and --file specified",
+	args:        []string{"application", "--file", "testconfig.yaml", "--key-file", "key=valid.txt"},
+	expectError: "cannot specify --file and --key-file simultaneously",
+}, {
+	about:       "--key-file when getting a value",
+	args:        []string{"application", "--key-file", "key=valid.txt", "get"},
+	expectError: "cannot set and retrieve values simultaneously",
+}, {
+	about:       "--key-file without a path",
+	args:        []string{"application", "--key-file", "key"},
+	expectError: `invalid --key-file: expected "key=value", got "key"`,
+}, {
+	about:       "--key-file key repeated",
+	args:        []string{"application", "--key-file", "key=valid.txt", "--key-file", "key=other.txt"},
+	expectError: `invalid --key-file: key "key" specified more than once`,
+}, {
+	about:       "--key-file key also set inline",
+	args:        []string{"application", "--key-file", "key=valid.txt", "key=value"},
+	expectError: `key "key" specified both as an argument and with --key-file`,
+}, {
+	about:       "--get-file when setting values",
+	args:        []string{"application", "--get-file", "key=out.txt", "key=value"},
+	expectError: "--get-file cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--get-file when getting a value",
+	args:        []string{"application", "--get-file", "key=out.txt", "other"},
+	expectError: "--get-file cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--get-file with --watch",
+	args:        []string{"application", "--get-file", "key=out.txt", "--watch"},
+	expectError: "--get-file cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--get-file without a path",
+	args:        []string{"application", "--get-file", "key"},
+	expectError: `invalid --get-file: expected "key=value", got "key"`,
+}, {
+	about:       "--get-file path repeated",
+	args:        []string{"application", "--get-file", "key=out.txt", "--get-file", "other=out.txt"},
+	expectError: `invalid --get-file: path "out.txt" given for more than one key`,
+}, {
+	about:       "--lock with --unlock",
+	args:        []string{"application", "--lock", "--unlock"},
+	expectError: "--lock and --unlock cannot be combined",
+}, {
+	about:       "--lock when setting values",
+	args:        []string{"application", "--lock", "key=value"},
+	expectError: "--lock and --unlock cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--unlock when resetting values",
+	args:        []string{"application", "--unlock", "--reset", "key"},
+	expectError: "--lock and --unlock cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--lock when getting a value",
+	args:        []string{"application", "--lock", "key"},
+	expectError: "--lock and --unlock cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--watch when setting values",
+	args:        []string{"application", "--watch", "key=value"},
+	expectError: "--watch can only be used when getting values",
+}, {
+	about:       "--watch when resetting values",
+	args:        []string{"application", "--watch", "--reset", "key"},
+	expectError: "--watch can only be used when getting values",
+}, {
+	about:       "--watch with --prune-unknown",
+	args:        []string{"application", "--watch", "--prune-unknown"},
+	expectError: "--watch can only be used when getting values",
+}, {
+	about:       "--watch with --only-changed",
+	args:        []string{"application", "--watch", "--only-changed"},
+	expectError: "--watch cannot be combined with --only-changed",
+}, {
+	about:       "--explain when setting values",
+	args:        []string{"application", "--explain", "key", "key=value"},
+	expectError: "--explain cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--explain when getting a value",
+	args:        []string{"application", "--explain", "key", "key"},
+	expectError: "--explain cannot be combined with getting, setting, resetting or watching values",
+}, {
+	about:       "--redact-in-logs when getting values",
+	args:        []string{"application", "--redact-in-logs"},
+	expectError: "--redact-in-logs can only be used when setting values",
+}, {
+	about:       "--redact-in-logs when resetting values",
+	args:        []string{"application", "--redact-in-logs", "--reset", "key"},
+	expectError: "--redact-in-logs can only be used when setting values",
+
+	about:       "--max-wait when getting values",
+	args:        []string{"application", "--max-wait", "1m"},
+	expectError: "--max-wait can only be used when setting or resetting values",
+}, {
+	about:       "--max-wait negative",
+	args:        []string{"application", "--max-wait", "-1m", "key=value"},
+	expectError: "--max-wait must not be negative",
 }, {
 	about:       "init too many args fails",
 	args:        []string{"application", "key", "another"},
@@ -285,6 +620,138 @@ func (s *configCommandSuite) TestSetFromStdin(c *gc.C) {
 	c.Check(s.fake.config, jc.DeepEquals, "settings:\n  username:\n  value: world\n")
 }
 
+func (s *configCommandSuite) TestSetKeyFiles(c *gc.C) {
+	setupValueFile(c, s.dir, "title.txt", "From a file")
+	s.assertSetSuccess(c, s.dir, []string{
+		"--key-file", "username=valid.txt",
+		"--key-file", "title=title.txt",
+		"outlook=false",
+	}, nil)
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       "From a file",
+		"skill-level": 100,
+		"username":    validSetTestValue,
+		"outlook":     "false",
+	})
+}
+
+func (s *configCommandSuite) TestSetKeyFilesFail(c *gc.C) {
+	s.assertSetFail(c, s.dir, []string{
+		"--key-file", "username=missing.txt",
+	}, "cannot read option from file \"missing.txt\": .* "+utils.NoSuchFileErrRegexp)
+	s.assertSetFail(c, s.dir, []string{
+		"--key-file", "username=big.txt",
+	}, "size of option file is larger than 5M")
+	s.assertSetFail(c, s.dir, []string{
+		"--key-file", "title=valid.txt",
+		"--key-file", "username=invalid.txt",
+	}, "value for option \"username\" contains non-UTF-8 sequences")
+	// Nothing is set if any file cannot be used.
+	c.Assert(s.fake.values["title"], gc.Equals, "Nearly There")
+}
+
+func (s *configCommandSuite) runExpandVars(c *gc.C, args ...string) error {
+	store := application.NewMockStore()
+	store.Models["foo"].Models["admin/bar"] = jujuclient.ModelDetails{
+		ModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+	}
+	cmd := application.NewConfigCommandForTest(s.fake)
+	cmd.SetClientStore(store)
+	args = append([]string{"dummy-application", "--expand-vars"}, args...)
+	_, err := cmdtesting.RunCommandInDir(c, cmd, args, s.dir)
+	return err
+}
+
+func (s *configCommandSuite) TestSetExpandVars(c *gc.C) {
+	err := s.runExpandVars(c,
+		"title=https://${model-name}.internal",
+		"username=${controller-name}/${model-uuid}",
+		"outlook=$HOME",
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       "https://bar.internal",
+		"skill-level": 100,
+		"username":    "foo/deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		"outlook":     "$HOME",
+	})
+}
+
+func (s *configCommandSuite) TestSetExpandVarsUnknown(c *gc.C) {
+	err := s.runExpandVars(c, "title=${model-name}", "username=${model-owner}")
+	c.Assert(err, gc.ErrorMatches, `unknown variable "\$\{model-owner\}" in value for option "username"; `+
+		`expected one of \$\{controller-name\}, \$\{model-name\}, \$\{model-uuid\}`)
+	c.Assert(s.fake.values["title"], gc.Equals, "Nearly There")
+}
+
+func (s *configCommandSuite) TestSetWithoutExpandVars(c *gc.C) {
+	s.assertSetSuccess(c, s.dir, []string{"title=${model-name}"}, nil)
+	c.Assert(s.fake.values["title"], gc.Equals, "${model-name}")
+}
+
+func (s *configCommandSuite) TestExpandVarsInit(c *gc.C) {
+	err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), []string{"app", "--expand-vars"})
+	c.Assert(err, gc.ErrorMatches, "--expand-vars can only be used when setting values as key=value arguments")
+	err = cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), []string{"app", "--expand-vars", "--file", "config.yaml"})
+	c.Assert(err, gc.ErrorMatches, "--expand-vars can only be used when setting values as key=value arguments")
+}
+
+func (s *configCommandSuite) TestRevertLast(c *gc.C) {
+	s.fake.previous = map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 100,
+		"username":    "hello",
+	}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--revert-last")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, ""+
+		"outlook: \"true\" -> (unset)\n"+
+		"username: \"admin001\" -> \"hello\"\n",
+	)
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 100,
+		"username":    "hello",
+	})
+}
+
+func (s *configCommandSuite) TestRevertLastNothingToRevert(c *gc.C) {
+	s.fake.previous = map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 100,
+		"username":    "admin001",
+		"outlook":     "true",
+	}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--revert-last")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "No settings to revert\n")
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestRevertLastNoHistory(c *gc.C) {
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--revert-last")
+	c.Assert(err, gc.ErrorMatches, `no previous settings for application "dummy-application" to revert to`)
+
+	s.fake.previousErr = errors.NotSupportedf("getting previous application config")
+	_, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--revert-last")
+	c.Assert(err, gc.ErrorMatches, "cannot revert settings: the controller does not retain previous application settings")
+}
+
+func (s *configCommandSuite) TestRevertLastInit(c *gc.C) {
+	for _, args := range [][]string{
+		{"app", "--revert-last", "username=hello"},
+		{"app", "--revert-last", "username"},
+		{"app", "--revert-last", "--reset", "username"},
+		{"app", "--revert-last", "--file", "config.yaml"},
+	} {
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), args)
+		c.Check(err, gc.ErrorMatches, "--revert-last cannot be combined with getting, setting or resetting values")
+	}
+	err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), []string{"app", "--revert-last", "--watch"})
+	c.Assert(err, gc.ErrorMatches, "--watch can only be used when getting values")
+}
+
 func (s *configCommandSuite) TestResetConfigToDefault(c *gc.C) {
 	s.fake = &fakeApplicationAPI{name: "dummy-application", values: map[string]interface{}{
 		"username": "hello",
@@ -295,6 +762,1074 @@ func (s *configCommandSuite) TestResetConfigToDefault(c *gc.C) {
 	}, make(map[string]interface{}))
 }
 
+func (s *configCommandSuite) TestSetConfigBackup(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{
+		"title":   "Nearly There",
+		"outlook": "true",
+	}
+	s.assertSetSuccess(c, s.dir, []string{
+		"--backup", "backup.yaml",
+		"username=hello",
+	}, nil)
+
+	// The backup holds the non-default settings from before the change.
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, "backup.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	var backup map[string]map[string]interface{}
+	err = goyaml.Unmarshal(content, &backup)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(backup, jc.DeepEquals, map[string]map[string]interface{}{
+		"dummy-application": {
+			"skill-level": 100,
+			"username":    "admin001",
+		},
+	})
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+
+	info, err := os.Stat(filepath.Join(s.dir, "backup.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+}
+
+func (s *configCommandSuite) TestSetConfigBackupReplacesFile(c *gc.C) {
+	path := filepath.Join(s.dir, "backup.yaml")
+	err := ioutil.WriteFile(path, []byte("old"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertSetSuccess(c, s.dir, []string{
+		"--backup", "backup.yaml",
+		"username=hello",
+	}, nil)
+
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(content), jc.Contains, "dummy-application:")
+	info, err := os.Stat(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+}
+
+func (s *configCommandSuite) TestSetConfigBackupKeptOnFailure(c *gc.C) {
+	s.fake.err = errors.New("boom")
+	s.assertSetFail(c, s.dir, []string{
+		"--backup", "backup.yaml",
+		"username=hello",
+	}, "boom")
+
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, "backup.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(content), jc.Contains, "dummy-application:")
+}
+
+func (s *configCommandSuite) runAuditLog(c *gc.C, args ...string) error {
+	store := application.NewMockStore()
+	store.Accounts["foo"] = jujuclient.AccountDetails{User: "bob"}
+	cmd := application.NewConfigCommandForTest(s.fake)
+	cmd.SetClientStore(store)
+	args = append([]string{"dummy-application", "--audit-log", "audit.log"}, args...)
+	_, err := cmdtesting.RunCommandInDir(c, cmd, args, s.dir)
+	return err
+}
+
+func (s *configCommandSuite) readAuditLog(c *gc.C) []map[string]interface{} {
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, "audit.log"))
+	c.Assert(err, jc.ErrorIsNil)
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		var entry map[string]interface{}
+		c.Assert(json.Unmarshal([]byte(line), &entry), jc.ErrorIsNil)
+		c.Assert(entry["timestamp"], gc.Not(gc.Equals), "")
+		delete(entry, "timestamp")
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *configCommandSuite) TestSetAuditLog(c *gc.C) {
+	err := s.runAuditLog(c, "username=hello", "title=Nearly There")
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.runAuditLog(c, "--reset", "outlook")
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Each change is appended; unchanged settings are not recorded.
+	c.Assert(s.readAuditLog(c), jc.DeepEquals, []map[string]interface{}{{
+		"user":        "bob",
+		"application": "dummy-application",
+		"changes": map[string]interface{}{
+			"username": map[string]interface{}{"old": "admin001", "new": "hello"},
+		},
+	}, {
+		"user":        "bob",
+		"application": "dummy-application",
+		"changes": map[string]interface{}{
+			"outlook": map[string]interface{}{"old": "true", "new": nil},
+		},
+	}})
+	info, err := os.Stat(filepath.Join(s.dir, "audit.log"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+}
+
+func (s *configCommandSuite) TestSetAuditLogNoChanges(c *gc.C) {
+	err := s.runAuditLog(c, "username=admin001")
+	c.Assert(err, jc.ErrorIsNil)
+	s.fake.err = errors.New("boom")
+	err = s.runAuditLog(c, "username=hello")
+	c.Assert(err, gc.ErrorMatches, "boom")
+
+	_, err = os.Stat(filepath.Join(s.dir, "audit.log"))
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
+
+func (s *configCommandSuite) TestAuditLogInit(c *gc.C) {
+	err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), []string{"app", "--audit-log", "audit.log"})
+	c.Assert(err, gc.ErrorMatches, "--audit-log can only be used when setting or resetting values")
+	err = cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), []string{"app", "--audit-log", "audit.log", "--watch"})
+	c.Assert(err, gc.ErrorMatches, "--audit-log can only be used when setting or resetting values")
+}
+
+func (s *configCommandSuite) TestSetNoCharmCheck(c *gc.C) {
+	s.fake.getErr = errors.New("charm metadata unavailable")
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "username=hello")
+	c.Assert(err, gc.ErrorMatches, "charm metadata unavailable")
+
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--no-charm-check", "username=hello")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `WARNING: --no-charm-check given: setting values for "dummy-application" without checking them against the charm's config schema`+"\n")
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+}
+
+func (s *configCommandSuite) TestNoCharmCheckInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--no-charm-check"},
+		err:  "--no-charm-check can only be used when setting values as key=value arguments",
+	}, {
+		args: []string{"app", "--no-charm-check", "--file", "config.yaml"},
+		err:  "--no-charm-check can only be used when setting values as key=value arguments",
+	}, {
+		args: []string{"app", "--no-charm-check", "--reset", "username"},
+		err:  "--no-charm-check can only be used when setting values as key=value arguments",
+	}, {
+		args: []string{"app", "--no-charm-check", "--backup", "backup.yaml", "username=hello"},
+		err:  "--no-charm-check cannot be combined with --backup or --audit-log, which need the charm's config schema",
+	}, {
+		args: []string{"app", "--no-charm-check", "--audit-log", "audit.log", "username=hello"},
+		err:  "--no-charm-check cannot be combined with --backup or --audit-log, which need the charm's config schema",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *configCommandSuite) TestSetRedactInLogs(c *gc.C) {
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--redact-in-logs", "username=s3cret")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.redacted, jc.IsTrue)
+	c.Assert(s.fake.values["username"], gc.Equals, "s3cret")
+}
+
+func (s *configCommandSuite) TestSetFileRedactInLogs(c *gc.C) {
+	command := application.NewConfigCommandForTest(s.fake)
+	command.SetClientStore(application.NewMockStore())
+	_, err := cmdtesting.RunCommandInDir(c, command, []string{
+		"dummy-application", "--file", "testconfig.yaml", "--redact-in-logs",
+	}, s.dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.redacted, jc.IsTrue)
+	c.Assert(s.fake.values["username"], gc.Equals, "admin001")
+}
+
+func (s *configCommandSuite) TestSetRedactInLogsNotSupported(c *gc.C) {
+	s.fake.redactErr = errors.NotSupportedf("redacting application settings in controller logs")
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--redact-in-logs", "username=s3cret")
+	c.Assert(err, gc.ErrorMatches, "cannot set values with --redact-in-logs: the controller does not support redacting them; no values were set")
+	c.Assert(s.fake.values["username"], gc.Not(gc.Equals), "s3cret")
+}
+
+func (s *configCommandSuite) TestWatchConfig(c *gc.C) {
+	s.fake.watchValues = []map[string]interface{}{{
+		"title":       "Nearly There",
+		"skill-level": 100,
+	}, {
+		"title":       "Almost There",
+		"skill-level": 100,
+		"username":    "admin001",
+	}, {
+		"title":       "Almost There",
+		"skill-level": 9000,
+	}}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--watch")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, ""+
+		"title: \"Nearly There\" -> \"Almost There\"\n"+
+		"username: (unset) -> \"admin001\"\n"+
+		"skill-level: 100 -> 9000\n"+
+		"username: \"admin001\" -> (unset)\n",
+	)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Watching settings for \"dummy-application\"; press Ctrl-C to stop.\n")
+}
+
+func (s *configCommandSuite) TestWatchConfigKey(c *gc.C) {
+	s.fake.watchValues = []map[string]interface{}{{
+		"title":       "Nearly There",
+		"skill-level": 100,
+	}, {
+		"title":       "Almost There",
+		"skill-level": 100,
+	}, {
+		"title":       "Almost There",
+		"skill-level": 9000,
+	}}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "skill-level", "--watch")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "skill-level: 100 -> 9000\n")
+}
+
+func (s *configCommandSuite) TestWatchConfigKeyNotFound(c *gc.C) {
+	s.fake.watchValues = []map[string]interface{}{{"title": "Nearly There"}}
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "invalid", "--watch")
+	c.Assert(err, gc.ErrorMatches, `key "invalid" not found in "dummy-application" application settings.`)
+}
+
+func (s *configCommandSuite) TestWatchConfigNotSupported(c *gc.C) {
+	s.fake.watchErr = errors.NotSupportedf("watching application config")
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--watch")
+	c.Assert(err, gc.ErrorMatches, "this juju controller does not support watching application settings")
+}
+
+// instantClock is a clock.Clock whose timers fire immediately, advancing
+// the time by their duration. It records the durations waited for.
+type instantClock struct {
+	clock.Clock
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *instantClock) Now() time.Time {
+	return c.now
+}
+
+func (c *instantClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func agentStatus(status string, since time.Time) params.DetailedStatus {
+	return params.DetailedStatus{Status: status, Since: &since}
+}
+
+func (s *configCommandSuite) TestSetMaxWait(c *gc.C) {
+	t0 := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+	s.fake.agentStatus = []map[string]params.DetailedStatus{{
+		// Before the change.
+		"dummy-application/0": agentStatus("idle", t0),
+		"dummy-application/1": agentStatus("idle", t0),
+	}, {
+		"dummy-application/0": agentStatus("executing", t1),
+		"dummy-application/1": agentStatus("idle", t0),
+	}, {
+		"dummy-application/0": agentStatus("idle", t1),
+		"dummy-application/1": agentStatus("executing", t1),
+	}, {
+		"dummy-application/0": agentStatus("idle", t1),
+		"dummy-application/1": agentStatus("idle", t1),
+	}}
+	clock := &instantClock{now: t0}
+	cmd := application.NewConfigCommandWithClockForTest(s.fake, clock)
+	ctx, err := cmdtesting.RunCommand(c, cmd, "dummy-application", "username=hello", "--max-wait", "1m")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, ""+
+		"0 of 2 units have applied the settings\n"+
+		"1 of 2 units have applied the settings\n"+
+		"All 2 units have applied the settings\n",
+	)
+	c.Assert(clock.waits, jc.DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+}
+
+func (s *configCommandSuite) TestSetMaxWaitTimeout(c *gc.C) {
+	t0 := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+	s.fake.agentStatus = []map[string]params.DetailedStatus{{
+		"dummy-application/0": agentStatus("idle", t0),
+		"dummy-application/1": agentStatus("idle", t0),
+		"dummy-application/2": agentStatus("idle", t0),
+	}, {
+		"dummy-application/0": agentStatus("idle", t1),
+		"dummy-application/1": agentStatus("idle", t0),
+		"dummy-application/2": agentStatus("error", t1),
+	}}
+	clock := &instantClock{now: t0}
+	cmd := application.NewConfigCommandWithClockForTest(s.fake, clock)
+	ctx, err := cmdtesting.RunCommand(c, cmd, "dummy-application", "username=hello", "--max-wait", "10s")
+	c.Assert(err, gc.ErrorMatches, "timed out after 10s waiting for units to apply the settings: dummy-application/1, dummy-application/2")
+	c.Assert(clock.waits, jc.DeepEquals, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 3 * time.Second})
+	c.Assert(strings.Count(cmdtesting.Stderr(ctx), "1 of 3 units have applied the settings\n"), gc.Equals, 5)
+}
+
+func (s *configCommandSuite) TestResetMaxWait(c *gc.C) {
+	t0 := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.fake.agentStatus = []map[string]params.DetailedStatus{{
+		"dummy-application/0": agentStatus("executing", t0),
+	}, {
+		"dummy-application/0": agentStatus("idle", t0.Add(time.Second)),
+	}}
+	clock := &instantClock{now: t0}
+	cmd := application.NewConfigCommandWithClockForTest(s.fake, clock)
+	ctx, err := cmdtesting.RunCommand(c, cmd, "dummy-application", "--reset", "username", "--max-wait", "1m")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "All 1 units have applied the settings\n")
+	c.Assert(clock.waits, gc.HasLen, 0)
+}
+
+func (s *configCommandSuite) TestPruneUnknown(c *gc.C) {
+	s.fake.values["old-option"] = "stale"
+	s.fake.values["removed"] = 42
+	s.fake.unknown = []string{"old-option", "removed"}
+
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--prune-unknown")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.values, gc.Not(jc.HasKey), "old-option")
+	c.Assert(s.fake.values, gc.Not(jc.HasKey), "removed")
+	c.Assert(s.fake.values, jc.HasKey, "username")
+	stderr := cmdtesting.Stderr(ctx)
+	c.Assert(stderr, jc.Contains, `Pruned "old-option"`)
+	c.Assert(stderr, jc.Contains, `Pruned "removed"`)
+}
+
+func (s *configCommandSuite) TestPruneUnknownNothingToDo(c *gc.C) {
+	before := make(map[string]interface{})
+	for k, v := range s.fake.values {
+		before[k] = v
+	}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--prune-unknown")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.values, jc.DeepEquals, before)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "No unknown settings to prune\n")
+}
+
+func (s *configCommandSuite) TestPruneUnknownRequiresForce(c *gc.C) {
+	var unknown []string
+	for i := 0; i < 6; i++ {
+		key := fmt.Sprintf("old-%d", i)
+		s.fake.values[key] = "stale"
+		unknown = append(unknown, key)
+	}
+	s.fake.unknown = unknown
+
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--prune-unknown")
+	c.Assert(err, gc.ErrorMatches, `would prune 6 unknown settings \(.*\), more than 5; use --force to proceed`)
+	c.Assert(s.fake.values, jc.HasKey, "old-0")
+
+	_, err = cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake), "dummy-application", "--prune-unknown", "--force")
+	c.Assert(err, jc.ErrorIsNil)
+	for _, key := range unknown {
+		c.Assert(s.fake.values, gc.Not(jc.HasKey), key)
+	}
+}
+
+func (s *configCommandSuite) TestSetIgnoreErrors(c *gc.C) {
+	s.fake.invalid = []string{"bogus", "stale"}
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake),
+		"dummy-application", "--ignore-errors", "username=hello", "bogus=1", "stale=2")
+	c.Assert(err, gc.Equals, cmd.ErrSilent)
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+	c.Assert(s.fake.values, gc.Not(jc.HasKey), "bogus")
+	c.Assert(s.fake.values, gc.Not(jc.HasKey), "stale")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+skipped 2 of 3 settings:
+  bogus: unknown option "bogus"
+  stale: unknown option "stale"
+`[1:])
+}
+
+func (s *configCommandSuite) TestSetIgnoreErrorsNoneSkipped(c *gc.C) {
+	ctx, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake),
+		"dummy-application", "--ignore-errors", "username=hello")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestSetWithoutIgnoreErrorsIsAllOrNothing(c *gc.C) {
+	s.fake.invalid = []string{"bogus"}
+	_, err := cmdtesting.RunCommand(c, application.NewConfigCommandForTest(s.fake),
+		"dummy-application", "username=hello", "bogus=1")
+	c.Assert(err, gc.ErrorMatches, `unknown option "bogus"`)
+	c.Assert(s.fake.values["username"], gc.Equals, "admin001")
+}
+
+func (s *configCommandSuite) TestSetFileIgnoreErrors(c *gc.C) {
+	s.fake.invalid = []string{"skill-level"}
+	command := application.NewConfigCommandForTest(s.fake)
+	command.SetClientStore(application.NewMockStore())
+	ctx, err := cmdtesting.RunCommandInDir(c, command, []string{
+		"dummy-application",
+		"--file",
+		"testconfig.yaml",
+		"--ignore-errors",
+	}, s.dir)
+	c.Assert(err, gc.Equals, cmd.ErrSilent)
+	c.Assert(s.fake.config, gc.Equals, "dummy-application:\n  username: admin001\n")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+skipped 1 of 2 settings:
+  skill-level: unknown option "skill-level"
+`[1:])
+}
+
+const countChangesConfig = `
+dummy-application:
+  skill-level: 9000
+  username: admin001
+  title: ""
+  bogus: 1
+`
+
+func (s *configCommandSuite) TestCountChanges(c *gc.C) {
+	path := filepath.Join(s.dir, "overlay.yaml")
+	err := ioutil.WriteFile(path, []byte(countChangesConfig), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	command := application.NewConfigCommandForTest(s.fake)
+	command.SetClientStore(application.NewMockStore())
+	ctx, err := cmdtesting.RunCommandInDir(c, command, []string{
+		"dummy-application", "--file", "overlay.yaml", "--count-changes",
+	}, s.dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "1 to change, 1 to reset, 1 unknown, 1 unchanged\n")
+	c.Assert(s.fake.config, gc.Equals, countChangesConfig)
+}
+
+func (s *configCommandSuite) TestCountChangesResetDefault(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{"title": "Nearly There"}
+	ctx := cmdtesting.Context(c)
+	ctx.Stdin = strings.NewReader("dummy-application:\n  title:\n")
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--file", "-", "--count-changes",
+	})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "0 to change, 0 to reset, 0 unknown, 1 unchanged\n")
+}
+
+func (s *configCommandSuite) TestCountChangesConfirm(c *gc.C) {
+	for i, test := range []struct {
+		answer  string
+		applied bool
+	}{{
+		answer: "n\n",
+	}, {
+		answer: "",
+	}, {
+		answer:  "y\n",
+		applied: true,
+	}} {
+		c.Logf("test %d: %q", i, test.answer)
+		s.fake.config = ""
+		ctx := cmdtesting.ContextForDir(c, s.dir)
+		ctx.Stdin = strings.NewReader(test.answer)
+		code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+			"dummy-application", "--file", "testconfig.yaml", "--count-changes", "--confirm",
+		})
+		c.Check(cmdtesting.Stdout(ctx), gc.Equals, "1 to change, 0 to reset, 0 unknown, 1 unchanged\nApply these settings? (y/N): ")
+		if test.applied {
+			c.Check(code, gc.Equals, 0)
+			c.Check(s.fake.config, gc.Equals, yamlConfigValue)
+		} else {
+			c.Check(code, gc.Equals, 1)
+			c.Check(cmdtesting.Stderr(ctx), gc.Equals, "ERROR applying settings: aborted\n")
+			c.Check(s.fake.config, gc.Equals, "")
+		}
+	}
+}
+
+func (s *configCommandSuite) TestCountChangesReset(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{"outlook": "true"}
+	s.fake.config = ""
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--file", "testconfig.yaml", "--count-changes",
+		"--reset", "title,outlook,username,bogus",
+	})
+	c.Assert(code, gc.Equals, 1)
+	// The username in the file is counted once; title is reset, and
+	// outlook is already at its default.
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, "1 to change, 1 to reset, 1 unknown, 2 unchanged\n")
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "ERROR unknown option \"bogus\"\n")
+}
+
+func (s *configCommandSuite) TestCountChangesConfirmReset(c *gc.C) {
+	for i, test := range []struct {
+		answer  string
+		applied bool
+	}{{
+		answer: "n\n",
+	}, {
+		answer:  "y\n",
+		applied: true,
+	}} {
+		c.Logf("test %d: %q", i, test.answer)
+		s.fake.values["title"] = "Nearly There"
+		s.fake.config = ""
+		ctx := cmdtesting.ContextForDir(c, s.dir)
+		ctx.Stdin = strings.NewReader(test.answer)
+		code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+			"dummy-application", "--file", "testconfig.yaml", "--count-changes", "--confirm",
+			"--reset", "title",
+		})
+		c.Check(cmdtesting.Stdout(ctx), gc.Equals, "1 to change, 1 to reset, 0 unknown, 1 unchanged\nApply these settings? (y/N): ")
+		_, reset := s.fake.values["title"]
+		reset = !reset
+		if test.applied {
+			c.Check(code, gc.Equals, 0)
+			c.Check(reset, jc.IsTrue)
+			c.Check(s.fake.config, gc.Equals, yamlConfigValue)
+		} else {
+			c.Check(code, gc.Equals, 1)
+			c.Check(reset, jc.IsFalse)
+			c.Check(s.fake.config, gc.Equals, "")
+		}
+	}
+}
+
+func (s *configCommandSuite) TestCountChangesInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--count-changes"},
+		err:  "--count-changes can only be used with --file",
+	}, {
+		args: []string{"app", "--count-changes", "username=hello"},
+		err:  "--count-changes can only be used with --file",
+	}, {
+		args: []string{"app", "--file", "config.yaml", "--confirm"},
+		err:  "--confirm can only be used with --count-changes",
+	}, {
+		args: []string{"app", "--file", "-", "--count-changes", "--confirm"},
+		err:  "--confirm cannot be used when reading settings from stdin",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+const mergeStrategyConfig = `
+dummy-application:
+  skill-level: 9000
+  username: bob
+`
+
+func (s *configCommandSuite) runMergeStrategy(c *gc.C, args ...string) *cmd.Context {
+	path := filepath.Join(s.dir, "merge.yaml")
+	err := ioutil.WriteFile(path, []byte(mergeStrategyConfig), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	command := application.NewConfigCommandForTest(s.fake)
+	command.SetClientStore(application.NewMockStore())
+	ctx, err := cmdtesting.RunCommandInDir(c, command, append([]string{
+		"dummy-application", "--file", "merge.yaml",
+	}, args...), s.dir)
+	c.Assert(err, jc.ErrorIsNil)
+	return ctx
+}
+
+func (s *configCommandSuite) TestMergeStrategyReplace(c *gc.C) {
+	s.runMergeStrategy(c, "--merge-strategy", "replace")
+	c.Assert(s.fake.config, gc.Equals, mergeStrategyConfig)
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 9000,
+		"username":    "bob",
+		"outlook":     "true",
+	})
+}
+
+func (s *configCommandSuite) TestMergeStrategyMerge(c *gc.C) {
+	s.runMergeStrategy(c, "--merge-strategy", "merge")
+	// Only the file's settings are sent; the controller leaves the
+	// others unchanged.
+	c.Assert(s.fake.config, gc.Equals, mergeStrategyConfig)
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 9000,
+		"username":    "bob",
+		"outlook":     "true",
+	})
+}
+
+func (s *configCommandSuite) TestMergeStrategiesResultingSettings(c *gc.C) {
+	results := make(map[string]map[string]interface{})
+	for _, strategy := range []string{"replace", "merge", "merge-reset-missing"} {
+		s.fake.values = map[string]interface{}{
+			"title":       "Nearly There",
+			"skill-level": 100,
+			"username":    "admin001",
+			"outlook":     "true",
+		}
+		s.runMergeStrategy(c, "--merge-strategy", strategy)
+		results[strategy] = s.fake.values
+	}
+	// replace and merge both leave the settings absent from the file,
+	// title and outlook, as they are; merge-reset-missing resets them.
+	c.Assert(results["replace"], jc.DeepEquals, map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 9000,
+		"username":    "bob",
+		"outlook":     "true",
+	})
+	c.Assert(results["merge"], jc.DeepEquals, results["replace"])
+	c.Assert(results["merge-reset-missing"], jc.DeepEquals, map[string]interface{}{
+		"skill-level": 9000,
+		"username":    "bob",
+	})
+}
+
+func (s *configCommandSuite) TestMergeStrategyResetMissing(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{"outlook": "true"}
+	s.runMergeStrategy(c, "--merge-strategy", "merge-reset-missing")
+	c.Assert(s.fake.config, gc.Equals, mergeStrategyConfig)
+	// title is reset as it is not in the file; outlook already has its
+	// default value, so is left alone.
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"skill-level": 9000,
+		"username":    "bob",
+		"outlook":     "true",
+	})
+}
+
+func (s *configCommandSuite) TestMergeStrategyResetMissingCountChanges(c *gc.C) {
+	ctx := s.runMergeStrategy(c, "--merge-strategy", "merge-reset-missing", "--count-changes")
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "2 to change, 2 to reset, 0 unknown, 0 unchanged\n")
+}
+
+func (s *configCommandSuite) TestMergeStrategyResetMissingPlan(c *gc.C) {
+	path := filepath.Join(s.dir, "merge.yaml")
+	err := ioutil.WriteFile(path, []byte(mergeStrategyConfig), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	plan := s.runPlan(c, "--file", "merge.yaml", "--merge-strategy", "merge-reset-missing")
+	c.Assert(plan, jc.DeepEquals, map[string]interface{}{
+		"application": "dummy-application",
+		"changes": []interface{}{
+			map[string]interface{}{"key": "outlook", "action": "reset", "old": "true", "new": nil},
+			map[string]interface{}{"key": "skill-level", "action": "set", "old": 100.0, "new": 9000.0},
+			map[string]interface{}{"key": "title", "action": "reset", "old": "Nearly There", "new": nil},
+			map[string]interface{}{"key": "username", "action": "set", "old": "admin001", "new": "bob"},
+		},
+	})
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestMergeStrategyInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--merge-strategy", "merge"},
+		err:  "--merge-strategy can only be used with --file",
+	}, {
+		args: []string{"app", "--merge-strategy", "merge-reset-missing", "username=hello"},
+		err:  "--merge-strategy can only be used with --file",
+	}, {
+		args: []string{"app", "--file", "config.yaml", "--merge-strategy", "overwrite"},
+		err:  `--merge-strategy: "overwrite" is not valid, expected "replace", "merge" or "merge-reset-missing"`,
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+const diffAgainstFileConfig = `
+dummy-application:
+  skill-level: 9000
+  username: admin001
+  title: ""
+  bogus: 1
+`
+
+func (s *configCommandSuite) TestDiffAgainstFile(c *gc.C) {
+	path := filepath.Join(s.dir, "known-good.yaml")
+	err := ioutil.WriteFile(path, []byte(diffAgainstFileConfig), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--diff-against-file", "known-good.yaml", "--format", "json",
+	})
+	c.Assert(code, gc.Equals, 0)
+	var diff map[string]interface{}
+	err = json.Unmarshal(ctx.Stdout.(*bytes.Buffer).Bytes(), &diff)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(diff, jc.DeepEquals, map[string]interface{}{
+		"differ": map[string]interface{}{
+			"skill-level": map[string]interface{}{"file": 9000.0, "live": 100.0},
+			"title":       map[string]interface{}{"file": nil, "live": "Nearly There"},
+		},
+		"only-in-file": map[string]interface{}{"bogus": 1.0},
+		"only-live":    map[string]interface{}{"outlook": "true"},
+	})
+
+	// Nothing is applied.
+	c.Assert(s.fake.config, gc.Equals, "")
+	c.Assert(s.fake.values["skill-level"], gc.Equals, 100)
+}
+
+func (s *configCommandSuite) TestDiffAgainstFileDefaults(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{"title": "Nearly There", "outlook": "false"}
+	ctx := cmdtesting.Context(c)
+	ctx.Stdin = strings.NewReader("dummy-application:\n  title: Nearly There\n  skill-level: 100\n  username: admin001\n  outlook: \"true\"\n")
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--diff-against-file", "-",
+	})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "{}\n")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "No differences\n")
+}
+
+func (s *configCommandSuite) TestDiffAgainstFileInit(c *gc.C) {
+	for i, args := range [][]string{
+		{"app", "--diff-against-file", "config.yaml", "username"},
+		{"app", "--diff-against-file", "config.yaml", "username=hello"},
+		{"app", "--diff-against-file", "config.yaml", "--file", "other.yaml"},
+		{"app", "--diff-against-file", "config.yaml", "--reset", "username"},
+		{"app", "--diff-against-file", "config.yaml", "--watch"},
+		{"app", "--diff-against-file", "config.yaml", "--only-changed"},
+	} {
+		c.Logf("test %d: %v", i, args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), args)
+		c.Check(err, gc.ErrorMatches, "--diff-against-file cannot be combined with getting, setting, resetting or watching values")
+	}
+}
+
+func (s *configCommandSuite) TestSetStrict(c *gc.C) {
+	s.assertSetFail(c, s.dir, []string{
+		"--strict", "username=hello", "outlook=false", "bogus=1", "other=2",
+	}, "no settings applied with --strict; unknown keys: bogus, other")
+	c.Assert(s.fake.values["username"], gc.Equals, "admin001")
+	c.Assert(s.fake.values["outlook"], gc.Equals, "true")
+
+	s.assertSetSuccess(c, s.dir, []string{"--strict", "username=hello"}, nil)
+	c.Assert(s.fake.values["username"], gc.Equals, "hello")
+}
+
+func (s *configCommandSuite) TestSetFileStrict(c *gc.C) {
+	path := filepath.Join(s.dir, "overlay.yaml")
+	err := ioutil.WriteFile(path, []byte(countChangesConfig), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertSetFail(c, s.dir, []string{
+		"--file", "overlay.yaml", "--strict",
+	}, "no settings applied with --strict; unknown keys: bogus")
+	c.Assert(s.fake.config, gc.Equals, "")
+
+	// Without --strict, the file is left for the controller to check.
+	s.assertSetSuccess(c, s.dir, []string{"--file", "overlay.yaml"}, nil)
+	c.Assert(s.fake.config, gc.Equals, countChangesConfig)
+}
+
+func (s *configCommandSuite) TestStrictInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--strict"},
+		err:  "--strict can only be used when setting values",
+	}, {
+		args: []string{"app", "--strict", "--reset", "username"},
+		err:  "--strict can only be used when setting values",
+	}, {
+		args: []string{"app", "--strict", "--no-charm-check", "username=hello"},
+		err:  "--strict cannot be combined with --no-charm-check, which skips the charm's config schema",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *configCommandSuite) runPlan(c *gc.C, args ...string) map[string]interface{} {
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, append([]string{"dummy-application", "--plan"}, args...))
+	c.Assert(code, gc.Equals, 0, gc.Commentf("stderr: %s", cmdtesting.Stderr(ctx)))
+	var plan map[string]interface{}
+	err := json.Unmarshal(ctx.Stdout.(*bytes.Buffer).Bytes(), &plan)
+	c.Assert(err, jc.ErrorIsNil)
+	return plan
+}
+
+func (s *configCommandSuite) TestPlan(c *gc.C) {
+	s.fake.defaults = map[string]interface{}{"title": "My Title", "outlook": "true"}
+	plan := s.runPlan(c, "skill-level=9000", "username=admin001", "bogus=1", "--reset", "title,outlook")
+	c.Assert(plan, jc.DeepEquals, map[string]interface{}{
+		"application": "dummy-application",
+		"changes": []interface{}{
+			map[string]interface{}{"key": "bogus", "action": "unknown", "old": nil, "new": "1"},
+			map[string]interface{}{"key": "outlook", "action": "no-op", "old": "true", "new": "true"},
+			map[string]interface{}{"key": "skill-level", "action": "set", "old": 100.0, "new": "9000"},
+			map[string]interface{}{"key": "title", "action": "reset", "old": "Nearly There", "new": "My Title"},
+			map[string]interface{}{"key": "username", "action": "no-op", "old": "admin001", "new": "admin001"},
+		},
+	})
+
+	// Nothing is applied.
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       "Nearly There",
+		"skill-level": 100,
+		"username":    "admin001",
+		"outlook":     "true",
+	})
+}
+
+func (s *configCommandSuite) TestPlanFile(c *gc.C) {
+	path := filepath.Join(s.dir, "overlay.yaml")
+	err := ioutil.WriteFile(path, []byte(countChangesConfig), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	plan := s.runPlan(c, "--file", "overlay.yaml")
+	c.Assert(plan, jc.DeepEquals, map[string]interface{}{
+		"application": "dummy-application",
+		"changes": []interface{}{
+			map[string]interface{}{"key": "bogus", "action": "unknown", "old": nil, "new": 1.0},
+			map[string]interface{}{"key": "skill-level", "action": "set", "old": 100.0, "new": 9000.0},
+			map[string]interface{}{"key": "title", "action": "reset", "old": "Nearly There", "new": nil},
+			map[string]interface{}{"key": "username", "action": "no-op", "old": "admin001", "new": "admin001"},
+		},
+	})
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestPlanInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--plan"},
+		err:  "--plan can only be used when setting or resetting values",
+	}, {
+		args: []string{"app", "--plan", "username"},
+		err:  "--plan can only be used when setting or resetting values",
+	}, {
+		args: []string{"app", "--plan", "--revert-last"},
+		err:  "--plan can only be used when setting or resetting values",
+	}, {
+		args: []string{"app", "--plan", "username=hello", "--backup", "backup.yaml"},
+		err:  "--plan cannot be combined with .*",
+	}, {
+		args: []string{"app", "--plan", "username=hello", "--max-wait", "1m"},
+		err:  "--plan cannot be combined with .*",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func writeConfigTemplate(c *gc.C, name, content string) {
+	dir := osenv.JujuXDGDataHomePath("config-templates")
+	err := os.MkdirAll(dir, 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte(content), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *configCommandSuite) TestTemplate(c *gc.C) {
+	writeConfigTemplate(c, "production", "skill-level: 9000\nusername: template-user\ntitle:\n")
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--template", "production", "username=override",
+	})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("stderr: %s", cmdtesting.Stderr(ctx)))
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+Applying config template "production" to dummy-application:
+  skill-level: 9000
+  title: (default)
+  username: override
+`[1:])
+	c.Assert(s.fake.values, jc.DeepEquals, map[string]interface{}{
+		"title":       nil,
+		"skill-level": 9000,
+		"username":    "override",
+		"outlook":     "true",
+	})
+}
+
+func (s *configCommandSuite) TestTemplateFromConfigOutput(c *gc.C) {
+	writeConfigTemplate(c, "saved", `
+application: other-application
+charm: dummy
+settings:
+  outlook:
+    type: string
+    value: "false"
+`[1:])
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--template", "saved", "skill-level=42",
+	})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("stderr: %s", cmdtesting.Stderr(ctx)))
+	c.Assert(s.fake.values["outlook"], gc.Equals, "false")
+	c.Assert(s.fake.values["skill-level"], gc.Equals, 42)
+}
+
+func (s *configCommandSuite) TestTemplateInvalid(c *gc.C) {
+	writeConfigTemplate(c, "broken", "skill-level: lots\nbogus: 1\n")
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--template", "broken", "username=42",
+	})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals,
+		`ERROR cannot apply config template "broken"; unknown keys: bogus; invalid values: skill-level: expected int, got lots`+"\n")
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestTemplateNotFound(c *gc.C) {
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--template", "missing",
+	})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Matches, `ERROR config template "missing" \(.*missing.yaml\) not found\n`)
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestTemplateInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--template", "production"},
+	}, {
+		args: []string{"app", "--template", "production", "username=hello", "--backup", "backup.yaml"},
+	}, {
+		args: []string{"app", "--template", "../production"},
+		err:  `--template: invalid config template name "../production"`,
+	}, {
+		args: []string{"app", "--template", "production", "--file", "config.yaml"},
+		err:  "--template cannot be combined with --file, --reset or getting values",
+	}, {
+		args: []string{"app", "--template", "production", "username"},
+		err:  "--template cannot be combined with --file, --reset or getting values",
+	}, {
+		args: []string{"app", "--template", "production", "--plan"},
+		err:  "--template cannot be combined with .*--plan",
+	}, {
+		args: []string{"app", "--template", "production", "--ignore-errors"},
+		err:  "--template cannot be combined with --count-changes, --ignore-errors or --no-charm-check",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
+func (s *configCommandSuite) writeBundle(c *gc.C, content string) {
+	err := ioutil.WriteFile(filepath.Join(s.dir, "bundle.yaml"), []byte(content), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *configCommandSuite) TestFromBundle(c *gc.C) {
+	s.writeBundle(c, `
+applications:
+  dummy-application:
+    charm: cs:xenial/dummy-42
+    num_units: 1
+    options:
+      skill-level: 9000
+      username: bundle-user
+  other:
+    charm: cs:other
+`[1:])
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--from-bundle", "bundle.yaml", "username=override",
+	})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("stderr: %s", cmdtesting.Stderr(ctx)))
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+Applying bundle "bundle.yaml" to dummy-application:
+  skill-level: 9000
+  username: override
+`[1:])
+	c.Assert(s.fake.values["skill-level"], gc.Equals, 9000)
+	c.Assert(s.fake.values["username"], gc.Equals, "override")
+}
+
+func (s *configCommandSuite) TestFromBundleApplicationNotFound(c *gc.C) {
+	s.writeBundle(c, "applications:\n  other:\n    charm: cs:dummy\n")
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--from-bundle", "bundle.yaml",
+	})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals,
+		`ERROR application "dummy-application" in bundle "bundle.yaml" not found`+"\n")
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestFromBundleCharmMismatch(c *gc.C) {
+	s.writeBundle(c, "applications:\n  dummy-application:\n    charm: cs:mysql\n    options:\n      username: foo\n")
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--from-bundle", "bundle.yaml",
+	})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals,
+		`ERROR cannot apply bundle "bundle.yaml"; application "dummy-application" uses charm "dummy", not "mysql" as in the bundle`+"\n")
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestFromBundleInvalid(c *gc.C) {
+	s.writeBundle(c, "applications:\n  dummy-application:\n    charm: dummy\n    options:\n      skill-level: lots\n      bogus: 1\n")
+	ctx := cmdtesting.ContextForDir(c, s.dir)
+	code := cmd.Main(application.NewConfigCommandForTest(s.fake), ctx, []string{
+		"dummy-application", "--from-bundle", "bundle.yaml",
+	})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals,
+		`ERROR cannot apply bundle "bundle.yaml"; unknown keys: bogus; invalid values: skill-level: expected int, got lots`+"\n")
+	c.Assert(s.fake.config, gc.Equals, "")
+}
+
+func (s *configCommandSuite) TestFromBundleInit(c *gc.C) {
+	for i, test := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"app", "--from-bundle", "bundle.yaml"},
+	}, {
+		args: []string{"app", "--from-bundle", "bundle.yaml", "username=hello", "--strict"},
+	}, {
+		args: []string{"app", "--from-bundle", "bundle.yaml", "--template", "production"},
+		err:  "--template cannot be combined with --from-bundle",
+	}, {
+		args: []string{"app", "--from-bundle", "bundle.yaml", "--reset", "username"},
+		err:  "--from-bundle cannot be combined with --file, --reset or getting values",
+	}, {
+		args: []string{"app", "--from-bundle", "bundle.yaml", "--watch"},
+		err:  "--from-bundle cannot be combined with .*--watch.*",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		err := cmdtesting.InitCommand(application.NewConfigCommandForTest(s.fake), test.args)
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
 func (s *configCommandSuite) TestBlockSetConfig(c *gc.C) {
 	// Block operation
 	s.fake.err = common.OperationBlockedError("TestBlockSetConfig")
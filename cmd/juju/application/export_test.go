@@ -5,6 +5,7 @@ package application
 
 import (
 	"github.com/juju/cmd"
+	"github.com/juju/utils/clock"
 	"gopkg.in/juju/charmrepo.v2-unstable/csclient"
 	"gopkg.in/macaroon-bakery.v1/httpbakery"
 
@@ -40,6 +41,15 @@ func NewUpgradeCharmCommandForTest(
 }
 
 // NewAddUnitCommandForTest returns an AddUnitCommand with the api provided as specified.
+// NewConfigCommandWithClockForTest returns a config command using the
+// given API and clock.
+func NewConfigCommandWithClockForTest(api configCommandAPI, clock clock.Clock) modelcmd.ModelCommand {
+	return modelcmd.Wrap(&configCommand{
+		api:   api,
+		clock: clock,
+	})
+}
+
 func NewAddUnitCommandForTest(api serviceAddUnitAPI) cmd.Command {
 	return modelcmd.Wrap(&addUnitCommand{
 		api: api,
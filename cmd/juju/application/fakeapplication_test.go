@@ -5,10 +5,13 @@ package application_test
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/juju/errors"
+	goyaml "gopkg.in/yaml.v2"
 
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/watcher"
 )
 
 // fakeServiceAPI is the fake application API for testing the application
@@ -17,48 +20,156 @@ type fakeApplicationAPI struct {
 	name      string
 	charmName string
 	values    map[string]interface{}
+	defaults  map[string]interface{}
+	unknown   []string
+	invalid   []string
 	config    string
 	err       error
+
+	// getErr, if set, is returned by Get, as when the charm's
+	// metadata is unavailable.
+	getErr error
+
+	// redacted records whether settings were changed with a request
+	// to redact their values in the controller's logs; redactErr, if
+	// set, is returned by such requests instead.
+	redacted  bool
+	redactErr error
+
+	// watchValues holds the successive values reported by Get, one
+	// for each event from the watcher returned by WatchConfig,
+	// starting with the initial event.
+	watchValues []map[string]interface{}
+	watchErr    error
+
+	// previous holds the settings reported by PreviousConfig; if nil,
+	// PreviousConfig reports that there are none, or previousErr.
+	previous    map[string]interface{}
+	previousErr error
+
+	// changed holds the times reported by ConfigChangeTimes;
+	// changedErr, if set, is returned instead.
+	changed    map[string]time.Time
+	changedErr error
+
+	// locked records whether the settings are locked, in which case
+	// changing them fails, as it does on the controller; lockErr, if
+	// set, is returned by SetConfigLocked instead.
+	locked  bool
+	lockErr error
+
+	// agentStatus holds the successive agent status of the units
+	// reported by Status, one for each call; the last is repeated.
+	agentStatus []map[string]params.DetailedStatus
 }
 
 func (f *fakeApplicationAPI) Update(args params.ApplicationUpdate) error {
 	if f.err != nil {
 		return f.err
 	}
+	if err := f.checkUnlocked(); err != nil {
+		return err
+	}
+	if args.RedactInLogs {
+		if f.redactErr != nil {
+			return f.redactErr
+		}
+		f.redacted = true
+	}
 
 	if args.ApplicationName != f.name {
 		return errors.NotFoundf("application %q", args.ApplicationName)
 	}
 
+	// Verify all settings before applying any of them.
+	var all map[string]map[string]interface{}
+	if err := goyaml.Unmarshal([]byte(args.SettingsYAML), &all); err == nil {
+		for k := range all[args.ApplicationName] {
+			if err := f.checkValid(k); err != nil {
+				return err
+			}
+		}
+		if len(all[args.ApplicationName]) > 0 && f.values == nil {
+			f.values = make(map[string]interface{})
+		}
+		for k, v := range all[args.ApplicationName] {
+			f.values[k] = v
+		}
+	}
+
 	f.config = args.SettingsYAML
 	return nil
 }
 
+func (f *fakeApplicationAPI) checkValid(option string) error {
+	if isUnknown(f.invalid, option) {
+		return errors.Errorf("unknown option %q", option)
+	}
+	return nil
+}
+
+func (f *fakeApplicationAPI) checkUnlocked() error {
+	if f.locked {
+		return errors.Errorf("cannot change settings of application %q: config locked", f.name)
+	}
+	return nil
+}
+
 func (f *fakeApplicationAPI) Close() error {
 	return nil
 }
 
 func (f *fakeApplicationAPI) Get(application string) (*params.ApplicationGetResults, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
 	if application != f.name {
 		return nil, errors.NotFoundf("application %q", application)
 	}
 
+	if len(f.watchValues) > 0 {
+		f.values, f.watchValues = f.watchValues[0], f.watchValues[1:]
+	}
+
 	configInfo := make(map[string]interface{})
+	var unknown []string
 	for k, v := range f.values {
-		configInfo[k] = map[string]interface{}{
+		if isUnknown(f.unknown, k) {
+			unknown = append(unknown, k)
+			continue
+		}
+		info := map[string]interface{}{
 			"description": fmt.Sprintf("Specifies %s", k),
 			"type":        fmt.Sprintf("%T", v),
 			"value":       v,
 		}
+		if d, ok := f.defaults[k]; ok {
+			info["default"] = d
+			if d == v {
+				info["is_default"] = true
+			}
+		}
+		configInfo[k] = info
 	}
 
 	return &params.ApplicationGetResults{
-		Application: f.name,
-		Charm:       f.charmName,
-		Config:      configInfo,
+		Application:     f.name,
+		Charm:           f.charmName,
+		Config:          configInfo,
+		UnknownSettings: unknown,
+		ConfigLocked:    f.locked,
 	}, nil
 }
 
+func isUnknown(unknown []string, key string) bool {
+	for _, k := range unknown {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *fakeApplicationAPI) Set(application string, options map[string]string) error {
 	if f.err != nil {
 		return f.err
@@ -67,6 +178,16 @@ func (f *fakeApplicationAPI) Set(application string, options map[string]string)
 	if application != f.name {
 		return errors.NotFoundf("application %q", application)
 	}
+	if err := f.checkUnlocked(); err != nil {
+		return err
+	}
+
+	// Verify all options before setting any of them.
+	for k := range options {
+		if err := f.checkValid(k); err != nil {
+			return err
+		}
+	}
 
 	if f.values == nil {
 		f.values = make(map[string]interface{})
@@ -78,6 +199,17 @@ func (f *fakeApplicationAPI) Set(application string, options map[string]string)
 	return nil
 }
 
+func (f *fakeApplicationAPI) SetRedacted(application string, options map[string]string) error {
+	if f.redactErr != nil {
+		return f.redactErr
+	}
+	if err := f.Set(application, options); err != nil {
+		return err
+	}
+	f.redacted = true
+	return nil
+}
+
 func (f *fakeApplicationAPI) Unset(application string, options []string) error {
 	if f.err != nil {
 		return f.err
@@ -86,6 +218,9 @@ func (f *fakeApplicationAPI) Unset(application string, options []string) error {
 	if application != f.name {
 		return errors.NotFoundf("application %q", application)
 	}
+	if err := f.checkUnlocked(); err != nil {
+		return err
+	}
 
 	// Verify all options before unsetting any of them.
 	for _, name := range options {
@@ -100,3 +235,86 @@ func (f *fakeApplicationAPI) Unset(application string, options []string) error {
 
 	return nil
 }
+
+func (f *fakeApplicationAPI) WatchConfig(application string) (watcher.NotifyWatcher, error) {
+	if f.watchErr != nil {
+		return nil, f.watchErr
+	}
+	if application != f.name {
+		return nil, errors.NotFoundf("application %q", application)
+	}
+	// Send an event for each of the watched values, then stop.
+	changes := make(chan struct{}, len(f.watchValues))
+	for range f.watchValues {
+		changes <- struct{}{}
+	}
+	close(changes)
+	return &fakeNotifyWatcher{changes: changes}, nil
+}
+
+func (f *fakeApplicationAPI) PreviousConfig(application string) (map[string]interface{}, error) {
+	if f.previousErr != nil {
+		return nil, f.previousErr
+	}
+	if application != f.name {
+		return nil, errors.NotFoundf("application %q", application)
+	}
+	if f.previous == nil {
+		return nil, errors.NotFoundf("previous config settings for application %q", application)
+	}
+	return f.previous, nil
+}
+
+func (f *fakeApplicationAPI) ConfigChangeTimes(application string) (map[string]time.Time, error) {
+	if f.changedErr != nil {
+		return nil, f.changedErr
+	}
+	if application != f.name {
+		return nil, errors.NotFoundf("application %q", application)
+	}
+	return f.changed, nil
+}
+
+func (f *fakeApplicationAPI) SetConfigLocked(application string, locked bool) error {
+	if f.lockErr != nil {
+		return f.lockErr
+	}
+	if application != f.name {
+		return errors.NotFoundf("application %q", application)
+	}
+	f.locked = locked
+	return nil
+}
+
+func (f *fakeApplicationAPI) Status(patterns []string) (*params.FullStatus, error) {
+	units := make(map[string]params.UnitStatus)
+	if len(f.agentStatus) > 0 {
+		for name, agentStatus := range f.agentStatus[0] {
+			units[name] = params.UnitStatus{AgentStatus: agentStatus}
+		}
+		if len(f.agentStatus) > 1 {
+			f.agentStatus = f.agentStatus[1:]
+		}
+	}
+	return &params.FullStatus{
+		Applications: map[string]params.ApplicationStatus{
+			f.name: {Units: units},
+		},
+	}, nil
+}
+
+// fakeNotifyWatcher is a watcher.NotifyWatcher that delivers the
+// events already queued on its channel.
+type fakeNotifyWatcher struct {
+	changes chan struct{}
+}
+
+func (w *fakeNotifyWatcher) Changes() watcher.NotifyChannel {
+	return w.changes
+}
+
+func (w *fakeNotifyWatcher) Kill() {}
+
+func (w *fakeNotifyWatcher) Wait() error {
+	return nil
+}
@@ -355,6 +355,7 @@ func registerCommands(r commandRegistry, ctx *cmd.Context) {
 	r.Register(machine.NewRemoveCommand())
 	r.Register(machine.NewListMachinesCommand())
 	r.Register(machine.NewShowMachineCommand())
+	r.Register(machine.NewLockdownCommand())
 
 	// Manage model
 	r.Register(model.NewConfigCommand())
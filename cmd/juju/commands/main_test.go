@@ -472,6 +472,7 @@ var commandNames = []string{
 	"list-subnets",
 	"list-users",
 	"list-wallets",
+	"lockdown-machine",
 	"login",
 	"logout",
 	"machines",
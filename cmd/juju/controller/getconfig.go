@@ -11,6 +11,7 @@ import (
 	"github.com/juju/cmd"
 	"github.com/juju/errors"
 	"github.com/juju/gnuflag"
+	"github.com/juju/utils/keyvalues"
 	"github.com/juju/utils/set"
 
 	apicontroller "github.com/juju/juju/api/controller"
@@ -27,9 +28,11 @@ func NewGetConfigCommand() cmd.Command {
 // the requested value in a format of the user's choosing.
 type getConfigCommand struct {
 	modelcmd.ControllerCommandBase
-	api controllerAPI
-	key string
-	out cmd.Output
+	api    controllerAPI
+	key    string
+	values map[string]string
+	reset  string
+	out    cmd.Output
 }
 
 const getControllerHelpDoc = `
@@ -40,11 +43,18 @@ The controller configuration is set during bootstrap, available keys
 and values can be found here:
   https://jujucharms.com/docs/stable/controllers-config
 
+A few keys may be changed once the controller is running, by passing
+key=value pairs, or removed with --reset. They are:
+  firewall-panic-close
+  firewall-essential-rules
+
 Examples:
 
     juju controller-config
     juju controller-config api-port
     juju controller-config -c mycontroller
+    juju controller-config firewall-essential-rules=22/tcp@10.0.0.0/8 firewall-panic-close=true
+    juju controller-config --reset firewall-panic-close
 
 See also:
     controllers
@@ -55,14 +65,15 @@ See also:
 func (c *getConfigCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "controller-config",
-		Args:    "[<attribute key>]",
-		Purpose: "Displays configuration settings for a controller.",
+		Args:    "[<attribute key> | <attribute key>=<value> ...]",
+		Purpose: "Displays or changes configuration settings for a controller.",
 		Doc:     strings.TrimSpace(getControllerHelpDoc),
 	}
 }
 
 func (c *getConfigCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.ControllerCommandBase.SetFlags(f)
+	f.StringVar(&c.reset, "reset", "", "Reset the provided comma delimited keys")
 	c.out.AddFlags(f, "tabular", map[string]cmd.Formatter{
 		"json":    cmd.FormatJson,
 		"tabular": formatConfigTabular,
@@ -71,6 +82,13 @@ func (c *getConfigCommand) SetFlags(f *gnuflag.FlagSet) {
 }
 
 func (c *getConfigCommand) Init(args []string) (err error) {
+	if len(args) > 0 && strings.Contains(args[0], "=") {
+		c.values, err = keyvalues.Parse(args, true)
+		return errors.Trace(err)
+	}
+	if c.reset != "" {
+		return cmd.CheckEmpty(args)
+	}
 	c.key, err = cmd.ZeroOrOneArgs(args)
 	return
 }
@@ -78,6 +96,7 @@ func (c *getConfigCommand) Init(args []string) (err error) {
 type controllerAPI interface {
 	Close() error
 	ControllerConfig() (controller.Config, error)
+	ConfigSet(values map[string]interface{}, unset []string) error
 }
 
 func (c *getConfigCommand) getAPI() (controllerAPI, error) {
@@ -102,6 +121,18 @@ func (c *getConfigCommand) Run(ctx *cmd.Context) error {
 	}
 	defer client.Close()
 
+	if len(c.values) > 0 || c.reset != "" {
+		values := make(map[string]interface{})
+		for key, value := range c.values {
+			values[key] = value
+		}
+		var unset []string
+		if c.reset != "" {
+			unset = strings.Split(c.reset, ",")
+		}
+		return errors.Trace(client.ConfigSet(values, unset))
+	}
+
 	attrs, err := client.ControllerConfig()
 	if err != nil {
 		return err
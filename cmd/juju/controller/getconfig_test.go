@@ -83,6 +83,40 @@ func (s *GetConfigSuite) TestAllValuesJSON(c *gc.C) {
 	c.Assert(output, gc.Equals, expected)
 }
 
+func (s *GetConfigSuite) TestInitSet(c *gc.C) {
+	err := cmdtesting.InitCommand(controller.NewGetConfigCommandForTest(&fakeControllerAPI{}, s.store), []string{"one=1", "two"})
+	c.Check(err, gc.ErrorMatches, `expected "key=value", got "two"`)
+	err = cmdtesting.InitCommand(controller.NewGetConfigCommandForTest(&fakeControllerAPI{}, s.store), []string{"--reset", "one", "two"})
+	c.Check(err, gc.ErrorMatches, `unrecognized args: \["two"\]`)
+}
+
+func (s *GetConfigSuite) TestSetValues(c *gc.C) {
+	api := &fakeControllerAPI{}
+	command := controller.NewGetConfigCommandForTest(api, s.store)
+	_, err := cmdtesting.RunCommand(c, command, "firewall-panic-close=true", "firewall-essential-rules=22/tcp")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(api.values, jc.DeepEquals, map[string]interface{}{
+		"firewall-panic-close":     "true",
+		"firewall-essential-rules": "22/tcp",
+	})
+	c.Assert(api.unset, gc.HasLen, 0)
+}
+
+func (s *GetConfigSuite) TestResetValues(c *gc.C) {
+	api := &fakeControllerAPI{}
+	command := controller.NewGetConfigCommandForTest(api, s.store)
+	_, err := cmdtesting.RunCommand(c, command, "--reset", "firewall-panic-close,firewall-essential-rules")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(api.values, gc.HasLen, 0)
+	c.Assert(api.unset, jc.DeepEquals, []string{"firewall-panic-close", "firewall-essential-rules"})
+}
+
+func (s *GetConfigSuite) TestSetError(c *gc.C) {
+	command := controller.NewGetConfigCommandForTest(&fakeControllerAPI{err: errors.New("error")}, s.store)
+	_, err := cmdtesting.RunCommand(c, command, "firewall-panic-close=true")
+	c.Assert(err, gc.ErrorMatches, "error")
+}
+
 func (s *GetConfigSuite) TestError(c *gc.C) {
 	command := controller.NewGetConfigCommandForTest(&fakeControllerAPI{err: errors.New("error")}, s.store)
 	_, err := cmdtesting.RunCommand(c, command)
@@ -90,7 +124,9 @@ func (s *GetConfigSuite) TestError(c *gc.C) {
 }
 
 type fakeControllerAPI struct {
-	err error
+	err    error
+	values map[string]interface{}
+	unset  []string
 }
 
 func (f *fakeControllerAPI) Close() error {
@@ -107,3 +143,12 @@ func (f *fakeControllerAPI) ControllerConfig() (jujucontroller.Config, error) {
 		"ca-cert":         "multi\nline",
 	}, nil
 }
+
+func (f *fakeControllerAPI) ConfigSet(values map[string]interface{}, unset []string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.values = values
+	f.unset = unset
+	return nil
+}
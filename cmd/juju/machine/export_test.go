@@ -57,3 +57,9 @@ func NewRemoveCommandForTest(apiRoot api.Connection, machineAPI RemoveMachineAPI
 func NewDisksFlag(disks *[]storage.Constraints) *disksFlag {
 	return &disksFlag{disks}
 }
+
+// NewLockdownCommandForTest returns a lockdown-machine command with
+// the api provided as specified.
+func NewLockdownCommandForTest(api LockdownMachineAPI) cmd.Command {
+	return modelcmd.Wrap(&lockdownCommand{api: api})
+}
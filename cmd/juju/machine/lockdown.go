@@ -0,0 +1,128 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package machine
+
+import (
+	"github.com/juju/cmd"
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+	"gopkg.in/juju/names.v2"
+
+	"github.com/juju/juju/api/machinemanager"
+	"github.com/juju/juju/cmd/juju/block"
+	"github.com/juju/juju/cmd/modelcmd"
+)
+
+// NewLockdownCommand returns a command used to close all ports on a
+// machine, or to lift a previous lockdown.
+func NewLockdownCommand() cmd.Command {
+	return modelcmd.Wrap(&lockdownCommand{})
+}
+
+// LockdownMachineAPI defines the methods on the machine manager API
+// needed by the lockdown-machine command.
+type LockdownMachineAPI interface {
+	BestAPIVersion() int
+	SetFirewallLockdown(machineName string, lockdown bool) error
+	Close() error
+}
+
+// lockdownCommand sets or clears the firewall lockdown of a machine.
+type lockdownCommand struct {
+	modelcmd.ModelCommandBase
+	api       LockdownMachineAPI
+	MachineId string
+	Clear     bool
+}
+
+const lockdownMachineDoc = `
+Locking down a machine instructs the firewaller to close all ports on
+the machine's instance, regardless of the ports opened by the units
+deployed to it. The lockdown persists until it is cleared with the
+'--clear' option, at which point the ports opened by the units are
+restored.
+
+Lockdowns are only honoured when the model uses the "instance"
+firewall mode.
+
+Examples:
+
+Close all ports on machine 3:
+
+    juju lockdown-machine 3
+
+Restore the ports on machine 3:
+
+    juju lockdown-machine 3 --clear
+
+See also:
+    expose
+    remove-machine
+`
+
+// Info implements Command.Info.
+func (c *lockdownCommand) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "lockdown-machine",
+		Args:    "<machine number>",
+		Purpose: "Closes all ports on a machine until the lockdown is cleared.",
+		Doc:     lockdownMachineDoc,
+	}
+}
+
+// SetFlags implements Command.SetFlags.
+func (c *lockdownCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.ModelCommandBase.SetFlags(f)
+	f.BoolVar(&c.Clear, "clear", false, "Lift the lockdown and restore the machine's ports")
+}
+
+// Init implements Command.Init.
+func (c *lockdownCommand) Init(args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("no machine specified")
+	}
+	id, err := cmd.ZeroOrOneArgs(args)
+	if err != nil {
+		return err
+	}
+	if !names.IsValidMachine(id) {
+		return errors.Errorf("invalid machine id %q", id)
+	}
+	c.MachineId = id
+	return nil
+}
+
+func (c *lockdownCommand) getAPI() (LockdownMachineAPI, error) {
+	if c.api != nil {
+		return c.api, nil
+	}
+	root, err := c.NewAPIRoot()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return machinemanager.NewClient(root), nil
+}
+
+// Run implements Command.Run.
+func (c *lockdownCommand) Run(ctx *cmd.Context) error {
+	client, err := c.getAPI()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if client.BestAPIVersion() < 5 {
+		return errors.New("lockdown-machine is not supported by this version of Juju")
+	}
+	err = client.SetFirewallLockdown(c.MachineId, !c.Clear)
+	if err := block.ProcessBlockedError(err, block.BlockChange); err != nil {
+		return err
+	}
+	if c.Clear {
+		ctx.Infof("lifted firewall lockdown of machine %s", c.MachineId)
+	} else {
+		ctx.Infof("locked down firewall of machine %s", c.MachineId)
+	}
+	return nil
+}
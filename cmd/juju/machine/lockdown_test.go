@@ -0,0 +1,107 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package machine_test
+
+import (
+	"github.com/juju/cmd/cmdtesting"
+	"github.com/juju/errors"
+	jujutesting "github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/cmd/juju/machine"
+	"github.com/juju/juju/testing"
+)
+
+type LockdownMachineSuite struct {
+	testing.FakeJujuXDGDataHomeSuite
+	fake *fakeLockdownMachineAPI
+}
+
+var _ = gc.Suite(&LockdownMachineSuite{})
+
+func (s *LockdownMachineSuite) SetUpTest(c *gc.C) {
+	s.FakeJujuXDGDataHomeSuite.SetUpTest(c)
+	s.fake = &fakeLockdownMachineAPI{version: 5}
+}
+
+func (s *LockdownMachineSuite) TestInit(c *gc.C) {
+	for i, test := range []struct {
+		args        []string
+		errorString string
+	}{
+		{
+			errorString: "no machine specified",
+		}, {
+			args: []string{"1"},
+		}, {
+			args: []string{"1/lxd/2", "--clear"},
+		}, {
+			args:        []string{"lxd"},
+			errorString: `invalid machine id "lxd"`,
+		}, {
+			args:        []string{"1", "2"},
+			errorString: `unrecognized args: \["2"\]`,
+		},
+	} {
+		c.Logf("test %d", i)
+		err := cmdtesting.InitCommand(machine.NewLockdownCommandForTest(s.fake), test.args)
+		if test.errorString == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.errorString)
+		}
+	}
+}
+
+func (s *LockdownMachineSuite) TestLockdown(c *gc.C) {
+	ctx, err := cmdtesting.RunCommand(c, machine.NewLockdownCommandForTest(s.fake), "1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "locked down firewall of machine 1\n")
+	s.fake.CheckCalls(c, []jujutesting.StubCall{
+		{"BestAPIVersion", nil},
+		{"SetFirewallLockdown", []interface{}{"1", true}},
+		{"Close", nil},
+	})
+}
+
+func (s *LockdownMachineSuite) TestClear(c *gc.C) {
+	ctx, err := cmdtesting.RunCommand(c, machine.NewLockdownCommandForTest(s.fake), "1", "--clear")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "lifted firewall lockdown of machine 1\n")
+	s.fake.CheckCall(c, 1, "SetFirewallLockdown", "1", false)
+}
+
+func (s *LockdownMachineSuite) TestError(c *gc.C) {
+	s.fake.SetErrors(errors.New("boom"))
+	_, err := cmdtesting.RunCommand(c, machine.NewLockdownCommandForTest(s.fake), "1")
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
+
+func (s *LockdownMachineSuite) TestOldServer(c *gc.C) {
+	s.fake.version = 4
+	_, err := cmdtesting.RunCommand(c, machine.NewLockdownCommandForTest(s.fake), "1")
+	c.Assert(err, gc.ErrorMatches, "lockdown-machine is not supported by this version of Juju")
+	s.fake.CheckCallNames(c, "BestAPIVersion", "Close")
+}
+
+type fakeLockdownMachineAPI struct {
+	jujutesting.Stub
+	version int
+}
+
+func (f *fakeLockdownMachineAPI) BestAPIVersion() int {
+	f.MethodCall(f, "BestAPIVersion")
+	return f.version
+}
+
+func (f *fakeLockdownMachineAPI) SetFirewallLockdown(machineName string, lockdown bool) error {
+	f.MethodCall(f, "SetFirewallLockdown", machineName, lockdown)
+	return f.NextErr()
+}
+
+func (f *fakeLockdownMachineAPI) Close() error {
+	f.MethodCall(f, "Close")
+	return nil
+}
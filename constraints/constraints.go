@@ -32,6 +32,7 @@ const (
 	InstanceType = "instance-type"
 	Spaces       = "spaces"
 	VirtType     = "virt-type"
+	Accelerator  = "accelerator"
 )
 
 // Value describes a user's requirements of the hardware on which units
@@ -85,6 +86,11 @@ type Value struct {
 	// VirtType, if not nil or empty, indicates that a machine must run the named
 	// virtual type. Only valid for clouds with multi-hypervisor support.
 	VirtType *string `json:"virt-type,omitempty" yaml:"virt-type,omitempty"`
+
+	// Accelerator, if not nil or empty, indicates that a machine must
+	// have a hardware accelerator of the named kind (such as "gpu") or
+	// model. Only valid for clouds with accelerated instance types.
+	Accelerator *string `json:"accelerator,omitempty" yaml:"accelerator,omitempty"`
 }
 
 var rawAliases = map[string]string{
@@ -180,6 +186,12 @@ func (v *Value) HasVirtType() bool {
 	return v.VirtType != nil && *v.VirtType != ""
 }
 
+// HasAccelerator returns true if the constraints.Value specifies an
+// accelerator.
+func (v *Value) HasAccelerator() bool {
+	return v.Accelerator != nil && *v.Accelerator != ""
+}
+
 // String expresses a constraints.Value in the language in which it was specified.
 func (v Value) String() string {
 	var strs []string
@@ -223,6 +235,9 @@ func (v Value) String() string {
 	if v.VirtType != nil {
 		strs = append(strs, "virt-type="+string(*v.VirtType))
 	}
+	if v.Accelerator != nil {
+		strs = append(strs, "accelerator="+*v.Accelerator)
+	}
 	return strings.Join(strs, " ")
 }
 
@@ -264,6 +279,9 @@ func (v Value) GoString() string {
 	if v.VirtType != nil {
 		values = append(values, fmt.Sprintf("VirtType: %q", *v.VirtType))
 	}
+	if v.Accelerator != nil {
+		values = append(values, fmt.Sprintf("Accelerator: %q", *v.Accelerator))
+	}
 	return fmt.Sprintf("{%s}", strings.Join(values, ", "))
 }
 
@@ -420,6 +438,8 @@ func (v *Value) setRaw(name, str string) error {
 		err = v.setSpaces(str)
 	case VirtType:
 		err = v.setVirtType(str)
+	case Accelerator:
+		err = v.setAccelerator(str)
 	default:
 		return errors.Errorf("unknown constraint %q", name)
 	}
@@ -483,6 +503,8 @@ func (v *Value) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			}
 		case VirtType:
 			v.VirtType = &vstr
+		case Accelerator:
+			v.Accelerator = &vstr
 		default:
 			return errors.Errorf("unknown constraint value: %v", k)
 		}
@@ -607,6 +629,14 @@ func (v *Value) setVirtType(str string) error {
 	return nil
 }
 
+func (v *Value) setAccelerator(str string) error {
+	if v.Accelerator != nil {
+		return errors.Errorf("already set")
+	}
+	v.Accelerator = &str
+	return nil
+}
+
 func parseUint64(str string) (*uint64, error) {
 	var value uint64
 	if str != "" {
@@ -313,6 +313,19 @@ var parseConstraintsTests = []struct {
 		err:     `bad "virt-type" constraint: already set`,
 	},
 
+	// "accelerator" in detail.
+	{
+		summary: "set accelerator empty",
+		args:    []string{"accelerator="},
+	}, {
+		summary: "set accelerator gpu",
+		args:    []string{"accelerator=gpu"},
+	}, {
+		summary: "double set accelerator together",
+		args:    []string{"accelerator=gpu accelerator=gpu"},
+		err:     `bad "accelerator" constraint: already set`,
+	},
+
 	// Everything at once.
 	{
 		summary: "kitchen sink together",
@@ -510,6 +523,8 @@ var constraintsRoundtripTests = []roundTrip{
 	{"Spaces3", constraints.Value{Spaces: &[]string{"space1", "^space2"}}},
 	{"InstanceType1", constraints.Value{InstanceType: strp("")}},
 	{"InstanceType2", constraints.Value{InstanceType: strp("foo")}},
+	{"Accelerator1", constraints.Value{Accelerator: strp("")}},
+	{"Accelerator2", constraints.Value{Accelerator: strp("gpu")}},
 	{"All", constraints.Value{
 		Arch:         strp("i386"),
 		Container:    ctypep("lxd"),
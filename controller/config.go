@@ -6,15 +6,19 @@ package controller
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/utils"
 	utilscert "github.com/juju/utils/cert"
+	"github.com/juju/utils/keyvalues"
 	"gopkg.in/macaroon-bakery.v1/bakery"
 
 	"github.com/juju/juju/cert"
+	"github.com/juju/juju/environs/tags"
+	"github.com/juju/juju/network"
 )
 
 const (
@@ -82,6 +86,27 @@ const (
 	// MaxTxnLogSize is the maximum size the of capped txn log collection, eg "10M"
 	MaxTxnLogSize = "max-txn-log-size"
 
+	// DefaultResourceTagsKey is an optional space-separated string
+	// of k=v pairs, defining tags to set on every resource Juju
+	// creates in any of the controller's models. Each model's own
+	// resource-tags take precedence over them.
+	DefaultResourceTagsKey = "default-resource-tags"
+
+	// FirewallPanicClose, when true, closes all ingress on every
+	// machine in every model of the controller, except for the
+	// FirewallEssentialRules. It may be changed while the controller
+	// is running, with "juju controller-config", and is cleared again
+	// to restore ingress. A model's firewall-panic-close-override
+	// takes precedence over it.
+	FirewallPanicClose = "firewall-panic-close"
+
+	// FirewallEssentialRules is a comma-separated list of the ingress
+	// rules that remain open while FirewallPanicClose is set. Each rule
+	// is a port range, optionally followed by @ and the CIDR from which
+	// traffic is allowed, eg "22/tcp@10.0.0.0/8". A model's
+	// firewall-essential-rules-override takes precedence over it.
+	FirewallEssentialRules = "firewall-essential-rules"
+
 	// Attribute Defaults
 
 	// DefaultAuditingEnabled contains the default value for the
@@ -129,6 +154,16 @@ var ControllerOnlyConfigAttributes = []string{
 	MaxLogsSize,
 	MaxLogsAge,
 	MaxTxnLogSize,
+	DefaultResourceTagsKey,
+	FirewallPanicClose,
+	FirewallEssentialRules,
+}
+
+// MutableConfigAttributes are the controller attributes that may be
+// changed after the controller has been bootstrapped.
+var MutableConfigAttributes = []string{
+	FirewallPanicClose,
+	FirewallEssentialRules,
 }
 
 // ControllerOnlyAttribute returns true if the specified attribute name
@@ -142,6 +177,17 @@ func ControllerOnlyAttribute(attr string) bool {
 	return false
 }
 
+// MutableAttribute returns true if the specified controller attribute
+// may be changed after the controller has been bootstrapped.
+func MutableAttribute(attr string) bool {
+	for _, a := range MutableConfigAttributes {
+		if attr == a {
+			return true
+		}
+	}
+	return false
+}
+
 type Config map[string]interface{}
 
 // Validate validates the controller configuration.
@@ -313,6 +359,97 @@ func (c Config) MaxTxnLogSizeMB() int {
 	return int(val)
 }
 
+// DefaultResourceTags returns the tags to set on every resource Juju
+// creates in any of the controller's models, beneath each model's own
+// resource tags.
+func (c Config) DefaultResourceTags() map[string]string {
+	// Value has already been validated.
+	tags, _ := parseResourceTags(c.asString(DefaultResourceTagsKey))
+	return tags
+}
+
+// ResourceTags is part of the tags.ResourceTagger interface. It
+// returns the controller's default resource tags.
+func (c Config) ResourceTags() (map[string]string, bool) {
+	tags := c.DefaultResourceTags()
+	return tags, len(tags) > 0
+}
+
+// FirewallPanicClose returns whether all ingress, apart from the
+// essential firewall rules, is closed on every machine in every model
+// of the controller.
+func (c Config) FirewallPanicClose() bool {
+	value, _ := c[FirewallPanicClose].(bool)
+	return value
+}
+
+// FirewallEssentialRules returns the ingress rules, sorted, that
+// remain open while FirewallPanicClose is set.
+func (c Config) FirewallEssentialRules() []network.IngressRule {
+	// Value has already been validated.
+	rules, _ := ParseFirewallRules(c.asString(FirewallEssentialRules))
+	return rules
+}
+
+// ParseFirewallRules parses a comma-separated list of ingress rules,
+// each of which is a port range, optionally followed by @ and the CIDR
+// from which traffic is allowed, eg "22/tcp@10.0.0.0/8". The rules are
+// returned sorted.
+func ParseFirewallRules(s string) ([]network.IngressRule, error) {
+	var rules []network.IngressRule
+	for _, rawRule := range strings.Split(s, ",") {
+		rawRule = strings.TrimSpace(rawRule)
+		if rawRule == "" {
+			continue
+		}
+		rule, err := ParseFirewallRule(rawRule)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		rules = append(rules, rule)
+	}
+	network.SortIngressRules(rules)
+	return rules, nil
+}
+
+// ParseFirewallRule parses a single ingress rule, which is a port
+// range, optionally followed by @ and the CIDR from which traffic is
+// allowed.
+func ParseFirewallRule(rawRule string) (network.IngressRule, error) {
+	parts := strings.SplitN(rawRule, "@", 2)
+	portRange, err := network.ParsePortRange(parts[0])
+	if err != nil {
+		return network.IngressRule{}, errors.Annotatef(err, "invalid rule %q", rawRule)
+	}
+	var sourceCIDRs []string
+	if len(parts) == 2 {
+		sourceCIDRs = []string{parts[1]}
+	}
+	rule, err := network.NewIngressRule(portRange.Protocol, portRange.FromPort, portRange.ToPort, sourceCIDRs...)
+	if err != nil {
+		return network.IngressRule{}, errors.Annotatef(err, "invalid rule %q", rawRule)
+	}
+	return rule, nil
+}
+
+// parseResourceTags parses a space-separated string of k=v pairs,
+// rejecting keys with the prefix reserved for Juju's own tags.
+func parseResourceTags(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	resourceTags, err := keyvalues.Parse(strings.Fields(s), true)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for k := range resourceTags {
+		if strings.HasPrefix(k, tags.JujuTagPrefix) {
+			return nil, errors.Errorf("tag %q uses reserved prefix %q", k, tags.JujuTagPrefix)
+		}
+	}
+	return resourceTags, nil
+}
+
 // Validate ensures that config is a valid configuration.
 func Validate(c Config) error {
 	if v, ok := c[IdentityPublicKey].(string); ok {
@@ -372,6 +509,18 @@ func Validate(c Config) error {
 		}
 	}
 
+	if v, ok := c[DefaultResourceTagsKey].(string); ok {
+		if _, err := parseResourceTags(v); err != nil {
+			return errors.Annotate(err, "invalid default resource tags in configuration")
+		}
+	}
+
+	if v, ok := c[FirewallEssentialRules].(string); ok {
+		if _, err := ParseFirewallRules(v); err != nil {
+			return errors.Annotate(err, "invalid firewall essential rules in configuration")
+		}
+	}
+
 	return nil
 }
 
@@ -395,6 +544,9 @@ var configChecker = schema.FieldMap(schema.Fields{
 	MaxLogsAge:              schema.String(),
 	MaxLogsSize:             schema.String(),
 	MaxTxnLogSize:           schema.String(),
+	DefaultResourceTagsKey:  schema.String(),
+	FirewallPanicClose:      schema.Bool(),
+	FirewallEssentialRules:  schema.String(),
 }, schema.Defaults{
 	APIPort:                 DefaultAPIPort,
 	AuditingEnabled:         DefaultAuditingEnabled,
@@ -409,4 +561,7 @@ var configChecker = schema.FieldMap(schema.Fields{
 	MaxLogsAge:              fmt.Sprintf("%vh", DefaultMaxLogsAgeDays*24),
 	MaxLogsSize:             fmt.Sprintf("%vM", DefaultMaxLogCollectionMB),
 	MaxTxnLogSize:           fmt.Sprintf("%vM", DefaultMaxTxnLogCollectionMB),
+	DefaultResourceTagsKey:  schema.Omit,
+	FirewallPanicClose:      schema.Omit,
+	FirewallEssentialRules:  schema.Omit,
 })
@@ -121,6 +121,26 @@ var validateTests = []struct {
 		controller.CACertKey:         testing.CACert,
 	},
 	expectError: `invalid identity public key: wrong length for base64 key, got 3 want 32`,
+}, {
+	about: "default resource tags OK",
+	config: controller.Config{
+		controller.DefaultResourceTagsKey: "Environment=prod CostCentre=",
+		controller.CACertKey:              testing.CACert,
+	},
+}, {
+	about: "malformed default resource tags",
+	config: controller.Config{
+		controller.DefaultResourceTagsKey: "Environment",
+		controller.CACertKey:              testing.CACert,
+	},
+	expectError: `invalid default resource tags in configuration: expected "key=value", got "Environment"`,
+}, {
+	about: "reserved default resource tags",
+	config: controller.Config{
+		controller.DefaultResourceTagsKey: "Environment=prod juju-model-uuid=foo",
+		controller.CACertKey:              testing.CACert,
+	},
+	expectError: `invalid default resource tags in configuration: tag "juju-model-uuid" uses reserved prefix "juju-"`,
 }}
 
 func (s *ConfigSuite) TestValidate(c *gc.C) {
@@ -173,3 +193,27 @@ func (s *ConfigSuite) TestTxnLogConfigValue(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(cfg.MaxTxnLogSizeMB(), gc.Equals, 8192)
 }
+
+func (s *ConfigSuite) TestDefaultResourceTagsDefault(c *gc.C) {
+	cfg, err := controller.NewConfig(testing.ControllerTag.Id(), testing.CACert, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cfg.DefaultResourceTags(), gc.HasLen, 0)
+	_, ok := cfg.ResourceTags()
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *ConfigSuite) TestDefaultResourceTagsValue(c *gc.C) {
+	cfg, err := controller.NewConfig(
+		testing.ControllerTag.Id(),
+		testing.CACert,
+		map[string]interface{}{
+			"default-resource-tags": "Environment=prod CostCentre=42",
+		},
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	expected := map[string]string{"Environment": "prod", "CostCentre": "42"}
+	c.Assert(cfg.DefaultResourceTags(), jc.DeepEquals, expected)
+	resourceTags, ok := cfg.ResourceTags()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(resourceTags, jc.DeepEquals, expected)
+}
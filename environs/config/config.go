@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/juju/juju/environs/tags"
 	"github.com/juju/juju/juju/osenv"
 	"github.com/juju/juju/logfwd/syslog"
+	"github.com/juju/juju/network"
 )
 
 var logger = loggo.GetLogger("juju.environs.config")
@@ -165,6 +167,72 @@ const (
 	// originates if the model is deployed such that NAT or similar is in use.
 	EgressSubnets = "egress-subnets"
 
+	// FirewallRuleGroups defines named groups of ingress rules, as a
+	// space-separated list of name=rule[,rule...] entries. Each rule is
+	// a port range, optionally followed by @ and the CIDR from which
+	// traffic is allowed, eg
+	// "monitoring=9100/tcp,9090-9091/tcp@10.0.0.0/8 ping=8/icmp".
+	FirewallRuleGroups = "firewall-rule-groups"
+
+	// EnabledFirewallRuleGroups is a comma-separated list of the names
+	// of the firewall rule groups whose rules are opened on every
+	// machine in the model. A name may be followed by @ and an RFC 3339
+	// time, after which the group's rules are closed again, eg
+	// "monitoring,ssh@2017-06-01T18:00:00Z".
+	EnabledFirewallRuleGroups = "enabled-firewall-rule-groups"
+
+	// IngressAddressFamilies is a comma-separated list of the address
+	// families, "ipv4" and "ipv6", for which the firewaller opens
+	// ingress rules. Ingress from addresses of other families is
+	// denied, even to exposed applications. When unset, no address
+	// family is denied.
+	IngressAddressFamilies = "ingress-address-families"
+
+	// FirewallPanicCloseOverride, when set, overrides the controller's
+	// firewall-panic-close for the model: when true, all ingress on
+	// every machine is closed except for the essential rules, and when
+	// false, ingress is left open whatever the controller's setting.
+	// When unset, the controller's setting applies.
+	FirewallPanicCloseOverride = "firewall-panic-close-override"
+
+	// FirewallEssentialRulesOverride, when set, overrides the
+	// controller's firewall-essential-rules for the model. It is a
+	// comma-separated list of the ingress rules that remain open during
+	// a panic close, in the same form as the controller's.
+	FirewallEssentialRulesOverride = "firewall-essential-rules-override"
+
+	// ControllerAdminCIDRs is a comma-separated list of the CIDRs from
+	// which the controller's API and SSH ports may be reached. The
+	// ports are open to all sources when the controller is
+	// bootstrapped, and the firewaller restricts them to these CIDRs
+	// once they are set, for all models in a controller at once with
+	// "juju model-defaults". They must include the address of the
+	// client setting them, so that it is not locked out.
+	ControllerAdminCIDRs = "controller-admin-cidrs"
+
+	// FirewallDecisionLogging, when true, makes the firewaller log at
+	// debug level why each port range is or is not opened on each
+	// machine.
+	FirewallDecisionLogging = "firewall-decision-logging"
+
+	// FirewallFreezeWindows is a comma-separated list of weekly windows
+	// of time, in UTC, during which the firewaller defers all changes
+	// to the firewall, applying only the latest of them once the window
+	// has passed. Each window is a day of the week or range of days,
+	// followed by the times of day at which it starts and ends, eg
+	// "mon-fri 09:00-17:00,sat 22:00-02:00". A window that ends at or
+	// before the time it starts ends on the following day. Setting
+	// a panic close takes effect even during a freeze.
+	FirewallFreezeWindows = "firewall-freeze-windows"
+
+	// FirewallOpenPortsTag is the name of a tag that the firewaller
+	// maintains on each machine's instance, if the provider supports
+	// tagging instances, summarising the port ranges currently open on
+	// it, eg "80/tcp,443/tcp", for discovery by external tools. The
+	// tag is removed from instances with no open ports. If empty, no
+	// such tag is maintained.
+	FirewallOpenPortsTag = "firewall-open-ports-tag"
+
 	//
 	// Deprecated Settings Attributes
 	//
@@ -327,6 +395,11 @@ const (
 	DefaultActionResultsAge = "336h" // 2 weeks
 
 	DefaultActionResultsSize = "5G"
+
+	// maxFirewallOpenPortsTagLength is the longest name that
+	// FirewallOpenPortsTag may have, that of the longest tag key
+	// accepted by the major clouds.
+	maxFirewallOpenPortsTagLength = 128
 )
 
 var defaultConfigValues = map[string]interface{}{
@@ -368,6 +441,13 @@ var defaultConfigValues = map[string]interface{}{
 	TransmitVendorMetricsKey:   true,
 	UpdateStatusHookInterval:   DefaultUpdateStatusHookInterval,
 	EgressSubnets:              "",
+	FirewallRuleGroups:         "",
+	EnabledFirewallRuleGroups:  "",
+	IngressAddressFamilies:     "",
+	ControllerAdminCIDRs:       "",
+	FirewallDecisionLogging:    false,
+	FirewallFreezeWindows:      "",
+	FirewallOpenPortsTag:       "",
 
 	// Image and agent streams and URLs.
 	"image-stream":       "released",
@@ -561,6 +641,35 @@ func Validate(cfg, old *Config) error {
 		}
 	}
 
+	if _, err := cfg.firewallRuleGroups(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := cfg.ingressAddressFamilies(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, _, err := cfg.firewallEssentialRulesOverride(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := cfg.controllerAdminCIDRs(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := cfg.firewallFreezeWindows(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if tag := cfg.asString(FirewallOpenPortsTag); tag != "" {
+		if strings.TrimSpace(tag) != tag {
+			return errors.Errorf("%s: %q must not start or end with spaces", FirewallOpenPortsTag, tag)
+		}
+		if len(tag) > maxFirewallOpenPortsTagLength {
+			return errors.Errorf("%s: %q is longer than %d characters", FirewallOpenPortsTag, tag, maxFirewallOpenPortsTagLength)
+		}
+	}
+
 	// Check the immutable config values.  These can't change
 	if old != nil {
 		for _, attr := range immutableAttributes {
@@ -1042,6 +1151,434 @@ func (c *Config) EgressSubnets() []string {
 	return result
 }
 
+const (
+	// IPv4AddressFamily identifies IPv4 in IngressAddressFamilies.
+	IPv4AddressFamily = "ipv4"
+
+	// IPv6AddressFamily identifies IPv6 in IngressAddressFamilies.
+	IPv6AddressFamily = "ipv6"
+)
+
+// IngressAddressFamilies returns the address families for which the
+// firewaller opens ingress rules, or nil if no address family is
+// denied.
+func (c *Config) IngressAddressFamilies() []string {
+	families, err := c.ingressAddressFamilies()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	return families
+}
+
+// ingressAddressFamilies parses the ingress address families; none
+// are returned when the setting is unset.
+func (c *Config) ingressAddressFamilies() ([]string, error) {
+	raw := c.asString(IngressAddressFamilies)
+	if raw == "" {
+		return nil, nil
+	}
+	var families []string
+	seen := make(map[string]bool)
+	for _, family := range strings.Split(raw, ",") {
+		family = strings.ToLower(strings.TrimSpace(family))
+		switch family {
+		case IPv4AddressFamily, IPv6AddressFamily:
+		default:
+			return nil, errors.NotValidf("ingress address family %q", family)
+		}
+		if !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	return families, nil
+}
+
+// FirewallRuleGroups returns the ingress rules of each of the firewall
+// rule groups defined in the model, keyed by group name.
+func (c *Config) FirewallRuleGroups() map[string][]network.IngressRule {
+	groups, err := c.firewallRuleGroups()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	return groups
+}
+
+// EnabledFirewallRuleGroups returns the names of the firewall rule
+// groups whose rules are opened on every machine in the model,
+// including those enabled until a time that may have passed.
+func (c *Config) EnabledFirewallRuleGroups() []string {
+	names, _, err := c.enabledFirewallRuleGroups()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	return names
+}
+
+// FirewallRuleGroupExpiries returns the time until which each of the
+// firewall rule groups enabled for a limited time is enabled, keyed by
+// group name.
+func (c *Config) FirewallRuleGroupExpiries() map[string]time.Time {
+	_, expiries, err := c.enabledFirewallRuleGroups()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	return expiries
+}
+
+// EnabledFirewallRules returns the ingress rules, sorted, of the
+// firewall rule groups that are enabled at the given time. It also
+// returns the time at which the next of those groups expires, or the
+// zero time if none of them do.
+func (c *Config) EnabledFirewallRules(now time.Time) ([]network.IngressRule, time.Time) {
+	groups := c.FirewallRuleGroups()
+	expiries := c.FirewallRuleGroupExpiries()
+	var rules []network.IngressRule
+	var nextExpiry time.Time
+	for _, name := range c.EnabledFirewallRuleGroups() {
+		if expiry, ok := expiries[name]; ok {
+			if !expiry.After(now) {
+				continue
+			}
+			if nextExpiry.IsZero() || expiry.Before(nextExpiry) {
+				nextExpiry = expiry
+			}
+		}
+		rules = append(rules, groups[name]...)
+	}
+	network.SortIngressRules(rules)
+	return rules, nextExpiry
+}
+
+// enabledFirewallRuleGroups parses the enabled firewall rule groups,
+// returning their names and the times at which those enabled for a
+// limited time expire.
+func (c *Config) enabledFirewallRuleGroups() ([]string, map[string]time.Time, error) {
+	var names []string
+	expiries := make(map[string]time.Time)
+	for _, entry := range strings.Split(c.asString(EnabledFirewallRuleGroups), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "@", 2)
+		name := parts[0]
+		for _, existing := range names {
+			if existing == name {
+				return nil, nil, errors.Errorf("firewall rule group %q enabled more than once", name)
+			}
+		}
+		if len(parts) == 2 {
+			expiry, err := time.Parse(time.RFC3339, parts[1])
+			if err != nil {
+				return nil, nil, errors.Annotatef(err, "enabled firewall rule group %q", name)
+			}
+			expiries[name] = expiry
+		}
+		names = append(names, name)
+	}
+	return names, expiries, nil
+}
+
+var firewallRuleGroupName = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_-]*$")
+
+// firewallRuleGroups parses the firewall rule groups, and checks that
+// the enabled groups are all defined.
+func (c *Config) firewallRuleGroups() (map[string][]network.IngressRule, error) {
+	groups := make(map[string][]network.IngressRule)
+	for _, entry := range strings.Fields(c.asString(FirewallRuleGroups)) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !firewallRuleGroupName.MatchString(parts[0]) {
+			return nil, errors.NotValidf("firewall rule group %q", entry)
+		}
+		name := parts[0]
+		if _, ok := groups[name]; ok {
+			return nil, errors.Errorf("firewall rule group %q defined more than once", name)
+		}
+		var rules []network.IngressRule
+		for _, rawRule := range strings.Split(parts[1], ",") {
+			rule, err := controller.ParseFirewallRule(rawRule)
+			if err != nil {
+				return nil, errors.Annotatef(err, "firewall rule group %q", name)
+			}
+			rules = append(rules, rule)
+		}
+		groups[name] = rules
+	}
+	enabled, _, err := c.enabledFirewallRuleGroups()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, name := range enabled {
+		if _, ok := groups[name]; !ok {
+			return nil, errors.NotFoundf("enabled firewall rule group %q", name)
+		}
+	}
+	return groups, nil
+}
+
+// FirewallPanicCloseOverride returns whether all ingress, apart from
+// the essential firewall rules, is closed on every machine in the
+// model, and whether this is set for the model at all. When it is not,
+// the controller's firewall-panic-close applies.
+func (c *Config) FirewallPanicCloseOverride() (bool, bool) {
+	value, ok := c.defined[FirewallPanicCloseOverride].(bool)
+	return value, ok
+}
+
+// FirewallOpenPortsTag returns the name of the tag in which the port
+// ranges open on each machine's instance are summarised, or "" if no
+// such tag is maintained.
+func (c *Config) FirewallOpenPortsTag() string {
+	return c.asString(FirewallOpenPortsTag)
+}
+
+// FirewallDecisionLogging returns whether the firewaller logs why each
+// port range is or is not opened on each machine.
+func (c *Config) FirewallDecisionLogging() bool {
+	value, _ := c.defined[FirewallDecisionLogging].(bool)
+	return value
+}
+
+// FirewallEssentialRulesOverride returns the ingress rules, sorted,
+// that remain open on the model's machines during a panic close, and
+// whether they are set for the model at all. When they are not, the
+// controller's firewall-essential-rules apply.
+func (c *Config) FirewallEssentialRulesOverride() ([]network.IngressRule, bool) {
+	rules, ok, err := c.firewallEssentialRulesOverride()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	return rules, ok
+}
+
+// firewallEssentialRulesOverride parses the model's essential firewall
+// rules.
+func (c *Config) firewallEssentialRulesOverride() ([]network.IngressRule, bool, error) {
+	raw, ok := c.defined[FirewallEssentialRulesOverride].(string)
+	if !ok {
+		return nil, false, nil
+	}
+	rules, err := controller.ParseFirewallRules(raw)
+	if err != nil {
+		return nil, false, errors.Annotate(err, "firewall essential rules override")
+	}
+	return rules, true, nil
+}
+
+// ControllerAdminCIDRs returns the CIDRs from which the controller's
+// API and SSH ports may be reached, or nil if they are open to all
+// sources.
+func (c *Config) ControllerAdminCIDRs() []string {
+	cidrs, err := c.controllerAdminCIDRs()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	return cidrs
+}
+
+// controllerAdminCIDRs parses the controller admin CIDRs.
+func (c *Config) controllerAdminCIDRs() ([]string, error) {
+	return ParseControllerAdminCIDRs(c.asString(ControllerAdminCIDRs))
+}
+
+// ParseControllerAdminCIDRs parses the given value of
+// controller-admin-cidrs.
+func ParseControllerAdminCIDRs(value string) ([]string, error) {
+	var cidrs []string
+	for _, cidr := range strings.Split(value, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, errors.Errorf("controller admin CIDR %q not valid", cidr)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// CheckControllerAdminCIDRs returns an error if the given address,
+// of a client setting the given controller admin CIDRs, is not in
+// any of them, as the client would then be locked out of the
+// controller. There is no restriction, and so no error, if there are
+// no CIDRs.
+func CheckControllerAdminCIDRs(cidrs []string, clientAddress string) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+	host := clientAddress
+	if h, _, err := net.SplitHostPort(clientAddress); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("cannot check controller admin CIDRs: client address %q not valid", clientAddress)
+	}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Errorf("controller admin CIDR %q not valid", cidr)
+		}
+		if ipNet.Contains(ip) {
+			return nil
+		}
+	}
+	return errors.Errorf(
+		"controller admin CIDRs %s do not include this client's address %s, which would be locked out of the controller",
+		strings.Join(cidrs, ","), ip,
+	)
+}
+
+// FirewallFrozen returns whether firewall changes are deferred at the
+// given time, as it is in one of the firewall freeze windows. It also
+// returns the time at which that next changes, or the zero time if
+// there are no freeze windows.
+func (c *Config) FirewallFrozen(now time.Time) (bool, time.Time) {
+	windows, err := c.firewallFreezeWindows()
+	if err != nil {
+		panic(err) // should be prevented by Validate
+	}
+	if len(windows) == 0 {
+		return false, time.Time{}
+	}
+	now = now.UTC()
+	year, month, day := now.Date()
+	weekStart := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -int(now.Weekday()))
+
+	// The windows of the previous and next weeks are included, as
+	// windows may span the end of a week, and the next window to
+	// start may be in the next week.
+	type interval struct{ start, end time.Time }
+	var intervals []interval
+	for week := -1; week <= 1; week++ {
+		base := weekStart.AddDate(0, 0, 7*week)
+		for _, w := range windows {
+			for _, d := range w.days {
+				start := base.AddDate(0, 0, int(d)).Add(w.start)
+				end := base.AddDate(0, 0, int(d)).Add(w.end)
+				if w.end <= w.start {
+					end = end.AddDate(0, 0, 1)
+				}
+				intervals = append(intervals, interval{start, end})
+			}
+		}
+	}
+
+	// When frozen, the freeze lasts until the end of the last of any
+	// overlapping windows.
+	var frozenUntil time.Time
+	for extended := true; extended; {
+		extended = false
+		at := now
+		if !frozenUntil.IsZero() {
+			at = frozenUntil
+		}
+		for _, i := range intervals {
+			if !at.Before(i.start) && at.Before(i.end) && i.end.After(frozenUntil) {
+				frozenUntil = i.end
+				extended = true
+			}
+		}
+	}
+	if !frozenUntil.IsZero() {
+		return true, frozenUntil
+	}
+	var next time.Time
+	for _, i := range intervals {
+		if i.start.After(now) && (next.IsZero() || i.start.Before(next)) {
+			next = i.start
+		}
+	}
+	return false, next
+}
+
+// firewallFreezeWindow is a weekly window of time, in UTC, during
+// which firewall changes are deferred.
+type firewallFreezeWindow struct {
+	// days holds the days of the week on which the window starts.
+	days []time.Weekday
+	// start and end are the times of day at which the window starts
+	// and ends; it ends on the following day if end is not after
+	// start.
+	start, end time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// firewallFreezeWindows parses the firewall freeze windows.
+func (c *Config) firewallFreezeWindows() ([]firewallFreezeWindow, error) {
+	var windows []firewallFreezeWindow
+	for _, entry := range strings.Split(c.asString(FirewallFreezeWindows), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		window, err := parseFirewallFreezeWindow(entry)
+		if err != nil {
+			return nil, errors.Annotatef(err, "firewall freeze window %q", entry)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseFirewallFreezeWindow parses a firewall freeze window, which is a
+// day of the week or range of days followed by a range of times of day,
+// eg "mon-fri 09:00-17:00".
+func parseFirewallFreezeWindow(entry string) (firewallFreezeWindow, error) {
+	fields := strings.Fields(entry)
+	if len(fields) != 2 {
+		return firewallFreezeWindow{}, errors.New(`expected days and times, eg "mon-fri 09:00-17:00"`)
+	}
+	var window firewallFreezeWindow
+	dayRange := strings.SplitN(strings.ToLower(fields[0]), "-", 2)
+	first, ok := weekdays[dayRange[0]]
+	if !ok {
+		return firewallFreezeWindow{}, errors.NotValidf("day %q", dayRange[0])
+	}
+	last := first
+	if len(dayRange) == 2 {
+		if last, ok = weekdays[dayRange[1]]; !ok {
+			return firewallFreezeWindow{}, errors.NotValidf("day %q", dayRange[1])
+		}
+	}
+	// A range of days may wrap around the end of the week, as in
+	// "fri-mon".
+	for d := first; ; d = (d + 1) % 7 {
+		window.days = append(window.days, d)
+		if d == last {
+			break
+		}
+	}
+	timeRange := strings.SplitN(fields[1], "-", 2)
+	if len(timeRange) != 2 {
+		return firewallFreezeWindow{}, errors.Errorf("expected a start and end time, eg %q", "09:00-17:00")
+	}
+	var times [2]time.Duration
+	for i, t := range timeRange {
+		parsed, err := time.Parse("15:04", t)
+		if err != nil {
+			return firewallFreezeWindow{}, errors.NotValidf("time %q", t)
+		}
+		times[i] = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	window.start, window.end = times[0], times[1]
+	if window.start == window.end {
+		return firewallFreezeWindow{}, errors.New("start and end times must differ")
+	}
+	return window, nil
+}
+
 // UnknownAttrs returns a copy of the raw configuration attributes
 // that are supposedly specific to the environment type. They could
 // also be wrong attributes, though. Only the specific environment
@@ -1117,42 +1654,51 @@ var alwaysOptional = schema.Defaults{
 	// Environ providers will specify their own defaults.
 	StorageDefaultBlockSourceKey: schema.Omit,
 
-	"firewall-mode":              schema.Omit,
-	"logging-config":             schema.Omit,
-	ProvisionerHarvestModeKey:    schema.Omit,
-	HTTPProxyKey:                 schema.Omit,
-	HTTPSProxyKey:                schema.Omit,
-	FTPProxyKey:                  schema.Omit,
-	NoProxyKey:                   schema.Omit,
-	AptHTTPProxyKey:              schema.Omit,
-	AptHTTPSProxyKey:             schema.Omit,
-	AptFTPProxyKey:               schema.Omit,
-	AptNoProxyKey:                schema.Omit,
-	"apt-mirror":                 schema.Omit,
-	AgentStreamKey:               schema.Omit,
-	ResourceTagsKey:              schema.Omit,
-	"cloudimg-base-url":          schema.Omit,
-	"enable-os-refresh-update":   schema.Omit,
-	"enable-os-upgrade":          schema.Omit,
-	"image-stream":               schema.Omit,
-	"image-metadata-url":         schema.Omit,
-	AgentMetadataURLKey:          schema.Omit,
-	"default-series":             schema.Omit,
-	"development":                schema.Omit,
-	"ssl-hostname-verification":  schema.Omit,
-	"proxy-ssh":                  schema.Omit,
-	"disable-network-management": schema.Omit,
-	IgnoreMachineAddresses:       schema.Omit,
-	AutomaticallyRetryHooks:      schema.Omit,
-	"test-mode":                  schema.Omit,
-	TransmitVendorMetricsKey:     schema.Omit,
-	NetBondReconfigureDelayKey:   schema.Omit,
-	MaxStatusHistoryAge:          schema.Omit,
-	MaxStatusHistorySize:         schema.Omit,
-	MaxActionResultsAge:          schema.Omit,
-	MaxActionResultsSize:         schema.Omit,
-	UpdateStatusHookInterval:     schema.Omit,
-	EgressSubnets:                schema.Omit,
+	"firewall-mode":                schema.Omit,
+	"logging-config":               schema.Omit,
+	ProvisionerHarvestModeKey:      schema.Omit,
+	HTTPProxyKey:                   schema.Omit,
+	HTTPSProxyKey:                  schema.Omit,
+	FTPProxyKey:                    schema.Omit,
+	NoProxyKey:                     schema.Omit,
+	AptHTTPProxyKey:                schema.Omit,
+	AptHTTPSProxyKey:               schema.Omit,
+	AptFTPProxyKey:                 schema.Omit,
+	AptNoProxyKey:                  schema.Omit,
+	"apt-mirror":                   schema.Omit,
+	AgentStreamKey:                 schema.Omit,
+	ResourceTagsKey:                schema.Omit,
+	"cloudimg-base-url":            schema.Omit,
+	"enable-os-refresh-update":     schema.Omit,
+	"enable-os-upgrade":            schema.Omit,
+	"image-stream":                 schema.Omit,
+	"image-metadata-url":           schema.Omit,
+	AgentMetadataURLKey:            schema.Omit,
+	"default-series":               schema.Omit,
+	"development":                  schema.Omit,
+	"ssl-hostname-verification":    schema.Omit,
+	"proxy-ssh":                    schema.Omit,
+	"disable-network-management":   schema.Omit,
+	IgnoreMachineAddresses:         schema.Omit,
+	AutomaticallyRetryHooks:        schema.Omit,
+	"test-mode":                    schema.Omit,
+	TransmitVendorMetricsKey:       schema.Omit,
+	NetBondReconfigureDelayKey:     schema.Omit,
+	MaxStatusHistoryAge:            schema.Omit,
+	MaxStatusHistorySize:           schema.Omit,
+	MaxActionResultsAge:            schema.Omit,
+	MaxActionResultsSize:           schema.Omit,
+	UpdateStatusHookInterval:       schema.Omit,
+	EgressSubnets:                  schema.Omit,
+	FirewallRuleGroups:             schema.Omit,
+	EnabledFirewallRuleGroups:      schema.Omit,
+	IngressAddressFamilies:         schema.Omit,
+	FirewallPanicCloseOverride:     schema.Omit,
+	FirewallEssentialRulesOverride: schema.Omit,
+	ControllerAdminCIDRs:           schema.Omit,
+	FirewallDecisionLogging:        schema.Omit,
+	FirewallFreezeWindows:          schema.Omit,
+	FirewallOpenPortsTag:           schema.Omit,
 }
 
 func allowEmpty(attr string) bool {
@@ -1554,4 +2100,49 @@ data of the store. (default false)`,
 		Type:        environschema.Tstring,
 		Group:       environschema.EnvironGroup,
 	},
+	FirewallRuleGroups: {
+		Description: `Named groups of ingress rules, as space-separated name=rule[,rule...] entries, where each rule is a port range optionally followed by @ and a source CIDR, eg "monitoring=9100/tcp,9090-9091/tcp@10.0.0.0/8"`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	EnabledFirewallRuleGroups: {
+		Description: `Comma-separated names of the firewall-rule-groups whose rules are opened on every machine in the model; a name may be followed by @ and an RFC 3339 time after which the group's rules are closed, eg "ssh@2017-06-01T18:00:00Z"`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	IngressAddressFamilies: {
+		Description: `Comma-separated address families, "ipv4" and "ipv6", for which ingress rules are opened; ingress from other address families is denied; when unset, no address family is denied`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	FirewallPanicCloseOverride: {
+		Description: `Whether to close all ingress on every machine, except for the essential rules, overriding the controller's firewall-panic-close for this model; when unset, the controller's setting applies`,
+		Type:        environschema.Tbool,
+		Group:       environschema.EnvironGroup,
+	},
+	FirewallEssentialRulesOverride: {
+		Description: `Comma-separated ingress rules that remain open during a panic close, overriding the controller's firewall-essential-rules for this model, where each rule is a port range optionally followed by @ and a source CIDR, eg "22/tcp@10.0.0.0/8"`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	ControllerAdminCIDRs: {
+		Description: `Comma-separated CIDRs from which the controller's API and SSH ports may be reached, once bootstrap has completed; set it with "juju model-defaults" to restrict them across the controller (default: any source)`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	FirewallDecisionLogging: {
+		Description: `Whether the firewaller logs, at debug level, why each port range is or is not opened on each machine`,
+		Type:        environschema.Tbool,
+		Group:       environschema.EnvironGroup,
+	},
+	FirewallFreezeWindows: {
+		Description: `Comma-separated weekly windows, in UTC, during which no firewall changes are made, where each window is a day or range of days followed by a start and end time, eg "mon-fri 09:00-17:00"; changes are applied once the window has passed, except that a panic close takes effect immediately`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	FirewallOpenPortsTag: {
+		Description: `The name of a tag to keep on each machine's instance listing its open port ranges, eg "80/tcp,443/tcp", for external discovery tools; the tag is updated after ports are opened or closed, at a limited rate, and removed when no ports are open or the machine is removed (default: no tag)`,
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/juju/juju/cert"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/juju/osenv"
+	"github.com/juju/juju/network"
 	"github.com/juju/juju/testing"
 )
 
@@ -1131,6 +1132,351 @@ func (s *ConfigSuite) TestEgressSubnets(c *gc.C) {
 	c.Assert(cfg.EgressSubnets(), gc.DeepEquals, []string{"10.0.0.1/32", "192.168.1.1/16"})
 }
 
+func (s *ConfigSuite) TestFirewallRuleGroups(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{
+		"firewall-rule-groups":         "monitoring=9100/tcp,9090-9091/tcp@10.0.0.0/8  ping=8/icmp",
+		"enabled-firewall-rule-groups": "monitoring, ping",
+	})
+	c.Assert(cfg.FirewallRuleGroups(), jc.DeepEquals, map[string][]network.IngressRule{
+		"monitoring": {
+			network.MustNewIngressRule("tcp", 9100, 9100),
+			network.MustNewIngressRule("tcp", 9090, 9091, "10.0.0.0/8"),
+		},
+		"ping": {
+			network.MustNewIngressRule("icmp", 8, 8),
+		},
+	})
+	c.Assert(cfg.EnabledFirewallRuleGroups(), jc.DeepEquals, []string{"monitoring", "ping"})
+	c.Assert(cfg.FirewallRuleGroupExpiries(), gc.HasLen, 0)
+	rules, nextExpiry := cfg.EnabledFirewallRules(time.Now())
+	c.Assert(rules, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("icmp", 8, 8),
+		network.MustNewIngressRule("tcp", 9090, 9091, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 9100, 9100),
+	})
+	c.Assert(nextExpiry.IsZero(), jc.IsTrue)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"firewall-rule-groups": "monitoring=9100/tcp",
+	})
+	c.Assert(cfg.EnabledFirewallRuleGroups(), gc.HasLen, 0)
+	rules, _ = cfg.EnabledFirewallRules(time.Now())
+	c.Assert(rules, gc.HasLen, 0)
+}
+
+func (s *ConfigSuite) TestFirewallRuleGroupsWithExpiry(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{
+		"firewall-rule-groups":         "monitoring=9100/tcp ssh=22/tcp ping=8/icmp",
+		"enabled-firewall-rule-groups": "monitoring,ssh@2017-06-01T18:00:00Z,ping@2017-06-01T12:00:00+02:00",
+	})
+	sshExpiry := time.Date(2017, 6, 1, 18, 0, 0, 0, time.UTC)
+	pingExpiry := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	c.Assert(cfg.EnabledFirewallRuleGroups(), jc.DeepEquals, []string{"monitoring", "ssh", "ping"})
+	expiries := cfg.FirewallRuleGroupExpiries()
+	c.Assert(expiries, gc.HasLen, 2)
+	c.Assert(expiries["ssh"].Equal(sshExpiry), jc.IsTrue)
+	c.Assert(expiries["ping"].Equal(pingExpiry), jc.IsTrue)
+
+	rules, nextExpiry := cfg.EnabledFirewallRules(pingExpiry.Add(-time.Hour))
+	c.Assert(rules, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("icmp", 8, 8),
+		network.MustNewIngressRule("tcp", 22, 22),
+		network.MustNewIngressRule("tcp", 9100, 9100),
+	})
+	c.Assert(nextExpiry.Equal(pingExpiry), jc.IsTrue)
+
+	rules, nextExpiry = cfg.EnabledFirewallRules(pingExpiry)
+	c.Assert(rules, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22),
+		network.MustNewIngressRule("tcp", 9100, 9100),
+	})
+	c.Assert(nextExpiry.Equal(sshExpiry), jc.IsTrue)
+
+	rules, nextExpiry = cfg.EnabledFirewallRules(sshExpiry)
+	c.Assert(rules, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 9100, 9100),
+	})
+	c.Assert(nextExpiry.IsZero(), jc.IsTrue)
+}
+
+func (s *ConfigSuite) TestFirewallRuleGroupsInvalid(c *gc.C) {
+	for i, test := range []struct {
+		groups  string
+		enabled string
+		err     string
+	}{{
+		groups: "monitoring",
+		err:    `firewall rule group "monitoring" not valid`,
+	}, {
+		groups: "mon,itoring=9100/tcp",
+		err:    `firewall rule group "mon,itoring=9100/tcp" not valid`,
+	}, {
+		groups: "monitoring=9100/tcp monitoring=9090/tcp",
+		err:    `firewall rule group "monitoring" defined more than once`,
+	}, {
+		groups: "monitoring=http",
+		err:    `firewall rule group "monitoring": invalid rule "http": .*`,
+	}, {
+		groups: "monitoring=9100/tcp@10.0.0.0",
+		err:    `firewall rule group "monitoring": invalid rule "9100/tcp@10.0.0.0": .*`,
+	}, {
+		groups:  "monitoring=9100/tcp",
+		enabled: "monitoring,ssh",
+		err:     `enabled firewall rule group "ssh" not found`,
+	}, {
+		groups:  "ssh=22/tcp",
+		enabled: "ssh@tomorrow",
+		err:     `enabled firewall rule group "ssh": parsing time .*`,
+	}, {
+		groups:  "ssh=22/tcp",
+		enabled: "ssh,ssh@2017-06-01T18:00:00Z",
+		err:     `firewall rule group "ssh" enabled more than once`,
+	}} {
+		c.Logf("test %d: %q, %q", i, test.groups, test.enabled)
+		_, err := config.New(config.UseDefaults, testing.Attrs{
+			"type": "my-type", "name": "my-name",
+			"uuid":                         testing.ModelTag.Id(),
+			"firewall-rule-groups":         test.groups,
+			"enabled-firewall-rule-groups": test.enabled,
+		})
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *ConfigSuite) TestIngressAddressFamilies(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{})
+	c.Assert(cfg.IngressAddressFamilies(), gc.IsNil)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"ingress-address-families": "ipv6",
+	})
+	c.Assert(cfg.IngressAddressFamilies(), jc.DeepEquals, []string{"ipv6"})
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"ingress-address-families": "IPv4, ipv6,ipv4",
+	})
+	c.Assert(cfg.IngressAddressFamilies(), jc.DeepEquals, []string{"ipv4", "ipv6"})
+}
+
+func (s *ConfigSuite) TestIngressAddressFamiliesInvalid(c *gc.C) {
+	_, err := config.New(config.UseDefaults, testing.Attrs{
+		"type": "my-type", "name": "my-name",
+		"uuid":                     testing.ModelTag.Id(),
+		"ingress-address-families": "ipv4,appletalk",
+	})
+	c.Assert(err, gc.ErrorMatches, `ingress address family "appletalk" not valid`)
+}
+
+func (s *ConfigSuite) TestFirewallPanicCloseOverride(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{})
+	_, ok := cfg.FirewallPanicCloseOverride()
+	c.Assert(ok, jc.IsFalse)
+	_, ok = cfg.FirewallEssentialRulesOverride()
+	c.Assert(ok, jc.IsFalse)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"firewall-panic-close-override":     false,
+		"firewall-essential-rules-override": "",
+	})
+	panicClose, ok := cfg.FirewallPanicCloseOverride()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(panicClose, jc.IsFalse)
+	rules, ok := cfg.FirewallEssentialRulesOverride()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(rules, gc.HasLen, 0)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"firewall-panic-close-override":     true,
+		"firewall-essential-rules-override": "443/tcp, 22/tcp@10.0.0.0/8",
+	})
+	panicClose, ok = cfg.FirewallPanicCloseOverride()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(panicClose, jc.IsTrue)
+	rules, ok = cfg.FirewallEssentialRulesOverride()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(rules, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 443, 443),
+	})
+}
+
+func (s *ConfigSuite) TestFirewallEssentialRulesOverrideInvalid(c *gc.C) {
+	_, err := config.New(config.UseDefaults, testing.Attrs{
+		"type": "my-type", "name": "my-name",
+		"uuid":                              testing.ModelTag.Id(),
+		"firewall-essential-rules-override": "22/tcp@10.0.0.0/8,ssh",
+	})
+	c.Assert(err, gc.ErrorMatches, `firewall essential rules override: invalid rule "ssh": .*`)
+}
+
+func (s *ConfigSuite) TestFirewallDecisionLogging(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{})
+	c.Assert(cfg.FirewallDecisionLogging(), jc.IsFalse)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"firewall-decision-logging": true,
+	})
+	c.Assert(cfg.FirewallDecisionLogging(), jc.IsTrue)
+}
+
+func (s *ConfigSuite) TestFirewallOpenPortsTag(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{})
+	c.Assert(cfg.FirewallOpenPortsTag(), gc.Equals, "")
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"firewall-open-ports-tag": "juju-open-ports",
+	})
+	c.Assert(cfg.FirewallOpenPortsTag(), gc.Equals, "juju-open-ports")
+
+	for _, test := range []struct {
+		tag string
+		err string
+	}{{
+		tag: " juju-open-ports",
+		err: `firewall-open-ports-tag: " juju-open-ports" must not start or end with spaces`,
+	}, {
+		tag: strings.Repeat("x", 129),
+		err: `firewall-open-ports-tag: "x+" is longer than 128 characters`,
+	}} {
+		_, err := config.New(config.UseDefaults, testing.Attrs{
+			"type": "my-type", "name": "my-name",
+			"uuid":                    testing.ModelTag.Id(),
+			"firewall-open-ports-tag": test.tag,
+		})
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *ConfigSuite) TestFirewallFrozen(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{})
+	frozen, next := cfg.FirewallFrozen(time.Date(2017, 6, 5, 12, 0, 0, 0, time.UTC))
+	c.Assert(frozen, jc.IsFalse)
+	c.Assert(next.IsZero(), jc.IsTrue)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"firewall-freeze-windows": "mon-fri 09:00-17:00, sat 22:00-02:00, wed 16:00-18:00",
+	})
+	for i, test := range []struct {
+		now    time.Time
+		frozen bool
+		next   time.Time
+	}{{
+		// Monday, before the window.
+		now:  time.Date(2017, 6, 5, 8, 0, 0, 0, time.UTC),
+		next: time.Date(2017, 6, 5, 9, 0, 0, 0, time.UTC),
+	}, {
+		now:    time.Date(2017, 6, 5, 9, 0, 0, 0, time.UTC),
+		frozen: true,
+		next:   time.Date(2017, 6, 5, 17, 0, 0, 0, time.UTC),
+	}, {
+		// Times in other zones are converted to UTC.
+		now:    time.Date(2017, 6, 5, 14, 0, 0, 0, time.FixedZone("X", 3600)),
+		frozen: true,
+		next:   time.Date(2017, 6, 5, 17, 0, 0, 0, time.UTC),
+	}, {
+		// Overlapping windows extend the freeze.
+		now:    time.Date(2017, 6, 7, 12, 0, 0, 0, time.UTC),
+		frozen: true,
+		next:   time.Date(2017, 6, 7, 18, 0, 0, 0, time.UTC),
+	}, {
+		now:  time.Date(2017, 6, 9, 17, 0, 0, 0, time.UTC),
+		next: time.Date(2017, 6, 10, 22, 0, 0, 0, time.UTC),
+	}, {
+		// The Saturday window ends on Sunday, in the next week.
+		now:    time.Date(2017, 6, 10, 23, 0, 0, 0, time.UTC),
+		frozen: true,
+		next:   time.Date(2017, 6, 11, 2, 0, 0, 0, time.UTC),
+	}, {
+		now:    time.Date(2017, 6, 11, 1, 0, 0, 0, time.UTC),
+		frozen: true,
+		next:   time.Date(2017, 6, 11, 2, 0, 0, 0, time.UTC),
+	}, {
+		now:  time.Date(2017, 6, 11, 3, 0, 0, 0, time.UTC),
+		next: time.Date(2017, 6, 12, 9, 0, 0, 0, time.UTC),
+	}} {
+		c.Logf("test %d: %v", i, test.now)
+		frozen, next := cfg.FirewallFrozen(test.now)
+		c.Check(frozen, gc.Equals, test.frozen)
+		c.Check(next.Equal(test.next), jc.IsTrue, gc.Commentf("got %v, expected %v", next, test.next))
+	}
+}
+
+func (s *ConfigSuite) TestFirewallFrozenDayRangeWraps(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{
+		"firewall-freeze-windows": "Sat-Mon 00:00-12:00",
+	})
+	frozen, next := cfg.FirewallFrozen(time.Date(2017, 6, 11, 6, 0, 0, 0, time.UTC))
+	c.Assert(frozen, jc.IsTrue)
+	c.Assert(next.Equal(time.Date(2017, 6, 11, 12, 0, 0, 0, time.UTC)), jc.IsTrue)
+	frozen, next = cfg.FirewallFrozen(time.Date(2017, 6, 6, 6, 0, 0, 0, time.UTC))
+	c.Assert(frozen, jc.IsFalse)
+	c.Assert(next.Equal(time.Date(2017, 6, 10, 0, 0, 0, 0, time.UTC)), jc.IsTrue)
+}
+
+func (s *ConfigSuite) TestFirewallFreezeWindowsInvalid(c *gc.C) {
+	for i, test := range []struct {
+		windows string
+		err     string
+	}{{
+		windows: "mon",
+		err:     `firewall freeze window "mon": expected days and times, eg "mon-fri 09:00-17:00"`,
+	}, {
+		windows: "someday 09:00-17:00",
+		err:     `firewall freeze window "someday 09:00-17:00": day "someday" not valid`,
+	}, {
+		windows: "mon-funday 09:00-17:00",
+		err:     `firewall freeze window "mon-funday 09:00-17:00": day "funday" not valid`,
+	}, {
+		windows: "mon 09:00",
+		err:     `firewall freeze window "mon 09:00": expected a start and end time, eg "09:00-17:00"`,
+	}, {
+		windows: "mon 09:00-25:00",
+		err:     `firewall freeze window "mon 09:00-25:00": time "25:00" not valid`,
+	}, {
+		windows: "mon 09:00-09:00",
+		err:     `firewall freeze window "mon 09:00-09:00": start and end times must differ`,
+	}} {
+		c.Logf("test %d: %q", i, test.windows)
+		_, err := config.New(config.UseDefaults, testing.Attrs{
+			"type": "my-type", "name": "my-name",
+			"uuid":                    testing.ModelTag.Id(),
+			"firewall-freeze-windows": test.windows,
+		})
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *ConfigSuite) TestControllerAdminCIDRs(c *gc.C) {
+	cfg := newTestConfig(c, testing.Attrs{})
+	c.Assert(cfg.ControllerAdminCIDRs(), gc.HasLen, 0)
+
+	cfg = newTestConfig(c, testing.Attrs{
+		"controller-admin-cidrs": "10.0.0.0/8, 2001:db8::/32",
+	})
+	c.Assert(cfg.ControllerAdminCIDRs(), jc.DeepEquals, []string{"10.0.0.0/8", "2001:db8::/32"})
+}
+
+func (s *ConfigSuite) TestControllerAdminCIDRsInvalid(c *gc.C) {
+	_, err := config.New(config.UseDefaults, testing.Attrs{
+		"type": "my-type", "name": "my-name",
+		"uuid":                   testing.ModelTag.Id(),
+		"controller-admin-cidrs": "10.0.0.0/8,10.0.0.1",
+	})
+	c.Assert(err, gc.ErrorMatches, `controller admin CIDR "10.0.0.1" not valid`)
+}
+
+func (s *ConfigSuite) TestCheckControllerAdminCIDRs(c *gc.C) {
+	cidrs := []string{"10.0.0.0/8", "2001:db8::/32"}
+	c.Check(config.CheckControllerAdminCIDRs(nil, "192.168.0.1:54321"), jc.ErrorIsNil)
+	c.Check(config.CheckControllerAdminCIDRs(cidrs, "10.1.2.3:54321"), jc.ErrorIsNil)
+	c.Check(config.CheckControllerAdminCIDRs(cidrs, "[2001:db8::1]:54321"), jc.ErrorIsNil)
+	c.Check(config.CheckControllerAdminCIDRs(cidrs, "10.1.2.3"), jc.ErrorIsNil)
+	c.Check(config.CheckControllerAdminCIDRs(cidrs, "192.168.0.1:54321"), gc.ErrorMatches,
+		`controller admin CIDRs 10.0.0.0/8,2001:db8::/32 do not include this client's address 192.168.0.1, which would be locked out of the controller`)
+	c.Check(config.CheckControllerAdminCIDRs(cidrs, "@"), gc.ErrorMatches,
+		`cannot check controller admin CIDRs: client address "@" not valid`)
+}
+
 func (s *ConfigSuite) TestSchemaNoExtra(c *gc.C) {
 	schema, err := config.Schema(nil)
 	c.Assert(err, gc.IsNil)
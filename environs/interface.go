@@ -410,6 +410,26 @@ type Firewaller interface {
 	IngressRules() ([]network.IngressRule, error)
 }
 
+// ProtocolFirewaller is an interface that can be implemented by
+// Firewallers whose provider can only enforce ingress rules for some
+// protocols. Rules for other protocols are not opened.
+type ProtocolFirewaller interface {
+	// SupportsFirewallProtocol reports whether ingress rules for the
+	// given protocol, such as "tcp" or "esp", can be enforced.
+	SupportsFirewallProtocol(protocol string) bool
+}
+
+// ControllerPortsFirewaller is an interface that can be implemented by
+// Firewallers whose provider opens the ports essential to reaching the
+// controller, its API port and SSH, to all sources when machines are
+// provisioned, so that bootstrap can complete.
+type ControllerPortsFirewaller interface {
+	// RestrictControllerPorts restricts ingress to the given
+	// controller ports to the given source CIDRs. With no CIDRs, the
+	// ports are opened to all sources again.
+	RestrictControllerPorts(ports []network.PortRange, sourceCIDRs []string) error
+}
+
 // InstanceTagger is an interface that can be used for tagging instances.
 type InstanceTagger interface {
 	// TagInstance tags the given instance with the specified tags.
@@ -419,6 +439,24 @@ type InstanceTagger interface {
 	TagInstance(id instance.Id, tags map[string]string) error
 }
 
+// InstanceUntagger is an interface that can be used for removing tags
+// from instances.
+type InstanceUntagger interface {
+	// UntagInstance removes the tags with the specified names from
+	// the given instance. Names of tags that are not set are ignored.
+	UntagInstance(id instance.Id, names []string) error
+}
+
+// Bastioned is an interface that can be implemented by Environs whose
+// machines are reached over SSH through a bastion host, such as when
+// they have no public addresses.
+type Bastioned interface {
+	// BastionAddress returns the address of the bastion host through
+	// which the model's machines are reached, or "" if they are
+	// reached directly.
+	BastionAddress() (string, error)
+}
+
 // InstanceTypesFetcher is an interface that allows for instance information from
 // a provider to be obtained.
 type InstanceTypesFetcher interface {
@@ -31,6 +31,16 @@ const (
 	// is assigned to.
 	JujuStorageInstance = JujuTagPrefix + "storage-instance"
 
+	// JujuVolume is the tag name used for identifying the Juju
+	// volume that an IaaS storage resource was created for. The
+	// value is the volume's ID, such as "0/0".
+	JujuVolume = JujuTagPrefix + "volume"
+
+	// JujuPreserve is the tag name used for marking storage resources
+	// that should be retained when the model that they belong to is
+	// destroyed. Operators set it to "true" on the resources to keep.
+	JujuPreserve = JujuTagPrefix + "preserve"
+
 	// JujuStorageOwner is the tag name used for identifying
 	// the service or unit that owns the Juju storage instance
 	// that an IaaS storage resource is assigned to.
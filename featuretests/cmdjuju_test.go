@@ -105,11 +105,13 @@ settings:
     is_default: true
     type: int
   title:
+    default: My Title
     description: A descriptive title used for the application.
     is_default: true
     type: string
     value: My Title
   username:
+    default: admin001
     description: The name of the initial account (given admin permissions).
     is_default: true
     type: string
@@ -57,6 +57,37 @@ type Instance interface {
 	IngressRules(machineId string) ([]network.IngressRule, error)
 }
 
+// EgressFirewaller is implemented by instances whose provider supports
+// per-instance egress rules, allowing outbound traffic to the given
+// port ranges from the instance.
+type EgressFirewaller interface {
+	// OpenEgressPorts allows outbound traffic to the given port
+	// ranges from the instance, which should have been started with
+	// the given machine id.
+	OpenEgressPorts(machineId string, ports []network.PortRange) error
+
+	// CloseEgressPorts stops allowing outbound traffic to the given
+	// port ranges from the instance, which should have been started
+	// with the given machine id.
+	CloseEgressPorts(machineId string, ports []network.PortRange) error
+
+	// EgressPorts returns the port ranges to which outbound traffic
+	// from the instance, which should have been started with the
+	// given machine id, is allowed. The port ranges are returned as
+	// sorted by network.SortPortRanges().
+	EgressPorts(machineId string) ([]network.PortRange, error)
+}
+
+// Interruptible is implemented by instances that the cloud may reclaim
+// at short notice, such as spot instances. Such instances report a
+// notice of their interruption with the Interrupting status, so they
+// should be polled often enough for the notice to be acted on in time.
+type Interruptible interface {
+	// Interruptible reports whether the instance may be reclaimed at
+	// short notice.
+	Interruptible() bool
+}
+
 // HardwareCharacteristics represents the characteristics of the instance (if known).
 // Attributes that are nil are unknown or not supported.
 type HardwareCharacteristics struct {
@@ -80,6 +111,14 @@ type HardwareCharacteristics struct {
 
 	// AvailabilityZone defines the zone in which the machine resides.
 	AvailabilityZone *string `json:"availability-zone,omitempty" yaml:"availabilityzone,omitempty"`
+
+	// Accelerator is the model of the machine's hardware accelerators,
+	// such as GPUs, if it has any.
+	Accelerator *string `json:"accelerator,omitempty" yaml:"accelerator,omitempty"`
+
+	// AcceleratorCount is the number of hardware accelerators the
+	// machine has.
+	AcceleratorCount *uint64 `json:"accelerator-count,omitempty" yaml:"acceleratorcount,omitempty"`
 }
 
 func (hc HardwareCharacteristics) String() string {
@@ -105,6 +144,12 @@ func (hc HardwareCharacteristics) String() string {
 	if hc.AvailabilityZone != nil && *hc.AvailabilityZone != "" {
 		strs = append(strs, fmt.Sprintf("availability-zone=%s", *hc.AvailabilityZone))
 	}
+	if hc.Accelerator != nil && *hc.Accelerator != "" {
+		strs = append(strs, fmt.Sprintf("accelerator=%s", *hc.Accelerator))
+	}
+	if hc.AcceleratorCount != nil {
+		strs = append(strs, fmt.Sprintf("accelerator-count=%d", *hc.AcceleratorCount))
+	}
 	return strings.Join(strs, " ")
 }
 
@@ -160,6 +205,10 @@ func (hc *HardwareCharacteristics) setRaw(raw string) error {
 		err = hc.setTags(str)
 	case "availability-zone":
 		err = hc.setAvailabilityZone(str)
+	case "accelerator":
+		err = hc.setAccelerator(str)
+	case "accelerator-count":
+		err = hc.setAcceleratorCount(str)
 	default:
 		return fmt.Errorf("unknown characteristic %q", name)
 	}
@@ -230,6 +279,24 @@ func (hc *HardwareCharacteristics) setAvailabilityZone(str string) error {
 	return nil
 }
 
+func (hc *HardwareCharacteristics) setAccelerator(str string) error {
+	if hc.Accelerator != nil {
+		return fmt.Errorf("already set")
+	}
+	if str != "" {
+		hc.Accelerator = &str
+	}
+	return nil
+}
+
+func (hc *HardwareCharacteristics) setAcceleratorCount(str string) (err error) {
+	if hc.AcceleratorCount != nil {
+		return fmt.Errorf("already set")
+	}
+	hc.AcceleratorCount, err = parseUint64(str)
+	return
+}
+
 // parseTags returns the tags in the value s
 func parseTags(s string) *[]string {
 	if s == "" {
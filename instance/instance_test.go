@@ -261,6 +261,23 @@ var parseHardwareTests = []parseHardwareTestSpec{
 		err:     `bad "availability-zone" characteristic: already set`,
 	},
 
+	// "accelerator" and "accelerator-count" in detail.
+	{
+		summary: "set accelerator",
+		args:    []string{"accelerator=nvidia-k80 accelerator-count=8"},
+	}, {
+		summary: "set accelerator-count zero",
+		args:    []string{"accelerator-count=0"},
+	}, {
+		summary: "set accelerator-count invalid",
+		args:    []string{"accelerator-count=lots"},
+		err:     `bad "accelerator-count" characteristic: must be a non-negative integer`,
+	}, {
+		summary: "double set accelerator together",
+		args:    []string{"accelerator=nvidia-k80 accelerator=nvidia-k80"},
+		err:     `bad "accelerator" characteristic: already set`,
+	},
+
 	// Everything at once.
 	{
 		summary: "kitchen sink together",
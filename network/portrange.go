@@ -19,9 +19,52 @@ type PortRange struct {
 	Protocol string
 }
 
+// ipProtocolNumbers maps the names of the IP protocols without ports
+// that a port range may specify to their IANA protocol numbers. A port
+// range for one of these protocols has no ports, and covers all of the
+// protocol's traffic.
+var ipProtocolNumbers = map[string]int{
+	"gre": 47,
+	"esp": 50,
+	"ah":  51,
+}
+
+// IPProtocolNumber returns the IANA protocol number of the named IP
+// protocol, and reports whether it is a protocol without ports that
+// port ranges may specify.
+func IPProtocolNumber(protocol string) (int, bool) {
+	number, ok := ipProtocolNumbers[strings.ToLower(protocol)]
+	return number, ok
+}
+
+// IPProtocolName returns the name of the IP protocol without ports with
+// the given IANA protocol number, and reports whether there is one.
+func IPProtocolName(number int) (string, bool) {
+	for name, n := range ipProtocolNumbers {
+		if n == number {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// HasPorts reports whether the port range's protocol has ports. A port
+// range for a protocol without ports, such as "esp", has both FromPort
+// and ToPort zero.
+func (p PortRange) HasPorts() bool {
+	_, ok := IPProtocolNumber(p.Protocol)
+	return !ok
+}
+
 // IsValid determines if the port range is valid.
 func (p PortRange) Validate() error {
 	proto := strings.ToLower(p.Protocol)
+	if !p.HasPorts() {
+		if p.FromPort != 0 || p.ToPort != 0 {
+			return errors.Errorf("invalid port range %d-%d/%s: protocol %q has no ports", p.FromPort, p.ToPort, p.Protocol, proto)
+		}
+		return nil
+	}
 	if proto != "tcp" && proto != "udp" {
 		return errors.Errorf(`invalid protocol %q, expected "tcp" or "udp"`, proto)
 	}
@@ -51,6 +94,9 @@ func (a PortRange) ConflictsWith(b PortRange) bool {
 }
 
 func (p PortRange) String() string {
+	if !p.HasPorts() {
+		return strings.ToLower(p.Protocol)
+	}
 	if p.FromPort == p.ToPort {
 		return fmt.Sprintf("%d/%s", p.FromPort, strings.ToLower(p.Protocol))
 	}
@@ -132,11 +178,16 @@ func CollapsePorts(ports []Port) (result []PortRange) {
 }
 
 // ParsePortRange builds a PortRange from the provided string. If the
-// string does not include a protocol then "tcp" is used. Validate()
+// string does not include a protocol then "tcp" is used. A protocol
+// without ports, such as "esp", is given on its own. Validate()
 // gets called on the result before returning. If validation fails the
 // invalid PortRange is still returned.
-// Example strings: "80/tcp", "443", "12345-12349/udp".
+// Example strings: "80/tcp", "443", "12345-12349/udp", "esp".
 func ParsePortRange(inPortRange string) (PortRange, error) {
+	if _, ok := IPProtocolNumber(inPortRange); ok {
+		return PortRange{Protocol: strings.ToLower(inPortRange)}, nil
+	}
+
 	// Extract the protocol.
 	protocol := "tcp"
 	parts := strings.SplitN(inPortRange, "/", 2)
@@ -93,6 +93,28 @@ func (*PortRangeSuite) TestStrings(c *gc.C) {
 		gc.Equals,
 		"80-100/tcp",
 	)
+	c.Assert(
+		network.PortRange{0, 0, "ESP"}.String(),
+		gc.Equals,
+		"esp",
+	)
+}
+
+func (*PortRangeSuite) TestIPProtocolNumber(c *gc.C) {
+	number, ok := network.IPProtocolNumber("ESP")
+	c.Check(ok, jc.IsTrue)
+	c.Check(number, gc.Equals, 50)
+	_, ok = network.IPProtocolNumber("tcp")
+	c.Check(ok, jc.IsFalse)
+
+	name, ok := network.IPProtocolName(51)
+	c.Check(ok, jc.IsTrue)
+	c.Check(name, gc.Equals, "ah")
+	_, ok = network.IPProtocolName(6)
+	c.Check(ok, jc.IsFalse)
+
+	c.Check(network.PortRange{80, 80, "tcp"}.HasPorts(), jc.IsTrue)
+	c.Check(network.PortRange{0, 0, "gre"}.HasPorts(), jc.IsFalse)
 }
 
 func (*PortRangeSuite) TestValidate(c *gc.C) {
@@ -140,6 +162,14 @@ func (*PortRangeSuite) TestValidate(c *gc.C) {
 		"invalid protocol",
 		network.PortRange{80, 80, "some protocol"},
 		`invalid protocol "some protocol", expected "tcp" or "udp"`,
+	}, {
+		"protocol without ports",
+		network.PortRange{0, 0, "ESP"},
+		"",
+	}, {
+		"ports for protocol without ports",
+		network.PortRange{500, 500, "esp"},
+		`invalid port range 500-500/esp: protocol "esp" has no ports`,
 	}}
 
 	for i, t := range testCases {
@@ -250,6 +280,13 @@ func (*PortRangeSuite) TestParsePortRangeRoundTrip(c *gc.C) {
 	c.Check(portRangeStr, gc.Equals, "8000-8099/tcp")
 }
 
+func (*PortRangeSuite) TestParsePortRangeIPProtocol(c *gc.C) {
+	portRange, err := network.ParsePortRange("ESP")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(portRange, gc.Equals, network.PortRange{Protocol: "esp"})
+	c.Check(portRange.String(), gc.Equals, "esp")
+}
+
 func (*PortRangeSuite) TestParsePortRangeMultiRange(c *gc.C) {
 	_, err := network.ParsePortRange("10-55-100")
 
@@ -117,6 +117,7 @@ type azureEnviron struct {
 }
 
 var _ environs.Environ = (*azureEnviron)(nil)
+var _ environs.ProtocolFirewaller = (*azureEnviron)(nil)
 
 // newEnviron creates a new azureEnviron.
 func newEnviron(
@@ -1631,6 +1632,16 @@ func (env *azureEnviron) IngressRules() ([]jujunetwork.IngressRule, error) {
 	return nil, errNoFwGlobal
 }
 
+// SupportsFirewallProtocol is specified in the environs.ProtocolFirewaller
+// interface. Network security rules are only created for TCP and UDP.
+func (env *azureEnviron) SupportsFirewallProtocol(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case "tcp", "udp":
+		return true
+	}
+	return false
+}
+
 // Provider is specified in the Environ interface.
 func (env *azureEnviron) Provider() environs.EnvironProvider {
 	return env.provider
@@ -474,6 +474,14 @@ func (s *environSuite) TestOpen(c *gc.C) {
 	c.Assert(env, gc.NotNil)
 }
 
+func (s *environSuite) TestSupportsFirewallProtocol(c *gc.C) {
+	env := s.openEnviron(c).(environs.ProtocolFirewaller)
+	c.Assert(env.SupportsFirewallProtocol("tcp"), jc.IsTrue)
+	c.Assert(env.SupportsFirewallProtocol("UDP"), jc.IsTrue)
+	c.Assert(env.SupportsFirewallProtocol("esp"), jc.IsFalse)
+	c.Assert(env.SupportsFirewallProtocol("gre"), jc.IsFalse)
+}
+
 func (s *environSuite) TestCloudEndpointManagementURI(c *gc.C) {
 	env := s.openEnviron(c)
 
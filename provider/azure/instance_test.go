@@ -195,6 +195,16 @@ func (s *instanceSuite) TestInstanceStatusNilProvisioningState(c *gc.C) {
 	assertInstanceStatus(c, inst.Status(), status.Allocating, "")
 }
 
+func (s *instanceSuite) TestInstanceStatusDeploymentUpdating(c *gc.C) {
+	// A VM that is being updated is still alive, and is reported as
+	// allocating rather than stopped.
+	for _, state := range []string{"Creating", "Updating"} {
+		s.deployments[0].Properties.ProvisioningState = to.StringPtr(state)
+		inst := s.getInstance(c)
+		assertInstanceStatus(c, inst.Status(), status.Allocating, state)
+	}
+}
+
 func assertInstanceStatus(c *gc.C, actual instance.InstanceStatus, status status.Status, message string) {
 	c.Assert(actual, jc.DeepEquals, instance.InstanceStatus{
 		Status:  status,
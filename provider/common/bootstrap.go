@@ -121,7 +121,10 @@ func BootstrapInstance(ctx environs.BootstrapContext, env environs.Environ, args
 	instanceConfig.EnableOSUpgrade = env.Config().EnableOSUpgrade()
 	instanceConfig.NetBondReconfigureDelay = env.Config().NetBondReconfigureDelay()
 
-	instanceConfig.Tags = instancecfg.InstanceTags(envCfg.UUID(), args.ControllerConfig.ControllerUUID(), envCfg, instanceConfig.Jobs)
+	instanceConfig.Tags = instancecfg.InstanceTags(
+		envCfg.UUID(), args.ControllerConfig.ControllerUUID(), instanceConfig.Jobs,
+		args.ControllerConfig, envCfg,
+	)
 	maybeSetBridge := func(icfg *instancecfg.InstanceConfig) {
 		// If we need to override the default bridge name, do it now. When
 		// args.ContainerBridgeName is empty, the default names for LXC
@@ -256,6 +259,16 @@ var FinishBootstrap = func(
 	defer ctx.StopInterruptNotify(interrupted)
 
 	hostSSHOptions := bootstrapSSHOptionsFunc(instanceConfig)
+	if bastioned, ok := env.(environs.Bastioned); ok {
+		bastion, err := bastioned.BastionAddress()
+		if err != nil {
+			return errors.Annotate(err, "getting bastion address")
+		}
+		if bastion != "" {
+			fmt.Fprintf(ctx.GetStderr(), "Connecting through bastion %s\n", bastion)
+			hostSSHOptions = bastionSSHOptionsFunc(bastion, hostSSHOptions)
+		}
+	}
 	addr, err := WaitSSH(
 		ctx.GetStderr(),
 		interrupted,
@@ -362,6 +375,29 @@ func bootstrapSSHOptionsFunc(instanceConfig *instancecfg.InstanceConfig) HostSSH
 	}
 }
 
+// bastionSSHOptionsFunc returns a HostSSHOptionsFunc that returns the
+// options returned by hostSSHOptions, altered to connect to the host
+// through the given bastion.
+func bastionSSHOptionsFunc(bastion string, hostSSHOptions HostSSHOptionsFunc) HostSSHOptionsFunc {
+	return func(host string) (*ssh.Options, func(), error) {
+		options, cleanup, err := hostSSHOptions(host)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		if options == nil {
+			options = &ssh.Options{}
+		}
+		options.SetProxyCommand(
+			"ssh",
+			"-o", "StrictHostKeyChecking=no",
+			"-q",
+			"-W", "%h:%p",
+			"ubuntu@"+bastion,
+		)
+		return options, cleanup, nil
+	}
+}
+
 func hostBootstrapSSHOptions(
 	host string,
 	instanceConfig *instancecfg.InstanceConfig,
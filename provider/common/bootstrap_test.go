@@ -308,6 +308,37 @@ func (s *BootstrapSuite) TestSuccess(c *gc.C) {
 	)
 }
 
+func (s *BootstrapSuite) TestBastionSSHOptions(c *gc.C) {
+	testing.PatchExecutableAsEchoArgs(c, s, "ssh")
+	var cleanedUp bool
+	hostSSHOptions := common.BastionSSHOptionsFunc("bastion.invalid", func(host string) (*ssh.Options, func(), error) {
+		options := &ssh.Options{}
+		options.SetStrictHostKeyChecking(ssh.StrictHostChecksYes)
+		return options, func() { cleanedUp = true }, nil
+	})
+	opts, cleanup, err := hostSSHOptions("testing.invalid")
+	c.Assert(err, jc.ErrorIsNil)
+	cleanup()
+	c.Assert(cleanedUp, jc.IsTrue)
+
+	client, err := ssh.NewOpenSSHClient()
+	c.Assert(err, jc.ErrorIsNil)
+	cmd := client.Command("ubuntu@testing.invalid", []string{"/bin/bash"}, opts)
+	err = cmd.Run()
+	c.Assert(err, jc.ErrorIsNil)
+	sshArgs := testing.ReadEchoArgs(c, "ssh")
+	c.Assert(sshArgs, gc.Matches, ".*'-o' 'StrictHostKeyChecking yes'.*")
+	c.Assert(sshArgs, gc.Matches, ".*'-o' 'ProxyCommand ssh .*-W .*%h:%p.* .*ubuntu@bastion.invalid.*'.*")
+	c.Assert(sshArgs, gc.Matches, ".*'ubuntu@testing.invalid' '/bin/bash'")
+}
+
+func (s *BootstrapSuite) TestBastionSSHOptionsDefault(c *gc.C) {
+	hostSSHOptions := common.BastionSSHOptionsFunc("bastion.invalid", common.DefaultHostSSHOptions)
+	opts, _, err := hostSSHOptions("testing.invalid")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(opts, gc.NotNil)
+}
+
 type neverRefreshes struct {
 }
 
@@ -0,0 +1,104 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/ec2"
+)
+
+// The aliases that may be given in allowed-ami-owners in place of
+// account IDs.
+const (
+	amiOwnerSelf      = "self"
+	amiOwnerCanonical = "canonical"
+	amiOwnerAmazon    = "amazon"
+)
+
+// canonicalAccountIDs are the AWS accounts through which Canonical
+// publishes Ubuntu images, in the standard, China and GovCloud
+// partitions.
+var canonicalAccountIDs = []string{
+	"099720109477",
+	"837727238323",
+	"513442679011",
+}
+
+// isAMIOwner reports whether the given entry of allowed-ami-owners is
+// an owner alias or an AWS account ID.
+func isAMIOwner(owner string) bool {
+	switch owner {
+	case amiOwnerSelf, amiOwnerCanonical, amiOwnerAmazon:
+		return true
+	}
+	if len(owner) != 12 {
+		return false
+	}
+	for _, c := range owner {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkImageOwner checks that the given image is owned by one of the
+// accounts in allowed-ami-owners, if any are given.
+func (e *environ) checkImageOwner(image *ec2.Image) error {
+	allowed := e.ecfg().allowedAMIOwners()
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, owner := range allowed {
+		switch owner {
+		case amiOwnerSelf:
+			account, err := e.vpcOwners.AccountID()
+			if err != nil {
+				return errors.Annotate(err, "checking image owner")
+			}
+			if image.OwnerId == account {
+				return nil
+			}
+		case amiOwnerCanonical:
+			for _, id := range canonicalAccountIDs {
+				if image.OwnerId == id {
+					return nil
+				}
+			}
+		case amiOwnerAmazon:
+			if image.OwnerAlias == amiOwnerAmazon {
+				return nil
+			}
+		default:
+			if image.OwnerId == owner {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf(
+		"image %q is owned by account %q, which is not allowed by allowed-ami-owners (%s)",
+		image.Id, image.OwnerId, strings.Join(allowed, ","),
+	)
+}
+
+// precheckImageOwner checks that the AMI given with image-id, if any,
+// is owned by one of the accounts in allowed-ami-owners. Images found
+// in simplestreams are only checked when instances are started, once
+// the image is known.
+func (e *environ) precheckImageOwner() error {
+	if len(e.ecfg().allowedAMIOwners()) == 0 || e.ecfg().imageID() == "" {
+		return nil
+	}
+	imageId, err := e.resolveImageId()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	image, err := validateCustomImage(e.ec2, imageId)
+	if err != nil {
+		return errors.Annotate(err, "validating image-id")
+	}
+	return errors.Trace(e.checkImageOwner(image))
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type amiOwnersSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&amiOwnersSuite{})
+
+type fakeVPCOwnerAPI struct {
+	accountID string
+	err       error
+	calls     int
+}
+
+func (f *fakeVPCOwnerAPI) VPCOwner(vpcID string) (string, error) {
+	return "", errors.NotImplementedf("VPCOwner")
+}
+
+func (f *fakeVPCOwnerAPI) AccountID() (string, error) {
+	f.calls++
+	return f.accountID, f.err
+}
+
+func (s *amiOwnersSuite) environ(c *gc.C, allowed string, owners vpcOwnerAPI) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(testing.Attrs{
+		"allowed-ami-owners": allowed,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg, vpcOwners: owners}
+}
+
+func (s *amiOwnersSuite) TestIsAMIOwner(c *gc.C) {
+	for _, owner := range []string{"self", "canonical", "amazon", "123456789012"} {
+		c.Check(isAMIOwner(owner), jc.IsTrue, gc.Commentf("%q", owner))
+	}
+	for _, owner := range []string{"", "aws-marketplace", "12345678901", "1234567890123", "12345678901a"} {
+		c.Check(isAMIOwner(owner), jc.IsFalse, gc.Commentf("%q", owner))
+	}
+}
+
+func (s *amiOwnersSuite) TestCheckImageOwnerUnrestricted(c *gc.C) {
+	owners := &fakeVPCOwnerAPI{}
+	env := s.environ(c, "", owners)
+	image := &ec2.Image{Id: "ami-0123456789abcdef0", OwnerId: "210987654321"}
+	c.Assert(env.checkImageOwner(image), jc.ErrorIsNil)
+	c.Assert(owners.calls, gc.Equals, 0)
+}
+
+func (s *amiOwnersSuite) TestCheckImageOwner(c *gc.C) {
+	owners := &fakeVPCOwnerAPI{accountID: "123456789012"}
+	env := s.environ(c, "self,canonical,amazon,111122223333", owners)
+	for _, image := range []*ec2.Image{
+		{Id: "ami-0123456789abcdef0", OwnerId: "123456789012"},
+		{Id: "ami-0123456789abcdef0", OwnerId: "099720109477"},
+		{Id: "ami-0123456789abcdef0", OwnerId: "137112412989", OwnerAlias: "amazon"},
+		{Id: "ami-0123456789abcdef0", OwnerId: "111122223333"},
+	} {
+		c.Check(env.checkImageOwner(image), jc.ErrorIsNil, gc.Commentf("owner %q", image.OwnerId))
+	}
+}
+
+func (s *amiOwnersSuite) TestCheckImageOwnerDisallowed(c *gc.C) {
+	owners := &fakeVPCOwnerAPI{accountID: "123456789012"}
+	env := s.environ(c, "self,canonical", owners)
+	image := &ec2.Image{Id: "ami-0123456789abcdef0", OwnerId: "210987654321"}
+	err := env.checkImageOwner(image)
+	c.Assert(err, gc.ErrorMatches, `image "ami-0123456789abcdef0" is owned by account "210987654321", which is not allowed by allowed-ami-owners \(self,canonical\)`)
+}
+
+func (s *amiOwnersSuite) TestCheckImageOwnerAccountIDError(c *gc.C) {
+	owners := &fakeVPCOwnerAPI{err: errors.New("access denied")}
+	env := s.environ(c, "self", owners)
+	image := &ec2.Image{Id: "ami-0123456789abcdef0", OwnerId: "210987654321"}
+	err := env.checkImageOwner(image)
+	c.Assert(err, gc.ErrorMatches, "checking image owner: access denied")
+}
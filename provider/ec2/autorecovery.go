@@ -0,0 +1,17 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import "net/url"
+
+// autoRecoveryParams adds the RunInstances parameters that enable
+// automatic recovery of the instance when instance-auto-recovery is
+// set. The EC2 client library has no support for maintenance options.
+// When instance-auto-recovery is not set, nothing is added, so the AWS
+// default behaviour applies.
+func autoRecoveryParams(ecfg *environConfig, params url.Values) {
+	if ecfg.instanceAutoRecovery() {
+		params.Set("MaintenanceOptions.AutoRecovery", "default")
+	}
+}
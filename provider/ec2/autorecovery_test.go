@@ -0,0 +1,41 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type autoRecoverySuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&autoRecoverySuite{})
+
+func (s *autoRecoverySuite) params(c *gc.C, attrs testing.Attrs) url.Values {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	params := make(url.Values)
+	autoRecoveryParams(ecfg, params)
+	return params
+}
+
+func (s *autoRecoverySuite) TestAutoRecoveryParams(c *gc.C) {
+	attrs := testing.Attrs{"instance-auto-recovery": true}
+	c.Assert(s.params(c, attrs), jc.DeepEquals, url.Values{
+		"MaintenanceOptions.AutoRecovery": {"default"},
+	})
+}
+
+func (s *autoRecoverySuite) TestAutoRecoveryParamsUnset(c *gc.C) {
+	c.Assert(s.params(c, nil), gc.HasLen, 0)
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// awsAPIClient is a minimal client for an AWS API that the EC2 client
+// library does not cover, or covers only at a version that predates the
+// features used. Requests are signed with Signature Version 4.
+type awsAPIClient struct {
+	auth     aws.Auth
+	endpoint string
+	sign     aws.Signer
+
+	// version is the API version sent with query API requests.
+	version string
+
+	// target names the service in the X-Amz-Target header sent with
+	// JSON API requests.
+	target string
+}
+
+// newAWSAPIClient returns an awsAPIClient for the named service, using the
+// cloud's credential. Requests are sent to the given endpoint, or to
+// the service's endpoint in the region if that is empty, and are signed
+// for the region by a signer wrapped with wrapSigner.
+func newAWSAPIClient(
+	cloud environs.CloudSpec,
+	region, service, endpoint string,
+	wrapSigner func(aws.Signer) aws.Signer,
+) *awsAPIClient {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", service, region)
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	credentialAttrs := cloud.Credential.Attributes()
+	return &awsAPIClient{
+		auth: aws.Auth{
+			AccessKey: credentialAttrs["access-key"],
+			SecretKey: credentialAttrs["secret-key"],
+		},
+		endpoint: endpoint,
+		sign:     wrapSigner(aws.SignV4Factory(region, service)),
+	}
+}
+
+// newEC2Client returns an awsAPIClient for the given version of the EC2
+// query API of the cloud.
+func newEC2Client(cloud environs.CloudSpec, version string, wrapSigner func(aws.Signer) aws.Signer) *awsAPIClient {
+	client := newAWSAPIClient(cloud, cloud.Region, "ec2", cloud.Endpoint, wrapSigner)
+	client.version = version
+	return client
+}
+
+// awsError is an error response from an AWS API.
+type awsError struct {
+	Code    string
+	Message string
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+// query makes a request to a query API, such as that of EC2, and
+// decodes the XML response into resp, unless it is nil.
+func (c *awsAPIClient) query(action string, params url.Values, resp interface{}) error {
+	params.Set("Action", action)
+	params.Set("Version", c.version)
+	req, err := http.NewRequest("GET", c.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	r, err := c.do(req, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		// EC2 reports errors in a differently shaped document to
+		// the other query APIs.
+		var errResp struct {
+			EC2Code    string `xml:"Errors>Error>Code"`
+			EC2Message string `xml:"Errors>Error>Message"`
+			Code       string `xml:"Error>Code"`
+			Message    string `xml:"Error>Message"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&errResp); err != nil {
+			return errors.Errorf("%s failed: %s", action, r.Status)
+		}
+		switch {
+		case errResp.EC2Code != "":
+			return &awsError{errResp.EC2Code, errResp.EC2Message}
+		case errResp.Code != "":
+			return &awsError{errResp.Code, errResp.Message}
+		}
+		return errors.Errorf("%s failed: %s", action, r.Status)
+	}
+	if resp == nil {
+		return nil
+	}
+	return errors.Trace(xml.NewDecoder(r.Body).Decode(resp))
+}
+
+// call makes a request to a JSON API, such as that of Systems Manager,
+// and decodes the response into resp.
+func (c *awsAPIClient) call(action string, args, resp interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", c.target+"."+action)
+	r, err := c.do(req, body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		var errResp struct {
+			Code    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&errResp); err != nil || errResp.Code == "" {
+			return errors.Errorf("%s failed: %s", action, r.Status)
+		}
+		// The error type may be qualified with a namespace.
+		if i := strings.LastIndex(errResp.Code, "#"); i >= 0 {
+			errResp.Code = errResp.Code[i+1:]
+		}
+		return &awsError{errResp.Code, errResp.Message}
+	}
+	return errors.Trace(json.NewDecoder(r.Body).Decode(resp))
+}
+
+// do signs and sends the request, whose body, if it has one, is given.
+func (c *awsAPIClient) do(req *http.Request, body []byte) (*http.Response, error) {
+	req.Header.Set("x-amz-date", time.Now().In(time.UTC).Format(aws.ISO8601BasicFormat))
+	if err := c.sign(req, c.auth); err != nil {
+		return nil, errors.Annotate(err, "signing request")
+	}
+	if body != nil {
+		// Signing consumes the body to compute its hash.
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		finishRequest(req)
+		return nil, errors.Trace(err)
+	}
+	r.Body = finishOnClose{r.Body, req}
+	return r, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/cloud"
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/testing"
+)
+
+type awsAPIClientSuite struct {
+	testing.BaseSuite
+
+	server   *httptest.Server
+	requests []*http.Request
+	bodies   []string
+	status   int
+	response string
+	client   *awsAPIClient
+}
+
+var _ = gc.Suite(&awsAPIClientSuite{})
+
+func (s *awsAPIClientSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.bodies = nil
+	s.status = http.StatusOK
+	s.response = ""
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.response)
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  "2016-11-15",
+		target:   "AmazonSSM",
+	}
+}
+
+func (s *awsAPIClientSuite) TestNewAWSAPIClient(c *gc.C) {
+	credential := cloud.NewCredential(
+		cloud.AccessKeyAuthType,
+		map[string]string{
+			"access-key": "x",
+			"secret-key": "y",
+		},
+	)
+	spec := environs.CloudSpec{
+		Region:     "eu-west-1",
+		Credential: &credential,
+	}
+	var wrapped int
+	wrapSigner := func(signer aws.Signer) aws.Signer {
+		wrapped++
+		return signer
+	}
+
+	client := newEC2Client(spec, "2016-11-15", wrapSigner)
+	c.Assert(client.auth, jc.DeepEquals, aws.Auth{AccessKey: "x", SecretKey: "y"})
+	c.Assert(client.endpoint, gc.Equals, "https://ec2.eu-west-1.amazonaws.com/")
+	c.Assert(client.version, gc.Equals, "2016-11-15")
+	c.Assert(wrapped, gc.Equals, 1)
+
+	spec.Endpoint = "https://ec2.example.com"
+	client = newEC2Client(spec, "2016-11-15", wrapSigner)
+	c.Assert(client.endpoint, gc.Equals, "https://ec2.example.com/")
+
+	client = newAWSAPIClient(spec, "us-east-1", "pricing", "https://api.pricing.us-east-1.amazonaws.com", wrapSigner)
+	c.Assert(client.endpoint, gc.Equals, "https://api.pricing.us-east-1.amazonaws.com/")
+	c.Assert(wrapped, gc.Equals, 3)
+}
+
+func (s *awsAPIClientSuite) TestQuery(c *gc.C) {
+	s.response = `
+<DescribeThingsResponse>
+  <thing>thing-1</thing>
+</DescribeThingsResponse>`
+	var resp struct {
+		Thing string `xml:"thing"`
+	}
+	err := s.client.query("DescribeThings", url.Values{"ThingId.1": {"thing-1"}}, &resp)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Thing, gc.Equals, "thing-1")
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Method, gc.Equals, "GET")
+	c.Assert(s.requests[0].URL.Query(), jc.DeepEquals, url.Values{
+		"Action":    {"DescribeThings"},
+		"Version":   {"2016-11-15"},
+		"ThingId.1": {"thing-1"},
+	})
+}
+
+func (s *awsAPIClientSuite) TestQueryWithoutResponse(c *gc.C) {
+	s.response = `<DeleteThingResponse><return>true</return></DeleteThingResponse>`
+	err := s.client.query("DeleteThing", url.Values{}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *awsAPIClientSuite) TestQueryEC2Error(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `
+<Response>
+  <Errors>
+    <Error>
+      <Code>InvalidThingID.NotFound</Code>
+      <Message>The thing does not exist</Message>
+    </Error>
+  </Errors>
+</Response>`
+	err := s.client.query("DescribeThings", url.Values{}, nil)
+	c.Assert(err, jc.DeepEquals, &awsError{
+		Code:    "InvalidThingID.NotFound",
+		Message: "The thing does not exist",
+	})
+	c.Assert(err, gc.ErrorMatches, `The thing does not exist \(InvalidThingID.NotFound\)`)
+}
+
+func (s *awsAPIClientSuite) TestQueryError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `
+<ErrorResponse>
+  <Error>
+    <Code>TargetGroupNotFound</Code>
+    <Message>No such target group</Message>
+  </Error>
+</ErrorResponse>`
+	err := s.client.query("DescribeTargetGroups", url.Values{}, nil)
+	c.Assert(err, jc.DeepEquals, &awsError{
+		Code:    "TargetGroupNotFound",
+		Message: "No such target group",
+	})
+}
+
+func (s *awsAPIClientSuite) TestQueryUnreadableError(c *gc.C) {
+	s.status = http.StatusInternalServerError
+	err := s.client.query("DescribeThings", url.Values{}, nil)
+	c.Assert(err, gc.ErrorMatches, `DescribeThings failed: 500 Internal Server Error`)
+}
+
+func (s *awsAPIClientSuite) TestCall(c *gc.C) {
+	s.response = `{"Value": "value"}`
+	args := struct {
+		Name string `json:"Name"`
+	}{"name"}
+	var resp struct {
+		Value string `json:"Value"`
+	}
+	err := s.client.call("GetThing", args, &resp)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Value, gc.Equals, "value")
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Method, gc.Equals, "POST")
+	c.Assert(s.requests[0].Header.Get("X-Amz-Target"), gc.Equals, "AmazonSSM.GetThing")
+	c.Assert(s.requests[0].Header.Get("Content-Type"), gc.Equals, "application/x-amz-json-1.1")
+	// The body is sent in full, even though signing consumes it.
+	c.Assert(s.bodies, jc.DeepEquals, []string{`{"Name":"name"}`})
+}
+
+func (s *awsAPIClientSuite) TestCallError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "com.amazonaws.ssm#AccessDeniedException", "message": "denied"}`
+	err := s.client.call("GetThing", struct{}{}, nil)
+	c.Assert(err, jc.DeepEquals, &awsError{
+		Code:    "AccessDeniedException",
+		Message: "denied",
+	})
+}
+
+func (s *awsAPIClientSuite) TestCallUnreadableError(c *gc.C) {
+	s.status = http.StatusForbidden
+	err := s.client.call("GetThing", struct{}{}, nil)
+	c.Assert(err, gc.ErrorMatches, `GetThing failed: 403 Forbidden`)
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/ec2"
+)
+
+// bastionTagPrefix prefixes bastion config values that identify the
+// bastion by tag, rather than by instance ID.
+const bastionTagPrefix = "tag:"
+
+var instanceIDRegexp = regexp.MustCompile("^i-[0-9a-f]+$")
+
+// isValidBastion reports whether the given bastion config value is
+// either an instance ID or a "tag:key=value" tag filter.
+func isValidBastion(bastion string) bool {
+	if instanceIDRegexp.MatchString(bastion) {
+		return true
+	}
+	if !strings.HasPrefix(bastion, bastionTagPrefix) {
+		return false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(bastion, bastionTagPrefix), "=", 2)
+	return len(parts) == 2 && parts[0] != ""
+}
+
+// bastionInstance returns the running instance in the model's VPC that
+// the configured bastion identifies, or nil if no bastion is configured.
+func (e *environ) bastionInstance() (*ec2.Instance, error) {
+	ecfg := e.ecfg()
+	bastion := ecfg.bastion()
+	if bastion == "" {
+		return nil, nil
+	}
+	filter := ec2.NewFilter()
+	filter.Add("instance-state-name", "running")
+	if vpcID := ecfg.vpcID(); isVPCIDSet(vpcID) {
+		filter.Add("vpc-id", vpcID)
+	}
+	var ids []string
+	if strings.HasPrefix(bastion, bastionTagPrefix) {
+		parts := strings.SplitN(strings.TrimPrefix(bastion, bastionTagPrefix), "=", 2)
+		filter.Add("tag:"+parts[0], parts[1])
+	} else {
+		ids = []string{bastion}
+	}
+	resp, err := e.ec2.Instances(ids, filter)
+	if err != nil && !isNotFoundError(err) {
+		return nil, errors.Annotatef(err, "finding bastion %q", bastion)
+	}
+	var insts []ec2.Instance
+	if resp != nil {
+		for _, r := range resp.Reservations {
+			insts = append(insts, r.Instances...)
+		}
+	}
+	switch len(insts) {
+	case 0:
+		return nil, errors.NotFoundf("running bastion %q", bastion)
+	case 1:
+		return &insts[0], nil
+	}
+	return nil, errors.Errorf("bastion %q matches %d instances, expected one", bastion, len(insts))
+}
+
+// BastionAddress is part of the environs.Bastioned interface.
+func (e *environ) BastionAddress() (string, error) {
+	inst, err := e.bastionInstance()
+	if err != nil || inst == nil {
+		return "", errors.Trace(err)
+	}
+	if inst.IPAddress != "" {
+		return inst.IPAddress, nil
+	}
+	if inst.DNSName != "" {
+		return inst.DNSName, nil
+	}
+	return "", errors.Errorf("bastion %q has no public address", inst.InstanceId)
+}
+
+// dialBastion checks that the SSH server at the given address accepts
+// connections.
+var dialBastion = func(addr string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, "22"), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// validateBastion checks that the configured bastion, if any, exists
+// and is reachable over SSH.
+func (e *environ) validateBastion() error {
+	addr, err := e.BastionAddress()
+	if err != nil {
+		return errors.Annotate(err, "validating bastion")
+	}
+	if addr == "" {
+		return nil
+	}
+	if err := dialBastion(addr); err != nil {
+		return errors.Annotatef(err, "bastion %s is not reachable", addr)
+	}
+	return nil
+}
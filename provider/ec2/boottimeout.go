@@ -0,0 +1,90 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/status"
+)
+
+const instanceStateRunning = "running"
+
+// instanceBootPollDelay is how often the state of a new instance is
+// checked while waiting for it to reach the running state.
+var instanceBootPollDelay = 5 * time.Second
+
+// defaultInstanceVolumesBootTimeout is how long to wait for a new
+// instance to reach the running state, so that the volumes created
+// with it can be attached, when instance-boot-timeout is not set.
+const defaultInstanceVolumesBootTimeout = 5 * time.Minute
+
+// instanceBootTimeoutError is returned when a new instance fails to
+// reach the running state within instance-boot-timeout.
+type instanceBootTimeoutError struct {
+	id        instance.Id
+	timeout   time.Duration
+	lastState string
+}
+
+func (e *instanceBootTimeoutError) Error() string {
+	return fmt.Sprintf(
+		"instance %s failed to reach running within %v; last state %s",
+		e.id, e.timeout, e.lastState,
+	)
+}
+
+// isInstanceBootTimeoutError reports whether err is, or was caused by,
+// an instance failing to reach the running state in time.
+func isInstanceBootTimeoutError(err error) bool {
+	_, ok := errors.Cause(err).(*instanceBootTimeoutError)
+	return ok
+}
+
+// waitForInstanceRunning waits up to the given timeout for the new
+// instance to reach the running state. It fails early if the instance
+// stops or is terminated while it is starting.
+func (e *environ) waitForInstanceRunning(inst *ec2Instance, timeout time.Duration, callback environs.StatusCallbackFunc) error {
+	callback(status.Allocating, fmt.Sprintf("Waiting up to %v for instance %s to reach running", timeout, inst.Id()), nil)
+	attempt := utils.AttemptStrategy{
+		Total: timeout,
+		Delay: instanceBootPollDelay,
+	}
+	state := inst.Instance.State.Name
+	for a := attempt.Start(); state != instanceStateRunning && a.Next(); {
+		resp, err := e.ec2.Instances([]string{string(inst.Id())}, nil)
+		if ec2ErrCode(err) == "InvalidInstanceID.NotFound" {
+			// The new instance may not be visible yet.
+			continue
+		} else if err != nil {
+			return errors.Annotatef(err, "getting state of instance %s", inst.Id())
+		}
+		for _, r := range resp.Reservations {
+			for i := range r.Instances {
+				if r.Instances[i].InstanceId == string(inst.Id()) {
+					inst.Instance = &r.Instances[i]
+					state = inst.Instance.State.Name
+				}
+			}
+		}
+		switch state {
+		case instanceStateShuttingDown, instanceStateTerminated, "stopping", "stopped":
+			return errors.Errorf("instance %s failed to reach running; it is %s", inst.Id(), state)
+		}
+	}
+	if state != instanceStateRunning {
+		return &instanceBootTimeoutError{
+			id:        inst.Id(),
+			timeout:   timeout,
+			lastState: state,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// runInstancesLaunch identifies a single launch of a machine's instance:
+// one RunInstances request, and any retries of it.
+type runInstancesLaunch struct {
+	controllerUUID string
+	modelUUID      string
+	machineId      string
+	// nonce is the machine's provisioning nonce, which differs each
+	// time the provisioner starts an instance for the machine.
+	nonce        string
+	availZone    string
+	instanceType string
+	// attempt counts the launch retries made when no zone had
+	// capacity for the instance.
+	attempt int
+}
+
+// clientToken returns the ClientToken with which the launch's
+// RunInstances requests are made. EC2 launches at most one instance for
+// each ClientToken, so a request retried after its response was lost
+// returns the instance launched by the first instead of launching
+// another. The token is derived from everything identifying the launch,
+// so that it is the same for each retry but differs between machines,
+// and between launches of a machine in different zones or with
+// different instance types, which EC2 would otherwise reject as
+// mismatching the token's first request.
+func (l runInstancesLaunch) clientToken() string {
+	identity := strings.Join([]string{
+		l.controllerUUID,
+		l.modelUUID,
+		l.machineId,
+		l.nonce,
+		l.availZone,
+		l.instanceType,
+		fmt.Sprint(l.attempt),
+	}, ":")
+	// Tokens are limited to 64 ASCII characters.
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(identity)))
+}
+
+// addParams adds the RunInstances parameter that makes the request
+// with the launch's ClientToken. The EC2 client library has no support
+// for client tokens.
+func (l runInstancesLaunch) addParams(params url.Values) {
+	params.Set("ClientToken", l.clientToken())
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	amzec2 "gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/status"
+	"github.com/juju/juju/testing"
+)
+
+type clientTokenSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&clientTokenSuite{})
+
+var testLaunch = runInstancesLaunch{
+	controllerUUID: testing.ControllerTag.Id(),
+	modelUUID:      testing.ModelTag.Id(),
+	machineId:      "1",
+	nonce:          "machine-0:nonce",
+	availZone:      "us-east-1a",
+	instanceType:   "m4.large",
+}
+
+func (s *clientTokenSuite) TestClientToken(c *gc.C) {
+	token := testLaunch.clientToken()
+	c.Assert(token, gc.HasLen, 64)
+	c.Assert(testLaunch.clientToken(), gc.Equals, token)
+
+	for i, change := range []func(*runInstancesLaunch){
+		func(l *runInstancesLaunch) { l.modelUUID = "f00dbeef-0bad-400d-8000-4b1d0d06f00d" },
+		func(l *runInstancesLaunch) { l.machineId = "2" },
+		func(l *runInstancesLaunch) { l.nonce = "machine-0:other-nonce" },
+		func(l *runInstancesLaunch) { l.availZone = "us-east-1b" },
+		func(l *runInstancesLaunch) { l.instanceType = "m4.xlarge" },
+		func(l *runInstancesLaunch) { l.attempt = 1 },
+	} {
+		c.Logf("test %d", i)
+		launch := testLaunch
+		change(&launch)
+		c.Check(launch.clientToken(), gc.Not(gc.Equals), token)
+	}
+}
+
+func (s *clientTokenSuite) TestAddParams(c *gc.C) {
+	params := make(url.Values)
+	testLaunch.addParams(params)
+	c.Assert(params, jc.DeepEquals, url.Values{
+		"ClientToken": {testLaunch.clientToken()},
+	})
+}
+
+func (s *clientTokenSuite) TestRetriedRunInstancesLaunchesOneInstance(c *gc.C) {
+	// The server launches one instance for each ClientToken, as EC2
+	// does, and the response to the first request is lost.
+	var (
+		mu        sync.Mutex
+		requests  int
+		tokens    []string
+		instances = make(map[string]string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		token := r.URL.Query().Get("ClientToken")
+		tokens = append(tokens, token)
+		instanceId, ok := instances[token]
+		if !ok {
+			instanceId = fmt.Sprintf("i-%d", len(instances))
+			instances[token] = instanceId
+		}
+		mu.Unlock()
+		if first {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintf(w, `
+<RunInstancesResponse>
+  <reservationId>r-0</reservationId>
+  <instancesSet>
+    <item>
+      <instanceId>%s</instanceId>
+    </item>
+  </instancesSet>
+</RunInstancesResponse>`, instanceId)
+	}))
+	defer srv.Close()
+
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(testing.Attrs{
+		"aws-api-read-timeout": "50ms",
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	env := &environ{ecfgUnlocked: ecfg}
+
+	token := testLaunch.clientToken()
+	extras := make(url.Values)
+	testLaunch.addParams(extras)
+	client := amzec2.New(
+		aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		aws.Region{Name: "us-east-1", EC2Endpoint: srv.URL},
+		runInstancesSigner(env.timeoutSigner(aws.SignV4Factory("us-east-1", "ec2")), extras),
+	)
+	callback := func(status.Status, string, map[string]interface{}) error { return nil }
+	resp, err := _runInstances(client, &amzec2.RunInstances{
+		ImageId:  "ami-00000033",
+		MinCount: 1,
+		MaxCount: 1,
+	}, callback)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Instances, gc.HasLen, 1)
+	c.Assert(resp.Instances[0].InstanceId, gc.Equals, "i-0")
+
+	mu.Lock()
+	defer mu.Unlock()
+	c.Assert(tokens, jc.DeepEquals, []string{token, token})
+	c.Assert(instances, gc.HasLen, 1)
+}
@@ -5,13 +5,44 @@ package ec2
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/juju/schema"
+	"github.com/juju/utils/set"
+	"gopkg.in/amz.v3/aws"
 	"gopkg.in/juju/environschema.v1"
 
+	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs/config"
 )
 
+const (
+	// shutdownBehaviorTerminate and shutdownBehaviorStop are the valid
+	// values of instance-initiated-shutdown-behavior.
+	shutdownBehaviorTerminate = "terminate"
+	shutdownBehaviorStop      = "stop"
+
+	// maxInstanceSizeReject and maxInstanceSizeDowngrade are the valid
+	// values of max-instance-size-action.
+	maxInstanceSizeReject    = "reject"
+	maxInstanceSizeDowngrade = "downgrade"
+
+	// defaultBootstrapLaunchRetries is the number of times the bootstrap
+	// instance's launch is retried, if bootstrap-launch-retries is not
+	// specified.
+	defaultBootstrapLaunchRetries = 3
+
+	// defaultMaxRulesPerSecurityGroup is the default quota of inbound
+	// rules per security group in an AWS account.
+	defaultMaxRulesPerSecurityGroup = 60
+
+	// maxRulesPerSecurityGroup is the largest quota of inbound rules per
+	// security group that AWS allows an account.
+	maxRulesPerSecurityGroup = 1000
+)
+
 var configSchema = environschema.Fields{
 	"vpc-id": {
 		Description: "Use a specific AWS VPC ID (optional). When not specified, Juju requires a default VPC or EC2-Classic features to be available for the account/region.",
@@ -26,6 +57,205 @@ var configSchema = environschema.Fields{
 		Group:       environschema.AccountGroup,
 		Immutable:   true,
 	},
+	"ebs-baseline-bandwidth": {
+		Description: "The minimum dedicated EBS bandwidth, in Mbps, required for instances. When non-zero, instances are launched EBS-optimized, using only instance types that provide at least this bandwidth. Zero uses the AWS default behaviour.",
+		Example:     1000,
+		Type:        environschema.Tint,
+		Group:       environschema.AccountGroup,
+	},
+	"instance-store-volumes": {
+		Description: "The number of instance store (ephemeral) volumes to map at launch, as block devices /dev/sdb onwards. When non-zero, only instance types with at least this many instance store volumes are used. Zero maps up to 4, on instance types that have any.",
+		Example:     2,
+		Type:        environschema.Tint,
+		Group:       environschema.AccountGroup,
+	},
+	"max-instances": {
+		Description: "The maximum number of instances the model may have, as a guardrail against runaway scaling. Starting an instance fails once the model has this many pending or running instances; terminated instances are not counted. Zero means no limit.",
+		Example:     20,
+		Type:        environschema.Tint,
+		Group:       environschema.AccountGroup,
+	},
+	"max-rules-per-security-group": {
+		Description: "The largest number of inbound rules to put in each security group of a machine; it should not exceed the account's rules-per-security-group quota. With firewall-mode instance, rules beyond this are put in additional security groups attached to the machine's instance, which are removed again as ports are closed. Defaults to 60, the default quota.",
+		Example:     120,
+		Type:        environschema.Tint,
+		Group:       environschema.AccountGroup,
+	},
+	"bootstrap-launch-retries": {
+		Description: "The number of times to retry launching the bootstrap instance when every availability zone is out of capacity, backing off between attempts. Zero means the bootstrap fails on the first such error.",
+		Example:     5,
+		Type:        environschema.Tint,
+		Group:       environschema.AccountGroup,
+	},
+	"instance-type-fallback": {
+		Description: "A comma-separated list of ladders of instance types, each a \">\"-separated list of instance types of one family, in increasing size. When an instance of a type in a ladder cannot be started in any availability zone because AWS has insufficient capacity, the next type in the ladder is tried instead. The type actually used is reflected in the machine's hardware characteristics. When not specified, no other instance type is tried.",
+		Example:     "m5.large>m5.xlarge>m5.2xlarge,c5.large>c5.xlarge",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"max-instance-size": {
+		Description: "The largest instance size the model may use, as cores and mem constraints, for keeping ephemeral development and test models frugal. An instance type chosen for a machine that has more cores or memory than this is handled as max-instance-size-action says. When not specified, instance sizes are not limited.",
+		Example:     "cores=2 mem=4G",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"max-instance-size-action": {
+		Description: "What happens when the instance type chosen for a machine exceeds max-instance-size: \"reject\" fails to start the machine, and machines whose constraints ask for more are refused up front; \"downgrade\" uses the largest instance type within max-instance-size instead, ignoring the constraints that asked for more. Controller instances are only downgraded when max-instance-size-downgrade-controllers is true, and are otherwise rejected. Defaults to \"reject\".",
+		Example:     "downgrade",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"max-instance-size-downgrade-controllers": {
+		Description: "Whether controller instances may be downgraded when max-instance-size-action is \"downgrade\". Defaults to false.",
+		Type:        environschema.Tbool,
+		Group:       environschema.AccountGroup,
+	},
+	"instance-auto-recovery": {
+		Description: "Whether new instances are recovered automatically by AWS, onto new hardware, when the underlying hardware fails. When true, only instance types that support recovery are used, and no instance store volumes are mapped. Defaults to false.",
+		Type:        environschema.Tbool,
+		Group:       environschema.AccountGroup,
+	},
+	"spot-interruption-notices": {
+		Description: "Whether to look for AWS's two-minute notices that spot instances of the model, such as those launched from a launch template that requests them, are about to be interrupted. An instance with a notice has the instance status \"interrupting\", and spot instances are checked for notices every 30 seconds. Needs the ec2:DescribeSpotInstanceRequests permission. Defaults to false.",
+		Type:        environschema.Tbool,
+		Group:       environschema.AccountGroup,
+	},
+	"detailed-monitoring": {
+		Description: "Whether detailed (1-minute) CloudWatch monitoring is enabled on new instances, rather than basic (5-minute) monitoring. Detailed monitoring incurs additional CloudWatch charges. Defaults to false.",
+		Type:        environschema.Tbool,
+		Group:       environschema.AccountGroup,
+	},
+	"propagate-instance-tags": {
+		Description: "Whether the user tags of an instance, such as cost-center or team, are copied to the EBS volumes created for it, for consistent billing. Juju's own tags, those beginning with \"juju-\", and the Name tag are not copied, and never override the volumes' own. Defaults to false.",
+		Type:        environschema.Tbool,
+		Group:       environschema.AccountGroup,
+	},
+	"ec2-vpc-endpoint": {
+		Description: "The DNS name of an EC2 VPC interface endpoint through which all EC2 and EBS API requests for the model are sent, so that they do not leave the VPC. The endpoint must be in the model's region. When not specified, the region's public endpoint is used.",
+		Example:     "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+		Immutable:   true,
+	},
+	"aws-api-proxy": {
+		Description: "The URL of an HTTP(S) proxy through which all AWS API requests for the model are made. When not specified, the controller's proxy settings apply.",
+		Example:     "http://squid.internal:3128",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"aws-api-no-proxy": {
+		Description: "A comma-separated list of hosts or domains for which aws-api-proxy is not used.",
+		Example:     ".internal,10.0.0.1",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"aws-api-connect-timeout": {
+		Description: "How long an AWS API request may take to connect before it fails, such as \"10s\". Zero means no timeout.",
+		Example:     "10s",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"aws-api-read-timeout": {
+		Description: "How long an AWS API request may take to complete once connected before it fails, such as \"1m\". Zero means no timeout.",
+		Example:     "1m",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"instance-boot-timeout": {
+		Description: "How long a new instance may take to reach the running state, such as \"10m\", before starting it fails. Zero means new instances are not waited for.",
+		Example:     "10m",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"terminate-stuck-instances": {
+		Description: "Whether instances that fail to reach the running state within instance-boot-timeout are terminated, so that they are not leaked. When false, they are left for investigation, and must be terminated by hand. Defaults to true.",
+		Type:        environschema.Tbool,
+		Group:       environschema.AccountGroup,
+	},
+	"dr-secondary-region": {
+		Description: "An AWS region, other than the model's, used for disaster recovery. When a controller is bootstrapped from a custom image, the image is copied to this region, with its tags, so that a replacement controller can be bootstrapped there without first copying it. Images found in simplestreams are already published to every region. When not specified, nothing is copied.",
+		Example:     "us-west-2",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"bastion": {
+		Description: "The bastion host through which Juju reaches machines over SSH, such as when bootstrapping into private subnets: the ID of a running instance in the model's VPC, or a tag it is found by, as \"tag:key=value\". The bastion must have a public address.",
+		Example:     "tag:Name=bastion",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"target-group-arn": {
+		Description: "The ARN of an ELB target group, such as one belonging to a Gateway Load Balancer, into which instances with exposed ports are registered. Requires the instance firewall mode.",
+		Example:     "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/inspection/0123456789abcdef",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"default-root-volume-type": {
+		Description: "The EBS volume type of the root disks of new machines: standard, gp2 or gp3. When not specified, the volume type of the image's root device is used. New models default to gp3.",
+		Example:     "gp3",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"instance-initiated-shutdown-behavior": {
+		Description: "What happens to new instances when they are shut down from within, such as by a workload running \"shutdown\": \"terminate\" or \"stop\". Instances that Juju stops are always terminated.",
+		Example:     "stop",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"controller-subnets": {
+		Description: "A comma-separated list of the IDs of the subnets in which controller machines are started, such as management subnets. The subnets must share an availability zone with those in workload-subnets, if any. When not specified, controllers are started in any suitable subnet.",
+		Example:     "subnet-0a1b2c3d,subnet-1a2b3c4d",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"workload-subnets": {
+		Description: "A comma-separated list of the IDs of the subnets in which machines other than controllers are started. The subnets must share an availability zone with those in controller-subnets, if any. When not specified, machines are started in any suitable subnet.",
+		Example:     "subnet-2a3b4c5d,subnet-3a4b5c6d",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"image-id": {
+		Description: "The AMI from which new instances are started, instead of the image found in simplestreams: either an AMI ID, or the path of an SSM parameter whose value is one, such as those through which the latest Ubuntu AMIs are published. Parameters are resolved each time an instance is started. The AMI must suit the series and architecture of the instances started from it.",
+		Example:     "/aws/service/canonical/ubuntu/server/16.04/stable/current/amd64/hvm/ebs-gp2/ami-id",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"allowed-ami-owners": {
+		Description: "A comma-separated list of the AWS accounts whose AMIs instances may be started from, whether the AMI is found in simplestreams or given with image-id. Each entry is an account ID, or \"self\" for the model's own account, \"canonical\" for the accounts that publish Ubuntu images, or \"amazon\" for AWS. When not specified, AMIs from any account may be used.",
+		Example:     "self,canonical",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"license-configuration-arn": {
+		Description: "The ARN of a License Manager license configuration to associate with launched instances, for bring-your-own-license workloads. When not specified, no license configuration is associated.",
+		Example:     "arn:aws:license-manager:us-east-1:123456789012:license-configuration:lic-0123456789abcdef0123456789abcdef",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"launch-template-id": {
+		Description: "The ID of an EC2 launch template used as the base for launching new instances. Juju's own settings, such as the image, instance type, user data and security groups, take precedence over those in the template. The template must not disable API termination, specify network interfaces, or tag instances with Juju's tags. When not specified, no launch template is used.",
+		Example:     "lt-0123456789abcdef0",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"private-dns-hostname-type": {
+		Description: "The type of the private DNS hostnames of new instances: \"ip-name\", derived from the instance's private IPv4 address, or \"resource-name\", derived from its instance ID. Instances in IPv6-only subnets must use resource-name. When not specified, the subnet's default applies.",
+		Example:     "resource-name",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"private-dns-resource-name-records": {
+		Description: "A comma-separated list of the DNS record types, \"a\" and \"aaaa\", with which queries for new instances' resource names are answered, or \"none\". AAAA records require subnets with an IPv6 CIDR block, and records are only answered in VPCs with DNS support enabled. When not specified, the subnet's defaults apply.",
+		Example:     "a,aaaa",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
+	"launch-template-version": {
+		Description: "The version of the launch-template-id to use: a version number, \"$Latest\" or \"$Default\". When not specified, the template's default version is used.",
+		Example:     "$Latest",
+		Type:        environschema.Tstring,
+		Group:       environschema.AccountGroup,
+	},
 }
 
 var configFields = func() schema.Fields {
@@ -37,8 +267,44 @@ var configFields = func() schema.Fields {
 }()
 
 var configDefaults = schema.Defaults{
-	"vpc-id":       "",
-	"vpc-id-force": false,
+	"vpc-id":                            "",
+	"vpc-id-force":                      false,
+	"ebs-baseline-bandwidth":            0,
+	"instance-store-volumes":            0,
+	"instance-auto-recovery":            false,
+	"detailed-monitoring":               false,
+	"spot-interruption-notices":         false,
+	"propagate-instance-tags":           false,
+	"max-instances":                     0,
+	"max-rules-per-security-group":      defaultMaxRulesPerSecurityGroup,
+	"bootstrap-launch-retries":          defaultBootstrapLaunchRetries,
+	"instance-type-fallback":            "",
+	"max-instance-size":                 "",
+	"max-instance-size-action":          maxInstanceSizeReject,
+	"target-group-arn":                  "",
+	"bastion":                           "",
+	"default-root-volume-type":          "",
+	"license-configuration-arn":         "",
+	"image-id":                          "",
+	"allowed-ami-owners":                "",
+	"launch-template-id":                "",
+	"launch-template-version":           "",
+	"private-dns-hostname-type":         "",
+	"private-dns-resource-name-records": "",
+	"controller-subnets":                "",
+	"workload-subnets":                  "",
+	"ec2-vpc-endpoint":                  "",
+	"aws-api-proxy":                     "",
+	"aws-api-no-proxy":                  "",
+	"aws-api-connect-timeout":           defaultAPIConnectTimeout.String(),
+	"aws-api-read-timeout":              defaultAPIReadTimeout.String(),
+	"instance-boot-timeout":             "0s",
+	"terminate-stuck-instances":         true,
+	"dr-secondary-region":               "",
+
+	"instance-initiated-shutdown-behavior": shutdownBehaviorTerminate,
+
+	"max-instance-size-downgrade-controllers": false,
 }
 
 type environConfig struct {
@@ -54,6 +320,236 @@ func (c *environConfig) forceVPCID() bool {
 	return c.attrs["vpc-id-force"].(bool)
 }
 
+func (c *environConfig) ebsBaselineBandwidth() int {
+	return c.attrs["ebs-baseline-bandwidth"].(int)
+}
+
+func (c *environConfig) instanceStoreVolumes() int {
+	return c.attrs["instance-store-volumes"].(int)
+}
+
+func (c *environConfig) instanceAutoRecovery() bool {
+	return c.attrs["instance-auto-recovery"].(bool)
+}
+
+func (c *environConfig) detailedMonitoring() bool {
+	return c.attrs["detailed-monitoring"].(bool)
+}
+
+func (c *environConfig) spotInterruptionNotices() bool {
+	return c.attrs["spot-interruption-notices"].(bool)
+}
+
+func (c *environConfig) propagateInstanceTags() bool {
+	return c.attrs["propagate-instance-tags"].(bool)
+}
+
+func (c *environConfig) maxInstances() int {
+	return c.attrs["max-instances"].(int)
+}
+
+func (c *environConfig) maxRulesPerSecurityGroup() int {
+	return c.attrs["max-rules-per-security-group"].(int)
+}
+
+func (c *environConfig) bootstrapLaunchRetries() int {
+	return c.attrs["bootstrap-launch-retries"].(int)
+}
+
+// instanceTypeFallback returns the instance type to try, for each
+// instance type in instance-type-fallback, when there is insufficient
+// capacity for it.
+func (c *environConfig) instanceTypeFallback() map[string]string {
+	fallback, _ := parseInstanceTypeFallback(c.attrs["instance-type-fallback"].(string))
+	return fallback
+}
+
+// maxInstanceSize returns the largest number of cores and memory, in
+// MiB, of the instance types the model may use, as constraints. Either
+// is nil if it is not limited.
+func (c *environConfig) maxInstanceSize() constraints.Value {
+	cons, _ := parseMaxInstanceSize(c.attrs["max-instance-size"].(string))
+	return cons
+}
+
+func (c *environConfig) maxInstanceSizeAction() string {
+	return c.attrs["max-instance-size-action"].(string)
+}
+
+func (c *environConfig) maxInstanceSizeDowngradeControllers() bool {
+	return c.attrs["max-instance-size-downgrade-controllers"].(bool)
+}
+
+// parseMaxInstanceSize parses the value of max-instance-size, which may
+// only limit cores and mem.
+func parseMaxInstanceSize(value string) (constraints.Value, error) {
+	cons, err := constraints.Parse(value)
+	if err != nil {
+		return constraints.Value{}, fmt.Errorf("max-instance-size: %v", err)
+	}
+	if value == "" {
+		return cons, nil
+	}
+	if !cons.HasCpuCores() && cons.Mem == nil {
+		return constraints.Value{}, fmt.Errorf("max-instance-size: %q does not limit cores or mem", value)
+	}
+	limits := constraints.Value{CpuCores: cons.CpuCores, Mem: cons.Mem}
+	if limits.String() != cons.String() {
+		return constraints.Value{}, fmt.Errorf("max-instance-size: %q may only limit cores and mem", value)
+	}
+	if (cons.CpuCores != nil && *cons.CpuCores == 0) || (cons.Mem != nil && *cons.Mem == 0) {
+		return constraints.Value{}, fmt.Errorf("max-instance-size: %q must not limit cores or mem to zero", value)
+	}
+	return cons, nil
+}
+
+// parseInstanceTypeFallback parses the value of instance-type-fallback
+// into a map from each instance type of its ladders to the next larger
+// type in the same ladder.
+func parseInstanceTypeFallback(value string) (map[string]string, error) {
+	fallback := make(map[string]string)
+	seen := set.NewStrings()
+	for _, ladder := range splitList(value) {
+		var types []string
+		for _, instanceType := range strings.Split(ladder, ">") {
+			types = append(types, strings.TrimSpace(instanceType))
+		}
+		if len(types) < 2 {
+			return nil, fmt.Errorf("instance-type-fallback: %q must list at least two instance types", ladder)
+		}
+		family := instanceTypeFamily(types[0])
+		for i, instanceType := range types {
+			if instanceType == "" {
+				return nil, fmt.Errorf("instance-type-fallback: %q contains an empty instance type", ladder)
+			}
+			if instanceTypeFamily(instanceType) != family {
+				return nil, fmt.Errorf("instance-type-fallback: %q mixes instance types of families %q and %q",
+					ladder, family, instanceTypeFamily(instanceType))
+			}
+			if seen.Contains(instanceType) {
+				return nil, fmt.Errorf("instance-type-fallback: instance type %q is listed more than once", instanceType)
+			}
+			seen.Add(instanceType)
+			if i > 0 {
+				fallback[types[i-1]] = instanceType
+			}
+		}
+	}
+	return fallback, nil
+}
+
+// instanceTypeFamily returns the family of the given instance type, such
+// as "m5" for "m5.large".
+func instanceTypeFamily(instanceType string) string {
+	if i := strings.Index(instanceType, "."); i >= 0 {
+		return instanceType[:i]
+	}
+	return instanceType
+}
+
+func (c *environConfig) bastion() string {
+	return c.attrs["bastion"].(string)
+}
+
+func (c *environConfig) targetGroupARN() string {
+	return c.attrs["target-group-arn"].(string)
+}
+
+func (c *environConfig) defaultRootVolumeType() string {
+	return c.attrs["default-root-volume-type"].(string)
+}
+
+func (c *environConfig) instanceInitiatedShutdownBehavior() string {
+	return c.attrs["instance-initiated-shutdown-behavior"].(string)
+}
+
+func (c *environConfig) imageID() string {
+	return c.attrs["image-id"].(string)
+}
+
+func (c *environConfig) allowedAMIOwners() []string {
+	return splitList(c.attrs["allowed-ami-owners"].(string))
+}
+
+func (c *environConfig) licenseConfigurationARN() string {
+	return c.attrs["license-configuration-arn"].(string)
+}
+
+func (c *environConfig) launchTemplateID() string {
+	return c.attrs["launch-template-id"].(string)
+}
+
+func (c *environConfig) launchTemplateVersion() string {
+	return c.attrs["launch-template-version"].(string)
+}
+
+func (c *environConfig) privateDNSOptions() privateDNSOptions {
+	// The values are validated in validateConfig.
+	opts, _ := parsePrivateDNSOptions(
+		c.attrs["private-dns-hostname-type"].(string),
+		c.attrs["private-dns-resource-name-records"].(string),
+	)
+	return opts
+}
+
+func (c *environConfig) controllerSubnets() []string {
+	return splitList(c.attrs["controller-subnets"].(string))
+}
+
+func (c *environConfig) workloadSubnets() []string {
+	return splitList(c.attrs["workload-subnets"].(string))
+}
+
+// splitList splits a comma-separated list, such as of subnet IDs,
+// ignoring empty entries.
+func splitList(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (c *environConfig) ec2VPCEndpoint() string {
+	return c.attrs["ec2-vpc-endpoint"].(string)
+}
+
+func (c *environConfig) awsAPIProxy() string {
+	return c.attrs["aws-api-proxy"].(string)
+}
+
+func (c *environConfig) awsAPINoProxy() string {
+	return c.attrs["aws-api-no-proxy"].(string)
+}
+
+func (c *environConfig) awsAPIConnectTimeout() time.Duration {
+	// The value is validated in validateConfig.
+	d, _ := time.ParseDuration(c.attrs["aws-api-connect-timeout"].(string))
+	return d
+}
+
+func (c *environConfig) awsAPIReadTimeout() time.Duration {
+	// The value is validated in validateConfig.
+	d, _ := time.ParseDuration(c.attrs["aws-api-read-timeout"].(string))
+	return d
+}
+
+func (c *environConfig) instanceBootTimeout() time.Duration {
+	// The value is validated in validateConfig.
+	d, _ := time.ParseDuration(c.attrs["instance-boot-timeout"].(string))
+	return d
+}
+
+func (c *environConfig) terminateStuckInstances() bool {
+	return c.attrs["terminate-stuck-instances"].(bool)
+}
+
+func (c *environConfig) drSecondaryRegion() string {
+	return c.attrs["dr-secondary-region"].(string)
+}
+
 func (p environProvider) newConfig(cfg *config.Config) (*environConfig, error) {
 	valid, err := p.Validate(cfg, nil)
 	if err != nil {
@@ -100,6 +596,141 @@ func validateConfig(cfg, old *config.Config) (*environConfig, error) {
 		return nil, fmt.Errorf("cannot use vpc-id-force without specifying vpc-id as well")
 	}
 
+	if bandwidth := ecfg.ebsBaselineBandwidth(); bandwidth < 0 {
+		return nil, fmt.Errorf("ebs-baseline-bandwidth: expected a non-negative value, got %d", bandwidth)
+	}
+
+	if volumes := ecfg.instanceStoreVolumes(); volumes < 0 || volumes > maxInstanceStoreVolumes {
+		return nil, fmt.Errorf("instance-store-volumes: expected a value between 0 and %d, got %d", maxInstanceStoreVolumes, volumes)
+	}
+
+	if max := ecfg.maxInstances(); max < 0 {
+		return nil, fmt.Errorf("max-instances: expected a non-negative value, got %d", max)
+	}
+
+	if max := ecfg.maxRulesPerSecurityGroup(); max < 1 || max > maxRulesPerSecurityGroup {
+		return nil, fmt.Errorf("max-rules-per-security-group: expected a value between 1 and %d, got %d", maxRulesPerSecurityGroup, max)
+	}
+
+	if retries := ecfg.bootstrapLaunchRetries(); retries < 0 {
+		return nil, fmt.Errorf("bootstrap-launch-retries: expected a non-negative value, got %d", retries)
+	}
+
+	if _, err := parseInstanceTypeFallback(ecfg.attrs["instance-type-fallback"].(string)); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseMaxInstanceSize(ecfg.attrs["max-instance-size"].(string)); err != nil {
+		return nil, err
+	}
+
+	switch action := ecfg.maxInstanceSizeAction(); action {
+	case maxInstanceSizeReject, maxInstanceSizeDowngrade:
+	default:
+		return nil, fmt.Errorf("max-instance-size-action: %q is not valid, expected %q or %q",
+			action, maxInstanceSizeReject, maxInstanceSizeDowngrade)
+	}
+
+	if ecfg.instanceAutoRecovery() && ecfg.instanceStoreVolumes() > 0 {
+		return nil, fmt.Errorf("cannot use instance-auto-recovery with instance-store-volumes, as instances with instance store volumes cannot be recovered")
+	}
+
+	if bastion := ecfg.bastion(); bastion != "" && !isValidBastion(bastion) {
+		return nil, fmt.Errorf("bastion: %q is neither an instance ID nor a tag:key=value filter", bastion)
+	}
+
+	if arn := ecfg.targetGroupARN(); arn != "" {
+		if !isTargetGroupARN(arn) {
+			return nil, fmt.Errorf("target-group-arn: %q is not a valid target group ARN", arn)
+		}
+		if ecfg.FirewallMode() != config.FwInstance {
+			return nil, fmt.Errorf("target-group-arn requires firewall-mode %q", config.FwInstance)
+		}
+	}
+
+	switch volumeType := ecfg.defaultRootVolumeType(); volumeType {
+	case "", volumeTypeStandard, volumeTypeGP2, volumeTypeGP3:
+	case volumeTypeST1, volumeTypeSC1:
+		return nil, fmt.Errorf("default-root-volume-type: %q is an HDD volume type, which cannot be used for root volumes", volumeType)
+	default:
+		return nil, fmt.Errorf("default-root-volume-type: %q is not a valid root volume type", volumeType)
+	}
+
+	switch behavior := ecfg.instanceInitiatedShutdownBehavior(); behavior {
+	case shutdownBehaviorTerminate, shutdownBehaviorStop:
+	default:
+		return nil, fmt.Errorf("instance-initiated-shutdown-behavior: %q is not a valid shutdown behavior, expected %q or %q",
+			behavior, shutdownBehaviorTerminate, shutdownBehaviorStop)
+	}
+
+	if arn := ecfg.licenseConfigurationARN(); arn != "" && !isLicenseConfigurationARN(arn) {
+		return nil, fmt.Errorf("license-configuration-arn: %q is not a valid license configuration ARN", arn)
+	}
+
+	if id := ecfg.imageID(); id != "" && !isAMIID(id) && !isSSMParameterPath(id) {
+		return nil, fmt.Errorf("image-id: %q is neither an AMI ID nor an SSM parameter path", id)
+	}
+
+	for _, owner := range ecfg.allowedAMIOwners() {
+		if !isAMIOwner(owner) {
+			return nil, fmt.Errorf("allowed-ami-owners: %q is neither an AWS account ID nor one of %q, %q or %q",
+				owner, amiOwnerSelf, amiOwnerCanonical, amiOwnerAmazon)
+		}
+	}
+
+	if id := ecfg.launchTemplateID(); id != "" && !isLaunchTemplateID(id) {
+		return nil, fmt.Errorf("launch-template-id: %q is not a valid launch template ID", id)
+	}
+	if version := ecfg.launchTemplateVersion(); version != "" {
+		if ecfg.launchTemplateID() == "" {
+			return nil, fmt.Errorf("cannot use launch-template-version without specifying launch-template-id as well")
+		}
+		if !isLaunchTemplateVersion(version) {
+			return nil, fmt.Errorf("launch-template-version: %q is not a valid launch template version, expected a version number, %q or %q",
+				version, launchTemplateVersionLatest, launchTemplateVersionDefault)
+		}
+	}
+
+	if _, err := parsePrivateDNSOptions(
+		ecfg.attrs["private-dns-hostname-type"].(string),
+		ecfg.attrs["private-dns-resource-name-records"].(string),
+	); err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"controller-subnets", "workload-subnets"} {
+		for _, id := range splitList(ecfg.attrs[key].(string)) {
+			if !strings.HasPrefix(id, "subnet-") {
+				return nil, fmt.Errorf("%s: %q is not a valid AWS subnet ID", key, id)
+			}
+		}
+	}
+
+	if endpoint := ecfg.ec2VPCEndpoint(); endpoint != "" && !isVPCEndpointDNSName(endpoint) {
+		return nil, fmt.Errorf("ec2-vpc-endpoint: %q is not the DNS name of an EC2 VPC endpoint", endpoint)
+	}
+
+	if region := ecfg.drSecondaryRegion(); region != "" {
+		if _, ok := aws.Regions[region]; !ok {
+			return nil, fmt.Errorf("dr-secondary-region: %q is not a known AWS region", region)
+		}
+	}
+
+	if proxy := ecfg.awsAPIProxy(); proxy != "" {
+		if u, err := url.Parse(proxy); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("aws-api-proxy: %q is not a valid http or https URL", proxy)
+		}
+	} else if ecfg.awsAPINoProxy() != "" {
+		return nil, fmt.Errorf("cannot use aws-api-no-proxy without specifying aws-api-proxy as well")
+	}
+
+	for _, key := range []string{"aws-api-connect-timeout", "aws-api-read-timeout", "instance-boot-timeout"} {
+		value := ecfg.attrs[key].(string)
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return nil, fmt.Errorf("%s: %q is not a valid non-negative duration", key, value)
+		}
+	}
+
 	if old != nil {
 		attrs := old.UnknownAttrs()
 
@@ -110,6 +741,10 @@ func validateConfig(cfg, old *config.Config) (*environConfig, error) {
 		if forceVPCID, _ := attrs["vpc-id-force"].(bool); forceVPCID != ecfg.forceVPCID() {
 			return nil, fmt.Errorf("cannot change vpc-id-force from %v to %v", forceVPCID, ecfg.forceVPCID())
 		}
+
+		if endpoint, _ := attrs["ec2-vpc-endpoint"].(string); endpoint != ecfg.ec2VPCEndpoint() {
+			return nil, fmt.Errorf("cannot change ec2-vpc-endpoint from %q to %q", endpoint, ecfg.ec2VPCEndpoint())
+		}
 	}
 
 	// ssl-hostname-verification cannot be disabled
@@ -194,6 +194,14 @@ var configTests = []configTest{
 		err:        `.*cannot change vpc-id-force from true to false`,
 		vpcID:      "vpc-unchanged",
 		forceVPCID: true,
+	}, {
+		config: attrs{
+			"ec2-vpc-endpoint": "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+		},
+		change: attrs{
+			"ec2-vpc-endpoint": "",
+		},
+		err: `.*cannot change ec2-vpc-endpoint from "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com" to ""`,
 	}, {
 		config: attrs{
 			"vpc-id": "",
@@ -273,6 +281,484 @@ var configTests = []configTest{
 			"ssl-hostname-verification": false,
 		},
 		err: ".*disabling ssh-hostname-verification is not supported",
+	}, {
+		config: attrs{
+			"ebs-baseline-bandwidth": 1000,
+		},
+		expect: attrs{
+			"ebs-baseline-bandwidth": 1000,
+		},
+	}, {
+		config: attrs{
+			"ebs-baseline-bandwidth": -1,
+		},
+		err: ".*ebs-baseline-bandwidth: expected a non-negative value, got -1",
+	}, {
+		config: attrs{
+			"instance-store-volumes": 2,
+		},
+		expect: attrs{
+			"instance-store-volumes": 2,
+		},
+	}, {
+		config: attrs{
+			"instance-store-volumes": -1,
+		},
+		err: ".*instance-store-volumes: expected a value between 0 and 24, got -1",
+	}, {
+		config: attrs{
+			"instance-store-volumes": 25,
+		},
+		err: ".*instance-store-volumes: expected a value between 0 and 24, got 25",
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"max-instances": 0,
+		},
+	}, {
+		config: attrs{
+			"max-instances": 20,
+		},
+		expect: attrs{
+			"max-instances": 20,
+		},
+	}, {
+		config: attrs{
+			"max-instances": -1,
+		},
+		err: ".*max-instances: expected a non-negative value, got -1",
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"max-rules-per-security-group": 60,
+		},
+	}, {
+		config: attrs{
+			"max-rules-per-security-group": 120,
+		},
+		expect: attrs{
+			"max-rules-per-security-group": 120,
+		},
+	}, {
+		config: attrs{
+			"max-rules-per-security-group": 0,
+		},
+		err: ".*max-rules-per-security-group: expected a value between 1 and 1000, got 0",
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"bootstrap-launch-retries": 3,
+		},
+	}, {
+		config: attrs{
+			"bootstrap-launch-retries": 0,
+		},
+		expect: attrs{
+			"bootstrap-launch-retries": 0,
+		},
+	}, {
+		config: attrs{
+			"bootstrap-launch-retries": -1,
+		},
+		err: ".*bootstrap-launch-retries: expected a non-negative value, got -1",
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"instance-type-fallback": "",
+		},
+	}, {
+		config: attrs{
+			"instance-type-fallback": "m5.large>m5.xlarge>m5.2xlarge, c5.large > c5.xlarge",
+		},
+		expect: attrs{
+			"instance-type-fallback": "m5.large>m5.xlarge>m5.2xlarge, c5.large > c5.xlarge",
+		},
+	}, {
+		config: attrs{
+			"instance-type-fallback": "m5.large",
+		},
+		err: `.*instance-type-fallback: "m5.large" must list at least two instance types`,
+	}, {
+		config: attrs{
+			"instance-type-fallback": "m5.large>>m5.2xlarge",
+		},
+		err: `.*instance-type-fallback: "m5.large>>m5.2xlarge" contains an empty instance type`,
+	}, {
+		config: attrs{
+			"instance-type-fallback": "m5.large>c5.xlarge",
+		},
+		err: `.*instance-type-fallback: "m5.large>c5.xlarge" mixes instance types of families "m5" and "c5"`,
+	}, {
+		config: attrs{
+			"instance-type-fallback": "m5.large>m5.xlarge,m5.xlarge>m5.2xlarge",
+		},
+		err: `.*instance-type-fallback: instance type "m5.xlarge" is listed more than once`,
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"max-instance-size":                       "",
+			"max-instance-size-action":                "reject",
+			"max-instance-size-downgrade-controllers": false,
+		},
+	}, {
+		config: attrs{
+			"max-instance-size":                       "cores=2 mem=4G",
+			"max-instance-size-action":                "downgrade",
+			"max-instance-size-downgrade-controllers": true,
+		},
+		expect: attrs{
+			"max-instance-size":                       "cores=2 mem=4G",
+			"max-instance-size-action":                "downgrade",
+			"max-instance-size-downgrade-controllers": true,
+		},
+	}, {
+		config: attrs{
+			"max-instance-size": "cores=2 arch=amd64",
+		},
+		err: `.*max-instance-size: "cores=2 arch=amd64" may only limit cores and mem`,
+	}, {
+		config: attrs{
+			"max-instance-size": "root-disk=8G",
+		},
+		err: `.*max-instance-size: "root-disk=8G" does not limit cores or mem`,
+	}, {
+		config: attrs{
+			"max-instance-size": "mem=0",
+		},
+		err: `.*max-instance-size: "mem=0" must not limit cores or mem to zero`,
+	}, {
+		config: attrs{
+			"max-instance-size": "mem=lots",
+		},
+		err: `.*max-instance-size: bad "mem" constraint: .*`,
+	}, {
+		config: attrs{
+			"max-instance-size-action": "shrink",
+		},
+		err: `.*max-instance-size-action: "shrink" is not valid, expected "reject" or "downgrade"`,
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"instance-auto-recovery": false,
+		},
+	}, {
+		config: attrs{
+			"instance-auto-recovery": true,
+		},
+		expect: attrs{
+			"instance-auto-recovery": true,
+		},
+	}, {
+		config: attrs{
+			"instance-auto-recovery": true,
+			"instance-store-volumes": 2,
+		},
+		err: ".*cannot use instance-auto-recovery with instance-store-volumes, as instances with instance store volumes cannot be recovered",
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"detailed-monitoring": false,
+		},
+	}, {
+		config: attrs{
+			"detailed-monitoring": true,
+		},
+		expect: attrs{
+			"detailed-monitoring": true,
+		},
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"spot-interruption-notices": false,
+		},
+	}, {
+		config: attrs{
+			"spot-interruption-notices": true,
+		},
+		expect: attrs{
+			"spot-interruption-notices": true,
+		},
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"propagate-instance-tags": false,
+		},
+	}, {
+		config: attrs{
+			"propagate-instance-tags": true,
+		},
+		expect: attrs{
+			"propagate-instance-tags": true,
+		},
+	}, {
+		config: attrs{
+			"target-group-arn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/inspection/0123456789abcdef",
+		},
+		expect: attrs{
+			"target-group-arn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/inspection/0123456789abcdef",
+		},
+	}, {
+		config: attrs{
+			"target-group-arn": "arn:aws:ec2:us-east-1:123456789012:instance/i-0123456789abcdef",
+		},
+		err: `.*target-group-arn: "arn:aws:ec2:us-east-1:123456789012:instance/i-0123456789abcdef" is not a valid target group ARN`,
+	}, {
+		config: attrs{
+			"target-group-arn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/inspection/0123456789abcdef",
+			"firewall-mode":    "global",
+		},
+		err: `.*target-group-arn requires firewall-mode "instance"`,
+	}, {
+		config: attrs{
+			"image-id": "ami-0123456789abcdef0",
+		},
+		expect: attrs{
+			"image-id": "ami-0123456789abcdef0",
+		},
+	}, {
+		config: attrs{
+			"image-id": "/aws/service/canonical/ubuntu/server/16.04/stable/current/amd64/hvm/ebs-gp2/ami-id",
+		},
+		expect: attrs{
+			"image-id": "/aws/service/canonical/ubuntu/server/16.04/stable/current/amd64/hvm/ebs-gp2/ami-id",
+		},
+	}, {
+		config: attrs{
+			"image-id": "ubuntu-latest",
+		},
+		err: `.*image-id: "ubuntu-latest" is neither an AMI ID nor an SSM parameter path`,
+	}, {
+		config: attrs{
+			"allowed-ami-owners": "self, canonical,123456789012",
+		},
+		expect: attrs{
+			"allowed-ami-owners": "self, canonical,123456789012",
+		},
+	}, {
+		config: attrs{
+			"allowed-ami-owners": "self,aws-marketplace",
+		},
+		err: `.*allowed-ami-owners: "aws-marketplace" is neither an AWS account ID nor one of "self", "canonical" or "amazon"`,
+	}, {
+		config: attrs{
+			"license-configuration-arn": "arn:aws:license-manager:us-east-1:123456789012:license-configuration:lic-0123456789abcdef",
+		},
+		expect: attrs{
+			"license-configuration-arn": "arn:aws:license-manager:us-east-1:123456789012:license-configuration:lic-0123456789abcdef",
+		},
+	}, {
+		config: attrs{
+			"license-configuration-arn": "lic-0123456789abcdef",
+		},
+		err: `.*license-configuration-arn: "lic-0123456789abcdef" is not a valid license configuration ARN`,
+	}, {
+		config: attrs{
+			"launch-template-id": "lt-0123456789abcdef0",
+		},
+		expect: attrs{
+			"launch-template-id":      "lt-0123456789abcdef0",
+			"launch-template-version": "",
+		},
+	}, {
+		config: attrs{
+			"launch-template-id":      "lt-0123456789abcdef0",
+			"launch-template-version": "$Latest",
+		},
+		expect: attrs{
+			"launch-template-id":      "lt-0123456789abcdef0",
+			"launch-template-version": "$Latest",
+		},
+	}, {
+		config: attrs{
+			"launch-template-id":      "lt-0123456789abcdef0",
+			"launch-template-version": "3",
+		},
+		expect: attrs{
+			"launch-template-version": "3",
+		},
+	}, {
+		config: attrs{
+			"launch-template-id": "standard-launch",
+		},
+		err: `.*launch-template-id: "standard-launch" is not a valid launch template ID`,
+	}, {
+		config: attrs{
+			"launch-template-id":      "lt-0123456789abcdef0",
+			"launch-template-version": "latest",
+		},
+		err: `.*launch-template-version: "latest" is not a valid launch template version, expected a version number, "\$Latest" or "\$Default"`,
+	}, {
+		config: attrs{
+			"launch-template-version": "3",
+		},
+		err: `.*cannot use launch-template-version without specifying launch-template-id as well`,
+	}, {
+		config: attrs{
+			"private-dns-hostname-type":         "resource-name",
+			"private-dns-resource-name-records": "a,aaaa",
+		},
+		expect: attrs{
+			"private-dns-hostname-type":         "resource-name",
+			"private-dns-resource-name-records": "a,aaaa",
+		},
+	}, {
+		config: attrs{
+			"private-dns-hostname-type": "instance-id",
+		},
+		err: `.*private-dns-hostname-type: "instance-id" is not a valid hostname type, expected "ip-name" or "resource-name"`,
+	}, {
+		config: attrs{
+			"private-dns-resource-name-records": "a,cname",
+		},
+		err: `.*private-dns-resource-name-records: "cname" is not a valid record type, expected "a", "aaaa" or "none"`,
+	}, {
+		config: attrs{
+			"private-dns-resource-name-records": "none,a",
+		},
+		err: `.*private-dns-resource-name-records: "none" cannot be combined with other record types`,
+	}, {
+		config: attrs{
+			"controller-subnets": "subnet-0a1b2c3d",
+			"workload-subnets":   "subnet-1a2b3c4d, subnet-2a3b4c5d",
+		},
+		expect: attrs{
+			"controller-subnets": "subnet-0a1b2c3d",
+			"workload-subnets":   "subnet-1a2b3c4d, subnet-2a3b4c5d",
+		},
+	}, {
+		config: attrs{
+			"workload-subnets": "subnet-1a2b3c4d,0.1.2.0/24",
+		},
+		err: `.*workload-subnets: "0.1.2.0/24" is not a valid AWS subnet ID`,
+	}, {
+		config: attrs{
+			"ec2-vpc-endpoint": "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+		},
+		expect: attrs{
+			"ec2-vpc-endpoint": "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+		},
+	}, {
+		config: attrs{
+			"ec2-vpc-endpoint": "https://ec2.us-east-1.amazonaws.com",
+		},
+		err: `.*ec2-vpc-endpoint: "https://ec2.us-east-1.amazonaws.com" is not the DNS name of an EC2 VPC endpoint`,
+	}, {
+		config: attrs{
+			"aws-api-connect-timeout": "5s",
+			"aws-api-read-timeout":    "30s",
+		},
+		expect: attrs{
+			"aws-api-connect-timeout": "5s",
+			"aws-api-read-timeout":    "30s",
+		},
+	}, {
+		config: attrs{
+			"aws-api-connect-timeout": "soon",
+		},
+		err: `.*aws-api-connect-timeout: "soon" is not a valid non-negative duration`,
+	}, {
+		config: attrs{
+			"aws-api-read-timeout": "-1s",
+		},
+		err: `.*aws-api-read-timeout: "-1s" is not a valid non-negative duration`,
+	}, {
+		config: attrs{
+			"instance-boot-timeout":     "10m",
+			"terminate-stuck-instances": false,
+		},
+		expect: attrs{
+			"instance-boot-timeout":     "10m",
+			"terminate-stuck-instances": false,
+		},
+	}, {
+		config: attrs{
+			"instance-boot-timeout": "forever",
+		},
+		err: `.*instance-boot-timeout: "forever" is not a valid non-negative duration`,
+	}, {
+		config: attrs{
+			"dr-secondary-region": "us-west-2",
+		},
+		expect: attrs{
+			"dr-secondary-region": "us-west-2",
+		},
+	}, {
+		config: attrs{
+			"dr-secondary-region": "us-west-9",
+		},
+		err: `.*dr-secondary-region: "us-west-9" is not a known AWS region`,
+	}, {
+		config: attrs{
+			"bastion": "i-0123456789abcdef0",
+		},
+		expect: attrs{
+			"bastion": "i-0123456789abcdef0",
+		},
+	}, {
+		config: attrs{
+			"bastion": "tag:Name=bastion",
+		},
+		expect: attrs{
+			"bastion": "tag:Name=bastion",
+		},
+	}, {
+		config: attrs{
+			"bastion": "bastion.example.com",
+		},
+		err: `.*bastion: "bastion.example.com" is neither an instance ID nor a tag:key=value filter`,
+	}, {
+		config: attrs{
+			"default-root-volume-type": "gp3",
+		},
+		expect: attrs{
+			"default-root-volume-type": "gp3",
+		},
+	}, {
+		config: attrs{
+			"default-root-volume-type": "io1",
+		},
+		err: `.*default-root-volume-type: "io1" is not a valid root volume type`,
+	}, {
+		config: attrs{
+			"default-root-volume-type": "st1",
+		},
+		err: `.*default-root-volume-type: "st1" is an HDD volume type, which cannot be used for root volumes`,
+	}, {
+		config: attrs{},
+		expect: attrs{
+			"instance-initiated-shutdown-behavior": "terminate",
+		},
+	}, {
+		config: attrs{
+			"instance-initiated-shutdown-behavior": "stop",
+		},
+		expect: attrs{
+			"instance-initiated-shutdown-behavior": "stop",
+		},
+	}, {
+		config: attrs{
+			"instance-initiated-shutdown-behavior": "hibernate",
+		},
+		err: `.*instance-initiated-shutdown-behavior: "hibernate" is not a valid shutdown behavior, expected "terminate" or "stop"`,
+	}, {
+		config: attrs{
+			"aws-api-proxy":    "http://squid.internal:3128",
+			"aws-api-no-proxy": ".internal",
+		},
+		expect: attrs{
+			"aws-api-proxy":    "http://squid.internal:3128",
+			"aws-api-no-proxy": ".internal",
+		},
+	}, {
+		config: attrs{
+			"aws-api-proxy": "squid.internal:3128",
+		},
+		err: `.*aws-api-proxy: "squid.internal:3128" is not a valid http or https URL`,
+	}, {
+		config: attrs{
+			"aws-api-no-proxy": ".internal",
+		},
+		err: ".*cannot use aws-api-no-proxy without specifying aws-api-proxy as well",
 	}, {
 		config: attrs{
 			"future": "hammerstein",
@@ -318,6 +804,20 @@ func (s *ConfigSuite) TestConfig(c *gc.C) {
 	}
 }
 
+func (s *ConfigSuite) TestInstanceTypeFallback(c *gc.C) {
+	fallback, err := parseInstanceTypeFallback("m5.large>m5.xlarge>m5.2xlarge,c5.large>c5.xlarge")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fallback, jc.DeepEquals, map[string]string{
+		"m5.large":  "m5.xlarge",
+		"m5.xlarge": "m5.2xlarge",
+		"c5.large":  "c5.xlarge",
+	})
+
+	fallback, err = parseInstanceTypeFallback("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fallback, gc.HasLen, 0)
+}
+
 func (s *ConfigSuite) TestPrepareConfigSetsDefaultBlockSource(c *gc.C) {
 	s.PatchValue(&verifyCredentials, func(*environ) error { return nil })
 	attrs := testing.FakeConfig().Merge(testing.Attrs{
@@ -348,6 +848,120 @@ func (s *ConfigSuite) TestPrepareConfigSetsDefaultBlockSource(c *gc.C) {
 	c.Assert(source, gc.Equals, "ebs")
 }
 
+func (s *ConfigSuite) TestPrepareConfigSetsDefaultRootVolumeType(c *gc.C) {
+	s.PatchValue(&verifyCredentials, func(*environ) error { return nil })
+	credential := cloud.NewCredential(
+		cloud.AccessKeyAuthType,
+		map[string]string{
+			"access-key": "x",
+			"secret-key": "y",
+		},
+	)
+	for _, t := range []struct {
+		configured string
+		expected   string
+	}{
+		{"", "gp3"},
+		{"gp2", "gp2"},
+	} {
+		c.Logf("configured %q", t.configured)
+		attrs := testing.FakeConfig().Merge(testing.Attrs{
+			"type":                     "ec2",
+			"default-root-volume-type": t.configured,
+		})
+		cfg, err := config.New(config.NoDefaults, attrs)
+		c.Assert(err, jc.ErrorIsNil)
+
+		cfg, err = providerInstance.PrepareConfig(environs.PrepareConfigParams{
+			Config: cfg,
+			Cloud: environs.CloudSpec{
+				Type:       "ec2",
+				Name:       "aws",
+				Region:     "test",
+				Credential: &credential,
+			},
+		})
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(cfg.UnknownAttrs()["default-root-volume-type"], gc.Equals, t.expected)
+	}
+}
+
+func (s *ConfigSuite) TestPrepareConfigValidatesVPCEndpointRegion(c *gc.C) {
+	credential := cloud.NewCredential(
+		cloud.AccessKeyAuthType,
+		map[string]string{
+			"access-key": "x",
+			"secret-key": "y",
+		},
+	)
+	attrs := testing.FakeConfig().Merge(testing.Attrs{
+		"type":             "ec2",
+		"ec2-vpc-endpoint": "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+	})
+	cfg, err := config.New(config.NoDefaults, attrs)
+	c.Assert(err, jc.ErrorIsNil)
+
+	prepare := func(region string) error {
+		_, err := providerInstance.PrepareConfig(environs.PrepareConfigParams{
+			Config: cfg,
+			Cloud: environs.CloudSpec{
+				Type:       "ec2",
+				Name:       "aws",
+				Region:     region,
+				Credential: &credential,
+			},
+		})
+		return err
+	}
+	c.Assert(prepare("us-east-1"), jc.ErrorIsNil)
+	c.Assert(prepare("eu-west-1"), gc.ErrorMatches,
+		`ec2-vpc-endpoint: "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com" is in region "us-east-1", but the model is in region "eu-west-1"`)
+}
+
+func (s *ConfigSuite) TestPrepareConfigValidatesDRSecondaryRegion(c *gc.C) {
+	credential := cloud.NewCredential(
+		cloud.AccessKeyAuthType,
+		map[string]string{
+			"access-key": "x",
+			"secret-key": "y",
+		},
+	)
+	attrs := testing.FakeConfig().Merge(testing.Attrs{
+		"type":                "ec2",
+		"dr-secondary-region": "us-west-2",
+	})
+	cfg, err := config.New(config.NoDefaults, attrs)
+	c.Assert(err, jc.ErrorIsNil)
+
+	prepare := func(region string) error {
+		_, err := providerInstance.PrepareConfig(environs.PrepareConfigParams{
+			Config: cfg,
+			Cloud: environs.CloudSpec{
+				Type:       "ec2",
+				Name:       "aws",
+				Region:     region,
+				Credential: &credential,
+			},
+		})
+		return err
+	}
+	c.Assert(prepare("us-east-1"), jc.ErrorIsNil)
+	c.Assert(prepare("us-west-2"), gc.ErrorMatches, `dr-secondary-region: "us-west-2" is the model's own region`)
+}
+
+func (s *ConfigSuite) TestExistingModelKeepsImageRootVolumeType(c *gc.C) {
+	// Models created before default-root-volume-type existed were
+	// never prepared with it, so they keep the image's volume type.
+	attrs := testing.FakeConfig().Merge(testing.Attrs{
+		"type": "ec2",
+	})
+	cfg, err := config.New(config.NoDefaults, attrs)
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ecfg.defaultRootVolumeType(), gc.Equals, "")
+}
+
 func (s *ConfigSuite) TestPrepareSetsDefaultBlockSource(c *gc.C) {
 	s.PatchValue(&verifyCredentials, func(*environ) error { return nil })
 	attrs := testing.FakeConfig().Merge(testing.Attrs{
@@ -0,0 +1,60 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/network"
+)
+
+var _ environs.ControllerPortsFirewaller = (*environ)(nil)
+
+// RestrictControllerPorts is part of the environs.ControllerPortsFirewaller
+// interface. The controller's ports are opened to all sources in the
+// model's Juju security group, which every machine is in, when machines
+// are provisioned; that group's rules for the ports are replaced with
+// ones for the given sources.
+func (e *environ) RestrictControllerPorts(ports []network.PortRange, sourceCIDRs []string) error {
+	if len(sourceCIDRs) == 0 {
+		sourceCIDRs = []string{defaultRouteCIDRBlock}
+	}
+	group, err := e.groupInfoByName(e.jujuGroupName())
+	if err != nil {
+		return errors.Annotate(err, "cannot restrict controller ports")
+	}
+	wanted := set.NewStrings(sourceCIDRs...)
+	var toOpen, toClose []network.IngressRule
+	for _, portRange := range ports {
+		current := set.NewStrings()
+		for _, p := range group.IPPerms {
+			if ec2ProtocolName(p.Protocol) == portRange.Protocol &&
+				p.FromPort == portRange.FromPort && p.ToPort == portRange.ToPort {
+				current = current.Union(set.NewStrings(p.SourceIPs...))
+			}
+		}
+		if open := wanted.Difference(current); !open.IsEmpty() {
+			toOpen = append(toOpen, network.IngressRule{PortRange: portRange, SourceCIDRs: open.SortedValues()})
+		}
+		if close := current.Difference(wanted); !close.IsEmpty() {
+			toClose = append(toClose, network.IngressRule{PortRange: portRange, SourceCIDRs: close.SortedValues()})
+		}
+	}
+	// The new sources are allowed before the others are revoked, so
+	// the ports are never closed to all of them.
+	if len(toOpen) > 0 {
+		if err := e.authorizeIngress(group.SecurityGroup, rulesToIPPerms(toOpen)); err != nil {
+			return errors.Annotate(err, "cannot restrict controller ports")
+		}
+	}
+	if len(toClose) > 0 {
+		if _, err := e.ec2.RevokeSecurityGroup(group.SecurityGroup, rulesToIPPerms(toClose)); err != nil {
+			return errors.Annotate(err, "cannot restrict controller ports")
+		}
+	}
+	logger.Infof("restricted controller ports %v to %v", ports, sourceCIDRs)
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/instances"
+)
+
+// dedicatedHostAPIVersion is the EC2 API version used to describe
+// Dedicated Hosts; the version used by the EC2 client library predates
+// them.
+const dedicatedHostAPIVersion = "2016-11-15"
+
+// The host affinities that may be given with the host placement
+// directive. With host affinity, an instance that is stopped always
+// restarts on the same Dedicated Host, as licenses bound to the host
+// require; with default affinity, it may restart on another host.
+const (
+	hostAffinityHost    = "host"
+	hostAffinityDefault = "default"
+)
+
+// dedicatedHostAPI is the subset of the EC2 API used to inspect the
+// Dedicated Hosts that instances are placed on.
+type dedicatedHostAPI interface {
+	// DescribeHost returns the details of the given Dedicated Host.
+	DescribeHost(hostId string) (*dedicatedHost, error)
+}
+
+// dedicatedHost holds the details of a Dedicated Host that determine
+// which instances may be placed on it.
+type dedicatedHost struct {
+	Id               string `xml:"hostId"`
+	State            string `xml:"state"`
+	AvailabilityZone string `xml:"availabilityZone"`
+	InstanceType     string `xml:"hostProperties>instanceType"`
+	InstanceFamily   string `xml:"hostProperties>instanceFamily"`
+}
+
+// supportsInstanceType reports whether instances of the named type can
+// be placed on the host. Hosts support either a single instance type, or
+// every size of an instance family.
+func (h *dedicatedHost) supportsInstanceType(instanceType string) bool {
+	if h.InstanceType != "" {
+		return instanceType == h.InstanceType
+	}
+	return h.InstanceFamily != "" && strings.HasPrefix(instanceType, h.InstanceFamily+".")
+}
+
+// newDedicatedHostAPI returns a dedicatedHostAPI for the given cloud,
+// whose request signer is wrapped with wrapSigner. It is a variable so
+// it can be replaced in tests.
+var newDedicatedHostAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) dedicatedHostAPI {
+	return &dedicatedHostClient{newEC2Client(cloud, dedicatedHostAPIVersion, wrapSigner)}
+}
+
+// dedicatedHostClient is a minimal client for the EC2 query API, used
+// to describe Dedicated Hosts.
+type dedicatedHostClient struct {
+	*awsAPIClient
+}
+
+// DescribeHost is part of the dedicatedHostAPI interface.
+func (c *dedicatedHostClient) DescribeHost(hostId string) (*dedicatedHost, error) {
+	params := url.Values{"HostId.1": {hostId}}
+	var resp struct {
+		Hosts []dedicatedHost `xml:"hostSet>item"`
+	}
+	if err := c.query("DescribeHosts", params, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && strings.HasPrefix(err.Code, "InvalidHostID.") {
+			return nil, errors.NotFoundf("dedicated host %q", hostId)
+		}
+		return nil, errors.Annotatef(err, "describing dedicated host %q", hostId)
+	}
+	if len(resp.Hosts) == 0 {
+		return nil, errors.NotFoundf("dedicated host %q", hostId)
+	}
+	return &resp.Hosts[0], nil
+}
+
+// hostPlacement records the Dedicated Host, and the affinity with it,
+// given with a host placement directive.
+type hostPlacement struct {
+	hostId   string
+	affinity string
+
+	// host holds the details of the Dedicated Host, once they have
+	// been looked up.
+	host *dedicatedHost
+}
+
+// parseHostPlacement parses the value of a host placement directive,
+// of the form <host-id>[,affinity=<host|default>]. Without an explicit
+// affinity, instances have host affinity, so that those whose licenses
+// are bound to the host always restart on it.
+func parseHostPlacement(value string) (*hostPlacement, error) {
+	parts := strings.Split(value, ",")
+	p := &hostPlacement{hostId: parts[0], affinity: hostAffinityHost}
+	if !strings.HasPrefix(p.hostId, "h-") || len(p.hostId) == len("h-") {
+		return nil, errors.Errorf("host placement: %q is not a valid dedicated host ID", p.hostId)
+	}
+	for _, part := range parts[1:] {
+		pos := strings.IndexRune(part, '=')
+		if pos == -1 || part[:pos] != "affinity" {
+			return nil, errors.Errorf("host placement: unknown option %q, expected affinity=<host|default>", part)
+		}
+		switch affinity := part[pos+1:]; affinity {
+		case hostAffinityHost, hostAffinityDefault:
+			p.affinity = affinity
+		default:
+			return nil, errors.Errorf("host placement: affinity %q is not valid, expected %q or %q", affinity, hostAffinityHost, hostAffinityDefault)
+		}
+	}
+	return p, nil
+}
+
+// lookupDedicatedHost records the details of the Dedicated Host given
+// with a host placement directive, checking that instances can be placed
+// on it.
+func (e *environ) lookupDedicatedHost(p *hostPlacement) error {
+	host, err := e.dedicatedHosts.DescribeHost(p.hostId)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if host.State != availableState {
+		return errors.Errorf("dedicated host %q is %q", host.Id, host.State)
+	}
+	if host.InstanceType == "" && host.InstanceFamily == "" {
+		return errors.Errorf("dedicated host %q does not report the instance types it supports", host.Id)
+	}
+	p.host = host
+	return nil
+}
+
+// instanceTypesForDedicatedHost returns the subset of the given instance
+// types that can be placed on the given Dedicated Host.
+func instanceTypesForDedicatedHost(instanceTypes []instances.InstanceType, host *dedicatedHost) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if host.supportsInstanceType(instanceType.Name) {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// addParams adds the RunInstances parameters that place the instance
+// on the Dedicated Host, with the placement's affinity. The EC2 client
+// library has no support for Dedicated Hosts.
+func (p *hostPlacement) addParams(params url.Values) {
+	params.Set("Placement.Tenancy", "host")
+	params.Set("Placement.HostId", p.hostId)
+	params.Set("Placement.Affinity", p.affinity)
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/instances"
+	"github.com/juju/juju/testing"
+)
+
+type dedicatedHostSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *dedicatedHostClient
+}
+
+var _ = gc.Suite(&dedicatedHostSuite{})
+
+func (s *dedicatedHostSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &dedicatedHostClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  dedicatedHostAPIVersion,
+	}}
+}
+
+func (s *dedicatedHostSuite) TestDescribeHost(c *gc.C) {
+	s.responses = []string{`
+<DescribeHostsResponse>
+  <hostSet>
+    <item>
+      <hostId>h-0123456789abcdef0</hostId>
+      <state>available</state>
+      <availabilityZone>us-east-1b</availabilityZone>
+      <hostProperties>
+        <instanceFamily>m5</instanceFamily>
+        <sockets>2</sockets>
+      </hostProperties>
+    </item>
+  </hostSet>
+</DescribeHostsResponse>`}
+	host, err := s.client.DescribeHost("h-0123456789abcdef0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(host, jc.DeepEquals, &dedicatedHost{
+		Id:               "h-0123456789abcdef0",
+		State:            "available",
+		AvailabilityZone: "us-east-1b",
+		InstanceFamily:   "m5",
+	})
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeHosts")
+	c.Assert(s.requests[0].Get("Version"), gc.Equals, dedicatedHostAPIVersion)
+	c.Assert(s.requests[0].Get("HostId.1"), gc.Equals, "h-0123456789abcdef0")
+}
+
+func (s *dedicatedHostSuite) TestDescribeHostNotFound(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.responses = []string{`
+<Response><Errors><Error>
+  <Code>InvalidHostID.NotFound</Code>
+  <Message>The host ID 'h-0123456789abcdef0' was not found</Message>
+</Error></Errors></Response>`}
+	_, err := s.client.DescribeHost("h-0123456789abcdef0")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `dedicated host "h-0123456789abcdef0" not found`)
+}
+
+func (s *dedicatedHostSuite) TestDescribeHostError(c *gc.C) {
+	s.status = http.StatusForbidden
+	s.responses = []string{`
+<Response><Errors><Error>
+  <Code>UnauthorizedOperation</Code>
+  <Message>You are not authorized to perform this operation.</Message>
+</Error></Errors></Response>`}
+	_, err := s.client.DescribeHost("h-0123456789abcdef0")
+	c.Assert(err, gc.ErrorMatches, `describing dedicated host "h-0123456789abcdef0": You are not authorized to perform this operation. \(UnauthorizedOperation\)`)
+}
+
+func (s *dedicatedHostSuite) TestParseHostPlacement(c *gc.C) {
+	for i, test := range []struct {
+		value  string
+		expect *hostPlacement
+		err    string
+	}{{
+		value:  "h-0123456789abcdef0",
+		expect: &hostPlacement{hostId: "h-0123456789abcdef0", affinity: "host"},
+	}, {
+		value:  "h-0123456789abcdef0,affinity=host",
+		expect: &hostPlacement{hostId: "h-0123456789abcdef0", affinity: "host"},
+	}, {
+		value:  "h-0123456789abcdef0,affinity=default",
+		expect: &hostPlacement{hostId: "h-0123456789abcdef0", affinity: "default"},
+	}, {
+		value: "i-0123456789abcdef0",
+		err:   `host placement: "i-0123456789abcdef0" is not a valid dedicated host ID`,
+	}, {
+		value: "h-",
+		err:   `host placement: "h-" is not a valid dedicated host ID`,
+	}, {
+		value: "h-0123456789abcdef0,affinity=sticky",
+		err:   `host placement: affinity "sticky" is not valid, expected "host" or "default"`,
+	}, {
+		value: "h-0123456789abcdef0,tenancy=host",
+		err:   `host placement: unknown option "tenancy=host", expected affinity=<host\|default>`,
+	}} {
+		c.Logf("test %d: %q", i, test.value)
+		p, err := parseHostPlacement(test.value)
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(p, jc.DeepEquals, test.expect)
+	}
+}
+
+type fakeDedicatedHostAPI struct {
+	host *dedicatedHost
+	err  error
+}
+
+func (f *fakeDedicatedHostAPI) DescribeHost(hostId string) (*dedicatedHost, error) {
+	return f.host, f.err
+}
+
+func (s *dedicatedHostSuite) TestLookupDedicatedHost(c *gc.C) {
+	host := &dedicatedHost{
+		Id:               "h-0123456789abcdef0",
+		State:            "available",
+		AvailabilityZone: "us-east-1b",
+		InstanceType:     "m5.large",
+	}
+	env := &environ{dedicatedHosts: &fakeDedicatedHostAPI{host: host}}
+	p := &hostPlacement{hostId: "h-0123456789abcdef0", affinity: "host"}
+	c.Assert(env.lookupDedicatedHost(p), jc.ErrorIsNil)
+	c.Assert(p.host, gc.Equals, host)
+
+	host.State = "under-assessment"
+	c.Assert(env.lookupDedicatedHost(p), gc.ErrorMatches, `dedicated host "h-0123456789abcdef0" is "under-assessment"`)
+
+	host.State = "available"
+	host.InstanceType = ""
+	c.Assert(env.lookupDedicatedHost(p), gc.ErrorMatches, `dedicated host "h-0123456789abcdef0" does not report the instance types it supports`)
+}
+
+func (s *dedicatedHostSuite) TestInstanceTypesForDedicatedHost(c *gc.C) {
+	instanceTypes := []instances.InstanceType{
+		{Name: "m5.large"}, {Name: "m5.xlarge"}, {Name: "m5d.large"}, {Name: "t2.micro"},
+	}
+	c.Assert(instanceTypesForDedicatedHost(instanceTypes, &dedicatedHost{InstanceFamily: "m5"}), jc.DeepEquals, []instances.InstanceType{
+		{Name: "m5.large"}, {Name: "m5.xlarge"},
+	})
+	c.Assert(instanceTypesForDedicatedHost(instanceTypes, &dedicatedHost{InstanceType: "m5d.large"}), jc.DeepEquals, []instances.InstanceType{
+		{Name: "m5d.large"},
+	})
+}
+
+func (s *dedicatedHostSuite) TestAddParams(c *gc.C) {
+	params := make(url.Values)
+	(&hostPlacement{hostId: "h-0123456789abcdef0", affinity: "default"}).addParams(params)
+	c.Assert(params, jc.DeepEquals, url.Values{
+		"Placement.Tenancy":  {"host"},
+		"Placement.HostId":   {"h-0123456789abcdef0"},
+		"Placement.Affinity": {"default"},
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/status"
+)
+
+// tagDRSourceImage is the tag set on an image copied to a model's
+// dr-secondary-region, recording the region and ID of the image it was
+// copied from.
+const tagDRSourceImage = "juju-dr-source-image"
+
+// drImageAPI is the subset of the EC2 API used to copy images to another
+// region, which the EC2 client library has no support for.
+type drImageAPI interface {
+	// CopyImage starts copying the given image, in the model's
+	// region, to the given region, and returns the ID of the copy.
+	CopyImage(region, imageId, name, description string) (string, error)
+
+	// TagImage sets the given tags on the image with the given ID in
+	// the given region.
+	TagImage(region, imageId string, tags map[string]string) error
+}
+
+// newDRImageAPI returns a drImageAPI for the given cloud, whose request
+// signer is wrapped with wrapSigner. It is a variable so it can be
+// replaced in tests.
+var newDRImageAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) drImageAPI {
+	return &drImageClient{
+		region: cloud.Region,
+		newRegionClient: func(region string) *privateDNSClient {
+			regionCloud := cloud
+			regionCloud.Region = region
+			regionCloud.Endpoint = ""
+			if awsRegion, ok := aws.Regions[region]; ok {
+				regionCloud.Endpoint = awsRegion.EC2Endpoint
+			}
+			return newEC2QueryClient(regionCloud, wrapSigner)
+		},
+	}
+}
+
+// drImageClient is a minimal client for the EC2 query API, used to copy
+// images from the model's region to another. Images are copied by the
+// destination region, so each request is sent to that region's
+// endpoint.
+type drImageClient struct {
+	region          string
+	newRegionClient func(region string) *privateDNSClient
+}
+
+// CopyImage is part of the drImageAPI interface.
+func (c *drImageClient) CopyImage(region, imageId, name, description string) (string, error) {
+	params := url.Values{
+		"SourceRegion":  {c.region},
+		"SourceImageId": {imageId},
+		"Name":          {name},
+		// The same image is only ever copied once, however many
+		// times the request is retried.
+		"ClientToken": {fmt.Sprintf("juju-dr-%s-%s", c.region, imageId)},
+	}
+	if description != "" {
+		params.Set("Description", description)
+	}
+	var resp struct {
+		ImageId string `xml:"imageId"`
+	}
+	if err := c.newRegionClient(region).query("CopyImage", params, &resp); err != nil {
+		return "", errors.Annotatef(err, "copying image %q to region %q", imageId, region)
+	}
+	return resp.ImageId, nil
+}
+
+// TagImage is part of the drImageAPI interface.
+func (c *drImageClient) TagImage(region, imageId string, tags map[string]string) error {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := url.Values{"ResourceId.1": {imageId}}
+	for i, key := range keys {
+		params.Set(fmt.Sprintf("Tag.%d.Key", i+1), key)
+		params.Set(fmt.Sprintf("Tag.%d.Value", i+1), tags[key])
+	}
+	var resp struct{}
+	if err := c.newRegionClient(region).query("CreateTags", params, &resp); err != nil {
+		return errors.Annotatef(err, "tagging image %q in region %q", imageId, region)
+	}
+	return nil
+}
+
+// stageDRImage copies the given image, from which a controller has been
+// bootstrapped, to the model's dr-secondary-region, if any, along with
+// its name, description and tags, so that a replacement controller can
+// be bootstrapped there from the same image without first copying it.
+// A nil image is one found in simplestreams, which is already published
+// to every region, so there is nothing to copy. Copying is best-effort:
+// if it fails, a warning is logged and the bootstrap carries on.
+func (e *environ) stageDRImage(image *ec2.Image, callback environs.StatusCallbackFunc) {
+	region := e.ecfg().drSecondaryRegion()
+	if region == "" {
+		return
+	}
+	if image == nil {
+		logger.Debugf("not copying bootstrap image to dr-secondary-region %q: simplestreams images are published to every region", region)
+		return
+	}
+	callback(status.Allocating, fmt.Sprintf("Copying image %q to region %q for disaster recovery", image.Id, region), nil)
+	name := image.Name
+	if name == "" {
+		name = image.Id
+	}
+	copyId, err := e.drImages.CopyImage(region, image.Id, name, image.Description)
+	if err != nil {
+		logger.Warningf("cannot copy bootstrap image to dr-secondary-region: %v", err)
+		return
+	}
+	tags := make(map[string]string)
+	for _, tag := range image.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	tags[tagDRSourceImage] = fmt.Sprintf("%s/%s", e.cloud.Region, image.Id)
+	if err := e.drImages.TagImage(region, copyId, tags); err != nil {
+		logger.Warningf("cannot tag bootstrap image copied to dr-secondary-region: %v", err)
+		return
+	}
+	logger.Infof("copying bootstrap image %q to region %q as %q", image.Id, region, copyId)
+}
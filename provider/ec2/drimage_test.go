@@ -0,0 +1,180 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/status"
+	"github.com/juju/juju/testing"
+)
+
+type drImageSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	responses []string
+	regions   []string
+	client    *drImageClient
+}
+
+var _ = gc.Suite(&drImageSuite{})
+
+func (s *drImageSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.responses = nil
+	s.regions = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.URL.Query())
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &drImageClient{
+		region: "us-east-1",
+		newRegionClient: func(region string) *privateDNSClient {
+			s.regions = append(s.regions, region)
+			return &privateDNSClient{&awsAPIClient{
+				auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+				endpoint: s.server.URL + "/",
+				sign:     aws.SignV4Factory(region, "ec2"),
+				version:  privateDNSAPIVersion,
+			}}
+		},
+	}
+}
+
+func (s *drImageSuite) TestCopyImage(c *gc.C) {
+	s.responses = []string{`
+<CopyImageResponse>
+  <imageId>ami-0fedcba9876543210</imageId>
+</CopyImageResponse>`}
+	imageId, err := s.client.CopyImage("us-west-2", "ami-0123456789abcdef0", "controller-image", "Controller image")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(imageId, gc.Equals, "ami-0fedcba9876543210")
+	c.Assert(s.regions, jc.DeepEquals, []string{"us-west-2"})
+	c.Assert(s.requests, gc.HasLen, 1)
+	query := s.requests[0]
+	c.Assert(query.Get("Action"), gc.Equals, "CopyImage")
+	c.Assert(query.Get("SourceRegion"), gc.Equals, "us-east-1")
+	c.Assert(query.Get("SourceImageId"), gc.Equals, "ami-0123456789abcdef0")
+	c.Assert(query.Get("Name"), gc.Equals, "controller-image")
+	c.Assert(query.Get("Description"), gc.Equals, "Controller image")
+	c.Assert(query.Get("ClientToken"), gc.Equals, "juju-dr-us-east-1-ami-0123456789abcdef0")
+}
+
+func (s *drImageSuite) TestTagImage(c *gc.C) {
+	err := s.client.TagImage("us-west-2", "ami-0fedcba9876543210", map[string]string{
+		"team":           "ops",
+		tagDRSourceImage: "us-east-1/ami-0123456789abcdef0",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.regions, jc.DeepEquals, []string{"us-west-2"})
+	c.Assert(s.requests, gc.HasLen, 1)
+	query := s.requests[0]
+	c.Assert(query.Get("Action"), gc.Equals, "CreateTags")
+	c.Assert(query.Get("ResourceId.1"), gc.Equals, "ami-0fedcba9876543210")
+	c.Assert(query.Get("Tag.1.Key"), gc.Equals, tagDRSourceImage)
+	c.Assert(query.Get("Tag.1.Value"), gc.Equals, "us-east-1/ami-0123456789abcdef0")
+	c.Assert(query.Get("Tag.2.Key"), gc.Equals, "team")
+	c.Assert(query.Get("Tag.2.Value"), gc.Equals, "ops")
+}
+
+// fakeDRImages is a drImageAPI that records the images copied and
+// tagged.
+type fakeDRImages struct {
+	copied  []string
+	tagged  map[string]map[string]string
+	copyErr error
+}
+
+func (f *fakeDRImages) CopyImage(region, imageId, name, description string) (string, error) {
+	if f.copyErr != nil {
+		return "", f.copyErr
+	}
+	f.copied = append(f.copied, fmt.Sprintf("%s %s %s %q", region, imageId, name, description))
+	return "ami-copy", nil
+}
+
+func (f *fakeDRImages) TagImage(region, imageId string, tags map[string]string) error {
+	if f.tagged == nil {
+		f.tagged = make(map[string]map[string]string)
+	}
+	f.tagged[region+"/"+imageId] = tags
+	return nil
+}
+
+func (s *drImageSuite) environ(c *gc.C, drImages drImageAPI, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{
+		ecfgUnlocked: ecfg,
+		cloud:        environs.CloudSpec{Region: "us-east-1"},
+		drImages:     drImages,
+	}
+}
+
+func noStatus(status.Status, string, map[string]interface{}) error {
+	return nil
+}
+
+var drImage = &ec2.Image{
+	Id:          "ami-0123456789abcdef0",
+	Name:        "controller-image",
+	Description: "Controller image",
+	Tags:        []ec2.Tag{{Key: "team", Value: "ops"}},
+}
+
+func (s *drImageSuite) TestStageDRImage(c *gc.C) {
+	fake := &fakeDRImages{}
+	env := s.environ(c, fake, testing.Attrs{"dr-secondary-region": "us-west-2"})
+	env.stageDRImage(drImage, noStatus)
+	c.Assert(fake.copied, jc.DeepEquals, []string{`us-west-2 ami-0123456789abcdef0 controller-image "Controller image"`})
+	c.Assert(fake.tagged, jc.DeepEquals, map[string]map[string]string{
+		"us-west-2/ami-copy": {
+			"team":           "ops",
+			tagDRSourceImage: "us-east-1/ami-0123456789abcdef0",
+		},
+	})
+}
+
+func (s *drImageSuite) TestStageDRImageNotConfigured(c *gc.C) {
+	fake := &fakeDRImages{}
+	env := s.environ(c, fake, nil)
+	env.stageDRImage(drImage, noStatus)
+	c.Assert(fake.copied, gc.HasLen, 0)
+}
+
+func (s *drImageSuite) TestStageDRImageSimplestreams(c *gc.C) {
+	fake := &fakeDRImages{}
+	env := s.environ(c, fake, testing.Attrs{"dr-secondary-region": "us-west-2"})
+	env.stageDRImage(nil, noStatus)
+	c.Assert(fake.copied, gc.HasLen, 0)
+}
+
+func (s *drImageSuite) TestStageDRImageCopyError(c *gc.C) {
+	fake := &fakeDRImages{copyErr: errors.New("boom")}
+	env := s.environ(c, fake, testing.Attrs{"dr-secondary-region": "us-west-2"})
+	// Copying is best-effort, so the failure is only logged.
+	env.stageDRImage(drImage, noStatus)
+	c.Assert(fake.tagged, gc.HasLen, 0)
+	c.Assert(c.GetTestLog(), jc.Contains, "cannot copy bootstrap image to dr-secondary-region: boom")
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// dualStackAPI is the subset of the EC2 API used to find the IPv6 CIDR
+// blocks of subnets and the IPv6 addresses of instances, which the EC2
+// client library has no support for.
+type dualStackAPI interface {
+	// SubnetIPv6CIDR returns the IPv6 CIDR block associated with the
+	// given subnet, or "" if it has none.
+	SubnetIPv6CIDR(subnetID string) (string, error)
+
+	// InstanceIPv6Interfaces returns the network interfaces attached to
+	// the given instance, with their IPv6 addresses, in order of device
+	// index.
+	InstanceIPv6Interfaces(instId string) ([]ipv6Interface, error)
+}
+
+// ipv6Interface holds the IPv6 addresses of a network interface.
+type ipv6Interface struct {
+	Id            string   `xml:"networkInterfaceId"`
+	DeviceIndex   int      `xml:"attachment>deviceIndex"`
+	IPv6Addresses []string `xml:"ipv6AddressesSet>item>ipv6Address"`
+}
+
+// ipv6AddressParams adds the RunInstances parameter that assigns the
+// instance an IPv6 address from its subnet's IPv6 CIDR block, as well as
+// an IPv4 address. The EC2 client library has no support for IPv6.
+func ipv6AddressParams(params url.Values) {
+	params.Set("Ipv6AddressCount", "1")
+}
+
+// newDualStackAPI returns a dualStackAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newDualStackAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) dualStackAPI {
+	return &dualStackClient{newEC2QueryClient(cloud, wrapSigner)}
+}
+
+// dualStackClient is a minimal client for the EC2 query API, used to
+// describe the IPv6 CIDR blocks of subnets and the IPv6 addresses of
+// network interfaces.
+type dualStackClient struct {
+	*privateDNSClient
+}
+
+// SubnetIPv6CIDR is part of the dualStackAPI interface.
+func (c *dualStackClient) SubnetIPv6CIDR(subnetID string) (string, error) {
+	params := url.Values{"SubnetId.1": {subnetID}}
+	var resp struct {
+		Subnets []struct {
+			CIDRs  []string `xml:"ipv6CidrBlockAssociationSet>item>ipv6CidrBlock"`
+			States []string `xml:"ipv6CidrBlockAssociationSet>item>ipv6CidrBlockState>state"`
+		} `xml:"subnetSet>item"`
+	}
+	if err := c.query("DescribeSubnets", params, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && err.Code == "InvalidSubnetID.NotFound" {
+			return "", errors.NotFoundf("subnet %q", subnetID)
+		}
+		return "", errors.Annotatef(err, "describing subnet %q", subnetID)
+	}
+	if len(resp.Subnets) == 0 {
+		return "", errors.NotFoundf("subnet %q", subnetID)
+	}
+	subnet := resp.Subnets[0]
+	for i, cidr := range subnet.CIDRs {
+		if i < len(subnet.States) && subnet.States[i] == "associated" {
+			return cidr, nil
+		}
+	}
+	return "", nil
+}
+
+// InstanceIPv6Interfaces is part of the dualStackAPI interface.
+func (c *dualStackClient) InstanceIPv6Interfaces(instId string) ([]ipv6Interface, error) {
+	params := url.Values{
+		"Filter.1.Name":    {"attachment.instance-id"},
+		"Filter.1.Value.1": {instId},
+	}
+	var resp struct {
+		Interfaces []ipv6Interface `xml:"networkInterfaceSet>item"`
+	}
+	if err := c.query("DescribeNetworkInterfaces", params, &resp); err != nil {
+		return nil, errors.Annotatef(err, "describing network interfaces of instance %q", instId)
+	}
+	sort.Slice(resp.Interfaces, func(i, j int) bool {
+		return resp.Interfaces[i].DeviceIndex < resp.Interfaces[j].DeviceIndex
+	})
+	return resp.Interfaces, nil
+}
+
+// instanceIPv6Addresses returns the IPv6 addresses of the given
+// instance's network interfaces, keyed by network interface ID.
+func (e *environ) instanceIPv6Addresses(instId string) (map[string][]string, error) {
+	ifaces, err := e.dualStack.InstanceIPv6Interfaces(instId)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	addrs := make(map[string][]string)
+	for _, iface := range ifaces {
+		if len(iface.IPv6Addresses) > 0 {
+			addrs[iface.Id] = iface.IPv6Addresses
+		}
+	}
+	return addrs, nil
+}
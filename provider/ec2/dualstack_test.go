@@ -0,0 +1,129 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type dualStackSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	responses []string
+	client    *dualStackClient
+}
+
+var _ = gc.Suite(&dualStackSuite{})
+
+func (s *dualStackSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.URL.Query())
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &dualStackClient{&privateDNSClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  privateDNSAPIVersion,
+	}}}
+}
+
+func (s *dualStackSuite) TestIPv6AddressParams(c *gc.C) {
+	params := make(url.Values)
+	ipv6AddressParams(params)
+	c.Assert(params, jc.DeepEquals, url.Values{
+		"Ipv6AddressCount": {"1"},
+	})
+}
+
+func (s *dualStackSuite) TestSubnetIPv6CIDR(c *gc.C) {
+	s.responses = []string{`
+<DescribeSubnetsResponse>
+  <subnetSet>
+    <item>
+      <subnetId>subnet-0a1b2c3d</subnetId>
+      <ipv6CidrBlockAssociationSet>
+        <item>
+          <ipv6CidrBlock>2600:1f18:1234:5500::/64</ipv6CidrBlock>
+          <ipv6CidrBlockState><state>disassociated</state></ipv6CidrBlockState>
+        </item>
+        <item>
+          <ipv6CidrBlock>2600:1f18:1234:5600::/64</ipv6CidrBlock>
+          <ipv6CidrBlockState><state>associated</state></ipv6CidrBlockState>
+        </item>
+      </ipv6CidrBlockAssociationSet>
+    </item>
+  </subnetSet>
+</DescribeSubnetsResponse>`}
+	cidr, err := s.client.SubnetIPv6CIDR("subnet-0a1b2c3d")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cidr, gc.Equals, "2600:1f18:1234:5600::/64")
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeSubnets")
+	c.Assert(s.requests[0].Get("Version"), gc.Equals, privateDNSAPIVersion)
+	c.Assert(s.requests[0].Get("SubnetId.1"), gc.Equals, "subnet-0a1b2c3d")
+}
+
+func (s *dualStackSuite) TestSubnetIPv6CIDRIPv4Only(c *gc.C) {
+	s.responses = []string{`
+<DescribeSubnetsResponse>
+  <subnetSet>
+    <item><subnetId>subnet-0a1b2c3d</subnetId></item>
+  </subnetSet>
+</DescribeSubnetsResponse>`}
+	cidr, err := s.client.SubnetIPv6CIDR("subnet-0a1b2c3d")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cidr, gc.Equals, "")
+}
+
+func (s *dualStackSuite) TestInstanceIPv6Interfaces(c *gc.C) {
+	s.responses = []string{`
+<DescribeNetworkInterfacesResponse>
+  <networkInterfaceSet>
+    <item>
+      <networkInterfaceId>eni-1</networkInterfaceId>
+      <attachment><deviceIndex>1</deviceIndex></attachment>
+    </item>
+    <item>
+      <networkInterfaceId>eni-0</networkInterfaceId>
+      <attachment><deviceIndex>0</deviceIndex></attachment>
+      <ipv6AddressesSet>
+        <item><ipv6Address>2600:1f18:1234:5600::5</ipv6Address></item>
+      </ipv6AddressesSet>
+    </item>
+  </networkInterfaceSet>
+</DescribeNetworkInterfacesResponse>`}
+	ifaces, err := s.client.InstanceIPv6Interfaces("i-0a1b2c3d")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ifaces, jc.DeepEquals, []ipv6Interface{{
+		Id:            "eni-0",
+		DeviceIndex:   0,
+		IPv6Addresses: []string{"2600:1f18:1234:5600::5"},
+	}, {
+		Id:          "eni-1",
+		DeviceIndex: 1,
+	}})
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeNetworkInterfaces")
+	c.Assert(s.requests[0].Get("Filter.1.Name"), gc.Equals, "attachment.instance-id")
+	c.Assert(s.requests[0].Get("Filter.1.Value.1"), gc.Equals, "i-0a1b2c3d")
+}
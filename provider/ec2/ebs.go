@@ -5,6 +5,8 @@ package ec2
 
 import (
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +32,8 @@ const (
 	// The volume type (default standard):
 	//   "gp2" for General Purpose (SSD) volumes
 	//   "io1" for Provisioned IOPS (SSD) volumes,
+	//   "st1" for Throughput Optimized HDD volumes,
+	//   "sc1" for Cold HDD volumes,
 	//   "standard" for Magnetic volumes.
 	EBS_VolumeType = "volume-type"
 
@@ -46,7 +50,10 @@ const (
 	volumeTypeProvisionedIops = "provisioned-iops" // io1
 	volumeTypeStandard        = "standard"
 	volumeTypeGP2             = "gp2"
+	volumeTypeGP3             = "gp3"
 	volumeTypeIO1             = "io1"
+	volumeTypeST1             = "st1"
+	volumeTypeSC1             = "sc1"
 
 	rootDiskDeviceName = "/dev/sda1"
 
@@ -54,6 +61,15 @@ const (
 	// root disk of controller machines, if no root-disk constraint
 	// is specified.
 	defaultControllerDiskSizeMiB = 32 * 1024
+
+	// defaultInstanceStoreVolumes is the number of instance store
+	// volumes mapped at launch, if the instance type has them and
+	// instance-store-volumes is not specified.
+	defaultInstanceStoreVolumes = 4
+
+	// maxInstanceStoreVolumes is the largest number of instance store
+	// volumes any instance type has, mapped to /dev/sdb to /dev/sdy.
+	maxInstanceStoreVolumes = 24
 )
 
 // AWS error codes
@@ -61,6 +77,7 @@ const (
 	deviceInUse        = "InvalidDevice.InUse"
 	attachmentNotFound = "InvalidAttachment.NotFound"
 	volumeNotFound     = "InvalidVolume.NotFound"
+	snapshotNotFound   = "InvalidSnapshot.NotFound"
 	incorrectState     = "IncorrectState"
 )
 
@@ -99,6 +116,14 @@ const (
 	// volumes in GiB.
 	maxProvisionedIopsVolumeSizeGiB = 16 * 1024
 
+	// minHDDVolumeSizeGiB is the minimum size for Throughput Optimized
+	// and Cold HDD volumes in GiB.
+	minHDDVolumeSizeGiB = 500
+
+	// maxHDDVolumeSizeGiB is the maximum size for Throughput Optimized
+	// and Cold HDD volumes in GiB.
+	maxHDDVolumeSizeGiB = 16 * 1024
+
 	// maxProvisionedIopsSizeRatio is the maximum allowed ratio of IOPS to
 	// size (in GiB), for provisioend IOPS volumes.
 	maxProvisionedIopsSizeRatio = 30
@@ -149,6 +174,8 @@ var ebsConfigFields = schema.Fields{
 		schema.Const(volumeTypeStandard),
 		schema.Const(volumeTypeGP2),
 		schema.Const(volumeTypeIO1),
+		schema.Const(volumeTypeST1),
+		schema.Const(volumeTypeSC1),
 	),
 	EBS_IOPS:      schema.ForceInt(),
 	EBS_Encrypted: schema.Bool(),
@@ -351,7 +378,16 @@ func (v *ebsVolumeSource) createVolume(p storage.VolumeParams, instances instanc
 		return nil, nil, errors.Trace(err)
 	}
 	vol, _ := parseVolumeOptions(p.Size, p.Attributes)
+	// EBS volumes can only be attached to instances in the same
+	// availability zone, so always create the volume alongside
+	// the instance it will be attached to.
 	vol.AvailZone = inst.AvailZone
+	if p.SnapshotId != "" {
+		if err := validateVolumeSnapshot(v.env.ec2, p.SnapshotId, vol.VolumeSize); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		vol.SnapshotId = p.SnapshotId
+	}
 	resp, err := v.env.ec2.CreateVolume(vol)
 	if err != nil {
 		return nil, nil, errors.Trace(err)
@@ -364,6 +400,10 @@ func (v *ebsVolumeSource) createVolume(p storage.VolumeParams, instances instanc
 		resourceTags[k] = v
 	}
 	resourceTags[tagName] = resourceName(p.Tag, v.envName)
+	resourceTags[tags.JujuVolume] = p.Tag.Id()
+	if v.env.ecfg().propagateInstanceTags() {
+		addInstanceTags(resourceTags, inst.Tags)
+	}
 	if err := tagResources(v.env.ec2, resourceTags, volumeId); err != nil {
 		return nil, nil, errors.Annotate(err, "tagging volume")
 	}
@@ -379,11 +419,107 @@ func (v *ebsVolumeSource) createVolume(p storage.VolumeParams, instances instanc
 	return &volume, nil, nil
 }
 
+// instanceVolumeParams returns the parameters of the EBS volumes, among
+// those given, to be created along with a new instance.
+func instanceVolumeParams(params []storage.VolumeParams) []storage.VolumeParams {
+	var result []storage.VolumeParams
+	for _, p := range params {
+		if p.Provider == EBS_ProviderType && p.Attachment != nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// createInstanceVolumes creates the given EBS volumes in the availability
+// zone of the new, running instance and attaches them to it, so they are
+// ready before the units on the machine are installed. If any volume
+// cannot be created or attached, those already created are deleted.
+func (e *environ) createInstanceVolumes(
+	inst *ec2.Instance, params []storage.VolumeParams,
+) (_ []storage.Volume, _ []storage.VolumeAttachment, err error) {
+	source := &ebsVolumeSource{
+		env:       e,
+		envName:   e.Config().Name(),
+		modelUUID: e.uuid(),
+	}
+	instances := instanceCache{inst.InstanceId: *inst}
+	nextDeviceName := blockDeviceNamer(false)
+
+	var volumes []storage.Volume
+	var attachments []storage.VolumeAttachment
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, volume := range volumes {
+			if _, err := e.ec2.DeleteVolume(volume.VolumeId); err != nil {
+				logger.Errorf("error cleaning up volume %v: %v", volume.VolumeId, err)
+			}
+		}
+	}()
+	for _, p := range params {
+		attachment := *p.Attachment
+		attachment.InstanceId = instance.Id(inst.InstanceId)
+		p.Attachment = &attachment
+		volume, _, err := source.createVolume(p, instances)
+		if err != nil {
+			return nil, nil, errors.Annotatef(err, "creating volume %s", p.Tag.Id())
+		}
+		volumes = append(volumes, *volume)
+		_, deviceName, err := source.attachOneVolume(nextDeviceName, volume.VolumeId, *inst)
+		if err != nil {
+			return nil, nil, errors.Annotatef(err, "attaching volume %s", p.Tag.Id())
+		}
+		attachments = append(attachments, storage.VolumeAttachment{
+			p.Tag,
+			attachment.Machine,
+			storage.VolumeAttachmentInfo{
+				DeviceName: deviceName,
+				ReadOnly:   attachment.ReadOnly,
+			},
+		})
+	}
+	return volumes, attachments, nil
+}
+
+// validateVolumeSnapshot checks that the snapshot with the given ID exists,
+// is ready to be used, and is no larger than the requested volume size.
+func validateVolumeSnapshot(client *ec2.EC2, snapshotId string, sizeInGib int) error {
+	resp, err := client.Snapshots([]string{snapshotId}, nil)
+	if err != nil {
+		if ec2ErrCode(err) == snapshotNotFound {
+			return errors.NotFoundf("snapshot %q", snapshotId)
+		}
+		return errors.Annotatef(err, "querying snapshot %q", snapshotId)
+	}
+	if len(resp.Snapshots) == 0 {
+		return errors.NotFoundf("snapshot %q", snapshotId)
+	}
+	snapshot := resp.Snapshots[0]
+	if snapshot.Status != "" && snapshot.Status != "completed" {
+		return errors.Errorf("snapshot %q is %q", snapshotId, snapshot.Status)
+	}
+	snapshotSize, err := strconv.Atoi(snapshot.VolumeSize)
+	if err != nil {
+		return errors.Annotatef(err, "parsing size of snapshot %q", snapshotId)
+	}
+	if sizeInGib < snapshotSize {
+		return errors.Errorf(
+			"volume size is %d GiB, must be at least the snapshot size of %d GiB",
+			sizeInGib, snapshotSize,
+		)
+	}
+	return nil
+}
+
 // ListVolumes is specified on the storage.VolumeSource interface.
 func (v *ebsVolumeSource) ListVolumes() ([]string, error) {
+	// The volumes listed are destroyed along with the model, so
+	// those tagged for preservation are left out.
 	filter := ec2.NewFilter()
-	filter.Add("tag:"+tags.JujuModel, v.modelUUID)
-	return listVolumes(v.env.ec2, filter, false)
+	addTagFilters(filter, map[string]string{tags.JujuModel: v.modelUUID})
+	return listDestroyableVolumes(v.env.ec2, filter)
 }
 
 func listVolumes(client *ec2.EC2, filter *ec2.Filter, includeRootDisks bool) ([]string, error) {
@@ -393,14 +529,7 @@ func listVolumes(client *ec2.EC2, filter *ec2.Filter, includeRootDisks bool) ([]
 	}
 	volumeIds := make([]string, 0, len(resp.Volumes))
 	for _, vol := range resp.Volumes {
-		var isRootDisk bool
-		for _, att := range vol.Attachments {
-			if att.Device == rootDiskDeviceName {
-				isRootDisk = true
-				break
-			}
-		}
-		if isRootDisk && !includeRootDisks {
+		if isRootDisk(vol) && !includeRootDisks {
 			// We don't want to list root disks in the output.
 			// These are managed by the instance provisioning
 			// code; they will be created and destroyed with
@@ -412,6 +541,53 @@ func listVolumes(client *ec2.EC2, filter *ec2.Filter, includeRootDisks bool) ([]
 	return volumeIds, nil
 }
 
+// listDestroyableVolumes returns the IDs of the volumes matching the
+// filter that are destroyed along with their model. Root disks are
+// destroyed with their instances, so are not listed. Volumes tagged
+// with juju-preserve=true are logged and left out, so that they
+// survive the model.
+func listDestroyableVolumes(client *ec2.EC2, filter *ec2.Filter) ([]string, error) {
+	resp, err := client.Volumes(nil, filter)
+	if err != nil {
+		return nil, err
+	}
+	volumeIds := make([]string, 0, len(resp.Volumes))
+	for _, vol := range resp.Volumes {
+		if isRootDisk(vol) {
+			continue
+		}
+		if isPreservedVolume(vol) {
+			logger.Infof("preserving volume %q tagged %s=true", vol.Id, tags.JujuPreserve)
+			continue
+		}
+		volumeIds = append(volumeIds, vol.Id)
+	}
+	return volumeIds, nil
+}
+
+// isRootDisk reports whether the volume is attached to an instance as
+// its root disk.
+func isRootDisk(vol ec2.Volume) bool {
+	for _, att := range vol.Attachments {
+		if att.Device == rootDiskDeviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreservedVolume reports whether the volume is tagged to be kept
+// when its model is destroyed.
+func isPreservedVolume(vol ec2.Volume) bool {
+	for _, tag := range vol.Tags {
+		if tag.Key == tags.JujuPreserve {
+			preserve, err := strconv.ParseBool(tag.Value)
+			return err == nil && preserve
+		}
+	}
+	return false
+}
+
 // DescribeVolumes is specified on the storage.VolumeSource interface.
 func (v *ebsVolumeSource) DescribeVolumes(volIds []string) ([]storage.DescribeVolumesResult, error) {
 	// TODO(axw) invalid volIds here should not cause the whole
@@ -634,6 +810,9 @@ func (v *ebsVolumeSource) ValidateVolumeParams(params storage.VolumeParams) erro
 	case volumeTypeIO1:
 		minVolumeSize = minProvisionedIopsVolumeSizeGiB
 		maxVolumeSize = maxProvisionedIopsVolumeSizeGiB
+	case volumeTypeST1, volumeTypeSC1:
+		minVolumeSize = minHDDVolumeSizeGiB
+		maxVolumeSize = maxHDDVolumeSizeGiB
 	}
 	if vol.VolumeSize < minVolumeSize {
 		return errors.Errorf(
@@ -681,7 +860,16 @@ func (v *ebsVolumeSource) AttachVolumes(attachParams []storage.VolumeAttachmentP
 		// must error if used with an "hvm" instance type.
 		const numbers = false
 		nextDeviceName := blockDeviceNamer(numbers)
-		_, deviceName, err := v.attachOneVolume(nextDeviceName, params.VolumeId, instId)
+		if err := instances.update(v.env.ec2, instId); err != nil {
+			results[i].Error = errors.Trace(err)
+			continue
+		}
+		inst, err := instances.get(instId)
+		if err != nil {
+			results[i].Error = errors.Trace(err)
+			continue
+		}
+		_, deviceName, err := v.attachOneVolume(nextDeviceName, params.VolumeId, inst)
 		if err != nil {
 			results[i].Error = err
 			continue
@@ -699,14 +887,26 @@ func (v *ebsVolumeSource) AttachVolumes(attachParams []storage.VolumeAttachmentP
 
 func (v *ebsVolumeSource) attachOneVolume(
 	nextDeviceName func() (string, string, error),
-	volumeId, instId string,
+	volumeId string,
+	inst ec2.Instance,
 ) (string, string, error) {
+	instId := inst.InstanceId
+
 	// Wait for the volume to move out of "creating".
 	volume, err := v.waitVolumeCreated(volumeId)
 	if err != nil {
 		return "", "", errors.Trace(err)
 	}
 
+	// EBS volumes cannot be attached across availability zones;
+	// report that clearly rather than relaying the AWS failure.
+	if volume.AvailZone != inst.AvailZone {
+		return "", "", errors.Errorf(
+			"cannot attach volume %v in availability zone %q to instance %v in availability zone %q",
+			volumeId, volume.AvailZone, instId, inst.AvailZone,
+		)
+	}
+
 	// Possible statuses:
 	//    creating | available | in-use | deleting | deleted | error
 	switch volume.Status {
@@ -821,6 +1021,24 @@ func describeVolume(client *ec2.EC2, volumeId string) (*ec2.Volume, error) {
 	return &resp.Volumes[0], nil
 }
 
+// addInstanceTags adds the user tags of an instance to the given
+// resource tags, for propagate-instance-tags and for the controller's
+// default resource tags. Juju's tags, the Name tag and the tags
+// reserved by AWS are not added, and neither are tags that would
+// override those already given.
+func addInstanceTags(resourceTags map[string]string, instanceTags []ec2.Tag) {
+	for _, tag := range instanceTags {
+		if tag.Key == tagName ||
+			strings.HasPrefix(tag.Key, tags.JujuTagPrefix) ||
+			strings.HasPrefix(tag.Key, "aws:") {
+			continue
+		}
+		if _, ok := resourceTags[tag.Key]; !ok {
+			resourceTags[tag.Key] = tag.Value
+		}
+	}
+}
+
 type instanceCache map[string]ec2.Instance
 
 func (c instanceCache) update(ec2client *ec2.EC2, ids ...string) error {
@@ -954,12 +1172,16 @@ func minRootDiskSizeMiB(series string) uint64 {
 
 // getBlockDeviceMappings translates constraints into BlockDeviceMappings.
 //
-// The first entry is always the root disk mapping, followed by instance
-// stores (ephemeral disks).
+// The first entry is always the root disk mapping, followed by the
+// specified number of instance stores (ephemeral disks). If
+// rootVolumeType is empty, the root disk has the volume type of the
+// image's root device.
 func getBlockDeviceMappings(
 	cons constraints.Value,
 	series string,
 	controller bool,
+	rootVolumeType string,
+	instanceStores int,
 ) []ec2.BlockDeviceMapping {
 	minRootDiskSizeMiB := minRootDiskSizeMiB(series)
 	rootDiskSizeMiB := minRootDiskSizeMiB
@@ -980,25 +1202,18 @@ func getBlockDeviceMappings(
 	// The first block device is for the root disk.
 	blockDeviceMappings := []ec2.BlockDeviceMapping{{
 		DeviceName: rootDiskDeviceName,
+		VolumeType: rootVolumeType,
 		VolumeSize: int64(mibToGib(rootDiskSizeMiB)),
 	}}
 
-	// Not all machines have this many instance stores.
-	// Instances will be started with as many of the
-	// instance stores as they can support.
-	blockDeviceMappings = append(blockDeviceMappings, []ec2.BlockDeviceMapping{{
-		VirtualName: "ephemeral0",
-		DeviceName:  "/dev/sdb",
-	}, {
-		VirtualName: "ephemeral1",
-		DeviceName:  "/dev/sdc",
-	}, {
-		VirtualName: "ephemeral2",
-		DeviceName:  "/dev/sdd",
-	}, {
-		VirtualName: "ephemeral3",
-		DeviceName:  "/dev/sde",
-	}}...)
+	// Instance stores are mapped to consecutive devices
+	// following the root disk, starting at /dev/sdb.
+	for i := 0; i < instanceStores; i++ {
+		blockDeviceMappings = append(blockDeviceMappings, ec2.BlockDeviceMapping{
+			VirtualName: "ephemeral" + strconv.Itoa(i),
+			DeviceName:  "/dev/sd" + string(rune('b'+i)),
+		})
+	}
 
 	return blockDeviceMappings
 }
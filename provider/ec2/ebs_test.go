@@ -274,17 +274,210 @@ func (s *ebsSuite) TestVolumeTags(c *gc.C) {
 	c.Assert(ec2Vols.Volumes[0].Tags, jc.SameContents, []awsec2.Tag{
 		{"juju-model-uuid", "deadbeef-0bad-400d-8000-4b1d0d06f00d"},
 		{"Name", "juju-testenv-volume-0"},
+		{"juju-volume", "0"},
 	})
 	c.Assert(ec2Vols.Volumes[1].Tags, jc.SameContents, []awsec2.Tag{
 		{"juju-model-uuid", "something-else"},
 		{"Name", "juju-testenv-volume-1"},
+		{"juju-volume", "1"},
 	})
 	c.Assert(ec2Vols.Volumes[2].Tags, jc.SameContents, []awsec2.Tag{
 		{"Name", "juju-testenv-volume-2"},
+		{"juju-volume", "2"},
 		{"abc", "123"},
 	})
 }
 
+func (s *ebsSuite) TestVolumeTagsPropagatedFromInstance(c *gc.C) {
+	var err error
+	s.modelConfig, err = s.modelConfig.Apply(map[string]interface{}{
+		"propagate-instance-tags": true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	instanceId := s.srv.ec2srv.NewInstances(1, "m1.medium", imageId, ec2test.Running, nil)[0]
+	_, err = s.srv.client.CreateTags([]string{instanceId}, []awsec2.Tag{
+		{"Name", "juju-testenv-machine-0"},
+		{"juju-model-uuid", "something-else"},
+		{"juju-machine-id", "testenv-machine-0"},
+		{"cost-center", "1234"},
+		{"team", "storage"},
+		{"abc", "456"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	vs := s.volumeSource(c, nil)
+	results, err := vs.CreateVolumes([]storage.VolumeParams{{
+		Tag:      names.NewVolumeTag("0"),
+		Size:     10 * 1024,
+		Provider: ec2.EBS_ProviderType,
+		ResourceTags: map[string]string{
+			tags.JujuModel: s.modelConfig.UUID(),
+			"abc":          "123",
+		},
+		Attachment: &storage.VolumeAttachmentParams{
+			AttachmentParams: storage.AttachmentParams{
+				InstanceId: instance.Id(instanceId),
+			},
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+
+	ec2Vols, err := ec2.StorageEC2(vs).Volumes([]string{results[0].Volume.VolumeId}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ec2Vols.Volumes, gc.HasLen, 1)
+	c.Assert(ec2Vols.Volumes[0].Tags, jc.SameContents, []awsec2.Tag{
+		{"juju-model-uuid", "deadbeef-0bad-400d-8000-4b1d0d06f00d"},
+		{"Name", "juju-testenv-volume-0"},
+		{"juju-volume", "0"},
+		{"abc", "123"},
+		{"cost-center", "1234"},
+		{"team", "storage"},
+	})
+}
+
+func (s *ebsSuite) TestCreateVolumeFromSnapshot(c *gc.C) {
+	instanceId := s.srv.ec2srv.NewInstances(1, "m1.medium", imageId, ec2test.Running, nil)[0]
+	vs := s.volumeSource(c, nil)
+	ec2Client := ec2.StorageEC2(vs)
+
+	vol, err := ec2Client.CreateVolume(awsec2.CreateVolume{
+		AvailZone:  "us-east-1c",
+		VolumeSize: 10,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	snap, err := ec2Client.CreateSnapshot(vol.Id, "backup")
+	c.Assert(err, jc.ErrorIsNil)
+
+	params := []storage.VolumeParams{{
+		Tag:        names.NewVolumeTag("0"),
+		Size:       20 * 1024,
+		Provider:   ec2.EBS_ProviderType,
+		SnapshotId: snap.Snapshot.Id,
+		ResourceTags: map[string]string{
+			tags.JujuModel: s.modelConfig.UUID(),
+		},
+		Attachment: &storage.VolumeAttachmentParams{
+			AttachmentParams: storage.AttachmentParams{
+				InstanceId: instance.Id(instanceId),
+			},
+		},
+	}}
+	results, err := vs.CreateVolumes(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	volumeId := results[0].Volume.VolumeId
+
+	ec2Vols, err := ec2Client.Volumes([]string{volumeId}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ec2Vols.Volumes, gc.HasLen, 1)
+	c.Assert(ec2Vols.Volumes[0].SnapshotId, gc.Equals, snap.Snapshot.Id)
+	c.Assert(ec2Vols.Volumes[0].Tags, jc.SameContents, []awsec2.Tag{
+		{"juju-model-uuid", "deadbeef-0bad-400d-8000-4b1d0d06f00d"},
+		{"Name", "juju-testenv-volume-0"},
+		{"juju-volume", "0"},
+	})
+
+	volIds, err := vs.ListVolumes()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(volIds, jc.SameContents, []string{volumeId})
+}
+
+func (s *ebsSuite) TestCreateVolumeFromSnapshotErrors(c *gc.C) {
+	instanceId := s.srv.ec2srv.NewInstances(1, "m1.medium", imageId, ec2test.Running, nil)[0]
+	vs := s.volumeSource(c, nil)
+	ec2Client := ec2.StorageEC2(vs)
+
+	vol, err := ec2Client.CreateVolume(awsec2.CreateVolume{
+		AvailZone:  "us-east-1c",
+		VolumeSize: 10,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	snap, err := ec2Client.CreateSnapshot(vol.Id, "backup")
+	c.Assert(err, jc.ErrorIsNil)
+
+	for _, test := range []struct {
+		snapshotId string
+		size       uint64
+		err        string
+	}{{
+		snapshotId: "snap-missing",
+		size:       10 * 1024,
+		err:        `snapshot "snap-missing" not found`,
+	}, {
+		snapshotId: snap.Snapshot.Id,
+		size:       5 * 1024,
+		err:        "volume size is 5 GiB, must be at least the snapshot size of 10 GiB",
+	}} {
+		results, err := vs.CreateVolumes([]storage.VolumeParams{{
+			Tag:        names.NewVolumeTag("0"),
+			Size:       test.size,
+			Provider:   ec2.EBS_ProviderType,
+			SnapshotId: test.snapshotId,
+			Attachment: &storage.VolumeAttachmentParams{
+				AttachmentParams: storage.AttachmentParams{
+					InstanceId: instance.Id(instanceId),
+				},
+			},
+		}})
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(results, gc.HasLen, 1)
+		c.Check(results[0].Error, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *ebsSuite) TestCreateVolumesHDD(c *gc.C) {
+	instanceIdRunning := s.srv.ec2srv.NewInstances(1, "m1.medium", imageId, ec2test.Running, nil)[0]
+	vs := s.volumeSource(c, nil)
+	ec2Client := ec2.StorageEC2(vs)
+	volumeTypes := []string{"st1", "sc1"}
+	for i, volumeType := range volumeTypes {
+		params := []storage.VolumeParams{{
+			Tag:      names.NewVolumeTag(fmt.Sprint(i)),
+			Size:     500 * 1024,
+			Provider: ec2.EBS_ProviderType,
+			Attributes: map[string]interface{}{
+				"volume-type": volumeType,
+			},
+			ResourceTags: map[string]string{
+				tags.JujuModel: s.modelConfig.UUID(),
+			},
+			Attachment: &storage.VolumeAttachmentParams{
+				AttachmentParams: storage.AttachmentParams{
+					InstanceId: instance.Id(instanceIdRunning),
+				},
+			},
+		}}
+		c.Assert(vs.ValidateVolumeParams(params[0]), jc.ErrorIsNil)
+		results, err := vs.CreateVolumes(params)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(results, gc.HasLen, 1)
+		c.Assert(results[0].Error, jc.ErrorIsNil)
+		c.Assert(results[0].Volume.Size, gc.Equals, uint64(500*1024))
+	}
+	ec2Vols, err := ec2Client.Volumes(nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ec2Vols.Volumes, gc.HasLen, len(volumeTypes))
+	sort.Sort(volumeSorter{ec2Vols.Volumes, func(i, j awsec2.Volume) bool {
+		return i.Id < j.Id
+	}})
+	for i, volumeType := range volumeTypes {
+		c.Assert(ec2Vols.Volumes[i].VolumeType, gc.Equals, volumeType)
+		c.Assert(ec2Vols.Volumes[i].Tags, jc.SameContents, []awsec2.Tag{
+			{"juju-model-uuid", "deadbeef-0bad-400d-8000-4b1d0d06f00d"},
+			{"Name", fmt.Sprintf("juju-testenv-volume-%d", i)},
+			{"juju-volume", fmt.Sprint(i)},
+		})
+	}
+
+	// They are listed along with the model's other volumes.
+	volIds, err := vs.ListVolumes()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(volIds, jc.SameContents, []string{"vol-0", "vol-1"})
+}
+
 func (s *ebsSuite) TestVolumeTypeAliases(c *gc.C) {
 	instanceIdRunning := s.srv.ec2srv.NewInstances(1, "m1.medium", imageId, ec2test.Running, nil)[0]
 	vs := s.volumeSource(c, nil)
@@ -383,6 +576,7 @@ func (s *ebsSuite) TestReleaseVolumes(c *gc.C) {
 		{"juju-controller-uuid", ""},
 		{"juju-model-uuid", ""},
 		{"Name", "juju-testenv-volume-0"},
+		{"juju-volume", "0"},
 	})
 }
 
@@ -403,6 +597,7 @@ func (s *ebsSuite) TestReleaseVolumesStillAttached(c *gc.C) {
 	c.Assert(ec2Vols.Volumes[0].Tags, jc.SameContents, []awsec2.Tag{
 		{"juju-model-uuid", "deadbeef-0bad-400d-8000-4b1d0d06f00d"},
 		{"Name", "juju-testenv-volume-0"},
+		{"juju-volume", "0"},
 	})
 }
 
@@ -548,6 +743,40 @@ func (s *ebsSuite) TestCreateVolumesErrors(c *gc.C) {
 			Attachment: &attachmentParams,
 		},
 		err: "volume size is 1 GiB, must be at least 4 GiB",
+	}, {
+		params: storage.VolumeParams{
+			Tag:      volume0,
+			Size:     100 * 1024,
+			Provider: ec2.EBS_ProviderType,
+			Attributes: map[string]interface{}{
+				"volume-type": "st1",
+			},
+			Attachment: &attachmentParams,
+		},
+		err: "volume size is 100 GiB, must be at least 500 GiB",
+	}, {
+		params: storage.VolumeParams{
+			Tag:      volume0,
+			Size:     100000000,
+			Provider: ec2.EBS_ProviderType,
+			Attributes: map[string]interface{}{
+				"volume-type": "sc1",
+			},
+			Attachment: &attachmentParams,
+		},
+		err: "volume size 97657 GiB exceeds the maximum of 16384 GiB",
+	}, {
+		params: storage.VolumeParams{
+			Tag:      volume0,
+			Size:     500 * 1024,
+			Provider: ec2.EBS_ProviderType,
+			Attributes: map[string]interface{}{
+				"volume-type": "sc1",
+				"iops":        "30",
+			},
+			Attachment: &attachmentParams,
+		},
+		err: `IOPS specified, but volume type is "sc1"`,
 	}, {
 		params: storage.VolumeParams{
 			Tag:      volume0,
@@ -664,6 +893,42 @@ func (s *ebsSuite) TestAttachVolumes(c *gc.C) {
 	})
 }
 
+func (s *ebsSuite) TestAttachVolumesAvailabilityZoneMismatch(c *gc.C) {
+	vs := s.volumeSource(c, nil)
+	instanceId := s.srv.ec2srv.NewInstances(1, "m1.medium", imageId, ec2test.Running, nil)[0]
+	resp, err := s.srv.client.Instances([]string{instanceId}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Reservations, gc.HasLen, 1)
+	c.Assert(resp.Reservations[0].Instances, gc.HasLen, 1)
+	instanceZone := resp.Reservations[0].Instances[0].AvailZone
+
+	volumeZone := "us-east-1a"
+	if instanceZone == volumeZone {
+		volumeZone = "us-east-1c"
+	}
+	vol, err := s.srv.client.CreateVolume(awsec2.CreateVolume{
+		VolumeSize: 1,
+		VolumeType: "gp2",
+		AvailZone:  volumeZone,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	results, err := vs.AttachVolumes([]storage.VolumeAttachmentParams{{
+		Volume:   names.NewVolumeTag("0"),
+		VolumeId: vol.Id,
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("1"),
+			InstanceId: instance.Id(instanceId),
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+	c.Assert(results[0].Error, gc.ErrorMatches, fmt.Sprintf(
+		"cannot attach volume %s in availability zone %q to instance %s in availability zone %q",
+		vol.Id, volumeZone, instanceId, instanceZone,
+	))
+}
+
 func (s *ebsSuite) TestAttachVolumesCreating(c *gc.C) {
 	vs := s.volumeSource(c, nil)
 	params := s.setupAttachVolumesTest(c, vs, ec2test.Running)
@@ -859,7 +1124,7 @@ func (*blockDeviceMappingSuite) TestBlockDeviceNamer(c *gc.C) {
 }
 
 func (*blockDeviceMappingSuite) TestGetBlockDeviceMappings(c *gc.C) {
-	mapping := ec2.GetBlockDeviceMappings(constraints.Value{}, "trusty", false)
+	mapping := ec2.GetBlockDeviceMappings(constraints.Value{}, "trusty", false, "", 4)
 	c.Assert(mapping, gc.DeepEquals, []awsec2.BlockDeviceMapping{{
 		VolumeSize: 8,
 		DeviceName: "/dev/sda1",
@@ -879,7 +1144,7 @@ func (*blockDeviceMappingSuite) TestGetBlockDeviceMappings(c *gc.C) {
 }
 
 func (*blockDeviceMappingSuite) TestGetBlockDeviceMappingsController(c *gc.C) {
-	mapping := ec2.GetBlockDeviceMappings(constraints.Value{}, "trusty", true)
+	mapping := ec2.GetBlockDeviceMappings(constraints.Value{}, "trusty", true, "", 4)
 	c.Assert(mapping, gc.DeepEquals, []awsec2.BlockDeviceMapping{{
 		VolumeSize: 32,
 		DeviceName: "/dev/sda1",
@@ -898,6 +1163,21 @@ func (*blockDeviceMappingSuite) TestGetBlockDeviceMappingsController(c *gc.C) {
 	}})
 }
 
+func (*blockDeviceMappingSuite) TestGetBlockDeviceMappingsInstanceStores(c *gc.C) {
+	mapping := ec2.GetBlockDeviceMappings(constraints.Value{}, "trusty", false, "", 0)
+	c.Assert(mapping, gc.DeepEquals, []awsec2.BlockDeviceMapping{{
+		VolumeSize: 8,
+		DeviceName: "/dev/sda1",
+	}})
+
+	mapping = ec2.GetBlockDeviceMappings(constraints.Value{}, "trusty", false, "", 24)
+	c.Assert(mapping, gc.HasLen, 25)
+	c.Assert(mapping[24], gc.DeepEquals, awsec2.BlockDeviceMapping{
+		VirtualName: "ephemeral23",
+		DeviceName:  "/dev/sdy",
+	})
+}
+
 func makeDescribeVolumesResponseModifier(modify func(*awsec2.VolumesResp) error) func(*http.Response) error {
 	return func(resp *http.Response) error {
 		if resp.Request.URL.Query().Get("Action") != "DescribeVolumes" {
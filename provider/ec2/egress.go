@@ -0,0 +1,203 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/network"
+)
+
+// egressAPIVersion is the EC2 API version used to manage the egress
+// rules of security groups, which the EC2 client library lacks.
+const egressAPIVersion = "2016-11-15"
+
+// egressRulesAPI is the subset of the EC2 API used to manage the
+// egress rules of VPC security groups.
+type egressRulesAPI interface {
+	// AuthorizeEgress allows outbound traffic from the group to the
+	// given port ranges.
+	AuthorizeEgress(groupId string, ports []network.PortRange) error
+
+	// RevokeEgress stops allowing outbound traffic from the group to
+	// the given port ranges.
+	RevokeEgress(groupId string, ports []network.PortRange) error
+
+	// EgressPorts returns the port ranges to which outbound traffic
+	// from the group is allowed.
+	EgressPorts(groupId string) ([]network.PortRange, error)
+}
+
+// newEgressRulesAPI returns an egressRulesAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newEgressRulesAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) egressRulesAPI {
+	return &egressClient{newEC2Client(cloud, egressAPIVersion, wrapSigner)}
+}
+
+// egressClient is a minimal client for the EC2 query API, used to
+// manage the egress rules of security groups.
+type egressClient struct {
+	*awsAPIClient
+}
+
+// egressParams returns the query parameters describing egress rules to
+// anywhere for the given port ranges of the group.
+func egressParams(groupId string, ports []network.PortRange) url.Values {
+	params := url.Values{"GroupId": {groupId}}
+	for i, p := range ports {
+		prefix := fmt.Sprintf("IpPermissions.%d.", i+1)
+		params.Set(prefix+"IpProtocol", p.Protocol)
+		params.Set(prefix+"FromPort", fmt.Sprint(p.FromPort))
+		params.Set(prefix+"ToPort", fmt.Sprint(p.ToPort))
+		params.Set(prefix+"IpRanges.1.CidrIp", defaultRouteCIDRBlock)
+	}
+	return params
+}
+
+// AuthorizeEgress is part of the egressRulesAPI interface.
+func (c *egressClient) AuthorizeEgress(groupId string, ports []network.PortRange) error {
+	err := c.query("AuthorizeSecurityGroupEgress", egressParams(groupId, ports), nil)
+	if isEgressErrorCode(err, "InvalidPermission.Duplicate") && len(ports) > 1 {
+		// As with ingress rules, none of the rules are authorized
+		// if any is a duplicate, so authorize each individually.
+		for i := range ports {
+			if err := c.AuthorizeEgress(groupId, ports[i:i+1]); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	}
+	if err != nil && !isEgressErrorCode(err, "InvalidPermission.Duplicate") {
+		return errors.Annotatef(err, "authorizing egress from security group %q", groupId)
+	}
+	return nil
+}
+
+// RevokeEgress is part of the egressRulesAPI interface.
+func (c *egressClient) RevokeEgress(groupId string, ports []network.PortRange) error {
+	err := c.query("RevokeSecurityGroupEgress", egressParams(groupId, ports), nil)
+	if err != nil && !isEgressErrorCode(err, "InvalidPermission.NotFound") {
+		return errors.Annotatef(err, "revoking egress from security group %q", groupId)
+	}
+	return nil
+}
+
+// EgressPorts is part of the egressRulesAPI interface. Rules allowing
+// all traffic, such as the one VPC security groups are created with,
+// and rules to specific destinations are not reported.
+func (c *egressClient) EgressPorts(groupId string) ([]network.PortRange, error) {
+	var resp struct {
+		Groups []struct {
+			Id    string `xml:"groupId"`
+			Perms []struct {
+				Protocol string   `xml:"ipProtocol"`
+				FromPort int      `xml:"fromPort"`
+				ToPort   int      `xml:"toPort"`
+				CIDRs    []string `xml:"ipRanges>item>cidrIp"`
+			} `xml:"ipPermissionsEgress>item"`
+		} `xml:"securityGroupInfo>item"`
+	}
+	params := url.Values{"GroupId.1": {groupId}}
+	if err := c.query("DescribeSecurityGroups", params, &resp); err != nil {
+		return nil, errors.Annotatef(err, "getting egress rules of security group %q", groupId)
+	}
+	var ports []network.PortRange
+	for _, group := range resp.Groups {
+		if group.Id != groupId {
+			continue
+		}
+		for _, perm := range group.Perms {
+			if perm.Protocol != "tcp" && perm.Protocol != "udp" {
+				continue
+			}
+			for _, cidr := range perm.CIDRs {
+				if cidr == defaultRouteCIDRBlock {
+					ports = append(ports, network.PortRange{
+						Protocol: perm.Protocol,
+						FromPort: perm.FromPort,
+						ToPort:   perm.ToPort,
+					})
+					break
+				}
+			}
+		}
+		network.SortPortRanges(ports)
+		return ports, nil
+	}
+	return nil, errors.NotFoundf("security group %q", groupId)
+}
+
+func isEgressErrorCode(err error, code string) bool {
+	ec2Err, ok := errors.Cause(err).(*awsError)
+	return ok && ec2Err.Code == code
+}
+
+// machineEgressGroupId returns the ID of the security group of the given
+// machine, whose instance is in the given VPC, for managing its egress
+// rules. Security groups outside VPCs (EC2-Classic) do not support
+// egress rules.
+func (e *environ) machineEgressGroupId(vpcID, machineId string) (string, error) {
+	if e.Config().FirewallMode() != config.FwInstance {
+		return "", errors.NotSupportedf("egress rules with firewall mode %q", e.Config().FirewallMode())
+	}
+	if vpcID == "" {
+		return "", errors.NotSupportedf("egress rules for instances outside a VPC")
+	}
+	g, err := e.groupByName(e.machineGroupName(machineId))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return g.Id, nil
+}
+
+var _ instance.EgressFirewaller = (*ec2Instance)(nil)
+
+// OpenEgressPorts is part of the instance.EgressFirewaller interface.
+func (inst *ec2Instance) OpenEgressPorts(machineId string, ports []network.PortRange) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	groupId, err := inst.e.machineEgressGroupId(inst.VPCId, machineId)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := inst.e.egressRules.AuthorizeEgress(groupId, ports); err != nil {
+		return errors.Trace(err)
+	}
+	logger.Infof("opened egress ports in security group %s: %v", groupId, ports)
+	return nil
+}
+
+// CloseEgressPorts is part of the instance.EgressFirewaller interface.
+func (inst *ec2Instance) CloseEgressPorts(machineId string, ports []network.PortRange) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	groupId, err := inst.e.machineEgressGroupId(inst.VPCId, machineId)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := inst.e.egressRules.RevokeEgress(groupId, ports); err != nil {
+		return errors.Trace(err)
+	}
+	logger.Infof("closed egress ports in security group %s: %v", groupId, ports)
+	return nil
+}
+
+// EgressPorts is part of the instance.EgressFirewaller interface.
+func (inst *ec2Instance) EgressPorts(machineId string) ([]network.PortRange, error) {
+	groupId, err := inst.e.machineEgressGroupId(inst.VPCId, machineId)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return inst.e.egressRules.EgressPorts(groupId)
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/network"
+	"github.com/juju/juju/testing"
+)
+
+type egressSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	statuses  []int
+	responses []string
+	client    *egressClient
+}
+
+var _ = gc.Suite(&egressSuite{})
+
+func (s *egressSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.statuses = nil
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status = s.statuses[0]
+			s.statuses = s.statuses[1:]
+		}
+		w.WriteHeader(status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &egressClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  egressAPIVersion,
+	}}
+}
+
+func egressErrorResponse(code string) string {
+	return fmt.Sprintf(`
+<Response>
+  <Errors>
+    <Error>
+      <Code>%s</Code>
+      <Message>oops</Message>
+    </Error>
+  </Errors>
+</Response>`, code)
+}
+
+var testEgressPorts = []network.PortRange{
+	{Protocol: "tcp", FromPort: 443, ToPort: 443},
+	{Protocol: "udp", FromPort: 53, ToPort: 53},
+}
+
+func (s *egressSuite) TestAuthorizeEgress(c *gc.C) {
+	err := s.client.AuthorizeEgress("sg-1", testEgressPorts)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	req := s.requests[0]
+	c.Check(req.Get("Action"), gc.Equals, "AuthorizeSecurityGroupEgress")
+	c.Check(req.Get("Version"), gc.Equals, egressAPIVersion)
+	c.Check(req.Get("GroupId"), gc.Equals, "sg-1")
+	c.Check(req.Get("IpPermissions.1.IpProtocol"), gc.Equals, "tcp")
+	c.Check(req.Get("IpPermissions.1.FromPort"), gc.Equals, "443")
+	c.Check(req.Get("IpPermissions.1.ToPort"), gc.Equals, "443")
+	c.Check(req.Get("IpPermissions.1.IpRanges.1.CidrIp"), gc.Equals, "0.0.0.0/0")
+	c.Check(req.Get("IpPermissions.2.IpProtocol"), gc.Equals, "udp")
+	c.Check(req.Get("IpPermissions.2.FromPort"), gc.Equals, "53")
+}
+
+func (s *egressSuite) TestAuthorizeEgressDuplicate(c *gc.C) {
+	s.statuses = []int{http.StatusBadRequest, http.StatusBadRequest}
+	s.responses = []string{
+		egressErrorResponse("InvalidPermission.Duplicate"),
+		egressErrorResponse("InvalidPermission.Duplicate"),
+	}
+	err := s.client.AuthorizeEgress("sg-1", testEgressPorts)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The rules are retried individually.
+	c.Assert(s.requests, gc.HasLen, 3)
+	c.Check(s.requests[1].Get("IpPermissions.1.IpProtocol"), gc.Equals, "tcp")
+	c.Check(s.requests[1].Get("IpPermissions.2.IpProtocol"), gc.Equals, "")
+	c.Check(s.requests[2].Get("IpPermissions.1.IpProtocol"), gc.Equals, "udp")
+}
+
+func (s *egressSuite) TestAuthorizeEgressError(c *gc.C) {
+	s.statuses = []int{http.StatusBadRequest}
+	s.responses = []string{egressErrorResponse("InvalidGroup.NotFound")}
+	err := s.client.AuthorizeEgress("sg-1", testEgressPorts[:1])
+	c.Assert(err, gc.ErrorMatches, `authorizing egress from security group "sg-1": oops \(InvalidGroup.NotFound\)`)
+}
+
+func (s *egressSuite) TestRevokeEgressNotFound(c *gc.C) {
+	s.statuses = []int{http.StatusBadRequest}
+	s.responses = []string{egressErrorResponse("InvalidPermission.NotFound")}
+	err := s.client.RevokeEgress("sg-1", testEgressPorts)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "RevokeSecurityGroupEgress")
+}
+
+func (s *egressSuite) TestEgressPorts(c *gc.C) {
+	s.responses = []string{`
+<DescribeSecurityGroupsResponse>
+  <securityGroupInfo>
+    <item>
+      <groupId>sg-1</groupId>
+      <ipPermissionsEgress>
+        <item>
+          <ipProtocol>-1</ipProtocol>
+          <ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges>
+        </item>
+        <item>
+          <ipProtocol>udp</ipProtocol>
+          <fromPort>53</fromPort>
+          <toPort>53</toPort>
+          <ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges>
+        </item>
+        <item>
+          <ipProtocol>tcp</ipProtocol>
+          <fromPort>443</fromPort>
+          <toPort>443</toPort>
+          <ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges>
+        </item>
+        <item>
+          <ipProtocol>tcp</ipProtocol>
+          <fromPort>5432</fromPort>
+          <toPort>5432</toPort>
+          <ipRanges><item><cidrIp>10.0.0.0/8</cidrIp></item></ipRanges>
+        </item>
+      </ipPermissionsEgress>
+    </item>
+  </securityGroupInfo>
+</DescribeSecurityGroupsResponse>`}
+	ports, err := s.client.EgressPorts("sg-1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ports, jc.DeepEquals, []network.PortRange{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443},
+		{Protocol: "udp", FromPort: 53, ToPort: 53},
+	})
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DescribeSecurityGroups")
+	c.Check(s.requests[0].Get("GroupId.1"), gc.Equals, "sg-1")
+}
+
+func (s *egressSuite) TestEgressPortsGroupNotFound(c *gc.C) {
+	s.responses = []string{`<DescribeSecurityGroupsResponse><securityGroupInfo/></DescribeSecurityGroupsResponse>`}
+	_, err := s.client.EgressPorts("sg-1")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *egressSuite) environ(c *gc.C, firewallMode string) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(testing.Attrs{
+		"firewall-mode": firewallMode,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg}
+}
+
+func (s *egressSuite) TestMachineEgressGroupIdNotSupported(c *gc.C) {
+	env := s.environ(c, config.FwInstance)
+	_, err := env.machineEgressGroupId("", "0")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err, gc.ErrorMatches, "egress rules for instances outside a VPC not supported")
+
+	env = s.environ(c, config.FwGlobal)
+	_, err = env.machineEgressGroupId("vpc-1", "0")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err, gc.ErrorMatches, `egress rules with firewall mode "global" not supported`)
+}
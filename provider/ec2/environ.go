@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -74,6 +76,45 @@ type environ struct {
 	defaultVPCMutex   sync.Mutex
 	defaultVPCChecked bool
 	defaultVPC        *ec2.VPC
+
+	targetGroups     targetGroupAPI
+	licenseManager   licenseManagerAPI
+	ssmParameters    ssmParameterAPI
+	launchTemplates  launchTemplateAPI
+	spotPriceHistory spotPriceAPI
+	vpcOwners        vpcOwnerAPI
+	egressRules      egressRulesAPI
+	pricing          pricingAPI
+	dedicatedHosts   dedicatedHostAPI
+	privateDNS       privateDNSAPI
+	dualStack        dualStackAPI
+	inventory        inventoryAPI
+	instanceGroups   instanceGroupsAPI
+	spotRequests     spotRequestAPI
+
+	instanceTypeOfferings instanceTypeOfferingsAPI
+	networkACLs           networkACLAPI
+	instanceTags          instanceTagsAPI
+	drImages              drImageAPI
+
+	// instancePricesMutex protects the cached On-Demand prices of
+	// instance types, which expire at instancePricesExpiry.
+	instancePricesMutex  sync.Mutex
+	instancePrices       map[string]float64
+	instancePricesExpiry time.Time
+
+	// instanceTypeZonesMutex protects the cached availability zones
+	// that offer each instance type.
+	instanceTypeZonesMutex sync.Mutex
+	instanceTypeZonesCache map[string]offeredZones
+
+	// zoneHealth records availability zones that recently lacked
+	// capacity, so that launches try other zones first.
+	zoneHealth *zoneHealth
+
+	// clock is used to wait between the attempts of operations that
+	// are retried.
+	clock clock.Clock
 }
 
 func (e *environ) Config() *config.Config {
@@ -111,7 +152,28 @@ func (env *environ) PrepareForBootstrap(ctx environs.BootstrapContext) error {
 	}
 	ecfg := env.ecfg()
 	vpcID, forceVPCID := ecfg.vpcID(), ecfg.forceVPCID()
-	if err := validateBootstrapVPC(env.ec2, env.cloud.Region, vpcID, forceVPCID, ctx); err != nil {
+	if err := validateBootstrapVPC(vpcValidationClient{env.ec2, env}, env.cloud.Region, vpcID, forceVPCID, ctx); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateTargetGroup(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateLicenseConfiguration(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateImageId(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateLaunchTemplate(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateRoleSubnets(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateBastion(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.cleanOrphanedSecurityGroups(ctx); err != nil {
 		return errors.Trace(err)
 	}
 	return nil
@@ -123,7 +185,22 @@ func (env *environ) Create(args environs.CreateParams) error {
 		return err
 	}
 	vpcID := env.ecfg().vpcID()
-	if err := validateModelVPC(env.ec2, env.name, vpcID); err != nil {
+	if err := validateModelVPC(vpcValidationClient{env.ec2, env}, env.name, vpcID); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateTargetGroup(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateLicenseConfiguration(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateImageId(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateLaunchTemplate(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := env.validateRoleSubnets(); err != nil {
 		return errors.Trace(err)
 	}
 	// TODO(axw) 2016-08-04 #1609643
@@ -174,6 +251,7 @@ func (e *environ) ConstraintsValidator() (constraints.Validator, error) {
 		instTypeNames[i] = itype.Name
 	}
 	validator.RegisterVocabulary(constraints.InstanceType, instTypeNames)
+	validator.RegisterVocabulary(constraints.Accelerator, ec2instancetypes.AcceleratorNames())
 	return validator, nil
 }
 
@@ -244,6 +322,8 @@ func (e *environ) InstanceAvailabilityZoneNames(ids []instance.Id) ([]string, er
 type ec2Placement struct {
 	availabilityZone *ec2.AvailabilityZoneInfo
 	subnet           *ec2.Subnet
+	host             *hostPlacement
+	group            *placementGroup
 }
 
 func (e *environ) parsePlacement(placement string) (*ec2Placement, error) {
@@ -298,6 +378,36 @@ func (e *environ) parsePlacement(placement string) (*ec2Placement, error) {
 			}
 		}
 		logger.Debugf("searched for subnet %q, did not find it in all subnets %v for vpc-id %q", value, allSubnets, vpcId)
+	case "host":
+		host, err := parseHostPlacement(value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := e.lookupDedicatedHost(host); err != nil {
+			return nil, errors.Trace(err)
+		}
+		zones, err := e.AvailabilityZones()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, z := range zones {
+			if z.Name() == host.host.AvailabilityZone {
+				ec2AZ := z.(*ec2AvailabilityZone)
+				return &ec2Placement{
+					availabilityZone: &ec2AZ.AvailabilityZoneInfo,
+					host:             host,
+				}, nil
+			}
+		}
+		return nil, errors.Errorf("dedicated host %q is in unknown availability zone %q", host.hostId, host.host.AvailabilityZone)
+	case "placement-group":
+		group, err := parsePlacementGroup(value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		// AWS chooses the zone of a group's first instance, so the
+		// placement does not determine one.
+		return &ec2Placement{group: group}, nil
 	}
 	return nil, fmt.Errorf("unknown placement directive: %v", placement)
 }
@@ -308,12 +418,53 @@ func (e *environ) PrecheckInstance(args environs.PrecheckInstanceParams) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
-	if _, _, err := e.instancePlacementZone(args.Placement, volumeAttachmentsZone); err != nil {
+	_, _, placement, err := e.instancePlacementZone(args.Placement, volumeAttachmentsZone)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if placement != nil && args.Constraints.HasInstanceType() {
+		instanceType := *args.Constraints.InstanceType
+		if host := placement.host; host != nil && !host.host.supportsInstanceType(instanceType) {
+			return errors.Errorf(
+				"dedicated host %q cannot run instances of type %q",
+				host.hostId, instanceType,
+			)
+		}
+		if group := placement.group; group != nil {
+			if err := group.checkInstanceType(instanceType); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	if err := e.precheckImageOwner(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Constraints.HasAccelerator() {
+		if err := e.precheckAccelerator(args.Constraints); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := e.precheckMaxInstanceSize(args.Constraints); err != nil {
 		return errors.Trace(err)
 	}
 	if !args.Constraints.HasInstanceType() {
 		return nil
 	}
+	if bandwidth := e.ecfg().ebsBaselineBandwidth(); bandwidth > 0 {
+		if err := checkEBSBaselineBandwidth(*args.Constraints.InstanceType, bandwidth); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if volumes := e.ecfg().instanceStoreVolumes(); volumes > 0 {
+		if err := checkInstanceStoreVolumes(*args.Constraints.InstanceType, volumes); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if e.ecfg().instanceAutoRecovery() {
+		if err := checkAutoRecovery(*args.Constraints.InstanceType); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	// Constraint has an instance-type constraint so let's see if it is valid.
 	instanceTypes, err := e.supportedInstanceTypes()
 	if err != nil {
@@ -323,9 +474,16 @@ func (e *environ) PrecheckInstance(args environs.PrecheckInstanceParams) error {
 		if itype.Name != *args.Constraints.InstanceType {
 			continue
 		}
-		if archMatches(itype.Arches, args.Constraints.Arch) {
-			return nil
+		if !archMatches(itype.Arches, args.Constraints.Arch) {
+			continue
 		}
+		if ecfg := e.ecfg(); ecfg.maxInstanceSizeAction() == maxInstanceSizeReject && !instanceTypeWithinSize(itype, ecfg.maxInstanceSize()) {
+			return errors.Errorf(
+				"instance type %q (%s) exceeds max-instance-size %q",
+				itype.Name, describeInstanceSize(itype), ecfg.maxInstanceSize(),
+			)
+		}
+		return nil
 	}
 	if args.Constraints.Arch == nil {
 		return fmt.Errorf("invalid AWS instance type %q specified", *args.Constraints.InstanceType)
@@ -392,12 +550,19 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 	if args.ControllerUUID == "" {
 		return nil, errors.New("missing controller UUID")
 	}
+	if err := e.checkMaxInstances(); err != nil {
+		return nil, errors.Trace(err)
+	}
 	var inst *ec2Instance
 	callback := args.StatusCallback
 	defer func() {
 		if resultErr == nil || inst == nil {
 			return
 		}
+		if isInstanceBootTimeoutError(resultErr) && !e.ecfg().terminateStuckInstances() {
+			logger.Warningf("leaving instance %s, which failed to reach running, for investigation", inst.Id())
+			return
+		}
 		if err := e.StopInstances(inst.Id()); err != nil {
 			callback(status.Error, fmt.Sprintf("error stopping failed instance: %v", err), nil)
 			logger.Errorf("error stopping failed instance: %v", err)
@@ -413,7 +578,16 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	placementZone, placementSubnetID, err := e.instancePlacementZone(args.Placement, volumeAttachmentsZone)
+	// The EBS volumes to be created with the instance are validated
+	// before it is launched; they are created in its zone once it is
+	// running.
+	instanceVolumes := instanceVolumeParams(args.Volumes)
+	for _, p := range instanceVolumes {
+		if err := (&ebsVolumeSource{env: e}).ValidateVolumeParams(p); err != nil {
+			return nil, errors.Annotatef(err, "invalid parameters for volume %s", p.Tag.Id())
+		}
+	}
+	placementZone, placementSubnetID, placement, err := e.instancePlacementZone(args.Placement, volumeAttachmentsZone)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -444,6 +618,7 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		if len(availabilityZones) == 0 {
 			return nil, errors.New("failed to determine availability zones")
 		}
+		availabilityZones = e.zoneHealth.order(availabilityZones)
 	}
 
 	arches := args.Tools.Arches()
@@ -452,6 +627,26 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	ebsBaselineBandwidth := e.ecfg().ebsBaselineBandwidth()
+	if ebsBaselineBandwidth > 0 {
+		instanceTypes = instanceTypesWithEBSBaselineBandwidth(instanceTypes, ebsBaselineBandwidth)
+	}
+	if volumes := e.ecfg().instanceStoreVolumes(); volumes > 0 {
+		instanceTypes = instanceTypesWithInstanceStores(instanceTypes, volumes)
+	}
+	autoRecovery := e.ecfg().instanceAutoRecovery()
+	if autoRecovery {
+		instanceTypes = instanceTypesWithAutoRecovery(instanceTypes)
+	}
+	if args.Constraints.HasAccelerator() {
+		instanceTypes = instanceTypesWithAccelerator(instanceTypes, *args.Constraints.Accelerator)
+	}
+	if placement != nil && placement.host != nil {
+		instanceTypes = instanceTypesForDedicatedHost(instanceTypes, placement.host.host)
+	}
+	if placement != nil && placement.group != nil {
+		instanceTypes = instanceTypesForPlacementGroup(instanceTypes, placement.group)
+	}
 
 	spec, err := findInstanceSpec(
 		args.InstanceConfig.Controller != nil,
@@ -476,6 +671,60 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 	if spec.InstanceType.Deprecated {
 		logger.Infof("deprecated instance type specified: %s", spec.InstanceType.Name)
 	}
+	maxInstanceSize := e.ecfg().maxInstanceSize()
+	if !instanceTypeWithinSize(spec.InstanceType, maxInstanceSize) {
+		downgrade, err := e.downgradeInstanceType(
+			instanceTypes, spec.InstanceType, spec.Image,
+			args.InstanceConfig.Controller != nil,
+		)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		callback(status.Allocating, fmt.Sprintf(
+			"Instance type %q exceeds max-instance-size; using %q",
+			spec.InstanceType.Name, downgrade.Name,
+		), nil)
+		spec.InstanceType = downgrade
+	}
+	// Automatically chosen zones that do not offer the instance type
+	// would only fail the launch, so the others are tried first.
+	if placementZone == "" && len(availabilityZones) > 1 {
+		availabilityZones = e.preferOfferedZones(availabilityZones, spec.InstanceType.Name)
+	}
+
+	// Images specified by the user may have been shared from another
+	// account, so check up front that this account can see them. An
+	// image configured for the model is used instead of the one found
+	// in simplestreams.
+	var customImage *ec2.Image
+	if e.ecfg().imageID() != "" {
+		customImage, err = e.configuredImage(args.InstanceConfig.Series, spec.Image.Arch)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		spec.Image.Id = customImage.Id
+	} else if isCustomImage(args.ImageMetadata, spec.Image.Id) {
+		customImage, err = validateCustomImage(e.ec2, spec.Image.Id)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if customImage.OwnerId != "" {
+			logger.Debugf("using image %q owned by account %q", customImage.Id, customImage.OwnerId)
+		}
+	}
+	if len(e.ecfg().allowedAMIOwners()) > 0 {
+		// The owner of images found in simplestreams is only known
+		// once they have been described.
+		image := customImage
+		if image == nil {
+			if image, err = validateCustomImage(e.ec2, spec.Image.Id); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if err := e.checkImageOwner(image); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 
 	if err := args.InstanceConfig.SetTools(tools); err != nil {
 		return nil, errors.Trace(err)
@@ -496,17 +745,27 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 	} else {
 		apiPort = args.InstanceConfig.APIInfo.Ports()[0]
 	}
+	// The instance's tags include the controller's default resource
+	// tags, so they are set on the other resources created for it.
+	resourceTags := e.modelResourceTags(args.ControllerUUID, ec2Tags(args.InstanceConfig.Tags))
 	callback(status.Allocating, "Setting up groups", nil)
-	groups, err := e.setUpGroups(args.ControllerUUID, args.InstanceConfig.MachineId, apiPort)
+	groups, err := e.setUpGroups(resourceTags, args.InstanceConfig.MachineId, apiPort)
 
 	if err != nil {
 		return nil, errors.Annotate(err, "cannot set up groups")
 	}
 
+	// Instances with instance store volumes cannot be recovered.
+	var instanceStores int
+	if !autoRecovery {
+		instanceStores = instanceStoreVolumes(spec.InstanceType.Name, e.ecfg().instanceStoreVolumes())
+	}
 	blockDeviceMappings := getBlockDeviceMappings(
 		args.Constraints,
 		args.InstanceConfig.Series,
 		args.InstanceConfig.Controller != nil,
+		e.ecfg().defaultRootVolumeType(),
+		instanceStores,
 	)
 	rootDiskSize := uint64(blockDeviceMappings[0].VolumeSize) * 1024
 
@@ -523,6 +782,27 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		logger.Infof("ignoring all but the first positive space from constraints: %v", spaces)
 	}
 
+	// Unless placed in a specific subnet, machines are started in the
+	// subnets configured for their role, if any.
+	subnetsToZones := args.SubnetsToZones
+	haveSubnetsToZones := args.Constraints.HaveSpaces()
+	if placementSubnetID == "" {
+		isController := args.InstanceConfig.Controller != nil
+		roleSubnets, err := e.roleSubnetsToZones(isController)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(roleSubnets) > 0 {
+			subnetsToZones, availabilityZones, err = restrictToRoleSubnets(
+				roleSubnetsKey(isController), roleSubnets, args.SubnetsToZones, availabilityZones,
+			)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			haveSubnetsToZones = true
+		}
+	}
+
 	var instResp *ec2.RunInstancesResp
 	commonRunArgs := &ec2.RunInstances{
 		MinCount:            1,
@@ -532,68 +812,213 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		SecurityGroups:      groups,
 		BlockDeviceMappings: blockDeviceMappings,
 		ImageId:             spec.Image.Id,
+		EBSOptimized:        ebsBaselineBandwidth > 0,
+		// StopInstances terminates instances directly, so this only
+		// affects instances that are shut down from within.
+		ShutdownBehavior: e.ecfg().instanceInitiatedShutdownBehavior(),
+		// Detailed monitoring is charged for, so it is only enabled
+		// when the model asks for it.
+		Monitoring: e.ecfg().detailedMonitoring(),
 	}
 
 	haveVPCID := isVPCIDSet(e.ecfg().vpcID())
 
-	for _, zone := range availabilityZones {
-		runArgs := commonRunArgs
-		runArgs.AvailZone = zone
-
-		var subnetIDsForZone []string
-		var subnetErr error
-		if haveVPCID {
-			var allowedSubnetIDs []string
-			if placementSubnetID != "" {
-				allowedSubnetIDs = []string{placementSubnetID}
-			} else {
-				for subnetID, _ := range args.SubnetsToZones {
-					allowedSubnetIDs = append(allowedSubnetIDs, string(subnetID))
+	// A transient shortage of capacity in every zone would otherwise fail
+	// the whole bootstrap, so the bootstrap instance's launch is retried
+	// with backoff, up to the configured number of times.
+	launchRetries := 0
+	if args.InstanceConfig.Bootstrap != nil {
+		launchRetries = e.ecfg().bootstrapLaunchRetries()
+	}
+	instanceTypeFallback := e.ecfg().instanceTypeFallback()
+	// Falling back never exceeds max-instance-size.
+	fallbackInstanceTypes := instanceTypesWithinSize(instanceTypes, maxInstanceSize)
+	// Errors other than failures to launch the instance end the
+	// launch without retrying.
+	var abortErr error
+	attempt := 0
+	launchRetry := bootstrapLaunchRetry
+	launchRetry.Attempts = launchRetries + 1
+	launchRetry.Clock = e.clock
+	launchRetry.IsFatalError = func(err error) bool {
+		return abortErr != nil || !isZoneCapacityError(err)
+	}
+	launchRetry.NotifyFunc = func(err error, n int) {
+		if n > launchRetries {
+			return
+		}
+		logger.Infof("no capacity in any availability zone, retrying: %v", err)
+		callback(status.Allocating, fmt.Sprintf(
+			"No capacity in any availability zone; retrying (attempt %d of %d)",
+			n+1, launchRetries+1,
+		), nil)
+	}
+	launchRetry.Func = func() error {
+		defer func() { attempt++ }()
+		for {
+			for _, zone := range availabilityZones {
+				runArgs := commonRunArgs
+				runArgs.AvailZone = zone
+
+				var subnetIDsForZone []string
+				var subnetErr error
+				if haveVPCID {
+					var allowedSubnetIDs []string
+					if placementSubnetID != "" {
+						allowedSubnetIDs = []string{placementSubnetID}
+					} else {
+						for subnetID, _ := range subnetsToZones {
+							allowedSubnetIDs = append(allowedSubnetIDs, string(subnetID))
+						}
+					}
+					subnetIDsForZone, subnetErr = getVPCSubnetIDsForAvailabilityZone(e.ec2, e.ecfg().vpcID(), zone, allowedSubnetIDs)
+				} else if haveSubnetsToZones {
+					subnetIDsForZone, subnetErr = findSubnetIDsForAvailabilityZone(zone, subnetsToZones)
+					if subnetErr == nil && placementSubnetID != "" {
+						asSet := set.NewStrings(subnetIDsForZone...)
+						if asSet.Contains(placementSubnetID) {
+							subnetIDsForZone = []string{placementSubnetID}
+						} else {
+							subnetIDsForZone = nil
+							subnetErr = errors.NotFoundf("subnets %q in AZ %q", placementSubnetID, zone)
+						}
+					}
 				}
-			}
-			subnetIDsForZone, subnetErr = getVPCSubnetIDsForAvailabilityZone(e.ec2, e.ecfg().vpcID(), zone, allowedSubnetIDs)
-		} else if args.Constraints.HaveSpaces() {
-			subnetIDsForZone, subnetErr = findSubnetIDsForAvailabilityZone(zone, args.SubnetsToZones)
-			if subnetErr == nil && placementSubnetID != "" {
-				asSet := set.NewStrings(subnetIDsForZone...)
-				if asSet.Contains(placementSubnetID) {
-					subnetIDsForZone = []string{placementSubnetID}
-				} else {
-					subnetIDsForZone = nil
-					subnetErr = errors.NotFoundf("subnets %q in AZ %q", placementSubnetID, zone)
+
+				switch {
+				case subnetErr != nil && errors.IsNotFound(subnetErr):
+					logger.Infof("no matching subnets in zone %q; assuming zone is constrained and trying another", zone)
+					continue
+				case subnetErr != nil:
+					abortErr = errors.Annotatef(subnetErr, "getting subnets for zone %q", zone)
+					return abortErr
+				case len(subnetIDsForZone) > 1:
+					// With multiple equally suitable subnets, picking one at random
+					// will allow for better instance spread within the same zone, and
+					// still work correctly if we happen to pick a constrained subnet
+					// (we'll just treat this the same way we treat constrained zones
+					// and retry).
+					runArgs.SubnetId = subnetIDsForZone[rand.Intn(len(subnetIDsForZone))]
+					logger.Debugf("selected random subnet %q from all matching in zone %q", runArgs.SubnetId, zone)
+				case len(subnetIDsForZone) == 1:
+					runArgs.SubnetId = subnetIDsForZone[0]
+					logger.Debugf("selected subnet %q in zone %q", runArgs.SubnetId, zone)
 				}
+				if err := e.validatePrivateDNSOptions(runArgs.SubnetId); err != nil {
+					abortErr = errors.Trace(err)
+					return abortErr
+				}
+
+				extrasArgs := runInstancesExtrasArgs{
+					placement: placement,
+					launch: runInstancesLaunch{
+						controllerUUID: args.ControllerUUID,
+						modelUUID:      e.uuid(),
+						machineId:      args.InstanceConfig.MachineId,
+						nonce:          args.InstanceConfig.MachineNonce,
+						availZone:      zone,
+						instanceType:   runArgs.InstanceType,
+						attempt:        attempt,
+					},
+				}
+				// Instances in dual-stack subnets are assigned an IPv6
+				// address as well; elsewhere they are IPv4-only.
+				if runArgs.SubnetId != "" {
+					ipv6CIDR, err := e.dualStack.SubnetIPv6CIDR(runArgs.SubnetId)
+					if err != nil {
+						abortErr = errors.Trace(err)
+						return abortErr
+					}
+					extrasArgs.ipv6 = ipv6CIDR != ""
+				}
+				// The parameters the EC2 client library has no support
+				// for are added to the request by a single signer.
+				runClient := *e.ec2
+				runClient.Sign = runInstancesSigner(e.ec2.Sign, e.runInstancesExtras(extrasArgs))
+
+				callback(status.Allocating, fmt.Sprintf("Trying to start instance in availability zone %q", zone), nil)
+				instResp, err = runInstances(&runClient, runArgs, callback)
+				if isZoneCapacityError(err) {
+					e.zoneHealth.recordFailure(zone)
+				}
+				if err == nil || !isZoneOrSubnetConstrainedError(err) {
+					break
+				}
+
+				logger.Infof("%q is constrained, trying another availability zone", zone)
 			}
-		}
 
-		switch {
-		case subnetErr != nil && errors.IsNotFound(subnetErr):
-			logger.Infof("no matching subnets in zone %q; assuming zone is constrained and trying another", zone)
-			continue
-		case subnetErr != nil:
-			return nil, errors.Annotatef(subnetErr, "getting subnets for zone %q", zone)
-		case len(subnetIDsForZone) > 1:
-			// With multiple equally suitable subnets, picking one at random
-			// will allow for better instance spread within the same zone, and
-			// still work correctly if we happen to pick a constrained subnet
-			// (we'll just treat this the same way we treat constrained zones
-			// and retry).
-			runArgs.SubnetId = subnetIDsForZone[rand.Intn(len(subnetIDsForZone))]
-			logger.Debugf("selected random subnet %q from all matching in zone %q", runArgs.SubnetId, zone)
-		case len(subnetIDsForZone) == 1:
-			runArgs.SubnetId = subnetIDsForZone[0]
-			logger.Debugf("selected subnet %q in zone %q", runArgs.SubnetId, zone)
-		}
-
-		callback(status.Allocating, fmt.Sprintf("Trying to start instance in availability zone %q", zone), nil)
-		instResp, err = runInstances(e.ec2, runArgs, callback)
-		if err == nil || !isZoneOrSubnetConstrainedError(err) {
-			break
+			// With insufficient capacity for the instance type in every
+			// zone, the next larger type of its instance-type-fallback
+			// ladder, if any, is tried before retrying the same type.
+			if err != nil && isZoneCapacityError(err) {
+				if next, ok := fallbackInstanceType(fallbackInstanceTypes, instanceTypeFallback, spec.InstanceType.Name, spec.Image.Arch); ok {
+					logger.Infof("no capacity for instance type %q in any availability zone, trying %q: %v", spec.InstanceType.Name, next.Name, err)
+					callback(status.Allocating, fmt.Sprintf(
+						"No capacity for instance type %q in any availability zone; trying %q",
+						spec.InstanceType.Name, next.Name,
+					), nil)
+					spec.InstanceType = next
+					commonRunArgs.InstanceType = next.Name
+					if !autoRecovery {
+						instanceStores = instanceStoreVolumes(next.Name, e.ecfg().instanceStoreVolumes())
+						commonRunArgs.BlockDeviceMappings = getBlockDeviceMappings(
+							args.Constraints,
+							args.InstanceConfig.Series,
+							args.InstanceConfig.Controller != nil,
+							e.ecfg().defaultRootVolumeType(),
+							instanceStores,
+						)
+					}
+					if placementZone == "" && len(availabilityZones) > 1 {
+						availabilityZones = e.preferOfferedZones(availabilityZones, next.Name)
+					}
+					// Falling back does not use up a launch retry.
+					continue
+				}
+			}
+			return err
 		}
-
-		logger.Infof("%q is constrained, trying another availability zone", zone)
+	}
+	err = retry.Call(launchRetry)
+	if retry.IsAttemptsExceeded(err) {
+		err = retry.LastError(err)
+	}
+	if abortErr != nil {
+		return nil, abortErr
 	}
 
 	if err != nil {
+		if customImage != nil && isImageLaunchPermissionError(err) {
+			owner, ownerErr := e.sharedImageOwner(customImage)
+			if ownerErr != nil {
+				logger.Warningf("cannot tell whether image %q is shared with this account: %v", customImage.Id, ownerErr)
+			}
+			if owner != "" {
+				return nil, errors.Errorf(
+					"cannot run instances: image %q owned by account %q is shared with this account, "+
+						"but cannot be launched; check that the owner has granted launch permission "+
+						"and shared the image's snapshots and encryption keys: %v",
+					customImage.Id, owner, err,
+				)
+			}
+			return nil, errors.Errorf(
+				"cannot run instances: image %q cannot be launched; check that its snapshots "+
+					"and encryption keys are available to this account: %v",
+				customImage.Id, err,
+			)
+		}
+		if haveVPCID && commonRunArgs.SubnetId != "" && isSharedSubnetPermissionError(err) {
+			vpcID := e.ecfg().vpcID()
+			if owner, ownerErr := e.sharedVPCOwner(vpcID); ownerErr == nil && owner != "" {
+				return nil, errors.Errorf(
+					"cannot run instances: subnet %q of VPC %q is shared with this account by account %q, "+
+						"but cannot be used; check that the owner's resource share includes the subnet "+
+						"and this account: %v",
+					commonRunArgs.SubnetId, vpcID, owner, err,
+				)
+			}
+		}
 		return nil, errors.Annotate(err, "cannot run instances")
 	}
 	if len(instResp.Instances) != 1 {
@@ -605,6 +1030,7 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		Instance: &instResp.Instances[0],
 	}
 	instAZ := inst.Instance.AvailZone
+	e.zoneHealth.recordSuccess(instAZ)
 	if haveVPCID {
 		instVPC := e.ecfg().vpcID()
 		instSubnet := inst.Instance.SubnetId
@@ -624,18 +1050,43 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 
 	// Tag the machine's root EBS volume, if it has one.
 	if inst.Instance.RootDeviceType == "ebs" {
-		cfg := e.Config()
-		tags := tags.ResourceTags(
-			names.NewModelTag(cfg.UUID()),
-			names.NewControllerTag(args.ControllerUUID),
-			cfg,
-		)
+		tags := make(map[string]string)
+		for k, v := range resourceTags {
+			tags[k] = v
+		}
 		tags[tagName] = instanceName + "-root"
 		if err := tagRootDisk(e.ec2, tags, inst.Instance); err != nil {
 			return nil, errors.Annotate(err, "tagging root disk")
 		}
 	}
 
+	timeout := e.ecfg().instanceBootTimeout()
+	if timeout == 0 && len(instanceVolumes) > 0 {
+		// Volumes can only be attached to running instances.
+		timeout = defaultInstanceVolumesBootTimeout
+	}
+	if timeout > 0 {
+		if err := e.waitForInstanceRunning(inst, timeout, callback); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	var volumes []storage.Volume
+	var volumeAttachments []storage.VolumeAttachment
+	if len(instanceVolumes) > 0 {
+		callback(status.Allocating, "Creating volumes", nil)
+		volumes, volumeAttachments, err = e.createInstanceVolumes(inst.Instance, instanceVolumes)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	// The image a controller is bootstrapped from is copied to the
+	// model's dr-secondary-region, if any.
+	if args.InstanceConfig.Bootstrap != nil {
+		e.stageDRImage(customImage, callback)
+	}
+
 	hc := instance.HardwareCharacteristics{
 		Arch:     &spec.Image.Arch,
 		Mem:      &spec.InstanceType.Mem,
@@ -645,41 +1096,55 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		// Tags currently not supported by EC2
 		AvailabilityZone: &inst.Instance.AvailZone,
 	}
+	if accel, ok := ec2instancetypes.Accelerators(spec.InstanceType.Name); ok {
+		count := uint64(accel.Count)
+		hc.Accelerator = &accel.Model
+		hc.AcceleratorCount = &count
+	}
 	return &environs.StartInstanceResult{
-		Instance: inst,
-		Hardware: &hc,
+		Instance:          inst,
+		Hardware:          &hc,
+		Volumes:           volumes,
+		VolumeAttachments: volumeAttachments,
 	}, nil
 }
 
-func (e *environ) instancePlacementZone(placement, volumeAttachmentsZone string) (zone, subnet string, _ error) {
+// instancePlacementZone returns the availability zone and subnet, if
+// any, determined by the given placement directive and the zone of the
+// volumes to be attached, and the parsed placement, if one is given.
+func (e *environ) instancePlacementZone(placement, volumeAttachmentsZone string) (zone, subnet string, _ *ec2Placement, _ error) {
 	if placement == "" {
-		return volumeAttachmentsZone, "", nil
+		return volumeAttachmentsZone, "", nil, nil
 	}
 	var placementSubnetID string
 	instPlacement, err := e.parsePlacement(placement)
 	if err != nil {
-		return "", "", errors.Trace(err)
+		return "", "", nil, errors.Trace(err)
+	}
+	if instPlacement.availabilityZone == nil {
+		// The placement does not determine a zone.
+		return volumeAttachmentsZone, "", instPlacement, nil
 	}
 	if instPlacement.availabilityZone.State != availableState {
-		return "", "", errors.Errorf(
+		return "", "", nil, errors.Errorf(
 			"availability zone %q is %q",
 			instPlacement.availabilityZone.Name,
 			instPlacement.availabilityZone.State,
 		)
 	}
 	if volumeAttachmentsZone != "" && volumeAttachmentsZone != instPlacement.availabilityZone.Name {
-		return "", "", errors.Errorf(
+		return "", "", nil, errors.Errorf(
 			"cannot create instance with placement %q, as this will prevent attaching the requested EBS volumes in zone %q",
 			placement, volumeAttachmentsZone,
 		)
 	}
 	if instPlacement.subnet != nil {
 		if instPlacement.subnet.State != availableState {
-			return "", "", errors.Errorf("subnet %q is %q", instPlacement.subnet.CIDRBlock, instPlacement.subnet.State)
+			return "", "", nil, errors.Errorf("subnet %q is %q", instPlacement.subnet.CIDRBlock, instPlacement.subnet.State)
 		}
 		placementSubnetID = instPlacement.subnet.Id
 	}
-	return instPlacement.availabilityZone.Name, placementSubnetID, nil
+	return instPlacement.availabilityZone.Name, placementSubnetID, instPlacement, nil
 }
 
 // volumeAttachmentsZone determines the availability zone for each volume
@@ -721,13 +1186,9 @@ func tagResources(e *ec2.EC2, tags map[string]string, resourceIds ...string) err
 	if len(tags) == 0 {
 		return nil
 	}
-	ec2Tags := make([]ec2.Tag, 0, len(tags))
-	for k, v := range tags {
-		ec2Tags = append(ec2Tags, ec2.Tag{k, v})
-	}
 	var err error
 	for a := shortAttempt.Start(); a.Next(); {
-		_, err = e.CreateTags(resourceIds, ec2Tags)
+		_, err = e.CreateTags(resourceIds, ec2Tags(tags))
 		if err == nil || !strings.HasSuffix(ec2ErrCode(err), ".NotFound") {
 			return err
 		}
@@ -735,6 +1196,31 @@ func tagResources(e *ec2.EC2, tags map[string]string, resourceIds ...string) err
 	return err
 }
 
+// ec2Tags returns the given tags as EC2 tags.
+func ec2Tags(tags map[string]string) []ec2.Tag {
+	result := make([]ec2.Tag, 0, len(tags))
+	for k, v := range tags {
+		result = append(result, ec2.Tag{k, v})
+	}
+	return result
+}
+
+// modelResourceTags returns the tags to set on a resource created for
+// the model alongside an instance with the given tags: the model's
+// resource tags, over the user tags of the instance. The instance's
+// tags hold the controller's default resource tags, which are not
+// otherwise known to the provider.
+func (e *environ) modelResourceTags(controllerUUID string, instanceTags []ec2.Tag) map[string]string {
+	cfg := e.Config()
+	resourceTags := tags.ResourceTags(
+		names.NewModelTag(cfg.UUID()),
+		names.NewControllerTag(controllerUUID),
+		cfg,
+	)
+	addInstanceTags(resourceTags, instanceTags)
+	return resourceTags
+}
+
 func tagRootDisk(e *ec2.EC2, tags map[string]string, inst *ec2.Instance) error {
 	if len(tags) == 0 {
 		return nil
@@ -781,13 +1267,22 @@ var runInstances = _runInstances
 
 // runInstances calls ec2.RunInstances for a fixed number of attempts until
 // RunInstances returns an error code that does not indicate an error that
-// may be caused by eventual consistency.
+// may be caused by eventual consistency, or a request that timed out.
+//
+// The client must make requests with a ClientToken (see
+// runInstancesExtras), so that a request that timed out once connected,
+// and may have launched an instance, is retried without launching
+// another.
 func _runInstances(e *ec2.EC2, ri *ec2.RunInstances, c environs.StatusCallbackFunc) (resp *ec2.RunInstancesResp, err error) {
+	// Make at least two attempts, as a request that timed out
+	// may have outlasted the attempt strategy.
+	attempt := shortAttempt
+	attempt.Min = 2
 	try := 1
-	for a := shortAttempt.Start(); a.Next(); {
+	for a := attempt.Start(); a.Next(); {
 		c(status.Allocating, fmt.Sprintf("Start instance attempt %d", try), nil)
 		resp, err = e.RunInstances(ri)
-		if err == nil || !isNotFoundError(err) {
+		if err == nil || !isNotFoundError(err) && !isAPITimeout(err) {
 			break
 		}
 		try++
@@ -855,6 +1350,9 @@ func (e *environ) Instances(ids []instance.Id) ([]instance.Instance, error) {
 			break
 		}
 	}
+	if err == nil || err == environs.ErrPartialInstances {
+		e.addSpotRequests(insts)
+	}
 	if err == environs.ErrPartialInstances {
 		for _, inst := range insts {
 			if inst != nil {
@@ -937,8 +1435,12 @@ func (e *environ) NetworkInterfaces(instId instance.Id) ([]network.InterfaceInfo
 		return nil, errors.Annotatef(err, "cannot get instance %q network interfaces", instId)
 	}
 	ec2Interfaces := networkInterfacesResp.Interfaces
-	result := make([]network.InterfaceInfo, len(ec2Interfaces))
-	for i, iface := range ec2Interfaces {
+	ipv6Addresses, err := e.instanceIPv6Addresses(string(instId))
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot get instance %q IPv6 addresses", instId)
+	}
+	var result []network.InterfaceInfo
+	for _, iface := range ec2Interfaces {
 		resp, err := e.ec2.Subnets([]string{iface.SubnetId}, nil)
 		if err != nil {
 			return nil, errors.Annotatef(err, "failed to retrieve subnet %q info", iface.SubnetId)
@@ -949,7 +1451,7 @@ func (e *environ) NetworkInterfaces(instId instance.Id) ([]network.InterfaceInfo
 		subnet := resp.Subnets[0]
 		cidr := subnet.CIDRBlock
 
-		result[i] = network.InterfaceInfo{
+		info := network.InterfaceInfo{
 			DeviceIndex:       iface.Attachment.DeviceIndex,
 			MACAddress:        iface.MACAddress,
 			CIDR:              cidr,
@@ -965,6 +1467,26 @@ func (e *environ) NetworkInterfaces(instId instance.Id) ([]network.InterfaceInfo
 			InterfaceType: network.EthernetInterface,
 			Address:       network.NewScopedAddress(iface.PrivateIPAddress, network.ScopeCloudLocal),
 		}
+		result = append(result, info)
+
+		// The IPv6 addresses of interfaces in dual-stack subnets are
+		// reported as further entries for the same device.
+		if len(ipv6Addresses[iface.Id]) == 0 {
+			continue
+		}
+		ipv6CIDR, err := e.dualStack.SubnetIPv6CIDR(iface.SubnetId)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to retrieve subnet %q IPv6 CIDR", iface.SubnetId)
+		}
+		if ipv6CIDR == "" {
+			continue
+		}
+		for _, addr := range ipv6Addresses[iface.Id] {
+			ipv6Info := info
+			ipv6Info.CIDR = ipv6CIDR
+			ipv6Info.Address = network.NewAddress(addr)
+			result = append(result, ipv6Info)
+		}
 	}
 	return result, nil
 }
@@ -1102,15 +1624,150 @@ func (e *environ) AdoptResources(controllerUUID string, fromVersion version.Numb
 		return errors.Trace(err)
 	}
 
-	resourceIds := make([]string, len(instances))
+	// Resources already tagged with the controller UUID are left
+	// alone, so that adoption can be resumed if it fails part way.
+	adopted, err := e.adoptedResourceIds(controllerUUID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	allIds := make([]string, len(instances))
 	for i, instance := range instances {
-		resourceIds[i] = string(instance.Id())
+		allIds[i] = string(instance.Id())
+	}
+	allIds = append(allIds, volumeIds...)
+	allIds = append(allIds, groupIds...)
+	var resourceIds []string
+	for _, id := range allIds {
+		if !adopted.Contains(id) {
+			resourceIds = append(resourceIds, id)
+		}
+	}
+	if len(resourceIds) == 0 {
+		return nil
 	}
-	resourceIds = append(resourceIds, volumeIds...)
-	resourceIds = append(resourceIds, groupIds...)
 
 	tags := map[string]string{tags.JujuController: controllerUUID}
-	return errors.Annotate(tagResources(e.ec2, tags, resourceIds...), "updating tags")
+	err = tagResourcesInBatches(newResourceTagger(e.ec2), tags, resourceIds)
+	return errors.Annotate(err, "updating tags")
+}
+
+// adoptedResourceIds returns the IDs of the model's instances, volumes
+// and security groups that are already tagged with the given controller
+// UUID.
+func (e *environ) adoptedResourceIds(controllerUUID string) (set.Strings, error) {
+	adopted := set.NewStrings()
+	newFilter := func() *ec2.Filter {
+		filter := ec2.NewFilter()
+		e.addModelFilter(filter)
+		e.addControllerFilter(filter, controllerUUID)
+		return filter
+	}
+
+	filter := newFilter()
+	filter.Add("instance-state-name", aliveInstanceStates...)
+	instIds, err := e.allInstanceIDs(filter)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, id := range instIds {
+		adopted.Add(string(id))
+	}
+	volumeIds, err := listVolumes(e.ec2, newFilter(), true)
+	if err != nil {
+		return nil, errors.Annotate(err, "listing volumes")
+	}
+	adopted = adopted.Union(set.NewStrings(volumeIds...))
+	resp, err := e.ec2.SecurityGroups(nil, newFilter())
+	if err != nil {
+		return nil, errors.Annotate(err, "listing security groups")
+	}
+	for _, info := range resp.Groups {
+		adopted.Add(info.Id)
+	}
+	return adopted, nil
+}
+
+// resourceTagger defines the EC2 API method used to tag resources.
+type resourceTagger interface {
+	CreateTags(resourceIds []string, tags []ec2.Tag) (*ec2.SimpleResp, error)
+}
+
+// newResourceTagger returns the resourceTagger used to tag resources
+// when adopting them. It is a variable so it can be replaced in tests.
+var newResourceTagger = func(client *ec2.EC2) resourceTagger {
+	return client
+}
+
+// bootstrapLaunchRetry holds the parameters for retrying the launch of
+// a bootstrap instance that failed for lack of capacity in every
+// availability zone. The number of attempts is set from
+// bootstrap-launch-retries, and the clock is the environ's.
+var bootstrapLaunchRetry = retry.CallArgs{
+	Delay:       10 * time.Second,
+	MaxDelay:    2 * time.Minute,
+	BackoffFunc: retry.DoubleDelay,
+}
+
+// tagResourcesBatchSize is the maximum number of resources tagged by
+// each call to CreateTags made by tagResourcesInBatches. EC2 accepts up
+// to 1000 resource IDs in a call.
+var tagResourcesBatchSize = 1000
+
+// tagResourcesRetry holds the parameters for retrying throttled calls
+// to CreateTags made by tagResourcesInBatches.
+var tagResourcesRetry = retry.CallArgs{
+	Attempts:    10,
+	Delay:       time.Second,
+	MaxDelay:    30 * time.Second,
+	BackoffFunc: retry.DoubleDelay,
+	Clock:       clock.WallClock,
+}
+
+// tagResourcesInBatches tags each of the specified resources with the
+// given tags, in batches of no more than tagResourcesBatchSize. A batch
+// that is throttled by EC2 is retried with increasing delays; any other
+// error stops the tagging, leaving the remaining batches untagged.
+func tagResourcesInBatches(client resourceTagger, tags map[string]string, resourceIds []string) error {
+	ec2Tags := make([]ec2.Tag, 0, len(tags))
+	for k, v := range tags {
+		ec2Tags = append(ec2Tags, ec2.Tag{k, v})
+	}
+	for start := 0; start < len(resourceIds); start += tagResourcesBatchSize {
+		end := start + tagResourcesBatchSize
+		if end > len(resourceIds) {
+			end = len(resourceIds)
+		}
+		batch := resourceIds[start:end]
+		args := tagResourcesRetry
+		args.Func = func() error {
+			_, err := client.CreateTags(batch, ec2Tags)
+			return err
+		}
+		args.IsFatalError = func(err error) bool {
+			return !isThrottlingError(err)
+		}
+		args.NotifyFunc = func(err error, attempt int) {
+			logger.Debugf("tagging %d resources throttled, attempt %d: %v", len(batch), attempt, err)
+		}
+		if err := retry.Call(args); err != nil {
+			if retry.IsAttemptsExceeded(err) {
+				err = retry.LastError(err)
+			}
+			return errors.Annotatef(err, "tagged %d of %d resources", start, len(resourceIds))
+		}
+		logger.Debugf("tagged %d of %d resources", end, len(resourceIds))
+	}
+	return nil
+}
+
+// isThrottlingError reports whether the given error is EC2 refusing a
+// request because the rate of requests is too high.
+func isThrottlingError(err error) bool {
+	switch ec2ErrCode(err) {
+	case "RequestLimitExceeded", "Throttling":
+		return true
+	}
+	return false
 }
 
 // AllInstances is part of the environs.InstanceBroker interface.
@@ -1159,8 +1816,10 @@ func (e *environ) AllInstancesByState(states ...string) ([]instance.Instance, er
 func (e *environ) ControllerInstances(controllerUUID string) ([]instance.Id, error) {
 	filter := ec2.NewFilter()
 	filter.Add("instance-state-name", aliveInstanceStates...)
-	filter.Add(fmt.Sprintf("tag:%s", tags.JujuIsController), "true")
-	e.addControllerFilter(filter, controllerUUID)
+	addTagFilters(filter, map[string]string{
+		tags.JujuIsController: "true",
+		tags.JujuController:   controllerUUID,
+	})
 	ids, err := e.allInstanceIDs(filter)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -1246,8 +1905,11 @@ func (e *environ) destroyControllerManagedEnvirons(controllerUUID string) error
 		return errors.Annotate(err, "terminating instances")
 	}
 
-	// Delete all volumes managed by the controller. (No need to delete root disks manually.)
-	volIds, err := e.allControllerManagedVolumes(controllerUUID, false)
+	// Delete all volumes managed by the controller, other than those
+	// tagged for preservation. (No need to delete root disks manually.)
+	filter := ec2.NewFilter()
+	e.addControllerFilter(filter, controllerUUID)
+	volIds, err := listDestroyableVolumes(e.ec2, filter)
 	if err != nil {
 		return errors.Annotate(err, "listing volumes")
 	}
@@ -1275,12 +1937,6 @@ func (e *environ) destroyControllerManagedEnvirons(controllerUUID string) error
 	return nil
 }
 
-func (e *environ) allControllerManagedVolumes(controllerUUID string, includeRootDisks bool) ([]string, error) {
-	filter := ec2.NewFilter()
-	e.addControllerFilter(filter, controllerUUID)
-	return listVolumes(e.ec2, filter, includeRootDisks)
-}
-
 func (e *environ) allModelVolumes(includeRootDisks bool) ([]string, error) {
 	filter := ec2.NewFilter()
 	e.addModelFilter(filter)
@@ -1295,6 +1951,13 @@ func rulesToIPPerms(rules []network.IngressRule) []ec2.IPPerm {
 			FromPort: r.FromPort,
 			ToPort:   r.ToPort,
 		}
+		if number, ok := network.IPProtocolNumber(r.Protocol); ok {
+			// EC2 identifies protocols other than tcp, udp and
+			// icmp by number, and ignores their ports.
+			ipPerms[i].Protocol = strconv.Itoa(number)
+			ipPerms[i].FromPort = -1
+			ipPerms[i].ToPort = -1
+		}
 		if len(r.SourceCIDRs) == 0 {
 			ipPerms[i].SourceIPs = []string{defaultRouteCIDRBlock}
 		} else {
@@ -1314,10 +1977,15 @@ func (e *environ) openPortsInGroup(name string, rules []network.IngressRule) err
 	if err != nil {
 		return err
 	}
-	ipPerms := rulesToIPPerms(rules)
-	_, err = e.ec2.AuthorizeSecurityGroup(g, ipPerms)
+	return e.authorizeIngress(g, rulesToIPPerms(rules))
+}
+
+// authorizeIngress grants the permissions in the security group,
+// ignoring those it already grants.
+func (e *environ) authorizeIngress(g ec2.SecurityGroup, ipPerms []ec2.IPPerm) error {
+	_, err := e.ec2.AuthorizeSecurityGroup(g, ipPerms)
 	if err != nil && ec2ErrCode(err) == "InvalidPermission.Duplicate" {
-		if len(rules) == 1 {
+		if len(ipPerms) == 1 {
 			return nil
 		}
 		// If there's more than one port and we get a duplicate error,
@@ -1362,11 +2030,7 @@ func (e *environ) ingressRulesInGroup(name string) (rules []network.IngressRule,
 		return nil, err
 	}
 	for _, p := range group.IPPerms {
-		ips := p.SourceIPs
-		if len(ips) == 0 {
-			ips = []string{defaultRouteCIDRBlock}
-		}
-		rule, err := network.NewIngressRule(p.Protocol, p.FromPort, p.ToPort, ips...)
+		rule, err := ipPermToIngressRule(p)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -1376,6 +2040,23 @@ func (e *environ) ingressRulesInGroup(name string) (rules []network.IngressRule,
 	return rules, nil
 }
 
+// ipPermToIngressRule returns the ingress rule for the given security
+// group permission. Protocols without ports, which EC2 identifies by
+// number, are given by name.
+func ipPermToIngressRule(p ec2.IPPerm) (network.IngressRule, error) {
+	ips := p.SourceIPs
+	if len(ips) == 0 {
+		ips = []string{defaultRouteCIDRBlock}
+	}
+	protocol, fromPort, toPort := ec2ProtocolName(p.Protocol), p.FromPort, p.ToPort
+	if number, err := strconv.Atoi(protocol); err == nil {
+		if name, ok := network.IPProtocolName(number); ok {
+			protocol, fromPort, toPort = name, 0, 0
+		}
+	}
+	return network.NewIngressRule(protocol, fromPort, toPort, ips...)
+}
+
 func (e *environ) OpenPorts(rules []network.IngressRule) error {
 	if e.Config().FirewallMode() != config.FwGlobal {
 		return errors.Errorf("invalid firewall mode %q for opening ports on model", e.Config().FirewallMode())
@@ -1482,6 +2163,57 @@ func (e *environ) cleanEnvironmentSecurityGroups() error {
 	return nil
 }
 
+// cleanOrphanedSecurityGroups deletes the security groups left behind for
+// the model by an earlier bootstrap that failed part way, so that a retry
+// starts afresh. If the model still has instances, its groups are in use,
+// so they are left to be reused.
+func (e *environ) cleanOrphanedSecurityGroups(ctx environs.BootstrapContext) error {
+	filter := ec2.NewFilter()
+	e.addModelFilter(filter)
+	resp, err := e.ec2.SecurityGroups(nil, filter)
+	if err != nil {
+		return errors.Annotate(err, "listing security groups")
+	}
+	groups := make([]ec2.SecurityGroup, 0, len(resp.Groups)+1)
+	found := set.NewStrings()
+	for _, info := range resp.Groups {
+		groups = append(groups, info.SecurityGroup)
+		found.Add(info.Id)
+	}
+	// The model's Juju group is created first, so it is left untagged
+	// if bootstrap fails before tagging it.
+	jujuGroup, err := e.groupByName(e.jujuGroupName())
+	if err != nil && !isNotFoundError(err) {
+		return errors.Trace(err)
+	} else if err == nil && !found.Contains(jujuGroup.Id) {
+		groups = append(groups, jujuGroup)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	insts, err := e.AllInstances()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(insts) > 0 {
+		ctx.Infof("Reusing the security groups of model %q, which still has %d instance(s)", e.uuid(), len(insts))
+		return nil
+	}
+	// Machine groups may refer to the Juju group, so they are deleted
+	// before it.
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name > groups[j].Name
+	})
+	for _, g := range groups {
+		ctx.Infof("Deleting security group %q (%s) left behind by an earlier failed bootstrap", g.Name, g.Id)
+		if err := deleteSecurityGroupInsistently(e.ec2, g, clock.WallClock); err != nil {
+			return errors.Annotate(err, "cleaning up after an earlier failed bootstrap")
+		}
+	}
+	return nil
+}
+
 func (e *environ) terminateInstances(ids []instance.Id) error {
 	if len(ids) == 0 {
 		return nil
@@ -1493,6 +2225,10 @@ func (e *environ) terminateInstances(ids []instance.Id) error {
 		e.deleteSecurityGroupsForInstances(ids)
 	}()
 
+	if err := e.deregisterTargets(ids); err != nil {
+		logger.Warningf("cannot deregister instances %v from target group: %v", ids, err)
+	}
+
 	// TODO (anastasiamac 2016-04-7) instance termination would benefit
 	// from retry with exponential delay just like security groups
 	// in defer. Bug#1567179.
@@ -1611,11 +2347,30 @@ var deleteSecurityGroupInsistently = func(inst SecurityGroupCleaner, group ec2.S
 }
 
 func (e *environ) addModelFilter(f *ec2.Filter) {
-	f.Add(fmt.Sprintf("tag:%s", tags.JujuModel), e.uuid())
+	addTagFilters(f, map[string]string{tags.JujuModel: e.uuid()})
 }
 
 func (e *environ) addControllerFilter(f *ec2.Filter, controllerUUID string) {
-	f.Add(fmt.Sprintf("tag:%s", tags.JujuController), controllerUUID)
+	addTagFilters(f, map[string]string{tags.JujuController: controllerUUID})
+}
+
+// addTagFilters adds a filter on each of the given tags, so that only
+// resources carrying all of them are returned. EC2 combines filters on
+// different keys with AND, so combining the model, controller and any
+// other known tags narrows the results server-side, rather than listing
+// every tagged resource in a shared account. Tags with empty values are
+// ignored.
+func addTagFilters(f *ec2.Filter, tagValues map[string]string) {
+	names := make([]string, 0, len(tagValues))
+	for name, value := range tagValues {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f.Add("tag:"+name, tagValues[name])
+	}
 }
 
 func (e *environ) uuid() string {
@@ -1641,10 +2396,10 @@ func (e *environ) jujuGroupName() string {
 // other instances that might be running on the same EC2 account.  In
 // addition, a specific machine security group is created for each
 // machine, so that its firewall rules can be configured per machine.
-func (e *environ) setUpGroups(controllerUUID, machineId string, apiPort int) ([]ec2.SecurityGroup, error) {
+func (e *environ) setUpGroups(resourceTags map[string]string, machineId string, apiPort int) ([]ec2.SecurityGroup, error) {
 
 	// Ensure there's a global group for Juju-related traffic.
-	jujuGroup, err := e.ensureGroup(controllerUUID, e.jujuGroupName(),
+	jujuGroup, err := e.ensureGroup(resourceTags, e.jujuGroupName(),
 		[]ec2.IPPerm{{
 			Protocol:  "tcp",
 			FromPort:  22,
@@ -1676,9 +2431,9 @@ func (e *environ) setUpGroups(controllerUUID, machineId string, apiPort int) ([]
 	var machineGroup ec2.SecurityGroup
 	switch e.Config().FirewallMode() {
 	case config.FwInstance:
-		machineGroup, err = e.ensureGroup(controllerUUID, e.machineGroupName(machineId), nil)
+		machineGroup, err = e.ensureGroup(resourceTags, e.machineGroupName(machineId), nil)
 	case config.FwGlobal:
-		machineGroup, err = e.ensureGroup(controllerUUID, e.globalGroupName(), nil)
+		machineGroup, err = e.ensureGroup(resourceTags, e.globalGroupName(), nil)
 	}
 	if err != nil {
 		return nil, err
@@ -1708,12 +2463,24 @@ func (e *environ) securityGroupsByNameOrID(groupName string) (*ec2.SecurityGroup
 	return e.ec2.SecurityGroups(groups, nil)
 }
 
+// securityGroupPermissions are the IAM permissions needed by juju to
+// manage the security groups of a model.
+var securityGroupPermissions = []string{
+	"ec2:CreateSecurityGroup",
+	"ec2:DeleteSecurityGroup",
+	"ec2:DescribeSecurityGroups",
+	"ec2:AuthorizeSecurityGroupIngress",
+	"ec2:RevokeSecurityGroupIngress",
+	"ec2:CreateTags",
+}
+
 // ensureGroup returns the security group with name and perms.
-// If a group with name does not exist, one will be created.
+// If a group with name does not exist, one will be created, and
+// tagged with resourceTags.
 // If it exists, its permissions are set to perms.
 // Any entries in perms without SourceIPs will be granted for
 // the named group only.
-func (e *environ) ensureGroup(controllerUUID, name string, perms []ec2.IPPerm) (g ec2.SecurityGroup, err error) {
+func (e *environ) ensureGroup(resourceTags map[string]string, name string, perms []ec2.IPPerm) (g ec2.SecurityGroup, err error) {
 	// Specify explicit VPC ID if needed (not for default VPC or EC2-classic).
 	chosenVPCID := e.ecfg().vpcID()
 	inVPCLogSuffix := fmt.Sprintf(" (in VPC %q)", chosenVPCID)
@@ -1723,6 +2490,14 @@ func (e *environ) ensureGroup(controllerUUID, name string, perms []ec2.IPPerm) (
 	}
 
 	resp, err := e.ec2.CreateSecurityGroup(chosenVPCID, name, "juju group")
+	if ec2ErrCode(err) == "UnauthorizedOperation" {
+		err = errors.Annotatef(err,
+			"creating security group %q%s: the credential is not authorized to manage security groups; "+
+				"grant it the IAM permissions %s",
+			name, inVPCLogSuffix, strings.Join(securityGroupPermissions, ", "),
+		)
+		return zeroGroup, err
+	}
 	if err != nil && ec2ErrCode(err) != "InvalidGroup.Duplicate" {
 		err = errors.Annotatef(err, "creating security group %q%s", name, inVPCLogSuffix)
 		return zeroGroup, err
@@ -1732,13 +2507,7 @@ func (e *environ) ensureGroup(controllerUUID, name string, perms []ec2.IPPerm) (
 	if err == nil {
 		g = resp.SecurityGroup
 		// Tag the created group with the model and controller UUIDs.
-		cfg := e.Config()
-		tags := tags.ResourceTags(
-			names.NewModelTag(cfg.UUID()),
-			names.NewControllerTag(controllerUUID),
-			cfg,
-		)
-		if err := tagResources(e.ec2, tags, g.Id); err != nil {
+		if err := tagResources(e.ec2, resourceTags, g.Id); err != nil {
 			return g, errors.Annotate(err, "tagging security group")
 		}
 		logger.Debugf("created security group %q with ID %q%s", name, g.Id, inVPCLogSuffix)
@@ -1803,26 +2572,68 @@ type permKey struct {
 
 type permSet map[permKey]bool
 
+// ec2ProtocolNames holds the names of the protocols that EC2 may report
+// by number, although they are authorized by name.
+var ec2ProtocolNames = map[string]string{
+	"1":  "icmp",
+	"6":  "tcp",
+	"17": "udp",
+}
+
+// ec2ProtocolName returns the name of the given protocol as it is
+// authorized, whether EC2 reports it by name or by number.
+func ec2ProtocolName(protocol string) string {
+	protocol = strings.ToLower(protocol)
+	if name, ok := ec2ProtocolNames[protocol]; ok {
+		return name
+	}
+	return protocol
+}
+
+// newPermKey returns the key for the given permission, without its
+// source. EC2 ignores the ports of rules for protocols other than tcp,
+// udp and icmp, such as esp or all traffic ("-1"), and may report them
+// as absent, so they are always keyed as -1. For icmp, the ports are
+// the ICMP type and code, where -1 stands for any, and are kept.
+func newPermKey(p ec2.IPPerm) permKey {
+	k := permKey{
+		protocol: ec2ProtocolName(p.Protocol),
+		fromPort: p.FromPort,
+		toPort:   p.ToPort,
+	}
+	switch k.protocol {
+	case "tcp", "udp", "icmp":
+	default:
+		k.fromPort, k.toPort = -1, -1
+	}
+	return k
+}
+
 // newPermSetForGroup returns a set of all the permissions in the
-// given slice of IPPerms. It ignores the name and owner
-// id in source groups, and any entry with no source ips will
+// given slice of IPPerms. It ignores the name and owner id in
+// source groups, and any entry with no source ips or groups will
 // be granted for the given group only.
 func newPermSetForGroup(ps []ec2.IPPerm, group ec2.SecurityGroup) permSet {
 	m := make(permSet)
 	for _, p := range ps {
-		k := permKey{
-			protocol: p.Protocol,
-			fromPort: p.FromPort,
-			toPort:   p.ToPort,
-		}
-		if len(p.SourceIPs) > 0 {
-			for _, ip := range p.SourceIPs {
-				k.ipAddr = ip
-				m[k] = true
-			}
-		} else {
+		k := newPermKey(p)
+		if len(p.SourceIPs) == 0 && len(p.SourceGroups) == 0 {
 			k.groupId = group.Id
 			m[k] = true
+			continue
+		}
+		for _, ip := range p.SourceIPs {
+			k.ipAddr = ip
+			m[k] = true
+		}
+		k.ipAddr = ""
+		for _, g := range p.SourceGroups {
+			k.groupId = g.Id
+			if k.groupId == "" {
+				// EC2-Classic may identify source groups by name only.
+				k.groupId = group.Id
+			}
+			m[k] = true
 		}
 	}
 	return m
@@ -1943,6 +2754,328 @@ func (e *environ) supportedInstanceTypes() ([]instances.InstanceType, error) {
 	return supportedInstanceTypes, nil
 }
 
+// instanceTypesWithEBSBaselineBandwidth returns the subset of the given
+// instance types that can be launched EBS-optimized with at least the
+// specified dedicated EBS bandwidth, in Mbps.
+// fallbackInstanceType returns the instance type to try, according to
+// the given instance-type-fallback ladders, when there is insufficient
+// capacity for the named instance type. The fallback must be one of the
+// given instance types, which are those suitable for the instance, and
+// support the image's architecture; otherwise it is skipped in favour of
+// the next type in the ladder.
+func fallbackInstanceType(instanceTypes []instances.InstanceType, fallback map[string]string, name, arch string) (instances.InstanceType, bool) {
+	byName := make(map[string]instances.InstanceType)
+	for _, instanceType := range instanceTypes {
+		byName[instanceType.Name] = instanceType
+	}
+	for next, ok := fallback[name]; ok; next, ok = fallback[next] {
+		instanceType, found := byName[next]
+		if !found {
+			continue
+		}
+		for _, a := range instanceType.Arches {
+			if a == arch {
+				return instanceType, true
+			}
+		}
+	}
+	return instances.InstanceType{}, false
+}
+
+// precheckMaxInstanceSize returns an error if max-instance-size-action
+// is "reject" and the given constraints ask for more cores or memory
+// than max-instance-size allows, as no instance type would be accepted.
+func (e *environ) precheckMaxInstanceSize(cons constraints.Value) error {
+	ecfg := e.ecfg()
+	if ecfg.maxInstanceSizeAction() != maxInstanceSizeReject {
+		return nil
+	}
+	maxInstanceSize := ecfg.maxInstanceSize()
+	if cons.HasCpuCores() && maxInstanceSize.CpuCores != nil && *cons.CpuCores > *maxInstanceSize.CpuCores {
+		return errors.Errorf("constraint cores=%d exceeds max-instance-size %q", *cons.CpuCores, maxInstanceSize)
+	}
+	if cons.Mem != nil && maxInstanceSize.Mem != nil && *cons.Mem > *maxInstanceSize.Mem {
+		return errors.Errorf("constraint mem=%dM exceeds max-instance-size %q", *cons.Mem, maxInstanceSize)
+	}
+	return nil
+}
+
+// downgradeInstanceType returns the instance type to use in place of
+// the given one, which exceeds max-instance-size, or an error if
+// max-instance-size-action does not allow it to be downgraded. The
+// largest instance type within max-instance-size that can run the image
+// is used, regardless of the constraints that led to the one chosen.
+func (e *environ) downgradeInstanceType(
+	instanceTypes []instances.InstanceType,
+	chosen instances.InstanceType,
+	image instances.Image,
+	controller bool,
+) (instances.InstanceType, error) {
+	ecfg := e.ecfg()
+	maxInstanceSize := ecfg.maxInstanceSize()
+	if ecfg.maxInstanceSizeAction() != maxInstanceSizeDowngrade {
+		return instances.InstanceType{}, errors.Errorf(
+			"instance type %q (%s) exceeds max-instance-size %q",
+			chosen.Name, describeInstanceSize(chosen), maxInstanceSize,
+		)
+	}
+	if controller && !ecfg.maxInstanceSizeDowngradeControllers() {
+		return instances.InstanceType{}, errors.Errorf(
+			"instance type %q (%s) exceeds max-instance-size %q, and controller instances "+
+				"are only downgraded when max-instance-size-downgrade-controllers is true",
+			chosen.Name, describeInstanceSize(chosen), maxInstanceSize,
+		)
+	}
+	var downgrade *instances.InstanceType
+	for _, instanceType := range instanceTypesWithinSize(instanceTypes, maxInstanceSize) {
+		if instanceType.Deprecated || !instanceTypeRunsImage(instanceType, image) {
+			continue
+		}
+		if downgrade == nil || largerInstanceType(instanceType, *downgrade) {
+			instanceType := instanceType
+			downgrade = &instanceType
+		}
+	}
+	if downgrade == nil {
+		return instances.InstanceType{}, errors.Errorf(
+			"instance type %q (%s) exceeds max-instance-size %q, and no smaller instance type can run image %q",
+			chosen.Name, describeInstanceSize(chosen), maxInstanceSize, image.Id,
+		)
+	}
+	logger.Warningf(
+		"instance type %q (%s) exceeds max-instance-size %q, downgrading to %q (%s)",
+		chosen.Name, describeInstanceSize(chosen), maxInstanceSize,
+		downgrade.Name, describeInstanceSize(*downgrade),
+	)
+	return *downgrade, nil
+}
+
+// instanceTypeWithinSize reports whether the instance type has no more
+// cores or memory than the given maximum size allows.
+func instanceTypeWithinSize(instanceType instances.InstanceType, size constraints.Value) bool {
+	if size.CpuCores != nil && instanceType.CpuCores > *size.CpuCores {
+		return false
+	}
+	if size.Mem != nil && instanceType.Mem > *size.Mem {
+		return false
+	}
+	return true
+}
+
+func instanceTypesWithinSize(instanceTypes []instances.InstanceType, size constraints.Value) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if instanceTypeWithinSize(instanceType, size) {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// instanceTypeRunsImage reports whether instances of the given type can
+// run the image.
+func instanceTypeRunsImage(instanceType instances.InstanceType, image instances.Image) bool {
+	if instanceType.VirtType != nil && image.VirtType != "" && *instanceType.VirtType != image.VirtType {
+		return false
+	}
+	for _, arch := range instanceType.Arches {
+		if arch == image.Arch {
+			return true
+		}
+	}
+	return false
+}
+
+// largerInstanceType reports whether instance type a is larger than b,
+// having more memory, or as much memory and more cores. Of equally
+// large types, the cheaper is taken to be larger, so that it is chosen.
+func largerInstanceType(a, b instances.InstanceType) bool {
+	if a.Mem != b.Mem {
+		return a.Mem > b.Mem
+	}
+	if a.CpuCores != b.CpuCores {
+		return a.CpuCores > b.CpuCores
+	}
+	if a.Cost != b.Cost {
+		return a.Cost < b.Cost
+	}
+	return a.Name < b.Name
+}
+
+// describeInstanceSize returns the cores and memory of an instance type,
+// for reporting.
+func describeInstanceSize(instanceType instances.InstanceType) string {
+	return fmt.Sprintf("%d cores, %dM memory", instanceType.CpuCores, instanceType.Mem)
+}
+
+func instanceTypesWithEBSBaselineBandwidth(instanceTypes []instances.InstanceType, bandwidth int) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if checkEBSBaselineBandwidth(instanceType.Name, bandwidth) == nil {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// checkEBSBaselineBandwidth returns an error if the named instance type
+// cannot provide the specified dedicated EBS bandwidth, in Mbps.
+func checkEBSBaselineBandwidth(instanceType string, bandwidth int) error {
+	available, ok := ec2instancetypes.EBSBaselineBandwidth(instanceType)
+	if !ok {
+		return errors.NotSupportedf("EBS optimization for instance type %q", instanceType)
+	}
+	if available < bandwidth {
+		return errors.Errorf(
+			"instance type %q provides %d Mbps of EBS bandwidth, less than the %d Mbps required by ebs-baseline-bandwidth",
+			instanceType, available, bandwidth,
+		)
+	}
+	return nil
+}
+
+// instanceTypesWithInstanceStores returns the subset of the given
+// instance types that have at least the specified number of instance
+// store volumes.
+func instanceTypesWithInstanceStores(instanceTypes []instances.InstanceType, volumes int) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if checkInstanceStoreVolumes(instanceType.Name, volumes) == nil {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// checkInstanceStoreVolumes returns an error if the named instance type
+// does not have the specified number of instance store volumes.
+func checkInstanceStoreVolumes(instanceType string, volumes int) error {
+	store, ok := ec2instancetypes.InstanceStores(instanceType)
+	if !ok {
+		return errors.NotSupportedf("instance store volumes for EBS-only instance type %q", instanceType)
+	}
+	if store.Count < volumes {
+		return errors.Errorf(
+			"instance type %q has %d instance store volumes, fewer than the %d required by instance-store-volumes",
+			instanceType, store.Count, volumes,
+		)
+	}
+	return nil
+}
+
+// instanceStoreVolumes returns the number of instance store volumes to
+// map when launching an instance of the named type: the number required
+// by instance-store-volumes, or if that is zero, as many as the instance
+// type has up to defaultInstanceStoreVolumes. None are mapped for
+// EBS-only instance types.
+func instanceStoreVolumes(instanceType string, volumes int) int {
+	store, ok := ec2instancetypes.InstanceStores(instanceType)
+	if !ok {
+		return 0
+	}
+	if volumes == 0 {
+		volumes = defaultInstanceStoreVolumes
+	}
+	if store.Count < volumes {
+		return store.Count
+	}
+	return volumes
+}
+
+// checkMaxInstances returns an error if the model already has as many
+// instances as max-instances allows. Only pending and running instances
+// are counted. Instances being started concurrently are not, so the limit
+// is a guardrail rather than a strict quota.
+func (e *environ) checkMaxInstances() error {
+	max := e.ecfg().maxInstances()
+	if max == 0 {
+		return nil
+	}
+	insts, err := e.AllInstances()
+	if err != nil {
+		return errors.Annotate(err, "counting model instances")
+	}
+	if len(insts) >= max {
+		return errors.Errorf("model already has %d instances, the maximum allowed by max-instances", len(insts))
+	}
+	return nil
+}
+
+// instanceTypesWithAutoRecovery returns the subset of the given instance
+// types whose instances can be recovered automatically.
+func instanceTypesWithAutoRecovery(instanceTypes []instances.InstanceType) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if checkAutoRecovery(instanceType.Name) == nil {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// checkAutoRecovery returns an error if instances of the named type
+// cannot be recovered automatically, as required by
+// instance-auto-recovery.
+func checkAutoRecovery(instanceType string) error {
+	if !ec2instancetypes.SupportsAutoRecovery(instanceType) {
+		return errors.NotSupportedf("automatic recovery of instance type %q", instanceType)
+	}
+	return nil
+}
+
+// instanceTypesWithAccelerator returns the subset of the given instance
+// types that come with hardware accelerators of the given kind or model.
+func instanceTypesWithAccelerator(instanceTypes []instances.InstanceType, accelerator string) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if checkAccelerator(instanceType.Name, accelerator) == nil {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// checkAccelerator returns an error if the named instance type does not
+// come with hardware accelerators of the given kind or model, as
+// required by the accelerator constraint.
+func checkAccelerator(instanceType, accelerator string) error {
+	accel, ok := ec2instancetypes.Accelerators(instanceType)
+	if !ok {
+		return errors.Errorf("instance type %q has no accelerators, but accelerator %q is required", instanceType, accelerator)
+	}
+	if !accel.Matches(accelerator) {
+		return errors.Errorf(
+			"instance type %q has %s accelerators (%s), but accelerator %q is required",
+			instanceType, accel.Kind, accel.Model, accelerator,
+		)
+	}
+	return nil
+}
+
+// precheckAccelerator checks that an instance with the accelerator
+// required by the given constraints can be started: that the constrained
+// instance type, if any, has the accelerator, or otherwise that some
+// instance type in the region with the constrained architecture does.
+func (e *environ) precheckAccelerator(cons constraints.Value) error {
+	accelerator := *cons.Accelerator
+	if cons.HasInstanceType() {
+		return checkAccelerator(*cons.InstanceType, accelerator)
+	}
+	instanceTypes, err := e.supportedInstanceTypes()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, itype := range instanceTypesWithAccelerator(instanceTypes, accelerator) {
+		if archMatches(itype.Arches, cons.Arch) {
+			return nil
+		}
+	}
+	if cons.Arch == nil {
+		return errors.Errorf("no instance types in region %q have accelerator %q", e.cloud.Region, accelerator)
+	}
+	return errors.Errorf("no %s instance types in region %q have accelerator %q", *cons.Arch, e.cloud.Region, accelerator)
+}
+
 func (e *environ) hasDefaultVPC() (bool, error) {
 	e.defaultVPCMutex.Lock()
 	defer e.defaultVPCMutex.Unlock()
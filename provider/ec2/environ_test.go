@@ -23,6 +23,7 @@ var (
 	_ config.ConfigSchemaSource  = (*environProvider)(nil)
 	_ simplestreams.HasRegion    = (*environ)(nil)
 	_ instance.Distributor       = (*environ)(nil)
+	_ environs.Bastioned         = (*environ)(nil)
 )
 
 type Suite struct{}
@@ -96,7 +97,7 @@ func (*Suite) TestRootDiskBlockDeviceMapping(c *gc.C) {
 	for _, t := range rootDiskTests {
 		c.Logf("Test %s", t.name)
 		cons := constraints.Value{RootDisk: t.constraint}
-		mappings := getBlockDeviceMappings(cons, t.series, false)
+		mappings := getBlockDeviceMappings(cons, t.series, false, "", 4)
 		expected := append([]amzec2.BlockDeviceMapping{t.device}, commonInstanceStoreDisks...)
 		c.Assert(mappings, gc.DeepEquals, expected)
 	}
@@ -155,6 +156,15 @@ func (*Suite) TestPortsToIPPerms(c *gc.C) {
 			ToPort:    82,
 			SourceIPs: []string{"192.168.1.0/24", "0.0.0.0/0"},
 		}},
+	}, {
+		about: "protocol without ports",
+		rules: []network.IngressRule{network.MustNewIngressRule("esp", 0, 0, "203.0.113.0/24")},
+		expected: []amzec2.IPPerm{{
+			Protocol:  "50",
+			FromPort:  -1,
+			ToPort:    -1,
+			SourceIPs: []string{"203.0.113.0/24"},
+		}},
 	}}
 
 	for i, t := range testCases {
@@ -164,6 +174,62 @@ func (*Suite) TestPortsToIPPerms(c *gc.C) {
 	}
 }
 
+func (*Suite) TestIPPermToIngressRule(c *gc.C) {
+	rule, err := ipPermToIngressRule(amzec2.IPPerm{
+		Protocol:  "50",
+		FromPort:  -1,
+		ToPort:    -1,
+		SourceIPs: []string{"203.0.113.0/24"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rule, jc.DeepEquals, network.MustNewIngressRule("esp", 0, 0, "203.0.113.0/24"))
+
+	rule, err = ipPermToIngressRule(amzec2.IPPerm{Protocol: "tcp", FromPort: 80, ToPort: 80})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rule, jc.DeepEquals, network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"))
+
+	rule, err = ipPermToIngressRule(amzec2.IPPerm{Protocol: "1", FromPort: -1, ToPort: -1})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rule, jc.DeepEquals, network.MustNewIngressRule("icmp", -1, -1, "0.0.0.0/0"))
+}
+
+func (*Suite) TestNewPermSetForGroup(c *gc.C) {
+	group := amzec2.SecurityGroup{Id: "sg-1"}
+	perms := newPermSetForGroup([]amzec2.IPPerm{{
+		// EC2 may report icmp by number.
+		Protocol:  "1",
+		FromPort:  -1,
+		ToPort:    -1,
+		SourceIPs: []string{"10.0.0.0/8"},
+	}, {
+		// EC2 may omit the ports of protocols without them.
+		Protocol:  "50",
+		SourceIPs: []string{"203.0.113.0/24"},
+	}, {
+		Protocol:     "-1",
+		SourceGroups: []amzec2.UserSecurityGroup{{Id: "sg-default"}},
+	}, {
+		Protocol: "icmp",
+		FromPort: 8,
+		ToPort:   -1,
+	}}, group)
+	c.Assert(perms, jc.DeepEquals, permSet{
+		{protocol: "icmp", fromPort: -1, toPort: -1, ipAddr: "10.0.0.0/8"}:   true,
+		{protocol: "50", fromPort: -1, toPort: -1, ipAddr: "203.0.113.0/24"}: true,
+		{protocol: "-1", fromPort: -1, toPort: -1, groupId: "sg-default"}:    true,
+		{protocol: "icmp", fromPort: 8, toPort: -1, groupId: "sg-1"}:         true,
+	})
+
+	// Rules compare equal however they are described.
+	want := newPermSetForGroup(rulesToIPPerms([]network.IngressRule{
+		network.MustNewIngressRule("icmp", -1, -1, "10.0.0.0/8"),
+		network.MustNewIngressRule("esp", 0, 0, "203.0.113.0/24"),
+	}), group)
+	for k := range want {
+		c.Check(perms[k], jc.IsTrue, gc.Commentf("%+v", k))
+	}
+}
+
 // These Support checks are currently valid with a 'nil' environ pointer. If
 // that changes, the tests will need to be updated. (we know statically what is
 // supported.)
@@ -50,6 +50,8 @@ Juju requirements:
    attribute enabled (i.e. at least one subnet needs to be 'public').
 5. All subnets should be implicitly associated to the VPC main route
    table, rather than explicitly to per-subnet route tables.
+6. The network ACLs of the public subnets should allow inbound SSH
+   (port 22) and Juju API (port 17070) traffic.
 
 A default VPC already satisfies all of the requirements above. If you
 still want to use the VPC, try running 'juju bootstrap' again with:
@@ -166,6 +168,14 @@ type vpcAPIClient interface {
 // 5. One or more of the VPC's subnets are not associated with the main route
 //    table of the VPC.
 // 6. None of the the VPC's subnets have the MapPublicIPOnLaunch attribute set.
+// 7. The network ACL of a public subnet appears to block inbound traffic to
+//    the controller's SSH or API port, when apiClient can describe network
+//    ACLs. See checkSubnetNetworkACLs().
+//
+// When the VPC is shared into the account by another using AWS Resource Access
+// Manager, and apiClient can tell so, only the first of the above is checked,
+// along with there being subnets shared with the account: the rest of the
+// VPC's resources belong to its owner. See validateSharedVPC().
 //
 // With the vpc-id-force config setting set to true, the provider can ignore a
 // vpcNotRecommendedError. A vpcNotUsableError cannot be ignored, while
@@ -191,6 +201,15 @@ func validateVPC(apiClient vpcAPIClient, vpcID string) error {
 		return errors.Trace(err)
 	}
 
+	if sharedClient, ok := apiClient.(sharedVPCAPIClient); ok {
+		ownerID, err := sharedClient.SharedVPCOwner(vpcID)
+		if err != nil {
+			logger.Warningf("cannot tell whether VPC %q is shared with this account: %v", vpcID, err)
+		} else if ownerID != "" {
+			return validateSharedVPC(apiClient, vpc, ownerID)
+		}
+	}
+
 	subnets, err := getVPCSubnets(apiClient, vpc)
 	if err != nil {
 		return errors.Trace(err)
@@ -232,6 +251,12 @@ func validateVPC(apiClient vpcAPIClient, vpcID string) error {
 		return errors.Annotatef(err, "VPC %q main route table %q", vpcID, mainRouteTable.Id)
 	}
 
+	if aclClient, ok := apiClient.(networkACLAPIClient); ok {
+		if err := checkSubnetNetworkACLs(aclClient, vpc.Id, subnets); err != nil {
+			return errors.Annotatef(err, "VPC %q", vpcID)
+		}
+	}
+
 	logger.Infof("VPC %q is suitable for Juju controllers and expose-able workloads", vpc.Id)
 	return nil
 }
@@ -688,6 +688,147 @@ const (
 	withPublicIPOnLaunch = true
 )
 
+func (s *vpcSuite) TestValidateVPCShared(c *gc.C) {
+	s.stubAPI.PrepareValidateVPCResponses()
+	// The owner's internet gateway and route tables are not visible.
+	s.stubAPI.SetGatewaysResponse(noResults, availableState)
+	sharedAPI := &stubSharedVPCAPIClient{s.stubAPI, "123456789012"}
+
+	err := validateVPC(sharedAPI, anyVPCID)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.stubAPI.CheckCallNames(c, "VPCs", "SharedVPCOwner", "Subnets")
+	s.stubAPI.CheckCall(c, 1, "SharedVPCOwner", anyVPCID)
+}
+
+func (s *vpcSuite) TestValidateVPCSharedWithNoSubnets(c *gc.C) {
+	s.stubAPI.SetVPCsResponse(1, availableState, notDefaultVPC)
+	s.stubAPI.SetSubnetsResponse(noResults, anyZone, noPublicIPOnLaunch)
+	sharedAPI := &stubSharedVPCAPIClient{s.stubAPI, "123456789012"}
+
+	err := validateVPC(sharedAPI, anyVPCID)
+	c.Assert(err, gc.ErrorMatches, `no subnets of VPC "vpc-0", owned by account "123456789012", are shared with this account`)
+	c.Check(err, jc.Satisfies, isVPCNotUsableError)
+
+	s.stubAPI.CheckCallNames(c, "VPCs", "SharedVPCOwner", "Subnets")
+}
+
+func (s *vpcSuite) TestValidateVPCNotShared(c *gc.C) {
+	s.stubAPI.PrepareValidateVPCResponses()
+	sharedAPI := &stubSharedVPCAPIClient{s.stubAPI, ""}
+
+	err := validateVPC(sharedAPI, anyVPCID)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.stubAPI.CheckCallNames(c, "VPCs", "SharedVPCOwner", "Subnets", "InternetGateways", "RouteTables")
+}
+
+func (s *vpcSuite) TestValidateVPCSharedOwnerUnknown(c *gc.C) {
+	s.stubAPI.PrepareValidateVPCResponses()
+	s.stubAPI.SetErrors(nil, errors.New("access denied"))
+	sharedAPI := &stubSharedVPCAPIClient{s.stubAPI, "123456789012"}
+
+	// The VPC is validated as if it were owned by the account.
+	err := validateVPC(sharedAPI, anyVPCID)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.stubAPI.CheckCallNames(c, "VPCs", "SharedVPCOwner", "Subnets", "InternetGateways", "RouteTables")
+}
+
+func (s *vpcSuite) TestValidateVPCNetworkACLsAllowed(c *gc.C) {
+	s.stubAPI.PrepareValidateVPCResponses()
+	aclAPI := &stubNetworkACLAPIClient{s.stubAPI, []networkACL{
+		makeNetworkACL("acl-default", "subnet-0",
+			networkACLEntry{RuleNumber: 100, Protocol: "-1", RuleAction: "allow", CIDRBlock: "0.0.0.0/0"},
+		),
+	}}
+
+	err := validateVPC(aclAPI, anyVPCID)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.stubAPI.CheckCallNames(c, "VPCs", "Subnets", "InternetGateways", "RouteTables", "NetworkACLs")
+	s.stubAPI.CheckCall(c, 4, "NetworkACLs", "vpc-0")
+}
+
+func (s *vpcSuite) TestValidateVPCNetworkACLsBlocked(c *gc.C) {
+	s.stubAPI.PrepareValidateVPCResponses()
+	aclAPI := &stubNetworkACLAPIClient{s.stubAPI, []networkACL{
+		makeNetworkACL("acl-web", "subnet-0",
+			networkACLEntry{RuleNumber: 100, Protocol: "6", RuleAction: "allow", CIDRBlock: "0.0.0.0/0", PortRange: portRange(443, 443)},
+			networkACLEntry{RuleNumber: 110, Protocol: "6", RuleAction: "allow", CIDRBlock: "10.0.0.0/8", PortRange: portRange(22, 22)},
+		),
+	}}
+
+	// SSH is allowed from some sources, but the API port from none.
+	err := validateVPC(aclAPI, anyVPCID)
+	c.Assert(err, gc.ErrorMatches, `VPC "vpc-anything": network ACL "acl-web" of subnet "subnet-0" appears to block inbound TCP port\(s\) 17070`)
+	c.Check(err, jc.Satisfies, isVPCNotRecommendedError)
+
+	s.stubAPI.ResetCalls()
+	err = validateBootstrapVPC(aclAPI, "region", anyVPCID, false, envtesting.BootstrapContext(c))
+	c.Check(err, gc.ErrorMatches, `The given vpc-id does not meet one or more(.|\n)*appears to block inbound TCP port\(s\) 17070`)
+
+	s.stubAPI.ResetCalls()
+	err = validateBootstrapVPC(aclAPI, "region", anyVPCID, true, envtesting.BootstrapContext(c))
+	c.Check(err, jc.ErrorIsNil)
+}
+
+func (s *vpcSuite) TestValidateVPCNetworkACLsUnavailable(c *gc.C) {
+	s.stubAPI.PrepareValidateVPCResponses()
+	s.stubAPI.SetErrors(nil, nil, nil, nil, errors.New("access denied"))
+	aclAPI := &stubNetworkACLAPIClient{s.stubAPI, nil}
+
+	err := validateVPC(aclAPI, anyVPCID)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.stubAPI.CheckCallNames(c, "VPCs", "Subnets", "InternetGateways", "RouteTables", "NetworkACLs")
+	c.Check(c.GetTestLog(), jc.Contains, `cannot check the network ACLs of VPC "vpc-0": access denied`)
+}
+
+// stubNetworkACLAPIClient is a stubVPCAPIClient that also describes
+// the network ACLs of a VPC.
+type stubNetworkACLAPIClient struct {
+	*stubVPCAPIClient
+	acls []networkACL
+}
+
+// NetworkACLs implements networkACLAPIClient.
+func (s *stubNetworkACLAPIClient) NetworkACLs(vpcID string) ([]networkACL, error) {
+	s.Stub.AddCall("NetworkACLs", vpcID)
+	if err := s.Stub.NextErr(); err != nil {
+		return nil, err
+	}
+	return s.acls, nil
+}
+
+func makeNetworkACL(aclID, subnetID string, entries ...networkACLEntry) networkACL {
+	// Every network ACL denies traffic matching no other entry.
+	entries = append(entries, networkACLEntry{
+		RuleNumber: 32767, Protocol: "-1", RuleAction: "deny", CIDRBlock: "0.0.0.0/0",
+	})
+	return networkACL{
+		Id:        aclID,
+		SubnetIds: []string{subnetID},
+		Entries:   entries,
+	}
+}
+
+// stubSharedVPCAPIClient is a stubVPCAPIClient that also reports the
+// owner of a VPC shared into the account.
+type stubSharedVPCAPIClient struct {
+	*stubVPCAPIClient
+	ownerID string
+}
+
+// SharedVPCOwner implements sharedVPCAPIClient.
+func (s *stubSharedVPCAPIClient) SharedVPCOwner(vpcID string) (string, error) {
+	s.Stub.AddCall("SharedVPCOwner", vpcID)
+	if err := s.Stub.NextErr(); err != nil {
+		return "", err
+	}
+	return s.ownerID, nil
+}
+
 type stubVPCAPIClient struct {
 	*testing.Stub
 	vpcAPIClient // embedded mostly for documentation
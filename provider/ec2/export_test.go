@@ -6,6 +6,8 @@ package ec2
 import (
 	"strings"
 
+	"github.com/juju/errors"
+	"github.com/juju/utils/clock"
 	"gopkg.in/amz.v3/aws"
 	"gopkg.in/amz.v3/ec2"
 	gc "gopkg.in/check.v1"
@@ -29,6 +31,10 @@ func MachineGroupName(e environs.Environ, machineId string) string {
 	return e.(*environ).machineGroupName(machineId)
 }
 
+func SetClock(e environs.Environ, clock clock.Clock) {
+	e.(*environ).clock = clock
+}
+
 func EnvironEC2(e environs.Environ) *ec2.EC2 {
 	return e.(*environ).ec2
 }
@@ -53,7 +59,96 @@ func AllModelGroups(e environs.Environ) ([]string, error) {
 	return e.(*environ).modelSecurityGroupIDs()
 }
 
+type instanceGroupsFunc func(instId string, groupIds []string) error
+
+func (f instanceGroupsFunc) SetInstanceGroups(instId string, groupIds []string) error {
+	return f(instId, groupIds)
+}
+
+// SetInstanceGroupsFunc replaces the call that sets the security groups
+// attached to an instance, which the local server does not support.
+func SetInstanceGroupsFunc(e environs.Environ, f func(instId string, groupIds []string) error) {
+	e.(*environ).instanceGroups = instanceGroupsFunc(f)
+}
+
+// fakeDualStack is a dualStackAPI with fixed IPv6 CIDR blocks, keyed by
+// subnet ID, and network interfaces, keyed by instance ID.
+type fakeDualStack struct {
+	subnetCIDRs map[string]string
+	interfaces  map[string][]ipv6Interface
+}
+
+func (f *fakeDualStack) SubnetIPv6CIDR(subnetID string) (string, error) {
+	return f.subnetCIDRs[subnetID], nil
+}
+
+func (f *fakeDualStack) InstanceIPv6Interfaces(instId string) ([]ipv6Interface, error) {
+	return f.interfaces[instId], nil
+}
+
+// SetDualStack makes the given subnets dual-stack, with the given IPv6
+// CIDR blocks, and gives the network interfaces of the given instance
+// the given IPv6 addresses, keyed by network interface ID. The local
+// server has no support for IPv6.
+func SetDualStack(e environs.Environ, subnetCIDRs map[string]string, instId instance.Id, addresses map[string][]string) {
+	var ifaces []ipv6Interface
+	for id, addrs := range addresses {
+		ifaces = append(ifaces, ipv6Interface{Id: id, IPv6Addresses: addrs})
+	}
+	e.(*environ).dualStack = &fakeDualStack{
+		subnetCIDRs: subnetCIDRs,
+		interfaces:  map[string][]ipv6Interface{string(instId): ifaces},
+	}
+}
+
+// fakeDedicatedHosts is a dedicatedHostAPI with fixed Dedicated Hosts,
+// keyed by host ID.
+type fakeDedicatedHosts map[string]*dedicatedHost
+
+func (f fakeDedicatedHosts) DescribeHost(hostId string) (*dedicatedHost, error) {
+	host, ok := f[hostId]
+	if !ok {
+		return nil, errors.NotFoundf("dedicated host %q", hostId)
+	}
+	return host, nil
+}
+
+// SetDedicatedHost gives the environ an available Dedicated Host with
+// the given ID, in the given availability zone, for instances of the
+// given type. The local server has no support for Dedicated Hosts.
+func SetDedicatedHost(e environs.Environ, hostId, zone, instanceType string) {
+	e.(*environ).dedicatedHosts = fakeDedicatedHosts{
+		hostId: {
+			Id:               hostId,
+			State:            availableState,
+			AvailabilityZone: zone,
+			InstanceType:     instanceType,
+		},
+	}
+}
+
+// fakeInstanceTypeOfferings is an instanceTypeOfferingsAPI with fixed
+// availability zones, keyed by instance type.
+type fakeInstanceTypeOfferings struct {
+	zones map[string][]string
+	err   error
+	calls int
+}
+
+func (f *fakeInstanceTypeOfferings) InstanceTypeZones(instanceType string) ([]string, error) {
+	f.calls++
+	return f.zones[instanceType], f.err
+}
+
+// SetInstanceTypeZones makes each of the given instance types offered
+// only in the given availability zones. The local server has no support
+// for describing instance type offerings.
+func SetInstanceTypeZones(e environs.Environ, zones map[string][]string) {
+	e.(*environ).instanceTypeOfferings = &fakeInstanceTypeOfferings{zones: zones}
+}
+
 var (
+	AddTagFilters               = addTagFilters
 	EC2AvailabilityZones        = &ec2AvailabilityZones
 	AvailabilityZoneAllocations = &availabilityZoneAllocations
 	RunInstances                = &runInstances
@@ -82,8 +177,28 @@ var (
 	DestroyVolumeAttempt           = &destroyVolumeAttempt
 	DeleteSecurityGroupInsistently = &deleteSecurityGroupInsistently
 	TerminateInstancesById         = &terminateInstancesById
+	DialBastion                    = &dialBastion
+	NewResourceTagger              = &newResourceTagger
+	TagResourcesBatchSize          = &tagResourcesBatchSize
+	TagResourcesRetry              = &tagResourcesRetry
+	BootstrapLaunchRetry           = &bootstrapLaunchRetry
+	InstanceBootPollDelay          = &instanceBootPollDelay
 )
 
+// ResourceTagger is the interface used to tag resources when adopting
+// them.
+type ResourceTagger interface {
+	CreateTags(resourceIds []string, tags []ec2.Tag) (*ec2.SimpleResp, error)
+}
+
+// WrapResourceTagger returns a replacement for NewResourceTagger that
+// returns the ResourceTagger made by wrap from the EC2 client.
+func WrapResourceTagger(wrap func(*ec2.EC2) ResourceTagger) func(*ec2.EC2) resourceTagger {
+	return func(client *ec2.EC2) resourceTagger {
+		return wrap(client)
+	}
+}
+
 // FabricateInstance creates a new fictitious instance
 // given an existing instance and a new id.
 func FabricateInstance(inst instance.Instance, newId string) instance.Instance {
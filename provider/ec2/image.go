@@ -4,6 +4,11 @@
 package ec2
 
 import (
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/ec2"
+
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs/imagemetadata"
 	"github.com/juju/juju/environs/instances"
@@ -68,3 +73,76 @@ func withDefaultNonControllerConstraints(cons constraints.Value) constraints.Val
 	}
 	return cons
 }
+
+// ec2Images is a variable so it can be patched in tests.
+var ec2Images = (*ec2.EC2).Images
+
+// isCustomImage reports whether the image with the given ID was specified
+// by the user, rather than found in simplestreams. Such images do not have
+// a storage type.
+func isCustomImage(images []*imagemetadata.ImageMetadata, imageId string) bool {
+	for _, image := range images {
+		if image.Id == imageId && image.Storage == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCustomImage checks that a user-specified image is visible to the
+// account, and is available for launching. The image may be owned by another
+// AWS account; DescribeImages will only return it if it is public, or if
+// the owner has shared it with this account.
+func validateCustomImage(e *ec2.EC2, imageId string) (*ec2.Image, error) {
+	resp, err := ec2Images(e, []string{imageId}, nil)
+	if err != nil {
+		if strings.HasPrefix(ec2ErrCode(err), "InvalidAMIID.") {
+			return nil, errors.NotFoundf("image %q (it may not be shared with this account)", imageId)
+		}
+		return nil, errors.Annotatef(err, "querying image %q", imageId)
+	}
+	if len(resp.Images) == 0 {
+		return nil, errors.NotFoundf("image %q (it may not be shared with this account)", imageId)
+	}
+	image := &resp.Images[0]
+	if image.State != "available" {
+		return nil, errors.Errorf("image %q is %q", imageId, image.State)
+	}
+	return image, nil
+}
+
+// sharedImageOwner returns the ID of the account that owns the given
+// image if it is shared with this account by another, or "" if this
+// account owns it, or its owner is not reported.
+func (e *environ) sharedImageOwner(image *ec2.Image) (string, error) {
+	if image.OwnerId == "" {
+		return "", nil
+	}
+	account, err := e.vpcOwners.AccountID()
+	if err != nil {
+		return "", errors.Annotatef(err, "getting owner of image %q", image.Id)
+	}
+	if image.OwnerId == account {
+		return "", nil
+	}
+	return image.OwnerId, nil
+}
+
+// isImageLaunchPermissionError reports whether or not the error indicates
+// RunInstances failed because the account may describe the image, but has
+// not been granted permission to launch it. This happens when an image is
+// shared from another account without also sharing its snapshots, or the
+// keys used to encrypt them.
+func isImageLaunchPermissionError(err error) bool {
+	ec2err, _ := errors.Cause(err).(*ec2.Error)
+	if ec2err == nil {
+		return false
+	}
+	switch ec2err.Code {
+	case "AuthFailure":
+		return strings.Contains(ec2err.Message, "image")
+	case "InvalidAMIID.Unavailable", "InvalidSnapshot.NotFound":
+		return true
+	}
+	return false
+}
@@ -6,8 +6,10 @@ package ec2
 import (
 	"fmt"
 
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils/series"
+	"gopkg.in/amz.v3/ec2"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/juju/constraints"
@@ -277,3 +279,80 @@ func (*specSuite) TestFilterImagesMaintainsOrdering(c *gc.C) {
 	ic := &instances.InstanceConstraint{Storage: []string{"ebs"}}
 	c.Check(filterImages(input, ic), gc.DeepEquals, input)
 }
+
+func (s *specSuite) TestIsCustomImage(c *gc.C) {
+	images := []*imagemetadata.ImageMetadata{
+		{Id: "ami-00000133", Storage: "ssd"},
+		{Id: "ami-shared"},
+	}
+	c.Assert(isCustomImage(images, "ami-shared"), jc.IsTrue)
+	c.Assert(isCustomImage(images, "ami-00000133"), jc.IsFalse)
+	c.Assert(isCustomImage(images, "ami-unknown"), jc.IsFalse)
+}
+
+func (s *specSuite) TestValidateCustomImageShared(c *gc.C) {
+	s.PatchValue(&ec2Images, func(_ *ec2.EC2, ids []string, _ *ec2.Filter) (*ec2.ImagesResp, error) {
+		c.Assert(ids, jc.DeepEquals, []string{"ami-shared"})
+		return &ec2.ImagesResp{Images: []ec2.Image{{
+			Id:      "ami-shared",
+			State:   "available",
+			OwnerId: "123456789012",
+		}}}, nil
+	})
+	image, err := validateCustomImage(nil, "ami-shared")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(image.OwnerId, gc.Equals, "123456789012")
+}
+
+func (s *specSuite) TestValidateCustomImageNotShared(c *gc.C) {
+	s.PatchValue(&ec2Images, func(*ec2.EC2, []string, *ec2.Filter) (*ec2.ImagesResp, error) {
+		return nil, &ec2.Error{Code: "InvalidAMIID.NotFound"}
+	})
+	_, err := validateCustomImage(nil, "ami-shared")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `image "ami-shared" \(it may not be shared with this account\) not found`)
+}
+
+func (s *specSuite) TestValidateCustomImageUnavailable(c *gc.C) {
+	s.PatchValue(&ec2Images, func(*ec2.EC2, []string, *ec2.Filter) (*ec2.ImagesResp, error) {
+		return &ec2.ImagesResp{Images: []ec2.Image{{Id: "ami-shared", State: "pending"}}}, nil
+	})
+	_, err := validateCustomImage(nil, "ami-shared")
+	c.Assert(err, gc.ErrorMatches, `image "ami-shared" is "pending"`)
+}
+
+func (s *specSuite) TestSharedImageOwner(c *gc.C) {
+	owners := &fakeVPCOwnerAPI{accountID: "123456789012"}
+	env := &environ{vpcOwners: owners}
+
+	owner, err := env.sharedImageOwner(&ec2.Image{Id: "ami-shared", OwnerId: "210987654321"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "210987654321")
+
+	// Images owned by this account are not shared.
+	owner, err = env.sharedImageOwner(&ec2.Image{Id: "ami-own", OwnerId: "123456789012"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "")
+
+	// The account is not looked up if the owner is not reported.
+	owner, err = env.sharedImageOwner(&ec2.Image{Id: "ami-unknown"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "")
+	c.Assert(owners.calls, gc.Equals, 2)
+}
+
+func (s *specSuite) TestSharedImageOwnerError(c *gc.C) {
+	env := &environ{vpcOwners: &fakeVPCOwnerAPI{err: errors.New("access denied")}}
+	_, err := env.sharedImageOwner(&ec2.Image{Id: "ami-shared", OwnerId: "210987654321"})
+	c.Assert(err, gc.ErrorMatches, `getting owner of image "ami-shared": access denied`)
+}
+
+func (*specSuite) TestIsImageLaunchPermissionError(c *gc.C) {
+	c.Assert(isImageLaunchPermissionError(&ec2.Error{
+		Code:    "AuthFailure",
+		Message: "Not authorized for images: [ami-shared]",
+	}), jc.IsTrue)
+	c.Assert(isImageLaunchPermissionError(&ec2.Error{Code: "InvalidAMIID.Unavailable"}), jc.IsTrue)
+	c.Assert(isImageLaunchPermissionError(&ec2.Error{Code: "AuthFailure"}), jc.IsFalse)
+	c.Assert(isImageLaunchPermissionError(errors.New("boom")), jc.IsFalse)
+}
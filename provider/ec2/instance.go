@@ -5,7 +5,9 @@ package ec2
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/juju/errors"
 	"gopkg.in/amz.v3/ec2"
 
 	"github.com/juju/juju/environs/config"
@@ -18,6 +20,11 @@ type ec2Instance struct {
 	e *environ
 
 	*ec2.Instance
+
+	// spotRequest holds the spot instance request that launched the
+	// instance, if it is a spot instance and spot-interruption-notices
+	// is enabled.
+	spotRequest *spotInstanceRequest
 }
 
 func (inst *ec2Instance) String() string {
@@ -38,18 +45,34 @@ func (inst *ec2Instance) Status() instance.InstanceStatus {
 		jujuStatus = status.Pending
 	case "running":
 		jujuStatus = status.Running
-	case "shutting-down", "terminated", "stopping", "stopped":
-		jujuStatus = status.Empty
+	case "stopping", "stopped":
+		jujuStatus = status.Stopped
+	case "shutting-down", "terminated":
+		jujuStatus = status.Terminated
 	default:
 		jujuStatus = status.Empty
 	}
+	message := inst.State.Name
+	if jujuStatus == status.Running && inst.spotRequest != nil && inst.spotRequest.interrupted() {
+		jujuStatus = status.Interrupting
+		message = fmt.Sprintf(
+			"%s: spot interruption notice %s since %s",
+			message, inst.spotRequest.StatusCode, inst.spotRequest.UpdateTime.UTC().Format(time.RFC3339),
+		)
+	}
 	return instance.InstanceStatus{
 		Status:  jujuStatus,
-		Message: inst.State.Name,
+		Message: message,
 	}
 
 }
 
+// Interruptible is part of the instance.Interruptible interface. Spot
+// instances may be interrupted by AWS, with two minutes' notice.
+func (inst *ec2Instance) Interruptible() bool {
+	return inst.spotRequest != nil
+}
+
 // Addresses implements network.Addresses() returning generic address
 // details for the instance, and requerying the ec2 api if required.
 func (inst *ec2Instance) Addresses() ([]network.Address, error) {
@@ -71,6 +94,20 @@ func (inst *ec2Instance) Addresses() ([]network.Address, error) {
 			addresses = append(addresses, address)
 		}
 	}
+	// Only instances in a VPC have network interfaces, and so may have
+	// IPv6 addresses from dual-stack subnets.
+	if len(inst.NetworkInterfaces) == 0 {
+		return addresses, nil
+	}
+	ifaces, err := inst.e.dualStack.InstanceIPv6Interfaces(inst.InstanceId)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, iface := range ifaces {
+		for _, addr := range iface.IPv6Addresses {
+			addresses = append(addresses, network.NewAddress(addr))
+		}
+	}
 	return addresses, nil
 }
 
@@ -79,11 +116,17 @@ func (inst *ec2Instance) OpenPorts(machineId string, rules []network.IngressRule
 		return fmt.Errorf("invalid firewall mode %q for opening ports on instance",
 			inst.e.Config().FirewallMode())
 	}
-	name := inst.e.machineGroupName(machineId)
-	if err := inst.e.openPortsInGroup(name, rules); err != nil {
+	if err := inst.e.openMachinePorts(inst.Instance, machineId, rules); err != nil {
 		return err
 	}
-	logger.Infof("opened ports in security group %s: %v", name, rules)
+	logger.Infof("opened ports in security groups of machine %s: %v", machineId, rules)
+
+	// Exposed instances are registered with the target group, if any.
+	if arn := inst.e.ecfg().targetGroupARN(); arn != "" && hasPublicIngress(rules) {
+		if err := inst.e.targetGroups.RegisterTargets(arn, []string{inst.InstanceId}); err != nil {
+			return errors.Annotatef(err, "registering %v with target group %q", inst.Id(), arn)
+		}
+	}
 	return nil
 }
 
@@ -92,11 +135,24 @@ func (inst *ec2Instance) ClosePorts(machineId string, ports []network.IngressRul
 		return fmt.Errorf("invalid firewall mode %q for closing ports on instance",
 			inst.e.Config().FirewallMode())
 	}
-	name := inst.e.machineGroupName(machineId)
-	if err := inst.e.closePortsInGroup(name, ports); err != nil {
+	if err := inst.e.closeMachinePorts(inst.Instance, machineId, ports); err != nil {
 		return err
 	}
-	logger.Infof("closed ports in security group %s: %v", name, ports)
+	logger.Infof("closed ports in security groups of machine %s: %v", machineId, ports)
+
+	// Once the instance is no longer exposed, remove it from the
+	// target group, if any.
+	if arn := inst.e.ecfg().targetGroupARN(); arn != "" && hasPublicIngress(ports) {
+		remaining, err := inst.e.machineIngressRules(machineId)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if !hasPublicIngress(remaining) {
+			if err := inst.e.deregisterTargets([]instance.Id{inst.Id()}); err != nil {
+				return errors.Annotatef(err, "deregistering %v from target group %q", inst.Id(), arn)
+			}
+		}
+	}
 	return nil
 }
 
@@ -105,10 +161,5 @@ func (inst *ec2Instance) IngressRules(machineId string) ([]network.IngressRule,
 		return nil, fmt.Errorf("invalid firewall mode %q for retrieving ingress rules from instance",
 			inst.e.Config().FirewallMode())
 	}
-	name := inst.e.machineGroupName(machineId)
-	ranges, err := inst.e.ingressRulesInGroup(name)
-	if err != nil {
-		return nil, err
-	}
-	return ranges, nil
+	return inst.e.machineIngressRules(machineId)
 }
@@ -19,6 +19,7 @@ func (e *environ) InstanceTypes(c constraints.Value) (instances.InstanceTypesWit
 	if err != nil {
 		return instances.InstanceTypesWithCostMetadata{}, errors.Trace(err)
 	}
+	iTypes = e.withCurrentPrices(iTypes)
 	iTypes, err = instances.MatchingInstanceTypes(iTypes, "", c)
 	if err != nil {
 		return instances.InstanceTypesWithCostMetadata{}, errors.Trace(err)
@@ -29,3 +30,29 @@ func (e *environ) InstanceTypes(c constraints.Value) (instances.InstanceTypesWit
 		CostDivisor:   1000,
 		CostCurrency:  "USD"}, nil
 }
+
+// withCurrentPrices returns a copy of the given instance types, with the
+// cost of each replaced by its current On-Demand price in the model's
+// region, where that is known. If the prices cannot be queried, the
+// instance types are returned unchanged, with the costs recorded when
+// Juju was built.
+func (e *environ) withCurrentPrices(instanceTypes []instances.InstanceType) []instances.InstanceType {
+	names := make([]string, len(instanceTypes))
+	for i, instanceType := range instanceTypes {
+		names[i] = instanceType.Name
+	}
+	prices, err := e.instanceTypePrices(names)
+	if err != nil {
+		logger.Warningf("using recorded instance type costs: %v", err)
+		return instanceTypes
+	}
+	result := make([]instances.InstanceType, len(instanceTypes))
+	for i, instanceType := range instanceTypes {
+		if price, ok := prices[instanceType.Name]; ok {
+			// Costs are recorded in thousandths of a US dollar.
+			instanceType.Cost = uint64(price*1000 + 0.5)
+		}
+		result[i] = instanceType
+	}
+	return result
+}
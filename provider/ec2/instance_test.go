@@ -0,0 +1,42 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	amzec2 "gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/status"
+	"github.com/juju/juju/testing"
+)
+
+type instanceSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&instanceSuite{})
+
+func (s *instanceSuite) TestStatus(c *gc.C) {
+	for i, test := range []struct {
+		state  string
+		status status.Status
+	}{
+		{"pending", status.Pending},
+		{"running", status.Running},
+		{"stopping", status.Stopped},
+		{"stopped", status.Stopped},
+		{"shutting-down", status.Terminated},
+		{"terminated", status.Terminated},
+		{"rebooting", status.Empty},
+	} {
+		c.Logf("test %d: %s", i, test.state)
+		inst := &ec2Instance{Instance: &amzec2.Instance{
+			InstanceId: "i-1",
+			State:      amzec2.InstanceState{Name: test.state},
+		}}
+		instStatus := inst.Status()
+		c.Check(instStatus.Status, gc.Equals, test.status)
+		c.Check(instStatus.Message, gc.Equals, test.state)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/instance"
+)
+
+var (
+	_ environs.InstanceTagger   = (*environ)(nil)
+	_ environs.InstanceUntagger = (*environ)(nil)
+)
+
+// instanceTagsAPI is the subset of the EC2 API used to remove tags from
+// resources, which the EC2 client library has no support for.
+type instanceTagsAPI interface {
+	// DeleteTags removes the tags with the given keys from the given
+	// resources.
+	DeleteTags(resourceIds []string, keys []string) error
+}
+
+// newInstanceTagsAPI returns an instanceTagsAPI for the given cloud,
+// whose request signer is wrapped with wrapSigner. It is a variable so
+// it can be replaced in tests.
+var newInstanceTagsAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) instanceTagsAPI {
+	return &instanceTagsClient{newEC2QueryClient(cloud, wrapSigner)}
+}
+
+// instanceTagsClient is a minimal client for the EC2 query API, used to
+// remove tags from resources.
+type instanceTagsClient struct {
+	*privateDNSClient
+}
+
+// DeleteTags is part of the instanceTagsAPI interface.
+func (c *instanceTagsClient) DeleteTags(resourceIds []string, keys []string) error {
+	params := make(url.Values)
+	for i, id := range resourceIds {
+		params.Set(fmt.Sprintf("ResourceId.%d", i+1), id)
+	}
+	for i, key := range keys {
+		params.Set(fmt.Sprintf("Tag.%d.Key", i+1), key)
+	}
+	var resp struct {
+		Return bool `xml:"return"`
+	}
+	if err := c.query("DeleteTags", params, &resp); err != nil {
+		return errors.Annotatef(err, "deleting tags %q of %v", keys, resourceIds)
+	}
+	return nil
+}
+
+// TagInstance is part of the environs.InstanceTagger interface.
+func (e *environ) TagInstance(id instance.Id, tags map[string]string) error {
+	if err := tagResources(e.ec2, tags, string(id)); err != nil {
+		return errors.Annotatef(err, "tagging instance %q", id)
+	}
+	return nil
+}
+
+// UntagInstance is part of the environs.InstanceUntagger interface.
+func (e *environ) UntagInstance(id instance.Id, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	return errors.Trace(e.instanceTags.DeleteTags([]string{string(id)}, names))
+}
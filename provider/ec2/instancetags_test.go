@@ -0,0 +1,66 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type instanceTagsSuite struct {
+	testing.BaseSuite
+
+	server   *httptest.Server
+	requests []url.Values
+	status   int
+	response string
+	client   *instanceTagsClient
+}
+
+var _ = gc.Suite(&instanceTagsSuite{})
+
+func (s *instanceTagsSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.response = `<DeleteTagsResponse><return>true</return></DeleteTagsResponse>`
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.response)
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &instanceTagsClient{&privateDNSClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  privateDNSAPIVersion,
+	}}}
+}
+
+func (s *instanceTagsSuite) TestDeleteTags(c *gc.C) {
+	err := s.client.DeleteTags([]string{"i-1234"}, []string{"juju-open-ports", "other"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DeleteTags")
+	c.Assert(s.requests[0].Get("ResourceId.1"), gc.Equals, "i-1234")
+	c.Assert(s.requests[0].Get("Tag.1.Key"), gc.Equals, "juju-open-ports")
+	c.Assert(s.requests[0].Get("Tag.2.Key"), gc.Equals, "other")
+	c.Assert(s.requests[0].Get("Tag.1.Value"), gc.Equals, "")
+}
+
+func (s *instanceTagsSuite) TestDeleteTagsError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `<Response><Errors><Error><Code>InvalidInstanceID.NotFound</Code><Message>not found</Message></Error></Errors></Response>`
+	err := s.client.DeleteTags([]string{"i-1234"}, []string{"juju-open-ports"})
+	c.Assert(err, gc.ErrorMatches, `deleting tags \["juju-open-ports"\] of \[i-1234\]: .*`)
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2instancetypes
+
+import "sort"
+
+// The kinds of hardware accelerator that instance types may come with.
+const (
+	AcceleratorGPU        = "gpu"
+	AcceleratorFPGA       = "fpga"
+	AcceleratorInferentia = "inferentia"
+)
+
+// Accelerator describes the hardware accelerators that come with an
+// instance type.
+type Accelerator struct {
+	// Kind is the kind of the accelerators, such as AcceleratorGPU.
+	Kind string
+
+	// Model is the model of the accelerators, eg "nvidia-tesla-k80".
+	Model string
+
+	// Count is the number of accelerators.
+	Count int
+}
+
+// Matches reports whether the accelerators are of the given kind or
+// model.
+func (a Accelerator) Matches(name string) bool {
+	return name == a.Kind || name == a.Model
+}
+
+// accelerators holds the hardware accelerators of each instance type
+// that has any. Instance types not listed here have none.
+//
+// See http://docs.aws.amazon.com/AWSEC2/latest/UserGuide/accelerated-computing-instances.html
+var accelerators = map[string]Accelerator{
+	"cg1.4xlarge":   {AcceleratorGPU, "nvidia-tesla-m2050", 2},
+	"g2.2xlarge":    {AcceleratorGPU, "nvidia-grid-k520", 1},
+	"g2.8xlarge":    {AcceleratorGPU, "nvidia-grid-k520", 4},
+	"g3.4xlarge":    {AcceleratorGPU, "nvidia-tesla-m60", 1},
+	"g3.8xlarge":    {AcceleratorGPU, "nvidia-tesla-m60", 2},
+	"g3.16xlarge":   {AcceleratorGPU, "nvidia-tesla-m60", 4},
+	"p2.xlarge":     {AcceleratorGPU, "nvidia-tesla-k80", 1},
+	"p2.8xlarge":    {AcceleratorGPU, "nvidia-tesla-k80", 8},
+	"p2.16xlarge":   {AcceleratorGPU, "nvidia-tesla-k80", 16},
+	"p3.2xlarge":    {AcceleratorGPU, "nvidia-tesla-v100", 1},
+	"p3.8xlarge":    {AcceleratorGPU, "nvidia-tesla-v100", 4},
+	"p3.16xlarge":   {AcceleratorGPU, "nvidia-tesla-v100", 8},
+	"f1.2xlarge":    {AcceleratorFPGA, "xilinx-vu9p", 1},
+	"f1.16xlarge":   {AcceleratorFPGA, "xilinx-vu9p", 8},
+	"inf1.xlarge":   {AcceleratorInferentia, "aws-inferentia", 1},
+	"inf1.2xlarge":  {AcceleratorInferentia, "aws-inferentia", 1},
+	"inf1.6xlarge":  {AcceleratorInferentia, "aws-inferentia", 4},
+	"inf1.24xlarge": {AcceleratorInferentia, "aws-inferentia", 16},
+}
+
+// Accelerators returns the hardware accelerators of the named instance
+// type, and reports whether the instance type has any at all.
+func Accelerators(instanceType string) (Accelerator, bool) {
+	accel, ok := accelerators[instanceType]
+	return accel, ok
+}
+
+// AcceleratorNames returns the sorted kinds and models of the hardware
+// accelerators that instance types come with.
+func AcceleratorNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, accel := range accelerators {
+		for _, name := range []string{accel.Kind, accel.Model} {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
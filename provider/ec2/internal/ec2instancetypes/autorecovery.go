@@ -0,0 +1,34 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2instancetypes
+
+import "strings"
+
+// SupportsAutoRecovery reports whether instances of the type with the
+// given name can be recovered automatically by AWS when the underlying
+// hardware fails.
+//
+// At the time of writing, the following instance type families support
+// recovery: C3, C4, C5, M3, M4, M5, P2, P3, R3, R4, T2, X1. Instances of
+// the C3, M3 and R3 families can be recovered only if they are launched
+// without instance store volumes.
+//
+// See http://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-instance-recover.html
+func SupportsAutoRecovery(instanceType string) bool {
+	parts := strings.SplitN(instanceType, ".", 2)
+	if len(parts) < 2 {
+		return false
+	}
+	switch strings.ToLower(parts[0]) {
+	case
+		"c3", "c4", "c5",
+		"m3", "m4", "m5",
+		"p2", "p3",
+		"r3", "r4",
+		"t2",
+		"x1":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2instancetypes
+
+// ebsBaselineBandwidth holds the dedicated EBS bandwidth, in Mbps, that AWS
+// provides to EBS-optimized instances of each type. Instance types not
+// listed here cannot be launched EBS-optimized.
+//
+// See http://docs.aws.amazon.com/AWSEC2/latest/UserGuide/EBSOptimized.html
+var ebsBaselineBandwidth = map[string]int{
+	"c3.xlarge":   500,
+	"c3.2xlarge":  1000,
+	"c3.4xlarge":  2000,
+	"c4.large":    500,
+	"c4.xlarge":   750,
+	"c4.2xlarge":  1000,
+	"c4.4xlarge":  2000,
+	"c4.8xlarge":  4000,
+	"d2.xlarge":   750,
+	"d2.2xlarge":  1000,
+	"d2.4xlarge":  2000,
+	"d2.8xlarge":  4000,
+	"g2.2xlarge":  1000,
+	"i2.xlarge":   500,
+	"i2.2xlarge":  1000,
+	"i2.4xlarge":  2000,
+	"i3.large":    425,
+	"i3.xlarge":   850,
+	"i3.2xlarge":  1700,
+	"i3.4xlarge":  3500,
+	"i3.8xlarge":  7000,
+	"i3.16xlarge": 14000,
+	"m3.xlarge":   500,
+	"m3.2xlarge":  1000,
+	"m4.large":    450,
+	"m4.xlarge":   750,
+	"m4.2xlarge":  1000,
+	"m4.4xlarge":  2000,
+	"m4.10xlarge": 4000,
+	"m4.16xlarge": 10000,
+	"p2.xlarge":   750,
+	"p2.8xlarge":  5000,
+	"p2.16xlarge": 10000,
+	"r3.xlarge":   500,
+	"r3.2xlarge":  1000,
+	"r3.4xlarge":  2000,
+	"r4.large":    425,
+	"r4.xlarge":   850,
+	"r4.2xlarge":  1700,
+	"r4.4xlarge":  3500,
+	"r4.8xlarge":  7000,
+	"r4.16xlarge": 14000,
+	"x1.16xlarge": 5000,
+	"x1.32xlarge": 10000,
+}
+
+// EBSBaselineBandwidth returns the dedicated EBS bandwidth, in Mbps, of the
+// named instance type when launched EBS-optimized, and reports whether the
+// instance type can be launched EBS-optimized at all.
+func EBSBaselineBandwidth(instanceType string) (int, bool) {
+	bandwidth, ok := ebsBaselineBandwidth[instanceType]
+	return bandwidth, ok
+}
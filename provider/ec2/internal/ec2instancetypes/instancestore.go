@@ -0,0 +1,68 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2instancetypes
+
+// InstanceStore describes the instance store (ephemeral) volumes that
+// come with an instance type.
+type InstanceStore struct {
+	// Count is the number of instance store volumes.
+	Count int
+
+	// SizeGiB is the size of each instance store volume, in GiB.
+	SizeGiB int
+}
+
+// instanceStores holds the instance store volumes of each instance type
+// that has any. Instance types not listed here are EBS-only.
+//
+// See http://docs.aws.amazon.com/AWSEC2/latest/UserGuide/InstanceStorage.html
+var instanceStores = map[string]InstanceStore{
+	"c1.medium":   {1, 350},
+	"c1.xlarge":   {4, 420},
+	"c3.large":    {2, 16},
+	"c3.xlarge":   {2, 40},
+	"c3.2xlarge":  {2, 80},
+	"c3.4xlarge":  {2, 160},
+	"c3.8xlarge":  {2, 320},
+	"cc2.8xlarge": {4, 840},
+	"cg1.4xlarge": {2, 840},
+	"cr1.8xlarge": {2, 120},
+	"d2.xlarge":   {3, 2000},
+	"d2.2xlarge":  {6, 2000},
+	"d2.4xlarge":  {12, 2000},
+	"d2.8xlarge":  {24, 2000},
+	"g2.2xlarge":  {1, 60},
+	"g2.8xlarge":  {2, 120},
+	"hi1.4xlarge": {2, 1024},
+	"hs1.8xlarge": {24, 2000},
+	"i2.xlarge":   {1, 800},
+	"i2.2xlarge":  {2, 800},
+	"i2.4xlarge":  {4, 800},
+	"i2.8xlarge":  {8, 800},
+	"m1.small":    {1, 160},
+	"m1.medium":   {1, 410},
+	"m1.large":    {2, 420},
+	"m1.xlarge":   {4, 420},
+	"m2.xlarge":   {1, 420},
+	"m2.2xlarge":  {1, 850},
+	"m2.4xlarge":  {2, 840},
+	"m3.medium":   {1, 4},
+	"m3.large":    {1, 32},
+	"m3.xlarge":   {2, 40},
+	"m3.2xlarge":  {2, 80},
+	"r3.large":    {1, 32},
+	"r3.xlarge":   {1, 80},
+	"r3.2xlarge":  {1, 160},
+	"r3.4xlarge":  {1, 320},
+	"r3.8xlarge":  {2, 320},
+	"x1.16xlarge": {1, 1920},
+	"x1.32xlarge": {2, 1920},
+}
+
+// InstanceStores returns the instance store volumes of the named instance
+// type, and reports whether the instance type has any at all.
+func InstanceStores(instanceType string) (InstanceStore, bool) {
+	store, ok := instanceStores[instanceType]
+	return store, ok
+}
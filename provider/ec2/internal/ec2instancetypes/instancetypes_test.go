@@ -110,3 +110,53 @@ func (s *InstanceTypesSuite) TestSupportsClassic(c *gc.C) {
 	assertDoesNotSupportClassic("t2.medium")
 	assertDoesNotSupportClassic("x1.32xlarge")
 }
+
+func (s *InstanceTypesSuite) TestEBSBaselineBandwidth(c *gc.C) {
+	bandwidth, ok := ec2instancetypes.EBSBaselineBandwidth("m4.xlarge")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(bandwidth, gc.Equals, 750)
+
+	_, ok = ec2instancetypes.EBSBaselineBandwidth("t2.micro")
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *InstanceTypesSuite) TestInstanceStores(c *gc.C) {
+	store, ok := ec2instancetypes.InstanceStores("d2.2xlarge")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(store, jc.DeepEquals, ec2instancetypes.InstanceStore{Count: 6, SizeGiB: 2000})
+
+	_, ok = ec2instancetypes.InstanceStores("m4.large")
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *InstanceTypesSuite) TestSupportsAutoRecovery(c *gc.C) {
+	c.Assert(ec2instancetypes.SupportsAutoRecovery("m4.large"), jc.IsTrue)
+	c.Assert(ec2instancetypes.SupportsAutoRecovery("c3.xlarge"), jc.IsTrue)
+	c.Assert(ec2instancetypes.SupportsAutoRecovery("t2.micro"), jc.IsTrue)
+	c.Assert(ec2instancetypes.SupportsAutoRecovery("d2.xlarge"), jc.IsFalse)
+	c.Assert(ec2instancetypes.SupportsAutoRecovery("m1.small"), jc.IsFalse)
+	c.Assert(ec2instancetypes.SupportsAutoRecovery("invalid"), jc.IsFalse)
+}
+
+func (s *InstanceTypesSuite) TestAccelerators(c *gc.C) {
+	accel, ok := ec2instancetypes.Accelerators("p2.8xlarge")
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(accel, jc.DeepEquals, ec2instancetypes.Accelerator{
+		Kind:  ec2instancetypes.AcceleratorGPU,
+		Model: "nvidia-tesla-k80",
+		Count: 8,
+	})
+	c.Assert(accel.Matches("gpu"), jc.IsTrue)
+	c.Assert(accel.Matches("nvidia-tesla-k80"), jc.IsTrue)
+	c.Assert(accel.Matches("inferentia"), jc.IsFalse)
+
+	_, ok = ec2instancetypes.Accelerators("m4.large")
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *InstanceTypesSuite) TestAcceleratorNames(c *gc.C) {
+	names := set.NewStrings(ec2instancetypes.AcceleratorNames()...)
+	for _, name := range []string{"gpu", "fpga", "inferentia", "nvidia-tesla-k80", "aws-inferentia"} {
+		c.Check(names.Contains(name), jc.IsTrue, gc.Commentf("%s", name))
+	}
+}
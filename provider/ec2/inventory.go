@@ -0,0 +1,240 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/tags"
+)
+
+// inventoryAPIVersion is the EC2 API version used to list the resources
+// in a model's inventory; the version used by the EC2 client library
+// predates paging for most of the describe actions.
+const inventoryAPIVersion = "2016-11-15"
+
+// inventoryPageSize is the number of resources requested in each page
+// of a describe action, the largest that all of them accept.
+const inventoryPageSize = 500
+
+// maxInventoryFilterValues is the largest number of values that EC2
+// accepts for a single filter.
+const maxInventoryFilterValues = 200
+
+// The describe actions that list the resources in a model's inventory.
+const (
+	describeInstancesAction         = "DescribeInstances"
+	describeVolumesAction           = "DescribeVolumes"
+	describeSecurityGroupsAction    = "DescribeSecurityGroups"
+	describeNetworkInterfacesAction = "DescribeNetworkInterfaces"
+)
+
+// TaggedResource holds the ID and tags of an AWS resource.
+type TaggedResource struct {
+	ID   string
+	Tags map[string]string
+}
+
+// ResourceInventory holds the AWS resources that belong to a model,
+// for auditing or migrating it. Each list is sorted by ID.
+type ResourceInventory struct {
+	Instances         []TaggedResource
+	Volumes           []TaggedResource
+	SecurityGroups    []TaggedResource
+	NetworkInterfaces []TaggedResource
+}
+
+// inventoryAPI is the subset of the EC2 API used to list the resources
+// in a model's inventory.
+type inventoryAPI interface {
+	// DescribeTagged returns the ID and tags of every resource listed
+	// by the given describe action with the given filters, following
+	// each page of the response.
+	DescribeTagged(action string, filters map[string][]string) ([]TaggedResource, error)
+}
+
+// newInventoryAPI returns an inventoryAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newInventoryAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) inventoryAPI {
+	return &inventoryClient{newEC2Client(cloud, inventoryAPIVersion, wrapSigner)}
+}
+
+// inventoryClient is a minimal client for the EC2 query API, used to
+// list resources a page at a time.
+type inventoryClient struct {
+	*awsAPIClient
+}
+
+// inventoryItem is a resource listed by one of the describe actions.
+// Only the ID element of the action's resource type is set; the IDs
+// of related resources are nested deeper, so are not decoded.
+type inventoryItem struct {
+	InstanceId         string `xml:"instanceId"`
+	VolumeId           string `xml:"volumeId"`
+	GroupId            string `xml:"groupId"`
+	NetworkInterfaceId string `xml:"networkInterfaceId"`
+	Tags               []struct {
+		Key   string `xml:"key"`
+		Value string `xml:"value"`
+	} `xml:"tagSet>item"`
+}
+
+// DescribeTagged is part of the inventoryAPI interface.
+func (c *inventoryClient) DescribeTagged(action string, filters map[string][]string) ([]TaggedResource, error) {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var resources []TaggedResource
+	var nextToken string
+	for {
+		params := url.Values{"MaxResults": {strconv.Itoa(inventoryPageSize)}}
+		for i, name := range names {
+			prefix := fmt.Sprintf("Filter.%d.", i+1)
+			params.Set(prefix+"Name", name)
+			for j, value := range filters[name] {
+				params.Set(fmt.Sprintf("%sValue.%d", prefix, j+1), value)
+			}
+		}
+		if nextToken != "" {
+			params.Set("NextToken", nextToken)
+		}
+		var resp struct {
+			Instances         []inventoryItem `xml:"reservationSet>item>instancesSet>item"`
+			Volumes           []inventoryItem `xml:"volumeSet>item"`
+			SecurityGroups    []inventoryItem `xml:"securityGroupInfo>item"`
+			NetworkInterfaces []inventoryItem `xml:"networkInterfaceSet>item"`
+			NextToken         string          `xml:"nextToken"`
+		}
+		if err := c.query(action, params, &resp); err != nil {
+			return nil, errors.Trace(err)
+		}
+		var items []inventoryItem
+		var id func(inventoryItem) string
+		switch action {
+		case describeInstancesAction:
+			items, id = resp.Instances, func(item inventoryItem) string { return item.InstanceId }
+		case describeVolumesAction:
+			items, id = resp.Volumes, func(item inventoryItem) string { return item.VolumeId }
+		case describeSecurityGroupsAction:
+			items, id = resp.SecurityGroups, func(item inventoryItem) string { return item.GroupId }
+		case describeNetworkInterfacesAction:
+			items, id = resp.NetworkInterfaces, func(item inventoryItem) string { return item.NetworkInterfaceId }
+		default:
+			return nil, errors.NotSupportedf("listing resources with %s", action)
+		}
+		for _, item := range items {
+			resource := TaggedResource{ID: id(item), Tags: make(map[string]string)}
+			for _, tag := range item.Tags {
+				resource.Tags[tag.Key] = tag.Value
+			}
+			resources = append(resources, resource)
+		}
+		if resp.NextToken == "" {
+			return resources, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// modelInventoryFilters returns the filters that select the resources
+// tagged with the model's UUID.
+func (e *environ) modelInventoryFilters() map[string][]string {
+	return map[string][]string{"tag:" + tags.JujuModel: {e.uuid()}}
+}
+
+// allModelNetworkInterfaces returns the network interfaces tagged with
+// the model's UUID, along with those attached to the given instances
+// of the model. Interfaces created along with an instance, including
+// its primary interface, are not tagged.
+func (e *environ) allModelNetworkInterfaces(instances []TaggedResource) ([]TaggedResource, error) {
+	interfaces, err := e.inventory.DescribeTagged(describeNetworkInterfacesAction, e.modelInventoryFilters())
+	if err != nil {
+		return nil, errors.Annotate(err, "listing network interfaces")
+	}
+	seen := set.NewStrings()
+	for _, iface := range interfaces {
+		seen.Add(iface.ID)
+	}
+	for start := 0; start < len(instances); start += maxInventoryFilterValues {
+		end := start + maxInventoryFilterValues
+		if end > len(instances) {
+			end = len(instances)
+		}
+		instIds := make([]string, 0, end-start)
+		for _, inst := range instances[start:end] {
+			instIds = append(instIds, inst.ID)
+		}
+		attached, err := e.inventory.DescribeTagged(describeNetworkInterfacesAction, map[string][]string{
+			"attachment.instance-id": instIds,
+		})
+		if err != nil {
+			return nil, errors.Annotate(err, "listing network interfaces")
+		}
+		for _, iface := range attached {
+			if !seen.Contains(iface.ID) {
+				seen.Add(iface.ID)
+				interfaces = append(interfaces, iface)
+			}
+		}
+	}
+	return interfaces, nil
+}
+
+// ModelResourceInventory returns the instances, volumes, security groups
+// and network interfaces that belong to the model, with their tags.
+// Resources are listed a page at a time, so none are missed however
+// many the model has.
+func (e *environ) ModelResourceInventory() (*ResourceInventory, error) {
+	filters := e.modelInventoryFilters()
+	filters["instance-state-name"] = aliveInstanceStates
+	instances, err := e.inventory.DescribeTagged(describeInstancesAction, filters)
+	if err != nil {
+		return nil, errors.Annotate(err, "listing instances")
+	}
+	volumes, err := e.inventory.DescribeTagged(describeVolumesAction, e.modelInventoryFilters())
+	if err != nil {
+		return nil, errors.Annotate(err, "listing volumes")
+	}
+	groups, err := e.inventory.DescribeTagged(describeSecurityGroupsAction, e.modelInventoryFilters())
+	if err != nil {
+		return nil, errors.Annotate(err, "listing security groups")
+	}
+	interfaces, err := e.allModelNetworkInterfaces(instances)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	inventory := &ResourceInventory{
+		Instances:         instances,
+		Volumes:           volumes,
+		SecurityGroups:    groups,
+		NetworkInterfaces: interfaces,
+	}
+	for _, resources := range [][]TaggedResource{
+		inventory.Instances,
+		inventory.Volumes,
+		inventory.SecurityGroups,
+		inventory.NetworkInterfaces,
+	} {
+		sort.Sort(taggedResourcesByID(resources))
+	}
+	return inventory, nil
+}
+
+type taggedResourcesByID []TaggedResource
+
+func (r taggedResourcesByID) Len() int           { return len(r) }
+func (r taggedResourcesByID) Less(i, j int) bool { return r[i].ID < r[j].ID }
+func (r taggedResourcesByID) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
@@ -0,0 +1,199 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type inventorySuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *inventoryClient
+}
+
+var _ = gc.Suite(&inventorySuite{})
+
+func (s *inventorySuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &inventoryClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  inventoryAPIVersion,
+	}}
+}
+
+func (s *inventorySuite) TestDescribeTaggedPages(c *gc.C) {
+	s.responses = []string{`
+<DescribeVolumesResponse>
+  <volumeSet>
+    <item>
+      <volumeId>vol-1</volumeId>
+      <attachmentSet><item><instanceId>i-1</instanceId></item></attachmentSet>
+      <tagSet><item><key>juju-model-uuid</key><value>uuid</value></item></tagSet>
+    </item>
+  </volumeSet>
+  <nextToken>token</nextToken>
+</DescribeVolumesResponse>`, `
+<DescribeVolumesResponse>
+  <volumeSet>
+    <item>
+      <volumeId>vol-2</volumeId>
+      <tagSet>
+        <item><key>juju-model-uuid</key><value>uuid</value></item>
+        <item><key>Name</key><value>juju-volume-2</value></item>
+      </tagSet>
+    </item>
+  </volumeSet>
+</DescribeVolumesResponse>`}
+	volumes, err := s.client.DescribeTagged("DescribeVolumes", map[string][]string{
+		"tag:juju-model-uuid": {"uuid"},
+		"status":              {"available", "in-use"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(volumes, jc.DeepEquals, []TaggedResource{
+		{ID: "vol-1", Tags: map[string]string{"juju-model-uuid": "uuid"}},
+		{ID: "vol-2", Tags: map[string]string{"juju-model-uuid": "uuid", "Name": "juju-volume-2"}},
+	})
+
+	c.Assert(s.requests, gc.HasLen, 2)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DescribeVolumes")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, inventoryAPIVersion)
+	c.Check(s.requests[0].Get("MaxResults"), gc.Equals, "500")
+	c.Check(s.requests[0].Get("Filter.1.Name"), gc.Equals, "status")
+	c.Check(s.requests[0].Get("Filter.1.Value.1"), gc.Equals, "available")
+	c.Check(s.requests[0].Get("Filter.1.Value.2"), gc.Equals, "in-use")
+	c.Check(s.requests[0].Get("Filter.2.Name"), gc.Equals, "tag:juju-model-uuid")
+	c.Check(s.requests[0].Get("Filter.2.Value.1"), gc.Equals, "uuid")
+	c.Check(s.requests[0].Get("NextToken"), gc.Equals, "")
+	c.Check(s.requests[1].Get("NextToken"), gc.Equals, "token")
+}
+
+func (s *inventorySuite) TestDescribeTaggedInstances(c *gc.C) {
+	s.responses = []string{`
+<DescribeInstancesResponse>
+  <reservationSet>
+    <item>
+      <instancesSet>
+        <item>
+          <instanceId>i-1</instanceId>
+          <networkInterfaceSet><item><networkInterfaceId>eni-1</networkInterfaceId></item></networkInterfaceSet>
+          <tagSet><item><key>Name</key><value>juju-machine-0</value></item></tagSet>
+        </item>
+      </instancesSet>
+    </item>
+  </reservationSet>
+</DescribeInstancesResponse>`}
+	instances, err := s.client.DescribeTagged("DescribeInstances", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(instances, jc.DeepEquals, []TaggedResource{
+		{ID: "i-1", Tags: map[string]string{"Name": "juju-machine-0"}},
+	})
+}
+
+func (s *inventorySuite) TestDescribeTaggedError(c *gc.C) {
+	s.status = http.StatusForbidden
+	s.responses = []string{`
+<Response><Errors><Error>
+  <Code>UnauthorizedOperation</Code>
+  <Message>denied</Message>
+</Error></Errors></Response>`}
+	_, err := s.client.DescribeTagged("DescribeSecurityGroups", nil)
+	c.Assert(err, gc.ErrorMatches, `denied \(UnauthorizedOperation\)`)
+}
+
+type fakeInventoryAPI struct {
+	resources map[string][]TaggedResource
+	calls     []map[string][]string
+}
+
+func (f *fakeInventoryAPI) DescribeTagged(action string, filters map[string][]string) ([]TaggedResource, error) {
+	f.calls = append(f.calls, filters)
+	if action == describeNetworkInterfacesAction {
+		if instIds, ok := filters["attachment.instance-id"]; ok {
+			var attached []TaggedResource
+			for _, instId := range instIds {
+				attached = append(attached, f.resources["attached:"+instId]...)
+			}
+			return attached, nil
+		}
+	}
+	return f.resources[action], nil
+}
+
+func (s *inventorySuite) TestModelResourceInventory(c *gc.C) {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig())
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	modelTags := map[string]string{"juju-model-uuid": testing.ModelTag.Id()}
+	api := &fakeInventoryAPI{resources: map[string][]TaggedResource{
+		"DescribeInstances": {
+			{ID: "i-2", Tags: modelTags},
+			{ID: "i-1", Tags: modelTags},
+		},
+		"DescribeVolumes":        {{ID: "vol-1", Tags: modelTags}},
+		"DescribeSecurityGroups": {{ID: "sg-1", Tags: modelTags}},
+		"DescribeNetworkInterfaces": {
+			{ID: "eni-3", Tags: modelTags},
+		},
+		"attached:i-1": {{ID: "eni-1", Tags: map[string]string{}}},
+		"attached:i-2": {
+			{ID: "eni-2", Tags: map[string]string{}},
+			{ID: "eni-3", Tags: modelTags},
+		},
+	}}
+	env := &environ{ecfgUnlocked: ecfg, inventory: api}
+
+	inventory, err := env.ModelResourceInventory()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(inventory, jc.DeepEquals, &ResourceInventory{
+		Instances: []TaggedResource{
+			{ID: "i-1", Tags: modelTags},
+			{ID: "i-2", Tags: modelTags},
+		},
+		Volumes:        []TaggedResource{{ID: "vol-1", Tags: modelTags}},
+		SecurityGroups: []TaggedResource{{ID: "sg-1", Tags: modelTags}},
+		NetworkInterfaces: []TaggedResource{
+			{ID: "eni-1", Tags: map[string]string{}},
+			{ID: "eni-2", Tags: map[string]string{}},
+			{ID: "eni-3", Tags: modelTags},
+		},
+	})
+	c.Assert(api.calls[0], jc.DeepEquals, map[string][]string{
+		"tag:juju-model-uuid": {testing.ModelTag.Id()},
+		"instance-state-name": {"pending", "running"},
+	})
+	c.Assert(api.calls[len(api.calls)-1], jc.DeepEquals, map[string][]string{
+		"attachment.instance-id": {"i-2", "i-1"},
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/tags"
+)
+
+// launchTemplateAPIVersion is the EC2 API version used for launch
+// templates; the version used by the EC2 client library predates them.
+const launchTemplateAPIVersion = "2016-11-15"
+
+const (
+	launchTemplateVersionLatest  = "$Latest"
+	launchTemplateVersionDefault = "$Default"
+)
+
+// launchTemplateAPI is the subset of the EC2 API used to inspect the
+// launch templates that instances are launched from.
+type launchTemplateAPI interface {
+	// LaunchTemplateData returns the launch parameters of the given
+	// version of the launch template.
+	LaunchTemplateData(templateId, version string) (*launchTemplateData, error)
+}
+
+// launchTemplateData holds the launch parameters of a launch template
+// version that may conflict with those Juju requires.
+type launchTemplateData struct {
+	DisableAPITermination bool `xml:"disableApiTermination"`
+	NetworkInterfaces     []struct {
+		DeviceIndex int `xml:"deviceIndex"`
+	} `xml:"networkInterfaceSet>item"`
+	TagSpecifications []struct {
+		ResourceType string `xml:"resourceType"`
+		Tags         []struct {
+			Key   string `xml:"key"`
+			Value string `xml:"value"`
+		} `xml:"tagSet>item"`
+	} `xml:"tagSpecificationSet>item"`
+}
+
+// newLaunchTemplateAPI returns a launchTemplateAPI for the given cloud,
+// whose request signer is wrapped with wrapSigner. It is a variable so
+// it can be replaced in tests.
+var newLaunchTemplateAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) launchTemplateAPI {
+	return &launchTemplateClient{newEC2Client(cloud, launchTemplateAPIVersion, wrapSigner)}
+}
+
+// launchTemplateClient is a minimal client for the EC2 query API, used
+// to describe launch templates.
+type launchTemplateClient struct {
+	*awsAPIClient
+}
+
+// LaunchTemplateData is part of the launchTemplateAPI interface.
+func (c *launchTemplateClient) LaunchTemplateData(templateId, version string) (*launchTemplateData, error) {
+	if version == "" {
+		version = launchTemplateVersionDefault
+	}
+	params := url.Values{
+		"LaunchTemplateId":        {templateId},
+		"LaunchTemplateVersion.1": {version},
+	}
+	var resp struct {
+		Versions []struct {
+			Data launchTemplateData `xml:"launchTemplateData"`
+		} `xml:"launchTemplateVersionSet>item"`
+	}
+	if err := c.query("DescribeLaunchTemplateVersions", params, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && strings.HasPrefix(err.Code, "InvalidLaunchTemplateId.") {
+			return nil, errors.NotFoundf("launch template %q version %q", templateId, version)
+		}
+		return nil, errors.Annotatef(err, "describing launch template %q", templateId)
+	}
+	if len(resp.Versions) == 0 {
+		return nil, errors.NotFoundf("launch template %q version %q", templateId, version)
+	}
+	return &resp.Versions[0].Data, nil
+}
+
+// isLaunchTemplateID reports whether the given string looks like the
+// ID of a launch template.
+func isLaunchTemplateID(id string) bool {
+	return strings.HasPrefix(id, "lt-") && len(id) > len("lt-")
+}
+
+// isLaunchTemplateVersion reports whether the given string names a
+// version of a launch template.
+func isLaunchTemplateVersion(version string) bool {
+	if version == launchTemplateVersionLatest || version == launchTemplateVersionDefault {
+		return true
+	}
+	n, err := strconv.Atoi(version)
+	return err == nil && n > 0
+}
+
+// validateLaunchTemplate checks that the configured launch template, if
+// any, exists, and that its launch parameters don't conflict with those
+// Juju requires. Parameters that Juju sets itself, such as the image,
+// user data and security groups, override the template's, so only
+// those that Juju can't override are checked.
+func (e *environ) validateLaunchTemplate() error {
+	templateId := e.ecfg().launchTemplateID()
+	if templateId == "" {
+		return nil
+	}
+	data, err := e.launchTemplates.LaunchTemplateData(templateId, e.ecfg().launchTemplateVersion())
+	if err != nil {
+		return errors.Annotate(err, "validating launch-template-id")
+	}
+	if data.DisableAPITermination {
+		return errors.Errorf("launch template %q disables API termination, so Juju could not terminate its instances", templateId)
+	}
+	if len(data.NetworkInterfaces) > 0 {
+		return errors.Errorf("launch template %q specifies network interfaces, which conflict with the subnets and security groups Juju assigns", templateId)
+	}
+	for _, spec := range data.TagSpecifications {
+		for _, tag := range spec.Tags {
+			if strings.HasPrefix(tag.Key, tags.JujuTagPrefix) {
+				return errors.Errorf("launch template %q tags %ss with %q, which is reserved for Juju", templateId, spec.ResourceType, tag.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// launchTemplateParams adds the RunInstances parameters that launch
+// the instance from the model's launch template, if any. The EC2 client
+// library has no support for launch templates. The parameters Juju sets
+// in the request take precedence over those in the template.
+func launchTemplateParams(ecfg *environConfig, params url.Values) {
+	if templateId := ecfg.launchTemplateID(); templateId != "" {
+		params.Set("LaunchTemplate.LaunchTemplateId", templateId)
+		if version := ecfg.launchTemplateVersion(); version != "" {
+			params.Set("LaunchTemplate.Version", version)
+		}
+	}
+}
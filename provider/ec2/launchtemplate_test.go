@@ -0,0 +1,227 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type launchTemplateSuite struct {
+	testing.BaseSuite
+
+	server   *httptest.Server
+	requests []url.Values
+	status   int
+	response string
+	client   *launchTemplateClient
+}
+
+var _ = gc.Suite(&launchTemplateSuite{})
+
+const testLaunchTemplateID = "lt-0123456789abcdef0"
+
+func (s *launchTemplateSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.response = launchTemplateResponse("")
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.response)
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &launchTemplateClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  launchTemplateAPIVersion,
+	}}
+}
+
+func launchTemplateResponse(data string) string {
+	return fmt.Sprintf(`
+<DescribeLaunchTemplateVersionsResponse>
+  <launchTemplateVersionSet>
+    <item>
+      <launchTemplateId>%s</launchTemplateId>
+      <versionNumber>1</versionNumber>
+      <launchTemplateData>%s</launchTemplateData>
+    </item>
+  </launchTemplateVersionSet>
+</DescribeLaunchTemplateVersionsResponse>`, testLaunchTemplateID, data)
+}
+
+func (s *launchTemplateSuite) environ(c *gc.C, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg, launchTemplates: s.client}
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateData(c *gc.C) {
+	s.response = launchTemplateResponse(`
+<disableApiTermination>true</disableApiTermination>
+<networkInterfaceSet><item><deviceIndex>0</deviceIndex></item></networkInterfaceSet>
+<tagSpecificationSet>
+  <item>
+    <resourceType>instance</resourceType>
+    <tagSet><item><key>cost-centre</key><value>ops</value></item></tagSet>
+  </item>
+</tagSpecificationSet>`)
+	data, err := s.client.LaunchTemplateData(testLaunchTemplateID, "3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(data.DisableAPITermination, jc.IsTrue)
+	c.Assert(data.NetworkInterfaces, gc.HasLen, 1)
+	c.Assert(data.TagSpecifications, gc.HasLen, 1)
+	c.Assert(data.TagSpecifications[0].ResourceType, gc.Equals, "instance")
+	c.Assert(data.TagSpecifications[0].Tags, gc.HasLen, 1)
+	c.Assert(data.TagSpecifications[0].Tags[0].Key, gc.Equals, "cost-centre")
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	req := s.requests[0]
+	c.Check(req.Get("Action"), gc.Equals, "DescribeLaunchTemplateVersions")
+	c.Check(req.Get("Version"), gc.Equals, launchTemplateAPIVersion)
+	c.Check(req.Get("LaunchTemplateId"), gc.Equals, testLaunchTemplateID)
+	c.Check(req.Get("LaunchTemplateVersion.1"), gc.Equals, "3")
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateDataDefaultVersion(c *gc.C) {
+	_, err := s.client.LaunchTemplateData(testLaunchTemplateID, "")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("LaunchTemplateVersion.1"), gc.Equals, "$Default")
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateDataNotFound(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `
+<Response>
+  <Errors>
+    <Error>
+      <Code>InvalidLaunchTemplateId.VersionNotFound</Code>
+      <Message>not found</Message>
+    </Error>
+  </Errors>
+</Response>`
+	_, err := s.client.LaunchTemplateData(testLaunchTemplateID, "3")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `launch template "lt-0123456789abcdef0" version "3" not found`)
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateDataError(c *gc.C) {
+	s.status = http.StatusForbidden
+	s.response = `
+<Response>
+  <Errors>
+    <Error>
+      <Code>UnauthorizedOperation</Code>
+      <Message>denied</Message>
+    </Error>
+  </Errors>
+</Response>`
+	_, err := s.client.LaunchTemplateData(testLaunchTemplateID, "")
+	c.Assert(err, gc.ErrorMatches, `describing launch template "lt-0123456789abcdef0": denied \(UnauthorizedOperation\)`)
+}
+
+func (s *launchTemplateSuite) TestValidateLaunchTemplate(c *gc.C) {
+	env := s.environ(c, testing.Attrs{
+		"launch-template-id":      testLaunchTemplateID,
+		"launch-template-version": "$Latest",
+	})
+	s.response = launchTemplateResponse(`
+<instanceType>m5.large</instanceType>
+<userData>IyEvYmluL3NoCg==</userData>
+<tagSpecificationSet>
+  <item>
+    <resourceType>instance</resourceType>
+    <tagSet><item><key>cost-centre</key><value>ops</value></item></tagSet>
+  </item>
+</tagSpecificationSet>`)
+	c.Assert(env.validateLaunchTemplate(), jc.ErrorIsNil)
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("LaunchTemplateVersion.1"), gc.Equals, "$Latest")
+}
+
+func (s *launchTemplateSuite) TestValidateLaunchTemplateConflicts(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"launch-template-id": testLaunchTemplateID})
+	for i, test := range []struct {
+		data string
+		err  string
+	}{{
+		data: `<disableApiTermination>true</disableApiTermination>`,
+		err:  `launch template "lt-0123456789abcdef0" disables API termination, so Juju could not terminate its instances`,
+	}, {
+		data: `<networkInterfaceSet><item><deviceIndex>0</deviceIndex></item></networkInterfaceSet>`,
+		err:  `launch template "lt-0123456789abcdef0" specifies network interfaces, .*`,
+	}, {
+		data: `
+<tagSpecificationSet>
+  <item>
+    <resourceType>volume</resourceType>
+    <tagSet><item><key>juju-model-uuid</key><value>deadbeef</value></item></tagSet>
+  </item>
+</tagSpecificationSet>`,
+		err: `launch template "lt-0123456789abcdef0" tags volumes with "juju-model-uuid", which is reserved for Juju`,
+	}} {
+		c.Logf("test %d", i)
+		s.response = launchTemplateResponse(test.data)
+		c.Check(env.validateLaunchTemplate(), gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *launchTemplateSuite) TestValidateLaunchTemplateNotFound(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"launch-template-id": testLaunchTemplateID})
+	s.response = `<DescribeLaunchTemplateVersionsResponse/>`
+	err := env.validateLaunchTemplate()
+	c.Assert(err, gc.ErrorMatches, `validating launch-template-id: launch template "lt-0123456789abcdef0" version "\$Default" not found`)
+}
+
+func (s *launchTemplateSuite) TestValidateLaunchTemplateUnset(c *gc.C) {
+	env := s.environ(c, nil)
+	c.Assert(env.validateLaunchTemplate(), jc.ErrorIsNil)
+	c.Assert(s.requests, gc.HasLen, 0)
+}
+
+func (s *launchTemplateSuite) params(env *environ) url.Values {
+	params := make(url.Values)
+	launchTemplateParams(env.ecfg(), params)
+	return params
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateParams(c *gc.C) {
+	env := s.environ(c, testing.Attrs{
+		"launch-template-id":      testLaunchTemplateID,
+		"launch-template-version": "3",
+	})
+	c.Assert(s.params(env), jc.DeepEquals, url.Values{
+		"LaunchTemplate.LaunchTemplateId": {testLaunchTemplateID},
+		"LaunchTemplate.Version":          {"3"},
+	})
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateParamsDefaultVersion(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"launch-template-id": testLaunchTemplateID})
+	c.Assert(s.params(env), jc.DeepEquals, url.Values{
+		"LaunchTemplate.LaunchTemplateId": {testLaunchTemplateID},
+	})
+}
+
+func (s *launchTemplateSuite) TestLaunchTemplateParamsUnset(c *gc.C) {
+	env := s.environ(c, nil)
+	c.Assert(s.params(env), gc.HasLen, 0)
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// licenseManagerAPI is the subset of the AWS License Manager API used
+// to validate license configurations.
+type licenseManagerAPI interface {
+	GetLicenseConfiguration(arn string) (*licenseConfiguration, error)
+}
+
+// licenseConfiguration describes a License Manager license
+// configuration.
+type licenseConfiguration struct {
+	Arn    string `json:"LicenseConfigurationArn"`
+	Name   string `json:"Name"`
+	Status string `json:"Status"`
+}
+
+// newLicenseManagerAPI returns a licenseManagerAPI for the given cloud,
+// whose request signer is wrapped with wrapSigner. It is a variable so
+// it can be replaced in tests.
+var newLicenseManagerAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) licenseManagerAPI {
+	client := newAWSAPIClient(cloud, cloud.Region, "license-manager", "", wrapSigner)
+	client.target = "AWSLicenseManager"
+	return &licenseManagerClient{client}
+}
+
+// licenseManagerClient is a minimal client for the AWS License Manager
+// JSON API.
+type licenseManagerClient struct {
+	*awsAPIClient
+}
+
+// GetLicenseConfiguration is part of the licenseManagerAPI interface.
+func (c *licenseManagerClient) GetLicenseConfiguration(arn string) (*licenseConfiguration, error) {
+	args := struct {
+		Arn string `json:"LicenseConfigurationArn"`
+	}{arn}
+	var resp licenseConfiguration
+	if err := c.call("GetLicenseConfiguration", args, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && err.Code == "InvalidParameterValueException" {
+			return nil, errors.NotFoundf("license configuration %q", arn)
+		}
+		return nil, errors.Trace(err)
+	}
+	return &resp, nil
+}
+
+// isLicenseConfigurationARN reports whether the given string looks like
+// the ARN of a License Manager license configuration.
+func isLicenseConfigurationARN(arn string) bool {
+	parts := strings.SplitN(arn, ":", 6)
+	return len(parts) == 6 &&
+		parts[0] == "arn" &&
+		parts[2] == "license-manager" &&
+		strings.HasPrefix(parts[5], "license-configuration:")
+}
+
+// validateLicenseConfiguration checks that the configured license
+// configuration, if any, exists and is available.
+func (e *environ) validateLicenseConfiguration() error {
+	arn := e.ecfg().licenseConfigurationARN()
+	if arn == "" {
+		return nil
+	}
+	lc, err := e.licenseManager.GetLicenseConfiguration(arn)
+	if err != nil {
+		return errors.Annotate(err, "validating license-configuration-arn")
+	}
+	if lc.Status != "" && lc.Status != "AVAILABLE" {
+		return errors.Errorf("license configuration %q is %s", arn, strings.ToLower(lc.Status))
+	}
+	return nil
+}
+
+// licenseParams adds the RunInstances parameter that carries the
+// model's license configuration, if any. The EC2 client library has no
+// support for license specifications.
+func licenseParams(ecfg *environConfig, params url.Values) {
+	if arn := ecfg.licenseConfigurationARN(); arn != "" {
+		params.Set("LicenseSpecification.1.LicenseConfigurationArn", arn)
+	}
+}
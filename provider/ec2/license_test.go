@@ -0,0 +1,142 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type licenseSuite struct {
+	testing.BaseSuite
+
+	server   *httptest.Server
+	targets  []string
+	bodies   []map[string]string
+	status   int
+	response string
+	client   *licenseManagerClient
+}
+
+var _ = gc.Suite(&licenseSuite{})
+
+const testLicenseConfigurationARN = "arn:aws:license-manager:us-east-1:123456789012:license-configuration:lic-0123456789abcdef"
+
+func (s *licenseSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.targets = nil
+	s.bodies = nil
+	s.status = http.StatusOK
+	s.response = ""
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		c.Check(json.NewDecoder(r.Body).Decode(&body), jc.ErrorIsNil)
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.targets = append(s.targets, r.Header.Get("X-Amz-Target"))
+		s.bodies = append(s.bodies, body)
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.response)
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &licenseManagerClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "license-manager"),
+		target:   "AWSLicenseManager",
+	}}
+}
+
+func (s *licenseSuite) environ(c *gc.C, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg}
+}
+
+func (s *licenseSuite) TestGetLicenseConfiguration(c *gc.C) {
+	s.response = `{
+  "LicenseConfigurationArn": "` + testLicenseConfigurationARN + `",
+  "Name": "windows-server",
+  "Status": "AVAILABLE"
+}`
+	lc, err := s.client.GetLicenseConfiguration(testLicenseConfigurationARN)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(lc, jc.DeepEquals, &licenseConfiguration{
+		Arn:    testLicenseConfigurationARN,
+		Name:   "windows-server",
+		Status: "AVAILABLE",
+	})
+	c.Assert(s.targets, jc.DeepEquals, []string{"AWSLicenseManager.GetLicenseConfiguration"})
+	c.Assert(s.bodies, jc.DeepEquals, []map[string]string{{
+		"LicenseConfigurationArn": testLicenseConfigurationARN,
+	}})
+}
+
+func (s *licenseSuite) TestGetLicenseConfigurationNotFound(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "com.amazonaws.licensemanager#InvalidParameterValueException", "message": "not found"}`
+	_, err := s.client.GetLicenseConfiguration(testLicenseConfigurationARN)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *licenseSuite) TestGetLicenseConfigurationError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "AccessDeniedException", "message": "denied"}`
+	_, err := s.client.GetLicenseConfiguration(testLicenseConfigurationARN)
+	c.Assert(err, gc.ErrorMatches, `denied \(AccessDeniedException\)`)
+}
+
+func (s *licenseSuite) TestValidateLicenseConfiguration(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"license-configuration-arn": testLicenseConfigurationARN})
+	env.licenseManager = s.client
+
+	s.response = `{"LicenseConfigurationArn": "` + testLicenseConfigurationARN + `", "Status": "AVAILABLE"}`
+	c.Assert(env.validateLicenseConfiguration(), jc.ErrorIsNil)
+
+	s.response = `{"LicenseConfigurationArn": "` + testLicenseConfigurationARN + `", "Status": "DISABLED"}`
+	err := env.validateLicenseConfiguration()
+	c.Assert(err, gc.ErrorMatches, `license configuration ".*" is disabled`)
+
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "InvalidParameterValueException", "message": "not found"}`
+	err = env.validateLicenseConfiguration()
+	c.Assert(err, gc.ErrorMatches, `validating license-configuration-arn: license configuration ".*" not found`)
+}
+
+func (s *licenseSuite) TestValidateLicenseConfigurationUnset(c *gc.C) {
+	env := s.environ(c, nil)
+	env.licenseManager = s.client
+	c.Assert(env.validateLicenseConfiguration(), jc.ErrorIsNil)
+	c.Assert(s.targets, gc.HasLen, 0)
+}
+
+func (s *licenseSuite) params(env *environ) url.Values {
+	params := make(url.Values)
+	licenseParams(env.ecfg(), params)
+	return params
+}
+
+func (s *licenseSuite) TestLicenseParams(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"license-configuration-arn": testLicenseConfigurationARN})
+	c.Assert(s.params(env), jc.DeepEquals, url.Values{
+		"LicenseSpecification.1.LicenseConfigurationArn": {testLicenseConfigurationARN},
+	})
+}
+
+func (s *licenseSuite) TestLicenseParamsUnset(c *gc.C) {
+	env := s.environ(c, nil)
+	c.Assert(s.params(env), gc.HasLen, 0)
+}
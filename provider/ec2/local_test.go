@@ -5,6 +5,7 @@ package ec2_test
 
 import (
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
@@ -12,8 +13,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
+	jujutesting "github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
 	"github.com/juju/utils/arch"
@@ -30,6 +33,7 @@ import (
 
 	"github.com/juju/juju/cloud"
 	"github.com/juju/juju/constraints"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/environs/bootstrap"
 	"github.com/juju/juju/environs/imagemetadata"
@@ -47,6 +51,7 @@ import (
 	"github.com/juju/juju/network"
 	"github.com/juju/juju/provider/common"
 	"github.com/juju/juju/provider/ec2"
+	"github.com/juju/juju/provider/ec2/internal/ec2instancetypes"
 	"github.com/juju/juju/status"
 	"github.com/juju/juju/storage"
 	coretesting "github.com/juju/juju/testing"
@@ -352,6 +357,77 @@ func (t *localServerSuite) TestPrepareForBootstrapWithDefaultVPCID(c *gc.C) {
 	t.prepareWithParamsAndBootstrapWithVPCID(c, params, t.srv.defaultVPC.Id)
 }
 
+func (t *localServerSuite) TestPrepareForBootstrapDeletesOrphanedSecurityGroups(c *gc.C) {
+	params := t.PrepareParams(c)
+	modelUUID := params.ModelConfig["uuid"].(string)
+	// Leave behind the groups of a failed bootstrap: the Juju group,
+	// which was not tagged, and a tagged machine group.
+	_, err := t.client.CreateSecurityGroup("", "juju-"+modelUUID, "juju group")
+	c.Assert(err, jc.ErrorIsNil)
+	machineGroup, err := t.client.CreateSecurityGroup("", "juju-"+modelUUID+"-0", "juju group")
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = t.client.CreateTags([]string{machineGroup.SecurityGroup.Id}, []amzec2.Tag{{Key: tags.JujuModel, Value: modelUUID}})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = t.client.CreateSecurityGroup("", "other", "not juju")
+	c.Assert(err, jc.ErrorIsNil)
+
+	t.PrepareWithParams(c, params)
+	resp, err := t.client.SecurityGroups(nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	var groupNames []string
+	for _, g := range resp.Groups {
+		groupNames = append(groupNames, g.Name)
+	}
+	c.Assert(groupNames, jc.SameContents, []string{"default", "other"})
+}
+
+func (t *localServerSuite) TestPrepareForBootstrapWithBastion(c *gc.C) {
+	ids := t.srv.ec2srv.NewInstances(1, "m1.small", "ami-a7f539ce", ec2test.Running, nil)
+	resp, err := t.client.Instances(ids, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	bastion := resp.Reservations[0].Instances[0]
+	_, err = t.client.CreateTags(ids, []amzec2.Tag{{Key: "Role", Value: "bastion"}})
+	c.Assert(err, jc.ErrorIsNil)
+
+	for _, value := range []string{ids[0], "tag:Role=bastion"} {
+		c.Logf("bastion %q", value)
+		var dialed []string
+		t.PatchValue(ec2.DialBastion, func(addr string) error {
+			dialed = append(dialed, addr)
+			return nil
+		})
+		params := t.PrepareParams(c)
+		params.ModelConfig["bastion"] = value
+		env := t.PrepareWithParams(c, params)
+		c.Assert(dialed, jc.DeepEquals, []string{bastion.IPAddress})
+
+		addr, err := env.(environs.Bastioned).BastionAddress()
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(addr, gc.Equals, bastion.IPAddress)
+	}
+}
+
+func (t *localServerSuite) TestPrepareForBootstrapWithMissingBastion(c *gc.C) {
+	// The stopped instance added by the test server cannot be used.
+	resp, err := t.client.Instances(nil, makeFilter("instance-state-name", "stopped"))
+	c.Assert(err, jc.ErrorIsNil)
+	stopped := resp.Reservations[0].Instances[0].InstanceId
+
+	t.AssertPrepareFailsWithConfig(c, coretesting.Attrs{"bastion": stopped},
+		`validating bastion: running bastion "`+stopped+`" not found`)
+	t.AssertPrepareFailsWithConfig(c, coretesting.Attrs{"bastion": "tag:Role=bastion"},
+		`validating bastion: running bastion "tag:Role=bastion" not found`)
+}
+
+func (t *localServerSuite) TestPrepareForBootstrapWithUnreachableBastion(c *gc.C) {
+	ids := t.srv.ec2srv.NewInstances(1, "m1.small", "ami-a7f539ce", ec2test.Running, nil)
+	t.PatchValue(ec2.DialBastion, func(addr string) error {
+		return errors.New("connection refused")
+	})
+	t.AssertPrepareFailsWithConfig(c, coretesting.Attrs{"bastion": ids[0]},
+		`bastion .* is not reachable: connection refused`)
+}
+
 func (t *localServerSuite) TestSystemdBootstrapInstanceUserDataAndState(c *gc.C) {
 	env := t.Prepare(c)
 	err := bootstrap.Bootstrap(envtesting.BootstrapContext(c), env, bootstrap.BootstrapParams{
@@ -659,6 +735,23 @@ func (t *localServerSuite) TestDestroyControllerDestroysHostedModelResources(c *
 	c.Assert(volumeResults, gc.HasLen, 1)
 	c.Assert(volumeResults[0].Error, jc.ErrorIsNil)
 
+	// Create a second volume, and tag it to be preserved.
+	preservedResults, err := vs.CreateVolumes([]storage.VolumeParams{{
+		Tag:      names.NewVolumeTag("1"),
+		Size:     1024,
+		Provider: ec2.EBS_ProviderType,
+		ResourceTags: map[string]string{
+			tags.JujuController: t.ControllerUUID,
+			tags.JujuModel:      hostedModelUUID,
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(preservedResults, gc.HasLen, 1)
+	c.Assert(preservedResults[0].Error, jc.ErrorIsNil)
+	preservedVolumeId := preservedResults[0].Volume.VolumeId
+	_, err = t.client.CreateTags([]string{preservedVolumeId}, []amzec2.Tag{{Key: tags.JujuPreserve, Value: "true"}})
+	c.Assert(err, jc.ErrorIsNil)
+
 	assertInstances := func(expect ...instance.Id) {
 		insts, err := env.AllInstances()
 		c.Assert(err, jc.ErrorIsNil)
@@ -701,6 +794,16 @@ func (t *localServerSuite) TestDestroyControllerDestroysHostedModelResources(c *
 	assertInstances()
 	assertVolumes()
 	assertGroups("default")
+
+	// The volume tagged for preservation survives.
+	volumesResp, err := t.client.Volumes(nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	volumeIds := set.NewStrings()
+	for _, vol := range volumesResp.Volumes {
+		volumeIds.Add(vol.Id)
+	}
+	c.Assert(volumeIds.Contains(preservedVolumeId), jc.IsTrue)
+	c.Assert(volumeIds.Contains(volumeResults[0].Volume.VolumeId), jc.IsFalse)
 }
 
 func (t *localServerSuite) TestInstanceStatus(c *gc.C) {
@@ -715,6 +818,7 @@ func (t *localServerSuite) TestInstanceStatus(c *gc.C) {
 	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(inst.Status().Message, gc.Equals, "terminated")
+	c.Assert(inst.Status().Status, gc.Equals, status.Terminated)
 }
 
 func (t *localServerSuite) TestStartInstanceHardwareCharacteristics(c *gc.C) {
@@ -725,6 +829,22 @@ func (t *localServerSuite) TestStartInstanceHardwareCharacteristics(c *gc.C) {
 	c.Check(*hc.CpuCores, gc.Equals, uint64(1))
 }
 
+func (t *localServerSuite) TestStartInstanceAccelerator(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	_, hc := testing.AssertStartInstanceWithConstraints(
+		c, env, t.ControllerUUID, "1", constraints.MustParse("instance-type=g2.2xlarge accelerator=gpu"),
+	)
+	c.Assert(hc.Accelerator, gc.NotNil)
+	c.Check(*hc.Accelerator, gc.Equals, "nvidia-grid-k520")
+	c.Assert(hc.AcceleratorCount, gc.NotNil)
+	c.Check(*hc.AcceleratorCount, gc.Equals, uint64(1))
+
+	_, _, _, err := testing.StartInstanceWithConstraints(
+		env, t.ControllerUUID, "2", constraints.MustParse("instance-type=m4.large accelerator=gpu"),
+	)
+	c.Assert(err, gc.ErrorMatches, `.*no instance types in .* matching constraints "instance-type=m4.large accelerator=gpu"`)
+}
+
 func (t *localServerSuite) TestStartInstanceAvailZone(c *gc.C) {
 	inst, err := t.testStartInstanceAvailZone(c, "test-available")
 	c.Assert(err, jc.ErrorIsNil)
@@ -804,6 +924,83 @@ func (t *localServerSuite) TestStartInstanceVolumeAttachmentsAvailZonePlacementC
 	c.Assert(err, gc.ErrorMatches, `cannot create instance with placement "zone=test-available", as this will prevent attaching the requested EBS volumes in zone "volume-zone"`)
 }
 
+func (t *localServerSuite) TestStartInstanceVolumes(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Running)
+
+	args := environs.StartInstanceParams{
+		ControllerUUID: t.ControllerUUID,
+		StatusCallback: fakeCallback,
+		Volumes: []storage.VolumeParams{{
+			Tag:      names.NewVolumeTag("1/0"),
+			Size:     1024,
+			Provider: ec2.EBS_ProviderType,
+			ResourceTags: map[string]string{
+				tags.JujuController: t.ControllerUUID,
+				tags.JujuModel:      coretesting.ModelTag.Id(),
+			},
+			Attachment: &storage.VolumeAttachmentParams{
+				AttachmentParams: storage.AttachmentParams{
+					Provider: ec2.EBS_ProviderType,
+					Machine:  names.NewMachineTag("1"),
+				},
+				Volume: names.NewVolumeTag("1/0"),
+			},
+		}},
+	}
+	result, err := testing.StartInstanceWithParams(env, "1", args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result.Volumes, gc.HasLen, 1)
+	volumeId := result.Volumes[0].VolumeId
+	c.Assert(result.Volumes[0].Tag, gc.Equals, names.NewVolumeTag("1/0"))
+	c.Assert(result.VolumeAttachments, jc.DeepEquals, []storage.VolumeAttachment{{
+		names.NewVolumeTag("1/0"),
+		names.NewMachineTag("1"),
+		storage.VolumeAttachmentInfo{DeviceName: "xvdf"},
+	}})
+
+	// The volume is created alongside the instance, attached to it
+	// and tagged as belonging to the model.
+	inst := ec2.InstanceEC2(result.Instance)
+	resp, err := t.client.Volumes([]string{volumeId}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Volumes, gc.HasLen, 1)
+	c.Assert(resp.Volumes[0].AvailZone, gc.Equals, inst.AvailZone)
+	c.Assert(resp.Volumes[0].Attachments, gc.HasLen, 1)
+	c.Assert(resp.Volumes[0].Attachments[0].InstanceId, gc.Equals, inst.InstanceId)
+	c.Assert(resp.Volumes[0].Tags, jc.SameContents, []amzec2.Tag{
+		{"Name", "juju-sample-volume-1-0"},
+		{"juju-model-uuid", coretesting.ModelTag.Id()},
+		{"juju-controller-uuid", t.ControllerUUID},
+		{"juju-volume", "1/0"},
+	})
+	modelVolumes, err := ec2.AllModelVolumes(env)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(set.NewStrings(modelVolumes...).Contains(volumeId), jc.IsTrue)
+}
+
+func (t *localServerSuite) TestStartInstanceVolumesInvalid(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	args := environs.StartInstanceParams{
+		ControllerUUID: t.ControllerUUID,
+		StatusCallback: fakeCallback,
+		Volumes: []storage.VolumeParams{{
+			Tag:        names.NewVolumeTag("1/0"),
+			Size:       1024 * 1024 * 1024,
+			Provider:   ec2.EBS_ProviderType,
+			Attachment: &storage.VolumeAttachmentParams{},
+		}},
+	}
+	_, err := testing.StartInstanceWithParams(env, "1", args)
+	c.Assert(err, gc.ErrorMatches, `invalid parameters for volume 1/0: volume size 1048576 GiB exceeds the maximum of 1024 GiB`)
+
+	// Nothing is launched for invalid volumes.
+	insts, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 1)
+}
+
 func (t *localServerSuite) TestStartInstanceSubnet(c *gc.C) {
 	inst, err := t.testStartInstanceSubnet(c, "0.1.2.0/24")
 	c.Assert(err, jc.ErrorIsNil)
@@ -860,6 +1057,70 @@ func (t *localServerSuite) TestStartInstanceSubnetWrongVPC(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, `unknown placement directive: subnet=0.1.2.0/24`)
 }
 
+// addRoleSubnets adds a VPC with an available management subnet and
+// workload subnet in the given zones, returning the VPC and subnet IDs.
+func (t *localServerSuite) addRoleSubnets(c *gc.C, managementZone, workloadZone string) (vpcID, management, workload string) {
+	vpc := t.srv.ec2srv.AddVPC(amzec2.VPC{
+		CIDRBlock: "0.1.0.0/16",
+		IsDefault: true,
+	})
+	sub1, err := t.srv.ec2srv.AddSubnet(amzec2.Subnet{
+		VPCId:     vpc.Id,
+		CIDRBlock: "0.1.10.0/24",
+		AvailZone: managementZone,
+		State:     "available",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	sub2, err := t.srv.ec2srv.AddSubnet(amzec2.Subnet{
+		VPCId:     vpc.Id,
+		CIDRBlock: "0.1.11.0/24",
+		AvailZone: workloadZone,
+		State:     "available",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return vpc.Id, sub1.Id, sub2.Id
+}
+
+func (t *localServerSuite) TestStartInstanceRoleSubnets(c *gc.C) {
+	vpcID, management, workload := t.addRoleSubnets(c, "test-available", "test-available")
+	env := t.prepareAndBootstrapWithConfig(c, coretesting.Attrs{
+		"vpc-id":             vpcID,
+		"vpc-id-force":       true,
+		"controller-subnets": management,
+		"workload-subnets":   workload,
+	})
+
+	// The controller is started in the management subnet.
+	insts, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 1)
+	c.Check(ec2.InstanceEC2(insts[0]).SubnetId, gc.Equals, management)
+
+	// Other machines are started in the workload subnet.
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Check(ec2.InstanceEC2(inst).SubnetId, gc.Equals, workload)
+}
+
+func (t *localServerSuite) TestPrepareForBootstrapWithRoleSubnetsInDifferentZones(c *gc.C) {
+	vpcID, management, workload := t.addRoleSubnets(c, "test-available", "test-impaired")
+	t.AssertPrepareFailsWithConfig(c, coretesting.Attrs{
+		"vpc-id":             vpcID,
+		"vpc-id-force":       true,
+		"controller-subnets": management,
+		"workload-subnets":   workload,
+	}, `controller-subnets \(in test-available\) and workload-subnets \(in test-impaired\) share no availability zone`)
+}
+
+func (t *localServerSuite) TestPrepareForBootstrapWithUnknownRoleSubnet(c *gc.C) {
+	vpcID, management, _ := t.addRoleSubnets(c, "test-available", "test-available")
+	t.AssertPrepareFailsWithConfig(c, coretesting.Attrs{
+		"vpc-id":             vpcID,
+		"vpc-id-force":       true,
+		"controller-subnets": management,
+		"workload-subnets":   "subnet-missing",
+	}, `workload-subnets: subnet "subnet-missing" not found`)
+}
+
 func (t *localServerSuite) TestGetAvailabilityZones(c *gc.C) {
 	var resultZones []amzec2.AvailabilityZoneInfo
 	var resultErr error
@@ -1054,6 +1315,87 @@ func (t *localServerSuite) testStartInstanceAvailZoneAllConstrained(c *gc.C, run
 	c.Assert(azArgs, gc.DeepEquals, []string{"az1", "az2"})
 }
 
+func (t *localServerSuite) TestStartInstanceAvailZonePrefersOffered(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	mock := mockAvailabilityZoneAllocations{
+		result: []common.AvailabilityZoneInstances{
+			{ZoneName: "az1"}, {ZoneName: "az2"}, {ZoneName: "az3"},
+		},
+	}
+	t.PatchValue(ec2.AvailabilityZoneAllocations, mock.AvailabilityZoneAllocations)
+	ec2.SetInstanceTypeZones(env, map[string][]string{
+		"m4.large": {"az2", "az3"},
+	})
+
+	var azArgs []string
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, c environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		azArgs = append(azArgs, ri.AvailZone)
+		return nil, azConstrainedErr
+	})
+	_, _, _, err := testing.StartInstanceWithConstraints(
+		env, t.ControllerUUID, "1", constraints.MustParse("instance-type=m4.large"),
+	)
+	c.Assert(err, gc.ErrorMatches, "cannot run instances: .*")
+	// az1 does not offer m4.large, so it is only tried once the
+	// others have failed.
+	c.Assert(azArgs, gc.DeepEquals, []string{"az2", "az3", "az1"})
+}
+
+// bootstrapWithRunInstancesErrors bootstraps with the given config, failing
+// the first failures launches for lack of capacity, and returns the number
+// of launches attempted. test-available is the only available zone, so each
+// attempt launches once.
+func (t *localServerSuite) bootstrapWithRunInstancesErrors(c *gc.C, config coretesting.Attrs, failures int) (int, error) {
+	t.PatchValue(&ec2.BootstrapLaunchRetry.Delay, time.Millisecond)
+	var calls int
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		calls++
+		if calls <= failures {
+			return nil, azInsufficientInstanceCapacityErr
+		}
+		return realRunInstances(e, ri, callback)
+	})
+	args := t.PrepareParams(c)
+	args.ModelConfig = coretesting.Attrs(args.ModelConfig).Merge(config)
+	env := t.PrepareWithParams(c, args)
+	err := bootstrap.Bootstrap(envtesting.BootstrapContext(c), env, bootstrap.BootstrapParams{
+		ControllerConfig: coretesting.FakeControllerConfig(),
+		AdminSecret:      testing.AdminSecret,
+		CAPrivateKey:     coretesting.CAKey,
+	})
+	return calls, err
+}
+
+func (t *localServerSuite) TestBootstrapRetriesInsufficientCapacity(c *gc.C) {
+	calls, err := t.bootstrapWithRunInstancesErrors(c, coretesting.Attrs{
+		"bootstrap-launch-retries": 2,
+	}, 2)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calls, gc.Equals, 3)
+}
+
+func (t *localServerSuite) TestBootstrapRetriesExhausted(c *gc.C) {
+	calls, err := t.bootstrapWithRunInstancesErrors(c, coretesting.Attrs{
+		"bootstrap-launch-retries": 1,
+	}, 10)
+	c.Assert(err, gc.ErrorMatches, fmt.Sprintf(
+		".*cannot run instances: %s \\(%s\\)",
+		regexp.QuoteMeta(azInsufficientInstanceCapacityErr.Message),
+		azInsufficientInstanceCapacityErr.Code,
+	))
+	c.Assert(calls, gc.Equals, 2)
+}
+
+func (t *localServerSuite) TestBootstrapRetriesDisabled(c *gc.C) {
+	calls, err := t.bootstrapWithRunInstancesErrors(c, coretesting.Attrs{
+		"bootstrap-launch-retries": 0,
+	}, 1)
+	c.Assert(err, gc.ErrorMatches, ".*cannot run instances: .*")
+	c.Assert(calls, gc.Equals, 1)
+}
+
 // addTestingSubnets adds a testing default VPC with 3 subnets in the EC2 test
 // server: 2 of the subnets are in the "test-available" AZ, the remaining - in
 // "test-unavailable". Returns a slice with the IDs of the created subnets and
@@ -1228,9 +1570,176 @@ func (t *localServerSuite) testStartInstanceAvailZoneOneConstrained(c *gc.C, run
 	c.Check(*hwc.AvailabilityZone, gc.Equals, "az2")
 }
 
+func (t *localServerSuite) TestStartInstanceAvailZoneCapacityCooldown(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	mock := mockAvailabilityZoneAllocations{
+		result: []common.AvailabilityZoneInstances{
+			{ZoneName: "az1"}, {ZoneName: "az2"}, {ZoneName: "az3"},
+		},
+	}
+	t.PatchValue(ec2.AvailabilityZoneAllocations, mock.AvailabilityZoneAllocations)
+
+	// The first launch finds az1 out of capacity, and so allocates to
+	// az2. The next launch tries az1 last while it cools down.
+	var azArgs []string
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, c environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		azArgs = append(azArgs, ri.AvailZone)
+		if ri.AvailZone == "az1" {
+			return nil, azInsufficientInstanceCapacityErr
+		}
+		return realRunInstances(e, ri, fakeCallback)
+	})
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(azArgs, gc.DeepEquals, []string{"az1", "az2"})
+	c.Assert(ec2.InstanceEC2(inst).AvailZone, gc.Equals, "az2")
+
+	azArgs = nil
+	inst, _ = testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+	c.Assert(azArgs, gc.DeepEquals, []string{"az2"})
+	c.Assert(ec2.InstanceEC2(inst).AvailZone, gc.Equals, "az2")
+}
+
+func (t *localServerSuite) TestStartInstanceInstanceTypeFallback(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-type-fallback": "m4.large>m4.xlarge>m4.2xlarge",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	mock := mockAvailabilityZoneAllocations{
+		result: []common.AvailabilityZoneInstances{
+			{ZoneName: "az1"}, {ZoneName: "az2"},
+		},
+	}
+	t.PatchValue(ec2.AvailabilityZoneAllocations, mock.AvailabilityZoneAllocations)
+
+	// Neither zone has capacity for m4.large, so the next type in the
+	// ladder is tried, and started in the first zone.
+	var launches []string
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, c environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		launches = append(launches, ri.InstanceType+" "+ri.AvailZone)
+		if ri.InstanceType == "m4.large" {
+			return nil, azInsufficientInstanceCapacityErr
+		}
+		return realRunInstances(e, ri, fakeCallback)
+	})
+	inst, hwc, _, err := testing.StartInstanceWithConstraints(
+		env, t.ControllerUUID, "1", constraints.MustParse("instance-type=m4.large"),
+	)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(launches, gc.DeepEquals, []string{
+		"m4.large az1", "m4.large az2", "m4.xlarge az1",
+	})
+	c.Assert(ec2.InstanceEC2(inst).InstanceType, gc.Equals, "m4.xlarge")
+	c.Check(*hwc.Mem, gc.Equals, uint64(16384))
+	c.Check(*hwc.CpuCores, gc.Equals, uint64(4))
+}
+
+func (t *localServerSuite) TestStartInstanceInstanceTypeFallbackDisabled(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var launches []string
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, c environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		launches = append(launches, ri.InstanceType)
+		return nil, azInsufficientInstanceCapacityErr
+	})
+	_, _, _, err := testing.StartInstanceWithConstraints(
+		env, t.ControllerUUID, "1", constraints.MustParse("instance-type=m4.large"),
+	)
+	c.Assert(err, gc.ErrorMatches, "cannot run instances: .*")
+	for _, instanceType := range launches {
+		c.Assert(instanceType, gc.Equals, "m4.large")
+	}
+}
+
+func (t *localServerSuite) TestStartInstanceMaxInstanceSizeReject(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"max-instance-size": "cores=2 mem=8G",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, _, _, err = testing.StartInstanceWithConstraints(
+		env, t.ControllerUUID, "1", constraints.MustParse("instance-type=m4.2xlarge"),
+	)
+	c.Assert(err, gc.ErrorMatches, `instance type "m4.2xlarge" \(8 cores, 32768M memory\) exceeds max-instance-size "cores=2 mem=8192M"`)
+
+	// Constraints that ask for more are refused before any instance
+	// is started.
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("mem=16G"),
+	})
+	c.Assert(err, gc.ErrorMatches, `constraint mem=16384M exceeds max-instance-size "cores=2 mem=8192M"`)
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=m4.2xlarge"),
+	})
+	c.Assert(err, gc.ErrorMatches, `instance type "m4.2xlarge" \(8 cores, 32768M memory\) exceeds max-instance-size "cores=2 mem=8192M"`)
+}
+
+func (t *localServerSuite) TestStartInstanceMaxInstanceSizeDowngrade(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"max-instance-size":        "cores=2 mem=8G",
+		"max-instance-size-action": "downgrade",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	cons := constraints.MustParse("instance-type=m4.2xlarge")
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: cons,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	inst, hwc, _, err := testing.StartInstanceWithConstraints(env, t.ControllerUUID, "1", cons)
+	c.Assert(err, jc.ErrorIsNil)
+	// The largest instance type within max-instance-size is used.
+	c.Assert(ec2.InstanceEC2(inst).InstanceType, gc.Not(gc.Equals), "m4.2xlarge")
+	c.Check(*hwc.Mem, gc.Equals, uint64(8192))
+	c.Check(*hwc.CpuCores, gc.Equals, uint64(2))
+}
+
+func (t *localServerSuite) TestBootstrapMaxInstanceSizeControllerNotDowngraded(c *gc.C) {
+	env := t.Prepare(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"max-instance-size":        "mem=1G",
+		"max-instance-size-action": "downgrade",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = bootstrap.Bootstrap(envtesting.BootstrapContext(c), env, bootstrap.BootstrapParams{
+		ControllerConfig: coretesting.FakeControllerConfig(),
+		AdminSecret:      testing.AdminSecret,
+		CAPrivateKey:     coretesting.CAKey,
+	})
+	c.Assert(err, gc.ErrorMatches, `.*instance type ".*" \(.*\) exceeds max-instance-size "mem=1024M", `+
+		`and controller instances are only downgraded when max-instance-size-downgrade-controllers is true`)
+}
+
 func (t *localServerSuite) TestAddresses(c *gc.C) {
 	env := t.prepareAndBootstrap(c)
 	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	// The local server has no IPv6 support, so the instance's subnet is
+	// made dual-stack after it has started.
+	ec2Inst := ec2.InstanceEC2(inst)
+	c.Assert(ec2Inst.NetworkInterfaces, gc.HasLen, 1)
+	ec2.SetDualStack(env, map[string]string{
+		ec2Inst.SubnetId: "2600:1f18:1234:5600::/64",
+	}, inst.Id(), map[string][]string{
+		ec2Inst.NetworkInterfaces[0].Id: {"2600:1f18:1234:5600::5"},
+	})
 	addrs, err := inst.Addresses()
 	c.Assert(err, jc.ErrorIsNil)
 	// Expected values use Address type but really contain a regexp for
@@ -1243,6 +1752,10 @@ func (t *localServerSuite) TestAddresses(c *gc.C) {
 		Value: "127.0.0.*",
 		Type:  network.IPv4Address,
 		Scope: network.ScopeCloudLocal,
+	}, {
+		Value: "2600:1f18:1234:5600::5",
+		Type:  network.IPv6Address,
+		Scope: network.ScopePublic,
 	}}
 	c.Assert(addrs, gc.HasLen, len(expected))
 	for i, addr := range addrs {
@@ -1271,6 +1784,16 @@ func (t *localServerSuite) TestConstraintsValidatorVocab(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, "invalid constraint value: instance-type=foo\nvalid values are:.*")
 }
 
+func (t *localServerSuite) TestConstraintsValidatorVocabAccelerator(c *gc.C) {
+	env := t.Prepare(c)
+	validator, err := env.ConstraintsValidator()
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = validator.Validate(constraints.MustParse("accelerator=gpu"))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = validator.Validate(constraints.MustParse("accelerator=tpu"))
+	c.Assert(err, gc.ErrorMatches, "invalid constraint value: accelerator=tpu\nvalid values are:.*")
+}
+
 func (t *localServerSuite) TestConstraintsValidatorVocabNoDefaultOrSpecifiedVPC(c *gc.C) {
 	t.srv.defaultVPC.IsDefault = false
 	err := t.srv.ec2srv.UpdateVPC(*t.srv.defaultVPC)
@@ -1352,12 +1875,112 @@ func (t *localServerSuite) TestPrecheckInstanceUnsupportedArch(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, `invalid AWS instance type "cc1.4xlarge" and arch "i386" specified`)
 }
 
-func (t *localServerSuite) TestPrecheckInstanceAvailZone(c *gc.C) {
+func (t *localServerSuite) TestPrecheckInstanceEBSBaselineBandwidth(c *gc.C) {
+	t.TestConfig["ebs-baseline-bandwidth"] = 1000
+	defer delete(t.TestConfig, "ebs-baseline-bandwidth")
 	env := t.Prepare(c)
-	placement := "zone=test-available"
+
 	err := env.PrecheckInstance(environs.PrecheckInstanceParams{
-		Series:    series.LatestLts(),
-		Placement: placement,
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=m4.2xlarge"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=m4.large"),
+	})
+	c.Assert(err, gc.ErrorMatches, `instance type "m4.large" provides 450 Mbps of EBS bandwidth, less than the 1000 Mbps required by ebs-baseline-bandwidth`)
+
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=t2.medium"),
+	})
+	c.Assert(err, gc.ErrorMatches, `EBS optimization for instance type "t2.medium" not supported`)
+}
+
+func (t *localServerSuite) TestPrecheckInstanceInstanceStoreVolumes(c *gc.C) {
+	t.TestConfig["instance-store-volumes"] = 2
+	defer delete(t.TestConfig, "instance-store-volumes")
+	env := t.Prepare(c)
+
+	err := env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=d2.xlarge"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=m3.medium"),
+	})
+	c.Assert(err, gc.ErrorMatches, `instance type "m3.medium" has 1 instance store volumes, fewer than the 2 required by instance-store-volumes`)
+
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=m4.large"),
+	})
+	c.Assert(err, gc.ErrorMatches, `instance store volumes for EBS-only instance type "m4.large" not supported`)
+}
+
+func (t *localServerSuite) TestPrecheckInstanceAutoRecovery(c *gc.C) {
+	t.TestConfig["instance-auto-recovery"] = true
+	defer delete(t.TestConfig, "instance-auto-recovery")
+	env := t.Prepare(c)
+
+	err := env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=m4.large"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:      series.LatestLts(),
+		Constraints: constraints.MustParse("instance-type=d2.xlarge"),
+	})
+	c.Assert(err, gc.ErrorMatches, `automatic recovery of instance type "d2.xlarge" not supported`)
+}
+
+func (t *localServerSuite) TestPrecheckInstanceAccelerator(c *gc.C) {
+	env := t.Prepare(c)
+	for i, test := range []struct {
+		cons string
+		err  string
+	}{{
+		cons: "instance-type=p2.xlarge accelerator=gpu",
+	}, {
+		cons: "instance-type=p2.xlarge accelerator=nvidia-tesla-k80",
+	}, {
+		cons: "arch=amd64 accelerator=gpu",
+	}, {
+		cons: "instance-type=m4.large accelerator=gpu",
+		err:  `instance type "m4.large" has no accelerators, but accelerator "gpu" is required`,
+	}, {
+		cons: "instance-type=p2.xlarge accelerator=inferentia",
+		err:  `instance type "p2.xlarge" has gpu accelerators \(nvidia-tesla-k80\), but accelerator "inferentia" is required`,
+	}, {
+		cons: "arch=i386 accelerator=gpu",
+		err:  `no i386 instance types in region "test" have accelerator "gpu"`,
+	}} {
+		c.Logf("test %d: %s", i, test.cons)
+		err := env.PrecheckInstance(environs.PrecheckInstanceParams{
+			Series:      series.LatestLts(),
+			Constraints: constraints.MustParse(test.cons),
+		})
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
+func (t *localServerSuite) TestPrecheckInstanceAvailZone(c *gc.C) {
+	env := t.Prepare(c)
+	placement := "zone=test-available"
+	err := env.PrecheckInstance(environs.PrecheckInstanceParams{
+		Series:    series.LatestLts(),
+		Placement: placement,
 	})
 	c.Assert(err, jc.ErrorIsNil)
 }
@@ -1382,6 +2005,43 @@ func (t *localServerSuite) TestPrecheckInstanceAvailZoneUnknown(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, `invalid availability zone "test-unknown"`)
 }
 
+func (t *localServerSuite) TestPrecheckInstancePlacementGroup(c *gc.C) {
+	env := t.Prepare(c)
+	for i, test := range []struct {
+		placement string
+		cons      string
+		err       string
+	}{{
+		placement: "placement-group=hpc",
+		cons:      "instance-type=m4.large",
+	}, {
+		placement: "placement-group=hpc",
+		cons:      "instance-type=t2.medium",
+		err:       `placement group "hpc": burstable instance type "t2.medium" cannot be launched into a cluster placement group`,
+	}, {
+		placement: "placement-group=db,strategy=spread",
+		cons:      "instance-type=t2.medium",
+	}, {
+		placement: "placement-group=kafka,strategy=partition,partition=2",
+		cons:      "instance-type=t2.medium",
+	}, {
+		placement: "placement-group=hpc,partition=2",
+		err:       `placement group: a partition can only be given with strategy=partition`,
+	}} {
+		c.Logf("test %d: %s %s", i, test.placement, test.cons)
+		err := env.PrecheckInstance(environs.PrecheckInstanceParams{
+			Series:      series.LatestLts(),
+			Placement:   test.placement,
+			Constraints: constraints.MustParse(test.cons),
+		})
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
 func (t *localServerSuite) TestPrecheckInstanceVolumeAvailZoneNoPlacement(c *gc.C) {
 	t.testPrecheckInstanceVolumeAvailZone(c, "")
 }
@@ -1487,13 +2147,24 @@ func (t *localServerSuite) TestNetworkInterfaces(c *gc.C) {
 	env, instId := t.setUpInstanceWithDefaultVpc(c)
 	interfaces, err := env.NetworkInterfaces(instId)
 	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(interfaces, gc.HasLen, 1)
+
+	// The local server has no IPv6 support, so the instance's subnet is
+	// made dual-stack after it has started.
+	ec2.SetDualStack(env, map[string]string{
+		string(interfaces[0].ProviderSubnetId): "2600:1f18:1234:5600::/64",
+	}, instId, map[string][]string{
+		"eni-0": {"2600:1f18:1234:5600::5"},
+	})
+	interfaces, err = env.NetworkInterfaces(instId)
+	c.Assert(err, jc.ErrorIsNil)
 
 	// The CIDR isn't predictable, but it is in the 10.10.x.0/24 format
 	// The subnet ID is in the form "subnet-x", where x matches the same
 	// number from the CIDR. The interfaces address is part of the CIDR.
 	// For these reasons we check that the CIDR is in the expected format
 	// and derive the expected values for ProviderSubnetId and Address.
-	c.Assert(interfaces, gc.HasLen, 1)
+	c.Assert(interfaces, gc.HasLen, 2)
 	cidr := interfaces[0].CIDR
 	re := regexp.MustCompile(`10\.10\.(\d+)\.0/24`)
 	c.Assert(re.Match([]byte(cidr)), jc.IsTrue)
@@ -1523,6 +2194,20 @@ func (t *localServerSuite) TestNetworkInterfaces(c *gc.C) {
 		InterfaceType:     network.EthernetInterface,
 		Address:           network.NewScopedAddress(addr, network.ScopeCloudLocal),
 		AvailabilityZones: zones,
+	}, {
+		DeviceIndex:       0,
+		MACAddress:        "20:01:60:cb:27:37",
+		CIDR:              "2600:1f18:1234:5600::/64",
+		ProviderId:        "eni-0",
+		ProviderSubnetId:  subnetId,
+		VLANTag:           0,
+		InterfaceName:     "unsupported0",
+		Disabled:          false,
+		NoAutoStart:       false,
+		ConfigType:        network.ConfigDHCP,
+		InterfaceType:     network.EthernetInterface,
+		Address:           network.NewScopedAddress("2600:1f18:1234:5600::5", network.ScopePublic),
+		AvailabilityZones: zones,
 	}}
 	c.Assert(interfaces, jc.DeepEquals, expectedInterfaces)
 }
@@ -1674,15 +2359,605 @@ func (t *localServerSuite) TestRootDiskTags(c *gc.C) {
 	})
 }
 
+func (t *localServerSuite) TestControllerDefaultResourceTags(c *gc.C) {
+	args := t.PrepareParams(c)
+	args.ModelConfig = coretesting.Attrs(args.ModelConfig).Merge(coretesting.Attrs{
+		"resource-tags": "CostCentre=model",
+	})
+	env := t.PrepareWithParams(c, args)
+	controllerConfig := coretesting.FakeControllerConfig()
+	controllerConfig[controller.DefaultResourceTagsKey] = "Environment=prod CostCentre=controller"
+	err := bootstrap.Bootstrap(envtesting.BootstrapContext(c), env, bootstrap.BootstrapParams{
+		ControllerConfig: controllerConfig,
+		AdminSecret:      testing.AdminSecret,
+		CAPrivateKey:     coretesting.CAKey,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The instance, its root disk and its security groups all have
+	// the controller's default tags, beneath the model's tags.
+	ec2conn := ec2.EnvironEC2(env)
+	expectTags := func(resourceTags []amzec2.Tag) {
+		c.Check(resourceTags, jc.Contains, amzec2.Tag{"Environment", "prod"})
+		c.Check(resourceTags, jc.Contains, amzec2.Tag{"CostCentre", "model"})
+		c.Check(resourceTags, gc.Not(jc.Contains), amzec2.Tag{"CostCentre", "controller"})
+	}
+	modelFilter := makeFilter("tag:"+tags.JujuModel, coretesting.ModelTag.Id())
+
+	instResp, err := ec2conn.Instances(nil, modelFilter)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(instResp.Reservations, gc.HasLen, 1)
+	c.Assert(instResp.Reservations[0].Instances, gc.HasLen, 1)
+	expectTags(instResp.Reservations[0].Instances[0].Tags)
+
+	volResp, err := ec2conn.Volumes(nil, modelFilter)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(volResp.Volumes, gc.HasLen, 1)
+	expectTags(volResp.Volumes[0].Tags)
+
+	groupResp, err := ec2conn.SecurityGroups(nil, modelFilter)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupResp.Groups, gc.HasLen, 2)
+	for _, group := range groupResp.Groups {
+		expectTags(group.Tags)
+	}
+}
+
+func (t *localServerSuite) TestDataVolumeTags(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	instances, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(instances, gc.HasLen, 1)
+
+	ebsProvider, err := env.StorageProvider(ec2.EBS_ProviderType)
+	c.Assert(err, jc.ErrorIsNil)
+	vs, err := ebsProvider.VolumeSource(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	volumeResults, err := vs.CreateVolumes([]storage.VolumeParams{{
+		Tag:      names.NewVolumeTag("0/0"),
+		Size:     1024,
+		Provider: ec2.EBS_ProviderType,
+		ResourceTags: map[string]string{
+			tags.JujuController: t.ControllerUUID,
+			tags.JujuModel:      coretesting.ModelTag.Id(),
+		},
+		Attachment: &storage.VolumeAttachmentParams{
+			AttachmentParams: storage.AttachmentParams{
+				InstanceId: instances[0].Id(),
+			},
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(volumeResults, gc.HasLen, 1)
+	c.Assert(volumeResults[0].Error, jc.ErrorIsNil)
+
+	ec2conn := ec2.EnvironEC2(env)
+	resp, err := ec2conn.Volumes([]string{volumeResults[0].Volume.VolumeId}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Volumes, gc.HasLen, 1)
+	c.Assert(resp.Volumes[0].Tags, jc.SameContents, []amzec2.Tag{
+		{"Name", "juju-sample-volume-0-0"},
+		{"juju-model-uuid", coretesting.ModelTag.Id()},
+		{"juju-controller-uuid", t.ControllerUUID},
+		{"juju-volume", "0/0"},
+	})
+}
+
 func (s *localServerSuite) TestBootstrapInstanceConstraints(c *gc.C) {
+	var rootDisk amzec2.BlockDeviceMapping
+	realRunInstances := *ec2.RunInstances
+	s.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		rootDisk = ri.BlockDeviceMappings[0]
+		return realRunInstances(e, ri, callback)
+	})
 	env := s.prepareAndBootstrap(c)
 	inst, err := env.AllInstances()
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(inst, gc.HasLen, 1)
 	ec2inst := ec2.InstanceEC2(inst[0])
 	// Controllers should be started with a burstable
-	// instance if possible, and a 32 GiB disk.
+	// instance if possible, and a 32 GiB gp3 disk.
 	c.Assert(ec2inst.InstanceType, gc.Equals, "t2.medium")
+	c.Assert(rootDisk.VolumeSize, gc.Equals, int64(32))
+	c.Assert(rootDisk.VolumeType, gc.Equals, "gp3")
+}
+
+func (t *localServerSuite) TestStartInstanceDefaultRootVolumeType(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var volumeTypes []string
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		volumeTypes = append(volumeTypes, ri.BlockDeviceMappings[0].VolumeType)
+		return realRunInstances(e, ri, callback)
+	})
+
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"default-root-volume-type": "gp2",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	cfg, err = env.Config().Apply(map[string]interface{}{
+		"default-root-volume-type": "",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+
+	c.Assert(volumeTypes, jc.DeepEquals, []string{"gp2", ""})
+}
+
+func (t *localServerSuite) TestStartInstanceInstanceStoreVolumes(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var mappings []amzec2.BlockDeviceMapping
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		mappings = ri.BlockDeviceMappings
+		return realRunInstances(e, ri, callback)
+	})
+
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-store-volumes": 2,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(mappings, gc.HasLen, 3)
+	c.Assert(mappings[1:], jc.DeepEquals, []amzec2.BlockDeviceMapping{{
+		VirtualName: "ephemeral0",
+		DeviceName:  "/dev/sdb",
+	}, {
+		VirtualName: "ephemeral1",
+		DeviceName:  "/dev/sdc",
+	}})
+
+	// No instance stores are mapped for EBS-only instance types.
+	cfg, err = env.Config().Apply(map[string]interface{}{
+		"instance-store-volumes": 0,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstanceWithConstraints(c, env, t.ControllerUUID, "2", constraints.MustParse("instance-type=m4.large"))
+	c.Assert(mappings, gc.HasLen, 1)
+}
+
+func (t *localServerSuite) TestStartInstanceAutoRecovery(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var instanceTypes []string
+	var mappings []amzec2.BlockDeviceMapping
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		instanceTypes = append(instanceTypes, ri.InstanceType)
+		mappings = ri.BlockDeviceMappings
+		return realRunInstances(e, ri, callback)
+	})
+
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-auto-recovery": true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(instanceTypes, gc.HasLen, 1)
+	c.Assert(ec2instancetypes.SupportsAutoRecovery(instanceTypes[0]), jc.IsTrue)
+
+	// Instances that can be recovered have no instance store volumes.
+	testing.AssertStartInstanceWithConstraints(c, env, t.ControllerUUID, "2", constraints.MustParse("instance-type=m3.medium"))
+	c.Assert(mappings, gc.HasLen, 1)
+
+	_, _, _, err = testing.StartInstanceWithConstraints(env, t.ControllerUUID, "3", constraints.MustParse("instance-type=d2.xlarge"))
+	c.Assert(err, gc.ErrorMatches, `.*no instance types in .* matching constraints "instance-type=d2.xlarge"`)
+}
+
+func (t *localServerSuite) TestStartInstanceMaxInstances(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	// The controller is the model's first instance.
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"max-instances": 2,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	_, _, _, err = testing.StartInstance(env, t.ControllerUUID, "2")
+	c.Assert(err, gc.ErrorMatches, "model already has 2 instances, the maximum allowed by max-instances")
+
+	// Terminated instances are not counted.
+	err = env.StopInstances(inst.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+}
+
+func (t *localServerSuite) TestStartInstanceSecurityGroupUnauthorized(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	t.srv.proxy.ModifyResponse = func(resp *http.Response) error {
+		if resp.Request.URL.Query().Get("Action") != "CreateSecurityGroup" {
+			return nil
+		}
+		resp.StatusCode = http.StatusForbidden
+		return replaceResponseBody(resp, ec2Errors{[]amzec2.Error{{
+			Code:    "UnauthorizedOperation",
+			Message: "You are not authorized to perform this operation.",
+		}}})
+	}
+	_, _, _, err := testing.StartInstance(env, t.ControllerUUID, "1")
+	c.Assert(err, gc.ErrorMatches, `.*creating security group "juju-.*-1": `+
+		`the credential is not authorized to manage security groups; `+
+		`grant it the IAM permissions ec2:CreateSecurityGroup, ec2:DeleteSecurityGroup, `+
+		`ec2:DescribeSecurityGroups, ec2:AuthorizeSecurityGroupIngress, `+
+		`ec2:RevokeSecurityGroupIngress, ec2:CreateTags: `+
+		`.*UnauthorizedOperation.*`)
+}
+
+func (t *localServerSuite) TestStartInstanceShutdownBehavior(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var behaviors []string
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		behaviors = append(behaviors, ri.ShutdownBehavior)
+		return realRunInstances(e, ri, callback)
+	})
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-initiated-shutdown-behavior": "stop",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+	c.Assert(behaviors, jc.DeepEquals, []string{"terminate", "stop"})
+
+	// Instances that Juju stops are terminated, whatever the
+	// shutdown behavior.
+	err = env.StopInstances(inst.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	terminated, err := ec2.TerminatedInstances(env)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(terminated, gc.HasLen, 1)
+	c.Assert(terminated[0].Id(), gc.Equals, inst.Id())
+}
+
+func (t *localServerSuite) TestStartInstanceDetailedMonitoring(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var monitoring []bool
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		monitoring = append(monitoring, ri.Monitoring)
+		return realRunInstances(e, ri, callback)
+	})
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"detailed-monitoring": true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+	c.Assert(monitoring, jc.DeepEquals, []bool{false, true})
+}
+
+// runInstancesExtras returns the query of a RunInstances request signed
+// by the given client, which carries the parameters the EC2 client
+// library has no support for.
+func runInstancesExtras(c *gc.C, client *amzec2.EC2) url.Values {
+	req, err := http.NewRequest("GET", "https://ec2.us-east-1.amazonaws.com/?Action=RunInstances", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = client.Sign(req, aws.Auth{AccessKey: "access", SecretKey: "secret"})
+	c.Assert(err, jc.ErrorIsNil)
+	return req.URL.Query()
+}
+
+func (t *localServerSuite) TestStartInstanceHostPlacement(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	ec2.SetDedicatedHost(env, "h-0123456789abcdef0", "test-available", "m4.large")
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-auto-recovery": true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var extras url.Values
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		extras = runInstancesExtras(c, e)
+		return realRunInstances(e, ri, callback)
+	})
+	params := environs.StartInstanceParams{
+		ControllerUUID: t.ControllerUUID,
+		Placement:      "host=h-0123456789abcdef0,affinity=default",
+		StatusCallback: fakeCallback,
+	}
+	_, err = testing.StartInstanceWithParams(env, "1", params)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The host placement is added to the request along with the
+	// model's parameters and the launch's ClientToken.
+	c.Assert(extras.Get("Placement.Tenancy"), gc.Equals, "host")
+	c.Assert(extras.Get("Placement.HostId"), gc.Equals, "h-0123456789abcdef0")
+	c.Assert(extras.Get("Placement.Affinity"), gc.Equals, "default")
+	c.Assert(extras.Get("MaintenanceOptions.AutoRecovery"), gc.Equals, "default")
+	c.Assert(extras.Get("ClientToken"), gc.Not(gc.Equals), "")
+	c.Assert(extras.Get("Version"), gc.Equals, "2016-11-15")
+}
+
+func (t *localServerSuite) TestStartInstancePlacementGroup(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-auto-recovery": true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var extras url.Values
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, callback environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+		extras = runInstancesExtras(c, e)
+		return realRunInstances(e, ri, callback)
+	})
+	params := environs.StartInstanceParams{
+		ControllerUUID: t.ControllerUUID,
+		Placement:      "placement-group=kafka,strategy=partition,partition=2",
+		Constraints:    constraints.MustParse("instance-type=m4.large"),
+		StatusCallback: fakeCallback,
+	}
+	_, err = testing.StartInstanceWithParams(env, "1", params)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The placement group is added to the request along with the
+	// model's parameters and the launch's ClientToken, none of which
+	// replaces another.
+	c.Assert(extras.Get("Placement.GroupName"), gc.Equals, "kafka")
+	c.Assert(extras.Get("Placement.PartitionNumber"), gc.Equals, "2")
+	c.Assert(extras.Get("MaintenanceOptions.AutoRecovery"), gc.Equals, "default")
+	c.Assert(extras.Get("ClientToken"), gc.Not(gc.Equals), "")
+	c.Assert(extras.Get("Version"), gc.Equals, "2016-11-15")
+}
+
+func (t *localServerSuite) TestStartInstanceBootTimeout(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.PatchValue(ec2.InstanceBootPollDelay, time.Millisecond)
+
+	// New instances are left pending by the local server.
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"instance-boot-timeout": "50ms",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	_, _, _, err = testing.StartInstance(env, t.ControllerUUID, "1")
+	c.Assert(err, gc.ErrorMatches, `.*instance i-\w+ failed to reach running within 50ms; last state pending`)
+
+	// The stuck instance is terminated, so it is not leaked.
+	terminated, err := ec2.TerminatedInstances(env)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(terminated, gc.HasLen, 1)
+	insts, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 1)
+
+	// Unless it is to be left for investigation.
+	cfg, err = env.Config().Apply(map[string]interface{}{
+		"terminate-stuck-instances": false,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	_, _, _, err = testing.StartInstance(env, t.ControllerUUID, "2")
+	c.Assert(err, gc.ErrorMatches, `.*instance i-\w+ failed to reach running within 50ms; last state pending`)
+	insts, err = env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 2)
+
+	// Instances that reach running in time are started.
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Running)
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "3")
+}
+
+func (t *localServerSuite) TestInstancePortsSplitAcrossGroups(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{
+		"max-rules-per-security-group": 2,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = env.SetConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var attached [][]string
+	ec2.SetInstanceGroupsFunc(env, func(instId string, groupIds []string) error {
+		attached = append(attached, groupIds)
+		return nil
+	})
+	testClock := jujutesting.NewClock(time.Time{})
+	ec2.SetClock(env, testClock)
+	var deleteClocks []clock.Clock
+	t.BaseSuite.PatchValue(ec2.DeleteSecurityGroupInsistently, func(
+		inst ec2.SecurityGroupCleaner, group amzec2.SecurityGroup, clk clock.Clock,
+	) error {
+		deleteClocks = append(deleteClocks, clk)
+		return deleteSecurityGroupForTestFunc(inst, group, clk)
+	})
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	fwInst := inst.(instance.InstanceFirewaller)
+	machineGroup := ec2.MachineGroupName(env, "1")
+	groupIPs := func(name string) []string {
+		resp, err := t.client.SecurityGroups(amzec2.SecurityGroupNames(name), nil)
+		if err, ok := err.(*amzec2.Error); ok && err.Code == "InvalidGroup.NotFound" {
+			return nil
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(resp.Groups, gc.HasLen, 1)
+		ips := []string{}
+		for _, p := range resp.Groups[0].IPPerms {
+			for _, ip := range p.SourceIPs {
+				ips = append(ips, fmt.Sprintf("%d/%s", p.FromPort, ip))
+			}
+		}
+		return ips
+	}
+
+	// Rules beyond the limit are put in overflow groups, which are
+	// attached to the instance.
+	err = fwInst.OpenPorts("1", []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"),
+		network.MustNewIngressRule("tcp", 443, 443, "10.0.0.0/24", "10.0.1.0/24"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupIPs(machineGroup), jc.SameContents, []string{"80/10.0.0.0/24", "80/10.0.1.0/24"})
+	c.Assert(groupIPs(machineGroup+"-1"), jc.SameContents, []string{"80/10.0.2.0/24", "443/10.0.0.0/24"})
+	c.Assert(groupIPs(machineGroup+"-2"), jc.SameContents, []string{"443/10.0.1.0/24"})
+	c.Assert(attached, gc.HasLen, 2)
+
+	rules, err := fwInst.IngressRules("1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rules, gc.HasLen, 2)
+	c.Assert(rules[0].PortRange, gc.Equals, network.PortRange{Protocol: "tcp", FromPort: 80, ToPort: 80})
+	c.Assert(rules[0].SourceCIDRs, jc.SameContents, []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"})
+	c.Assert(rules[1].PortRange, gc.Equals, network.PortRange{Protocol: "tcp", FromPort: 443, ToPort: 443})
+	c.Assert(rules[1].SourceCIDRs, jc.SameContents, []string{"10.0.0.0/24", "10.0.1.0/24"})
+
+	// Overflow groups are removed once their rules fit in the others.
+	err = fwInst.ClosePorts("1", []network.IngressRule{
+		network.MustNewIngressRule("tcp", 443, 443, "10.0.0.0/24", "10.0.1.0/24"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupIPs(machineGroup+"-1"), jc.SameContents, []string{"80/10.0.2.0/24"})
+	c.Assert(groupIPs(machineGroup+"-2"), gc.IsNil)
+	c.Assert(attached, gc.HasLen, 3)
+	c.Assert(deleteClocks, jc.DeepEquals, []clock.Clock{testClock})
+
+	err = fwInst.ClosePorts("1", []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/24"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupIPs(machineGroup), jc.SameContents, []string{"80/10.0.1.0/24", "80/10.0.2.0/24"})
+	c.Assert(groupIPs(machineGroup+"-1"), gc.IsNil)
+	c.Assert(attached, gc.HasLen, 4)
+
+	// Rules that would not fit even when split are refused.
+	var cidrs []string
+	for i := 0; i < 7; i++ {
+		cidrs = append(cidrs, fmt.Sprintf("10.1.%d.0/24", i))
+	}
+	err = fwInst.OpenPorts("1", []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, cidrs...),
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot open ports for machine 1: 9 ingress rules do not fit in 4 security groups of at most 2 rules \(max-rules-per-security-group\)`)
+}
+
+func (t *localServerSuite) TestRestrictControllerPorts(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	fw, ok := env.(environs.ControllerPortsFirewaller)
+	c.Assert(ok, jc.IsTrue)
+	groupIPs := func() []string {
+		resp, err := t.client.SecurityGroups(amzec2.SecurityGroupNames(ec2.JujuGroupName(env)), nil)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(resp.Groups, gc.HasLen, 1)
+		ips := []string{}
+		for _, p := range resp.Groups[0].IPPerms {
+			if p.FromPort != 22 && p.FromPort != 17777 {
+				continue
+			}
+			for _, ip := range p.SourceIPs {
+				ips = append(ips, fmt.Sprintf("%d/%s", p.FromPort, ip))
+			}
+		}
+		return ips
+	}
+	ports := []network.PortRange{
+		{Protocol: "tcp", FromPort: 22, ToPort: 22},
+		{Protocol: "tcp", FromPort: 17777, ToPort: 17777},
+	}
+
+	// The ports are open to all sources once bootstrapped.
+	c.Assert(groupIPs(), jc.SameContents, []string{"22/0.0.0.0/0", "17777/0.0.0.0/0"})
+
+	err := fw.RestrictControllerPorts(ports, []string{"10.0.0.0/8", "192.168.0.0/16"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupIPs(), jc.SameContents, []string{
+		"22/10.0.0.0/8", "22/192.168.0.0/16", "17777/10.0.0.0/8", "17777/192.168.0.0/16",
+	})
+
+	err = fw.RestrictControllerPorts(ports, []string{"10.0.0.0/8"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupIPs(), jc.SameContents, []string{"22/10.0.0.0/8", "17777/10.0.0.0/8"})
+
+	// Without sources, the ports are open to all of them again.
+	err = fw.RestrictControllerPorts(ports, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupIPs(), jc.SameContents, []string{"22/0.0.0.0/0", "17777/0.0.0.0/0"})
+}
+
+func (t *localServerSuite) TestInstancePortsPreexistingICMPRule(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	fwInst := inst.(instance.InstanceFirewaller)
+	groupPerms := func(name string) []amzec2.IPPerm {
+		resp, err := t.client.SecurityGroups(amzec2.SecurityGroupNames(name), nil)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(resp.Groups, gc.HasLen, 1)
+		return resp.Groups[0].IPPerms
+	}
+	icmpPerm := amzec2.IPPerm{
+		Protocol:  "icmp",
+		FromPort:  -1,
+		ToPort:    -1,
+		SourceIPs: []string{"10.0.0.0/8"},
+	}
+
+	// Both the machine's group and the default group already allow ICMP.
+	for _, name := range []string{ec2.MachineGroupName(env, "1"), "default"} {
+		resp, err := t.client.SecurityGroups(amzec2.SecurityGroupNames(name), nil)
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = t.client.AuthorizeSecurityGroup(resp.Groups[0].SecurityGroup, []amzec2.IPPerm{icmpPerm})
+		c.Assert(err, jc.ErrorIsNil)
+	}
+
+	// The ICMP rule is reported alongside the ports opened.
+	err := fwInst.OpenPorts("1", []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/8"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	rules, err := fwInst.IngressRules("1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rules, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("icmp", -1, -1, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/8"),
+	})
+
+	// Closing the reported rule revokes it, leaving the ports open.
+	err = fwInst.ClosePorts("1", rules[:1])
+	c.Assert(err, jc.ErrorIsNil)
+	perms := groupPerms(ec2.MachineGroupName(env, "1"))
+	c.Assert(perms, gc.HasLen, 1)
+	c.Assert(perms[0].Protocol, gc.Equals, "tcp")
+
+	// Destroying the model leaves the default group and its rules alone.
+	err = env.DestroyController(t.ControllerUUID)
+	c.Assert(err, jc.ErrorIsNil)
+	groupsResp, err := t.client.SecurityGroups(nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(groupsResp.Groups, gc.HasLen, 1)
+	c.Assert(groupsResp.Groups[0].Name, gc.Equals, "default")
+	c.Assert(groupPerms("default"), jc.DeepEquals, []amzec2.IPPerm{icmpPerm})
 }
 
 func makeFilter(key string, values ...string) *amzec2.Filter {
@@ -1722,23 +2997,30 @@ func (s *localServerSuite) TestAdoptResources(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	vs, err := ebsProvider.VolumeSource(nil)
 	c.Assert(err, jc.ErrorIsNil)
-	volumeResults, err := vs.CreateVolumes([]storage.VolumeParams{{
-		Tag:      names.NewVolumeTag("0"),
-		Size:     1024,
-		Provider: ec2.EBS_ProviderType,
-		ResourceTags: map[string]string{
-			tags.JujuController: s.ControllerUUID,
-			tags.JujuModel:      hostedModelUUID,
-		},
-		Attachment: &storage.VolumeAttachmentParams{
-			AttachmentParams: storage.AttachmentParams{
-				InstanceId: inst.Id(),
+	// Create enough volumes that tagging them takes several batches.
+	var volumeParams []storage.VolumeParams
+	for i := 0; i < 4; i++ {
+		volumeParams = append(volumeParams, storage.VolumeParams{
+			Tag:      names.NewVolumeTag(strconv.Itoa(i)),
+			Size:     1024,
+			Provider: ec2.EBS_ProviderType,
+			ResourceTags: map[string]string{
+				tags.JujuController: s.ControllerUUID,
+				tags.JujuModel:      hostedModelUUID,
 			},
-		},
-	}})
+			Attachment: &storage.VolumeAttachmentParams{
+				AttachmentParams: storage.AttachmentParams{
+					InstanceId: inst.Id(),
+				},
+			},
+		})
+	}
+	volumeResults, err := vs.CreateVolumes(volumeParams)
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(volumeResults, gc.HasLen, 1)
-	c.Assert(volumeResults[0].Error, jc.ErrorIsNil)
+	c.Assert(volumeResults, gc.HasLen, 4)
+	for _, result := range volumeResults {
+		c.Assert(result.Error, jc.ErrorIsNil)
+	}
 
 	modelVolumes, err := ec2.AllModelVolumes(env)
 	c.Assert(err, jc.ErrorIsNil)
@@ -1793,8 +3075,40 @@ func (s *localServerSuite) TestAdoptResources(c *gc.C) {
 	checkVolumeTags(origController, allVolumes...)
 	checkGroupTags(origController, allGroups...)
 
+	// The first batch is throttled and retried, and the second fails,
+	// leaving the remaining resources untagged.
+	s.PatchValue(ec2.TagResourcesBatchSize, 3)
+	s.PatchValue(&ec2.TagResourcesRetry.Delay, time.Millisecond)
+	tagger := &fakeResourceTagger{errs: []error{
+		&amzec2.Error{Code: "RequestLimitExceeded"},
+		nil,
+		&amzec2.Error{Code: "UnauthorizedOperation", Message: "denied"},
+	}}
+	s.PatchValue(ec2.NewResourceTagger, ec2.WrapResourceTagger(func(client *amzec2.EC2) ec2.ResourceTagger {
+		tagger.ResourceTagger = client
+		return tagger
+	}))
+	numResources := 1 + len(modelVolumes) + len(modelGroups)
+	err = env.AdoptResources("new-controller", version.MustParse("0.0.1"))
+	c.Assert(err, gc.ErrorMatches, fmt.Sprintf(
+		`updating tags: tagged 3 of %d resources: denied \(UnauthorizedOperation\)`, numResources,
+	))
+	c.Assert(tagger.batches, gc.HasLen, 3)
+	c.Assert(tagger.batches[0], gc.HasLen, 3)
+	c.Assert(tagger.batches[1], jc.DeepEquals, tagger.batches[0])
+	tagged := set.NewStrings(tagger.batches[0]...)
+
+	// Adopting again tags only the resources that were left untagged.
+	tagger.batches = nil
 	err = env.AdoptResources("new-controller", version.MustParse("0.0.1"))
 	c.Assert(err, jc.ErrorIsNil)
+	retagged := set.NewStrings()
+	for _, batch := range tagger.batches {
+		c.Assert(len(batch) <= 3, jc.IsTrue)
+		retagged = retagged.Union(set.NewStrings(batch...))
+	}
+	c.Assert(retagged.Size(), gc.Equals, numResources-3)
+	c.Assert(retagged.Intersection(tagged).IsEmpty(), jc.IsTrue)
 
 	checkInstanceTags("new-controller", string(inst.Id()))
 	checkInstanceTags(origController, string(controllerInsts[0].Id()))
@@ -1804,6 +3118,90 @@ func (s *localServerSuite) TestAdoptResources(c *gc.C) {
 	checkGroupTags(origController, controllerGroups...)
 }
 
+// fakeResourceTagger records the resources tagged by each call to
+// CreateTags, failing calls with the given errors in turn.
+type fakeResourceTagger struct {
+	ec2.ResourceTagger
+	batches [][]string
+	errs    []error
+}
+
+func (t *fakeResourceTagger) CreateTags(resourceIds []string, tags []amzec2.Tag) (*amzec2.SimpleResp, error) {
+	t.batches = append(t.batches, resourceIds)
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.ResourceTagger.CreateTags(resourceIds, tags)
+}
+
+func (s *localServerSuite) TestTagFilters(c *gc.C) {
+	controllerEnv := s.prepareAndBootstrap(c)
+	controllerInsts, err := controllerEnv.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(controllerInsts, gc.HasLen, 1)
+
+	hostedModelUUID := "7e386e08-cba7-44a4-a76e-7c1633584210"
+	s.srv.ec2srv.SetInitialInstanceState(ec2test.Running)
+	cfg, err := controllerEnv.Config().Apply(map[string]interface{}{
+		"uuid":          hostedModelUUID,
+		"firewall-mode": "global",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	env, err := environs.New(environs.OpenParams{
+		Cloud:  s.CloudSpec(),
+		Config: cfg,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	inst, _ := testing.AssertStartInstance(c, env, s.ControllerUUID, "0")
+
+	ec2conn := ec2.EnvironEC2(env)
+	checkInstances := func(tagValues map[string]string, expectedIds ...string) {
+		filter := amzec2.NewFilter()
+		ec2.AddTagFilters(filter, tagValues)
+		resp, err := ec2conn.Instances(nil, filter)
+		c.Assert(err, jc.ErrorIsNil)
+		actualIds := set.NewStrings()
+		for _, reservation := range resp.Reservations {
+			for _, instance := range reservation.Instances {
+				actualIds.Add(instance.InstanceId)
+			}
+		}
+		c.Check(actualIds, gc.DeepEquals, set.NewStrings(expectedIds...))
+	}
+
+	// The filter built from several tags is the same as filtering on
+	// each of them in turn.
+	filter := amzec2.NewFilter()
+	ec2.AddTagFilters(filter, map[string]string{
+		tags.JujuModel:      hostedModelUUID,
+		tags.JujuController: s.ControllerUUID,
+		tags.JujuMachine:    "",
+	})
+	expect := makeFilter("tag:"+tags.JujuController, s.ControllerUUID)
+	expect.Add("tag:"+tags.JujuModel, hostedModelUUID)
+	c.Assert(filter, jc.DeepEquals, expect)
+
+	checkInstances(map[string]string{
+		tags.JujuController: s.ControllerUUID,
+	}, string(inst.Id()), string(controllerInsts[0].Id()))
+	checkInstances(map[string]string{
+		tags.JujuController: s.ControllerUUID,
+		tags.JujuModel:      hostedModelUUID,
+	}, string(inst.Id()))
+	checkInstances(map[string]string{
+		tags.JujuController:   s.ControllerUUID,
+		tags.JujuIsController: "true",
+	}, string(controllerInsts[0].Id()))
+	checkInstances(map[string]string{
+		tags.JujuController: "other-controller",
+		tags.JujuModel:      hostedModelUUID,
+	})
+}
+
 // localNonUSEastSuite is similar to localServerSuite but the S3 mock server
 // behaves as if it is not in the us-east region.
 type localNonUSEastSuite struct {
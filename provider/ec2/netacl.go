@@ -0,0 +1,213 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+
+	"github.com/juju/juju/controller"
+	"github.com/juju/juju/environs"
+)
+
+const (
+	// networkACLProtocolAll and networkACLProtocolTCP are the protocol
+	// numbers of network ACL entries that match all protocols and TCP.
+	networkACLProtocolAll = "-1"
+	networkACLProtocolTCP = "6"
+
+	networkACLActionAllow = "allow"
+	networkACLActionDeny  = "deny"
+)
+
+// networkACLAPI is the subset of the EC2 API used to describe the
+// network ACLs of a VPC, which the EC2 client library has no support
+// for.
+type networkACLAPI interface {
+	// NetworkACLs returns the network ACLs of the given VPC, with
+	// their entries and the subnets associated with them.
+	NetworkACLs(vpcID string) ([]networkACL, error)
+}
+
+// networkACL is a network ACL of a VPC.
+type networkACL struct {
+	Id        string            `xml:"networkAclId"`
+	SubnetIds []string          `xml:"associationSet>item>subnetId"`
+	Entries   []networkACLEntry `xml:"entrySet>item"`
+}
+
+// networkACLEntry is a rule of a network ACL. Entries are evaluated in
+// increasing order of rule number, and the first that matches traffic
+// decides whether it is allowed.
+type networkACLEntry struct {
+	RuleNumber int    `xml:"ruleNumber"`
+	Protocol   string `xml:"protocol"`
+	RuleAction string `xml:"ruleAction"`
+	Egress     bool   `xml:"egress"`
+	CIDRBlock  string `xml:"cidrBlock"`
+	// PortRange is nil for entries matching all ports.
+	PortRange *networkACLPortRange `xml:"portRange"`
+}
+
+// networkACLPortRange is the range of ports matched by a network ACL
+// entry.
+type networkACLPortRange struct {
+	From int `xml:"from"`
+	To   int `xml:"to"`
+}
+
+// newNetworkACLAPI returns a networkACLAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newNetworkACLAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) networkACLAPI {
+	return &networkACLClient{newEC2QueryClient(cloud, wrapSigner)}
+}
+
+// networkACLClient is a minimal client for the EC2 query API, used to
+// describe network ACLs.
+type networkACLClient struct {
+	*privateDNSClient
+}
+
+// NetworkACLs is part of the networkACLAPI interface.
+func (c *networkACLClient) NetworkACLs(vpcID string) ([]networkACL, error) {
+	var acls []networkACL
+	var nextToken string
+	for {
+		params := url.Values{
+			"Filter.1.Name":    {"vpc-id"},
+			"Filter.1.Value.1": {vpcID},
+		}
+		if nextToken != "" {
+			params.Set("NextToken", nextToken)
+		}
+		var resp struct {
+			ACLs      []networkACL `xml:"networkAclSet>item"`
+			NextToken string       `xml:"nextToken"`
+		}
+		if err := c.query("DescribeNetworkAcls", params, &resp); err != nil {
+			return nil, errors.Annotatef(err, "describing network ACLs of VPC %q", vpcID)
+		}
+		acls = append(acls, resp.ACLs...)
+		if resp.NextToken == "" {
+			return acls, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// networkACLAPIClient is implemented by VPC API clients that can also
+// describe the network ACLs of a VPC.
+type networkACLAPIClient interface {
+	vpcAPIClient
+
+	// NetworkACLs returns the network ACLs of the given VPC.
+	NetworkACLs(vpcID string) ([]networkACL, error)
+}
+
+// NetworkACLs is part of the networkACLAPIClient interface.
+func (c vpcValidationClient) NetworkACLs(vpcID string) ([]networkACL, error) {
+	return c.env.networkACLs.NetworkACLs(vpcID)
+}
+
+// controllerEssentialPorts returns the TCP ports on which a controller
+// must be reachable from clients: SSH, and the default API port, as the
+// API port chosen for a controller is not known when its VPC is
+// validated.
+func controllerEssentialPorts() []int {
+	return []int{22, controller.DefaultAPIPort}
+}
+
+// checkSubnetNetworkACLs returns an error satisfying
+// isVPCNotRecommendedError() if the network ACL of any of the given
+// public subnets, in which a controller could be started, appears to
+// block inbound traffic to the controller's essential ports from every
+// source. This otherwise shows up as a timeout connecting to the new
+// controller. If the network ACLs cannot be described, a warning is
+// logged and nothing is checked.
+func checkSubnetNetworkACLs(apiClient networkACLAPIClient, vpcID string, subnets []ec2.Subnet) error {
+	acls, err := apiClient.NetworkACLs(vpcID)
+	if err != nil {
+		logger.Warningf("cannot check the network ACLs of VPC %q: %v", vpcID, err)
+		return nil
+	}
+	bySubnet := make(map[string]*networkACL)
+	for i, acl := range acls {
+		for _, subnetID := range acl.SubnetIds {
+			bySubnet[subnetID] = &acls[i]
+		}
+	}
+	var problems []string
+	for _, subnet := range subnets {
+		if !subnet.MapPublicIPOnLaunch {
+			continue
+		}
+		acl, ok := bySubnet[subnet.Id]
+		if !ok {
+			continue
+		}
+		var blocked []string
+		for _, port := range controllerEssentialPorts() {
+			if networkACLBlocksInboundTCP(acl.Entries, port) {
+				blocked = append(blocked, fmt.Sprint(port))
+			}
+		}
+		if len(blocked) > 0 {
+			problems = append(problems, fmt.Sprintf(
+				"network ACL %q of subnet %q appears to block inbound TCP port(s) %s",
+				acl.Id, subnet.Id, strings.Join(blocked, ", "),
+			))
+		}
+	}
+	if len(problems) > 0 {
+		return vpcNotRecommendedf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// networkACLBlocksInboundTCP reports whether the given network ACL
+// entries block inbound TCP traffic to the given port from every
+// source. Traffic is taken to be allowed if any entry allows it from
+// some source before an entry denies it from all sources, as the
+// addresses of the controller's clients are not known.
+func networkACLBlocksInboundTCP(entries []networkACLEntry, port int) bool {
+	var inbound []networkACLEntry
+	for _, entry := range entries {
+		// Only IPv4 entries, which have a CIDR block, are relevant,
+		// as controllers are reached over IPv4.
+		if !entry.Egress && entry.CIDRBlock != "" {
+			inbound = append(inbound, entry)
+		}
+	}
+	sort.Slice(inbound, func(i, j int) bool {
+		return inbound[i].RuleNumber < inbound[j].RuleNumber
+	})
+	for _, entry := range inbound {
+		switch entry.Protocol {
+		case networkACLProtocolAll:
+		case networkACLProtocolTCP:
+			if entry.PortRange != nil && (port < entry.PortRange.From || port > entry.PortRange.To) {
+				continue
+			}
+		default:
+			continue
+		}
+		switch entry.RuleAction {
+		case networkACLActionAllow:
+			return false
+		case networkACLActionDeny:
+			if entry.CIDRBlock == defaultRouteCIDRBlock {
+				return true
+			}
+		}
+	}
+	// Traffic matching no entry is denied.
+	return true
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type networkACLSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	responses []string
+	client    *networkACLClient
+}
+
+var _ = gc.Suite(&networkACLSuite{})
+
+func (s *networkACLSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.URL.Query())
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &networkACLClient{&privateDNSClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  privateDNSAPIVersion,
+	}}}
+}
+
+func (s *networkACLSuite) TestNetworkACLs(c *gc.C) {
+	s.responses = []string{`
+<DescribeNetworkAclsResponse>
+  <networkAclSet>
+    <item>
+      <networkAclId>acl-5566953c</networkAclId>
+      <vpcId>vpc-5266953b</vpcId>
+      <default>true</default>
+      <entrySet>
+        <item>
+          <ruleNumber>100</ruleNumber>
+          <protocol>6</protocol>
+          <ruleAction>allow</ruleAction>
+          <egress>false</egress>
+          <cidrBlock>0.0.0.0/0</cidrBlock>
+          <portRange>
+            <from>22</from>
+            <to>22</to>
+          </portRange>
+        </item>
+        <item>
+          <ruleNumber>100</ruleNumber>
+          <protocol>-1</protocol>
+          <ruleAction>allow</ruleAction>
+          <egress>true</egress>
+          <cidrBlock>0.0.0.0/0</cidrBlock>
+        </item>
+      </entrySet>
+      <associationSet>
+        <item>
+          <networkAclAssociationId>aclassoc-5c659635</networkAclAssociationId>
+          <networkAclId>acl-5566953c</networkAclId>
+          <subnetId>subnet-ff669596</subnetId>
+        </item>
+      </associationSet>
+    </item>
+  </networkAclSet>
+  <nextToken>token</nextToken>
+</DescribeNetworkAclsResponse>`, `
+<DescribeNetworkAclsResponse>
+  <networkAclSet>
+    <item>
+      <networkAclId>acl-5d659634</networkAclId>
+      <vpcId>vpc-5266953b</vpcId>
+      <associationSet>
+        <item>
+          <subnetId>subnet-f0669599</subnetId>
+        </item>
+      </associationSet>
+    </item>
+  </networkAclSet>
+</DescribeNetworkAclsResponse>`}
+	acls, err := s.client.NetworkACLs("vpc-5266953b")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(acls, jc.DeepEquals, []networkACL{{
+		Id:        "acl-5566953c",
+		SubnetIds: []string{"subnet-ff669596"},
+		Entries: []networkACLEntry{{
+			RuleNumber: 100,
+			Protocol:   "6",
+			RuleAction: "allow",
+			CIDRBlock:  "0.0.0.0/0",
+			PortRange:  portRange(22, 22),
+		}, {
+			RuleNumber: 100,
+			Protocol:   "-1",
+			RuleAction: "allow",
+			Egress:     true,
+			CIDRBlock:  "0.0.0.0/0",
+		}},
+	}, {
+		Id:        "acl-5d659634",
+		SubnetIds: []string{"subnet-f0669599"},
+	}})
+	c.Assert(s.requests, gc.HasLen, 2)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeNetworkAcls")
+	c.Assert(s.requests[0].Get("Filter.1.Name"), gc.Equals, "vpc-id")
+	c.Assert(s.requests[0].Get("Filter.1.Value.1"), gc.Equals, "vpc-5266953b")
+	c.Assert(s.requests[0].Get("NextToken"), gc.Equals, "")
+	c.Assert(s.requests[1].Get("NextToken"), gc.Equals, "token")
+}
+
+func (s *networkACLSuite) TestNetworkACLBlocksInboundTCP(c *gc.C) {
+	denyAll := networkACLEntry{RuleNumber: 32767, Protocol: "-1", RuleAction: "deny", CIDRBlock: "0.0.0.0/0"}
+	for i, test := range []struct {
+		about   string
+		entries []networkACLEntry
+		blocked bool
+	}{{
+		about:   "no entries",
+		blocked: true,
+	}, {
+		about: "all traffic allowed",
+		entries: []networkACLEntry{
+			{RuleNumber: 100, Protocol: "-1", RuleAction: "allow", CIDRBlock: "0.0.0.0/0"},
+			denyAll,
+		},
+	}, {
+		about: "port allowed from some sources",
+		entries: []networkACLEntry{
+			denyAll,
+			{RuleNumber: 100, Protocol: "6", RuleAction: "allow", CIDRBlock: "10.0.0.0/8", PortRange: portRange(1, 1024)},
+		},
+	}, {
+		about: "port denied from some sources first",
+		entries: []networkACLEntry{
+			{RuleNumber: 100, Protocol: "6", RuleAction: "deny", CIDRBlock: "192.168.0.0/16", PortRange: portRange(22, 22)},
+			{RuleNumber: 110, Protocol: "6", RuleAction: "allow", CIDRBlock: "0.0.0.0/0", PortRange: portRange(22, 22)},
+			denyAll,
+		},
+	}, {
+		about: "port denied from all sources first",
+		entries: []networkACLEntry{
+			{RuleNumber: 110, Protocol: "6", RuleAction: "allow", CIDRBlock: "0.0.0.0/0", PortRange: portRange(22, 22)},
+			{RuleNumber: 100, Protocol: "6", RuleAction: "deny", CIDRBlock: "0.0.0.0/0", PortRange: portRange(0, 1024)},
+			denyAll,
+		},
+		blocked: true,
+	}, {
+		about: "other ports, protocols and directions allowed",
+		entries: []networkACLEntry{
+			{RuleNumber: 100, Protocol: "6", RuleAction: "allow", CIDRBlock: "0.0.0.0/0", PortRange: portRange(80, 443)},
+			{RuleNumber: 110, Protocol: "17", RuleAction: "allow", CIDRBlock: "0.0.0.0/0", PortRange: portRange(22, 22)},
+			{RuleNumber: 120, Protocol: "-1", RuleAction: "allow", CIDRBlock: "0.0.0.0/0", Egress: true},
+			denyAll,
+		},
+		blocked: true,
+	}} {
+		c.Logf("test %d: %s", i, test.about)
+		c.Check(networkACLBlocksInboundTCP(test.entries, 22), gc.Equals, test.blocked)
+	}
+}
+
+func portRange(from, to int) *networkACLPortRange {
+	return &networkACLPortRange{From: from, To: to}
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// instanceTypeZonesTTL is how long the availability zones that offer an
+// instance type are cached for before they are queried again. It is a
+// variable so it can be replaced in tests.
+var instanceTypeZonesTTL = time.Hour
+
+// instanceTypeOfferingsAPI is the subset of the EC2 API used to find
+// the availability zones in which instance types are offered, which the
+// EC2 client library has no support for.
+type instanceTypeOfferingsAPI interface {
+	// InstanceTypeZones returns the availability zones of the region
+	// in which the given instance type is offered.
+	InstanceTypeZones(instanceType string) ([]string, error)
+}
+
+// newInstanceTypeOfferingsAPI returns an instanceTypeOfferingsAPI for
+// the given cloud, whose request signer is wrapped with wrapSigner. It
+// is a variable so it can be replaced in tests.
+var newInstanceTypeOfferingsAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) instanceTypeOfferingsAPI {
+	return &instanceTypeOfferingsClient{newEC2QueryClient(cloud, wrapSigner)}
+}
+
+// instanceTypeOfferingsClient is a minimal client for the EC2 query
+// API, used to describe instance type offerings.
+type instanceTypeOfferingsClient struct {
+	*privateDNSClient
+}
+
+// InstanceTypeZones is part of the instanceTypeOfferingsAPI interface.
+func (c *instanceTypeOfferingsClient) InstanceTypeZones(instanceType string) ([]string, error) {
+	var zones []string
+	var nextToken string
+	for {
+		params := url.Values{
+			"LocationType":     {"availability-zone"},
+			"Filter.1.Name":    {"instance-type"},
+			"Filter.1.Value.1": {instanceType},
+		}
+		if nextToken != "" {
+			params.Set("NextToken", nextToken)
+		}
+		var resp struct {
+			Locations []string `xml:"instanceTypeOfferingSet>item>location"`
+			NextToken string   `xml:"nextToken"`
+		}
+		if err := c.query("DescribeInstanceTypeOfferings", params, &resp); err != nil {
+			return nil, errors.Annotatef(err, "describing offerings of instance type %q", instanceType)
+		}
+		zones = append(zones, resp.Locations...)
+		if resp.NextToken == "" {
+			return zones, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// offeredZones holds the availability zones that offer an instance
+// type, which are cached until expiry.
+type offeredZones struct {
+	zones  set.Strings
+	expiry time.Time
+}
+
+// instanceTypeZones returns the availability zones in the model's
+// region that offer the given instance type. The zones of each instance
+// type are cached for instanceTypeZonesTTL.
+func (e *environ) instanceTypeZones(instanceType string) (set.Strings, error) {
+	e.instanceTypeZonesMutex.Lock()
+	defer e.instanceTypeZonesMutex.Unlock()
+	if cached, ok := e.instanceTypeZonesCache[instanceType]; ok && time.Now().Before(cached.expiry) {
+		return cached.zones, nil
+	}
+	zones, err := e.instanceTypeOfferings.InstanceTypeZones(instanceType)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if e.instanceTypeZonesCache == nil {
+		e.instanceTypeZonesCache = make(map[string]offeredZones)
+	}
+	offered := set.NewStrings(zones...)
+	e.instanceTypeZonesCache[instanceType] = offeredZones{
+		zones:  offered,
+		expiry: time.Now().Add(instanceTypeZonesTTL),
+	}
+	return offered, nil
+}
+
+// preferOfferedZones returns the given availability zones with those
+// that do not offer the given instance type moved to the end, so they
+// are only tried if launching in the others fails. The relative order of
+// the zones is otherwise preserved. If the zones that offer the instance
+// type cannot be determined, for example because the region does not
+// support querying them, the zones are returned unchanged and launches
+// find out by trying them.
+func (e *environ) preferOfferedZones(zones []string, instanceType string) []string {
+	offered, err := e.instanceTypeZones(instanceType)
+	if err != nil {
+		logger.Debugf("cannot determine availability zones offering %q, trying all of them: %v", instanceType, err)
+		return zones
+	}
+	var preferred, others []string
+	for _, zone := range zones {
+		if offered.Contains(zone) {
+			preferred = append(preferred, zone)
+		} else {
+			others = append(others, zone)
+		}
+	}
+	if len(others) > 0 {
+		logger.Debugf("instance type %q is not offered in availability zones %v; trying them last", instanceType, others)
+	}
+	return append(preferred, others...)
+}
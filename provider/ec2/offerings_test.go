@@ -0,0 +1,112 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type offeringsSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	responses []string
+	client    *instanceTypeOfferingsClient
+}
+
+var _ = gc.Suite(&offeringsSuite{})
+
+func (s *offeringsSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.URL.Query())
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &instanceTypeOfferingsClient{&privateDNSClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  privateDNSAPIVersion,
+	}}}
+}
+
+func (s *offeringsSuite) TestInstanceTypeZones(c *gc.C) {
+	s.responses = []string{`
+<DescribeInstanceTypeOfferingsResponse>
+  <instanceTypeOfferingSet>
+    <item>
+      <instanceType>p3.2xlarge</instanceType>
+      <locationType>availability-zone</locationType>
+      <location>us-east-1a</location>
+    </item>
+  </instanceTypeOfferingSet>
+  <nextToken>token</nextToken>
+</DescribeInstanceTypeOfferingsResponse>`, `
+<DescribeInstanceTypeOfferingsResponse>
+  <instanceTypeOfferingSet>
+    <item>
+      <instanceType>p3.2xlarge</instanceType>
+      <locationType>availability-zone</locationType>
+      <location>us-east-1d</location>
+    </item>
+  </instanceTypeOfferingSet>
+</DescribeInstanceTypeOfferingsResponse>`}
+	zones, err := s.client.InstanceTypeZones("p3.2xlarge")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones, jc.DeepEquals, []string{"us-east-1a", "us-east-1d"})
+	c.Assert(s.requests, gc.HasLen, 2)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeInstanceTypeOfferings")
+	c.Assert(s.requests[0].Get("LocationType"), gc.Equals, "availability-zone")
+	c.Assert(s.requests[0].Get("Filter.1.Name"), gc.Equals, "instance-type")
+	c.Assert(s.requests[0].Get("Filter.1.Value.1"), gc.Equals, "p3.2xlarge")
+	c.Assert(s.requests[0].Get("NextToken"), gc.Equals, "")
+	c.Assert(s.requests[1].Get("NextToken"), gc.Equals, "token")
+}
+
+func (s *offeringsSuite) TestPreferOfferedZones(c *gc.C) {
+	fake := &fakeInstanceTypeOfferings{zones: map[string][]string{
+		"p3.2xlarge": {"az2", "az4"},
+	}}
+	env := &environ{instanceTypeOfferings: fake}
+	zones := env.preferOfferedZones([]string{"az1", "az2", "az3", "az4"}, "p3.2xlarge")
+	c.Assert(zones, jc.DeepEquals, []string{"az2", "az4", "az1", "az3"})
+
+	// The offerings are cached.
+	zones = env.preferOfferedZones([]string{"az4", "az3", "az2"}, "p3.2xlarge")
+	c.Assert(zones, jc.DeepEquals, []string{"az4", "az2", "az3"})
+	c.Assert(fake.calls, gc.Equals, 1)
+
+	// Until they expire.
+	s.PatchValue(&instanceTypeZonesTTL, time.Duration(0))
+	env.preferOfferedZones([]string{"az1"}, "p3.2xlarge")
+	env.preferOfferedZones([]string{"az1"}, "p3.2xlarge")
+	c.Assert(fake.calls, gc.Equals, 3)
+}
+
+func (s *offeringsSuite) TestPreferOfferedZonesUnsupported(c *gc.C) {
+	fake := &fakeInstanceTypeOfferings{err: &awsError{
+		Code:    "InvalidAction",
+		Message: "The action DescribeInstanceTypeOfferings is not valid for this web service.",
+	}}
+	env := &environ{instanceTypeOfferings: fake}
+	zones := env.preferOfferedZones([]string{"az1", "az2"}, "p3.2xlarge")
+	c.Assert(zones, jc.DeepEquals, []string{"az1", "az2"})
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs/instances"
+)
+
+// The strategies of placement groups. Instances in a cluster group are
+// packed close together within a zone, for low-latency networking; those
+// in a spread group are each placed on distinct hardware; and those in a
+// partition group are spread across partitions that share no hardware
+// with each other.
+const (
+	placementStrategyCluster   = "cluster"
+	placementStrategySpread    = "spread"
+	placementStrategyPartition = "partition"
+)
+
+// maxPlacementGroupPartitions is the largest number of partitions that
+// a partition placement group may have in each availability zone.
+const maxPlacementGroupPartitions = 7
+
+// placementGroup records the placement group, and the partition within
+// it, given with a placement-group placement directive.
+type placementGroup struct {
+	name     string
+	strategy string
+
+	// partition is the partition of a partition group the instance
+	// is launched into, or zero to let AWS choose one.
+	partition int
+}
+
+// parsePlacementGroup parses the value of a placement-group placement
+// directive, of the form
+// <name>[,strategy=<cluster|spread|partition>][,partition=<n>].
+// Without an explicit strategy, the group is taken to be a cluster
+// group. The strategy must be that the group was created with, and a
+// partition may only be given for partition groups.
+func parsePlacementGroup(value string) (*placementGroup, error) {
+	parts := strings.Split(value, ",")
+	g := &placementGroup{name: parts[0], strategy: placementStrategyCluster}
+	if g.name == "" {
+		return nil, errors.New("placement group: no group name specified")
+	}
+	for _, part := range parts[1:] {
+		pos := strings.IndexRune(part, '=')
+		if pos == -1 {
+			return nil, errors.Errorf("placement group: unknown option %q, expected strategy=<cluster|spread|partition> or partition=<n>", part)
+		}
+		switch key, value := part[:pos], part[pos+1:]; key {
+		case "strategy":
+			switch value {
+			case placementStrategyCluster, placementStrategySpread, placementStrategyPartition:
+				g.strategy = value
+			default:
+				return nil, errors.Errorf(
+					"placement group: strategy %q is not valid, expected %q, %q or %q",
+					value, placementStrategyCluster, placementStrategySpread, placementStrategyPartition,
+				)
+			}
+		case "partition":
+			partition, err := strconv.Atoi(value)
+			if err != nil || partition < 1 || partition > maxPlacementGroupPartitions {
+				return nil, errors.Errorf(
+					"placement group: partition %q is not valid, expected a number from 1 to %d",
+					value, maxPlacementGroupPartitions,
+				)
+			}
+			g.partition = partition
+		default:
+			return nil, errors.Errorf("placement group: unknown option %q, expected strategy=<cluster|spread|partition> or partition=<n>", part)
+		}
+	}
+	if g.partition != 0 && g.strategy != placementStrategyPartition {
+		return nil, errors.Errorf("placement group: a partition can only be given with strategy=%s", placementStrategyPartition)
+	}
+	return g, nil
+}
+
+// checkInstanceType returns an error if instances of the named type
+// cannot be launched into the placement group.
+//
+// At the time of writing, burstable performance instances, of the T
+// families, cannot be launched into cluster placement groups, as they
+// cannot be packed onto the low-latency network the groups provide.
+// Instances of any type can be launched into spread and partition
+// groups. See
+// http://docs.aws.amazon.com/AWSEC2/latest/UserGuide/placement-groups.html
+func (g *placementGroup) checkInstanceType(instanceType string) error {
+	if g.strategy != placementStrategyCluster {
+		return nil
+	}
+	family := strings.ToLower(strings.SplitN(instanceType, ".", 2)[0])
+	switch family {
+	case "t1", "t2", "t3", "t3a", "t4g":
+		return errors.Errorf(
+			"placement group %q: burstable instance type %q cannot be launched into a %s placement group",
+			g.name, instanceType, g.strategy,
+		)
+	}
+	return nil
+}
+
+// instanceTypesForPlacementGroup returns the subset of the given
+// instance types that can be launched into the given placement group.
+func instanceTypesForPlacementGroup(instanceTypes []instances.InstanceType, group *placementGroup) []instances.InstanceType {
+	var result []instances.InstanceType
+	for _, instanceType := range instanceTypes {
+		if group.checkInstanceType(instanceType.Name) == nil {
+			result = append(result, instanceType)
+		}
+	}
+	return result
+}
+
+// addParams adds the RunInstances parameters that launch the instance
+// into the placement group, and partition if any. The EC2 client
+// library has no support for partitions.
+func (g *placementGroup) addParams(params url.Values) {
+	params.Set("Placement.GroupName", g.name)
+	if g.partition != 0 {
+		params.Set("Placement.PartitionNumber", strconv.Itoa(g.partition))
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/instances"
+	"github.com/juju/juju/testing"
+)
+
+type placementGroupSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&placementGroupSuite{})
+
+func (s *placementGroupSuite) TestParsePlacementGroup(c *gc.C) {
+	for i, test := range []struct {
+		value  string
+		expect *placementGroup
+		err    string
+	}{{
+		value:  "hpc",
+		expect: &placementGroup{name: "hpc", strategy: "cluster"},
+	}, {
+		value:  "db,strategy=spread",
+		expect: &placementGroup{name: "db", strategy: "spread"},
+	}, {
+		value:  "kafka,strategy=partition",
+		expect: &placementGroup{name: "kafka", strategy: "partition"},
+	}, {
+		value:  "kafka,strategy=partition,partition=3",
+		expect: &placementGroup{name: "kafka", strategy: "partition", partition: 3},
+	}, {
+		value: "",
+		err:   `placement group: no group name specified`,
+	}, {
+		value: "hpc,strategy=rack",
+		err:   `placement group: strategy "rack" is not valid, expected "cluster", "spread" or "partition"`,
+	}, {
+		value: "kafka,strategy=partition,partition=8",
+		err:   `placement group: partition "8" is not valid, expected a number from 1 to 7`,
+	}, {
+		value: "kafka,strategy=partition,partition=one",
+		err:   `placement group: partition "one" is not valid, expected a number from 1 to 7`,
+	}, {
+		value: "hpc,partition=1",
+		err:   `placement group: a partition can only be given with strategy=partition`,
+	}, {
+		value: "hpc,tenancy=dedicated",
+		err:   `placement group: unknown option "tenancy=dedicated", expected strategy=<cluster\|spread\|partition> or partition=<n>`,
+	}} {
+		c.Logf("test %d: %q", i, test.value)
+		g, err := parsePlacementGroup(test.value)
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(g, jc.DeepEquals, test.expect)
+	}
+}
+
+func (s *placementGroupSuite) TestCheckInstanceType(c *gc.C) {
+	cluster := &placementGroup{name: "hpc", strategy: "cluster"}
+	c.Assert(cluster.checkInstanceType("c5.large"), jc.ErrorIsNil)
+	c.Assert(cluster.checkInstanceType("t2.micro"), gc.ErrorMatches,
+		`placement group "hpc": burstable instance type "t2.micro" cannot be launched into a cluster placement group`)
+
+	for _, strategy := range []string{"spread", "partition"} {
+		g := &placementGroup{name: "db", strategy: strategy}
+		c.Check(g.checkInstanceType("t2.micro"), jc.ErrorIsNil)
+	}
+}
+
+func (s *placementGroupSuite) TestInstanceTypesForPlacementGroup(c *gc.C) {
+	instanceTypes := []instances.InstanceType{
+		{Name: "c5.large"}, {Name: "t2.micro"}, {Name: "t3a.small"}, {Name: "m5.large"},
+	}
+	c.Assert(instanceTypesForPlacementGroup(instanceTypes, &placementGroup{strategy: "cluster"}), jc.DeepEquals, []instances.InstanceType{
+		{Name: "c5.large"}, {Name: "m5.large"},
+	})
+	c.Assert(instanceTypesForPlacementGroup(instanceTypes, &placementGroup{strategy: "spread"}), jc.DeepEquals, instanceTypes)
+}
+
+func (s *placementGroupSuite) TestAddParams(c *gc.C) {
+	params := make(url.Values)
+	(&placementGroup{name: "kafka", strategy: "partition", partition: 2}).addParams(params)
+	c.Assert(params, jc.DeepEquals, url.Values{
+		"Placement.GroupName":       {"kafka"},
+		"Placement.PartitionNumber": {"2"},
+	})
+
+	params = make(url.Values)
+	(&placementGroup{name: "hpc", strategy: "cluster"}).addParams(params)
+	c.Assert(params, jc.DeepEquals, url.Values{
+		"Placement.GroupName": {"hpc"},
+	})
+}
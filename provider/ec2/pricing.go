@@ -0,0 +1,169 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// pricingRegion is the region whose endpoint serves the AWS Price List
+// API; prices for every region are queried there.
+const pricingRegion = "us-east-1"
+
+// instancePriceTTL is how long the On-Demand prices of instance types
+// are cached for before they are queried again. It is a variable so it
+// can be replaced in tests.
+var instancePriceTTL = 6 * time.Hour
+
+// pricingAPI is the subset of the AWS Price List API used to look up
+// the prices of instance types.
+type pricingAPI interface {
+	// OnDemandPrices returns the hourly On-Demand price, in USD, of
+	// each instance type in the given region for which one is known.
+	OnDemandPrices(region string) (map[string]float64, error)
+}
+
+// newPricingAPI returns a pricingAPI for the given cloud, whose request
+// signer is wrapped with wrapSigner. It is a variable so it can be
+// replaced in tests.
+var newPricingAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) pricingAPI {
+	client := newAWSAPIClient(cloud, pricingRegion, "pricing", fmt.Sprintf("https://api.pricing.%s.amazonaws.com", pricingRegion), wrapSigner)
+	client.target = "AWSPriceListService"
+	return &pricingClient{client}
+}
+
+// pricingClient is a minimal client for the AWS Price List JSON API.
+type pricingClient struct {
+	*awsAPIClient
+}
+
+// pricingFilter is a filter on the attributes of the products whose
+// prices are queried.
+type pricingFilter struct {
+	Type  string `json:"Type"`
+	Field string `json:"Field"`
+	Value string `json:"Value"`
+}
+
+// onDemandPriceFilters restrict the products whose prices are queried
+// to those matching the instances Juju launches.
+var onDemandPriceFilters = []pricingFilter{
+	{Type: "TERM_MATCH", Field: "operatingSystem", Value: "Linux"},
+	{Type: "TERM_MATCH", Field: "tenancy", Value: "Shared"},
+	{Type: "TERM_MATCH", Field: "preInstalledSw", Value: "NA"},
+	{Type: "TERM_MATCH", Field: "licenseModel", Value: "No License required"},
+	{Type: "TERM_MATCH", Field: "capacitystatus", Value: "Used"},
+}
+
+// pricingProduct is the part of a product in the price list that
+// records its hourly On-Demand price.
+type pricingProduct struct {
+	Product struct {
+		Attributes struct {
+			InstanceType string `json:"instanceType"`
+		} `json:"attributes"`
+	} `json:"product"`
+	Terms struct {
+		OnDemand map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// hourlyPrice returns the hourly On-Demand price of the product in USD,
+// and reports whether it has one.
+func (p *pricingProduct) hourlyPrice() (float64, bool) {
+	for _, term := range p.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			if dimension.Unit != "Hrs" {
+				continue
+			}
+			price, err := strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+			if err == nil && price > 0 {
+				return price, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// OnDemandPrices is part of the pricingAPI interface.
+func (c *pricingClient) OnDemandPrices(region string) (map[string]float64, error) {
+	filters := append([]pricingFilter{
+		{Type: "TERM_MATCH", Field: "regionCode", Value: region},
+	}, onDemandPriceFilters...)
+	prices := make(map[string]float64)
+	var nextToken string
+	for {
+		args := struct {
+			ServiceCode string          `json:"ServiceCode"`
+			Filters     []pricingFilter `json:"Filters"`
+			NextToken   string          `json:"NextToken,omitempty"`
+		}{"AmazonEC2", filters, nextToken}
+		var resp struct {
+			// Each entry of the price list is a JSON document
+			// encoded as a string.
+			PriceList []string `json:"PriceList"`
+			NextToken string   `json:"NextToken"`
+		}
+		if err := c.call("GetProducts", args, &resp); err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, entry := range resp.PriceList {
+			var product pricingProduct
+			if err := json.Unmarshal([]byte(entry), &product); err != nil {
+				return nil, errors.Annotate(err, "parsing price list")
+			}
+			instanceType := product.Product.Attributes.InstanceType
+			price, ok := product.hourlyPrice()
+			if instanceType == "" || !ok {
+				continue
+			}
+			// Should there be several products for an instance type,
+			// the cheapest is assumed to be the one Juju launches.
+			if existing, ok := prices[instanceType]; !ok || price < existing {
+				prices[instanceType] = price
+			}
+		}
+		if resp.NextToken == "" {
+			return prices, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// instanceTypePrices returns the approximate hourly On-Demand price, in
+// USD, of each of the given instance types in the model's region for
+// which one is known. The prices of all instance types in the region are
+// queried together, and cached for instancePriceTTL.
+func (e *environ) instanceTypePrices(instanceTypes []string) (map[string]float64, error) {
+	e.instancePricesMutex.Lock()
+	defer e.instancePricesMutex.Unlock()
+	if e.instancePrices == nil || !time.Now().Before(e.instancePricesExpiry) {
+		prices, err := e.pricing.OnDemandPrices(e.cloud.Region)
+		if err != nil {
+			return nil, errors.Annotatef(err, "querying instance type prices in %q", e.cloud.Region)
+		}
+		e.instancePrices = prices
+		e.instancePricesExpiry = time.Now().Add(instancePriceTTL)
+	}
+	result := make(map[string]float64)
+	for _, instanceType := range instanceTypes {
+		if price, ok := e.instancePrices[instanceType]; ok {
+			result[instanceType] = price
+		}
+	}
+	return result, nil
+}
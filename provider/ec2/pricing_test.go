@@ -0,0 +1,180 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/instances"
+	"github.com/juju/juju/testing"
+)
+
+type pricingSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	targets   []string
+	bodies    []map[string]interface{}
+	status    int
+	responses []string
+	client    *pricingClient
+}
+
+var _ = gc.Suite(&pricingSuite{})
+
+func (s *pricingSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.targets = nil
+	s.bodies = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		c.Check(json.NewDecoder(r.Body).Decode(&body), jc.ErrorIsNil)
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.targets = append(s.targets, r.Header.Get("X-Amz-Target"))
+		s.bodies = append(s.bodies, body)
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &pricingClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "pricing"),
+		target:   "AWSPriceListService",
+	}}
+}
+
+// pricingProductJSON returns a price list entry for the given instance
+// type, with the given hourly price in USD.
+func pricingProductJSON(instanceType, price string) string {
+	return fmt.Sprintf(`{
+  "product": {"attributes": {"instanceType": %q}},
+  "terms": {"OnDemand": {"SKU.TERM": {"priceDimensions": {"SKU.TERM.DIM": {
+    "unit": "Hrs", "pricePerUnit": {"USD": %q}
+  }}}}}
+}`, instanceType, price)
+}
+
+func pricingResponse(c *gc.C, nextToken string, products ...string) string {
+	resp, err := json.Marshal(map[string]interface{}{
+		"PriceList": products,
+		"NextToken": nextToken,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return string(resp)
+}
+
+func (s *pricingSuite) TestOnDemandPrices(c *gc.C) {
+	s.responses = []string{
+		pricingResponse(c, "token",
+			pricingProductJSON("m4.large", "0.1000000000"),
+			pricingProductJSON("m4.large", "0.1200000000"),
+		),
+		pricingResponse(c, "",
+			pricingProductJSON("t2.micro", "0.0116000000"),
+			pricingProductJSON("t2.nano", "0.0000000000"),
+		),
+	}
+	prices, err := s.client.OnDemandPrices("eu-west-1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(prices, jc.DeepEquals, map[string]float64{
+		"m4.large": 0.1,
+		"t2.micro": 0.0116,
+	})
+
+	c.Assert(s.targets, jc.DeepEquals, []string{
+		"AWSPriceListService.GetProducts",
+		"AWSPriceListService.GetProducts",
+	})
+	c.Assert(s.bodies[0]["ServiceCode"], gc.Equals, "AmazonEC2")
+	c.Assert(s.bodies[0]["NextToken"], gc.IsNil)
+	c.Assert(s.bodies[1]["NextToken"], gc.Equals, "token")
+	filters := s.bodies[0]["Filters"].([]interface{})
+	c.Assert(filters, gc.HasLen, len(onDemandPriceFilters)+1)
+	c.Assert(filters[0], jc.DeepEquals, map[string]interface{}{
+		"Type": "TERM_MATCH", "Field": "regionCode", "Value": "eu-west-1",
+	})
+}
+
+func (s *pricingSuite) TestOnDemandPricesError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.responses = []string{`{"__type": "com.amazonaws#AccessDeniedException", "message": "denied"}`}
+	_, err := s.client.OnDemandPrices("eu-west-1")
+	c.Assert(err, gc.ErrorMatches, `denied \(AccessDeniedException\)`)
+}
+
+type fakePricingAPI struct {
+	regions []string
+	prices  map[string]float64
+	err     error
+}
+
+func (f *fakePricingAPI) OnDemandPrices(region string) (map[string]float64, error) {
+	f.regions = append(f.regions, region)
+	return f.prices, f.err
+}
+
+func (s *pricingSuite) TestInstanceTypePricesCached(c *gc.C) {
+	fake := &fakePricingAPI{prices: map[string]float64{
+		"m4.large": 0.1,
+		"t2.micro": 0.0116,
+	}}
+	env := &environ{cloud: environs.CloudSpec{Region: "eu-west-1"}, pricing: fake}
+
+	prices, err := env.instanceTypePrices([]string{"m4.large", "x1.32xlarge"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(prices, jc.DeepEquals, map[string]float64{"m4.large": 0.1})
+	prices, err = env.instanceTypePrices([]string{"t2.micro"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(prices, jc.DeepEquals, map[string]float64{"t2.micro": 0.0116})
+	c.Assert(fake.regions, jc.DeepEquals, []string{"eu-west-1"})
+
+	// Once the cached prices expire, they are queried again.
+	env.instancePricesExpiry = time.Now().Add(-time.Second)
+	fake.prices = map[string]float64{"t2.micro": 0.02}
+	prices, err = env.instanceTypePrices([]string{"t2.micro"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(prices, jc.DeepEquals, map[string]float64{"t2.micro": 0.02})
+	c.Assert(fake.regions, jc.DeepEquals, []string{"eu-west-1", "eu-west-1"})
+}
+
+func (s *pricingSuite) TestWithCurrentPrices(c *gc.C) {
+	fake := &fakePricingAPI{prices: map[string]float64{"m4.large": 0.1234}}
+	env := &environ{cloud: environs.CloudSpec{Region: "eu-west-1"}, pricing: fake}
+	instanceTypes := []instances.InstanceType{
+		{Name: "m4.large", Cost: 100},
+		{Name: "t2.micro", Cost: 13},
+	}
+	c.Assert(env.withCurrentPrices(instanceTypes), jc.DeepEquals, []instances.InstanceType{
+		{Name: "m4.large", Cost: 123},
+		{Name: "t2.micro", Cost: 13},
+	})
+	c.Assert(instanceTypes[0].Cost, gc.Equals, uint64(100))
+}
+
+func (s *pricingSuite) TestWithCurrentPricesError(c *gc.C) {
+	fake := &fakePricingAPI{err: errors.New("denied")}
+	env := &environ{cloud: environs.CloudSpec{Region: "eu-west-1"}, pricing: fake}
+	instanceTypes := []instances.InstanceType{{Name: "m4.large", Cost: 100}}
+	c.Assert(env.withCurrentPrices(instanceTypes), jc.DeepEquals, instanceTypes)
+
+	// Failures are not cached.
+	env.withCurrentPrices(instanceTypes)
+	c.Assert(fake.regions, gc.HasLen, 2)
+}
@@ -0,0 +1,238 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// privateDNSAPIVersion is the EC2 API version used to describe the
+// subnets and VPCs that private DNS name options are validated against;
+// the version used by the EC2 client library predates them.
+const privateDNSAPIVersion = "2016-11-15"
+
+// The values of private-dns-hostname-type. With ip-name, an instance's
+// private DNS name is derived from its private IPv4 address; with
+// resource-name, it is derived from its instance ID.
+const (
+	privateDNSHostnameIPName       = "ip-name"
+	privateDNSHostnameResourceName = "resource-name"
+)
+
+// The entries of private-dns-resource-name-records. "none" may only be
+// given alone, to answer queries for resource names with neither.
+const (
+	privateDNSRecordA    = "a"
+	privateDNSRecordAAAA = "aaaa"
+	privateDNSRecordNone = "none"
+)
+
+// privateDNSOptions holds the private DNS name options with which
+// instances are launched. Options that are not set are left to the
+// subnet's defaults, as they are by AWS.
+type privateDNSOptions struct {
+	hostnameType string
+
+	// setRecords is true if the DNS records answered for resource
+	// names are set, in which case aRecord and aaaaRecord report
+	// whether A and AAAA records are.
+	setRecords bool
+	aRecord    bool
+	aaaaRecord bool
+}
+
+// isZero reports whether no private DNS name options are set.
+func (o privateDNSOptions) isZero() bool {
+	return o.hostnameType == "" && !o.setRecords
+}
+
+// parsePrivateDNSOptions parses the values of private-dns-hostname-type
+// and private-dns-resource-name-records.
+func parsePrivateDNSOptions(hostnameType, records string) (privateDNSOptions, error) {
+	opts := privateDNSOptions{hostnameType: hostnameType}
+	switch hostnameType {
+	case "", privateDNSHostnameIPName, privateDNSHostnameResourceName:
+	default:
+		return privateDNSOptions{}, errors.Errorf(
+			"private-dns-hostname-type: %q is not a valid hostname type, expected %q or %q",
+			hostnameType, privateDNSHostnameIPName, privateDNSHostnameResourceName,
+		)
+	}
+	entries := splitList(records)
+	for _, entry := range entries {
+		opts.setRecords = true
+		switch strings.ToLower(entry) {
+		case privateDNSRecordA:
+			opts.aRecord = true
+		case privateDNSRecordAAAA:
+			opts.aaaaRecord = true
+		case privateDNSRecordNone:
+			if len(entries) > 1 {
+				return privateDNSOptions{}, errors.Errorf(
+					"private-dns-resource-name-records: %q cannot be combined with other record types", entry,
+				)
+			}
+		default:
+			return privateDNSOptions{}, errors.Errorf(
+				"private-dns-resource-name-records: %q is not a valid record type, expected %q, %q or %q",
+				entry, privateDNSRecordA, privateDNSRecordAAAA, privateDNSRecordNone,
+			)
+		}
+	}
+	return opts, nil
+}
+
+// privateDNSParams adds the RunInstances parameters that carry the
+// model's private DNS name options, if any. The EC2 client library has
+// no support for private DNS name options. Options that are not set
+// are left out, so the subnet's defaults apply.
+func privateDNSParams(ecfg *environConfig, params url.Values) {
+	opts := ecfg.privateDNSOptions()
+	if opts.hostnameType != "" {
+		params.Set("PrivateDnsNameOptions.HostnameType", opts.hostnameType)
+	}
+	if opts.setRecords {
+		params.Set("PrivateDnsNameOptions.EnableResourceNameDnsARecord", strconv.FormatBool(opts.aRecord))
+		params.Set("PrivateDnsNameOptions.EnableResourceNameDnsAAAARecord", strconv.FormatBool(opts.aaaaRecord))
+	}
+}
+
+// privateDNSAPI is the subset of the EC2 API used to check that the
+// private DNS name options suit the subnets instances are launched in.
+type privateDNSAPI interface {
+	// DescribeSubnet returns the details of the given subnet.
+	DescribeSubnet(subnetID string) (*privateDNSSubnet, error)
+
+	// VPCDNSSupport reports whether the given VPC resolves DNS names
+	// through the Amazon DNS server.
+	VPCDNSSupport(vpcID string) (bool, error)
+}
+
+// privateDNSSubnet holds the details of a subnet that determine which
+// private DNS name options instances launched in it may use.
+type privateDNSSubnet struct {
+	Id         string   `xml:"subnetId"`
+	VPCId      string   `xml:"vpcId"`
+	IPv6Native bool     `xml:"ipv6Native"`
+	IPv6States []string `xml:"ipv6CidrBlockAssociationSet>item>ipv6CidrBlockState>state"`
+}
+
+// hasIPv6 reports whether the subnet has an IPv6 CIDR block.
+func (s *privateDNSSubnet) hasIPv6() bool {
+	for _, state := range s.IPv6States {
+		if state == "associated" {
+			return true
+		}
+	}
+	return false
+}
+
+// newPrivateDNSAPI returns a privateDNSAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newPrivateDNSAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) privateDNSAPI {
+	return newEC2QueryClient(cloud, wrapSigner)
+}
+
+// newEC2QueryClient returns a privateDNSClient for the EC2 query API of
+// the given cloud, whose request signer is wrapped with wrapSigner.
+func newEC2QueryClient(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) *privateDNSClient {
+	return &privateDNSClient{newEC2Client(cloud, privateDNSAPIVersion, wrapSigner)}
+}
+
+// privateDNSClient is a minimal client for the EC2 query API, used to
+// describe subnets and the DNS support of VPCs. Its queries are also used
+// by dualStackClient.
+type privateDNSClient struct {
+	*awsAPIClient
+}
+
+// DescribeSubnet is part of the privateDNSAPI interface.
+func (c *privateDNSClient) DescribeSubnet(subnetID string) (*privateDNSSubnet, error) {
+	params := url.Values{"SubnetId.1": {subnetID}}
+	var resp struct {
+		Subnets []privateDNSSubnet `xml:"subnetSet>item"`
+	}
+	if err := c.query("DescribeSubnets", params, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && err.Code == "InvalidSubnetID.NotFound" {
+			return nil, errors.NotFoundf("subnet %q", subnetID)
+		}
+		return nil, errors.Annotatef(err, "describing subnet %q", subnetID)
+	}
+	if len(resp.Subnets) == 0 {
+		return nil, errors.NotFoundf("subnet %q", subnetID)
+	}
+	return &resp.Subnets[0], nil
+}
+
+// VPCDNSSupport is part of the privateDNSAPI interface.
+func (c *privateDNSClient) VPCDNSSupport(vpcID string) (bool, error) {
+	params := url.Values{
+		"VpcId":     {vpcID},
+		"Attribute": {"enableDnsSupport"},
+	}
+	var resp struct {
+		Value bool `xml:"enableDnsSupport>value"`
+	}
+	if err := c.query("DescribeVpcAttribute", params, &resp); err != nil {
+		return false, errors.Annotatef(err, "getting DNS support of VPC %q", vpcID)
+	}
+	return resp.Value, nil
+}
+
+// validatePrivateDNSOptions checks that the model's private DNS name
+// options suit the given subnet and its VPC: IPv6-only subnets have no
+// IPv4 addresses from which to derive names or answer A records, AAAA
+// records need an IPv6 CIDR block, and records for resource names are
+// only answered by the Amazon DNS server of VPCs with DNS support.
+func (e *environ) validatePrivateDNSOptions(subnetID string) error {
+	opts := e.ecfg().privateDNSOptions()
+	if opts.isZero() || subnetID == "" {
+		return nil
+	}
+	subnet, err := e.privateDNS.DescribeSubnet(subnetID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if subnet.IPv6Native {
+		if opts.hostnameType == privateDNSHostnameIPName {
+			return errors.Errorf(
+				"private-dns-hostname-type: subnet %q is IPv6-only, so instances launched in it must use %q",
+				subnetID, privateDNSHostnameResourceName,
+			)
+		}
+		if opts.aRecord {
+			return errors.Errorf(
+				"private-dns-resource-name-records: subnet %q is IPv6-only, so has no IPv4 addresses for A records",
+				subnetID,
+			)
+		}
+	}
+	if opts.aaaaRecord && !subnet.hasIPv6() {
+		return errors.Errorf(
+			"private-dns-resource-name-records: subnet %q has no IPv6 CIDR block for AAAA records",
+			subnetID,
+		)
+	}
+	if opts.aRecord || opts.aaaaRecord {
+		supported, err := e.privateDNS.VPCDNSSupport(subnet.VPCId)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if !supported {
+			return errors.Errorf(
+				"private-dns-resource-name-records: VPC %q of subnet %q does not have DNS support enabled",
+				subnet.VPCId, subnetID,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type privateDNSSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *privateDNSClient
+}
+
+var _ = gc.Suite(&privateDNSSuite{})
+
+func (s *privateDNSSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &privateDNSClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  privateDNSAPIVersion,
+	}}
+}
+
+func (s *privateDNSSuite) environ(c *gc.C, attrs testing.Attrs, api privateDNSAPI) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg, privateDNS: api}
+}
+
+func (s *privateDNSSuite) params(c *gc.C, attrs testing.Attrs) url.Values {
+	env := s.environ(c, attrs, nil)
+	params := make(url.Values)
+	privateDNSParams(env.ecfg(), params)
+	return params
+}
+
+func (s *privateDNSSuite) TestPrivateDNSParams(c *gc.C) {
+	attrs := testing.Attrs{
+		"private-dns-hostname-type":         "resource-name",
+		"private-dns-resource-name-records": "aaaa",
+	}
+	c.Assert(s.params(c, attrs), jc.DeepEquals, url.Values{
+		"PrivateDnsNameOptions.HostnameType":                    {"resource-name"},
+		"PrivateDnsNameOptions.EnableResourceNameDnsARecord":    {"false"},
+		"PrivateDnsNameOptions.EnableResourceNameDnsAAAARecord": {"true"},
+	})
+}
+
+func (s *privateDNSSuite) TestPrivateDNSParamsHostnameTypeOnly(c *gc.C) {
+	attrs := testing.Attrs{"private-dns-hostname-type": "ip-name"}
+	c.Assert(s.params(c, attrs), jc.DeepEquals, url.Values{
+		"PrivateDnsNameOptions.HostnameType": {"ip-name"},
+	})
+}
+
+func (s *privateDNSSuite) TestPrivateDNSParamsUnset(c *gc.C) {
+	c.Assert(s.params(c, nil), gc.HasLen, 0)
+}
+
+func (s *privateDNSSuite) TestDescribeSubnet(c *gc.C) {
+	s.responses = []string{`
+<DescribeSubnetsResponse>
+  <subnetSet>
+    <item>
+      <subnetId>subnet-0a1b2c3d</subnetId>
+      <vpcId>vpc-0a1b2c3d</vpcId>
+      <ipv6Native>true</ipv6Native>
+      <ipv6CidrBlockAssociationSet>
+        <item>
+          <ipv6CidrBlock>2600:1f18:1234:5600::/64</ipv6CidrBlock>
+          <ipv6CidrBlockState><state>associated</state></ipv6CidrBlockState>
+        </item>
+      </ipv6CidrBlockAssociationSet>
+    </item>
+  </subnetSet>
+</DescribeSubnetsResponse>`}
+	subnet, err := s.client.DescribeSubnet("subnet-0a1b2c3d")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnet, jc.DeepEquals, &privateDNSSubnet{
+		Id:         "subnet-0a1b2c3d",
+		VPCId:      "vpc-0a1b2c3d",
+		IPv6Native: true,
+		IPv6States: []string{"associated"},
+	})
+	c.Assert(subnet.hasIPv6(), jc.IsTrue)
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeSubnets")
+	c.Assert(s.requests[0].Get("Version"), gc.Equals, privateDNSAPIVersion)
+	c.Assert(s.requests[0].Get("SubnetId.1"), gc.Equals, "subnet-0a1b2c3d")
+}
+
+func (s *privateDNSSuite) TestDescribeSubnetNotFound(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.responses = []string{`
+<Response><Errors><Error>
+  <Code>InvalidSubnetID.NotFound</Code>
+  <Message>The subnet ID 'subnet-0a1b2c3d' does not exist</Message>
+</Error></Errors></Response>`}
+	_, err := s.client.DescribeSubnet("subnet-0a1b2c3d")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `subnet "subnet-0a1b2c3d" not found`)
+}
+
+func (s *privateDNSSuite) TestVPCDNSSupport(c *gc.C) {
+	s.responses = []string{`
+<DescribeVpcAttributeResponse>
+  <vpcId>vpc-0a1b2c3d</vpcId>
+  <enableDnsSupport><value>true</value></enableDnsSupport>
+</DescribeVpcAttributeResponse>`}
+	supported, err := s.client.VPCDNSSupport("vpc-0a1b2c3d")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(supported, jc.IsTrue)
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Assert(s.requests[0].Get("Action"), gc.Equals, "DescribeVpcAttribute")
+	c.Assert(s.requests[0].Get("VpcId"), gc.Equals, "vpc-0a1b2c3d")
+	c.Assert(s.requests[0].Get("Attribute"), gc.Equals, "enableDnsSupport")
+}
+
+type fakePrivateDNSAPI struct {
+	subnet     *privateDNSSubnet
+	dnsSupport bool
+	calls      []string
+}
+
+func (f *fakePrivateDNSAPI) DescribeSubnet(subnetID string) (*privateDNSSubnet, error) {
+	f.calls = append(f.calls, "DescribeSubnet "+subnetID)
+	return f.subnet, nil
+}
+
+func (f *fakePrivateDNSAPI) VPCDNSSupport(vpcID string) (bool, error) {
+	f.calls = append(f.calls, "VPCDNSSupport "+vpcID)
+	return f.dnsSupport, nil
+}
+
+func (s *privateDNSSuite) TestValidatePrivateDNSOptionsUnset(c *gc.C) {
+	api := &fakePrivateDNSAPI{}
+	env := s.environ(c, nil, api)
+	c.Assert(env.validatePrivateDNSOptions("subnet-0a1b2c3d"), jc.ErrorIsNil)
+	c.Assert(api.calls, gc.HasLen, 0)
+}
+
+func (s *privateDNSSuite) TestValidatePrivateDNSOptions(c *gc.C) {
+	dualStack := &privateDNSSubnet{Id: "subnet-0a1b2c3d", VPCId: "vpc-0a1b2c3d", IPv6States: []string{"associated"}}
+	ipv4Only := &privateDNSSubnet{Id: "subnet-0a1b2c3d", VPCId: "vpc-0a1b2c3d"}
+	ipv6Only := &privateDNSSubnet{Id: "subnet-0a1b2c3d", VPCId: "vpc-0a1b2c3d", IPv6Native: true, IPv6States: []string{"associated"}}
+	for i, test := range []struct {
+		attrs      testing.Attrs
+		subnet     *privateDNSSubnet
+		dnsSupport bool
+		err        string
+	}{{
+		attrs:      testing.Attrs{"private-dns-hostname-type": "resource-name", "private-dns-resource-name-records": "a,aaaa"},
+		subnet:     dualStack,
+		dnsSupport: true,
+	}, {
+		attrs:  testing.Attrs{"private-dns-hostname-type": "ip-name", "private-dns-resource-name-records": "none"},
+		subnet: ipv4Only,
+	}, {
+		attrs:  testing.Attrs{"private-dns-hostname-type": "ip-name"},
+		subnet: ipv6Only,
+		err:    `private-dns-hostname-type: subnet "subnet-0a1b2c3d" is IPv6-only, so instances launched in it must use "resource-name"`,
+	}, {
+		attrs:      testing.Attrs{"private-dns-resource-name-records": "a"},
+		subnet:     ipv6Only,
+		dnsSupport: true,
+		err:        `private-dns-resource-name-records: subnet "subnet-0a1b2c3d" is IPv6-only, so has no IPv4 addresses for A records`,
+	}, {
+		attrs:      testing.Attrs{"private-dns-resource-name-records": "aaaa"},
+		subnet:     ipv4Only,
+		dnsSupport: true,
+		err:        `private-dns-resource-name-records: subnet "subnet-0a1b2c3d" has no IPv6 CIDR block for AAAA records`,
+	}, {
+		attrs:  testing.Attrs{"private-dns-resource-name-records": "a"},
+		subnet: ipv4Only,
+		err:    `private-dns-resource-name-records: VPC "vpc-0a1b2c3d" of subnet "subnet-0a1b2c3d" does not have DNS support enabled`,
+	}} {
+		c.Logf("test %d: %v", i, test.attrs)
+		env := s.environ(c, test.attrs, &fakePrivateDNSAPI{subnet: test.subnet, dnsSupport: test.dnsSupport})
+		err := env.validatePrivateDNSOptions("subnet-0a1b2c3d")
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		c.Check(err, jc.ErrorIsNil)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/juju/errors"
 	"github.com/juju/jsonschema"
 	"github.com/juju/loggo"
+	"github.com/juju/utils/clock"
 	"gopkg.in/amz.v3/aws"
 	"gopkg.in/amz.v3/ec2"
 
@@ -48,12 +49,40 @@ func (p environProvider) Open(args environs.OpenParams) (environs.Environ, error
 			e.cloud.Endpoint = region.EC2Endpoint
 		}
 	}
+	// Requests are sent through the model's VPC endpoint, if any,
+	// by every EC2 API client below.
+	vpcEndpoint, _ := args.Config.UnknownAttrs()["ec2-vpc-endpoint"].(string)
+	e.cloud = withVPCEndpoint(e.cloud, vpcEndpoint)
 
 	var err error
 	e.ec2, err = awsClient(e.cloud)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	wrapSigner := func(signer aws.Signer) aws.Signer {
+		return e.proxySigner(e.timeoutSigner(signer))
+	}
+	e.ec2.Sign = wrapSigner(e.ec2.Sign)
+	e.targetGroups = newTargetGroupAPI(e.cloud, wrapSigner)
+	e.licenseManager = newLicenseManagerAPI(e.cloud, wrapSigner)
+	e.ssmParameters = newSSMParameterAPI(e.cloud, wrapSigner)
+	e.launchTemplates = newLaunchTemplateAPI(e.cloud, wrapSigner)
+	e.spotPriceHistory = newSpotPriceAPI(e.cloud, wrapSigner)
+	e.vpcOwners = newVPCOwnerAPI(e.cloud, wrapSigner)
+	e.egressRules = newEgressRulesAPI(e.cloud, wrapSigner)
+	e.pricing = newPricingAPI(e.cloud, wrapSigner)
+	e.dedicatedHosts = newDedicatedHostAPI(e.cloud, wrapSigner)
+	e.privateDNS = newPrivateDNSAPI(e.cloud, wrapSigner)
+	e.dualStack = newDualStackAPI(e.cloud, wrapSigner)
+	e.inventory = newInventoryAPI(e.cloud, wrapSigner)
+	e.instanceGroups = newInstanceGroupsAPI(e.cloud, wrapSigner)
+	e.spotRequests = newSpotRequestAPI(e.cloud, wrapSigner)
+	e.instanceTypeOfferings = newInstanceTypeOfferingsAPI(e.cloud, wrapSigner)
+	e.networkACLs = newNetworkACLAPI(e.cloud, wrapSigner)
+	e.instanceTags = newInstanceTagsAPI(e.cloud, wrapSigner)
+	e.drImages = newDRImageAPI(e.cloud, wrapSigner)
+	e.clock = clock.WallClock
+	e.zoneHealth = newZoneHealth(e.clock, zoneCapacityCooldown)
 
 	if err := e.SetConfig(args.Config); err != nil {
 		return nil, errors.Trace(err)
@@ -119,11 +148,25 @@ func (p environProvider) PrepareConfig(args environs.PrepareConfigParams) (*conf
 	if err := validateCloudSpec(args.Cloud); err != nil {
 		return nil, errors.Annotate(err, "validating cloud spec")
 	}
+	if endpoint, _ := args.Config.UnknownAttrs()["ec2-vpc-endpoint"].(string); endpoint != "" {
+		if err := validateVPCEndpointRegion(endpoint, args.Cloud.Region); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if region, _ := args.Config.UnknownAttrs()["dr-secondary-region"].(string); region == args.Cloud.Region && region != "" {
+		return nil, errors.Errorf("dr-secondary-region: %q is the model's own region", region)
+	}
 	// Set the default block-storage source.
 	attrs := make(map[string]interface{})
 	if _, ok := args.Config.StorageDefaultBlockSource(); !ok {
 		attrs[config.StorageDefaultBlockSourceKey] = EBS_ProviderType
 	}
+	// New models default to gp3 root disks. Existing models, which
+	// were never prepared with this attribute, keep using the volume
+	// type of the image's root device.
+	if volumeType, _ := args.Config.UnknownAttrs()["default-root-volume-type"].(string); volumeType == "" {
+		attrs["default-root-volume-type"] = volumeTypeGP3
+	}
 	if len(attrs) == 0 {
 		return args.Config, nil
 	}
@@ -0,0 +1,55 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	proxyutils "github.com/juju/utils/proxy"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/utils/proxy"
+)
+
+// apiProxyConfig returns the proxy configuration to use for AWS API
+// requests, or nil if the model does not specify one.
+func (c *environConfig) apiProxyConfig() (*proxy.ProxyConfig, error) {
+	proxyURL := c.awsAPIProxy()
+	if proxyURL == "" {
+		return nil, nil
+	}
+	var pc proxy.ProxyConfig
+	if err := pc.Set(proxyutils.Settings{
+		Http:    proxyURL,
+		Https:   proxyURL,
+		NoProxy: c.awsAPINoProxy(),
+	}); err != nil {
+		return nil, errors.Annotate(err, "aws-api-proxy")
+	}
+	return &pc, nil
+}
+
+// proxySigner wraps the given signer so that the requests it signs
+// carry the model's AWS API proxy settings. The AWS client libraries
+// always use the default HTTP transport, whose proxy resolution
+// honours settings carried in the request context.
+//
+// The request is signed before the proxy settings are attached, so
+// signing is unaffected by them.
+func (e *environ) proxySigner(signer aws.Signer) aws.Signer {
+	return func(req *http.Request, auth aws.Auth) error {
+		if err := signer(req, auth); err != nil {
+			return err
+		}
+		pc, err := e.ecfg().apiProxyConfig()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if pc != nil {
+			*req = *req.WithContext(proxy.WithConfig(req.Context(), pc))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+	"github.com/juju/juju/utils/proxy"
+)
+
+type proxySuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&proxySuite{})
+
+func (s *proxySuite) environ(c *gc.C, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg}
+}
+
+func (s *proxySuite) signedRequest(c *gc.C, env *environ, requestURL string) *http.Request {
+	var signed bool
+	signer := env.proxySigner(func(req *http.Request, auth aws.Auth) error {
+		signed = true
+		req.Header.Set("Authorization", "signed")
+		return nil
+	})
+	req, err := http.NewRequest("GET", requestURL, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(signer(req, aws.Auth{}), jc.ErrorIsNil)
+	c.Assert(signed, jc.IsTrue)
+	c.Assert(req.Header.Get("Authorization"), gc.Equals, "signed")
+	return req
+}
+
+func (s *proxySuite) proxyFor(c *gc.C, req *http.Request) string {
+	// An unconfigured ProxyConfig stands in for the process-wide
+	// settings installed in the default transport.
+	var defaultConfig proxy.ProxyConfig
+	proxyURL, err := defaultConfig.GetProxy(req)
+	c.Assert(err, jc.ErrorIsNil)
+	if proxyURL == nil {
+		return ""
+	}
+	return proxyURL.String()
+}
+
+func (s *proxySuite) TestProxySigner(c *gc.C) {
+	env := s.environ(c, testing.Attrs{
+		"aws-api-proxy":    "http://squid.internal:3128",
+		"aws-api-no-proxy": ".example.com",
+	})
+	req := s.signedRequest(c, env, "https://ec2.us-east-1.amazonaws.com/")
+	c.Assert(s.proxyFor(c, req), gc.Equals, "http://squid.internal:3128")
+
+	req = s.signedRequest(c, env, "https://ec2.example.com/")
+	c.Assert(s.proxyFor(c, req), gc.Equals, "")
+}
+
+func (s *proxySuite) TestProxySignerNoProxy(c *gc.C) {
+	env := s.environ(c, nil)
+	req := s.signedRequest(c, env, "https://ec2.us-east-1.amazonaws.com/")
+	c.Assert(s.proxyFor(c, req), gc.Equals, "")
+}
@@ -0,0 +1,378 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/tags"
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/network"
+)
+
+// instanceGroupsAPIVersion is the EC2 API version used to change the
+// security groups attached to an instance, which the EC2 client library
+// lacks.
+const instanceGroupsAPIVersion = "2016-11-15"
+
+// maxInstanceSecurityGroups is the number of security groups that may
+// be attached to a network interface, by default.
+const maxInstanceSecurityGroups = 5
+
+// maxMachineRuleGroups is the number of security groups that may hold
+// the ingress rules of a machine: its machine group, followed by its
+// overflow groups. The model's juju group takes the remaining place
+// on the instance.
+const maxMachineRuleGroups = maxInstanceSecurityGroups - 1
+
+// instanceGroupsAPI is the subset of the EC2 API used to change the
+// security groups attached to an instance.
+type instanceGroupsAPI interface {
+	// SetInstanceGroups replaces the security groups attached to the
+	// instance with the given groups.
+	SetInstanceGroups(instId string, groupIds []string) error
+}
+
+// newInstanceGroupsAPI returns an instanceGroupsAPI for the given cloud,
+// whose request signer is wrapped with wrapSigner. It is a variable so
+// it can be replaced in tests.
+var newInstanceGroupsAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) instanceGroupsAPI {
+	return &instanceGroupsClient{newEC2Client(cloud, instanceGroupsAPIVersion, wrapSigner)}
+}
+
+// instanceGroupsClient is a minimal client for the EC2 query API, used
+// to change the security groups attached to instances.
+type instanceGroupsClient struct {
+	*awsAPIClient
+}
+
+// SetInstanceGroups is part of the instanceGroupsAPI interface.
+func (c *instanceGroupsClient) SetInstanceGroups(instId string, groupIds []string) error {
+	params := url.Values{"InstanceId": {instId}}
+	for i, id := range groupIds {
+		params.Set(fmt.Sprintf("GroupId.%d", i+1), id)
+	}
+	if err := c.query("ModifyInstanceAttribute", params, nil); err != nil {
+		return errors.Annotatef(err, "setting security groups of instance %s", instId)
+	}
+	return nil
+}
+
+// ruleGroup is one of the security groups holding a machine's ingress
+// rules, with the permissions it grants.
+type ruleGroup struct {
+	info  ec2.SecurityGroupInfo
+	perms permSet
+}
+
+// overflowGroupName returns the name of the machine's nth overflow
+// group, counting from 1, which holds the ingress rules that do not fit
+// in its machine group.
+func (e *environ) overflowGroupName(machineId string, n int) string {
+	return fmt.Sprintf("%s-%d", e.machineGroupName(machineId), n)
+}
+
+// machineRuleGroups returns the machine's security group, followed by
+// its overflow groups, if any.
+func (e *environ) machineRuleGroups(machineId string) ([]ruleGroup, error) {
+	names := make([]string, maxMachineRuleGroups)
+	names[0] = e.machineGroupName(machineId)
+	for n := 1; n < maxMachineRuleGroups; n++ {
+		names[n] = e.overflowGroupName(machineId, n)
+	}
+	filter := ec2.NewFilter()
+	filter.Add("group-name", names...)
+	if vpcID := e.ecfg().vpcID(); isVPCIDSet(vpcID) {
+		filter.Add("vpc-id", vpcID)
+	}
+	resp, err := e.ec2.SecurityGroups(nil, filter)
+	if err != nil {
+		return nil, errors.Annotatef(err, "listing security groups of machine %s", machineId)
+	}
+	byName := make(map[string]ec2.SecurityGroupInfo)
+	for _, info := range resp.Groups {
+		byName[info.Name] = info
+	}
+	if _, ok := byName[names[0]]; !ok {
+		return nil, errors.NotFoundf("security group %q", names[0])
+	}
+	var groups []ruleGroup
+	for _, name := range names {
+		if info, ok := byName[name]; ok {
+			groups = append(groups, ruleGroup{
+				info:  info,
+				perms: newPermSetForGroup(info.IPPerms, info.SecurityGroup),
+			})
+		}
+	}
+	return groups, nil
+}
+
+// sortedPermKeys returns the permissions in the set, ordered so that
+// rules are split across groups the same way every time.
+func sortedPermKeys(perms permSet) []permKey {
+	keys := make([]permKey, 0, len(perms))
+	for k := range perms {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.protocol != b.protocol {
+			return a.protocol < b.protocol
+		}
+		if a.fromPort != b.fromPort {
+			return a.fromPort < b.fromPort
+		}
+		if a.toPort != b.toPort {
+			return a.toPort < b.toPort
+		}
+		if a.groupId != b.groupId {
+			return a.groupId < b.groupId
+		}
+		return a.ipAddr < b.ipAddr
+	})
+	return keys
+}
+
+// allocateRules assigns the rules to be added to the free space of
+// groups already holding the given numbers of rules, in order, and then
+// to new groups of at most max rules each. It returns the rules to add
+// to each group, existing groups first.
+func allocateRules(held []int, add []permKey, max int) [][]permKey {
+	var result [][]permKey
+	for i := 0; len(add) > 0 || i < len(held); i++ {
+		free := max
+		if i < len(held) {
+			free -= held[i]
+		}
+		if free < 0 {
+			free = 0
+		}
+		if free > len(add) {
+			free = len(add)
+		}
+		result = append(result, add[:free])
+		add = add[free:]
+	}
+	return result
+}
+
+// machineGroupsController returns the UUID of the controller that manages
+// the instance, with which new security groups are tagged.
+func machineGroupsController(inst *ec2.Instance) string {
+	for _, tag := range inst.Tags {
+		if tag.Key == tags.JujuController {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// setInstanceGroupIds replaces the security groups attached to the
+// instance with its current groups, less those in remove, plus add.
+func (e *environ) setInstanceGroupIds(instId instance.Id, add, remove *ec2.SecurityGroup) error {
+	current, err := e.instanceSecurityGroups([]instance.Id{instId})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var groupIds []string
+	for _, g := range current {
+		if (remove != nil && g.Id == remove.Id) || (add != nil && g.Id == add.Id) {
+			continue
+		}
+		groupIds = append(groupIds, g.Id)
+	}
+	if add != nil {
+		groupIds = append(groupIds, add.Id)
+	}
+	return errors.Trace(e.instanceGroups.SetInstanceGroups(string(instId), groupIds))
+}
+
+// openMachinePorts grants the ingress rules in the machine's security
+// groups. Rules that do not fit in the machine group, of at most
+// max-rules-per-security-group rules, are put in overflow groups, which
+// are created and attached to the instance as needed.
+func (e *environ) openMachinePorts(inst *ec2.Instance, machineId string, rules []network.IngressRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	groups, err := e.machineRuleGroups(machineId)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	want := newPermSetForGroup(rulesToIPPerms(rules), zeroGroup)
+	var total int
+	held := make([]int, len(groups))
+	for i, g := range groups {
+		held[i] = len(g.perms)
+		total += len(g.perms)
+		for k := range g.perms {
+			delete(want, k)
+		}
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	max := e.ecfg().maxRulesPerSecurityGroup()
+	// Nothing is created unless all the rules fit, so that no group
+	// beyond the machine's last overflow group is ever created.
+	allocated := allocateRules(held, sortedPermKeys(want), max)
+	if len(allocated) > maxMachineRuleGroups {
+		return errors.Errorf(
+			"cannot open ports for machine %s: %d ingress rules do not fit in %d security groups of at most %d rules (max-rules-per-security-group)",
+			machineId, total+len(want), maxMachineRuleGroups, max,
+		)
+	}
+	for i, add := range allocated {
+		if len(add) == 0 {
+			continue
+		}
+		if i == len(groups) {
+			g, err := e.addOverflowGroup(inst, machineId, groups)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			groups = append(groups, g)
+		}
+		perms := make(permSet)
+		for _, k := range add {
+			perms[k] = true
+		}
+		if err := e.authorizeIngress(groups[i].info.SecurityGroup, perms.ipPerms()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// addOverflowGroup creates the next overflow group of the machine and
+// attaches it to the machine's instance.
+func (e *environ) addOverflowGroup(inst *ec2.Instance, machineId string, groups []ruleGroup) (ruleGroup, error) {
+	inUse := make(map[string]bool)
+	for _, g := range groups {
+		inUse[g.info.Name] = true
+	}
+	var name string
+	for n := 1; name == "" || inUse[name]; n++ {
+		name = e.overflowGroupName(machineId, n)
+	}
+	g, err := e.ensureGroup(e.modelResourceTags(machineGroupsController(inst), inst.Tags), name, nil)
+	if err != nil {
+		return ruleGroup{}, errors.Trace(err)
+	}
+	if err := e.setInstanceGroupIds(instance.Id(inst.InstanceId), &g, nil); err != nil {
+		return ruleGroup{}, errors.Annotatef(err, "attaching security group %q", name)
+	}
+	logger.Infof("attached overflow security group %s to instance %s", name, inst.InstanceId)
+	return ruleGroup{
+		info:  ec2.SecurityGroupInfo{SecurityGroup: g},
+		perms: make(permSet),
+	}, nil
+}
+
+// closeMachinePorts revokes the ingress rules from the machine's
+// security groups, and then removes those of its overflow groups whose
+// rules fit in the others.
+func (e *environ) closeMachinePorts(inst *ec2.Instance, machineId string, rules []network.IngressRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	groups, err := e.machineRuleGroups(machineId)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	remove := newPermSetForGroup(rulesToIPPerms(rules), zeroGroup)
+	for _, g := range groups {
+		revoke := make(permSet)
+		for k := range remove {
+			if g.perms[k] {
+				revoke[k] = true
+				delete(g.perms, k)
+			}
+		}
+		if len(revoke) == 0 {
+			continue
+		}
+		if _, err := e.ec2.RevokeSecurityGroup(g.info.SecurityGroup, revoke.ipPerms()); err != nil {
+			return fmt.Errorf("cannot close ports: %v", err)
+		}
+	}
+	return errors.Trace(e.consolidateRuleGroups(inst, groups))
+}
+
+// consolidateRuleGroups moves the rules of the last of the machine's
+// overflow groups into the free space of its other groups, and then
+// detaches and deletes it, for as long as they fit.
+func (e *environ) consolidateRuleGroups(inst *ec2.Instance, groups []ruleGroup) error {
+	max := e.ecfg().maxRulesPerSecurityGroup()
+	for len(groups) > 1 {
+		last := groups[len(groups)-1]
+		groups = groups[:len(groups)-1]
+		held := make([]int, len(groups))
+		for i, g := range groups {
+			held[i] = len(g.perms)
+		}
+		moves := allocateRules(held, sortedPermKeys(last.perms), max)
+		if len(moves) > len(groups) {
+			// The rules do not fit in the other groups.
+			return nil
+		}
+		for i, move := range moves {
+			if len(move) == 0 {
+				continue
+			}
+			perms := make(permSet)
+			for _, k := range move {
+				perms[k] = true
+				groups[i].perms[k] = true
+			}
+			if err := e.authorizeIngress(groups[i].info.SecurityGroup, perms.ipPerms()); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err := e.setInstanceGroupIds(instance.Id(inst.InstanceId), nil, &last.info.SecurityGroup); err != nil {
+			return errors.Annotatef(err, "detaching security group %q", last.info.Name)
+		}
+		if err := deleteSecurityGroupInsistently(e.ec2, last.info.SecurityGroup, e.clock); err != nil {
+			return errors.Annotatef(err, "deleting security group %q", last.info.Name)
+		}
+		logger.Infof("removed overflow security group %s from instance %s", last.info.Name, inst.InstanceId)
+	}
+	return nil
+}
+
+// machineIngressRules returns the ingress rules granted by the machine's
+// security groups. Rules for the same ports that are split across
+// groups are merged.
+func (e *environ) machineIngressRules(machineId string) ([]network.IngressRule, error) {
+	groups, err := e.machineRuleGroups(machineId)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var rules []network.IngressRule
+	index := make(map[network.PortRange]int)
+	for _, g := range groups {
+		for _, p := range g.info.IPPerms {
+			rule, err := ipPermToIngressRule(p)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if i, ok := index[rule.PortRange]; ok {
+				rules[i].SourceCIDRs = append(rules[i].SourceCIDRs, rule.SourceCIDRs...)
+				continue
+			}
+			index[rule.PortRange] = len(rules)
+			rules = append(rules, rule)
+		}
+	}
+	network.SortIngressRules(rules)
+	return rules, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type ruleGroupsSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *instanceGroupsClient
+}
+
+var _ = gc.Suite(&ruleGroupsSuite{})
+
+func (s *ruleGroupsSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &instanceGroupsClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  instanceGroupsAPIVersion,
+	}}
+}
+
+func (s *ruleGroupsSuite) TestSetInstanceGroups(c *gc.C) {
+	s.responses = []string{`<ModifyInstanceAttributeResponse><return>true</return></ModifyInstanceAttributeResponse>`}
+	err := s.client.SetInstanceGroups("i-1", []string{"sg-1", "sg-2"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "ModifyInstanceAttribute")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, instanceGroupsAPIVersion)
+	c.Check(s.requests[0].Get("InstanceId"), gc.Equals, "i-1")
+	c.Check(s.requests[0].Get("GroupId.1"), gc.Equals, "sg-1")
+	c.Check(s.requests[0].Get("GroupId.2"), gc.Equals, "sg-2")
+}
+
+func (s *ruleGroupsSuite) TestSetInstanceGroupsError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.responses = []string{`
+<Response><Errors><Error>
+  <Code>SecurityGroupsPerInstanceLimitExceeded</Code>
+  <Message>too many groups</Message>
+</Error></Errors></Response>`}
+	err := s.client.SetInstanceGroups("i-1", []string{"sg-1"})
+	c.Assert(err, gc.ErrorMatches, `setting security groups of instance i-1: too many groups \(SecurityGroupsPerInstanceLimitExceeded\)`)
+}
+
+func (s *ruleGroupsSuite) TestAllocateRules(c *gc.C) {
+	keys := sortedPermKeys(permSet{
+		{protocol: "tcp", fromPort: 443, toPort: 443, ipAddr: "10.0.0.0/24"}: true,
+		{protocol: "tcp", fromPort: 80, toPort: 80, ipAddr: "10.0.1.0/24"}:   true,
+		{protocol: "tcp", fromPort: 80, toPort: 80, ipAddr: "10.0.0.0/24"}:   true,
+		{protocol: "udp", fromPort: 53, toPort: 53, ipAddr: "10.0.0.0/24"}:   true,
+	})
+	c.Assert(keys, jc.DeepEquals, []permKey{
+		{protocol: "tcp", fromPort: 80, toPort: 80, ipAddr: "10.0.0.0/24"},
+		{protocol: "tcp", fromPort: 80, toPort: 80, ipAddr: "10.0.1.0/24"},
+		{protocol: "tcp", fromPort: 443, toPort: 443, ipAddr: "10.0.0.0/24"},
+		{protocol: "udp", fromPort: 53, toPort: 53, ipAddr: "10.0.0.0/24"},
+	})
+
+	// Free space in existing groups is used first, in order.
+	c.Assert(allocateRules([]int{2, 1}, keys, 3), jc.DeepEquals, [][]permKey{
+		keys[:1], keys[1:3], keys[3:],
+	})
+	// Full groups are given no rules.
+	c.Assert(allocateRules([]int{3}, keys[:2], 2), jc.DeepEquals, [][]permKey{
+		{}, keys[:2],
+	})
+	// Every existing group is listed, even with nothing to add.
+	c.Assert(allocateRules([]int{1, 0}, nil, 2), jc.DeepEquals, [][]permKey{
+		nil, nil,
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/http"
+	"net/url"
+
+	"gopkg.in/amz.v3/aws"
+)
+
+// runInstancesAPIVersion is the EC2 API version used for RunInstances
+// requests that carry parameters the EC2 client library has no support
+// for; the version used by the library predates them.
+const runInstancesAPIVersion = "2016-11-15"
+
+// runInstancesExtrasArgs holds the details of a launch that determine
+// the parameters added to its RunInstances request.
+type runInstancesExtrasArgs struct {
+	// placement is the instance's placement, if any.
+	placement *ec2Placement
+
+	// ipv6 is true if the instance is launched into a dual-stack
+	// subnet.
+	ipv6 bool
+
+	// launch identifies the launch, and so its ClientToken.
+	launch runInstancesLaunch
+}
+
+// runInstancesExtras returns the parameters, unsupported by the EC2
+// client library, that are added to the RunInstances request of the
+// given launch: those of the model config, and those of the launch
+// itself. Every such parameter is gathered here, so that they are all
+// applied together by a single runInstancesSigner.
+func (e *environ) runInstancesExtras(args runInstancesExtrasArgs) url.Values {
+	ecfg := e.ecfg()
+	extras := make(url.Values)
+	autoRecoveryParams(ecfg, extras)
+	privateDNSParams(ecfg, extras)
+	licenseParams(ecfg, extras)
+	launchTemplateParams(ecfg, extras)
+	if args.placement != nil && args.placement.host != nil {
+		args.placement.host.addParams(extras)
+	}
+	if args.placement != nil && args.placement.group != nil {
+		args.placement.group.addParams(extras)
+	}
+	if args.ipv6 {
+		ipv6AddressParams(extras)
+	}
+	args.launch.addParams(extras)
+	return extras
+}
+
+// runInstancesSigner wraps the given signer so that RunInstances
+// requests carry the given extra parameters, which are added to the
+// request before it is signed. Other requests are left alone.
+func runInstancesSigner(signer aws.Signer, extras url.Values) aws.Signer {
+	return func(req *http.Request, auth aws.Auth) error {
+		query := req.URL.Query()
+		if query.Get("Action") == "RunInstances" && len(extras) > 0 {
+			query.Set("Version", runInstancesAPIVersion)
+			for key, values := range extras {
+				query[key] = values
+			}
+			req.URL.RawQuery = query.Encode()
+		}
+		return signer(req, auth)
+	}
+}
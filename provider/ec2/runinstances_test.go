@@ -0,0 +1,101 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/http"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type runInstancesSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&runInstancesSuite{})
+
+func (s *runInstancesSuite) environ(c *gc.C, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg}
+}
+
+func (s *runInstancesSuite) TestRunInstancesExtras(c *gc.C) {
+	env := s.environ(c, testing.Attrs{
+		"instance-auto-recovery":    true,
+		"license-configuration-arn": testLicenseConfigurationARN,
+	})
+	// Every parameter is kept, however many features add them.
+	extras := env.runInstancesExtras(runInstancesExtrasArgs{
+		placement: &ec2Placement{
+			host:  &hostPlacement{hostId: "h-0123456789abcdef0", affinity: "host"},
+			group: &placementGroup{name: "kafka", strategy: "partition", partition: 2},
+		},
+		ipv6:   true,
+		launch: testLaunch,
+	})
+	c.Assert(extras, jc.DeepEquals, url.Values{
+		"MaintenanceOptions.AutoRecovery":                {"default"},
+		"LicenseSpecification.1.LicenseConfigurationArn": {testLicenseConfigurationARN},
+		"Placement.Tenancy":                              {"host"},
+		"Placement.HostId":                               {"h-0123456789abcdef0"},
+		"Placement.Affinity":                             {"host"},
+		"Placement.GroupName":                            {"kafka"},
+		"Placement.PartitionNumber":                      {"2"},
+		"Ipv6AddressCount":                               {"1"},
+		"ClientToken":                                    {testLaunch.clientToken()},
+	})
+}
+
+func (s *runInstancesSuite) TestRunInstancesExtrasClientTokenOnly(c *gc.C) {
+	env := s.environ(c, nil)
+	extras := env.runInstancesExtras(runInstancesExtrasArgs{launch: testLaunch})
+	c.Assert(extras, jc.DeepEquals, url.Values{
+		"ClientToken": {testLaunch.clientToken()},
+	})
+}
+
+func (s *runInstancesSuite) signedQuery(c *gc.C, extras url.Values, action string) url.Values {
+	signer := runInstancesSigner(func(req *http.Request, auth aws.Auth) error {
+		req.Header.Set("Authorization", "signed")
+		return nil
+	}, extras)
+	req, err := http.NewRequest("GET", "https://ec2.us-east-1.amazonaws.com/?Version=2014-10-01&Action="+action, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(signer(req, aws.Auth{}), jc.ErrorIsNil)
+	c.Assert(req.Header.Get("Authorization"), gc.Equals, "signed")
+	return req.URL.Query()
+}
+
+func (s *runInstancesSuite) TestRunInstancesSigner(c *gc.C) {
+	extras := url.Values{
+		"Placement.GroupName": {"hpc"},
+		"ClientToken":         {"token"},
+	}
+	c.Assert(s.signedQuery(c, extras, "RunInstances"), jc.DeepEquals, url.Values{
+		"Action":              {"RunInstances"},
+		"Version":             {runInstancesAPIVersion},
+		"Placement.GroupName": {"hpc"},
+		"ClientToken":         {"token"},
+	})
+	c.Assert(s.signedQuery(c, extras, "DescribeInstances"), jc.DeepEquals, url.Values{
+		"Action":  {"DescribeInstances"},
+		"Version": {"2014-10-01"},
+	})
+}
+
+func (s *runInstancesSuite) TestRunInstancesSignerNoExtras(c *gc.C) {
+	c.Assert(s.signedQuery(c, nil, "RunInstances"), jc.DeepEquals, url.Values{
+		"Action":  {"RunInstances"},
+		"Version": {"2014-10-01"},
+	})
+}
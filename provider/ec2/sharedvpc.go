@@ -0,0 +1,172 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+
+	"github.com/juju/juju/environs"
+)
+
+// vpcOwnerAPIVersion is the EC2 API version used to query the owners of
+// VPCs; the version used by the EC2 client library predates them.
+const vpcOwnerAPIVersion = "2016-11-15"
+
+// stsAPIVersion is the version of the AWS Security Token Service API
+// used to find the account in use.
+const stsAPIVersion = "2011-06-15"
+
+// vpcOwnerAPI is the subset of the EC2 and STS APIs used to tell
+// whether a VPC is shared into the account by another, using AWS
+// Resource Access Manager (RAM).
+type vpcOwnerAPI interface {
+	// VPCOwner returns the ID of the account that owns the VPC, or
+	// "" if that is not reported.
+	VPCOwner(vpcID string) (string, error)
+
+	// AccountID returns the ID of the account in use.
+	AccountID() (string, error)
+}
+
+// newVPCOwnerAPI returns a vpcOwnerAPI for the given cloud, whose
+// request signers are wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newVPCOwnerAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) vpcOwnerAPI {
+	sts := newAWSAPIClient(cloud, cloud.Region, "sts", "", wrapSigner)
+	sts.version = stsAPIVersion
+	return &vpcOwnerClient{
+		ec2: newEC2Client(cloud, vpcOwnerAPIVersion, wrapSigner),
+		sts: sts,
+	}
+}
+
+// vpcOwnerClient is a minimal client for the EC2 and STS query APIs,
+// used to find the owners of VPCs and the account in use.
+type vpcOwnerClient struct {
+	ec2 *awsAPIClient
+	sts *awsAPIClient
+
+	mu        sync.Mutex
+	accountID string
+}
+
+// VPCOwner is part of the vpcOwnerAPI interface.
+func (c *vpcOwnerClient) VPCOwner(vpcID string) (string, error) {
+	params := url.Values{"VpcId.1": {vpcID}}
+	var resp struct {
+		VPCs []struct {
+			Id      string `xml:"vpcId"`
+			OwnerId string `xml:"ownerId"`
+		} `xml:"vpcSet>item"`
+	}
+	if err := c.ec2.query("DescribeVpcs", params, &resp); err != nil {
+		return "", errors.Annotatef(err, "getting owner of VPC %q", vpcID)
+	}
+	for _, vpc := range resp.VPCs {
+		if vpc.Id == vpcID {
+			return vpc.OwnerId, nil
+		}
+	}
+	return "", errors.NotFoundf("VPC %q", vpcID)
+}
+
+// AccountID is part of the vpcOwnerAPI interface.
+func (c *vpcOwnerClient) AccountID() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accountID != "" {
+		return c.accountID, nil
+	}
+	var resp struct {
+		Account string `xml:"GetCallerIdentityResult>Account"`
+	}
+	if err := c.sts.query("GetCallerIdentity", url.Values{}, &resp); err != nil {
+		return "", errors.Annotate(err, "getting account ID")
+	}
+	c.accountID = resp.Account
+	return c.accountID, nil
+}
+
+// sharedVPCOwner returns the ID of the account that owns the given VPC
+// if it is shared into this account by another, or "" if this account
+// owns it, or its owner is not reported.
+func (e *environ) sharedVPCOwner(vpcID string) (string, error) {
+	owner, err := e.vpcOwners.VPCOwner(vpcID)
+	if err != nil || owner == "" {
+		return "", errors.Trace(err)
+	}
+	account, err := e.vpcOwners.AccountID()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if owner == account {
+		return "", nil
+	}
+	return owner, nil
+}
+
+// sharedVPCAPIClient is implemented by VPC API clients that can also
+// tell whether a VPC is shared into the account by another.
+type sharedVPCAPIClient interface {
+	vpcAPIClient
+
+	// SharedVPCOwner returns the ID of the account that owns the
+	// VPC if it is shared into this account by another, or "".
+	SharedVPCOwner(vpcID string) (string, error)
+}
+
+// vpcValidationClient is the sharedVPCAPIClient used by an environ to
+// validate its VPC.
+type vpcValidationClient struct {
+	*ec2.EC2
+	env *environ
+}
+
+// SharedVPCOwner is part of the sharedVPCAPIClient interface.
+func (c vpcValidationClient) SharedVPCOwner(vpcID string) (string, error) {
+	return c.env.sharedVPCOwner(vpcID)
+}
+
+// validateSharedVPC validates a VPC shared into the account by the given
+// owner. Only the subnets shared with the account are visible, and its
+// internet gateway and route tables belong to the owner, so all that can
+// be checked is that some subnets are shared. Returns an error satisfying
+// isVPCNotUsableError() when none are.
+func validateSharedVPC(apiClient vpcAPIClient, vpc *ec2.VPC, ownerID string) error {
+	subnets, err := getVPCSubnets(apiClient, vpc)
+	if isVPCNotUsableError(err) {
+		return vpcNotUsablef(nil, "no subnets of VPC %q, owned by account %q, are shared with this account", vpc.Id, ownerID)
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	logger.Infof(
+		"VPC %q is shared by account %q with %d subnets; not checking its internet gateway and routes, which belong to the owner",
+		vpc.Id, ownerID, len(subnets),
+	)
+	return nil
+}
+
+// isSharedSubnetPermissionError reports whether the error indicates that
+// RunInstances failed because the account may not launch instances into
+// the chosen subnet. This happens when a subnet of a shared VPC is not,
+// or is no longer, shared with the account.
+func isSharedSubnetPermissionError(err error) bool {
+	ec2err, _ := errors.Cause(err).(*ec2.Error)
+	if ec2err == nil {
+		return false
+	}
+	switch ec2err.Code {
+	case "UnauthorizedOperation", "InvalidSubnetID.NotFound":
+		return true
+	case "AuthFailure":
+		return strings.Contains(ec2err.Message, "subnet")
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type vpcOwnerSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *vpcOwnerClient
+}
+
+var _ = gc.Suite(&vpcOwnerSuite{})
+
+func (s *vpcOwnerSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &vpcOwnerClient{
+		ec2: &awsAPIClient{
+			auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+			endpoint: s.server.URL + "/",
+			sign:     aws.SignV4Factory("us-east-1", "ec2"),
+			version:  vpcOwnerAPIVersion,
+		},
+		sts: &awsAPIClient{
+			auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+			endpoint: s.server.URL + "/",
+			sign:     aws.SignV4Factory("us-east-1", "sts"),
+			version:  stsAPIVersion,
+		},
+	}
+}
+
+const describeVPCsResponse = `
+<DescribeVpcsResponse>
+  <vpcSet>
+    <item>
+      <vpcId>vpc-shared</vpcId>
+      <ownerId>111111111111</ownerId>
+    </item>
+  </vpcSet>
+</DescribeVpcsResponse>`
+
+const getCallerIdentityResponse = `
+<GetCallerIdentityResponse>
+  <GetCallerIdentityResult>
+    <Account>222222222222</Account>
+  </GetCallerIdentityResult>
+</GetCallerIdentityResponse>`
+
+func (s *vpcOwnerSuite) TestVPCOwner(c *gc.C) {
+	s.responses = []string{describeVPCsResponse}
+	owner, err := s.client.VPCOwner("vpc-shared")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "111111111111")
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DescribeVpcs")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, vpcOwnerAPIVersion)
+	c.Check(s.requests[0].Get("VpcId.1"), gc.Equals, "vpc-shared")
+}
+
+func (s *vpcOwnerSuite) TestVPCOwnerError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.responses = []string{`
+<Response>
+  <Errors>
+    <Error>
+      <Code>InvalidVpcID.NotFound</Code>
+      <Message>not found</Message>
+    </Error>
+  </Errors>
+</Response>`}
+	_, err := s.client.VPCOwner("vpc-shared")
+	c.Assert(err, gc.ErrorMatches, `getting owner of VPC "vpc-shared": not found \(InvalidVpcID.NotFound\)`)
+}
+
+func (s *vpcOwnerSuite) TestAccountID(c *gc.C) {
+	s.responses = []string{getCallerIdentityResponse}
+	for i := 0; i < 2; i++ {
+		account, err := s.client.AccountID()
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(account, gc.Equals, "222222222222")
+	}
+
+	// The account ID is only requested once.
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "GetCallerIdentity")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, stsAPIVersion)
+}
+
+func (s *vpcOwnerSuite) TestAccountIDError(c *gc.C) {
+	s.status = http.StatusForbidden
+	s.responses = []string{`
+<ErrorResponse>
+  <Error>
+    <Code>AccessDenied</Code>
+    <Message>denied</Message>
+  </Error>
+</ErrorResponse>`}
+	_, err := s.client.AccountID()
+	c.Assert(err, gc.ErrorMatches, `getting account ID: denied \(AccessDenied\)`)
+}
+
+func (s *vpcOwnerSuite) TestSharedVPCOwner(c *gc.C) {
+	env := &environ{vpcOwners: s.client}
+	s.responses = []string{describeVPCsResponse, getCallerIdentityResponse}
+	owner, err := env.sharedVPCOwner("vpc-shared")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "111111111111")
+}
+
+func (s *vpcOwnerSuite) TestSharedVPCOwnerOwnedByAccount(c *gc.C) {
+	env := &environ{vpcOwners: s.client}
+	s.client.accountID = "111111111111"
+	s.responses = []string{describeVPCsResponse}
+	owner, err := env.sharedVPCOwner("vpc-shared")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "")
+}
+
+func (s *vpcOwnerSuite) TestSharedVPCOwnerNotReported(c *gc.C) {
+	env := &environ{vpcOwners: s.client}
+	s.responses = []string{`<DescribeVpcsResponse><vpcSet><item><vpcId>vpc-shared</vpcId></item></vpcSet></DescribeVpcsResponse>`}
+	owner, err := env.sharedVPCOwner("vpc-shared")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(owner, gc.Equals, "")
+
+	// Without an owner, the account is not needed.
+	c.Assert(s.requests, gc.HasLen, 1)
+}
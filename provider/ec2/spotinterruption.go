@@ -0,0 +1,115 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/instance"
+)
+
+// spotRequestAPIVersion is the EC2 API version used to describe spot
+// instance requests, which the EC2 client library lacks.
+const spotRequestAPIVersion = "2016-11-15"
+
+// spotInterruptionCodePrefix prefixes the status codes of spot instance
+// requests whose instances AWS has given notice it will interrupt, such
+// as marked-for-termination and marked-for-stop. The notice is given
+// two minutes before the instance is interrupted.
+const spotInterruptionCodePrefix = "marked-for-"
+
+// spotRequestAPI is the subset of the EC2 API used to look for notices
+// of the interruption of spot instances.
+type spotRequestAPI interface {
+	// SpotInstanceRequests returns the spot instance requests that
+	// launched any of the given instances. Instances that are not
+	// spot instances have none.
+	SpotInstanceRequests(instIds []string) ([]spotInstanceRequest, error)
+}
+
+// spotInstanceRequest holds the status of the spot instance request
+// that launched an instance.
+type spotInstanceRequest struct {
+	Id         string    `xml:"spotInstanceRequestId"`
+	InstanceId string    `xml:"instanceId"`
+	StatusCode string    `xml:"status>code"`
+	UpdateTime time.Time `xml:"status>updateTime"`
+}
+
+// interrupted reports whether AWS has given notice that it will
+// interrupt the request's instance.
+func (r *spotInstanceRequest) interrupted() bool {
+	return strings.HasPrefix(r.StatusCode, spotInterruptionCodePrefix)
+}
+
+// newSpotRequestAPI returns a spotRequestAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newSpotRequestAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) spotRequestAPI {
+	return &spotRequestClient{newEC2Client(cloud, spotRequestAPIVersion, wrapSigner)}
+}
+
+// spotRequestClient is a minimal client for the EC2 query API, used to
+// describe spot instance requests.
+type spotRequestClient struct {
+	*awsAPIClient
+}
+
+// SpotInstanceRequests is part of the spotRequestAPI interface.
+func (c *spotRequestClient) SpotInstanceRequests(instIds []string) ([]spotInstanceRequest, error) {
+	params := url.Values{"Filter.1.Name": {"instance-id"}}
+	for i, id := range instIds {
+		params.Set(fmt.Sprintf("Filter.1.Value.%d", i+1), id)
+	}
+	var resp struct {
+		Requests []spotInstanceRequest `xml:"spotInstanceRequestSet>item"`
+	}
+	if err := c.query("DescribeSpotInstanceRequests", params, &resp); err != nil {
+		return nil, errors.Annotate(err, "describing spot instance requests")
+	}
+	return resp.Requests, nil
+}
+
+// addSpotRequests records, on each of the given instances that is a spot
+// instance, the spot instance request that launched it, so that notices
+// of its interruption are reported in its status. Spot instance requests
+// are only looked up if spot-interruption-notices is enabled. Failing to
+// look them up does not prevent the instances being reported, so the
+// error is only logged.
+func (e *environ) addSpotRequests(insts []instance.Instance) {
+	if !e.ecfg().spotInterruptionNotices() {
+		return
+	}
+	byId := make(map[string]*ec2Instance)
+	var instIds []string
+	for _, inst := range insts {
+		if inst, ok := inst.(*ec2Instance); ok && inst.State.Name == instanceStateRunning {
+			byId[inst.InstanceId] = inst
+			instIds = append(instIds, inst.InstanceId)
+		}
+	}
+	for start := 0; start < len(instIds); start += maxInventoryFilterValues {
+		end := start + maxInventoryFilterValues
+		if end > len(instIds) {
+			end = len(instIds)
+		}
+		requests, err := e.spotRequests.SpotInstanceRequests(instIds[start:end])
+		if err != nil {
+			logger.Warningf("cannot check for spot interruption notices: %v", err)
+			return
+		}
+		for i := range requests {
+			if inst, ok := byId[requests[i].InstanceId]; ok {
+				inst.spotRequest = &requests[i]
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	amzec2 "gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/status"
+	"github.com/juju/juju/testing"
+)
+
+type spotInterruptionSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *spotRequestClient
+}
+
+var _ = gc.Suite(&spotInterruptionSuite{})
+
+func (s *spotInterruptionSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &spotRequestClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  spotRequestAPIVersion,
+	}}
+}
+
+func (s *spotInterruptionSuite) TestSpotInstanceRequests(c *gc.C) {
+	s.responses = []string{`
+<DescribeSpotInstanceRequestsResponse>
+  <spotInstanceRequestSet>
+    <item>
+      <spotInstanceRequestId>sir-1</spotInstanceRequestId>
+      <instanceId>i-1</instanceId>
+      <status>
+        <code>marked-for-termination</code>
+        <updateTime>2017-05-10T12:30:00.000Z</updateTime>
+      </status>
+    </item>
+  </spotInstanceRequestSet>
+</DescribeSpotInstanceRequestsResponse>`}
+	requests, err := s.client.SpotInstanceRequests([]string{"i-1", "i-2"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(requests, jc.DeepEquals, []spotInstanceRequest{{
+		Id:         "sir-1",
+		InstanceId: "i-1",
+		StatusCode: "marked-for-termination",
+		UpdateTime: time.Date(2017, 5, 10, 12, 30, 0, 0, time.UTC),
+	}})
+	c.Check(requests[0].interrupted(), jc.IsTrue)
+
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DescribeSpotInstanceRequests")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, spotRequestAPIVersion)
+	c.Check(s.requests[0].Get("Filter.1.Name"), gc.Equals, "instance-id")
+	c.Check(s.requests[0].Get("Filter.1.Value.1"), gc.Equals, "i-1")
+	c.Check(s.requests[0].Get("Filter.1.Value.2"), gc.Equals, "i-2")
+}
+
+func (s *spotInterruptionSuite) TestSpotInstanceRequestsError(c *gc.C) {
+	s.status = http.StatusForbidden
+	s.responses = []string{`
+<Response><Errors><Error>
+  <Code>UnauthorizedOperation</Code>
+  <Message>not allowed</Message>
+</Error></Errors></Response>`}
+	_, err := s.client.SpotInstanceRequests([]string{"i-1"})
+	c.Assert(err, gc.ErrorMatches, `describing spot instance requests: not allowed \(UnauthorizedOperation\)`)
+}
+
+func (s *spotInterruptionSuite) TestInstanceStatus(c *gc.C) {
+	inst := &ec2Instance{Instance: &amzec2.Instance{
+		InstanceId: "i-1",
+		State:      amzec2.InstanceState{Name: "running"},
+	}}
+	c.Check(inst.Interruptible(), jc.IsFalse)
+	c.Check(inst.Status().Status, gc.Equals, status.Running)
+	c.Check(inst.Status().Message, gc.Equals, "running")
+
+	inst.spotRequest = &spotInstanceRequest{
+		InstanceId: "i-1",
+		StatusCode: "fulfilled",
+	}
+	c.Check(inst.Interruptible(), jc.IsTrue)
+	c.Check(inst.Status().Status, gc.Equals, status.Running)
+	c.Check(inst.Status().Message, gc.Equals, "running")
+
+	inst.spotRequest.StatusCode = "marked-for-termination"
+	inst.spotRequest.UpdateTime = time.Date(2017, 5, 10, 12, 30, 0, 0, time.UTC)
+	instStatus := inst.Status()
+	c.Check(instStatus.Status, gc.Equals, status.Interrupting)
+	c.Check(instStatus.Message, gc.Equals, "running: spot interruption notice marked-for-termination since 2017-05-10T12:30:00Z")
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+)
+
+// spotPriceAPIVersion is the EC2 API version used to query spot price
+// history; the EC2 client library has no support for it.
+const spotPriceAPIVersion = "2016-11-15"
+
+// spotPriceHistoryWindow is how far back spot price history is queried
+// when ordering availability zones by price.
+const spotPriceHistoryWindow = time.Hour
+
+// spotProductDescription is the product description for which spot
+// prices are queried, matching the images Juju launches.
+const spotProductDescription = "Linux/UNIX"
+
+// spotPriceAPI is the subset of the EC2 API used to query spot price
+// history.
+type spotPriceAPI interface {
+	SpotPriceHistory(instanceType string, since time.Time) ([]spotPrice, error)
+}
+
+// spotPrice records the spot price of an instance type in an
+// availability zone from a point in time.
+type spotPrice struct {
+	InstanceType     string    `xml:"instanceType"`
+	AvailabilityZone string    `xml:"availabilityZone"`
+	Price            string    `xml:"spotPrice"`
+	Timestamp        time.Time `xml:"timestamp"`
+}
+
+// newSpotPriceAPI returns a spotPriceAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newSpotPriceAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) spotPriceAPI {
+	return &spotPriceClient{newEC2Client(cloud, spotPriceAPIVersion, wrapSigner)}
+}
+
+// spotPriceClient is a minimal client for the EC2 query API's spot
+// price history.
+type spotPriceClient struct {
+	*awsAPIClient
+}
+
+// SpotPriceHistory is part of the spotPriceAPI interface.
+func (c *spotPriceClient) SpotPriceHistory(instanceType string, since time.Time) ([]spotPrice, error) {
+	var prices []spotPrice
+	var nextToken string
+	for {
+		params := url.Values{
+			"InstanceType.1":       {instanceType},
+			"ProductDescription.1": {spotProductDescription},
+			"StartTime":            {since.In(time.UTC).Format(time.RFC3339)},
+		}
+		if nextToken != "" {
+			params.Set("NextToken", nextToken)
+		}
+		var resp struct {
+			Prices    []spotPrice `xml:"spotPriceHistorySet>item"`
+			NextToken string      `xml:"nextToken"`
+		}
+		if err := c.query("DescribeSpotPriceHistory", params, &resp); err != nil {
+			return nil, errors.Trace(err)
+		}
+		prices = append(prices, resp.Prices...)
+		if resp.NextToken == "" {
+			return prices, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// spotPrices returns the most recent spot price of the given instance
+// type in each availability zone for which one is known.
+func (e *environ) spotPrices(instanceType string) (map[string]float64, error) {
+	history, err := e.spotPriceHistory.SpotPriceHistory(instanceType, time.Now().Add(-spotPriceHistoryWindow))
+	if err != nil {
+		return nil, errors.Annotatef(err, "querying spot price history for %q", instanceType)
+	}
+	prices := make(map[string]float64)
+	latest := make(map[string]time.Time)
+	for _, p := range history {
+		if p.InstanceType != instanceType {
+			continue
+		}
+		if t, ok := latest[p.AvailabilityZone]; ok && !p.Timestamp.After(t) {
+			continue
+		}
+		price, err := strconv.ParseFloat(p.Price, 64)
+		if err != nil {
+			return nil, errors.Annotatef(err, "parsing spot price %q", p.Price)
+		}
+		prices[p.AvailabilityZone] = price
+		latest[p.AvailabilityZone] = p.Timestamp
+	}
+	return prices, nil
+}
+
+// orderZonesBySpotPrice returns the given zones ordered by the most
+// recent spot price of the given instance type, cheapest first, for use
+// when launching spot instances. Zones with no known price are tried
+// last, and ties keep their original order. If the price history cannot
+// be queried, the zones are returned unchanged.
+func (e *environ) orderZonesBySpotPrice(instanceType string, zones []string) []string {
+	prices, err := e.spotPrices(instanceType)
+	if err != nil {
+		logger.Warningf("not ordering availability zones by spot price: %v", err)
+		return zones
+	}
+	ordered := make([]string, len(zones))
+	copy(ordered, zones)
+	sort.Stable(bySpotPrice{ordered, prices})
+	return ordered
+}
+
+// bySpotPrice sorts zones by spot price, placing those without a price
+// last.
+type bySpotPrice struct {
+	zones  []string
+	prices map[string]float64
+}
+
+func (b bySpotPrice) Len() int      { return len(b.zones) }
+func (b bySpotPrice) Swap(i, j int) { b.zones[i], b.zones[j] = b.zones[j], b.zones[i] }
+func (b bySpotPrice) Less(i, j int) bool {
+	pi, iok := b.prices[b.zones[i]]
+	pj, jok := b.prices[b.zones[j]]
+	if iok != jok {
+		return iok
+	}
+	return iok && pi < pj
+}
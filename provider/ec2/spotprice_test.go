@@ -0,0 +1,134 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type spotPriceSuite struct {
+	testing.BaseSuite
+
+	server    *httptest.Server
+	requests  []url.Values
+	status    int
+	responses []string
+	client    *spotPriceClient
+}
+
+var _ = gc.Suite(&spotPriceSuite{})
+
+func (s *spotPriceSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.status = http.StatusOK
+	s.responses = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.requests = append(s.requests, r.URL.Query())
+		w.WriteHeader(s.status)
+		if len(s.responses) > 0 {
+			fmt.Fprint(w, s.responses[0])
+			s.responses = s.responses[1:]
+		}
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &spotPriceClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ec2"),
+		version:  spotPriceAPIVersion,
+	}}
+}
+
+func spotPriceResponse(nextToken string, prices ...spotPrice) string {
+	resp := "<DescribeSpotPriceHistoryResponse><spotPriceHistorySet>"
+	for _, p := range prices {
+		resp += fmt.Sprintf(`
+<item>
+  <instanceType>%s</instanceType>
+  <productDescription>Linux/UNIX</productDescription>
+  <spotPrice>%s</spotPrice>
+  <timestamp>%s</timestamp>
+  <availabilityZone>%s</availabilityZone>
+</item>`, p.InstanceType, p.Price, p.Timestamp.Format(time.RFC3339), p.AvailabilityZone)
+	}
+	resp += "</spotPriceHistorySet>"
+	if nextToken != "" {
+		resp += "<nextToken>" + nextToken + "</nextToken>"
+	}
+	return resp + "</DescribeSpotPriceHistoryResponse>"
+}
+
+func (s *spotPriceSuite) TestSpotPriceHistory(c *gc.C) {
+	t0 := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	first := spotPrice{"m3.medium", "us-east-1a", "0.0130", t0}
+	second := spotPrice{"m3.medium", "us-east-1b", "0.0110", t0.Add(time.Minute)}
+	s.responses = []string{
+		spotPriceResponse("token", first),
+		spotPriceResponse("", second),
+	}
+	prices, err := s.client.SpotPriceHistory("m3.medium", t0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(prices, jc.DeepEquals, []spotPrice{first, second})
+
+	c.Assert(s.requests, gc.HasLen, 2)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DescribeSpotPriceHistory")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, spotPriceAPIVersion)
+	c.Check(s.requests[0].Get("InstanceType.1"), gc.Equals, "m3.medium")
+	c.Check(s.requests[0].Get("ProductDescription.1"), gc.Equals, "Linux/UNIX")
+	c.Check(s.requests[0].Get("StartTime"), gc.Equals, "2017-06-01T12:00:00Z")
+	c.Check(s.requests[0].Get("NextToken"), gc.Equals, "")
+	c.Check(s.requests[1].Get("NextToken"), gc.Equals, "token")
+}
+
+func (s *spotPriceSuite) TestSpotPriceHistoryError(c *gc.C) {
+	s.status = http.StatusForbidden
+	s.responses = []string{`
+<Response>
+  <Errors>
+    <Error>
+      <Code>UnauthorizedOperation</Code>
+      <Message>denied</Message>
+    </Error>
+  </Errors>
+</Response>`}
+	_, err := s.client.SpotPriceHistory("m3.medium", time.Now())
+	c.Assert(err, gc.ErrorMatches, `denied \(UnauthorizedOperation\)`)
+}
+
+func (s *spotPriceSuite) TestOrderZonesBySpotPrice(c *gc.C) {
+	env := &environ{spotPriceHistory: s.client}
+	t0 := time.Now().Add(-time.Minute)
+	s.responses = []string{spotPriceResponse("",
+		spotPrice{"m3.medium", "us-east-1a", "0.0300", t0.Add(-time.Minute)},
+		spotPrice{"m3.medium", "us-east-1a", "0.0130", t0},
+		spotPrice{"m3.medium", "us-east-1b", "0.0200", t0},
+		spotPrice{"m3.medium", "us-east-1c", "0.0110", t0},
+		spotPrice{"m3.medium", "us-east-1d", "0.0130", t0},
+	)}
+	zones := []string{"us-east-1e", "us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d"}
+	ordered := env.orderZonesBySpotPrice("m3.medium", zones)
+	c.Assert(ordered, jc.DeepEquals, []string{
+		"us-east-1c", "us-east-1a", "us-east-1d", "us-east-1b", "us-east-1e",
+	})
+	c.Assert(zones[0], gc.Equals, "us-east-1e")
+}
+
+func (s *spotPriceSuite) TestOrderZonesBySpotPriceFallback(c *gc.C) {
+	env := &environ{spotPriceHistory: s.client}
+	s.status = http.StatusInternalServerError
+	zones := []string{"us-east-1b", "us-east-1a"}
+	c.Assert(env.orderZonesBySpotPrice("m3.medium", zones), jc.DeepEquals, zones)
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/arch"
+	jujuos "github.com/juju/utils/os"
+	"github.com/juju/utils/series"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+
+	"github.com/juju/juju/environs"
+)
+
+// ssmParameterAPI is the subset of the AWS Systems Manager API used to
+// resolve the SSM parameters through which AMIs are published.
+type ssmParameterAPI interface {
+	// GetParameter returns the value of the named parameter.
+	GetParameter(name string) (string, error)
+}
+
+// newSSMParameterAPI returns an ssmParameterAPI for the given cloud,
+// whose request signer is wrapped with wrapSigner. It is a variable so
+// it can be replaced in tests.
+var newSSMParameterAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) ssmParameterAPI {
+	client := newAWSAPIClient(cloud, cloud.Region, "ssm", "", wrapSigner)
+	client.target = "AmazonSSM"
+	return &ssmClient{client}
+}
+
+// ssmClient is a minimal client for the AWS Systems Manager JSON API.
+type ssmClient struct {
+	*awsAPIClient
+}
+
+// GetParameter is part of the ssmParameterAPI interface.
+func (c *ssmClient) GetParameter(name string) (string, error) {
+	args := struct {
+		Name string `json:"Name"`
+	}{name}
+	var resp struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err := c.call("GetParameter", args, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && err.Code == "ParameterNotFound" {
+			return "", errors.NotFoundf("SSM parameter %q", name)
+		}
+		return "", errors.Trace(err)
+	}
+	return resp.Parameter.Value, nil
+}
+
+// isSSMParameterPath reports whether the given image-id is the path of
+// an SSM parameter, rather than an AMI ID.
+func isSSMParameterPath(imageId string) bool {
+	return strings.HasPrefix(imageId, "/")
+}
+
+// isAMIID reports whether the given string looks like an AMI ID.
+func isAMIID(id string) bool {
+	return strings.HasPrefix(id, "ami-") && len(id) > len("ami-")
+}
+
+// resolveImageId returns the ID of the AMI named by the configured
+// image-id, resolving it if it is the path of an SSM parameter.
+func (e *environ) resolveImageId() (string, error) {
+	imageId := e.ecfg().imageID()
+	if !isSSMParameterPath(imageId) {
+		return imageId, nil
+	}
+	value, err := e.ssmParameters.GetParameter(imageId)
+	if err != nil {
+		return "", errors.Annotate(err, "resolving image-id")
+	}
+	if !isAMIID(value) {
+		return "", errors.NotValidf("SSM parameter %q value %q as an AMI ID", imageId, value)
+	}
+	logger.Debugf("resolved image-id %q to %q", imageId, value)
+	return value, nil
+}
+
+// validateImageId checks that the configured image-id, if any, names
+// an AMI; the AMI itself is checked when instances are started, once
+// their series and architecture are known.
+func (e *environ) validateImageId() error {
+	if e.ecfg().imageID() == "" {
+		return nil
+	}
+	_, err := e.resolveImageId()
+	return errors.Trace(err)
+}
+
+// configuredImage returns the AMI named by the configured image-id,
+// checking that it is available and suits instances of the given
+// series and architecture.
+func (e *environ) configuredImage(forSeries, forArch string) (*ec2.Image, error) {
+	imageId, err := e.resolveImageId()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	image, err := validateCustomImage(e.ec2, imageId)
+	if err != nil {
+		return nil, errors.Annotate(err, "validating image-id")
+	}
+	if err := checkImageSuits(image, forSeries, forArch); err != nil {
+		return nil, errors.Annotate(err, "validating image-id")
+	}
+	return image, nil
+}
+
+// ec2Architectures maps juju's architectures to those reported for
+// EC2 images.
+var ec2Architectures = map[string]string{
+	arch.AMD64: "x86_64",
+	arch.I386:  "i386",
+	arch.ARM64: "arm64",
+}
+
+// checkImageSuits checks that the given image can be used for instances
+// of the given series and architecture. Only the names of Ubuntu images
+// are known to include their series, so the series of other images is
+// not checked.
+func checkImageSuits(image *ec2.Image, forSeries, forArch string) error {
+	ec2Arch, ok := ec2Architectures[forArch]
+	if !ok {
+		return errors.NotSupportedf("architecture %q", forArch)
+	}
+	if image.Architecture != ec2Arch {
+		return errors.Errorf("image %q has architecture %q, expected %q", image.Id, image.Architecture, ec2Arch)
+	}
+	os, err := series.GetOSFromSeries(forSeries)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if os == jujuos.Ubuntu && !strings.Contains(image.Name, forSeries) {
+		return errors.Errorf("image %q (%s) is not for series %q", image.Id, image.Name, forSeries)
+	}
+	return nil
+}
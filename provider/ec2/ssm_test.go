@@ -0,0 +1,157 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	"gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type ssmSuite struct {
+	testing.BaseSuite
+
+	server   *httptest.Server
+	targets  []string
+	bodies   []map[string]string
+	status   int
+	response string
+	client   *ssmClient
+}
+
+var _ = gc.Suite(&ssmSuite{})
+
+const testSSMParameter = "/aws/service/canonical/ubuntu/server/16.04/stable/current/amd64/hvm/ebs-gp2/ami-id"
+
+func (s *ssmSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.targets = nil
+	s.bodies = nil
+	s.status = http.StatusOK
+	s.response = ""
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		c.Check(json.NewDecoder(r.Body).Decode(&body), jc.ErrorIsNil)
+		c.Check(r.Header.Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+		s.targets = append(s.targets, r.Header.Get("X-Amz-Target"))
+		s.bodies = append(s.bodies, body)
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.response)
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &ssmClient{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "ssm"),
+		target:   "AmazonSSM",
+	}}
+}
+
+func (s *ssmSuite) environ(c *gc.C, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg, ssmParameters: s.client}
+}
+
+func (s *ssmSuite) TestGetParameter(c *gc.C) {
+	s.response = `{"Parameter": {"Name": "` + testSSMParameter + `", "Type": "String", "Value": "ami-0123456789abcdef0"}}`
+	value, err := s.client.GetParameter(testSSMParameter)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(value, gc.Equals, "ami-0123456789abcdef0")
+	c.Assert(s.targets, jc.DeepEquals, []string{"AmazonSSM.GetParameter"})
+	c.Assert(s.bodies, jc.DeepEquals, []map[string]string{{"Name": testSSMParameter}})
+}
+
+func (s *ssmSuite) TestGetParameterNotFound(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "ParameterNotFound", "message": ""}`
+	_, err := s.client.GetParameter(testSSMParameter)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `SSM parameter ".*" not found`)
+}
+
+func (s *ssmSuite) TestGetParameterError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "com.amazonaws.ssm#AccessDeniedException", "message": "denied"}`
+	_, err := s.client.GetParameter(testSSMParameter)
+	c.Assert(err, gc.ErrorMatches, `denied \(AccessDeniedException\)`)
+}
+
+func (s *ssmSuite) TestValidateImageId(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"image-id": testSSMParameter})
+
+	s.response = `{"Parameter": {"Value": "ami-0123456789abcdef0"}}`
+	c.Assert(env.validateImageId(), jc.ErrorIsNil)
+
+	s.response = `{"Parameter": {"Value": "not-an-ami"}}`
+	err := env.validateImageId()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+
+	s.status = http.StatusBadRequest
+	s.response = `{"__type": "ParameterNotFound"}`
+	err = env.validateImageId()
+	c.Assert(err, gc.ErrorMatches, `resolving image-id: SSM parameter ".*" not found`)
+}
+
+func (s *ssmSuite) TestValidateImageIdAMI(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"image-id": "ami-0123456789abcdef0"})
+	c.Assert(env.validateImageId(), jc.ErrorIsNil)
+	c.Assert(s.targets, gc.HasLen, 0)
+
+	env = s.environ(c, nil)
+	c.Assert(env.validateImageId(), jc.ErrorIsNil)
+	c.Assert(s.targets, gc.HasLen, 0)
+}
+
+func (s *ssmSuite) TestConfiguredImage(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"image-id": testSSMParameter})
+	s.response = `{"Parameter": {"Value": "ami-0123456789abcdef0"}}`
+	s.PatchValue(&ec2Images, func(_ *ec2.EC2, ids []string, _ *ec2.Filter) (*ec2.ImagesResp, error) {
+		c.Assert(ids, jc.DeepEquals, []string{"ami-0123456789abcdef0"})
+		return &ec2.ImagesResp{Images: []ec2.Image{{
+			Id:           "ami-0123456789abcdef0",
+			Name:         "ubuntu/images/hvm-ssd/ubuntu-xenial-16.04-amd64-server-20170919",
+			State:        "available",
+			Architecture: "x86_64",
+		}}}, nil
+	})
+	image, err := env.configuredImage("xenial", "amd64")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(image.Id, gc.Equals, "ami-0123456789abcdef0")
+
+	_, err = env.configuredImage("trusty", "amd64")
+	c.Assert(err, gc.ErrorMatches, `validating image-id: image "ami-0123456789abcdef0" \(.*\) is not for series "trusty"`)
+
+	_, err = env.configuredImage("xenial", "arm64")
+	c.Assert(err, gc.ErrorMatches, `validating image-id: image "ami-0123456789abcdef0" has architecture "x86_64", expected "arm64"`)
+}
+
+func (s *ssmSuite) TestConfiguredImageNotFound(c *gc.C) {
+	env := s.environ(c, testing.Attrs{"image-id": "ami-0123456789abcdef0"})
+	s.PatchValue(&ec2Images, func(*ec2.EC2, []string, *ec2.Filter) (*ec2.ImagesResp, error) {
+		return nil, &ec2.Error{Code: "InvalidAMIID.NotFound"}
+	})
+	_, err := env.configuredImage("xenial", "amd64")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(s.targets, gc.HasLen, 0)
+}
+
+func (*ssmSuite) TestCheckImageSuitsNonUbuntu(c *gc.C) {
+	image := &ec2.Image{Id: "ami-1", Name: "CentOS 7", Architecture: "x86_64"}
+	c.Assert(checkImageSuits(image, "centos7", "amd64"), jc.ErrorIsNil)
+	err := checkImageSuits(image, "centos7", "ppc64el")
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
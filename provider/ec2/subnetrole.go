@@ -0,0 +1,129 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+
+	"github.com/juju/juju/network"
+)
+
+// roleSubnetsKey returns the model config key holding the subnets in
+// which machines of the given role are started.
+func roleSubnetsKey(controller bool) string {
+	if controller {
+		return "controller-subnets"
+	}
+	return "workload-subnets"
+}
+
+// roleSubnetsToZones returns the availability zone of each of the
+// subnets configured for machines of the given role, keyed by subnet ID,
+// in the form of StartInstanceParams.SubnetsToZones. If no subnets are
+// configured for the role, it returns nil.
+func (e *environ) roleSubnetsToZones(controller bool) (map[network.Id][]string, error) {
+	ids := e.ecfg().workloadSubnets()
+	if controller {
+		ids = e.ecfg().controllerSubnets()
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	key := roleSubnetsKey(controller)
+	resp, err := e.ec2.Subnets(ids, nil)
+	if ec2ErrCode(err) == "InvalidSubnetID.NotFound" {
+		return nil, errors.NewNotFound(err, key)
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "getting %s", key)
+	}
+	vpcID := e.ecfg().vpcID()
+	subnetsToZones := make(map[network.Id][]string)
+	for _, subnet := range resp.Subnets {
+		if isVPCIDSet(vpcID) && subnet.VPCId != vpcID {
+			return nil, errors.Errorf("%s: subnet %q is not in VPC %q", key, subnet.Id, vpcID)
+		}
+		subnetsToZones[network.Id(subnet.Id)] = []string{subnet.AvailZone}
+	}
+	for _, id := range ids {
+		if _, ok := subnetsToZones[network.Id(id)]; !ok {
+			return nil, errors.NotFoundf("%s: subnet %q", key, id)
+		}
+	}
+	return subnetsToZones, nil
+}
+
+// restrictToRoleSubnets returns the subnets configured for a role,
+// under the given config key, that are also among the given space
+// subnets, if any, and the given availability zones that contain them.
+func restrictToRoleSubnets(
+	key string,
+	roleSubnets, spaceSubnets map[network.Id][]string,
+	zones []string,
+) (map[network.Id][]string, []string, error) {
+	subnetsToZones := roleSubnets
+	if len(spaceSubnets) > 0 {
+		subnetsToZones = make(map[network.Id][]string)
+		for id, idZones := range roleSubnets {
+			if _, ok := spaceSubnets[id]; ok {
+				subnetsToZones[id] = idZones
+			}
+		}
+		if len(subnetsToZones) == 0 {
+			return nil, nil, errors.Errorf("none of the %s are in the space constraints", key)
+		}
+	}
+	roleZones := subnetZones(subnetsToZones)
+	var matchingZones []string
+	for _, zone := range zones {
+		if roleZones.Contains(zone) {
+			matchingZones = append(matchingZones, zone)
+		}
+	}
+	if len(matchingZones) == 0 {
+		return nil, nil, errors.Errorf(
+			"none of the %s are in availability zones %s",
+			key, strings.Join(zones, ", "),
+		)
+	}
+	return subnetsToZones, matchingZones, nil
+}
+
+// validateRoleSubnets checks that the subnets configured for
+// controllers and for workloads, if any, exist and, if both are
+// configured, share at least one availability zone.
+func (e *environ) validateRoleSubnets() error {
+	controllerSubnets, err := e.roleSubnetsToZones(true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	workloadSubnets, err := e.roleSubnetsToZones(false)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(controllerSubnets) == 0 || len(workloadSubnets) == 0 {
+		return nil
+	}
+	controllerZones := subnetZones(controllerSubnets)
+	workloadZones := subnetZones(workloadSubnets)
+	if controllerZones.Intersection(workloadZones).IsEmpty() {
+		return errors.Errorf(
+			"controller-subnets (in %s) and workload-subnets (in %s) share no availability zone",
+			strings.Join(controllerZones.SortedValues(), ", "),
+			strings.Join(workloadZones.SortedValues(), ", "),
+		)
+	}
+	return nil
+}
+
+// subnetZones returns the availability zones of the given subnets.
+func subnetZones(subnetsToZones map[network.Id][]string) set.Strings {
+	zones := set.NewStrings()
+	for _, idZones := range subnetsToZones {
+		zones = zones.Union(set.NewStrings(idZones...))
+	}
+	return zones
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/network"
+)
+
+// elbv2APIVersion is the version of the Elastic Load Balancing API
+// used to manage target groups.
+const elbv2APIVersion = "2015-12-01"
+
+// targetGroupAPI is the subset of the Elastic Load Balancing (v2) API
+// used to register instances with a target group, such as one belonging
+// to a Gateway Load Balancer.
+type targetGroupAPI interface {
+	DescribeTargetGroup(arn string) (*targetGroup, error)
+	RegisterTargets(arn string, instanceIds []string) error
+	DeregisterTargets(arn string, instanceIds []string) error
+}
+
+// targetGroup describes an ELB target group.
+type targetGroup struct {
+	Arn        string `xml:"TargetGroupArn"`
+	TargetType string `xml:"TargetType"`
+	VpcId      string `xml:"VpcId"`
+	Protocol   string `xml:"Protocol"`
+}
+
+// newTargetGroupAPI returns a targetGroupAPI for the given cloud, whose
+// request signer is wrapped with wrapSigner. It is a variable so it can
+// be replaced in tests.
+var newTargetGroupAPI = func(cloud environs.CloudSpec, wrapSigner func(aws.Signer) aws.Signer) targetGroupAPI {
+	client := newAWSAPIClient(cloud, cloud.Region, "elasticloadbalancing", "", wrapSigner)
+	client.version = elbv2APIVersion
+	return &elbv2Client{client}
+}
+
+// elbv2Client is a minimal client for the Elastic Load Balancing (v2)
+// query API.
+type elbv2Client struct {
+	*awsAPIClient
+}
+
+// DescribeTargetGroup is part of the targetGroupAPI interface.
+func (c *elbv2Client) DescribeTargetGroup(arn string) (*targetGroup, error) {
+	var resp struct {
+		TargetGroups []targetGroup `xml:"DescribeTargetGroupsResult>TargetGroups>member"`
+	}
+	params := url.Values{"TargetGroupArns.member.1": {arn}}
+	if err := c.query("DescribeTargetGroups", params, &resp); err != nil {
+		if err, ok := err.(*awsError); ok && err.Code == "TargetGroupNotFound" {
+			return nil, errors.NotFoundf("target group %q", arn)
+		}
+		return nil, errors.Trace(err)
+	}
+	if len(resp.TargetGroups) == 0 {
+		return nil, errors.NotFoundf("target group %q", arn)
+	}
+	return &resp.TargetGroups[0], nil
+}
+
+// RegisterTargets is part of the targetGroupAPI interface.
+func (c *elbv2Client) RegisterTargets(arn string, instanceIds []string) error {
+	return c.query("RegisterTargets", targetsParams(arn, instanceIds), nil)
+}
+
+// DeregisterTargets is part of the targetGroupAPI interface.
+func (c *elbv2Client) DeregisterTargets(arn string, instanceIds []string) error {
+	return c.query("DeregisterTargets", targetsParams(arn, instanceIds), nil)
+}
+
+func targetsParams(arn string, instanceIds []string) url.Values {
+	params := url.Values{"TargetGroupArn": {arn}}
+	for i, id := range instanceIds {
+		params.Set(fmt.Sprintf("Targets.member.%d.Id", i+1), id)
+	}
+	return params
+}
+
+// isTargetGroupARN reports whether the given string looks like the ARN
+// of an ELB target group.
+func isTargetGroupARN(arn string) bool {
+	parts := strings.SplitN(arn, ":", 6)
+	return len(parts) == 6 &&
+		parts[0] == "arn" &&
+		parts[2] == "elasticloadbalancing" &&
+		strings.HasPrefix(parts[5], "targetgroup/")
+}
+
+// validateTargetGroup checks that the configured target group, if any,
+// exists, accepts instance targets, and is in the model's VPC.
+func (e *environ) validateTargetGroup() error {
+	arn := e.ecfg().targetGroupARN()
+	if arn == "" {
+		return nil
+	}
+	group, err := e.targetGroups.DescribeTargetGroup(arn)
+	if err != nil {
+		return errors.Annotate(err, "validating target-group-arn")
+	}
+	if group.TargetType != "" && group.TargetType != "instance" {
+		return errors.Errorf(
+			"target group %q has target type %q, expected %q",
+			arn, group.TargetType, "instance",
+		)
+	}
+	if vpcID := e.ecfg().vpcID(); isVPCIDSet(vpcID) && group.VpcId != vpcID {
+		return errors.Errorf(
+			"target group %q is in VPC %q, not the model's VPC %q",
+			arn, group.VpcId, vpcID,
+		)
+	}
+	return nil
+}
+
+// deregisterTargets removes the given instances from the configured
+// target group, if any. Instances that are not registered are ignored.
+func (e *environ) deregisterTargets(ids []instance.Id) error {
+	arn := e.ecfg().targetGroupARN()
+	if arn == "" || len(ids) == 0 {
+		return nil
+	}
+	instanceIds := make([]string, len(ids))
+	for i, id := range ids {
+		instanceIds[i] = string(id)
+	}
+	return errors.Trace(e.targetGroups.DeregisterTargets(arn, instanceIds))
+}
+
+// hasPublicIngress reports whether any of the rules allows ingress from
+// anywhere, which is how an exposed application's ports are opened.
+func hasPublicIngress(rules []network.IngressRule) bool {
+	for _, rule := range rules {
+		if len(rule.SourceCIDRs) == 0 {
+			return true
+		}
+		for _, cidr := range rule.SourceCIDRs {
+			if cidr == "0.0.0.0/0" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/network"
+	"github.com/juju/juju/testing"
+)
+
+type targetGroupSuite struct {
+	testing.BaseSuite
+
+	server   *httptest.Server
+	requests []url.Values
+	headers  []http.Header
+	status   int
+	response string
+	client   *elbv2Client
+}
+
+var _ = gc.Suite(&targetGroupSuite{})
+
+const testTargetGroupARN = "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/inspection/0123456789abcdef"
+
+func (s *targetGroupSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.requests = nil
+	s.headers = nil
+	s.status = http.StatusOK
+	s.response = ""
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.URL.Query())
+		s.headers = append(s.headers, r.Header)
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.response)
+	}))
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.client = &elbv2Client{&awsAPIClient{
+		auth:     aws.Auth{AccessKey: "access", SecretKey: "secret"},
+		endpoint: s.server.URL + "/",
+		sign:     aws.SignV4Factory("us-east-1", "elasticloadbalancing"),
+		version:  elbv2APIVersion,
+	}}
+}
+
+func (s *targetGroupSuite) TestDescribeTargetGroup(c *gc.C) {
+	s.response = `
+<DescribeTargetGroupsResponse>
+  <DescribeTargetGroupsResult>
+    <TargetGroups>
+      <member>
+        <TargetGroupArn>` + testTargetGroupARN + `</TargetGroupArn>
+        <TargetType>instance</TargetType>
+        <VpcId>vpc-0123</VpcId>
+        <Protocol>GENEVE</Protocol>
+      </member>
+    </TargetGroups>
+  </DescribeTargetGroupsResult>
+</DescribeTargetGroupsResponse>`
+	group, err := s.client.DescribeTargetGroup(testTargetGroupARN)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(group, jc.DeepEquals, &targetGroup{
+		Arn:        testTargetGroupARN,
+		TargetType: "instance",
+		VpcId:      "vpc-0123",
+		Protocol:   "GENEVE",
+	})
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DescribeTargetGroups")
+	c.Check(s.requests[0].Get("Version"), gc.Equals, elbv2APIVersion)
+	c.Check(s.requests[0].Get("TargetGroupArns.member.1"), gc.Equals, testTargetGroupARN)
+	c.Check(s.headers[0].Get("Authorization"), jc.HasPrefix, "AWS4-HMAC-SHA256 ")
+}
+
+func (s *targetGroupSuite) TestDescribeTargetGroupNotFound(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `
+<ErrorResponse>
+  <Error>
+    <Code>TargetGroupNotFound</Code>
+    <Message>One or more target groups not found</Message>
+  </Error>
+</ErrorResponse>`
+	_, err := s.client.DescribeTargetGroup(testTargetGroupARN)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *targetGroupSuite) TestRegisterTargets(c *gc.C) {
+	err := s.client.RegisterTargets(testTargetGroupARN, []string{"i-1", "i-2"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.requests, gc.HasLen, 1)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "RegisterTargets")
+	c.Check(s.requests[0].Get("TargetGroupArn"), gc.Equals, testTargetGroupARN)
+	c.Check(s.requests[0].Get("Targets.member.1.Id"), gc.Equals, "i-1")
+	c.Check(s.requests[0].Get("Targets.member.2.Id"), gc.Equals, "i-2")
+}
+
+func (s *targetGroupSuite) TestDeregisterTargetsError(c *gc.C) {
+	s.status = http.StatusBadRequest
+	s.response = `
+<ErrorResponse>
+  <Error>
+    <Code>InvalidTarget</Code>
+    <Message>The target is not valid</Message>
+  </Error>
+</ErrorResponse>`
+	err := s.client.DeregisterTargets(testTargetGroupARN, []string{"i-1"})
+	c.Assert(err, gc.ErrorMatches, `The target is not valid \(InvalidTarget\)`)
+	c.Check(s.requests[0].Get("Action"), gc.Equals, "DeregisterTargets")
+}
+
+func (s *targetGroupSuite) TestIsTargetGroupARN(c *gc.C) {
+	c.Check(isTargetGroupARN(testTargetGroupARN), jc.IsTrue)
+	c.Check(isTargetGroupARN("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/gwy/lb/0123"), jc.IsFalse)
+	c.Check(isTargetGroupARN("targetgroup/inspection/0123456789abcdef"), jc.IsFalse)
+}
+
+func (s *targetGroupSuite) TestHasPublicIngress(c *gc.C) {
+	c.Check(hasPublicIngress(nil), jc.IsFalse)
+	c.Check(hasPublicIngress([]network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/8"),
+	}), jc.IsFalse)
+	c.Check(hasPublicIngress([]network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0"),
+	}), jc.IsTrue)
+	c.Check(hasPublicIngress([]network.IngressRule{
+		network.MustNewIngressRule("tcp", 443, 443),
+	}), jc.IsTrue)
+}
@@ -0,0 +1,240 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/aws"
+)
+
+const (
+	// defaultAPIConnectTimeout is how long an AWS API request may
+	// take to connect, if aws-api-connect-timeout is not specified.
+	defaultAPIConnectTimeout = 10 * time.Second
+
+	// defaultAPIReadTimeout is how long an AWS API request may take
+	// to complete once connected, if aws-api-read-timeout is not
+	// specified.
+	defaultAPIReadTimeout = time.Minute
+)
+
+// apiTimeoutError is the error with which an AWS API request fails when
+// it takes longer than one of the model's AWS API timeouts.
+type apiTimeoutError struct {
+	// connecting is true if the request timed out before it was
+	// connected, and so was never sent.
+	connecting bool
+	timeout    time.Duration
+}
+
+// Error is part of the error interface.
+func (e *apiTimeoutError) Error() string {
+	if e.connecting {
+		return fmt.Sprintf("AWS API request timed out connecting after %v", e.timeout)
+	}
+	return fmt.Sprintf("AWS API request timed out after %v", e.timeout)
+}
+
+// Timeout is part of the net.Error interface.
+func (e *apiTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary is part of the net.Error interface.
+func (e *apiTimeoutError) Temporary() bool {
+	return true
+}
+
+// asAPITimeout returns the apiTimeoutError with which an AWS API request
+// failed, if any. The error may be wrapped by the HTTP client, in a
+// *url.Error, and by the network, in a *net.OpError.
+func asAPITimeout(err error) (*apiTimeoutError, bool) {
+	for {
+		switch cause := errors.Cause(err).(type) {
+		case *apiTimeoutError:
+			return cause, true
+		case *url.Error:
+			err = cause.Err
+		case *net.OpError:
+			err = cause.Err
+		default:
+			return nil, false
+		}
+	}
+}
+
+// isAPITimeout reports whether the given error is from an AWS API
+// request that took longer than one of the model's AWS API timeouts.
+func isAPITimeout(err error) bool {
+	_, ok := asAPITimeout(err)
+	return ok
+}
+
+// isAPIConnectTimeout reports whether the given error is from an AWS
+// API request that timed out while connecting. Such requests were
+// never sent, so they may be retried even if they are not idempotent.
+func isAPIConnectTimeout(err error) bool {
+	timeoutErr, ok := asAPITimeout(err)
+	return ok && timeoutErr.connecting
+}
+
+// timeoutContext is a context that is cancelled with a specific error
+// when a request times out, so that the error the request fails with
+// says which timeout was exceeded.
+type timeoutContext struct {
+	context.Context
+	cancel func()
+
+	mu           sync.Mutex
+	err          error
+	finished     bool
+	connectTimer *time.Timer
+	readTimer    *time.Timer
+}
+
+// timeoutContextKey is the key under which a timeoutContext can be
+// found in the contexts derived from it.
+type timeoutContextKey struct{}
+
+// expire cancels the context, with the given error if the request has
+// not already finished or timed out.
+func (ctx *timeoutContext) expire(err error) {
+	ctx.mu.Lock()
+	if ctx.err == nil && !ctx.finished {
+		ctx.err = err
+	}
+	ctx.mu.Unlock()
+	ctx.finish()
+}
+
+// finish stops the context's timers and cancels it, once the request
+// has finished or timed out.
+func (ctx *timeoutContext) finish() {
+	ctx.mu.Lock()
+	ctx.finished = true
+	if ctx.connectTimer != nil {
+		ctx.connectTimer.Stop()
+	}
+	if ctx.readTimer != nil {
+		ctx.readTimer.Stop()
+	}
+	ctx.mu.Unlock()
+	ctx.cancel()
+}
+
+// connected stops the connect timer and starts the read timer, unless
+// the request has already finished or timed out.
+func (ctx *timeoutContext) connected(readTimeout time.Duration) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.finished {
+		return
+	}
+	if ctx.connectTimer != nil {
+		ctx.connectTimer.Stop()
+	}
+	if readTimeout > 0 {
+		ctx.readTimer = time.AfterFunc(readTimeout, func() {
+			ctx.expire(&apiTimeoutError{timeout: readTimeout})
+		})
+	}
+}
+
+// Err is part of the context.Context interface.
+func (ctx *timeoutContext) Err() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.err != nil {
+		return ctx.err
+	}
+	return ctx.Context.Err()
+}
+
+// Value is part of the context.Context interface.
+func (ctx *timeoutContext) Value(key interface{}) interface{} {
+	if key == (timeoutContextKey{}) {
+		return ctx
+	}
+	return ctx.Context.Value(key)
+}
+
+// finishRequest stops the timeouts of a request signed by a
+// timeoutSigner, once it has failed or its response body is closed. It
+// does nothing for other requests.
+func finishRequest(req *http.Request) {
+	if ctx, ok := req.Context().Value(timeoutContextKey{}).(*timeoutContext); ok {
+		ctx.finish()
+	}
+}
+
+// finishOnClose is a response body that stops the timeouts of its
+// request when it is closed.
+type finishOnClose struct {
+	io.ReadCloser
+	req *http.Request
+}
+
+// Close is part of the io.Closer interface.
+func (b finishOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	finishRequest(b.req)
+	return err
+}
+
+// timeoutSigner wraps the given signer so that the requests it signs
+// fail if they take longer than the model's AWS API timeouts: the
+// connect timeout until a connection to the API is obtained, then the
+// read timeout until the response is complete. As the timeouts apply
+// to each request, every attempt made when retrying a call is given
+// the full timeouts.
+//
+// The timeouts are stopped once the response has been read in full, or
+// when finishRequest is called for the request. The sender of a request
+// whose response may not be read in full should call finishRequest, or
+// wrap the response body with finishOnClose.
+func (e *environ) timeoutSigner(signer aws.Signer) aws.Signer {
+	return func(req *http.Request, auth aws.Auth) error {
+		if err := signer(req, auth); err != nil {
+			return err
+		}
+		ecfg := e.ecfg()
+		connectTimeout := ecfg.awsAPIConnectTimeout()
+		readTimeout := ecfg.awsAPIReadTimeout()
+		if connectTimeout == 0 && readTimeout == 0 {
+			return nil
+		}
+
+		parent, cancel := context.WithCancel(req.Context())
+		ctx := &timeoutContext{Context: parent, cancel: cancel}
+		if connectTimeout > 0 {
+			ctx.mu.Lock()
+			ctx.connectTimer = time.AfterFunc(connectTimeout, func() {
+				ctx.expire(&apiTimeoutError{connecting: true, timeout: connectTimeout})
+			})
+			ctx.mu.Unlock()
+		}
+		trace := &httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) {
+				ctx.connected(readTimeout)
+			},
+			// The connection is offered back to the pool once the
+			// response has been read in full.
+			PutIdleConn: func(error) {
+				ctx.finish()
+			},
+		}
+		*req = *req.WithContext(httptrace.WithClientTrace(ctx, trace))
+		return nil
+	}
+}
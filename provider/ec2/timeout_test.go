@@ -0,0 +1,228 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/aws"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/testing"
+)
+
+type timeoutSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&timeoutSuite{})
+
+func (s *timeoutSuite) environ(c *gc.C, attrs testing.Attrs) *environ {
+	cfg, err := config.New(config.NoDefaults, testing.FakeConfig().Merge(attrs))
+	c.Assert(err, jc.ErrorIsNil)
+	ecfg, err := providerInstance.newConfig(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return &environ{ecfgUnlocked: ecfg}
+}
+
+func (s *timeoutSuite) signedRequest(c *gc.C, env *environ, requestURL string) *http.Request {
+	signer := env.timeoutSigner(func(req *http.Request, auth aws.Auth) error {
+		req.Header.Set("Authorization", "signed")
+		return nil
+	})
+	req, err := http.NewRequest("GET", requestURL, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = signer(req, aws.Auth{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(req.Header.Get("Authorization"), gc.Equals, "signed")
+	return req
+}
+
+func (s *timeoutSuite) do(c *gc.C, env *environ, requestURL string) error {
+	req := s.signedRequest(c, env, requestURL)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func (s *timeoutSuite) TestDefaults(c *gc.C) {
+	env := s.environ(c, nil)
+	c.Assert(env.ecfg().awsAPIConnectTimeout(), gc.Equals, 10*time.Second)
+	c.Assert(env.ecfg().awsAPIReadTimeout(), gc.Equals, time.Minute)
+}
+
+func (s *timeoutSuite) TestReadTimeout(c *gc.C) {
+	done := make(chan struct{})
+	defer close(done)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+
+	env := s.environ(c, testing.Attrs{"aws-api-read-timeout": "50ms"})
+	err := s.do(c, env, srv.URL)
+	c.Assert(err, gc.ErrorMatches, ".*AWS API request timed out after 50ms")
+	c.Assert(isAPITimeout(err), jc.IsTrue)
+	c.Assert(isAPIConnectTimeout(err), jc.IsFalse)
+}
+
+func (s *timeoutSuite) TestConnectTimeout(c *gc.C) {
+	// Connections to the listener are never accepted, so the TLS
+	// handshake never completes.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, jc.ErrorIsNil)
+	defer l.Close()
+
+	env := s.environ(c, testing.Attrs{"aws-api-connect-timeout": "50ms"})
+	err = s.do(c, env, "https://"+l.Addr().String())
+	c.Assert(err, gc.ErrorMatches, ".*AWS API request timed out connecting after 50ms")
+	c.Assert(isAPITimeout(err), jc.IsTrue)
+	c.Assert(isAPIConnectTimeout(err), jc.IsTrue)
+}
+
+func (s *timeoutSuite) TestNoTimeouts(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	env := s.environ(c, testing.Attrs{
+		"aws-api-connect-timeout": "0s",
+		"aws-api-read-timeout":    "0s",
+	})
+	err := s.do(c, env, srv.URL)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *timeoutSuite) TestCompletesWithinTimeouts(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer srv.Close()
+
+	env := s.environ(c, testing.Attrs{
+		"aws-api-connect-timeout": "1s",
+		"aws-api-read-timeout":    "1s",
+	})
+	err := s.do(c, env, srv.URL)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+// assertFinished asserts that the timeouts of the request have been
+// stopped, and its context cancelled without a timeout error.
+func assertFinished(c *gc.C, req *http.Request) {
+	ctx, ok := req.Context().Value(timeoutContextKey{}).(*timeoutContext)
+	c.Assert(ok, jc.IsTrue)
+	select {
+	case <-ctx.Done():
+	default:
+		c.Fatalf("request context not cancelled")
+	}
+	c.Assert(ctx.Err(), gc.Equals, context.Canceled)
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	c.Assert(ctx.finished, jc.IsTrue)
+	c.Assert(ctx.readTimer.Stop(), jc.IsFalse)
+}
+
+func (s *timeoutSuite) TestFinishesWhenResponseRead(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "response")
+	}))
+	defer srv.Close()
+
+	env := s.environ(c, testing.Attrs{"aws-api-read-timeout": "1m"})
+	req := s.signedRequest(c, env, srv.URL)
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(body), gc.Equals, "response")
+	assertFinished(c, req)
+}
+
+func (s *timeoutSuite) TestFinishOnClose(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "response")
+	}))
+	defer srv.Close()
+
+	env := s.environ(c, testing.Attrs{"aws-api-read-timeout": "1m"})
+	req := s.signedRequest(c, env, srv.URL)
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, jc.ErrorIsNil)
+	// The response body is closed without being read.
+	err = finishOnClose{resp.Body, req}.Close()
+	c.Assert(err, jc.ErrorIsNil)
+	assertFinished(c, req)
+}
+
+func (s *timeoutSuite) TestFinishRequestAfterFailure(c *gc.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, jc.ErrorIsNil)
+	addr := l.Addr().String()
+	l.Close()
+
+	env := s.environ(c, testing.Attrs{"aws-api-connect-timeout": "1m"})
+	req := s.signedRequest(c, env, "http://"+addr)
+	_, err = http.DefaultClient.Do(req)
+	c.Assert(err, gc.NotNil)
+	c.Assert(isAPITimeout(err), jc.IsFalse)
+	finishRequest(req)
+
+	ctx := req.Context().Value(timeoutContextKey{}).(*timeoutContext)
+	c.Assert(ctx.Err(), gc.Equals, context.Canceled)
+	c.Assert(ctx.connectTimer.Stop(), jc.IsFalse)
+}
+
+func (s *timeoutSuite) TestAsAPITimeout(c *gc.C) {
+	timeoutErr := &apiTimeoutError{connecting: true, timeout: time.Second}
+	for i, test := range []struct {
+		err     error
+		timeout bool
+	}{{
+		err:     timeoutErr,
+		timeout: true,
+	}, {
+		err:     errors.Annotate(timeoutErr, "describing instances"),
+		timeout: true,
+	}, {
+		err:     &url.Error{Op: "Get", URL: "https://ec2.example.com/", Err: timeoutErr},
+		timeout: true,
+	}, {
+		err:     &net.OpError{Op: "dial", Net: "tcp", Err: timeoutErr},
+		timeout: true,
+	}, {
+		err: errors.Annotate(&url.Error{
+			Op:  "Get",
+			URL: "https://ec2.example.com/",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutErr},
+		}, "describing instances"),
+		timeout: true,
+	}, {
+		err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+	}, {
+		err: errors.New("AWS API request timed out after 1s"),
+	}} {
+		c.Logf("test %d: %v", i, test.err)
+		got, ok := asAPITimeout(test.err)
+		c.Check(ok, gc.Equals, test.timeout)
+		if test.timeout {
+			c.Check(got, gc.Equals, timeoutErr)
+		}
+		c.Check(isAPIConnectTimeout(test.err), gc.Equals, test.timeout)
+	}
+}
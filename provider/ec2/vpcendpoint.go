@@ -0,0 +1,54 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"regexp"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/environs"
+)
+
+// vpcEndpointDNSName matches the DNS names of EC2 VPC interface
+// endpoints, both regional, such as
+// vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com,
+// and zonal, capturing the region.
+var vpcEndpointDNSName = regexp.MustCompile(
+	`^vpce-[0-9a-f]+-[0-9a-z]+(?:-[0-9a-z-]+)?\.ec2\.([0-9a-z-]+)\.vpce\.amazonaws\.com(?:\.cn)?$`,
+)
+
+// isVPCEndpointDNSName reports whether the given name is the DNS name
+// of an EC2 VPC interface endpoint.
+func isVPCEndpointDNSName(name string) bool {
+	return vpcEndpointDNSName.MatchString(name)
+}
+
+// validateVPCEndpointRegion checks that the given VPC endpoint is in
+// the given region. Requests sent through the endpoint are signed for
+// the cloud's region, so they are rejected by an endpoint in any other.
+func validateVPCEndpointRegion(name, region string) error {
+	match := vpcEndpointDNSName.FindStringSubmatch(name)
+	if match == nil {
+		return errors.NotValidf("VPC endpoint %q", name)
+	}
+	if match[1] != region {
+		return errors.Errorf(
+			"ec2-vpc-endpoint: %q is in region %q, but the model is in region %q",
+			name, match[1], region,
+		)
+	}
+	return nil
+}
+
+// withVPCEndpoint returns the given cloud spec with its endpoint
+// replaced by the given VPC endpoint, if any, so that every EC2 API
+// request, including those for EBS volumes, is sent through it. The
+// region, used to sign the requests, is left alone.
+func withVPCEndpoint(cloud environs.CloudSpec, name string) environs.CloudSpec {
+	if name != "" {
+		cloud.Endpoint = "https://" + name
+	}
+	return cloud
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/testing"
+)
+
+type vpcEndpointSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&vpcEndpointSuite{})
+
+func (s *vpcEndpointSuite) TestIsVPCEndpointDNSName(c *gc.C) {
+	for _, name := range []string{
+		"vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+		"vpce-0123456789abcdef0-abcdefgh-us-east-1a.ec2.us-east-1.vpce.amazonaws.com",
+		"vpce-0123456789abcdef0-abcdefgh.ec2.cn-north-1.vpce.amazonaws.com.cn",
+	} {
+		c.Check(isVPCEndpointDNSName(name), jc.IsTrue, gc.Commentf("%q", name))
+	}
+	for _, name := range []string{
+		"",
+		"ec2.us-east-1.amazonaws.com",
+		"https://vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com",
+		"vpce-0123456789abcdef0-abcdefgh.s3.us-east-1.vpce.amazonaws.com",
+		"vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.example.com",
+	} {
+		c.Check(isVPCEndpointDNSName(name), jc.IsFalse, gc.Commentf("%q", name))
+	}
+}
+
+func (s *vpcEndpointSuite) TestValidateVPCEndpointRegion(c *gc.C) {
+	name := "vpce-0123456789abcdef0-abcdefgh-us-east-1a.ec2.us-east-1.vpce.amazonaws.com"
+	c.Assert(validateVPCEndpointRegion(name, "us-east-1"), jc.ErrorIsNil)
+	c.Assert(validateVPCEndpointRegion(name, "us-east-2"), gc.ErrorMatches,
+		`ec2-vpc-endpoint: ".*" is in region "us-east-1", but the model is in region "us-east-2"`)
+}
+
+func (s *vpcEndpointSuite) TestWithVPCEndpoint(c *gc.C) {
+	cloud := environs.CloudSpec{
+		Type:     "ec2",
+		Region:   "us-east-1",
+		Endpoint: "https://ec2.us-east-1.amazonaws.com",
+	}
+	c.Assert(withVPCEndpoint(cloud, ""), jc.DeepEquals, cloud)
+
+	name := "vpce-0123456789abcdef0-abcdefgh.ec2.us-east-1.vpce.amazonaws.com"
+	c.Assert(withVPCEndpoint(cloud, name), jc.DeepEquals, environs.CloudSpec{
+		Type:     "ec2",
+		Region:   "us-east-1",
+		Endpoint: "https://" + name,
+	})
+}
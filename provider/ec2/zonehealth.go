@@ -0,0 +1,101 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/utils/clock"
+	"gopkg.in/amz.v3/ec2"
+)
+
+// zoneCapacityCooldown is how long an availability zone is tried last
+// after an instance failed to start in it due to insufficient capacity.
+const zoneCapacityCooldown = 10 * time.Minute
+
+// zoneHealth records the availability zones in which instances
+// recently failed to start due to insufficient capacity, so that
+// subsequent launches try other zones first.
+type zoneHealth struct {
+	clock    clock.Clock
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	failed map[string]time.Time
+}
+
+func newZoneHealth(clock clock.Clock, cooldown time.Duration) *zoneHealth {
+	return &zoneHealth{
+		clock:    clock,
+		cooldown: cooldown,
+		failed:   make(map[string]time.Time),
+	}
+}
+
+// recordFailure notes that an instance failed to start in the given
+// zone due to insufficient capacity.
+func (h *zoneHealth) recordFailure(zone string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failed[zone] = h.clock.Now()
+}
+
+// recordSuccess notes that an instance started in the given zone.
+func (h *zoneHealth) recordSuccess(zone string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failed, zone)
+}
+
+// order returns the given zones with those that are cooling down after
+// a capacity failure moved to the end, least recently failed first.
+// The relative order of the other zones is preserved. If every zone is
+// cooling down, the zones are returned in their original order.
+func (h *zoneHealth) order(zones []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.clock.Now()
+	var healthy, cooling []string
+	for _, zone := range zones {
+		failed, ok := h.failed[zone]
+		if !ok {
+			healthy = append(healthy, zone)
+			continue
+		}
+		if now.Sub(failed) >= h.cooldown {
+			delete(h.failed, zone)
+			healthy = append(healthy, zone)
+			continue
+		}
+		// Insert in order of failure time; zones are few.
+		i := len(cooling)
+		for i > 0 && h.failed[cooling[i-1]].After(failed) {
+			i--
+		}
+		cooling = append(cooling, "")
+		copy(cooling[i+1:], cooling[i:])
+		cooling[i] = zone
+	}
+	if len(healthy) == 0 {
+		return zones
+	}
+	return append(healthy, cooling...)
+}
+
+// isZoneCapacityError reports whether or not the error indicates
+// RunInstances failed due to insufficient capacity in the specified
+// availability zone.
+func isZoneCapacityError(err error) bool {
+	if err, ok := err.(*ec2.Error); ok {
+		switch err.Code {
+		case "InsufficientInstanceCapacity":
+			return true
+		case "Unsupported":
+			return strings.Contains(err.Message, "capacity")
+		}
+	}
+	return false
+}
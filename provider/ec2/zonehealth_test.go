@@ -0,0 +1,94 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"errors"
+	"time"
+
+	jujutesting "github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"gopkg.in/amz.v3/ec2"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type zoneHealthSuite struct {
+	testing.BaseSuite
+
+	clock  *jujutesting.Clock
+	health *zoneHealth
+}
+
+var _ = gc.Suite(&zoneHealthSuite{})
+
+func (s *zoneHealthSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.clock = jujutesting.NewClock(time.Time{})
+	s.health = newZoneHealth(s.clock, time.Minute)
+}
+
+func (s *zoneHealthSuite) TestOrderNoFailures(c *gc.C) {
+	zones := []string{"az1", "az2", "az3"}
+	c.Assert(s.health.order(zones), jc.DeepEquals, []string{"az1", "az2", "az3"})
+}
+
+func (s *zoneHealthSuite) TestOrderDeprioritisesFailedZones(c *gc.C) {
+	s.health.recordFailure("az2")
+	s.clock.Advance(time.Second)
+	s.health.recordFailure("az1")
+	zones := []string{"az1", "az2", "az3", "az4"}
+	c.Assert(s.health.order(zones), jc.DeepEquals, []string{"az3", "az4", "az2", "az1"})
+	// The input is not modified.
+	c.Assert(zones, jc.DeepEquals, []string{"az1", "az2", "az3", "az4"})
+}
+
+func (s *zoneHealthSuite) TestOrderCooldownExpires(c *gc.C) {
+	s.health.recordFailure("az1")
+	s.clock.Advance(59 * time.Second)
+	c.Assert(s.health.order([]string{"az1", "az2"}), jc.DeepEquals, []string{"az2", "az1"})
+	s.clock.Advance(time.Second)
+	c.Assert(s.health.order([]string{"az1", "az2"}), jc.DeepEquals, []string{"az1", "az2"})
+}
+
+func (s *zoneHealthSuite) TestOrderAllZonesCooling(c *gc.C) {
+	s.health.recordFailure("az2")
+	s.health.recordFailure("az1")
+	c.Assert(s.health.order([]string{"az1", "az2"}), jc.DeepEquals, []string{"az1", "az2"})
+}
+
+func (s *zoneHealthSuite) TestRecordSuccess(c *gc.C) {
+	s.health.recordFailure("az1")
+	s.health.recordSuccess("az1")
+	c.Assert(s.health.order([]string{"az1", "az2"}), jc.DeepEquals, []string{"az1", "az2"})
+}
+
+func (s *zoneHealthSuite) TestIsZoneCapacityError(c *gc.C) {
+	for i, test := range []struct {
+		err    error
+		expect bool
+	}{{
+		err:    &ec2.Error{Code: "InsufficientInstanceCapacity", Message: "We currently do not have sufficient capacity"},
+		expect: true,
+	}, {
+		err:    &ec2.Error{Code: "Unsupported", Message: "We currently do not have sufficient m1.small capacity in the Availability Zone you requested"},
+		expect: true,
+	}, {
+		err:    &ec2.Error{Code: "Unsupported", Message: "The requested Availability Zone is currently constrained"},
+		expect: false,
+	}, {
+		err:    &ec2.Error{Code: "VolumeTypeNotAvailableInZone"},
+		expect: false,
+	}, {
+		err:    errors.New("InsufficientInstanceCapacity"),
+		expect: false,
+	}, {
+		err:    nil,
+		expect: false,
+	}} {
+		c.Logf("test %d: %v", i, test.err)
+		c.Check(isZoneCapacityError(test.err), gc.Equals, test.expect)
+	}
+}
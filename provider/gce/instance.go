@@ -40,8 +40,10 @@ func (inst *environInstance) Status() instance.InstanceStatus {
 		jujuStatus = status.Provisioning
 	case "RUNNING":
 		jujuStatus = status.Running
-	case "STOPPING", "TERMINATED":
-		jujuStatus = status.Empty
+	case "STOPPING", "STOPPED", "TERMINATED":
+		// A TERMINATED instance has been stopped, and may
+		// be started again.
+		jujuStatus = status.Stopped
 	default:
 		jujuStatus = status.Empty
 	}
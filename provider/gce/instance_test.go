@@ -10,6 +10,7 @@ import (
 	"github.com/juju/juju/instance"
 	"github.com/juju/juju/provider/gce"
 	"github.com/juju/juju/provider/gce/google"
+	"github.com/juju/juju/status"
 )
 
 type instanceSuite struct {
@@ -40,6 +41,28 @@ func (s *instanceSuite) TestStatus(c *gc.C) {
 	s.CheckNoAPI(c)
 }
 
+func (s *instanceSuite) TestStatusMapping(c *gc.C) {
+	for i, test := range []struct {
+		gceStatus string
+		status    status.Status
+	}{
+		{google.StatusProvisioning, status.Provisioning},
+		{google.StatusStaging, status.Provisioning},
+		{google.StatusRunning, status.Running},
+		{google.StatusStopping, status.Stopped},
+		{google.StatusStopped, status.Stopped},
+		{google.StatusTerminated, status.Stopped},
+		{"SUSPENDED", status.Empty},
+	} {
+		c.Logf("test %d: %s", i, test.gceStatus)
+		s.BaseInstance.InstanceSummary.Status = test.gceStatus
+		instStatus := s.Instance.Status()
+		c.Check(instStatus.Status, gc.Equals, test.status)
+		c.Check(instStatus.Message, gc.Equals, test.gceStatus)
+	}
+	s.CheckNoAPI(c)
+}
+
 func (s *instanceSuite) TestAddresses(c *gc.C) {
 	addresses, err := s.Instance.Addresses()
 	c.Assert(err, jc.ErrorIsNil)
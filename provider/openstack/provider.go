@@ -346,12 +346,15 @@ func (inst *openstackInstance) Status() instance.InstanceStatus {
 		jujuStatus = status.Running
 	case nova.StatusError:
 		jujuStatus = status.ProvisioningError
+	case nova.StatusShutoff, nova.StatusSuspended:
+		jujuStatus = status.Stopped
+	case nova.StatusDeleted:
+		jujuStatus = status.Terminated
 	case nova.StatusBuild, nova.StatusBuildSpawning,
-		nova.StatusDeleted, nova.StatusHardReboot,
-		nova.StatusPassword, nova.StatusReboot,
-		nova.StatusRebuild, nova.StatusRescue,
-		nova.StatusResize, nova.StatusShutoff,
-		nova.StatusSuspended, nova.StatusVerifyResize:
+		nova.StatusHardReboot, nova.StatusPassword,
+		nova.StatusReboot, nova.StatusRebuild,
+		nova.StatusRescue, nova.StatusResize,
+		nova.StatusVerifyResize:
 		jujuStatus = status.Empty
 	case nova.StatusUnknown:
 		jujuStatus = status.Unknown
@@ -19,6 +19,7 @@ import (
 	"github.com/juju/juju/cloud"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/network"
+	"github.com/juju/juju/status"
 )
 
 // localTests contains tests which do not require a live service or test double to run.
@@ -522,6 +523,33 @@ type providerUnitTests struct{}
 
 var _ = gc.Suite(&providerUnitTests{})
 
+func (s *providerUnitTests) TestInstanceStatus(c *gc.C) {
+	for i, test := range []struct {
+		novaStatus string
+		status     status.Status
+	}{
+		{nova.StatusBuild, status.Empty},
+		{nova.StatusActive, status.Running},
+		{nova.StatusError, status.ProvisioningError},
+		{nova.StatusReboot, status.Empty},
+		{nova.StatusHardReboot, status.Empty},
+		{nova.StatusResize, status.Empty},
+		{nova.StatusVerifyResize, status.Empty},
+		{nova.StatusRescue, status.Empty},
+		{nova.StatusShutoff, status.Stopped},
+		{nova.StatusSuspended, status.Stopped},
+		{nova.StatusDeleted, status.Terminated},
+		{nova.StatusUnknown, status.Unknown},
+		{"SHELVED", status.Empty},
+	} {
+		c.Logf("test %d: %s", i, test.novaStatus)
+		inst := &openstackInstance{serverDetail: &nova.ServerDetail{Status: test.novaStatus}}
+		instStatus := inst.Status()
+		c.Check(instStatus.Status, gc.Equals, test.status)
+		c.Check(instStatus.Message, gc.Equals, test.novaStatus)
+	}
+}
+
 func (s *providerUnitTests) checkIdentityClientVersionInvalid(c *gc.C, url string) {
 	_, err := identityClientVersion(url)
 	c.Check(err, gc.ErrorMatches, fmt.Sprintf("version part of identity url %s not valid", url))
@@ -117,8 +117,18 @@ func (c *Codec) ReadHeader(hdr *rpc.Header) error {
 	var version int
 	err := c.conn.Receive(&m)
 	if err == nil {
-		logger.Tracef("<- %s", m)
 		c.msg, version, err = c.readMessage(m)
+		if err != nil {
+			logger.Tracef("<- unreadable message: %v", err)
+		} else if c.msg.Request != "" {
+			// Request parameters may hold secrets, such as the
+			// values of application settings, so only the request
+			// is traced here; the API server traces the parameters
+			// itself, redacting those that must not be logged.
+			logger.Tracef("<- request %d: %s(%d).%s", c.msg.RequestId, c.msg.Type, c.msg.Version, c.msg.Request)
+		} else {
+			logger.Tracef("<- %s", m)
+		}
 	} else {
 		logger.Tracef("<- error: %v (closing %v)", err, c.isClosing())
 	}
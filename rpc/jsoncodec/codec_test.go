@@ -10,6 +10,7 @@ import (
 	"reflect"
 	stdtesting "testing"
 
+	"github.com/juju/loggo"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
@@ -137,6 +138,34 @@ func (*suite) TestRead(c *gc.C) {
 	}
 }
 
+func (*suite) TestReadRequestDoesNotTraceParams(c *gc.C) {
+	var tw loggo.TestWriter
+	c.Assert(loggo.RegisterWriter("codec-tests", &tw), jc.ErrorIsNil)
+	defer loggo.RemoveWriter("codec-tests")
+	loggo.GetLogger("juju.rpc.jsoncodec").SetLogLevel(loggo.TRACE)
+
+	codec := jsoncodec.New(&testConn{
+		readMsgs: []string{
+			`{"request-id": 1, "type": "Application", "version": 8, "request": "Set", "params": {"options": {"password": "s3cret"}}}`,
+			`{"request-id": 1, "response": {"X": "result"}}`,
+		},
+	})
+	var hdr rpc.Header
+	err := codec.ReadHeader(&hdr)
+	c.Assert(err, jc.ErrorIsNil)
+	err = codec.ReadHeader(&hdr)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var messages []string
+	for _, entry := range tw.Log() {
+		messages = append(messages, entry.Message)
+	}
+	c.Assert(messages, jc.DeepEquals, []string{
+		"<- request 1: Application(8).Set",
+		`<- {"request-id": 1, "response": {"X": "result"}}`,
+	})
+}
+
 func (*suite) TestErrorAfterClose(c *gc.C) {
 	conn := &testConn{
 		err: errors.New("some error"),
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	jujutxn "github.com/juju/txn"
@@ -50,6 +51,24 @@ type applicationDoc struct {
 	MinUnits             int        `bson:"minunits"`
 	TxnRevno             int64      `bson:"txn-revno"`
 	MetricCredentials    []byte     `bson:"metric-credentials"`
+
+	// PreviousConfig holds the charm config settings as they were
+	// before the most recent change to them, with escaped keys.
+	PreviousConfig map[string]interface{} `bson:"previous-config,omitempty"`
+
+	// ExposedSpaces holds the names of the spaces on whose networks
+	// the application's ports are opened when it is exposed; if
+	// empty, they are opened to everywhere.
+	ExposedSpaces []string `bson:"exposed-spaces,omitempty"`
+
+	// ConfigChanged holds the time, in nanoseconds since the epoch,
+	// at which each charm config setting was last changed, with
+	// escaped keys.
+	ConfigChanged map[string]int64 `bson:"config-changed,omitempty"`
+
+	// ConfigLocked is true if changes to the charm config settings
+	// are to be refused until the application is unlocked.
+	ConfigLocked bool `bson:"config-locked,omitempty"`
 }
 
 func newApplication(st *State, doc *applicationDoc) *Application {
@@ -305,29 +324,61 @@ func (a *Application) IsExposed() bool {
 	return a.doc.Exposed
 }
 
-// SetExposed marks the application as exposed.
+// ExposedSpaces returns the names of the spaces on whose networks the
+// application's ports are opened when it is exposed. If there are none,
+// an exposed application's ports are opened to everywhere.
+func (a *Application) ExposedSpaces() []string {
+	return a.doc.ExposedSpaces
+}
+
+// SetExposed marks the application as exposed to everywhere.
 // See ClearExposed and IsExposed.
 func (a *Application) SetExposed() error {
-	return a.setExposed(true)
+	return a.setExposed(true, nil)
 }
 
-// ClearExposed removes the exposed flag from the application.
+// SetExposedSpaces marks the application as exposed only on the
+// networks of the given spaces, which must exist. With no spaces, it
+// is the same as SetExposed.
+// See ClearExposed, IsExposed and ExposedSpaces.
+func (a *Application) SetExposedSpaces(spaces []string) error {
+	for _, name := range spaces {
+		if _, err := a.st.Space(name); err != nil {
+			return errors.Annotatef(err, "cannot expose application %q to space %q", a, name)
+		}
+	}
+	return a.setExposed(true, spaces)
+}
+
+// ClearExposed removes the exposed flag, and any spaces the application
+// was exposed to, from the application.
 // See SetExposed and IsExposed.
 func (a *Application) ClearExposed() error {
-	return a.setExposed(false)
+	return a.setExposed(false, nil)
 }
 
-func (a *Application) setExposed(exposed bool) (err error) {
+func (a *Application) setExposed(exposed bool, spaces []string) (err error) {
+	var update bson.D
+	if len(spaces) == 0 {
+		spaces = nil
+		update = bson.D{
+			{"$set", bson.D{{"exposed", exposed}}},
+			{"$unset", bson.D{{"exposed-spaces", nil}}},
+		}
+	} else {
+		update = bson.D{{"$set", bson.D{{"exposed", exposed}, {"exposed-spaces", spaces}}}}
+	}
 	ops := []txn.Op{{
 		C:      applicationsC,
 		Id:     a.doc.DocID,
 		Assert: isAliveDoc,
-		Update: bson.D{{"$set", bson.D{{"exposed", exposed}}}},
+		Update: update,
 	}}
 	if err := a.st.db().RunTransaction(ops); err != nil {
 		return errors.Errorf("cannot set exposed flag for application %q to %v: %v", a, exposed, onAbort(err, errNotAlive))
 	}
 	a.doc.Exposed = exposed
+	a.doc.ExposedSpaces = spaces
 	return nil
 }
 
@@ -1570,16 +1621,31 @@ func (a *Application) ConfigSettings() (charm.Settings, error) {
 }
 
 // UpdateConfigSettings changes a application's charm config settings. Values set
-// to nil will be deleted; unknown and invalid values will return an error.
+// to nil will be deleted, even for options the charm does not define; other
+// unknown and invalid values will return an error.
 func (a *Application) UpdateConfigSettings(changes charm.Settings) error {
 	charm, _, err := a.Charm()
 	if err != nil {
 		return err
 	}
-	changes, err = charm.Config().ValidateSettings(changes)
+	// Settings for options that the charm no longer defines, such
+	// as those left behind by an upgrade, may only be removed.
+	var unknown []string
+	known := make(map[string]interface{})
+	for name, value := range changes {
+		if _, ok := charm.Config().Options[name]; !ok && value == nil {
+			unknown = append(unknown, name)
+			continue
+		}
+		known[name] = value
+	}
+	changes, err = charm.Config().ValidateSettings(known)
 	if err != nil {
 		return err
 	}
+	for _, name := range unknown {
+		changes[name] = nil
+	}
 	// TODO(fwereade) state.Settings is itself really problematic in just
 	// about every use case. This needs to be resolved some time; but at
 	// least the settings docs are keyed by charm url as well as application
@@ -1588,6 +1654,7 @@ func (a *Application) UpdateConfigSettings(changes charm.Settings) error {
 	if err != nil {
 		return err
 	}
+	previous := node.Map()
 	for name, value := range changes {
 		if value == nil {
 			node.Delete(name)
@@ -1595,8 +1662,92 @@ func (a *Application) UpdateConfigSettings(changes charm.Settings) error {
 			node.Set(name, value)
 		}
 	}
-	_, err = node.Write()
-	return err
+	itemChanges, ops := node.settingsUpdateOps()
+	if len(ops) == 0 {
+		return nil
+	}
+	// The settings are retained as they were before the change, so
+	// that it can be reverted, and the time of the change is recorded
+	// against each setting changed.
+	previousConfig := copyMap(previous, escapeReplacer.Replace)
+	updates := bson.D{{"previous-config", previousConfig}}
+	now := a.st.clock().Now().UnixNano()
+	for _, change := range itemChanges {
+		key := escapeReplacer.Replace(change.Key)
+		updates = append(updates, bson.DocElem{"config-changed." + key, now})
+	}
+	ops = append(ops, txn.Op{
+		C:  applicationsC,
+		Id: a.doc.DocID,
+		// The settings of a locked application may not be changed,
+		// even if it was locked since a.doc was read.
+		Assert: bson.D{{"config-locked", bson.D{{"$ne", true}}}},
+		Update: bson.D{{"$set", updates}},
+	})
+	if err := node.write(ops); err != nil {
+		if errors.IsNotFound(err) {
+			if current, rerr := a.st.Application(a.doc.Name); rerr == nil && current.IsConfigLocked() {
+				a.doc.ConfigLocked = true
+				return errors.Errorf("cannot change settings of application %q: config locked", a.doc.Name)
+			}
+		}
+		return err
+	}
+	a.doc.PreviousConfig = previousConfig
+	if a.doc.ConfigChanged == nil {
+		a.doc.ConfigChanged = make(map[string]int64)
+	}
+	for _, change := range itemChanges {
+		a.doc.ConfigChanged[escapeReplacer.Replace(change.Key)] = now
+	}
+	return nil
+}
+
+// PreviousConfigSettings returns the application's charm config settings
+// as they were before the most recent change made by UpdateConfigSettings.
+// It returns a NotFound error if the settings have not been changed.
+func (a *Application) PreviousConfigSettings() (charm.Settings, error) {
+	if a.doc.PreviousConfig == nil {
+		return nil, errors.NotFoundf("previous config settings for application %q", a.doc.Name)
+	}
+	return copyMap(a.doc.PreviousConfig, unescapeReplacer.Replace), nil
+}
+
+// ConfigChangeTimes returns the time at which each of the application's
+// charm config settings was last changed by UpdateConfigSettings.
+// Settings that have not been changed since the controller began
+// recording the times are left out.
+func (a *Application) ConfigChangeTimes() map[string]time.Time {
+	times := make(map[string]time.Time)
+	for key, nanos := range a.doc.ConfigChanged {
+		times[unescapeReplacer.Replace(key)] = time.Unix(0, nanos)
+	}
+	return times
+}
+
+// IsConfigLocked returns whether the application's charm config settings
+// are locked against change. See SetConfigLocked.
+func (a *Application) IsConfigLocked() bool {
+	return a.doc.ConfigLocked
+}
+
+// SetConfigLocked locks or unlocks the application's charm config
+// settings. UpdateConfigSettings refuses to change the settings of a
+// locked application; it is up to clients of state, such as the API
+// server, to refuse other changes to them, such as those made when
+// upgrading the application's charm.
+func (a *Application) SetConfigLocked(locked bool) error {
+	ops := []txn.Op{{
+		C:      applicationsC,
+		Id:     a.doc.DocID,
+		Assert: isAliveDoc,
+		Update: bson.D{{"$set", bson.D{{"config-locked", locked}}}},
+	}}
+	if err := a.st.db().RunTransaction(ops); err != nil {
+		return errors.Errorf("cannot set config lock for application %q to %v: %v", a, locked, onAbort(err, errNotAlive))
+	}
+	a.doc.ConfigLocked = locked
+	return nil
 }
 
 // LeaderSettings returns a application's leader settings. If nothing has been set
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -853,6 +854,9 @@ var serviceUpdateConfigSettingsTests = []struct {
 	about:  "unknown option",
 	update: charm.Settings{"foo": "bar"},
 	err:    `unknown option "foo"`,
+}, {
+	about:  "unset unknown option",
+	update: charm.Settings{"foo": nil},
 }, {
 	about:  "bad type",
 	update: charm.Settings{"skill-level": "profound"},
@@ -921,6 +925,110 @@ func (s *ApplicationSuite) TestUpdateConfigSettings(c *gc.C) {
 	}
 }
 
+func (s *ApplicationSuite) TestPreviousConfigSettings(c *gc.C) {
+	sch := s.AddTestingCharm(c, "dummy")
+	app := s.AddTestingApplication(c, "dummy-application", sch)
+	_, err := app.PreviousConfigSettings()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	err = app.UpdateConfigSettings(charm.Settings{"outlook": "positive"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.UpdateConfigSettings(charm.Settings{"outlook": nil, "skill-level": 303})
+	c.Assert(err, jc.ErrorIsNil)
+	previous, err := app.PreviousConfigSettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(previous, gc.DeepEquals, charm.Settings{"outlook": "positive"})
+
+	// Updates that change nothing leave the previous settings alone.
+	err = app.UpdateConfigSettings(charm.Settings{"skill-level": 303})
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	previous, err = app.PreviousConfigSettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(previous, gc.DeepEquals, charm.Settings{"outlook": "positive"})
+}
+
+func (s *ApplicationSuite) TestConfigChangeTimes(c *gc.C) {
+	sch := s.AddTestingCharm(c, "dummy")
+	app := s.AddTestingApplication(c, "dummy-application", sch)
+	c.Assert(app.ConfigChangeTimes(), gc.HasLen, 0)
+
+	first := s.Clock.Now()
+	err := app.UpdateConfigSettings(charm.Settings{"outlook": "positive", "skill-level": 303})
+	c.Assert(err, jc.ErrorIsNil)
+	s.Clock.Advance(time.Hour)
+	second := s.Clock.Now()
+	err = app.UpdateConfigSettings(charm.Settings{"outlook": nil, "skill-level": 303})
+	c.Assert(err, jc.ErrorIsNil)
+
+	expect := map[string]time.Time{
+		"outlook":     second,
+		"skill-level": first,
+	}
+	times := app.ConfigChangeTimes()
+	c.Assert(times, gc.HasLen, 2)
+	for key, t := range expect {
+		c.Check(times[key].Equal(t), jc.IsTrue, gc.Commentf("%s changed at %v", key, times[key]))
+	}
+
+	// The times survive a refresh from the database.
+	err = app.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	times = app.ConfigChangeTimes()
+	for key, t := range expect {
+		c.Check(times[key].Equal(t), jc.IsTrue, gc.Commentf("%s changed at %v", key, times[key]))
+	}
+}
+
+func (s *ApplicationSuite) TestConfigLocked(c *gc.C) {
+	c.Assert(s.mysql.IsConfigLocked(), jc.IsFalse)
+
+	err := s.mysql.SetConfigLocked(true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsConfigLocked(), jc.IsTrue)
+
+	// The lock survives a refresh from the database.
+	err = s.mysql.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsConfigLocked(), jc.IsTrue)
+
+	err = s.mysql.SetConfigLocked(false)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsConfigLocked(), jc.IsFalse)
+
+	err = s.mysql.Destroy()
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.SetConfigLocked(true)
+	c.Assert(err, gc.ErrorMatches, `cannot set config lock for application "mysql" to true: .*`)
+}
+
+func (s *ApplicationSuite) TestUpdateConfigSettingsConfigLocked(c *gc.C) {
+	sch := s.AddTestingCharm(c, "dummy")
+	app := s.AddTestingApplication(c, "dummy-application", sch)
+
+	// Lock the application through another object, so that app
+	// does not know about the lock until the update is refused.
+	other, err := s.State.Application(app.Name())
+	c.Assert(err, jc.ErrorIsNil)
+	err = other.SetConfigLocked(true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = app.UpdateConfigSettings(charm.Settings{"outlook": "positive"})
+	c.Assert(err, gc.ErrorMatches, `cannot change settings of application "dummy-application": config locked`)
+	c.Assert(app.IsConfigLocked(), jc.IsTrue)
+	settings, err := app.ConfigSettings()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(settings["outlook"], gc.IsNil)
+
+	err = other.SetConfigLocked(false)
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.UpdateConfigSettings(charm.Settings{"outlook": "positive"})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
 func (s *ApplicationSuite) TestUpdateApplicationSeries(c *gc.C) {
 	ch := state.AddTestingCharmMultiSeries(c, s.State, "multi-series")
 	app := state.AddTestingApplicationForSeries(c, s.State, "precise", "multi-series", ch)
@@ -1662,6 +1770,44 @@ func (s *ApplicationSuite) TestServiceExposed(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, notAliveErr)
 }
 
+func (s *ApplicationSuite) TestServiceExposedSpaces(c *gc.C) {
+	c.Assert(s.mysql.ExposedSpaces(), gc.HasLen, 0)
+
+	err := s.mysql.SetExposedSpaces([]string{"dmz"})
+	c.Assert(err, gc.ErrorMatches, `cannot expose application "mysql" to space "dmz": space "dmz" not found`)
+	c.Assert(s.mysql.IsExposed(), jc.IsFalse)
+
+	_, err = s.State.AddSpace("dmz", "", nil, true)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.SetExposedSpaces([]string{"dmz"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsExposed(), jc.IsTrue)
+	c.Assert(s.mysql.ExposedSpaces(), jc.DeepEquals, []string{"dmz"})
+
+	err = s.mysql.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsExposed(), jc.IsTrue)
+	c.Assert(s.mysql.ExposedSpaces(), jc.DeepEquals, []string{"dmz"})
+
+	// Exposing to everywhere forgets the spaces.
+	err = s.mysql.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsExposed(), jc.IsTrue)
+	c.Assert(s.mysql.ExposedSpaces(), gc.HasLen, 0)
+
+	// As does unexposing.
+	err = s.mysql.SetExposedSpaces([]string{"dmz"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.ClearExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.mysql.IsExposed(), jc.IsFalse)
+	c.Assert(s.mysql.ExposedSpaces(), gc.HasLen, 0)
+}
+
 func (s *ApplicationSuite) TestAddUnit(c *gc.C) {
 	// Check that principal units can be added on their own.
 	unitZero, err := s.mysql.AddUnit(state.AddUnitParams{})
@@ -2448,6 +2594,46 @@ func (s *ApplicationSuite) TestWatchApplication(c *gc.C) {
 	testing.NewNotifyWatcherC(c, s.State, w).AssertOneChange()
 }
 
+func (s *ApplicationSuite) TestWatchConfigSettings(c *gc.C) {
+	oldCh := s.AddConfigCharm(c, "mysql", stringConfig, 2)
+	err := s.mysql.SetCharm(state.SetCharmConfig{Charm: oldCh})
+	c.Assert(err, jc.ErrorIsNil)
+
+	w := s.mysql.WatchConfigSettings()
+	defer testing.AssertStop(c, w)
+
+	// Initial event.
+	wc := testing.NewNotifyWatcherC(c, s.State, w)
+	wc.AssertOneChange()
+
+	// Update config a couple of times, check a single event.
+	err = s.mysql.UpdateConfigSettings(charm.Settings{"key": "value1"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.mysql.UpdateConfigSettings(charm.Settings{"key": "value2"})
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertOneChange()
+
+	// Non-change is not reported.
+	err = s.mysql.UpdateConfigSettings(charm.Settings{"key": "value2"})
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertNoChange()
+
+	// Change the application's charm; nothing detected.
+	newCh := s.AddConfigCharm(c, "mysql", floatConfig, 3)
+	err = s.mysql.SetCharm(state.SetCharmConfig{Charm: newCh})
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertNoChange()
+
+	// Change config for the new charm; nothing detected.
+	err = s.mysql.UpdateConfigSettings(charm.Settings{"key": 42.0})
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertNoChange()
+
+	// Stop, check closed.
+	testing.AssertStop(c, w)
+	wc.AssertClosed()
+}
+
 func (s *ApplicationSuite) TestMetricCredentials(c *gc.C) {
 	err := s.mysql.SetMetricCredentials([]byte("hello there"))
 	c.Assert(err, jc.ErrorIsNil)
@@ -28,6 +28,7 @@ type constraintsDoc struct {
 	Tags         *[]string
 	Spaces       *[]string
 	VirtType     *string
+	Accelerator  *string
 }
 
 func (doc constraintsDoc) value() constraints.Value {
@@ -42,6 +43,7 @@ func (doc constraintsDoc) value() constraints.Value {
 		Tags:         doc.Tags,
 		Spaces:       doc.Spaces,
 		VirtType:     doc.VirtType,
+		Accelerator:  doc.Accelerator,
 	}
 	return result
 }
@@ -58,6 +60,7 @@ func newConstraintsDoc(cons constraints.Value) constraintsDoc {
 		Tags:         cons.Tags,
 		Spaces:       cons.Spaces,
 		VirtType:     cons.VirtType,
+		Accelerator:  cons.Accelerator,
 	}
 	return result
 }
@@ -87,3 +87,40 @@ func (st *State) ControllerConfig() (jujucontroller.Config, error) {
 	}
 	return settings.Map(), nil
 }
+
+// UpdateControllerConfig sets or removes the given controller
+// attributes. Only the attributes that may be changed after bootstrap,
+// listed in controller.MutableConfigAttributes, are accepted.
+func (st *State) UpdateControllerConfig(updateAttrs map[string]interface{}, removeAttrs []string) error {
+	for attr := range updateAttrs {
+		if !jujucontroller.MutableAttribute(attr) {
+			return errors.Errorf("cannot change controller attribute %q", attr)
+		}
+	}
+	for _, attr := range removeAttrs {
+		if !jujucontroller.MutableAttribute(attr) {
+			return errors.Errorf("cannot remove controller attribute %q", attr)
+		}
+	}
+	settings, err := readSettings(st.db(), controllersC, controllerSettingsGlobalKey)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, attr := range removeAttrs {
+		settings.Delete(attr)
+	}
+	settings.Update(updateAttrs)
+	current := jujucontroller.Config(settings.Map())
+	caCert, _ := current.CACert()
+	// The new values are coerced, and the result validated, as they
+	// were when the controller was bootstrapped.
+	coerced, err := jujucontroller.NewConfig(current.ControllerUUID(), caCert, settings.Map())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for attr := range updateAttrs {
+		settings.Set(attr, coerced[attr])
+	}
+	_, err = settings.Write()
+	return errors.Trace(err)
+}
@@ -9,7 +9,9 @@ import (
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/juju/controller"
+	"github.com/juju/juju/network"
 	"github.com/juju/juju/state"
+	statetesting "github.com/juju/juju/state/testing"
 )
 
 type ControllerSuite struct {
@@ -25,12 +27,15 @@ func (s *ControllerSuite) TestControllerAndModelConfigInitialisation(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 
 	optional := map[string]bool{
-		controller.IdentityURL:         true,
-		controller.IdentityPublicKey:   true,
-		controller.AutocertURLKey:      true,
-		controller.AutocertDNSNameKey:  true,
-		controller.AllowModelAccessKey: true,
-		controller.MongoMemoryProfile:  true,
+		controller.IdentityURL:            true,
+		controller.IdentityPublicKey:      true,
+		controller.AutocertURLKey:         true,
+		controller.AutocertDNSNameKey:     true,
+		controller.AllowModelAccessKey:    true,
+		controller.MongoMemoryProfile:     true,
+		controller.DefaultResourceTagsKey: true,
+		controller.FirewallPanicClose:     true,
+		controller.FirewallEssentialRules: true,
 	}
 	for _, controllerAttr := range controller.ControllerOnlyConfigAttributes {
 		v, ok := controllerSettings.Get(controllerAttr)
@@ -55,6 +60,61 @@ func (s *ControllerSuite) TestControllerConfig(c *gc.C) {
 	c.Assert(cfg["controller-uuid"], gc.Equals, s.State.ControllerUUID())
 }
 
+func (s *ControllerSuite) TestUpdateControllerConfig(c *gc.C) {
+	err := s.State.UpdateControllerConfig(map[string]interface{}{
+		controller.FirewallPanicClose:     "true",
+		controller.FirewallEssentialRules: "22/tcp",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	cfg, err := s.State.ControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cfg.FirewallPanicClose(), jc.IsTrue)
+	c.Assert(cfg.FirewallEssentialRules(), jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22),
+	})
+	c.Assert(cfg.ControllerUUID(), gc.Equals, s.State.ControllerUUID())
+
+	err = s.State.UpdateControllerConfig(nil, []string{controller.FirewallPanicClose})
+	c.Assert(err, jc.ErrorIsNil)
+	cfg, err = s.State.ControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cfg.FirewallPanicClose(), jc.IsFalse)
+	c.Assert(cfg.FirewallEssentialRules(), gc.HasLen, 1)
+}
+
+func (s *ControllerSuite) TestUpdateControllerConfigImmutable(c *gc.C) {
+	err := s.State.UpdateControllerConfig(map[string]interface{}{
+		controller.APIPort: 1234,
+	}, nil)
+	c.Assert(err, gc.ErrorMatches, `cannot change controller attribute "api-port"`)
+	err = s.State.UpdateControllerConfig(nil, []string{controller.CACertKey})
+	c.Assert(err, gc.ErrorMatches, `cannot remove controller attribute "ca-cert"`)
+}
+
+func (s *ControllerSuite) TestUpdateControllerConfigInvalid(c *gc.C) {
+	err := s.State.UpdateControllerConfig(map[string]interface{}{
+		controller.FirewallEssentialRules: "ssh",
+	}, nil)
+	c.Assert(err, gc.ErrorMatches, `invalid firewall essential rules in configuration: invalid rule "ssh": .*`)
+	cfg, err := s.State.ControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	_, ok := cfg[controller.FirewallEssentialRules]
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *ControllerSuite) TestWatchControllerConfigUpdate(c *gc.C) {
+	w := s.State.WatchControllerConfig()
+	defer statetesting.AssertStop(c, w)
+	wc := statetesting.NewNotifyWatcherC(c, s.State, w)
+	wc.AssertOneChange()
+
+	err := s.State.UpdateControllerConfig(map[string]interface{}{
+		controller.FirewallPanicClose: true,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	wc.AssertOneChange()
+}
+
 func (s *ControllerSuite) TestPing(c *gc.C) {
 	c.Assert(s.Controller.Ping(), gc.IsNil)
 	gitjujutesting.MgoServer.Restart()
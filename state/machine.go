@@ -140,6 +140,10 @@ type machineDoc struct {
 	// StopMongoUntilVersion holds the version that must be checked to
 	// know if mongo must be stopped.
 	StopMongoUntilVersion string `bson:",omitempty"`
+
+	// FirewallLockdown, when set, requests that all ports on the
+	// machine are closed, regardless of the ports opened by its units.
+	FirewallLockdown bool `bson:"firewall-lockdown,omitempty"`
 }
 
 func newMachine(st *State, doc *machineDoc) *Machine {
@@ -208,6 +212,9 @@ type instanceData struct {
 	Tags       *[]string   `bson:"tags,omitempty"`
 	AvailZone  *string     `bson:"availzone,omitempty"`
 
+	Accelerator      *string `bson:"accelerator,omitempty"`
+	AcceleratorCount *uint64 `bson:"acceleratorcount,omitempty"`
+
 	// KeepInstance is set to true if, on machine removal from Juju,
 	// the cloud instance should be retained.
 	KeepInstance bool `bson:"keep-instance,omitempty"`
@@ -222,6 +229,8 @@ func hardwareCharacteristics(instData instanceData) *instance.HardwareCharacteri
 		CpuPower:         instData.CpuPower,
 		Tags:             instData.Tags,
 		AvailabilityZone: instData.AvailZone,
+		Accelerator:      instData.Accelerator,
+		AcceleratorCount: instData.AcceleratorCount,
 	}
 }
 
@@ -352,6 +361,29 @@ func (m *Machine) StopMongoUntilVersion() (mongo.Version, error) {
 	return mongo.NewVersion(m.doc.StopMongoUntilVersion)
 }
 
+// FirewallLockdown reports whether all ports on the machine should be
+// closed, overriding the ports opened by its units.
+func (m *Machine) FirewallLockdown() bool {
+	return m.doc.FirewallLockdown
+}
+
+// SetFirewallLockdown sets or clears the firewall lockdown of the
+// machine. While locked down, the firewaller closes all ports on the
+// machine; once cleared, the ports opened by its units are restored.
+func (m *Machine) SetFirewallLockdown(lockdown bool) error {
+	ops := []txn.Op{{
+		C:      machinesC,
+		Id:     m.doc.DocID,
+		Assert: notDeadDoc,
+		Update: bson.D{{"$set", bson.D{{"firewall-lockdown", lockdown}}}},
+	}}
+	if err := m.st.db().RunTransaction(ops); err != nil {
+		return errors.Annotatef(onAbort(err, ErrDead), "cannot set firewall lockdown of machine %v", m)
+	}
+	m.doc.FirewallLockdown = lockdown
+	return nil
+}
+
 // IsManager returns true if the machine has JobManageModel.
 func (m *Machine) IsManager() bool {
 	return hasJob(m.doc.Jobs, JobManageModel)
@@ -1207,6 +1239,9 @@ func (m *Machine) SetProvisioned(id instance.Id, nonce string, characteristics *
 		CpuPower:   characteristics.CpuPower,
 		Tags:       characteristics.Tags,
 		AvailZone:  characteristics.AvailabilityZone,
+
+		Accelerator:      characteristics.Accelerator,
+		AcceleratorCount: characteristics.AcceleratorCount,
 	}
 
 	ops := []txn.Op{
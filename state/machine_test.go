@@ -919,6 +919,31 @@ func (s *MachineSuite) TestMachineAvailabilityZoneMissing(c *gc.C) {
 	c.Check(zone, gc.Equals, "")
 }
 
+func (s *MachineSuite) TestFirewallLockdown(c *gc.C) {
+	c.Assert(s.machine.FirewallLockdown(), jc.IsFalse)
+
+	err := s.machine.SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.machine.FirewallLockdown(), jc.IsTrue)
+
+	m, err := s.State.Machine(s.machine.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(m.FirewallLockdown(), jc.IsTrue)
+
+	err = m.SetFirewallLockdown(false)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.machine.Refresh()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.machine.FirewallLockdown(), jc.IsFalse)
+}
+
+func (s *MachineSuite) TestSetFirewallLockdownDeadMachine(c *gc.C) {
+	err := s.machine.EnsureDead()
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.machine.SetFirewallLockdown(true)
+	c.Assert(err, gc.ErrorMatches, `cannot set firewall lockdown of machine 1: not found or dead`)
+}
+
 func (s *MachineSuite) TestMachineSetCheckProvisioned(c *gc.C) {
 	// Check before provisioning.
 	c.Assert(s.machine.CheckProvisioned("fake_nonce"), jc.IsFalse)
@@ -468,7 +468,11 @@ func (e *exporter) newMachine(exParent description.Machine, machine *Machine, in
 		exMachine.AddOpenedPorts(args)
 	}
 
-	exMachine.SetAnnotations(e.getAnnotations(globalKey))
+	annotations := e.getAnnotations(globalKey)
+	if machine.doc.FirewallLockdown {
+		annotations = withMarkerAnnotation(annotations, firewallLockdownAnnotation)
+	}
+	exMachine.SetAnnotations(annotations)
 
 	constraintsArgs, err := e.constraintsArgs(globalKey)
 	if err != nil {
@@ -486,6 +490,13 @@ func (e *exporter) openedPortsArgsForMachine(machineId string, portsData []ports
 		if doc.MachineID == machineId && len(doc.Ports) > 0 {
 			args := description.OpenedPortsArgs{SubnetID: doc.SubnetID}
 			for _, p := range doc.Ports {
+				if p.Egress {
+					// The model description cannot represent
+					// egress ranges; their units must open
+					// them again after migration.
+					e.logger.Warningf("not exporting egress ports %v on machine %s", p, machineId)
+					continue
+				}
 				args.OpenedPorts = append(args.OpenedPorts, description.PortRangeArgs{
 					UnitName: p.UnitName,
 					FromPort: p.FromPort,
@@ -675,6 +686,10 @@ func (e *exporter) addApplication(ctx addApplicationContext) error {
 	}
 	delete(e.modelSettings, leadershipKey)
 
+	// The model description cannot restrict exposure to spaces, so
+	// applications exposed only to some spaces are exported as
+	// unexposed rather than as exposed to everywhere.
+	exposed := application.doc.Exposed && len(application.doc.ExposedSpaces) == 0
 	args := description.ApplicationArgs{
 		Tag:                  application.ApplicationTag(),
 		Series:               application.doc.Series,
@@ -683,7 +698,7 @@ func (e *exporter) addApplication(ctx addApplicationContext) error {
 		Channel:              application.doc.Channel,
 		CharmModifiedVersion: application.doc.CharmModifiedVersion,
 		ForceCharm:           application.doc.ForceCharm,
-		Exposed:              application.doc.Exposed,
+		Exposed:              exposed,
 		MinUnits:             application.doc.MinUnits,
 		EndpointBindings:     map[string]string(ctx.endpoingBindings[globalKey]),
 		Settings:             applicationSettingsDoc.Settings,
@@ -702,7 +717,11 @@ func (e *exporter) addApplication(ctx addApplicationContext) error {
 	}
 	exApplication.SetStatus(statusArgs)
 	exApplication.SetStatusHistory(e.statusHistoryArgs(globalKey))
-	exApplication.SetAnnotations(e.getAnnotations(globalKey))
+	annotations := e.getAnnotations(globalKey)
+	if application.doc.ConfigLocked {
+		annotations = withMarkerAnnotation(annotations, configLockedAnnotation)
+	}
+	exApplication.SetAnnotations(annotations)
 
 	constraintsArgs, err := e.constraintsArgs(globalKey)
 	if err != nil {
@@ -1278,6 +1297,28 @@ func (e *exporter) getAnnotations(key string) map[string]string {
 	return result.Annotations
 }
 
+// The model description has no fields for some entity state, which is
+// instead carried as marker annotations that import removes.
+const (
+	// configLockedAnnotation records that an application's config
+	// settings are locked.
+	configLockedAnnotation = "juju-config-locked"
+
+	// firewallLockdownAnnotation records that all ports on a machine
+	// are locked down.
+	firewallLockdownAnnotation = "juju-firewall-lockdown"
+)
+
+// withMarkerAnnotation returns a copy of the annotations with the
+// marker annotation set to "true".
+func withMarkerAnnotation(annotations map[string]string, marker string) map[string]string {
+	result := map[string]string{marker: "true"}
+	for key, value := range annotations {
+		result[key] = value
+	}
+	return result
+}
+
 func (e *exporter) readAllSettings() error {
 	e.modelSettings = make(map[string]settingsDoc)
 	if e.cfg.SkipSettings {
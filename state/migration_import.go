@@ -429,7 +429,7 @@ func (i *importer) machine(m description.Machine) error {
 	}
 
 	machine := newMachine(i.st, mdoc)
-	if annotations := m.Annotations(); len(annotations) > 0 {
+	if annotations := withoutMarkerAnnotation(m.Annotations(), firewallLockdownAnnotation); len(annotations) > 0 {
 		if err := i.im.SetAnnotations(machine, annotations); err != nil {
 			return errors.Trace(err)
 		}
@@ -581,6 +581,7 @@ func (i *importer) makeMachineDoc(m description.Machine) (*machineDoc, error) {
 		SupportedContainersKnown: supportedSet,
 		SupportedContainers:      supportedContainers,
 		Placement:                m.Placement(),
+		FirewallLockdown:         m.Annotations()[firewallLockdownAnnotation] == "true",
 	}, nil
 }
 
@@ -780,7 +781,7 @@ func (i *importer) application(a description.Application) error {
 		return errors.Trace(err)
 	}
 
-	if annotations := a.Annotations(); len(annotations) > 0 {
+	if annotations := withoutMarkerAnnotation(a.Annotations(), configLockedAnnotation); len(annotations) > 0 {
 		if err := i.im.SetAnnotations(app, annotations); err != nil {
 			return errors.Trace(err)
 		}
@@ -1011,9 +1012,26 @@ func (i *importer) makeApplicationDoc(s description.Application) (*applicationDo
 		Exposed:              s.Exposed(),
 		MinUnits:             s.MinUnits(),
 		MetricCredentials:    s.MetricsCredentials(),
+		ConfigLocked:         s.Annotations()[configLockedAnnotation] == "true",
 	}, nil
 }
 
+// withoutMarkerAnnotation returns the annotations of an imported entity
+// without the marker annotation that export uses to record state the
+// model description has no field for.
+func withoutMarkerAnnotation(annotations map[string]string, marker string) map[string]string {
+	if _, found := annotations[marker]; !found {
+		return annotations
+	}
+	result := make(map[string]string)
+	for key, value := range annotations {
+		if key != marker {
+			result[key] = value
+		}
+	}
+	return result
+}
+
 func (i *importer) relationCount(application string) int {
 	count := 0
 
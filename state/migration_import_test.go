@@ -375,6 +375,23 @@ func (s *MigrationImportSuite) TestMachines(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	// Can't test the constraints directly, so go through the string repr.
 	c.Assert(newCons.String(), gc.Equals, cons.String())
+	c.Assert(parent.FirewallLockdown(), jc.IsFalse)
+}
+
+func (s *MigrationImportSuite) TestMachineFirewallLockdown(c *gc.C) {
+	machine := s.Factory.MakeMachine(c, nil)
+	err := machine.SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.Model.SetAnnotations(machine, testAnnotations)
+	c.Assert(err, jc.ErrorIsNil)
+
+	newModel, newSt := s.importModel(c)
+
+	imported, err := newSt.Machine(machine.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(imported.FirewallLockdown(), jc.IsTrue)
+	// The annotation that carries the lockdown is not imported.
+	s.assertAnnotations(c, newModel, imported)
 }
 
 func (s *MigrationImportSuite) TestMachineDevices(c *gc.C) {
@@ -454,6 +471,7 @@ func (s *MigrationImportSuite) TestApplications(c *gc.C) {
 	c.Assert(imported.Series(), gc.Equals, exported.Series())
 	c.Assert(imported.IsExposed(), gc.Equals, exported.IsExposed())
 	c.Assert(imported.MetricCredentials(), jc.DeepEquals, exported.MetricCredentials())
+	c.Assert(imported.IsConfigLocked(), jc.IsFalse)
 
 	exportedConfig, err := exported.ConfigSettings()
 	c.Assert(err, jc.ErrorIsNil)
@@ -482,6 +500,22 @@ func (s *MigrationImportSuite) TestApplications(c *gc.C) {
 	c.Assert(resources.Resources, gc.HasLen, 3)
 }
 
+func (s *MigrationImportSuite) TestApplicationConfigLocked(c *gc.C) {
+	application := s.Factory.MakeApplication(c, nil)
+	err := application.SetConfigLocked(true)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.Model.SetAnnotations(application, testAnnotations)
+	c.Assert(err, jc.ErrorIsNil)
+
+	newModel, newSt := s.importModel(c)
+
+	imported, err := newSt.Application(application.Name())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(imported.IsConfigLocked(), jc.IsTrue)
+	// The annotation that carries the lock is not imported.
+	s.assertAnnotations(c, newModel, imported)
+}
+
 func (s *MigrationImportSuite) TestApplicationLeaders(c *gc.C) {
 	s.makeApplicationWithLeader(c, "mysql", 2, 1)
 	s.makeApplicationWithLeader(c, "wordpress", 4, 2)
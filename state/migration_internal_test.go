@@ -314,6 +314,7 @@ func (s *MigrationSuite) TestMachineDocFields(c *gc.C) {
 		"SupportedContainers",
 		"SupportedContainersKnown",
 		"Tools",
+		"FirewallLockdown",
 	)
 	s.AssertExportedFields(c, machineDoc{}, migrated.Union(ignored))
 }
@@ -323,6 +324,10 @@ func (s *MigrationSuite) TestInstanceDataFields(c *gc.C) {
 		// KeepInstance is only set when a machine is
 		// dying/dead (to be removed).
 		"KeepInstance",
+		// TODO: the model description cannot yet represent
+		// accelerators, so they are not migrated.
+		"Accelerator",
+		"AcceleratorCount",
 	)
 	migrated := set.NewStrings(
 		// DocID is the env + machine id
@@ -360,6 +365,16 @@ func (s *MigrationSuite) TestApplicationDocFields(c *gc.C) {
 		// RelationCount is handled by the number of times the application name
 		// appears in relation endpoints.
 		"RelationCount",
+		// PreviousConfig only supports reverting the most recent
+		// config change, so is not worth migrating.
+		"PreviousConfig",
+		// ConfigChanged records when settings were changed on
+		// this controller, and is rebuilt as they change again.
+		"ConfigChanged",
+		// ExposedSpaces is not yet supported by the model
+		// description, so applications exposed to spaces are
+		// migrated unexposed.
+		"ExposedSpaces",
 	)
 	migrated := set.NewStrings(
 		"Name",
@@ -372,6 +387,9 @@ func (s *MigrationSuite) TestApplicationDocFields(c *gc.C) {
 		"Exposed",
 		"MinUnits",
 		"MetricCredentials",
+		// ConfigLocked is carried as an annotation, as the model
+		// description has no field for it.
+		"ConfigLocked",
 	)
 	s.AssertExportedFields(c, applicationDoc{}, migrated.Union(ignored))
 }
@@ -536,6 +554,9 @@ func (s *MigrationSuite) TestConstraintsDocFields(c *gc.C) {
 		"Tags",
 		"Spaces",
 		"VirtType",
+		// TODO: the model description cannot yet represent
+		// the accelerator constraint, so it is not migrated.
+		"Accelerator",
 	)
 	s.AssertExportedFields(c, constraintsDoc{}, fields)
 }
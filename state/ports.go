@@ -37,6 +37,10 @@ type PortRange struct {
 	FromPort int
 	ToPort   int
 	Protocol string
+
+	// Egress is true if the unit connects out to the range on
+	// other hosts, rather than accepting connections on it.
+	Egress bool `bson:",omitempty"`
 }
 
 // NewPortRange create a new port range and validate it.
@@ -53,15 +57,23 @@ func NewPortRange(unitName string, fromPort, toPort int, protocol string) (PortR
 	return p, nil
 }
 
-// Validate checks if the port range is valid.
+// Validate checks if the port range is valid. A port range for an IP
+// protocol without ports, such as "esp", must have no ports.
 func (p PortRange) Validate() error {
 	proto := strings.ToLower(p.Protocol)
-	if proto != "tcp" && proto != "udp" {
+	_, portless := network.IPProtocolNumber(proto)
+	if proto != "tcp" && proto != "udp" && !portless {
 		return errors.Errorf("invalid protocol %q", proto)
 	}
 	if !names.IsValidUnit(p.UnitName) {
 		return errors.Errorf("invalid unit %q", p.UnitName)
 	}
+	if portless {
+		if p.FromPort != 0 || p.ToPort != 0 {
+			return errors.Errorf("invalid port range %d-%d: protocol %q has no ports", p.FromPort, p.ToPort, proto)
+		}
+		return nil
+	}
 	if p.FromPort > p.ToPort {
 		return errors.Errorf("invalid port range %d-%d", p.FromPort, p.ToPort)
 	}
@@ -116,7 +128,8 @@ func (prA PortRange) CheckConflicts(prB PortRange) error {
 	if prA == prB {
 		return nil
 	}
-	if prA.Protocol != prB.Protocol {
+	// Ingress and egress ranges are opened independently.
+	if prA.Protocol != prB.Protocol || prA.Egress != prB.Egress {
 		return nil
 	}
 	if prA.ToPort >= prB.FromPort && prB.ToPort >= prA.FromPort {
@@ -127,6 +140,15 @@ func (prA PortRange) CheckConflicts(prB PortRange) error {
 
 // Strings returns the port range as a string.
 func (p PortRange) String() string {
+	if _, portless := network.IPProtocolNumber(p.Protocol); portless {
+		if p.Egress {
+			return fmt.Sprintf("%s egress (%q)", strings.ToLower(p.Protocol), p.UnitName)
+		}
+		return fmt.Sprintf("%s (%q)", strings.ToLower(p.Protocol), p.UnitName)
+	}
+	if p.Egress {
+		return fmt.Sprintf("%d-%d/%s egress (%q)", p.FromPort, p.ToPort, strings.ToLower(p.Protocol), p.UnitName)
+	}
 	return fmt.Sprintf("%d-%d/%s (%q)", p.FromPort, p.ToPort, strings.ToLower(p.Protocol), p.UnitName)
 }
 
@@ -168,6 +190,12 @@ func extractPortsIDParts(globalKey string) ([]string, error) {
 	return nil, errors.NotValidf("ports document key %q", globalKey)
 }
 
+// MachineID returns the ID of the machine associated with this ports
+// document.
+func (p *Ports) MachineID() string {
+	return p.doc.MachineID
+}
+
 // SubnetID returns the subnet ID associated with this ports document.
 func (p *Ports) SubnetID() string {
 	return p.doc.SubnetID
@@ -337,10 +365,23 @@ func (p *Ports) Refresh() error {
 }
 
 // AllPortRanges returns a map with network.PortRange as keys and unit
-// names as values.
+// names as values. Egress port ranges are not included.
 func (p *Ports) AllPortRanges() map[network.PortRange]string {
+	return p.portRanges(false)
+}
+
+// AllEgressPortRanges returns a map with the egress network.PortRange
+// as keys and unit names as values.
+func (p *Ports) AllEgressPortRanges() map[network.PortRange]string {
+	return p.portRanges(true)
+}
+
+func (p *Ports) portRanges(egress bool) map[network.PortRange]string {
 	result := make(map[network.PortRange]string)
 	for _, portRange := range p.doc.Ports {
+		if portRange.Egress != egress {
+			continue
+		}
 		rawRange := network.PortRange{
 			FromPort: portRange.FromPort,
 			ToPort:   portRange.ToPort,
@@ -395,6 +436,24 @@ func (m *Machine) AllPorts() ([]*Ports, error) {
 	return results, nil
 }
 
+// AllOpenedPorts returns the opened ports documents of all machines in
+// the model, on all networks.
+func (st *State) AllOpenedPorts() ([]*Ports, error) {
+	openedPorts, closer := st.db().GetCollection(openedPortsC)
+	defer closer()
+
+	docs := []portsDoc{}
+	err := openedPorts.Find(nil).All(&docs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	results := make([]*Ports, len(docs))
+	for i, doc := range docs {
+		results[i] = &Ports{st: st, doc: doc}
+	}
+	return results, nil
+}
+
 // addPortsDocOps returns the ops for adding a number of port ranges
 // to a new ports document. portsAssert allows specifying an assert
 // statement for on the openedPorts collection op.
@@ -494,17 +553,10 @@ func removePortsForUnitOps(st *State, unit *Unit) ([]txn.Op, error) {
 	}
 	var ops []txn.Op
 	for _, ports := range allPorts {
-		allRanges := ports.AllPortRanges()
 		var keepPorts []PortRange
-		for portRange, unitName := range allRanges {
-			if unitName != unit.Name() {
-				unitRange := PortRange{
-					UnitName: unitName,
-					FromPort: portRange.FromPort,
-					ToPort:   portRange.ToPort,
-					Protocol: portRange.Protocol,
-				}
-				keepPorts = append(keepPorts, unitRange)
+		for _, portRange := range ports.doc.Ports {
+			if portRange.UnitName != unit.Name() {
+				keepPorts = append(keepPorts, portRange)
 			}
 		}
 		if len(keepPorts) > 0 {
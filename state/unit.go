@@ -905,11 +905,15 @@ func (u *Unit) SetStatus(unitStatus status.StatusInfo) error {
 // existing, alive subnet, otherwise an error is returned. Returns an error if
 // opening the requested range conflicts with another already opened range on
 // the same subnet and and the unit's assigned machine.
-func (u *Unit) OpenPortsOnSubnet(subnetID, protocol string, fromPort, toPort int) (err error) {
+func (u *Unit) OpenPortsOnSubnet(subnetID, protocol string, fromPort, toPort int) error {
 	ports, err := NewPortRange(u.Name(), fromPort, toPort, protocol)
 	if err != nil {
 		return errors.Annotatef(err, "invalid port range %v-%v/%v", fromPort, toPort, protocol)
 	}
+	return u.openPortRangeOnSubnet(subnetID, ports)
+}
+
+func (u *Unit) openPortRangeOnSubnet(subnetID string, ports PortRange) (err error) {
 	defer errors.DeferredAnnotatef(&err, "cannot open ports %v for unit %q on subnet %q", ports, u, subnetID)
 
 	machineID, err := u.AssignedMachineId()
@@ -948,11 +952,15 @@ func (u *Unit) checkSubnetAliveWhenSet(subnetID string) error {
 // ClosePortsOnSubnet closes the given port range and protocol for the unit on
 // the given subnet, which can be empty. When non-empty, subnetID must refer to
 // an existing, alive subnet, otherwise an error is returned.
-func (u *Unit) ClosePortsOnSubnet(subnetID, protocol string, fromPort, toPort int) (err error) {
+func (u *Unit) ClosePortsOnSubnet(subnetID, protocol string, fromPort, toPort int) error {
 	ports, err := NewPortRange(u.Name(), fromPort, toPort, protocol)
 	if err != nil {
 		return errors.Annotatef(err, "invalid port range %v-%v/%v", fromPort, toPort, protocol)
 	}
+	return u.closePortRangeOnSubnet(subnetID, ports)
+}
+
+func (u *Unit) closePortRangeOnSubnet(subnetID string, ports PortRange) (err error) {
 	defer errors.DeferredAnnotatef(&err, "cannot close ports %v for unit %q on subnet %q", ports, u, subnetID)
 
 	machineID, err := u.AssignedMachineId()
@@ -990,6 +998,30 @@ func (u *Unit) ClosePorts(protocol string, fromPort, toPort int) (err error) {
 	return u.ClosePortsOnSubnet("", protocol, fromPort, toPort)
 }
 
+// OpenEgressPorts records that the unit connects out to the given port
+// range and protocol on other hosts, so that the firewaller can allow that
+// traffic where the provider controls egress. Egress port ranges do not
+// conflict with the ranges the unit or others open for ingress.
+func (u *Unit) OpenEgressPorts(protocol string, fromPort, toPort int) error {
+	ports, err := NewPortRange(u.Name(), fromPort, toPort, protocol)
+	if err != nil {
+		return errors.Annotatef(err, "invalid port range %v-%v/%v", fromPort, toPort, protocol)
+	}
+	ports.Egress = true
+	return u.openPortRangeOnSubnet("", ports)
+}
+
+// CloseEgressPorts removes the given egress port range and protocol for
+// the unit.
+func (u *Unit) CloseEgressPorts(protocol string, fromPort, toPort int) error {
+	ports, err := NewPortRange(u.Name(), fromPort, toPort, protocol)
+	if err != nil {
+		return errors.Annotatef(err, "invalid port range %v-%v/%v", fromPort, toPort, protocol)
+	}
+	ports.Egress = true
+	return u.closePortRangeOnSubnet("", ports)
+}
+
 // OpenPortOnSubnet opens the given port and protocol for the unit on the given
 // subnet, which can be empty. When non-empty, subnetID must refer to an
 // existing, alive subnet, otherwise an error is returned.
@@ -1026,6 +1058,10 @@ func (u *Unit) ClosePort(protocol string, number int) error {
 // Also, when no ports are yet open for the unit on that subnet, no error and
 // empty slice is returned.
 func (u *Unit) OpenedPortsOnSubnet(subnetID string) ([]network.PortRange, error) {
+	return u.openedPortRangesOnSubnet(subnetID, false)
+}
+
+func (u *Unit) openedPortRangesOnSubnet(subnetID string, egress bool) ([]network.PortRange, error) {
 	machineID, err := u.AssignedMachineId()
 	if err != nil {
 		return nil, errors.Annotatef(err, "unit %q has no assigned machine", u)
@@ -1044,6 +1080,9 @@ func (u *Unit) OpenedPortsOnSubnet(subnetID string) ([]network.PortRange, error)
 	}
 	ports := machinePorts.PortsForUnit(u.Name())
 	for _, port := range ports {
+		if port.Egress != egress {
+			continue
+		}
 		result = append(result, network.PortRange{
 			Protocol: port.Protocol,
 			FromPort: port.FromPort,
@@ -1063,6 +1102,12 @@ func (u *Unit) OpenedPorts() ([]network.PortRange, error) {
 	return u.OpenedPortsOnSubnet("")
 }
 
+// OpenedEgressPorts returns a slice containing the egress port ranges of
+// the unit.
+func (u *Unit) OpenedEgressPorts() ([]network.PortRange, error) {
+	return u.openedPortRangesOnSubnet("", true)
+}
+
 // CharmURL returns the charm URL this unit is currently using.
 func (u *Unit) CharmURL() (*charm.URL, bool) {
 	if u.doc.CharmURL == nil {
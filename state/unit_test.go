@@ -1296,7 +1296,7 @@ func (s *UnitSuite) TestRemoveLastUnitOnMachineRemovesAllPorts(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(ports, gc.HasLen, 1)
 	c.Assert(ports[0].PortsForUnit(s.unit.Name()), jc.DeepEquals, []state.PortRange{
-		{s.unit.Name(), 100, 200, "tcp"},
+		{UnitName: s.unit.Name(), FromPort: 100, ToPort: 200, Protocol: "tcp"},
 	})
 
 	// Now remove the unit and check again.
@@ -1338,10 +1338,10 @@ func (s *UnitSuite) TestRemoveUnitRemovesItsPortsOnly(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(ports, gc.HasLen, 1)
 	c.Assert(ports[0].PortsForUnit(s.unit.Name()), jc.DeepEquals, []state.PortRange{
-		{s.unit.Name(), 100, 200, "tcp"},
+		{UnitName: s.unit.Name(), FromPort: 100, ToPort: 200, Protocol: "tcp"},
 	})
 	c.Assert(ports[0].PortsForUnit(otherUnit.Name()), jc.DeepEquals, []state.PortRange{
-		{otherUnit.Name(), 300, 400, "udp"},
+		{UnitName: otherUnit.Name(), FromPort: 300, ToPort: 400, Protocol: "udp"},
 	})
 
 	// Now remove the first unit and check again.
@@ -1358,10 +1358,41 @@ func (s *UnitSuite) TestRemoveUnitRemovesItsPortsOnly(c *gc.C) {
 	c.Assert(ports, gc.HasLen, 1)
 	c.Assert(ports[0].PortsForUnit(s.unit.Name()), gc.HasLen, 0)
 	c.Assert(ports[0].PortsForUnit(otherUnit.Name()), jc.DeepEquals, []state.PortRange{
-		{otherUnit.Name(), 300, 400, "udp"},
+		{UnitName: otherUnit.Name(), FromPort: 300, ToPort: 400, Protocol: "udp"},
 	})
 }
 
+func (s *UnitSuite) TestOpenCloseEgressPorts(c *gc.C) {
+	machine, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.unit.AssignToMachine(machine)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Egress ranges do not conflict with ingress ranges.
+	err = s.unit.OpenPorts("tcp", 80, 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.unit.OpenEgressPorts("tcp", 80, 443)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.unit.OpenEgressPorts("tcp", 400, 500)
+	c.Assert(err, gc.ErrorMatches, `.*port ranges 80-443/tcp egress \("wordpress/0"\) and 400-500/tcp egress \("wordpress/0"\) conflict`)
+
+	opened, err := s.unit.OpenedPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(opened, jc.DeepEquals, []network.PortRange{{FromPort: 80, ToPort: 80, Protocol: "tcp"}})
+	egress, err := s.unit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(egress, jc.DeepEquals, []network.PortRange{{FromPort: 80, ToPort: 443, Protocol: "tcp"}})
+
+	err = s.unit.CloseEgressPorts("tcp", 80, 443)
+	c.Assert(err, jc.ErrorIsNil)
+	egress, err = s.unit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(egress, gc.HasLen, 0)
+	opened, err = s.unit.OpenedPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(opened, gc.HasLen, 1)
+}
+
 func (s *UnitSuite) TestSetClearResolvedWhenNotAlive(c *gc.C) {
 	preventUnitDestroyRemove(c, s.unit)
 	err := s.unit.Destroy()
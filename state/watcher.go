@@ -1663,6 +1663,13 @@ func (a *Application) WatchLeaderSettings() NotifyWatcher {
 	return newEntityWatcher(a.st, settingsC, docId)
 }
 
+// WatchConfigSettings returns a watcher for observing changes to the
+// application's configuration settings. The returned watcher will be
+// valid only while the application's charm URL is not changed.
+func (a *Application) WatchConfigSettings() NotifyWatcher {
+	return newEntityWatcher(a.st, settingsC, a.st.docID(a.settingsKey()))
+}
+
 // Watch returns a watcher for observing changes to a unit.
 func (u *Unit) Watch() NotifyWatcher {
 	return newEntityWatcher(u.st, unitsC, u.doc.DocID)
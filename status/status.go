@@ -212,6 +212,11 @@ const (
 	Provisioning      Status = "allocating"
 	Running           Status = "running"
 	ProvisioningError Status = "provisioning error"
+
+	// Interrupting indicates that the instance is running, but the
+	// cloud has given notice that it will reclaim it shortly, as with
+	// an interrupted spot instance.
+	Interrupting Status = "interrupting"
 )
 
 const (
@@ -228,6 +233,7 @@ func (status Status) KnownInstanceStatus() bool {
 		ProvisioningError,
 		Allocating,
 		Running,
+		Interrupting,
 		Unknown:
 		return true
 	}
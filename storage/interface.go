@@ -236,6 +236,11 @@ type VolumeParams struct {
 	// storage provider supports tags.
 	ResourceTags map[string]string
 
+	// SnapshotId, if non-empty, is the provider ID of a snapshot from
+	// which the volume should be created. It is ignored by storage
+	// providers that do not support creating volumes from snapshots.
+	SnapshotId string
+
 	// Attachment identifies the machine that the volume should be attached
 	// to initially, or nil if the volume should not be attached to any
 	// machine. Some providers, such as MAAS, do not support dynamic
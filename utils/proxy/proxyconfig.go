@@ -4,6 +4,7 @@
 package proxy
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"net/url"
@@ -46,7 +47,13 @@ func (pc *ProxyConfig) Set(newSettings proxyutils.Settings) error {
 // stored settings rather than pulling the configuration from
 // environment variables. (The implementation is copied from
 // net/http.ProxyFromEnvironment.)
+//
+// If the request's context carries a ProxyConfig added with
+// WithConfig, that configuration is used instead.
 func (pc *ProxyConfig) GetProxy(req *http.Request) (*url.URL, error) {
+	if override, ok := req.Context().Value(configKey{}).(*ProxyConfig); ok && override != pc {
+		return override.GetProxy(req)
+	}
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
@@ -134,6 +141,18 @@ func (pc *ProxyConfig) InstallInDefaultTransport() error {
 
 var DefaultConfig = ProxyConfig{}
 
+type configKey struct{}
+
+// WithConfig returns a copy of ctx that carries the given proxy
+// configuration. Requests made with the returned context use pc
+// rather than the process-wide settings when resolved through a
+// ProxyConfig installed in the default transport. This allows, for
+// example, a provider to use per-model proxy settings for its API
+// calls.
+func WithConfig(ctx context.Context, pc *ProxyConfig) context.Context {
+	return context.WithValue(ctx, configKey{}, pc)
+}
+
 func tolerantParse(value string) (*url.URL, error) {
 	if value == "" {
 		return nil, nil
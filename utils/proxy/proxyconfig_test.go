@@ -4,6 +4,7 @@
 package proxy_test
 
 import (
+	"context"
 	"net/http"
 
 	jc "github.com/juju/testing/checkers"
@@ -115,3 +116,28 @@ func (s *Suite) TestInstall(c *gc.C) {
 	c.Assert(proxyURL, gc.Not(gc.IsNil))
 	c.Assert(proxyURL.String(), gc.Equals, "https://https.proxy")
 }
+
+func (s *Suite) TestGetProxyContextOverride(c *gc.C) {
+	pc := proxyconfig.ProxyConfig{}
+	c.Assert(pc.Set(normal), jc.ErrorIsNil)
+	override := &proxyconfig.ProxyConfig{}
+	c.Assert(override.Set(proxy.Settings{
+		Https:   "https://model.proxy",
+		NoProxy: ".internal",
+	}), jc.ErrorIsNil)
+
+	req, err := http.NewRequest("GET", "https://ec2.us-east-1.amazonaws.com", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	req = req.WithContext(proxyconfig.WithConfig(context.Background(), override))
+	proxyURL, err := pc.GetProxy(req)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(proxyURL, gc.Not(gc.IsNil))
+	c.Check(proxyURL.String(), gc.Equals, "https://model.proxy")
+
+	req, err = http.NewRequest("GET", "https://ec2.internal", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	req = req.WithContext(proxyconfig.WithConfig(context.Background(), override))
+	proxyURL, err = pc.GetProxy(req)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(proxyURL, gc.IsNil)
+}
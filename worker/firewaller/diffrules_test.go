@@ -8,7 +8,9 @@ import (
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
+	"github.com/juju/juju/instance"
 	"github.com/juju/juju/network"
+	"github.com/juju/juju/status"
 )
 
 type DiffRulesSuite struct {
@@ -129,3 +131,86 @@ func (s *DiffRulesSuite) TestDiffRangesClosesPortsIfRulesAreDisjoint(c *gc.C) {
 	c.Assert(toOpen, gc.DeepEquals, wanted)
 	c.Assert(toClose, gc.DeepEquals, current)
 }
+
+func (s *DiffRulesSuite) TestLargePortRangeUnchanged(c *gc.C) {
+	current := []network.IngressRule{
+		network.MustNewIngressRule("tcp", 30000, 32767, "0.0.0.0/0"),
+	}
+	wanted := []network.IngressRule{
+		network.MustNewIngressRule("tcp", 30000, 32767),
+	}
+	toOpen, toClose := diffRanges(current, wanted)
+	c.Assert(toOpen, gc.HasLen, 0)
+	c.Assert(toClose, gc.HasLen, 0)
+}
+
+func (s *DiffRulesSuite) TestProtocolWithoutPorts(c *gc.C) {
+	current := []network.IngressRule{
+		network.MustNewIngressRule("esp", 0, 0, "0.0.0.0/0"),
+		network.MustNewIngressRule("udp", 500, 500, "0.0.0.0/0"),
+	}
+	wanted := []network.IngressRule{
+		network.MustNewIngressRule("esp", 0, 0),
+		network.MustNewIngressRule("ah", 0, 0, "10.0.0.0/8"),
+	}
+	toOpen, toClose := diffRanges(current, wanted)
+	c.Assert(toOpen, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("ah", 0, 0, "10.0.0.0/8"),
+	})
+	c.Assert(toClose, jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("udp", 500, 500, "0.0.0.0/0"),
+	})
+}
+
+func (s *DiffRulesSuite) TestFilterAddressFamilies(c *gc.C) {
+	rules := []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0", "::/0"),
+		network.MustNewIngressRule("tcp", 443, 443),
+		network.MustNewIngressRule("tcp", 5432, 5432, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "2001:db8::/32"),
+	}
+	// No address family is denied unless families are given.
+	c.Assert(filterAddressFamilies(rules, nil), jc.DeepEquals, rules)
+	c.Assert(filterAddressFamilies(rules, []string{"ipv4"}), jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 5432, 5432, "10.0.0.0/8"),
+	})
+	c.Assert(filterAddressFamilies(rules, []string{"ipv6"}), jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "::/0"),
+		network.MustNewIngressRule("tcp", 443, 443, "::/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "2001:db8::/32"),
+	})
+	c.Assert(filterAddressFamilies(rules, []string{"ipv4", "ipv6"}), jc.DeepEquals, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0", "::/0"),
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0", "::/0"),
+		network.MustNewIngressRule("tcp", 5432, 5432, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "2001:db8::/32"),
+	})
+}
+
+func (s *DiffRulesSuite) TestInstanceStopped(c *gc.C) {
+	for i, test := range []struct {
+		about   string
+		status  status.Status
+		stopped bool
+	}{
+		{"ec2 pending", status.Pending, false},
+		{"ec2 running", status.Running, false},
+		{"ec2 stopping or stopped", status.Stopped, true},
+		{"ec2 shutting-down or terminated", status.Terminated, true},
+		{"azure creating or updating", status.Provisioning, false},
+		{"azure failed", status.ProvisioningError, false},
+		{"gce provisioning or staging", status.Provisioning, false},
+		{"gce stopping, stopped or terminated", status.Stopped, true},
+		{"openstack reboot, resize or rescue", status.Empty, false},
+		{"openstack shutoff or suspended", status.Stopped, true},
+		{"openstack deleted", status.Terminated, true},
+		{"openstack unknown", status.Unknown, false},
+		{"unrecognised", status.Status("updating"), false},
+	} {
+		c.Logf("test %d: %s", i, test.about)
+		stopped := instanceStopped(instance.InstanceStatus{Status: test.status})
+		c.Check(stopped, gc.Equals, test.stopped)
+	}
+}
@@ -4,7 +4,10 @@
 package firewaller
 
 import (
+	"fmt"
 	"io"
+	"net"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,19 +23,27 @@ import (
 	"github.com/juju/juju/api/firewaller"
 	"github.com/juju/juju/api/remoterelations"
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/controller"
 	"github.com/juju/juju/core/relation"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/instance"
 	"github.com/juju/juju/network"
+	"github.com/juju/juju/status"
 	"github.com/juju/juju/watcher"
 	"github.com/juju/juju/worker/catacomb"
 )
 
 // FirewallerAPI exposes functionality off the firewaller API facade to a worker.
 type FirewallerAPI interface {
+	BestAPIVersion() int
+	WatchForModelConfigChanges() (watcher.NotifyWatcher, error)
+	ModelConfig() (*config.Config, error)
+	ControllerConfig() (controller.Config, error)
 	WatchModelMachines() (watcher.StringsWatcher, error)
 	WatchOpenedPorts() (watcher.StringsWatcher, error)
+	AllMachinePorts() ([]firewaller.MachineSubnetPorts, error)
+	WatchControllerConfig() (watcher.NotifyWatcher, error)
 	Machine(tag names.MachineTag) (*firewaller.Machine, error)
 	Unit(tag names.UnitTag) (*firewaller.Unit, error)
 	Relation(tag names.RelationTag) (*firewaller.Relation, error)
@@ -71,6 +82,20 @@ type EnvironInstances interface {
 
 type newCrossModelFacadeFunc func(*api.Info) (CrossModelFirewallerFacadeCloser, error)
 
+// DefaultInstanceStatusPollInterval is how often the firewaller
+// checks whether machines' instances have been stopped or restarted.
+const DefaultInstanceStatusPollInterval = time.Minute
+
+// DefaultFlushDelay is how long the flushes of machines' ports in
+// response to changes to their units and the units' opened ports are
+// delayed, so that bursts of changes are coalesced.
+const DefaultFlushDelay = time.Second
+
+// DefaultExposedSpacesPollInterval is how often the firewaller checks
+// for changes to the subnets of the spaces that applications are
+// exposed to.
+const DefaultExposedSpacesPollInterval = time.Minute
+
 // Config defines the operation of a Worker.
 type Config struct {
 	ModelUUID          string
@@ -82,6 +107,45 @@ type Config struct {
 
 	NewCrossModelFacadeFunc newCrossModelFacadeFunc
 
+	// ReconcileConcurrency limits the number of machines whose
+	// instance firewall rules are reconciled with the provider at
+	// once when the worker starts. This keeps large models from
+	// tripping provider rate limits. If zero,
+	// DefaultReconcileConcurrency is used.
+	ReconcileConcurrency int
+
+	// InstanceStatusPollInterval is how often the status of machines'
+	// instances is checked in instance mode, so that the ports of
+	// stopped instances are closed and those of restarted instances
+	// reopened. The ports of running instances whose ingress rules
+	// have all been removed out of band, such as by recreating their
+	// security groups, are reopened too. If zero, instance status is
+	// only checked when the worker starts.
+	InstanceStatusPollInterval time.Duration
+
+	// FlushDelay is how long the flush of a machine's ports in
+	// response to changes to its units, or to the ports they have
+	// opened, is delayed. The changes made to any machine during
+	// the delay are flushed together, so that a burst of changes,
+	// such as when many units are added at once, results in a
+	// single flush of each affected machine. If zero, such changes
+	// are flushed immediately.
+	FlushDelay time.Duration
+
+	// ExposedSpacesPollInterval is how often the subnets of the
+	// spaces that each application is exposed to are checked for
+	// changes, so that ports are opened to subnets added to those
+	// spaces and closed to those removed from them. If zero, the
+	// subnets are only checked when the application changes.
+	ExposedSpacesPollInterval time.Duration
+
+	// OpenedPortsSnapshot, if true, loads the ports opened on every
+	// machine in the model in a single API call when the worker
+	// starts, rather than fetching the ports of each machine as it
+	// is started. This speeds up starting in large models. It has
+	// no effect if the controller cannot load them all at once.
+	OpenedPortsSnapshot bool
+
 	Clock clock.Clock
 }
 
@@ -105,6 +169,18 @@ func (config Config) Validate() error {
 	if config.NewCrossModelFacadeFunc == nil {
 		return errors.NotValidf("nil Cross Model Facade func")
 	}
+	if config.ReconcileConcurrency < 0 {
+		return errors.NotValidf("negative ReconcileConcurrency")
+	}
+	if config.InstanceStatusPollInterval < 0 {
+		return errors.NotValidf("negative InstanceStatusPollInterval")
+	}
+	if config.FlushDelay < 0 {
+		return errors.NotValidf("negative FlushDelay")
+	}
+	if config.ExposedSpacesPollInterval < 0 {
+		return errors.NotValidf("negative ExposedSpacesPollInterval")
+	}
 	return nil
 }
 
@@ -122,14 +198,82 @@ type Firewaller struct {
 
 	machinesWatcher      watcher.StringsWatcher
 	portsWatcher         watcher.StringsWatcher
+	modelConfigWatcher   watcher.NotifyWatcher
 	machineds            map[names.MachineTag]*machineData
 	unitsChange          chan *unitsChange
 	unitds               map[names.UnitTag]*unitData
 	applicationids       map[names.ApplicationTag]*applicationData
 	exposedChange        chan *exposedChange
+	lockdownChange       chan *lockdownChange
+	machineChange        chan *machineData
 	globalMode           bool
 	globalIngressRuleRef map[string]int // map of rule names to count of occurrences
 
+	// ruleGroupRules holds the rules of the model's enabled
+	// firewall rule groups, which are opened on every machine.
+	ruleGroupRules []network.IngressRule
+	// ruleGroupExpiry fires when the next of the firewall rule
+	// groups enabled for a limited time expires.
+	ruleGroupExpiry <-chan time.Time
+	// addressFamilies holds the address families for which ingress
+	// rules are opened; rules for other families are never opened.
+	// It is nil when no address family is denied.
+	addressFamilies []string
+	// panicClose is true if all ingress rules, apart from the
+	// essentialRules, are closed on every machine.
+	panicClose bool
+	// essentialRules holds the rules that remain open on every
+	// machine while panicClose is true.
+	essentialRules []network.IngressRule
+	// controllerPanicClose and controllerEssentialRules hold the
+	// controller's firewall panic close settings, which apply unless
+	// the model overrides them. controllerConfigWatcher notifies of
+	// changes to them; it is nil if the controller does not support
+	// watching its config.
+	controllerPanicClose     bool
+	controllerEssentialRules []network.IngressRule
+	controllerConfigWatcher  watcher.NotifyWatcher
+	// controllerAdminCIDRs holds the sources to which the
+	// controller's API and SSH ports are restricted.
+	controllerAdminCIDRs []string
+	// logDecisions is true if the reasons each port range is or is
+	// not opened on each machine are logged.
+	logDecisions bool
+	// frozen is true while firewall changes are deferred, as the
+	// current time is in one of the model's firewall freeze windows.
+	// frozenFlushes holds the machines whose flushes were deferred;
+	// they are flushed with the rules wanted on them at the time once
+	// the freeze ends, so that only the latest changes are applied.
+	// freezeChange fires when the next freeze starts or ends.
+	frozen        bool
+	frozenFlushes map[names.MachineTag]*machineData
+	freezeChange  <-chan time.Time
+	// reconcileFrozen and restrictFrozen are true if the reconciling
+	// of ports at startup, or the restricting of the controller's
+	// ports, was deferred by a freeze.
+	reconcileFrozen bool
+	restrictFrozen  bool
+	// ignoreFreeze is true while changes are applied despite a
+	// freeze, as when a firewall panic close is set.
+	ignoreFreeze bool
+	// openPortsTag is the name of the tag in which the port ranges
+	// open on each machine's instance are summarised, or "" if no
+	// such tag is maintained. openPortsTags holds the tag last queued
+	// to be written for each machine, and pendingTags the values of
+	// the tags waiting to be written, where "" removes the tag. They
+	// are written in batches when tagWrite fires, no more often than
+	// every openPortsTagInterval; lastTagWrite is when the last batch
+	// was written.
+	openPortsTag  string
+	openPortsTags map[names.MachineTag]openPortsTagged
+	pendingTags   map[instanceTag]string
+	tagWrite      <-chan time.Time
+	lastTagWrite  time.Time
+	// unsupportedProtocolWarned holds the units and port ranges
+	// that have been warned about as the provider cannot enforce
+	// their protocol.
+	unsupportedProtocolWarned set.Strings
+
 	modelUUID                   string
 	newRemoteFirewallerAPIFunc  newCrossModelFacadeFunc
 	remoteRelationsWatcher      watcher.StringsWatcher
@@ -137,6 +281,37 @@ type Firewaller struct {
 	localRelationsChange        chan *remoteRelationNetworkChange
 	relationIngress             map[names.RelationTag]*remoteRelationData
 	pollClock                   clock.Clock
+
+	metrics firewallMetrics
+
+	// reconcileConcurrency limits concurrent provider operations
+	// while reconciling instance ports at startup.
+	reconcileConcurrency int
+
+	instanceStatusPollInterval time.Duration
+
+	// flushDelay is how long flushes in response to changes to
+	// units and their opened ports are delayed. pendingFlushes
+	// holds the machines awaiting such a flush, which happens when
+	// pendingFlush fires.
+	flushDelay     time.Duration
+	pendingFlushes map[names.MachineTag]*machineData
+	pendingFlush   <-chan time.Time
+
+	exposedSpacesPollInterval time.Duration
+
+	// loadPortsSnapshot is true if the ports opened on every
+	// machine are loaded at once when the worker starts, into
+	// portsSnapshot. The snapshot is discarded once the initial
+	// machines and opened ports have been handled.
+	loadPortsSnapshot bool
+	portsSnapshot     *openedPortsSnapshot
+
+	// startInstances holds the instances of the initial machines, as
+	// fetched when they were started, so that the initial reconcile
+	// does not fetch them again. It is discarded once that reconcile
+	// has run, or been deferred.
+	startInstances map[names.MachineTag]instance.Instance
 }
 
 // NewFirewaller returns a new Firewaller.
@@ -158,12 +333,23 @@ func NewFirewaller(cfg Config) (worker.Worker, error) {
 		machineds:                   make(map[names.MachineTag]*machineData),
 		unitsChange:                 make(chan *unitsChange),
 		unitds:                      make(map[names.UnitTag]*unitData),
+		unsupportedProtocolWarned:   set.NewStrings(),
 		applicationids:              make(map[names.ApplicationTag]*applicationData),
 		exposedChange:               make(chan *exposedChange),
+		lockdownChange:              make(chan *lockdownChange),
+		machineChange:               make(chan *machineData),
 		relationIngress:             make(map[names.RelationTag]*remoteRelationData),
 		remoteRelationNetworkChange: make(chan *remoteRelationNetworkChange),
 		localRelationsChange:        make(chan *remoteRelationNetworkChange),
 		pollClock:                   clk,
+		reconcileConcurrency:        cfg.ReconcileConcurrency,
+		instanceStatusPollInterval:  cfg.InstanceStatusPollInterval,
+		flushDelay:                  cfg.FlushDelay,
+		exposedSpacesPollInterval:   cfg.ExposedSpacesPollInterval,
+		loadPortsSnapshot:           cfg.OpenedPortsSnapshot,
+	}
+	if fw.reconcileConcurrency == 0 {
+		fw.reconcileConcurrency = DefaultReconcileConcurrency
 	}
 
 	switch cfg.Mode {
@@ -202,6 +388,19 @@ func (fw *Firewaller) setUp() error {
 	if err := fw.catacomb.Add(fw.portsWatcher); err != nil {
 		return errors.Trace(err)
 	}
+	// The snapshot is loaded after the ports watcher is started, so
+	// any change it misses is reported by the watcher.
+	if fw.loadPortsSnapshot && fw.firewallerApi.BestAPIVersion() >= 9 {
+		all, err := fw.firewallerApi.AllMachinePorts()
+		if err != nil {
+			return errors.Annotate(err, "cannot load opened ports")
+		}
+		fw.portsSnapshot = newOpenedPortsSnapshot(all)
+		logger.Debugf("loaded %d opened ports documents", len(all))
+	}
+	if !fw.globalMode {
+		fw.startInstances = make(map[names.MachineTag]instance.Instance)
+	}
 
 	fw.remoteRelationsWatcher, err = fw.remoteRelationsApi.WatchRemoteRelations()
 	if err != nil {
@@ -211,6 +410,64 @@ func (fw *Firewaller) setUp() error {
 		return errors.Trace(err)
 	}
 
+	fw.modelConfigWatcher, err = fw.firewallerApi.WatchForModelConfigChanges()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := fw.catacomb.Add(fw.modelConfigWatcher); err != nil {
+		return errors.Trace(err)
+	}
+	// Consume the initial event and read the firewall rule groups
+	// before any machines are started, so that the rules are never
+	// closed and reopened while reconciling.
+	select {
+	case <-fw.catacomb.Dying():
+		return fw.catacomb.ErrDying()
+	case _, ok := <-fw.modelConfigWatcher.Changes():
+		if !ok {
+			return errors.New("model config watcher closed")
+		}
+	}
+	if fw.firewallerApi.BestAPIVersion() >= 10 {
+		fw.controllerConfigWatcher, err = fw.firewallerApi.WatchControllerConfig()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := fw.catacomb.Add(fw.controllerConfigWatcher); err != nil {
+			return errors.Trace(err)
+		}
+		select {
+		case <-fw.catacomb.Dying():
+			return fw.catacomb.ErrDying()
+		case _, ok := <-fw.controllerConfigWatcher.Changes():
+			if !ok {
+				return errors.New("controller config watcher closed")
+			}
+		}
+		if err := fw.readControllerConfig(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	cfg, err := fw.firewallerApi.ModelConfig()
+	if err != nil {
+		return errors.Annotate(err, "cannot read model config")
+	}
+	fw.ruleGroupRules = fw.enabledRuleGroupRules(cfg)
+	fw.addressFamilies = cfg.IngressAddressFamilies()
+	fw.panicClose, fw.essentialRules = fw.panicCloseSettings(cfg)
+	fw.logDecisions = cfg.FirewallDecisionLogging()
+	if fw.panicClose {
+		logger.Warningf("firewall panic close is set; only %v are opened on any machine", fw.essentialRules)
+	}
+	fw.updateFreeze(cfg)
+	fw.setOpenPortsTag(cfg.FirewallOpenPortsTag())
+	// The controller's ports are restricted at every start, as their
+	// sources may have been changed while the worker was stopped.
+	fw.controllerAdminCIDRs = cfg.ControllerAdminCIDRs()
+	if err := fw.restrictControllerPorts(); err != nil {
+		return errors.Trace(err)
+	}
+
 	logger.Debugf("started watching opened port ranges for the model")
 	return nil
 }
@@ -219,12 +476,59 @@ func (fw *Firewaller) loop() error {
 	if err := fw.setUp(); err != nil {
 		return errors.Trace(err)
 	}
-	var reconciled bool
+	var reconciled, portsWatched bool
+	dropPortsSnapshot := func() {
+		if reconciled && portsWatched {
+			fw.portsSnapshot = nil
+		}
+	}
+	var instanceStatusPoll <-chan time.Time
+	scheduleInstanceStatusPoll := func() {
+		if !fw.globalMode && fw.instanceStatusPollInterval > 0 {
+			instanceStatusPoll = fw.pollClock.After(fw.instanceStatusPollInterval)
+		}
+	}
 	portsChange := fw.portsWatcher.Changes()
+	var controllerConfigChange watcher.NotifyChannel
+	if fw.controllerConfigWatcher != nil {
+		controllerConfigChange = fw.controllerConfigWatcher.Changes()
+	}
 	for {
 		select {
 		case <-fw.catacomb.Dying():
 			return fw.catacomb.ErrDying()
+		case <-instanceStatusPoll:
+			if err := fw.pollInstanceStatus(); err != nil {
+				return errors.Trace(err)
+			}
+			scheduleInstanceStatusPoll()
+		case _, ok := <-fw.modelConfigWatcher.Changes():
+			if !ok {
+				return errors.New("model config watcher closed")
+			}
+			if err := fw.modelConfigChanged(); err != nil {
+				return errors.Annotate(err, "cannot change firewall ports")
+			}
+		case _, ok := <-controllerConfigChange:
+			if !ok {
+				return errors.New("controller config watcher closed")
+			}
+			if err := fw.readControllerConfig(); err != nil {
+				return errors.Trace(err)
+			}
+			if err := fw.modelConfigChanged(); err != nil {
+				return errors.Annotate(err, "cannot change firewall ports")
+			}
+		case <-fw.ruleGroupExpiry:
+			if err := fw.modelConfigChanged(); err != nil {
+				return errors.Annotate(err, "cannot close expired firewall rule groups")
+			}
+		case <-fw.freezeChange:
+			if err := fw.modelConfigChanged(); err != nil {
+				return errors.Annotate(err, "cannot apply deferred firewall changes")
+			}
+		case <-fw.tagWrite:
+			fw.writeInstanceTags()
 		case change, ok := <-fw.machinesWatcher.Changes():
 			if !ok {
 				return errors.New("machines watcher closed")
@@ -236,15 +540,12 @@ func (fw *Firewaller) loop() error {
 			}
 			if !reconciled {
 				reconciled = true
-				var err error
-				if fw.globalMode {
-					err = fw.reconcileGlobal()
-				} else {
-					err = fw.reconcileInstances()
-				}
-				if err != nil {
+				if err := fw.reconcile(); err != nil {
 					return errors.Trace(err)
 				}
+				fw.startInstances = nil
+				scheduleInstanceStatusPoll()
+				dropPortsSnapshot()
 			}
 		case change, ok := <-portsChange:
 			if !ok {
@@ -255,10 +556,17 @@ func (fw *Firewaller) loop() error {
 				if err != nil {
 					return errors.Trace(err)
 				}
+				if portsWatched {
+					fw.portsSnapshot.invalidate(machineTag)
+				}
 				if err := fw.openedPortsChanged(machineTag, subnetTag); err != nil {
 					return errors.Trace(err)
 				}
 			}
+			if !portsWatched {
+				portsWatched = true
+				dropPortsSnapshot()
+			}
 		case change, ok := <-fw.remoteRelationsWatcher.Changes():
 			if !ok {
 				return errors.New("remote relations watcher closed")
@@ -285,8 +593,12 @@ func (fw *Firewaller) loop() error {
 			if err := fw.unitsChanged(change); err != nil {
 				return errors.Trace(err)
 			}
+		case <-fw.pendingFlush:
+			if err := fw.flushPending(); err != nil {
+				return errors.Annotate(err, "cannot change firewall ports")
+			}
 		case change := <-fw.exposedChange:
-			change.applicationd.exposed = change.exposed
+			change.applicationd.exposure = change.exposure
 			unitds := []*unitData{}
 			for _, unitd := range change.applicationd.unitds {
 				unitds = append(unitds, unitd)
@@ -294,8 +606,256 @@ func (fw *Firewaller) loop() error {
 			if err := fw.flushUnits(unitds); err != nil {
 				return errors.Annotate(err, "cannot change firewall ports")
 			}
+		case change := <-fw.lockdownChange:
+			if err := fw.lockdownChanged(change); err != nil {
+				return errors.Annotate(err, "cannot change firewall ports")
+			}
+		case machined := <-fw.machineChange:
+			if err := fw.activeSubnetsChanged(machined); err != nil {
+				return errors.Annotate(err, "cannot change firewall ports")
+			}
+		}
+	}
+}
+
+// lockdownChanged responds to a change of a machine's firewall
+// lockdown flag, closing all of its ports or restoring the ports
+// opened by its units.
+func (fw *Firewaller) lockdownChanged(change *lockdownChange) error {
+	machined := change.machined
+	if fw.machineds[machined.tag] != machined {
+		// The machine has been forgotten in the meantime.
+		return nil
+	}
+	machined.lockdown = change.lockdown
+	if machined.lockdown {
+		logger.Infof("locking down firewall of %v", machined.tag)
+	} else {
+		logger.Infof("lifting firewall lockdown of %v", machined.tag)
+	}
+	return fw.flushMachine(machined)
+}
+
+// enabledRuleGroupRules returns the rules of the model's currently
+// enabled firewall rule groups, and schedules the closing of those
+// enabled for a limited time when the next of them expires. As the
+// expiry is part of the model config, it is honoured across restarts.
+func (fw *Firewaller) enabledRuleGroupRules(cfg *config.Config) []network.IngressRule {
+	now := fw.pollClock.Now()
+	rules, nextExpiry := cfg.EnabledFirewallRules(now)
+	fw.ruleGroupExpiry = nil
+	if !nextExpiry.IsZero() {
+		fw.ruleGroupExpiry = fw.pollClock.After(nextExpiry.Sub(now))
+	}
+	return rules
+}
+
+// updateFreeze records whether firewall changes are deferred at the
+// current time, according to the model's firewall freeze windows, and
+// schedules the next update for when that changes. It returns whether
+// a freeze has just ended.
+func (fw *Firewaller) updateFreeze(cfg *config.Config) (thawed bool) {
+	now := fw.pollClock.Now()
+	frozen, next := cfg.FirewallFrozen(now)
+	fw.freezeChange = nil
+	if !next.IsZero() {
+		fw.freezeChange = fw.pollClock.After(next.Sub(now))
+	}
+	if frozen && !fw.frozen {
+		logger.Infof("firewall freeze window started; deferring firewall changes until %v", next)
+	}
+	thawed = fw.frozen && !frozen
+	fw.frozen = frozen
+	return thawed
+}
+
+// deferChanges reports whether firewall changes are currently deferred
+// by a freeze.
+func (fw *Firewaller) deferChanges() bool {
+	return fw.frozen && !fw.ignoreFreeze
+}
+
+// thaw applies the firewall changes deferred during a freeze. Each
+// machine is flushed once, opening and closing only the difference
+// between the rules last applied to it and those now wanted.
+func (fw *Firewaller) thaw() error {
+	pending := fw.frozenFlushes
+	fw.frozenFlushes = nil
+	logger.Infof("firewall freeze window ended; applying deferred changes to %d machines", len(pending))
+	if err := fw.flushMachines(pending); err != nil {
+		return errors.Trace(err)
+	}
+	if fw.reconcileFrozen {
+		fw.reconcileFrozen = false
+		if err := fw.reconcile(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if fw.restrictFrozen {
+		fw.restrictFrozen = false
+		if err := fw.restrictControllerPorts(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// reconcile opens and closes the ports of every machine, or globally,
+// to match those juju expects, once the initial machines are known. It
+// is deferred until the end of any freeze.
+func (fw *Firewaller) reconcile() error {
+	if fw.deferChanges() {
+		fw.reconcileFrozen = true
+		return nil
+	}
+	if fw.globalMode {
+		return fw.reconcileGlobal()
+	}
+	return fw.reconcileInstances()
+}
+
+// modelConfigChanged responds to a change of the model's config, or
+// the expiry of a firewall rule group, by opening the rules of newly
+// enabled firewall rule groups, and closing those of disabled or
+// expired ones, on every machine. Rules are likewise opened or closed
+// when the address families for which ingress is allowed change, and
+// when a firewall panic close is set or cleared, for the controller or
+// the model. The controller's ports are restricted when the controller
+// admin CIDRs change. Changes deferred by a freeze are applied when it
+// ends.
+func (fw *Firewaller) modelConfigChanged() error {
+	cfg, err := fw.firewallerApi.ModelConfig()
+	if err != nil {
+		return errors.Annotate(err, "cannot read model config")
+	}
+	fw.logDecisions = cfg.FirewallDecisionLogging()
+	fw.setOpenPortsTag(cfg.FirewallOpenPortsTag())
+	thawed := fw.updateFreeze(cfg)
+	if adminCIDRs := cfg.ControllerAdminCIDRs(); strings.Join(adminCIDRs, ",") != strings.Join(fw.controllerAdminCIDRs, ",") {
+		fw.controllerAdminCIDRs = adminCIDRs
+		if err := fw.restrictControllerPorts(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	rules := fw.enabledRuleGroupRules(cfg)
+	families := cfg.IngressAddressFamilies()
+	panicClose, essentialRules := fw.panicCloseSettings(cfg)
+	rulesChanged := !ingressRulesEqual(rules, fw.ruleGroupRules)
+	familiesChanged := strings.Join(families, ",") != strings.Join(fw.addressFamilies, ",")
+	panicChanged := panicClose != fw.panicClose
+	essentialChanged := !ingressRulesEqual(essentialRules, fw.essentialRules)
+	if !rulesChanged && !familiesChanged && !panicChanged && !essentialChanged {
+		if thawed {
+			return errors.Trace(fw.thaw())
+		}
+		return nil
+	}
+	if rulesChanged {
+		logger.Infof("firewall rule groups changed; opening %v on all machines", rules)
+	}
+	if familiesChanged {
+		logger.Infof("ingress address families changed to %v; updating all machines", families)
+	}
+	if panicChanged {
+		if panicClose {
+			logger.Warningf("firewall panic close set; closing all ports except %v on all machines", essentialRules)
+			// A panic close is an emergency measure, so it is
+			// applied even during a freeze.
+			if fw.frozen {
+				fw.ignoreFreeze = true
+				defer func() { fw.ignoreFreeze = false }()
+			}
+		} else {
+			logger.Infof("firewall panic close cleared; restoring ports on all machines")
+		}
+	} else if essentialChanged && panicClose {
+		logger.Infof("essential firewall rules changed; opening %v on all machines", essentialRules)
+	}
+	fw.ruleGroupRules = rules
+	fw.addressFamilies = families
+	fw.panicClose = panicClose
+	fw.essentialRules = essentialRules
+	if err := fw.flushMachines(fw.machineds); err != nil {
+		return errors.Trace(err)
+	}
+	if thawed {
+		return errors.Trace(fw.thaw())
+	}
+	return nil
+}
+
+// readControllerConfig reads the controller's firewall panic close
+// settings.
+func (fw *Firewaller) readControllerConfig() error {
+	controllerCfg, err := fw.firewallerApi.ControllerConfig()
+	if err != nil {
+		return errors.Annotate(err, "cannot read controller config")
+	}
+	fw.controllerPanicClose = controllerCfg.FirewallPanicClose()
+	fw.controllerEssentialRules = controllerCfg.FirewallEssentialRules()
+	return nil
+}
+
+// panicCloseSettings returns whether a firewall panic close is in
+// effect for the model, and the essential rules that remain open
+// during it. Each is the controller's, unless the model overrides it.
+func (fw *Firewaller) panicCloseSettings(cfg *config.Config) (bool, []network.IngressRule) {
+	panicClose := fw.controllerPanicClose
+	if override, ok := cfg.FirewallPanicCloseOverride(); ok {
+		panicClose = override
+	}
+	essentialRules := fw.controllerEssentialRules
+	if override, ok := cfg.FirewallEssentialRulesOverride(); ok {
+		essentialRules = override
+	}
+	return panicClose, essentialRules
+}
+
+// modelRules returns the rules opened on every machine in the model:
+// those of the enabled firewall rule groups or, during a firewall panic
+// close, the essential rules.
+func (fw *Firewaller) modelRules() []network.IngressRule {
+	if fw.panicClose {
+		return fw.essentialRules
+	}
+	return fw.ruleGroupRules
+}
+
+// machineRuleGroupRules returns the model's rules to open on the given
+// machine, as returned by modelRules. In instance mode, they are only
+// opened once the machine has been provisioned.
+func (fw *Firewaller) machineRuleGroupRules(machined *machineData) ([]network.IngressRule, error) {
+	if machined.removed {
+		if fw.globalMode {
+			// Release the machine's references to the rules.
+			return nil, nil
+		}
+		// The rules are closed along with the machine's instance.
+		return machined.ruleGroupRules, nil
+	}
+	rules := fw.modelRules()
+	if len(rules) == 0 || fw.globalMode {
+		return rules, nil
+	}
+	if machined.instanceId == "" {
+		m, err := machined.machine()
+		if params.IsCodeNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.Trace(err)
+		}
+		instanceId, err := m.InstanceId()
+		if errors.IsNotProvisioned(err) {
+			// The rules are opened when the poller finds the
+			// machine's instance.
+			fw.logDecision(machined, "not opening %v yet: machine not provisioned", rules)
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.Trace(err)
 		}
+		machined.instanceId = instanceId
 	}
+	return rules, nil
 }
 
 func (fw *Firewaller) publishNetworkChanged(change *remoteRelationNetworkChange) error {
@@ -371,11 +931,13 @@ func (fw *Firewaller) relationIngressChanged(change *remoteRelationNetworkChange
 // machine and starts watching the machine for units added or removed.
 func (fw *Firewaller) startMachine(tag names.MachineTag) error {
 	machined := &machineData{
-		fw:           fw,
-		tag:          tag,
-		unitds:       make(map[names.UnitTag]*unitData),
-		ingressRules: make([]network.IngressRule, 0),
-		definedPorts: make(map[names.UnitTag]portRanges),
+		fw:                 fw,
+		tag:                tag,
+		unitds:             make(map[names.UnitTag]*unitData),
+		ingressRules:       make([]network.IngressRule, 0),
+		definedPorts:       make(map[names.UnitTag]portRanges),
+		subnetPorts:        make(map[names.SubnetTag]map[names.UnitTag]portRanges),
+		definedEgressPorts: make(map[names.UnitTag]portRanges),
 	}
 	m, err := machined.machine()
 	if params.IsCodeNotFound(err) {
@@ -383,6 +945,34 @@ func (fw *Firewaller) startMachine(tag names.MachineTag) error {
 	} else if err != nil {
 		return errors.Annotate(err, "cannot watch machine units")
 	}
+	// Machine watches and the lockdown flag are only available from
+	// version 5 of the facade. A lockdown cannot be honoured in global
+	// mode, where ports are shared by all machines.
+	var machinew watcher.NotifyWatcher
+	if fw.firewallerApi.BestAPIVersion() >= 5 && !fw.globalMode {
+		machinew, err = m.Watch()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := fw.catacomb.Add(machinew); err != nil {
+			return errors.Trace(err)
+		}
+		// Consume the initial event; the flag is read below.
+		select {
+		case <-fw.catacomb.Dying():
+			return fw.catacomb.ErrDying()
+		case _, ok := <-machinew.Changes():
+			if !ok {
+				return errors.New("machine watcher closed")
+			}
+		}
+		machined.lockdown, err = m.FirewallLockdown()
+		if params.IsCodeNotFound(err) {
+			return nil
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+	}
 	unitw, err := m.WatchUnits()
 	if err != nil {
 		return errors.Trace(err)
@@ -405,8 +995,38 @@ func (fw *Firewaller) startMachine(tag names.MachineTag) error {
 		if !ok {
 			return errors.New("machine units watcher closed")
 		}
+		if !fw.globalMode {
+			// Start from the rules the instance already has, such
+			// as those opened before the firewaller restarted, so
+			// that the first flush opens and closes only the
+			// difference.
+			inst, err := fw.machineInstance(machined, m)
+			if err != nil {
+				return errors.Annotatef(err, "cannot get instance of %q", tag)
+			}
+			machined.ingressRules, err = fw.instanceIngressRules(machined, inst)
+			if err != nil {
+				return errors.Annotatef(err, "cannot get ingress rules of %q", tag)
+			}
+			machined.egressPorts, err = fw.instanceEgressPorts(machined, inst)
+			if err != nil {
+				return errors.Annotatef(err, "cannot get egress ports of %q", tag)
+			}
+			if fw.startInstances != nil {
+				fw.startInstances[tag] = inst
+			}
+		}
+		// Flush once all of the machine's units are known, rather
+		// than as each is started, so that the rules of units not
+		// yet started are not closed in the meantime. This also
+		// opens the rule groups' rules, even if there are no units.
 		fw.machineds[tag] = machined
+		machined.starting = true
 		err = fw.unitsChanged(&unitsChange{machined, change})
+		machined.starting = false
+		if err == nil {
+			err = fw.flushMachine(machined)
+		}
 		if err != nil {
 			delete(fw.machineds, tag)
 			return errors.Annotatef(err, "cannot respond to units changes for %q", tag)
@@ -416,7 +1036,7 @@ func (fw *Firewaller) startMachine(tag names.MachineTag) error {
 	err = catacomb.Invoke(catacomb.Plan{
 		Site: &machined.catacomb,
 		Work: func() error {
-			return machined.watchLoop(unitw)
+			return machined.watchLoop(unitw, machinew)
 		},
 	})
 	if err != nil {
@@ -425,7 +1045,57 @@ func (fw *Firewaller) startMachine(tag names.MachineTag) error {
 	}
 
 	// register the machined with the firewaller's catacomb.
-	return fw.catacomb.Add(machined)
+	if err := fw.catacomb.Add(machined); err != nil {
+		return errors.Trace(err)
+	}
+	fw.updateTrackedMetrics()
+	return nil
+}
+
+// machineInstance returns the machine's instance, recording its ID, or
+// nil if it has not been provisioned or no longer exists.
+func (fw *Firewaller) machineInstance(machined *machineData, m *firewaller.Machine) (instance.Instance, error) {
+	instanceId, err := m.InstanceId()
+	if errors.IsNotProvisioned(err) || params.IsCodeNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	machined.instanceId = instanceId
+	instances, err := fw.environInstances.Instances([]instance.Id{instanceId})
+	if err == environs.ErrNoInstances {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return instances[0], nil
+}
+
+// instanceIngressRules returns the ingress rules open on the machine's
+// instance, or none if it is not known.
+func (fw *Firewaller) instanceIngressRules(machined *machineData, inst instance.Instance) ([]network.IngressRule, error) {
+	if inst == nil {
+		return nil, nil
+	}
+	return inst.IngressRules(machined.tag.Id())
+}
+
+// instanceEgressPorts returns the egress port ranges open on the
+// machine's instance, or none if it is not known or its provider does
+// not support egress rules.
+func (fw *Firewaller) instanceEgressPorts(machined *machineData, inst instance.Instance) ([]network.PortRange, error) {
+	if inst == nil || fw.firewallerApi.BestAPIVersion() < 6 {
+		return nil, nil
+	}
+	egressFirewaller, ok := inst.(instance.EgressFirewaller)
+	if !ok {
+		return nil, nil
+	}
+	ports, err := egressFirewaller.EgressPorts(machined.tag.Id())
+	if errors.IsNotSupported(err) {
+		return nil, nil
+	}
+	return ports, errors.Trace(err)
 }
 
 // startUnit creates a new data value for tracking details of the unit
@@ -461,15 +1131,10 @@ func (fw *Firewaller) startUnit(unit *firewaller.Unit, machineTag names.MachineT
 	unitd.applicationd = fw.applicationids[applicationTag]
 	unitd.applicationd.unitds[unitTag] = unitd
 
-	m, err := unitd.machined.machine()
-	if err != nil {
-		return err
-	}
-
 	// check if the machine has ports open on any subnets
-	subnetTags, err := m.ActiveSubnets()
+	subnetTags, err := fw.machineActiveSubnets(unitd.machined)
 	if err != nil {
-		return errors.Annotatef(err, "failed getting %q active subnets", machineTag)
+		return err
 	}
 	for _, subnetTag := range subnetTags {
 		err := fw.openedPortsChanged(machineTag, subnetTag)
@@ -477,29 +1142,46 @@ func (fw *Firewaller) startUnit(unit *firewaller.Unit, machineTag names.MachineT
 			return err
 		}
 	}
-
+	fw.updateTrackedMetrics()
 	return nil
 }
 
+// machineActiveSubnets returns the subnets for which ports are opened
+// on the machine, from the opened ports snapshot if it holds them.
+func (fw *Firewaller) machineActiveSubnets(machined *machineData) ([]names.SubnetTag, error) {
+	if subnetTags, ok := fw.portsSnapshot.activeSubnets(machined.tag); ok {
+		return subnetTags, nil
+	}
+	m, err := machined.machine()
+	if err != nil {
+		return nil, err
+	}
+	subnetTags, err := m.ActiveSubnets()
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed getting %q active subnets", machined.tag)
+	}
+	return subnetTags, nil
+}
+
 // startApplication creates a new data value for tracking details of the
 // application and starts watching the application for exposure changes.
 func (fw *Firewaller) startApplication(app *firewaller.Application) error {
-	exposed, err := app.IsExposed()
-	if err != nil {
-		return err
-	}
 	applicationd := &applicationData{
 		fw:          fw,
 		application: app,
-		exposed:     exposed,
 		unitds:      make(map[names.UnitTag]*unitData),
 	}
+	exposure, err := applicationd.getExposure()
+	if err != nil {
+		return err
+	}
+	applicationd.exposure = exposure
 	fw.applicationids[app.Tag()] = applicationd
 
 	err = catacomb.Invoke(catacomb.Plan{
 		Site: &applicationd.catacomb,
 		Work: func() error {
-			return applicationd.watchLoop(exposed)
+			return applicationd.watchLoop(exposure)
 		},
 	})
 	if err != nil {
@@ -520,6 +1202,14 @@ func (fw *Firewaller) reconcileGlobal() error {
 		machines = append(machines, machined)
 	}
 	want, err := fw.gatherIngressRules(machines...)
+	if fw.panicClose {
+		// Only the essential rules are opened during a panic close.
+		want = nil
+	}
+	if len(machines) > 0 {
+		want = append(want, fw.modelRules()...)
+	}
+	want = filterAddressFamilies(want, fw.addressFamilies)
 	initialPortRanges, err := fw.environFirewaller.IngressRules()
 	if err != nil {
 		return err
@@ -529,13 +1219,17 @@ func (fw *Firewaller) reconcileGlobal() error {
 	toOpen, toClose := diffRanges(initialPortRanges, want)
 	if len(toOpen) > 0 {
 		logger.Infof("opening global ports %v", toOpen)
-		if err := fw.environFirewaller.OpenPorts(toOpen); err != nil {
+		err := fw.environFirewaller.OpenPorts(toOpen)
+		fw.metrics.recordOpenPorts(len(toOpen), err)
+		if err != nil {
 			return err
 		}
 	}
 	if len(toClose) > 0 {
 		logger.Infof("closing global ports %v", toClose)
-		if err := fw.environFirewaller.ClosePorts(toClose); err != nil {
+		err := fw.environFirewaller.ClosePorts(toClose)
+		fw.metrics.recordClosePorts(len(toClose), err)
+		if err != nil {
 			return err
 		}
 	}
@@ -546,6 +1240,11 @@ func (fw *Firewaller) reconcileGlobal() error {
 // units and appications with the opened and closed ports of the instances and
 // opens and closes the appropriate ports for each instance.
 func (fw *Firewaller) reconcileInstances() error {
+	// Gather the provisioned machines first; the provider operations
+	// are then run with limited concurrency.
+	var reconcilers []func() error
+	var reconciled []*machineData
+	var stopped []bool
 	for _, machined := range fw.machineds {
 		m, err := machined.machine()
 		if params.IsCodeNotFound(err) {
@@ -565,53 +1264,212 @@ func (fw *Firewaller) reconcileInstances() error {
 		if err != nil {
 			return err
 		}
-		instances, err := fw.environInstances.Instances([]instance.Id{instanceId})
-		if err == environs.ErrNoInstances {
-			return nil
+		machined.instanceId = instanceId
+		machineTag := machined.tag
+		ingressRules := machined.ingressRules
+		// The instance fetched when the machine was started is used,
+		// if it is still the machine's.
+		inst := fw.startInstances[machineTag]
+		if inst != nil && inst.Id() != instanceId {
+			inst = nil
 		}
-		if err != nil {
+		i := len(reconcilers)
+		reconciled = append(reconciled, machined)
+		stopped = append(stopped, false)
+		reconcilers = append(reconcilers, func() (err error) {
+			stopped[i], err = fw.reconcileInstance(machineTag, instanceId, inst, ingressRules)
 			return err
+		})
+	}
+	if err := runLimited(fw.reconcileConcurrency, reconcilers); err != nil {
+		return err
+	}
+	for i, machined := range reconciled {
+		if stopped[i] {
+			// All ports were closed on the stopped instance.
+			machined.instanceStopped = true
+			machined.ingressRules = nil
+		}
+	}
+	return nil
+}
+
+// reconcileInstance opens and closes ports on the given machine's
+// instance so that they match the ingress rules juju expects. If the
+// instance is not running, all of its ports are closed instead, and
+// stopped is returned as true. The instance is fetched unless inst,
+// already fetched, is given.
+func (fw *Firewaller) reconcileInstance(
+	machineTag names.MachineTag, instanceId instance.Id, inst instance.Instance, ingressRules []network.IngressRule,
+) (stopped bool, _ error) {
+	if inst == nil {
+		instances, err := fw.environInstances.Instances([]instance.Id{instanceId})
+		if err == environs.ErrNoInstances {
+			return false, nil
 		}
-		machineId := machined.tag.Id()
-		initialRules, err := instances[0].IngressRules(machineId)
 		if err != nil {
-			return err
+			return false, err
 		}
+		inst = instances[0]
+	}
+	machineId := machineTag.Id()
+	initialRules, err := inst.IngressRules(machineId)
+	if err != nil {
+		return false, err
+	}
+	if instanceStopped(inst.Status()) {
+		logger.Infof("instance %v of %q is not running; closing its ports", instanceId, machineTag)
+		stopped = true
+		ingressRules = nil
+	}
 
-		// Check which ports to open or to close.
-		toOpen, toClose := diffRanges(initialRules, machined.ingressRules)
-		if len(toOpen) > 0 {
-			logger.Infof("opening instance port ranges %v for %q",
-				toOpen, machined.tag)
-			if err := instances[0].OpenPorts(machineId, toOpen); err != nil {
-				// TODO(mue) Add local retry logic.
-				return err
-			}
+	// Check which ports to open or to close.
+	toOpen, toClose := diffRanges(initialRules, ingressRules)
+	if len(toOpen) > 0 {
+		logger.Infof("opening instance port ranges %v for %q",
+			toOpen, machineTag)
+		err := inst.OpenPorts(machineId, toOpen)
+		fw.metrics.recordOpenPorts(len(toOpen), err)
+		if err != nil {
+			// TODO(mue) Add local retry logic.
+			return false, err
 		}
-		if len(toClose) > 0 {
-			logger.Infof("closing instance port ranges %v for %q",
-				toClose, machined.tag)
-			if err := instances[0].ClosePorts(machineId, toClose); err != nil {
-				// TODO(mue) Add local retry logic.
-				return err
-			}
+	}
+	if len(toClose) > 0 {
+		logger.Infof("closing instance port ranges %v for %q",
+			toClose, machineTag)
+		err := inst.ClosePorts(machineId, toClose)
+		fw.metrics.recordClosePorts(len(toClose), err)
+		if err != nil {
+			// TODO(mue) Add local retry logic.
+			return false, err
 		}
 	}
-	return nil
+	return stopped, nil
 }
 
-// unitsChanged responds to changes to the assigned units.
-func (fw *Firewaller) unitsChanged(change *unitsChange) error {
-	changed := []*unitData{}
-	for _, name := range change.units {
-		unitTag := names.NewUnitTag(name)
-		unit, err := fw.firewallerApi.Unit(unitTag)
-		if err != nil && !params.IsCodeNotFound(err) {
-			return err
-		}
-		var machineTag names.MachineTag
-		if unit != nil {
-			machineTag, err = unit.AssignedMachine()
+// pollInstanceStatus checks the status of the instances of all known
+// machines, closing the ports of those that have stopped and reopening
+// the ports of those that are running again.
+func (fw *Firewaller) pollInstanceStatus() error {
+	var machineds []*machineData
+	var ids []instance.Id
+	provisioned := make(map[*machineData]bool)
+	for _, machined := range fw.machineds {
+		if machined.instanceId == "" {
+			m, err := machined.machine()
+			if params.IsCodeNotFound(err) {
+				continue
+			} else if err != nil {
+				return errors.Trace(err)
+			}
+			machined.instanceId, err = m.InstanceId()
+			if errors.IsNotProvisioned(err) {
+				continue
+			} else if err != nil {
+				return errors.Trace(err)
+			}
+			provisioned[machined] = true
+		}
+		machineds = append(machineds, machined)
+		ids = append(ids, machined.instanceId)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	instances, err := fw.environInstances.Instances(ids)
+	if err != nil && err != environs.ErrPartialInstances {
+		if err == environs.ErrNoInstances {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	for i, inst := range instances {
+		if inst == nil {
+			// The instance is gone; the machine will be removed.
+			continue
+		}
+		machined := machineds[i]
+		stopped := instanceStopped(inst.Status())
+		if stopped == machined.instanceStopped {
+			if provisioned[machined] && len(fw.modelRules()) > 0 {
+				// Open the firewall rule groups' or essential
+				// rules on the newly provisioned machine.
+				if err := fw.flushMachine(machined); err != nil {
+					return errors.Annotate(err, "cannot change firewall ports")
+				}
+				continue
+			}
+			if !stopped {
+				if err := fw.reapplyClearedIngressRules(machined, inst); err != nil {
+					return errors.Annotate(err, "cannot change firewall ports")
+				}
+			}
+			continue
+		}
+		if stopped {
+			logger.Infof("instance %v of %q is not running; closing its ports", machined.instanceId, machined.tag)
+		} else {
+			logger.Infof("instance %v of %q is running; reopening its ports", machined.instanceId, machined.tag)
+		}
+		machined.instanceStopped = stopped
+		if err := fw.flushMachine(machined); err != nil {
+			return errors.Annotate(err, "cannot change firewall ports")
+		}
+	}
+	return nil
+}
+
+// reapplyClearedIngressRules reopens the ports of the given machine
+// if its running instance reports no ingress rules although some are
+// expected, as happens when the instance's security group is deleted
+// and recreated out of band. Rules that are only partially missing
+// are not detected.
+func (fw *Firewaller) reapplyClearedIngressRules(machined *machineData, inst instance.Instance) error {
+	if len(machined.ingressRules) == 0 {
+		return nil
+	}
+	rules, err := inst.IngressRules(machined.tag.Id())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(rules) > 0 {
+		return nil
+	}
+	logger.Warningf(
+		"instance %v of %q has no ingress rules, but %d are expected; reapplying them",
+		machined.instanceId, machined.tag, len(machined.ingressRules),
+	)
+	machined.ingressRules = nil
+	return fw.flushMachine(machined)
+}
+
+// instanceStopped reports whether the given instance status indicates
+// that the instance has been stopped or terminated. Providers report
+// transitional states, such as an instance rebooting or being resized,
+// with statuses that are not recognised here, so only the explicit
+// stopped and terminated statuses count; the ports of an instance in
+// any other state are left open.
+func instanceStopped(instStatus instance.InstanceStatus) bool {
+	switch instStatus.Status {
+	case status.Stopped, status.Terminated:
+		return true
+	}
+	return false
+}
+
+// unitsChanged responds to changes to the assigned units.
+func (fw *Firewaller) unitsChanged(change *unitsChange) error {
+	changed := []*unitData{}
+	for _, name := range change.units {
+		unitTag := names.NewUnitTag(name)
+		unit, err := fw.firewallerApi.Unit(unitTag)
+		if err != nil && !params.IsCodeNotFound(err) {
+			return err
+		}
+		var machineTag names.MachineTag
+		if unit != nil {
+			machineTag, err = unit.AssignedMachine()
 			if params.IsCodeNotFound(err) {
 				continue
 			} else if err != nil && !params.IsCodeNotAssigned(err) {
@@ -635,7 +1493,11 @@ func (fw *Firewaller) unitsChanged(change *unitsChange) error {
 			logger.Debugf("started watching %q", unitTag)
 		}
 	}
-	if err := fw.flushUnits(changed); err != nil {
+	machineds := make(map[names.MachineTag]*machineData)
+	for _, unitd := range changed {
+		machineds[unitd.machined.tag] = unitd.machined
+	}
+	if err := fw.flushMachinesSoon(machineds); err != nil {
 		return errors.Annotate(err, "cannot change firewall ports")
 	}
 	return nil
@@ -653,12 +1515,7 @@ func (fw *Firewaller) openedPortsChanged(machineTag names.MachineTag, subnetTag
 		return nil
 	}
 
-	m, err := machined.machine()
-	if err != nil {
-		return err
-	}
-
-	ports, err := m.OpenedPorts(subnetTag)
+	ports, egressPorts, err := fw.machineOpenedPorts(machined, subnetTag)
 	if err != nil {
 		return err
 	}
@@ -668,10 +1525,11 @@ func (fw *Firewaller) openedPortsChanged(machineTag names.MachineTag, subnetTag
 		unitd, ok := machined.unitds[unitTag]
 		if !ok {
 			// It is common to receive port change notification before
-			// registering a unit. Skip handling the port change - it will
-			// be handled when the unit is registered.
-			logger.Debugf("failed to lookup %q, skipping port change", unitTag)
-			return nil
+			// registering a unit. Skip the unit's ports - they will be
+			// handled when the unit is registered - but still apply
+			// those of the units already known.
+			logger.Debugf("failed to lookup %q, skipping its ports %v", unitTag, portRange)
+			continue
 		}
 		ranges, ok := newPortRanges[unitd.tag]
 		if !ok {
@@ -681,13 +1539,111 @@ func (fw *Firewaller) openedPortsChanged(machineTag names.MachineTag, subnetTag
 		ranges[portRange] = true
 	}
 
-	if !unitPortsEqual(machined.definedPorts, newPortRanges) {
-		machined.definedPorts = newPortRanges
-		return fw.flushMachine(machined)
+	changed := machined.setSubnetPorts(subnetTag, newPortRanges)
+
+	// Egress ports are only opened on the machine's default subnet,
+	// from version 6 of the facade.
+	if subnetTag.Id() == "" && fw.firewallerApi.BestAPIVersion() >= 6 {
+		newEgressPorts := make(map[names.UnitTag]portRanges)
+		for portRange, unitTag := range egressPorts {
+			if _, ok := machined.unitds[unitTag]; !ok {
+				logger.Debugf("failed to lookup %q, skipping its egress ports %v", unitTag, portRange)
+				continue
+			}
+			ranges, ok := newEgressPorts[unitTag]
+			if !ok {
+				ranges = make(portRanges)
+				newEgressPorts[unitTag] = ranges
+			}
+			ranges[portRange] = true
+		}
+		if !unitPortsEqual(machined.definedEgressPorts, newEgressPorts) {
+			machined.definedEgressPorts = newEgressPorts
+			changed = true
+		}
+	}
+
+	if changed {
+		return fw.flushMachineSoon(machined)
+	}
+	return nil
+}
+
+// activeSubnetsChanged responds to a change of a machine, such as a
+// network interface being attached or detached, by applying the ports
+// opened on the subnets on which the machine has newly become active,
+// and removing those of the subnets on which it no longer is. Changes
+// to the ports of the subnets already known are left to the ports
+// watcher.
+func (fw *Firewaller) activeSubnetsChanged(machined *machineData) error {
+	if fw.machineds[machined.tag] != machined {
+		// The machine has been forgotten in the meantime.
+		return nil
+	}
+	m, err := machined.machine()
+	if params.IsCodeNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	subnetTags, err := m.ActiveSubnets()
+	if params.IsCodeNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Annotatef(err, "failed getting %q active subnets", machined.tag)
+	}
+	active := make(map[names.SubnetTag]bool)
+	for _, subnetTag := range subnetTags {
+		active[subnetTag] = true
+		if _, ok := machined.subnetPorts[subnetTag]; ok {
+			continue
+		}
+		logger.Debugf("%v is active on subnet %q; applying its ports", machined.tag, subnetTag.Id())
+		if err := fw.openedPortsChanged(machined.tag, subnetTag); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	changed := false
+	for subnetTag := range machined.subnetPorts {
+		if active[subnetTag] {
+			continue
+		}
+		logger.Debugf("%v is no longer active on subnet %q; removing its ports", machined.tag, subnetTag.Id())
+		if machined.setSubnetPorts(subnetTag, nil) {
+			changed = true
+		}
+	}
+	if changed {
+		return fw.flushMachineSoon(machined)
 	}
 	return nil
 }
 
+// machineOpenedPorts returns the ingress and egress port ranges opened
+// by units on the machine for the subnet, from the opened ports
+// snapshot if it holds them. Egress ports are only fetched for the
+// machine's default subnet, from version 6 of the facade.
+func (fw *Firewaller) machineOpenedPorts(machined *machineData, subnetTag names.SubnetTag) (ingress, egress map[network.PortRange]names.UnitTag, _ error) {
+	if ingress, egress, ok := fw.portsSnapshot.openedPorts(machined.tag, subnetTag); ok {
+		return ingress, egress, nil
+	}
+	m, err := machined.machine()
+	if err != nil {
+		return nil, nil, err
+	}
+	ingress, err = m.OpenedPorts(subnetTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subnetTag.Id() == "" && fw.firewallerApi.BestAPIVersion() >= 6 {
+		egress, err = m.OpenedEgressPorts(subnetTag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return ingress, egress, nil
+}
+
 func unitPortsEqual(a, b map[names.UnitTag]portRanges) bool {
 	if len(a) != len(b) {
 		return false
@@ -704,6 +1660,18 @@ func unitPortsEqual(a, b map[names.UnitTag]portRanges) bool {
 	return true
 }
 
+func ingressRulesEqual(a, b []network.IngressRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
 func portRangesEqual(a, b portRanges) bool {
 	if len(a) != len(b) {
 		return false
@@ -726,28 +1694,301 @@ func (fw *Firewaller) flushUnits(unitds []*unitData) error {
 	for _, unitd := range unitds {
 		machineds[unitd.machined.tag] = unitd.machined
 	}
-	for _, machined := range machineds {
-		if err := fw.flushMachine(machined); err != nil {
-			return err
+	return fw.flushMachines(machineds)
+}
+
+// flushMachineSoon flushes the passed machine, or, if flushes are
+// delayed, arranges for it to be flushed once the delay has passed,
+// together with any other machines changed in the meantime.
+func (fw *Firewaller) flushMachineSoon(machined *machineData) error {
+	return fw.flushMachinesSoon(map[names.MachineTag]*machineData{machined.tag: machined})
+}
+
+// flushMachinesSoon flushes the passed machines together, or, if
+// flushes are delayed, arranges for them to be flushed once the delay
+// has passed.
+func (fw *Firewaller) flushMachinesSoon(machineds map[names.MachineTag]*machineData) error {
+	if fw.flushDelay == 0 {
+		return fw.flushMachines(machineds)
+	}
+	for tag, machined := range machineds {
+		if machined.starting {
+			// Machines being started are flushed once all
+			// their units are known.
+			continue
 		}
+		if fw.pendingFlushes == nil {
+			fw.pendingFlushes = make(map[names.MachineTag]*machineData)
+		}
+		fw.pendingFlushes[tag] = machined
+	}
+	if fw.pendingFlushes != nil && fw.pendingFlush == nil {
+		fw.pendingFlush = fw.pollClock.After(fw.flushDelay)
 	}
 	return nil
 }
 
-// flushMachine opens and closes ports for the passed machine.
+// flushPending flushes the machines whose flushes were delayed.
+func (fw *Firewaller) flushPending() error {
+	pending := fw.pendingFlushes
+	fw.pendingFlushes = nil
+	fw.pendingFlush = nil
+	return fw.flushMachines(pending)
+}
+
+// flushMachine opens and closes ports for the passed machine. Machines
+// being started are flushed once all their units are known.
 func (fw *Firewaller) flushMachine(machined *machineData) error {
+	return fw.flushMachines(map[names.MachineTag]*machineData{machined.tag: machined})
+}
+
+// machineFlush holds the ingress rules to open and close on a machine.
+type machineFlush struct {
+	machined        *machineData
+	toOpen, toClose []network.IngressRule
+}
+
+// flushMachines opens and closes ports for the passed machines. The
+// rules opened on every machine are opened before any are closed, and
+// the machines' rules are then closed in the reverse order, so that a
+// rule that moves between machines, such as when a unit is replaced,
+// or whose sources change, such as those scoped to a relation's
+// addresses, is never briefly closed. In global mode, such a rule is
+// neither closed nor reopened. Machines being started are flushed once
+// all their units are known. During a freeze, the machines are instead
+// flushed once it ends. The open ports tags of the machines whose rules
+// were flushed successfully are then updated.
+func (fw *Firewaller) flushMachines(machineds map[names.MachineTag]*machineData) error {
+	tags := make([]string, 0, len(machineds))
+	byTag := make(map[string]*machineData)
+	for tag, machined := range machineds {
+		if machined.starting {
+			continue
+		}
+		if fw.deferChanges() {
+			if fw.frozenFlushes == nil {
+				fw.frozenFlushes = make(map[names.MachineTag]*machineData)
+			}
+			fw.frozenFlushes[tag] = machined
+			continue
+		}
+		delete(fw.frozenFlushes, tag)
+		tags = append(tags, tag.String())
+		byTag[tag.String()] = machined
+	}
+	sort.Strings(tags)
+
+	var flushes []machineFlush
+	for _, tag := range tags {
+		machined := byTag[tag]
+		toOpen, toClose, err := fw.machineIngressChanges(machined)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		flushes = append(flushes, machineFlush{machined, toOpen, toClose})
+	}
+
+	// Opens are applied first. The rules of a machine whose rules
+	// could not be opened are not closed, so as not to close a rule
+	// that the failed open was to replace.
+	var firstErr error
+	failed := make(map[*machineData]bool)
+	for _, flush := range flushes {
+		if err := fw.flushIngressRules(flush.machined, flush.toOpen, nil); err != nil {
+			failed[flush.machined] = true
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for i := len(flushes) - 1; i >= 0; i-- {
+		flush := flushes[i]
+		if !failed[flush.machined] {
+			if err := fw.flushIngressRules(flush.machined, nil, flush.toClose); err != nil {
+				failed[flush.machined] = true
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		// Egress ports are flushed even if the ingress rules could
+		// not be, as the two are reconciled independently.
+		if err := fw.flushMachineEgress(flush.machined); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if !failed[flush.machined] {
+			fw.updateOpenPortsTag(flush.machined)
+		}
+	}
+	return firstErr
+}
+
+// machineIngressChanges returns the ingress rules to open and close on
+// the passed machine, recording the rules wanted on it.
+func (fw *Firewaller) machineIngressChanges(machined *machineData) (toOpen, toClose []network.IngressRule, _ error) {
 	want, err := fw.gatherIngressRules(machined)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, nil, errors.Trace(err)
+	}
+	groupRules, err := fw.machineRuleGroupRules(machined)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	machined.ruleGroupRules = groupRules
+	if fw.panicClose && !machined.removed {
+		// Only the essential rules are opened during a panic
+		// close; the units' ports are restored once it is cleared.
+		if len(want) > 0 {
+			fw.logDecision(machined, "not opening %v: firewall panic close is set", want)
+		}
+		want = nil
+	}
+	want = append(want, groupRules...)
+	want = filterAddressFamilies(want, fw.addressFamilies)
+	if machined.lockdown || machined.instanceStopped {
+		// No ports are wanted while the machine is locked down or
+		// its instance is stopped; the rules are restored once the
+		// lockdown is lifted or the instance is running again.
+		if len(want) > 0 {
+			reason := "machine is locked down"
+			if !machined.lockdown {
+				reason = "instance is stopped"
+			}
+			fw.logDecision(machined, "not opening %v: %s", want, reason)
+		}
+		want = nil
+	}
+	toOpen, toClose = diffRanges(machined.ingressRules, want)
+	if fw.logDecisions {
+		for _, rule := range toOpen {
+			fw.logDecision(machined, "opening %v", rule)
+		}
+		for _, rule := range toClose {
+			if machined.removed {
+				fw.logDecision(machined, "closing %v: machine removed", rule)
+			} else {
+				fw.logDecision(machined, "closing %v: no longer wanted", rule)
+			}
+		}
 	}
-	toOpen, toClose := diffRanges(machined.ingressRules, want)
 	machined.ingressRules = want
+	return toOpen, toClose, nil
+}
+
+// flushIngressRules opens and closes the given ingress rules for the
+// passed machine, globally or on its instance.
+func (fw *Firewaller) flushIngressRules(machined *machineData, toOpen, toClose []network.IngressRule) error {
 	if fw.globalMode {
 		return fw.flushGlobalPorts(toOpen, toClose)
 	}
 	return fw.flushInstancePorts(machined, toOpen, toClose)
 }
 
+// flushMachineEgress opens and closes egress ports on the passed
+// machine's instance, if its provider supports egress rules.
+func (fw *Firewaller) flushMachineEgress(machined *machineData) error {
+	want := gatherEgressPorts(machined)
+	if machined.lockdown {
+		want = nil
+	}
+	toOpen, toClose := diffPortRanges(machined.egressPorts, want)
+	if len(toOpen) == 0 && len(toClose) == 0 {
+		return nil
+	}
+	if fw.globalMode {
+		if len(toOpen) > 0 {
+			logger.Warningf("cannot open egress port ranges %v on %q: not supported in global firewall mode", toOpen, machined.tag)
+		}
+		machined.egressPorts = want
+		return nil
+	}
+	m, err := machined.machine()
+	if params.IsCodeNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	instanceId, err := m.InstanceId()
+	if err != nil {
+		return err
+	}
+	instances, err := fw.environInstances.Instances([]instance.Id{instanceId})
+	if err != nil {
+		return err
+	}
+	egressFirewaller, ok := instances[0].(instance.EgressFirewaller)
+	if !ok {
+		if len(toOpen) > 0 {
+			logger.Warningf("cannot open egress port ranges %v on %q: not supported by the provider", toOpen, machined.tag)
+		}
+		machined.egressPorts = want
+		return nil
+	}
+	machineId := machined.tag.Id()
+	if len(toOpen) > 0 {
+		err := egressFirewaller.OpenEgressPorts(machineId, toOpen)
+		if errors.IsNotSupported(err) {
+			logger.Warningf("cannot open egress port ranges %v on %q: %v", toOpen, machined.tag, err)
+		} else if err != nil {
+			return err
+		} else {
+			logger.Infof("opened egress port ranges %v on %q", toOpen, machined.tag)
+		}
+	}
+	if len(toClose) > 0 {
+		err := egressFirewaller.CloseEgressPorts(machineId, toClose)
+		if err != nil && !errors.IsNotSupported(err) {
+			return err
+		} else if err == nil {
+			logger.Infof("closed egress port ranges %v on %q", toClose, machined.tag)
+		}
+	}
+	machined.egressPorts = want
+	return nil
+}
+
+// gatherEgressPorts returns the egress port ranges wanted by the known
+// units of the machine, sorted by network.SortPortRanges().
+func gatherEgressPorts(machined *machineData) []network.PortRange {
+	var want []network.PortRange
+	seen := make(portRanges)
+	for unitTag, ranges := range machined.definedEgressPorts {
+		if _, known := machined.unitds[unitTag]; !known {
+			continue
+		}
+		for portRange := range ranges {
+			if !seen[portRange] {
+				seen[portRange] = true
+				want = append(want, portRange)
+			}
+		}
+	}
+	network.SortPortRanges(want)
+	return want
+}
+
+// diffPortRanges returns the port ranges in wanted but not current, and
+// those in current but not wanted.
+func diffPortRanges(current, wanted []network.PortRange) (toOpen, toClose []network.PortRange) {
+	currentSet := make(portRanges)
+	for _, portRange := range current {
+		currentSet[portRange] = true
+	}
+	wantedSet := make(portRanges)
+	for _, portRange := range wanted {
+		wantedSet[portRange] = true
+		if !currentSet[portRange] {
+			toOpen = append(toOpen, portRange)
+		}
+	}
+	for _, portRange := range current {
+		if !wantedSet[portRange] {
+			toClose = append(toClose, portRange)
+		}
+	}
+	return toOpen, toClose
+}
+
 // gatherIngressRules returns the ingress rules to open and close
 // for the specified machines.
 func (fw *Firewaller) gatherIngressRules(machines ...*machineData) ([]network.IngressRule, error) {
@@ -759,20 +2000,50 @@ func (fw *Firewaller) gatherIngressRules(machines ...*machineData) ([]network.In
 				logger.Debugf("no ingress rules for unknown %v on %v", unitTag, machined.tag)
 				continue
 			}
+			appName := unitd.applicationd.application.Tag().Id()
 
 			cidrs := set.NewStrings()
-			// If the unit is exposed, allow access from everywhere.
-			if unitd.applicationd.exposed {
+			exposure := unitd.applicationd.exposure
+			// If the unit is exposed to no particular spaces, allow
+			// access from everywhere; addresses of denied families
+			// are filtered out later. Unless address families are
+			// configured, everywhere means every IPv4 address, as
+			// it always has.
+			if exposure.exposed && len(exposure.spaces) == 0 {
 				cidrs.Add("0.0.0.0/0")
+				if len(fw.addressFamilies) > 0 {
+					cidrs.Add("::/0")
+				}
 			} else {
-				// Not exposed, so add any ingress rules required by remote relations.
+				// If the unit is exposed to spaces, allow access
+				// from their subnets.
+				for _, cidr := range exposure.cidrs {
+					cidrs.Add(cidr)
+				}
+				// Not exposed everywhere, so add any ingress rules required by remote relations.
 				if err := fw.updateForRemoteRelationIngress(unitd.applicationd.application.Tag(), cidrs); err != nil {
 					return nil, errors.Trace(err)
 				}
 				logger.Debugf("CIDRS for %v: %v", unitTag, cidrs.Values())
 			}
+			if cidrs.Size() == 0 && fw.logDecisions {
+				reason := exposureReason(appName, exposure, cidrs)
+				for portRange := range portRanges {
+					fw.logDecision(machined, "not opening %v of %v: %s", portRange, unitTag.Id(), reason)
+				}
+			}
 			if cidrs.Size() > 0 {
 				for portRange := range portRanges {
+					if !fw.supportsProtocol(portRange.Protocol) {
+						fw.warnUnsupportedProtocol(unitTag, portRange)
+						fw.logDecision(machined, "not opening %v of %v: the provider cannot enforce protocol %q",
+							portRange, unitTag.Id(), strings.ToLower(portRange.Protocol))
+						continue
+					}
+					if fw.logDecisions {
+						fw.logDecision(machined, "wanting %v of %v from %v: %s",
+							portRange, unitTag.Id(), cidrs.SortedValues(), exposureReason(appName, exposure, cidrs))
+					}
 					sourceCidrs := cidrs.SortedValues()
 					rule, err := network.NewIngressRule(portRange.Protocol, portRange.FromPort, portRange.ToPort, sourceCidrs...)
 					if err != nil {
@@ -786,6 +2057,84 @@ func (fw *Firewaller) gatherIngressRules(machines ...*machineData) ([]network.In
 	return want, nil
 }
 
+// exposureReason returns why the ports of the named application's units
+// are opened to the given CIDRs, or not opened if there are none, for
+// logging firewall decisions.
+func exposureReason(appName string, exposure exposure, cidrs set.Strings) string {
+	switch {
+	case exposure.exposed && len(exposure.spaces) == 0:
+		return fmt.Sprintf("application %q is exposed", appName)
+	case exposure.exposed && len(exposure.cidrs) > 0:
+		return fmt.Sprintf("application %q is exposed to spaces %v", appName, exposure.spaces)
+	case cidrs.Size() > 0:
+		return fmt.Sprintf("relations of application %q require ingress", appName)
+	case exposure.exposed:
+		return fmt.Sprintf("application %q is exposed to spaces %v, which have no subnets", appName, exposure.spaces)
+	default:
+		return fmt.Sprintf("application %q is not exposed, and no relations require ingress", appName)
+	}
+}
+
+// logDecision logs, at debug level, a decision about the ingress rules
+// of the given machine, if firewall-decision-logging is set.
+func (fw *Firewaller) logDecision(machined *machineData, format string, args ...interface{}) {
+	if fw.logDecisions {
+		logger.Debugf("firewall decision for %v: %s", machined.tag, fmt.Sprintf(format, args...))
+	}
+}
+
+// restrictControllerPorts restricts the sources of the controller's
+// API and SSH ports, which are opened on every machine when it is
+// provisioned, to the controller admin CIDRs; if there are none, the
+// ports are opened to all sources. Nothing is done unless the provider
+// supports it, and it is deferred until the end of any freeze.
+func (fw *Firewaller) restrictControllerPorts() error {
+	if fw.deferChanges() {
+		fw.restrictFrozen = true
+		return nil
+	}
+	portsFirewaller, ok := fw.environFirewaller.(environs.ControllerPortsFirewaller)
+	if !ok || fw.firewallerApi.BestAPIVersion() < 4 {
+		if len(fw.controllerAdminCIDRs) > 0 {
+			logger.Warningf("not restricting controller ports to %v: not supported by the provider", fw.controllerAdminCIDRs)
+		}
+		return nil
+	}
+	controllerCfg, err := fw.firewallerApi.ControllerConfig()
+	if err != nil {
+		return errors.Annotate(err, "cannot read controller config")
+	}
+	ports := []network.PortRange{
+		{FromPort: 22, ToPort: 22, Protocol: "tcp"},
+		{FromPort: controllerCfg.APIPort(), ToPort: controllerCfg.APIPort(), Protocol: "tcp"},
+	}
+	return errors.Trace(portsFirewaller.RestrictControllerPorts(ports, fw.controllerAdminCIDRs))
+}
+
+// supportsProtocol reports whether the provider can enforce ingress
+// rules for the given protocol. Providers that do not say otherwise are
+// taken to support every protocol.
+func (fw *Firewaller) supportsProtocol(protocol string) bool {
+	protocolFirewaller, ok := fw.environFirewaller.(environs.ProtocolFirewaller)
+	return !ok || protocolFirewaller.SupportsFirewallProtocol(protocol)
+}
+
+// warnUnsupportedProtocol logs a warning that the given port range,
+// opened by the given unit, is not opened as the provider cannot enforce
+// its protocol. Each port range is only warned about once for each unit,
+// rather than each time the unit's machine is flushed.
+func (fw *Firewaller) warnUnsupportedProtocol(unitTag names.UnitTag, portRange network.PortRange) {
+	key := unitTag.String() + " " + portRange.String()
+	if fw.unsupportedProtocolWarned.Contains(key) {
+		return
+	}
+	fw.unsupportedProtocolWarned.Add(key)
+	logger.Warningf(
+		"not opening %v for unit %q: the provider cannot enforce protocol %q",
+		portRange, unitTag.Id(), strings.ToLower(portRange.Protocol),
+	)
+}
+
 func (fw *Firewaller) updateForRemoteRelationIngress(appTag names.ApplicationTag, cidrs set.Strings) error {
 	logger.Debugf("finding egress rules for %v", appTag)
 	// Now create the rules for any remote relations of which the
@@ -827,7 +2176,9 @@ func (fw *Firewaller) flushGlobalPorts(rawOpen, rawClose []network.IngressRule)
 	}
 	// Open and close the ports.
 	if len(toOpen) > 0 {
-		if err := fw.environFirewaller.OpenPorts(toOpen); err != nil {
+		err := fw.environFirewaller.OpenPorts(toOpen)
+		fw.metrics.recordOpenPorts(len(toOpen), err)
+		if err != nil {
 			// TODO(mue) Add local retry logic.
 			return err
 		}
@@ -835,7 +2186,9 @@ func (fw *Firewaller) flushGlobalPorts(rawOpen, rawClose []network.IngressRule)
 		logger.Infof("opened port ranges %v in environment", toOpen)
 	}
 	if len(toClose) > 0 {
-		if err := fw.environFirewaller.ClosePorts(toClose); err != nil {
+		err := fw.environFirewaller.ClosePorts(toClose)
+		fw.metrics.recordClosePorts(len(toClose), err)
+		if err != nil {
 			// TODO(mue) Add local retry logic.
 			return err
 		}
@@ -873,7 +2226,9 @@ func (fw *Firewaller) flushInstancePorts(machined *machineData, toOpen, toClose
 	}
 	// Open and close the ports.
 	if len(toOpen) > 0 {
-		if err := instances[0].OpenPorts(machineId, toOpen); err != nil {
+		err := instances[0].OpenPorts(machineId, toOpen)
+		fw.metrics.recordOpenPorts(len(toOpen), err)
+		if err != nil {
 			// TODO(mue) Add local retry logic.
 			return err
 		}
@@ -881,7 +2236,9 @@ func (fw *Firewaller) flushInstancePorts(machined *machineData, toOpen, toClose
 		logger.Infof("opened port ranges %v on %q", toOpen, machined.tag)
 	}
 	if len(toClose) > 0 {
-		if err := instances[0].ClosePorts(machineId, toClose); err != nil {
+		err := instances[0].ClosePorts(machineId, toClose)
+		fw.metrics.recordClosePorts(len(toClose), err)
+		if err != nil {
 			// TODO(mue) Add local retry logic.
 			return err
 		}
@@ -920,15 +2277,19 @@ func (fw *Firewaller) forgetMachine(machined *machineData) error {
 	for _, unitd := range machined.unitds {
 		fw.forgetUnit(unitd)
 	}
+	machined.removed = true
+	delete(fw.pendingFlushes, machined.tag)
 	if err := fw.flushMachine(machined); err != nil {
 		return errors.Trace(err)
 	}
+	fw.removeOpenPortsTag(machined.tag)
 
 	// Unusually, it's fine to ignore this error, because we know the machined
 	// is being tracked in fw.catacomb. But we do still want to wait until the
 	// watch loop has stopped before we nuke the last data and return.
 	worker.Stop(machined)
 	delete(fw.machineds, machined.tag)
+	fw.updateTrackedMetrics()
 	logger.Debugf("stopped watching %q", machined.tag)
 	return nil
 }
@@ -955,6 +2316,7 @@ func (fw *Firewaller) forgetUnit(unitd *unitData) {
 	delete(fw.unitds, unitd.tag)
 	delete(machined.unitds, unitd.tag)
 	delete(applicationd.unitds, unitd.tag)
+	fw.updateTrackedMetrics()
 	logger.Debugf("stopped watching %q", unitd.tag)
 	if stoppedApplication {
 		applicationTag := applicationd.application.Tag()
@@ -963,6 +2325,18 @@ func (fw *Firewaller) forgetUnit(unitd *unitData) {
 	}
 }
 
+// updateTrackedMetrics records the number of machines and units currently
+// tracked. It must only be called from the loop goroutine.
+func (fw *Firewaller) updateTrackedMetrics() {
+	fw.metrics.setTracked(len(fw.machineds), len(fw.unitds))
+}
+
+// Report is part of the dependency.Reporter interface. It is safe to call
+// from any goroutine.
+func (fw *Firewaller) Report() map[string]interface{} {
+	return fw.metrics.report()
+}
+
 // Kill is part of the worker.Worker interface.
 func (fw *Firewaller) Kill() {
 	fw.catacomb.Kill(nil)
@@ -986,23 +2360,127 @@ type machineData struct {
 	tag          names.MachineTag
 	unitds       map[names.UnitTag]*unitData
 	ingressRules []network.IngressRule
-	// ports defined by units on this machine
+	// ports defined by units on this machine, on all of its subnets
 	definedPorts map[names.UnitTag]portRanges
+	// subnetPorts holds the ports defined by units on this machine
+	// for each subnet on which they are opened; definedPorts is
+	// their union.
+	subnetPorts map[names.SubnetTag]map[names.UnitTag]portRanges
+	// egressPorts holds the egress port ranges open on the machine's
+	// instance, which are tracked separately from its ingress rules.
+	egressPorts []network.PortRange
+	// egress ports defined by units on this machine
+	definedEgressPorts map[names.UnitTag]portRanges
+	// lockdown is true if all ports on the machine must be closed.
+	lockdown bool
+	// ruleGroupRules holds the firewall rule groups' rules, or the
+	// essential rules during a panic close, that are wanted on the
+	// machine.
+	ruleGroupRules []network.IngressRule
+	// removed is true once the machine is being forgotten.
+	removed bool
+	// instanceId is the machine's instance, once known.
+	instanceId instance.Id
+	// instanceStopped is true if the machine's instance was last
+	// seen not running, and so has all its ports closed.
+	instanceStopped bool
+	// starting is true while the machine's initial units are being
+	// started, during which flushes are deferred.
+	starting bool
+}
+
+// lockdownChange contains the changed firewall lockdown flag for one
+// specific machine.
+type lockdownChange struct {
+	machined *machineData
+	lockdown bool
 }
 
 func (md *machineData) machine() (*firewaller.Machine, error) {
 	return md.fw.firewallerApi.Machine(md.tag)
 }
 
-// watchLoop watches the machine for units added or removed.
-func (md *machineData) watchLoop(unitw watcher.StringsWatcher) error {
+// setSubnetPorts records the ports defined by units on the machine for
+// the given subnet, forgetting the subnet if there are none, and
+// recomputes definedPorts from those of all of its subnets. It reports
+// whether definedPorts has changed.
+func (md *machineData) setSubnetPorts(subnetTag names.SubnetTag, ports map[names.UnitTag]portRanges) bool {
+	if len(ports) == 0 {
+		delete(md.subnetPorts, subnetTag)
+	} else {
+		md.subnetPorts[subnetTag] = ports
+	}
+	definedPorts := make(map[names.UnitTag]portRanges)
+	for _, subnetPorts := range md.subnetPorts {
+		for unitTag, ranges := range subnetPorts {
+			unitRanges, ok := definedPorts[unitTag]
+			if !ok {
+				unitRanges = make(portRanges)
+				definedPorts[unitTag] = unitRanges
+			}
+			for portRange := range ranges {
+				unitRanges[portRange] = true
+			}
+		}
+	}
+	if unitPortsEqual(md.definedPorts, definedPorts) {
+		return false
+	}
+	md.definedPorts = definedPorts
+	return true
+}
+
+// watchLoop watches the machine for units added or removed and, if
+// machinew is not nil, for changes to its firewall lockdown flag.
+func (md *machineData) watchLoop(unitw watcher.StringsWatcher, machinew watcher.NotifyWatcher) error {
 	if err := md.catacomb.Add(unitw); err != nil {
 		return errors.Trace(err)
 	}
+	var machineChanges watcher.NotifyChannel
+	if machinew != nil {
+		if err := md.catacomb.Add(machinew); err != nil {
+			return errors.Trace(err)
+		}
+		machineChanges = machinew.Changes()
+	}
+	lockdown := md.lockdown
 	for {
 		select {
 		case <-md.catacomb.Dying():
 			return md.catacomb.ErrDying()
+		case _, ok := <-machineChanges:
+			if !ok {
+				return errors.New("machine watcher closed")
+			}
+			// A change of the machine may be a network interface
+			// being attached or detached, so have the subnets on
+			// which it is active checked.
+			select {
+			case <-md.catacomb.Dying():
+				return md.catacomb.ErrDying()
+			case md.fw.machineChange <- md:
+			}
+			m, err := md.machine()
+			if params.IsCodeNotFound(err) {
+				return nil
+			} else if err != nil {
+				return errors.Trace(err)
+			}
+			change, err := m.FirewallLockdown()
+			if params.IsCodeNotFound(err) {
+				return nil
+			} else if err != nil {
+				return errors.Trace(err)
+			}
+			if change == lockdown {
+				continue
+			}
+			lockdown = change
+			select {
+			case <-md.catacomb.Dying():
+				return md.catacomb.ErrDying()
+			case md.fw.lockdownChange <- &lockdownChange{md, change}:
+			}
 		case change, ok := <-unitw.Changes():
 			if !ok {
 				return errors.New("machine units watcher closed")
@@ -1035,10 +2513,31 @@ type unitData struct {
 	machined     *machineData
 }
 
-// exposedChange contains the changed exposed flag for one specific application.
+// exposure holds whether an application is exposed and, if it is
+// exposed only to some spaces, the spaces and the CIDRs of their
+// subnets.
+type exposure struct {
+	exposed bool
+	spaces  []string
+	cidrs   []string
+}
+
+// equal reports whether e and other are the same exposure, regardless
+// of the order of their spaces and CIDRs.
+func (e exposure) equal(other exposure) bool {
+	sameStrings := func(a, b []string) bool {
+		sa, sb := set.NewStrings(a...), set.NewStrings(b...)
+		return sa.Size() == sb.Size() && sa.Difference(sb).IsEmpty()
+	}
+	return e.exposed == other.exposed &&
+		sameStrings(e.spaces, other.spaces) &&
+		sameStrings(e.cidrs, other.cidrs)
+}
+
+// exposedChange contains the changed exposure for one specific application.
 type exposedChange struct {
 	applicationd *applicationData
-	exposed      bool
+	exposure     exposure
 }
 
 // applicationData holds application details and watches exposure changes.
@@ -1046,12 +2545,35 @@ type applicationData struct {
 	catacomb    catacomb.Catacomb
 	fw          *Firewaller
 	application *firewaller.Application
-	exposed     bool
+	exposure    exposure
 	unitds      map[names.UnitTag]*unitData
 }
 
-// watchLoop watches the application's exposed flag for changes.
-func (ad *applicationData) watchLoop(exposed bool) error {
+// getExposure returns the application's current exposure. Controllers
+// that cannot expose applications to spaces only ever expose them to
+// everywhere.
+func (ad *applicationData) getExposure() (exposure, error) {
+	exposed, err := ad.application.IsExposed()
+	if err != nil {
+		return exposure{}, errors.Trace(err)
+	}
+	if !exposed {
+		return exposure{}, nil
+	}
+	spaces, cidrs, err := ad.application.ExposedSpaces()
+	if errors.IsNotSupported(err) {
+		return exposure{exposed: true}, nil
+	} else if err != nil {
+		return exposure{}, errors.Trace(err)
+	}
+	return exposure{exposed: true, spaces: spaces, cidrs: cidrs}, nil
+}
+
+// watchLoop watches the application's exposure for changes. While the
+// application is exposed to some spaces, the subnets of the spaces are
+// also checked periodically, as they may change without the
+// application doing so.
+func (ad *applicationData) watchLoop(current exposure) error {
 	appWatcher, err := ad.application.Watch()
 	if err != nil {
 		if params.IsCodeNotFound(err) {
@@ -1063,6 +2585,10 @@ func (ad *applicationData) watchLoop(exposed bool) error {
 		return errors.Trace(err)
 	}
 	for {
+		var spacesPoll <-chan time.Time
+		if current.exposed && len(current.spaces) > 0 && ad.fw.exposedSpacesPollInterval > 0 {
+			spacesPoll = ad.fw.pollClock.After(ad.fw.exposedSpacesPollInterval)
+		}
 		select {
 		case <-ad.catacomb.Dying():
 			return ad.catacomb.ErrDying()
@@ -1076,20 +2602,23 @@ func (ad *applicationData) watchLoop(exposed bool) error {
 				}
 				return nil
 			}
-			change, err := ad.application.IsExposed()
-			if err != nil {
-				return errors.Trace(err)
-			}
-			if change == exposed {
-				continue
-			}
+		case <-spacesPoll:
+		}
+		change, err := ad.getExposure()
+		if params.IsCodeNotFound(err) {
+			return nil
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+		if change.equal(current) {
+			continue
+		}
 
-			exposed = change
-			select {
-			case <-ad.catacomb.Dying():
-				return ad.catacomb.ErrDying()
-			case ad.fw.exposedChange <- &exposedChange{ad, change}:
-			}
+		current = change
+		select {
+		case <-ad.catacomb.Dying():
+			return ad.catacomb.ErrDying()
+		case ad.fw.exposedChange <- &exposedChange{ad, change}:
 		}
 	}
 }
@@ -1123,6 +2652,52 @@ func parsePortsKey(change string) (machineTag names.MachineTag, subnetTag names.
 	return machineTag, subnetTag, nil
 }
 
+// filterAddressFamilies returns the given rules with the source CIDRs
+// of address families other than those given removed, so that no
+// ingress is ever opened from addresses of a denied family. Rules with
+// no source CIDRs allow ingress from anywhere in the allowed families.
+// Rules left with no source CIDRs are dropped. If no families are
+// given, none is denied and the rules are returned as they are.
+func filterAddressFamilies(rules []network.IngressRule, families []string) []network.IngressRule {
+	if len(families) == 0 {
+		return rules
+	}
+	allowed := set.NewStrings(families...)
+	var result []network.IngressRule
+	for _, rule := range rules {
+		ruleCidrs := rule.SourceCIDRs
+		if len(ruleCidrs) == 0 {
+			ruleCidrs = []string{"0.0.0.0/0", "::/0"}
+		}
+		var cidrs []string
+		for _, cidr := range ruleCidrs {
+			if allowed.Contains(cidrAddressFamily(cidr)) {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+		if len(cidrs) == 0 {
+			logger.Debugf("not opening %v: address family denied", rule)
+			continue
+		}
+		rule.SourceCIDRs = cidrs
+		result = append(result, rule)
+	}
+	return result
+}
+
+// cidrAddressFamily returns the address family, as named in the
+// ingress-address-families model config, of the given CIDR.
+func cidrAddressFamily(cidr string) string {
+	if ip, _, err := net.ParseCIDR(cidr); err == nil && ip.To4() == nil {
+		return config.IPv6AddressFamily
+	}
+	return config.IPv4AddressFamily
+}
+
+// diffRanges returns the ingress rules to open and close in order to move
+// from the current rules to the wanted rules. Port ranges are compared as a
+// whole, so a large contiguous range is only ever opened or closed as a
+// single rule, and an unchanged range produces no work at all.
 func diffRanges(currentRules, wantedRules []network.IngressRule) (toOpen, toClose []network.IngressRule) {
 	portCidrs := func(rules []network.IngressRule) map[network.PortRange]set.Strings {
 		result := make(map[network.PortRange]set.Strings)
@@ -4,7 +4,9 @@
 package firewaller_test
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"gopkg.in/macaroon.v1"
 
 	"github.com/juju/juju/api"
+	"github.com/juju/juju/api/base"
 	basetesting "github.com/juju/juju/api/base/testing"
 	"github.com/juju/juju/api/crossmodelrelations"
 	apifirewaller "github.com/juju/juju/api/firewaller"
@@ -35,6 +38,7 @@ import (
 	"github.com/juju/juju/status"
 	coretesting "github.com/juju/juju/testing"
 	"github.com/juju/juju/testing/factory"
+	"github.com/juju/juju/worker/dependency"
 	"github.com/juju/juju/worker/firewaller"
 )
 
@@ -189,21 +193,33 @@ func (s *InstanceModeSuite) TearDownTest(c *gc.C) {
 	s.firewallerBaseSuite.JujuConnSuite.TearDownTest(c)
 }
 
-// mockClock will panic if anything but After is called
+// mockClock will panic if anything but Now or After is called
 type mockClock struct {
 	clock.Clock
 	wait time.Duration
 	c    *gc.C
 }
 
+func (m *mockClock) Now() time.Time {
+	return time.Now()
+}
+
 func (m *mockClock) After(duration time.Duration) <-chan time.Time {
 	m.wait = duration
 	return time.After(time.Millisecond)
 }
 
 func (s *InstanceModeSuite) newFirewaller(c *gc.C) worker.Worker {
+	fw, err := firewaller.NewFirewaller(s.firewallerConfig(c))
+	c.Assert(err, jc.ErrorIsNil)
+	return fw
+}
+
+// firewallerConfig returns the configuration of an instance mode
+// firewaller, which may be modified before the firewaller is started.
+func (s *InstanceModeSuite) firewallerConfig(c *gc.C) firewaller.Config {
 	s.mockClock = &mockClock{c: c}
-	cfg := firewaller.Config{
+	return firewaller.Config{
 		ModelUUID:          s.State.ModelUUID(),
 		Mode:               config.FwInstance,
 		EnvironFirewaller:  s.Environ,
@@ -215,9 +231,6 @@ func (s *InstanceModeSuite) newFirewaller(c *gc.C) worker.Worker {
 		},
 		Clock: s.mockClock,
 	}
-	fw, err := firewaller.NewFirewaller(cfg)
-	c.Assert(err, jc.ErrorIsNil)
-	return fw
 }
 
 func (s *InstanceModeSuite) TestStartStop(c *gc.C) {
@@ -275,6 +288,373 @@ func (s *InstanceModeSuite) TestExposedApplication(c *gc.C) {
 	})
 }
 
+func (s *InstanceModeSuite) TestExposedApplicationProtocolWithoutPorts(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	// ESP has no ports, so is opened as a rule for the whole protocol.
+	err = u.OpenPorts("esp", 0, 0)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("udp", 500)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("esp", 0, 0, "0.0.0.0/0"),
+		network.MustNewIngressRule("udp", 500, 500, "0.0.0.0/0"),
+	})
+
+	err = u.ClosePorts("esp", 0, 0)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("udp", 500, 500, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestUnsupportedProtocolSkipped(c *gc.C) {
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironFirewaller = tcpUDPFirewaller{s.Environ}
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	// The provider cannot enforce GRE, so only the TCP and UDP
+	// ports are opened.
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPorts("gre", 0, 0)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("udp", 500)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("udp", 500, 500, "0.0.0.0/0"),
+	})
+	c.Assert(c.GetTestLog(), jc.Contains,
+		`not opening gre for unit "wordpress/0": the provider cannot enforce protocol "gre"`)
+}
+
+func (s *InstanceModeSuite) TestFirewallDecisionLogging(c *gc.C) {
+	err := s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-decision-logging": true,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	err = u.ClosePort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	prefix := "firewall decision for machine-" + m.Id() + ": "
+	log := c.GetTestLog()
+	c.Assert(log, jc.Contains, prefix+`not opening 80/tcp of wordpress/0: application "wordpress" is not exposed, and no relations require ingress`)
+	c.Assert(log, jc.Contains, prefix+`wanting 80/tcp of wordpress/0 from [0.0.0.0/0]: application "wordpress" is exposed`)
+	c.Assert(log, jc.Contains, prefix+"opening 80/tcp")
+	c.Assert(log, jc.Contains, prefix+"closing 80/tcp: no longer wanted")
+}
+
+func (s *InstanceModeSuite) TestFirewallFreezeWindow(c *gc.C) {
+	// The firewaller starts during a freeze window, which ends at noon.
+	err := s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-freeze-windows": "mon 09:00-12:00",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	clk := testing.NewClock(time.Date(2017, 6, 5, 10, 0, 0, 0, time.UTC))
+	cfg := s.firewallerConfig(c)
+	cfg.Clock = clk
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.ClosePort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// No changes are made during the freeze.
+	s.BackingState.StartSync()
+	time.Sleep(coretesting.ShortWait)
+	got, err := inst.IngressRules(m.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(got, gc.HasLen, 0)
+
+	// Once it ends, only the latest rules are opened.
+	err = clk.WaitAdvance(2*time.Hour, coretesting.LongWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+	c.Assert(c.GetTestLog(), jc.Contains, "firewall freeze window ended; applying deferred changes to 1 machines")
+}
+
+func (s *InstanceModeSuite) TestFirewallPanicCloseDuringFreezeWindow(c *gc.C) {
+	clk := testing.NewClock(time.Date(2017, 6, 5, 10, 0, 0, 0, time.UTC))
+	cfg := s.firewallerConfig(c)
+	cfg.Clock = clk
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	// A panic close is applied even during a freeze window.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-freeze-windows": "mon 09:00-12:00",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.State.UpdateControllerConfig(map[string]interface{}{
+		"firewall-panic-close":     true,
+		"firewall-essential-rules": "22/tcp",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "0.0.0.0/0"),
+	})
+}
+
+// tcpUDPFirewaller wraps an EnvironFirewaller whose provider can only
+// enforce ingress rules for TCP and UDP.
+type tcpUDPFirewaller struct {
+	firewaller.EnvironFirewaller
+}
+
+func (tcpUDPFirewaller) SupportsFirewallProtocol(protocol string) bool {
+	return protocol == "tcp" || protocol == "udp"
+}
+
+func (s *InstanceModeSuite) TestRestrictControllerPorts(c *gc.C) {
+	controllerCfg, err := s.State.ControllerConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	apiPort := controllerCfg.APIPort()
+	envFirewaller := &controllerPortsFirewaller{
+		EnvironFirewaller: s.Environ,
+		restricted:        make(chan []string, 1),
+	}
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironFirewaller = envFirewaller
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	// The ports are opened to all sources when the worker starts, as
+	// no controller admin CIDRs are set.
+	c.Assert(envFirewaller.nextRestriction(c), gc.HasLen, 0)
+	c.Assert(envFirewaller.ports, jc.DeepEquals, []network.PortRange{
+		{FromPort: 22, ToPort: 22, Protocol: "tcp"},
+		{FromPort: apiPort, ToPort: apiPort, Protocol: "tcp"},
+	})
+
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"controller-admin-cidrs": "10.0.0.0/8, 192.168.1.0/24",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(envFirewaller.nextRestriction(c), jc.DeepEquals, []string{"10.0.0.0/8", "192.168.1.0/24"})
+
+	err = s.State.UpdateModelConfig(nil, []string{"controller-admin-cidrs"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(envFirewaller.nextRestriction(c), gc.HasLen, 0)
+}
+
+// controllerPortsFirewaller wraps an EnvironFirewaller whose provider
+// can restrict the sources of the controller's ports.
+type controllerPortsFirewaller struct {
+	firewaller.EnvironFirewaller
+
+	ports      []network.PortRange
+	restricted chan []string
+}
+
+func (f *controllerPortsFirewaller) RestrictControllerPorts(ports []network.PortRange, sourceCIDRs []string) error {
+	f.ports = ports
+	f.restricted <- sourceCIDRs
+	return nil
+}
+
+func (f *controllerPortsFirewaller) nextRestriction(c *gc.C) []string {
+	select {
+	case cidrs := <-f.restricted:
+		return cidrs
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for controller ports to be restricted")
+	}
+	return nil
+}
+
+func (s *InstanceModeSuite) TestOpenPortsTag(c *gc.C) {
+	err := s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-open-ports-tag": "juju-open-ports",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	envFirewaller := &taggingFirewaller{
+		EnvironFirewaller: s.Environ,
+		tagged:            make(chan string, 10),
+	}
+	clk := testing.NewClock(time.Now())
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironFirewaller = envFirewaller
+	cfg.Clock = clk
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 443)
+	c.Assert(err, jc.ErrorIsNil)
+	envFirewaller.waitForTag(c, clk, fmt.Sprintf("%s juju-open-ports=80/tcp,443/tcp", inst.Id()))
+
+	// The tag is removed once no ports are open.
+	err = u.EnsureDead()
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.Remove()
+	c.Assert(err, jc.ErrorIsNil)
+	envFirewaller.waitForTag(c, clk, fmt.Sprintf("%s -juju-open-ports", inst.Id()))
+}
+
+// taggingFirewaller wraps an EnvironFirewaller whose provider can tag
+// instances, reporting the tags written.
+type taggingFirewaller struct {
+	firewaller.EnvironFirewaller
+
+	tagged chan string
+}
+
+func (f *taggingFirewaller) TagInstance(id instance.Id, tags map[string]string) error {
+	for name, value := range tags {
+		f.tagged <- fmt.Sprintf("%s %s=%s", id, name, value)
+	}
+	return nil
+}
+
+func (f *taggingFirewaller) UntagInstance(id instance.Id, names []string) error {
+	for _, name := range names {
+		f.tagged <- fmt.Sprintf("%s -%s", id, name)
+	}
+	return nil
+}
+
+// waitForTag waits for the given tag to be written, advancing the clock
+// so that writes deferred to limit their rate are made.
+func (f *taggingFirewaller) waitForTag(c *gc.C, clk *testing.Clock, want string) {
+	timeout := time.After(coretesting.LongWait)
+	for {
+		select {
+		case got := <-f.tagged:
+			if got == want {
+				return
+			}
+		case <-time.After(coretesting.ShortWait):
+			clk.Advance(time.Minute)
+		case <-timeout:
+			c.Fatalf("timed out waiting for tag %q to be written", want)
+		}
+	}
+}
+
+func (s *InstanceModeSuite) TestExposedApplicationLargePortRange(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	// A 2768 port range must be reflected as a single provider rule.
+	err = u.OpenPorts("tcp", 30000, 32767)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 30000, 32767, "0.0.0.0/0"),
+	})
+
+	err = u.ClosePorts("tcp", 30000, 32767)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), nil)
+}
+
+func (s *InstanceModeSuite) TestReport(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	err = u.ClosePort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// The counters are updated just after the provider call returns,
+	// so wait for the close to be counted.
+	var report map[string]interface{}
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		report = fw.(dependency.Reporter).Report()
+		if report[firewaller.KeyPortsClosed] == int64(1) {
+			break
+		}
+	}
+	c.Assert(report[firewaller.KeyPortsOpened], gc.Equals, int64(1))
+	c.Assert(report[firewaller.KeyPortsOpenFailures], gc.Equals, int64(0))
+	c.Assert(report[firewaller.KeyPortsClosed], gc.Equals, int64(1))
+	c.Assert(report[firewaller.KeyPortsCloseFailures], gc.Equals, int64(0))
+	c.Assert(report[firewaller.KeyUnits], gc.Equals, int64(1))
+}
+
 func (s *InstanceModeSuite) TestMultipleExposedApplications(c *gc.C) {
 	fw := s.newFirewaller(c)
 	defer statetesting.AssertKillAndWait(c, fw)
@@ -335,53 +715,1018 @@ func (s *InstanceModeSuite) TestMachineWithoutInstanceId(c *gc.C) {
 	inst2 := s.startInstance(c, m2)
 	err = u2.OpenPort("tcp", 80)
 	c.Assert(err, jc.ErrorIsNil)
-	s.assertPorts(c, inst2, m2.Id(), []network.IngressRule{
-		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	s.assertPorts(c, inst2, m2.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	inst1 := s.startInstance(c, m1)
+	err = u1.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestMultipleUnits(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	u1, m1 := s.addUnit(c, app)
+	inst1 := s.startInstance(c, m1)
+	err = u1.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	u2, m2 := s.addUnit(c, app)
+	inst2 := s.startInstance(c, m2)
+	err = u2.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	s.assertPorts(c, inst2, m2.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	err = u1.ClosePort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u2.ClosePort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst1, m1.Id(), nil)
+	s.assertPorts(c, inst2, m2.Id(), nil)
+}
+
+func (s *InstanceModeSuite) TestStartWithState(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Nothing open without firewaller.
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// Starting the firewaller opens the ports.
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *InstanceModeSuite) TestStartWithPartialState(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Starting the firewaller, no open ports.
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// Complete steps to open port.
+	u, err := app.AddUnit(state.AddUnitParams{})
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.AssignToMachine(m)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestRestartWithPartiallyAppliedState(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+	statetesting.AssertKillAndWait(c, fw)
+
+	// While the firewaller is down, a port is closed in the model
+	// and a stale rule is left on the instance.
+	err = u.ClosePort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	err = inst.OpenPorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 9000, 9000, "0.0.0.0/0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Restarting the firewaller closes the ports no longer wanted,
+	// without reopening those that are still open.
+	insts := &recordingInstances{EnvironInstances: s.Environ}
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironInstances = insts
+	fw, err = firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	c.Assert(insts.openedRules(), gc.HasLen, 0)
+}
+
+func (s *InstanceModeSuite) TestStartFetchesInstanceOnce(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	// The port is already open, so starting the machine opens and
+	// closes nothing.
+	err = inst.OpenPorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	insts := &recordingInstances{EnvironInstances: s.Environ}
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironInstances = insts
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		if fw.(dependency.Reporter).Report()[firewaller.KeyMachines] == int64(1) {
+			break
+		}
+	}
+	// The initial reconcile, which follows the start of the machine,
+	// has run by the time the worker stops.
+	statetesting.AssertKillAndWait(c, fw)
+
+	// Seeding the machine's rules and reconciling its instance share
+	// the instance fetched when the machine was started.
+	c.Assert(insts.instanceCalls(), gc.Equals, 1)
+	c.Assert(insts.openPortsCalls(), gc.Equals, 0)
+}
+
+func (s *InstanceModeSuite) TestFlushDelayCoalescesUnitChanges(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	const flushDelay = time.Second
+	insts := &recordingInstances{EnvironInstances: s.Environ}
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironInstances = insts
+	cfg.FlushDelay = flushDelay
+	cfg.Clock = clock.WallClock
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	// Add a burst of units to the machine, each opening a port.
+	start := time.Now()
+	var expected []network.IngressRule
+	for i := 0; i < 50; i++ {
+		u, err := app.AddUnit(state.AddUnitParams{})
+		c.Assert(err, jc.ErrorIsNil)
+		err = u.AssignToMachine(m)
+		c.Assert(err, jc.ErrorIsNil)
+		err = u.OpenPort("tcp", 8000+i)
+		c.Assert(err, jc.ErrorIsNil)
+		expected = append(expected, network.MustNewIngressRule("tcp", 8000+i, 8000+i, "0.0.0.0/0"))
+	}
+	s.assertPorts(c, inst, m.Id(), expected)
+
+	// The machine is flushed at most once per delay, rather than
+	// once for each unit and port.
+	maxCalls := int(time.Since(start)/flushDelay) + 1
+	c.Assert(insts.openPortsCalls() <= maxCalls, jc.IsTrue, gc.Commentf(
+		"%d calls to OpenPorts in %v", insts.openPortsCalls(), time.Since(start),
+	))
+	c.Assert(insts.openedRules(), gc.HasLen, 50)
+}
+
+func (s *InstanceModeSuite) TestStartWithUnexposedApplication(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	u, err := app.AddUnit(state.AddUnitParams{})
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.AssignToMachine(m)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Starting the firewaller, no open ports.
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// Expose service.
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestSetClearExposedApplication(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err := u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Not exposed service, so no open port.
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// SeExposed opens the ports.
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+
+	// ClearExposed closes the ports again.
+	err = app.ClearExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), nil)
+}
+
+func (s *InstanceModeSuite) TestExposedSpaces(c *gc.C) {
+	_, err := s.State.AddSubnet(state.SubnetInfo{CIDR: "10.0.1.0/24"})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = s.State.AddSpace("dmz", "", []string{"10.0.1.0/24"}, true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	cfg := s.firewallerConfig(c)
+	cfg.ExposedSpacesPollInterval = time.Second
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Exposing the application to a space opens its ports only to
+	// the space's subnets.
+	err = app.SetExposedSpaces([]string{"dmz"})
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.1.0/24"),
+	})
+
+	// Subnets added to the space are picked up.
+	_, err = s.State.AddSubnet(state.SubnetInfo{CIDR: "10.0.2.0/24", SpaceName: "dmz"})
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.1.0/24", "10.0.2.0/24"),
+	})
+
+	// Exposing the application to everywhere replaces the rule.
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	err = app.ClearExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), nil)
+}
+
+func (s *InstanceModeSuite) TestIngressAddressFamilies(c *gc.C) {
+	err := s.State.UpdateModelConfig(map[string]interface{}{
+		"ingress-address-families": "ipv6",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Only IPv6 ingress is opened.
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "::/0"),
+	})
+
+	// Denying IPv6 and allowing IPv4 swaps the rules over.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"ingress-address-families": "ipv4",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestIngressAddressFamiliesUnset(c *gc.C) {
+	// A model without ingress-address-families denies no address
+	// family, so IPv6 ingress is opened as it always has been.
+	err := s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "ipv6=443/tcp@::/0",
+		"enabled-firewall-rule-groups": "ipv6",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 443, 443, "::/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestSecondaryInterface(c *gc.C) {
+	_, err := s.State.AddSubnet(state.SubnetInfo{CIDR: "10.0.1.0/24"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	// Attaching a second interface, on which the unit opens more
+	// ports, applies them alongside those of the first.
+	err = m.SetProviderAddresses(network.NewScopedAddress("10.0.1.5", network.ScopeCloudLocal))
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenPortsOnSubnet("10.0.1.0/24", "tcp", 8080, 8081)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8081, "0.0.0.0/0"),
+	})
+
+	// Detaching it removes them again.
+	err = u.ClosePortsOnSubnet("10.0.1.0/24", "tcp", 8080, 8081)
+	c.Assert(err, jc.ErrorIsNil)
+	err = m.SetProviderAddresses()
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestFirewallLockdown(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	// Locking down the machine closes all its ports.
+	err = m.SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// Ports opened while locked down stay closed.
+	err = u.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// Lifting the lockdown restores the opened ports.
+	err = m.SetFirewallLockdown(false)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestStartWithFirewallLockdown(c *gc.C) {
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = inst.OpenPorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = m.SetFirewallLockdown(true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// Starting the firewaller closes the ports on the locked down machine.
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	s.assertPorts(c, inst, m.Id(), nil)
+}
+
+// stoppableInstances wraps an EnvironInstances so that chosen
+// instances report that they have been stopped.
+type stoppableInstances struct {
+	firewaller.EnvironInstances
+
+	mu      sync.Mutex
+	stopped map[instance.Id]bool
+}
+
+func (s *stoppableInstances) setStopped(id instance.Id, stopped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped[id] = stopped
+}
+
+func (s *stoppableInstances) Instances(ids []instance.Id) ([]instance.Instance, error) {
+	insts, err := s.EnvironInstances.Instances(ids)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, inst := range insts {
+		if inst != nil && s.stopped[inst.Id()] {
+			insts[i] = stoppedInstance{inst}
+		}
+	}
+	return insts, err
+}
+
+type stoppedInstance struct {
+	instance.Instance
+}
+
+func (stoppedInstance) Status() instance.InstanceStatus {
+	return instance.InstanceStatus{Status: status.Stopped, Message: "stopped"}
+}
+
+// recordingInstances wraps an EnvironInstances so that the rules
+// opened on its instances are recorded.
+type recordingInstances struct {
+	firewaller.EnvironInstances
+
+	mu             sync.Mutex
+	opened         []network.IngressRule
+	openCalls      int
+	instancesCalls int
+}
+
+func (r *recordingInstances) Instances(ids []instance.Id) ([]instance.Instance, error) {
+	r.mu.Lock()
+	r.instancesCalls++
+	r.mu.Unlock()
+	insts, err := r.EnvironInstances.Instances(ids)
+	for i, inst := range insts {
+		if inst != nil {
+			insts[i] = recordingInstance{inst, r}
+		}
+	}
+	return insts, err
+}
+
+func (r *recordingInstances) openedRules() []network.IngressRule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.opened
+}
+
+func (r *recordingInstances) openPortsCalls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.openCalls
+}
+
+func (r *recordingInstances) instanceCalls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.instancesCalls
+}
+
+type recordingInstance struct {
+	instance.Instance
+	r *recordingInstances
+}
+
+func (i recordingInstance) OpenPorts(machineId string, rules []network.IngressRule) error {
+	i.r.mu.Lock()
+	i.r.opened = append(i.r.opened, rules...)
+	i.r.openCalls++
+	i.r.mu.Unlock()
+	return i.Instance.OpenPorts(machineId, rules)
+}
+
+// egressInstances wraps an EnvironInstances so that its instances
+// support egress rules, which are held in memory.
+type egressInstances struct {
+	firewaller.EnvironInstances
+
+	mu    sync.Mutex
+	ports map[string][]network.PortRange
+}
+
+func (e *egressInstances) Instances(ids []instance.Id) ([]instance.Instance, error) {
+	insts, err := e.EnvironInstances.Instances(ids)
+	for i, inst := range insts {
+		if inst != nil {
+			insts[i] = egressInstance{inst, e}
+		}
+	}
+	return insts, err
+}
+
+func (e *egressInstances) egressPorts(machineId string) []network.PortRange {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ports := append([]network.PortRange(nil), e.ports[machineId]...)
+	network.SortPortRanges(ports)
+	return ports
+}
+
+type egressInstance struct {
+	instance.Instance
+	e *egressInstances
+}
+
+func (i egressInstance) OpenEgressPorts(machineId string, ports []network.PortRange) error {
+	i.e.mu.Lock()
+	defer i.e.mu.Unlock()
+	i.e.ports[machineId] = append(i.e.ports[machineId], ports...)
+	return nil
+}
+
+func (i egressInstance) CloseEgressPorts(machineId string, ports []network.PortRange) error {
+	i.e.mu.Lock()
+	defer i.e.mu.Unlock()
+	var remaining []network.PortRange
+	for _, open := range i.e.ports[machineId] {
+		closed := false
+		for _, portRange := range ports {
+			if open == portRange {
+				closed = true
+			}
+		}
+		if !closed {
+			remaining = append(remaining, open)
+		}
+	}
+	i.e.ports[machineId] = remaining
+	return nil
+}
+
+func (i egressInstance) EgressPorts(machineId string) ([]network.PortRange, error) {
+	return i.e.egressPorts(machineId), nil
+}
+
+func (s *InstanceModeSuite) assertEgressPorts(c *gc.C, insts *egressInstances, machineId string, expected []network.PortRange) {
+	s.BackingState.StartSync()
+	start := time.Now()
+	for {
+		got := insts.egressPorts(machineId)
+		if reflect.DeepEqual(got, expected) {
+			return
+		}
+		if time.Since(start) > coretesting.LongWait {
+			c.Fatalf("timed out: expected %v; got %v", expected, got)
+		}
+		time.Sleep(coretesting.ShortWait)
+	}
+}
+
+func (s *InstanceModeSuite) TestEgressPorts(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	u, err := app.AddUnit(state.AddUnitParams{})
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.AssignToMachine(m)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// A stale egress rule left on the instance is closed once the
+	// firewaller starts.
+	stale := network.PortRange{Protocol: "tcp", FromPort: 8080, ToPort: 8080}
+	insts := &egressInstances{
+		EnvironInstances: s.Environ,
+		ports:            map[string][]network.PortRange{m.Id(): {stale}},
+	}
+	fw := s.newFirewallerWithInstances(c, insts)
+	defer statetesting.AssertKillAndWait(c, fw)
+	s.assertEgressPorts(c, insts, m.Id(), nil)
+
+	err = u.OpenEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u.OpenEgressPorts("udp", 53, 53)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEgressPorts(c, insts, m.Id(), []network.PortRange{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443},
+		{Protocol: "udp", FromPort: 53, ToPort: 53},
+	})
+
+	// Egress ports do not depend on the application being exposed,
+	// and are not opened as ingress rules.
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	err = u.CloseEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEgressPorts(c, insts, m.Id(), []network.PortRange{
+		{Protocol: "udp", FromPort: 53, ToPort: 53},
+	})
+
+	// Ingress rules are reconciled independently.
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	s.assertEgressPorts(c, insts, m.Id(), []network.PortRange{
+		{Protocol: "udp", FromPort: 53, ToPort: 53},
+	})
+}
+
+func (s *InstanceModeSuite) newFirewallerWithInstances(c *gc.C, insts firewaller.EnvironInstances) worker.Worker {
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironInstances = insts
+	cfg.InstanceStatusPollInterval = time.Second
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	return fw
+}
+
+func (s *InstanceModeSuite) TestFirewallRuleGroups(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m1 := s.addUnit(c, app)
+	inst1 := s.startInstance(c, m1)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	m2, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst2 := s.startInstance(c, m2)
+
+	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	// Defining rule groups opens nothing until they are enabled.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups": "monitoring=9100/tcp@10.0.0.0/8 syslog=514/udp",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst2, m2.Id(), nil)
+
+	// Enabling a group opens its rules on every machine.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"enabled-firewall-rule-groups": "monitoring",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+	s.assertPorts(c, inst2, m2.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+
+	// Groups are toggled together.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"enabled-firewall-rule-groups": "syslog",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("udp", 514, 514, "0.0.0.0/0"),
+	})
+	s.assertPorts(c, inst2, m2.Id(), []network.IngressRule{
+		network.MustNewIngressRule("udp", 514, 514, "0.0.0.0/0"),
+	})
+
+	// A machine provisioned while a group is enabled gets its rules.
+	m3, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst3 := s.startInstance(c, m3)
+	s.assertPorts(c, inst3, m3.Id(), []network.IngressRule{
+		network.MustNewIngressRule("udp", 514, 514, "0.0.0.0/0"),
+	})
+
+	// Disabling the groups closes their rules, leaving the units' ports.
+	err = s.State.UpdateModelConfig(nil, []string{"enabled-firewall-rule-groups"})
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+	s.assertPorts(c, inst2, m2.Id(), nil)
+	s.assertPorts(c, inst3, m3.Id(), nil)
+}
+
+func (s *InstanceModeSuite) TestFirewallPanicClose(c *gc.C) {
+	err := s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "monitoring=9100/tcp@10.0.0.0/8",
+		"enabled-firewall-rule-groups": "monitoring",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.State.UpdateControllerConfig(map[string]interface{}{
+		"firewall-essential-rules": "22/tcp@10.0.0.0/8",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+
+	// A panic close set for the controller closes everything but the
+	// essential rules.
+	err = s.State.UpdateControllerConfig(map[string]interface{}{
+		"firewall-panic-close": true,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "10.0.0.0/8"),
+	})
+
+	// Ports opened by units in the meantime stay closed.
+	err = u.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "10.0.0.0/8"),
+	})
+
+	// Clearing it restores the units' ports and the rule groups.
+	err = s.State.UpdateControllerConfig(nil, []string{"firewall-panic-close"})
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+}
+
+func (s *InstanceModeSuite) TestFirewallPanicCloseModelOverride(c *gc.C) {
+	err := s.State.UpdateControllerConfig(map[string]interface{}{
+		"firewall-panic-close":     true,
+		"firewall-essential-rules": "22/tcp",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err = app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The controller's panic close applies to the model.
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "0.0.0.0/0"),
+	})
+
+	// The model's essential rules override the controller's.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-essential-rules-override": "443/tcp",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0"),
+	})
+
+	// The model may opt out of the controller's panic close.
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-panic-close-override": false,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	// Once the controller's panic close is cleared, the model may
+	// still set its own.
+	err = s.State.UpdateControllerConfig(nil, []string{"firewall-panic-close"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-panic-close-override": true,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0"),
+	})
+}
+
+func (s *InstanceModeSuite) TestStartWithFirewallRuleGroups(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+	err = inst.OpenPorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "monitoring=9100/tcp@10.0.0.0/8",
+		"enabled-firewall-rule-groups": "monitoring",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The stray port is closed, and the group's rules opened.
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+}
+
+func (s *InstanceModeSuite) TestFirewallRuleGroupExpiry(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+	expiry := time.Now().Add(3 * time.Second).UTC().Format(time.RFC3339)
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "monitoring=9100/tcp@10.0.0.0/8 ssh=22/tcp",
+		"enabled-firewall-rule-groups": "monitoring,ssh@" + expiry,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
 	})
 
-	inst1 := s.startInstance(c, m1)
-	err = u1.OpenPort("tcp", 8080)
+	// The time-bounded group's rules are closed once it expires,
+	// leaving those of the other group.
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+
+	// Enabling the group again with a later expiry reopens them.
+	expiry = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"enabled-firewall-rule-groups": "monitoring,ssh@" + expiry,
+	}, nil)
 	c.Assert(err, jc.ErrorIsNil)
-	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
-		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
 	})
 }
 
-func (s *InstanceModeSuite) TestMultipleUnits(c *gc.C) {
+func (s *InstanceModeSuite) TestStartWithExpiredFirewallRuleGroup(c *gc.C) {
+	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, jc.ErrorIsNil)
+	inst := s.startInstance(c, m)
+	err = inst.OpenPorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 22, 22, "0.0.0.0/0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	expiry := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "ssh=22/tcp",
+		"enabled-firewall-rule-groups": "ssh@" + expiry,
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The expiry is honoured across restarts, so the rules of a group
+	// that expired while the firewaller was not running are closed.
 	fw := s.newFirewaller(c)
 	defer statetesting.AssertKillAndWait(c, fw)
+	s.assertPorts(c, inst, m.Id(), nil)
+}
+
+func (s *InstanceModeSuite) TestInstanceStopped(c *gc.C) {
+	insts := &stoppableInstances{
+		EnvironInstances: s.Environ,
+		stopped:          make(map[instance.Id]bool),
+	}
+	fw := s.newFirewallerWithInstances(c, insts)
+	defer statetesting.AssertKillAndWait(c, fw)
 
 	app := s.AddTestingApplication(c, "wordpress", s.charm)
 	err := app.SetExposed()
 	c.Assert(err, jc.ErrorIsNil)
 
-	u1, m1 := s.addUnit(c, app)
-	inst1 := s.startInstance(c, m1)
-	err = u1.OpenPort("tcp", 80)
-	c.Assert(err, jc.ErrorIsNil)
-
-	u2, m2 := s.addUnit(c, app)
-	inst2 := s.startInstance(c, m2)
-	err = u2.OpenPort("tcp", 80)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
 	c.Assert(err, jc.ErrorIsNil)
 
-	s.assertPorts(c, inst1, m1.Id(), []network.IngressRule{
-		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
-	})
-	s.assertPorts(c, inst2, m2.Id(), []network.IngressRule{
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
 		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
 	})
 
-	err = u1.ClosePort("tcp", 80)
-	c.Assert(err, jc.ErrorIsNil)
-	err = u2.ClosePort("tcp", 80)
+	// Stopping the instance closes all its ports.
+	insts.setStopped(inst.Id(), true)
+	s.assertPorts(c, inst, m.Id(), nil)
+
+	// Ports opened while the instance is stopped stay closed.
+	err = u.OpenPort("tcp", 8080)
 	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), nil)
 
-	s.assertPorts(c, inst1, m1.Id(), nil)
-	s.assertPorts(c, inst2, m2.Id(), nil)
+	// Running the instance again restores the opened ports.
+	insts.setStopped(inst.Id(), false)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
 }
 
-func (s *InstanceModeSuite) TestStartWithState(c *gc.C) {
+func (s *InstanceModeSuite) TestStartWithInstanceStopped(c *gc.C) {
 	app := s.AddTestingApplication(c, "wordpress", s.charm)
 	err := app.SetExposed()
 	c.Assert(err, jc.ErrorIsNil)
@@ -390,110 +1735,211 @@ func (s *InstanceModeSuite) TestStartWithState(c *gc.C) {
 
 	err = u.OpenPort("tcp", 80)
 	c.Assert(err, jc.ErrorIsNil)
-	err = u.OpenPort("tcp", 8080)
+	err = inst.OpenPorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
 	c.Assert(err, jc.ErrorIsNil)
 
-	// Nothing open without firewaller.
-	s.assertPorts(c, inst, m.Id(), nil)
+	insts := &stoppableInstances{
+		EnvironInstances: s.Environ,
+		stopped:          map[instance.Id]bool{inst.Id(): true},
+	}
 
-	// Starting the firewaller opens the ports.
-	fw := s.newFirewaller(c)
+	// Starting the firewaller closes the ports on the stopped instance.
+	fw := s.newFirewallerWithInstances(c, insts)
 	defer statetesting.AssertKillAndWait(c, fw)
+	s.assertPorts(c, inst, m.Id(), nil)
 
+	insts.setStopped(inst.Id(), false)
 	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
 		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
-		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
 	})
-
-	err = app.SetExposed()
-	c.Assert(err, jc.ErrorIsNil)
 }
 
-func (s *InstanceModeSuite) TestStartWithPartialState(c *gc.C) {
-	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
-	c.Assert(err, jc.ErrorIsNil)
-	inst := s.startInstance(c, m)
-
-	app := s.AddTestingApplication(c, "wordpress", s.charm)
-	err = app.SetExposed()
-	c.Assert(err, jc.ErrorIsNil)
-
-	// Starting the firewaller, no open ports.
-	fw := s.newFirewaller(c)
+func (s *InstanceModeSuite) TestReapplyClearedRules(c *gc.C) {
+	fw := s.newFirewallerWithInstances(c, s.Environ)
 	defer statetesting.AssertKillAndWait(c, fw)
 
-	s.assertPorts(c, inst, m.Id(), nil)
-
-	// Complete steps to open port.
-	u, err := app.AddUnit(state.AddUnitParams{})
-	c.Assert(err, jc.ErrorIsNil)
-	err = u.AssignToMachine(m)
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
 	c.Assert(err, jc.ErrorIsNil)
-	err = u.OpenPort("tcp", 80)
+	u, m := s.addUnit(c, app)
+	inst := s.startInstance(c, m)
+	err = u.OpenPorts("tcp", 80, 81)
 	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 81, "0.0.0.0/0"),
+	})
 
+	// Clearing the instance's rules behind juju's back, as when its
+	// security group is recreated, gets them reapplied.
+	err = inst.ClosePorts(m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 81, "0.0.0.0/0"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
 	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
-		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 80, 81, "0.0.0.0/0"),
 	})
 }
 
-func (s *InstanceModeSuite) TestStartWithUnexposedApplication(c *gc.C) {
-	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
-	c.Assert(err, jc.ErrorIsNil)
-	inst := s.startInstance(c, m)
+// hidingFirewallerAPI wraps a FirewallerAPI so that chosen units
+// appear not to exist, and so are never registered by the firewaller.
+type hidingFirewallerAPI struct {
+	firewaller.FirewallerAPI
+
+	mu     sync.Mutex
+	hidden map[names.UnitTag]bool
+}
+
+func (h *hidingFirewallerAPI) Unit(tag names.UnitTag) (*apifirewaller.Unit, error) {
+	h.mu.Lock()
+	hidden := h.hidden[tag]
+	h.mu.Unlock()
+	if hidden {
+		return nil, &params.Error{Code: params.CodeNotFound, Message: "unit not found"}
+	}
+	return h.FirewallerAPI.Unit(tag)
+}
 
+func (s *InstanceModeSuite) TestOpenedPortsForUnknownUnit(c *gc.C) {
 	app := s.AddTestingApplication(c, "wordpress", s.charm)
-	u, err := app.AddUnit(state.AddUnitParams{})
-	c.Assert(err, jc.ErrorIsNil)
-	err = u.AssignToMachine(m)
+	err := app.SetExposed()
 	c.Assert(err, jc.ErrorIsNil)
-	err = u.OpenPort("tcp", 80)
+	u1, m := s.addUnit(c, app)
+	u2, err := app.AddUnit(state.AddUnitParams{})
 	c.Assert(err, jc.ErrorIsNil)
 
-	// Starting the firewaller, no open ports.
-	fw := s.newFirewaller(c)
+	cfg := s.firewallerConfig(c)
+	cfg.FirewallerAPI = &hidingFirewallerAPI{
+		FirewallerAPI: s.firewaller,
+		hidden:        map[names.UnitTag]bool{u2.UnitTag(): true},
+	}
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
 	defer statetesting.AssertKillAndWait(c, fw)
 
-	s.assertPorts(c, inst, m.Id(), nil)
+	inst := s.startInstance(c, m)
+	err = u1.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
 
-	// Expose service.
-	err = app.SetExposed()
+	// The machine's opened ports now include those of a unit the
+	// firewaller does not know about; the known unit's ports are
+	// still applied.
+	err = u2.AssignToMachine(m)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u2.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u1.OpenPort("tcp", 8081)
 	c.Assert(err, jc.ErrorIsNil)
 	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
 		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8081, 8081, "0.0.0.0/0"),
 	})
 }
 
-func (s *InstanceModeSuite) TestSetClearExposedApplication(c *gc.C) {
-	fw := s.newFirewaller(c)
-	defer statetesting.AssertKillAndWait(c, fw)
+// countingAPICaller wraps an APICaller, counting the requests made
+// to the Firewaller facade.
+type countingAPICaller struct {
+	base.APICaller
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (a *countingAPICaller) APICall(objType string, version int, id, request string, args, response interface{}) error {
+	if objType == "Firewaller" {
+		a.mu.Lock()
+		a.calls[request]++
+		a.mu.Unlock()
+	}
+	return a.APICaller.APICall(objType, version, id, request, args, response)
+}
+
+func (a *countingAPICaller) count(request string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls[request]
+}
 
+// startManyMachines starts a firewaller, with the opened ports
+// snapshot enabled or not, in a model whose exposed application has
+// a unit with opened ports on each of many machines. It waits for the
+// ports of every machine to be opened, and returns the caller through
+// which the firewaller made its API calls.
+func (s *InstanceModeSuite) startManyMachines(c *gc.C, snapshot bool) *countingAPICaller {
+	const numMachines = 20
 	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	machines := make([]*state.Machine, numMachines)
+	insts := make([]instance.Instance, numMachines)
+	for i := range machines {
+		var u *state.Unit
+		u, machines[i] = s.addUnit(c, app)
+		insts[i] = s.startInstance(c, machines[i])
+		err = u.OpenPort("tcp", 80)
+		c.Assert(err, jc.ErrorIsNil)
+		err = u.OpenPort("tcp", 8000+i)
+		c.Assert(err, jc.ErrorIsNil)
+	}
 
-	u, m := s.addUnit(c, app)
-	inst := s.startInstance(c, m)
-	err := u.OpenPort("tcp", 80)
+	caller := &countingAPICaller{APICaller: s.st, calls: make(map[string]int)}
+	client, err := apifirewaller.NewClient(caller)
 	c.Assert(err, jc.ErrorIsNil)
-	err = u.OpenPort("tcp", 8080)
+	cfg := s.firewallerConfig(c)
+	cfg.FirewallerAPI = client
+	cfg.OpenedPortsSnapshot = snapshot
+	start := time.Now()
+	fw, err := firewaller.NewFirewaller(cfg)
 	c.Assert(err, jc.ErrorIsNil)
+	s.AddCleanup(func(c *gc.C) { statetesting.AssertKillAndWait(c, fw) })
 
-	// Not exposed service, so no open port.
-	s.assertPorts(c, inst, m.Id(), nil)
+	for i, m := range machines {
+		s.assertPorts(c, insts[i], m.Id(), []network.IngressRule{
+			network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+			network.MustNewIngressRule("tcp", 8000+i, 8000+i, "0.0.0.0/0"),
+		})
+	}
+	c.Logf("opened ports of %d machines in %v (snapshot %v; %d GetMachinePorts, %d GetMachineActiveSubnets calls)",
+		numMachines, time.Since(start), snapshot,
+		caller.count("GetMachinePorts"), caller.count("GetMachineActiveSubnets"))
+	return caller
+}
 
-	// SeExposed opens the ports.
-	err = app.SetExposed()
-	c.Assert(err, jc.ErrorIsNil)
+func (s *InstanceModeSuite) TestStartManyMachines(c *gc.C) {
+	caller := s.startManyMachines(c, false)
+	c.Check(caller.count("GetAllMachinePorts"), gc.Equals, 0)
+	c.Check(caller.count("GetMachinePorts") >= 20, jc.IsTrue)
+	c.Check(caller.count("GetMachineActiveSubnets") >= 20, jc.IsTrue)
+}
 
-	s.assertPorts(c, inst, m.Id(), []network.IngressRule{
-		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
-		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
-	})
+func (s *InstanceModeSuite) TestStartManyMachinesWithOpenedPortsSnapshot(c *gc.C) {
+	caller := s.startManyMachines(c, true)
+	c.Check(caller.count("GetAllMachinePorts"), gc.Equals, 1)
+	c.Check(caller.count("GetMachinePorts"), gc.Equals, 0)
+	c.Check(caller.count("GetMachineActiveSubnets"), gc.Equals, 0)
 
-	// ClearExposed closes the ports again.
-	err = app.ClearExposed()
+	// Once started, changes to the machines' ports are still applied.
+	m, err := s.State.Machine("1")
 	c.Assert(err, jc.ErrorIsNil)
-
-	s.assertPorts(c, inst, m.Id(), nil)
+	units, err := m.Units()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(units, gc.HasLen, 1)
+	err = units[0].OpenPort("tcp", 443)
+	c.Assert(err, jc.ErrorIsNil)
+	instId, err := m.InstanceId()
+	c.Assert(err, jc.ErrorIsNil)
+	insts, err := s.Environ.Instances([]instance.Id{instId})
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertPorts(c, insts[0], m.Id(), []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8000, 8000, "0.0.0.0/0"),
+	})
+	c.Check(caller.count("GetMachinePorts"), jc.GreaterThan, 0)
 }
 
 func (s *InstanceModeSuite) TestRemoveUnit(c *gc.C) {
@@ -1079,7 +2525,15 @@ func (s *GlobalModeSuite) TearDownTest(c *gc.C) {
 }
 
 func (s *GlobalModeSuite) newFirewaller(c *gc.C) worker.Worker {
-	cfg := firewaller.Config{
+	fw, err := firewaller.NewFirewaller(s.firewallerConfig(c))
+	c.Assert(err, jc.ErrorIsNil)
+	return fw
+}
+
+// firewallerConfig returns the configuration of a global mode
+// firewaller, which may be modified before the firewaller is started.
+func (s *GlobalModeSuite) firewallerConfig(c *gc.C) firewaller.Config {
+	return firewaller.Config{
 		ModelUUID:          s.State.ModelUUID(),
 		Mode:               config.FwGlobal,
 		EnvironFirewaller:  s.Environ,
@@ -1090,9 +2544,6 @@ func (s *GlobalModeSuite) newFirewaller(c *gc.C) worker.Worker {
 			return s.crossmodelFirewaller, nil
 		},
 	}
-	fw, err := firewaller.NewFirewaller(cfg)
-	c.Assert(err, jc.ErrorIsNil)
-	return fw
 }
 
 func (s *GlobalModeSuite) TestStartStop(c *gc.C) {
@@ -1152,6 +2603,62 @@ func (s *GlobalModeSuite) TestGlobalMode(c *gc.C) {
 	s.assertEnvironPorts(c, nil)
 }
 
+func (s *GlobalModeSuite) TestFirewallRuleGroups(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEnvironPorts(c, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"firewall-rule-groups":         "monitoring=9100/tcp@10.0.0.0/8",
+		"enabled-firewall-rule-groups": "monitoring",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEnvironPorts(c, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 9100, 9100, "10.0.0.0/8"),
+	})
+
+	err = s.State.UpdateModelConfig(map[string]interface{}{
+		"enabled-firewall-rule-groups": "",
+	}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEnvironPorts(c, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+}
+
+func (s *GlobalModeSuite) TestGlobalModeLargePortRange(c *gc.C) {
+	fw := s.newFirewaller(c)
+	defer statetesting.AssertKillAndWait(c, fw)
+
+	app := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u, m := s.addUnit(c, app)
+	s.startInstance(c, m)
+
+	// A 2768 port range must be reflected as a single provider rule.
+	err = u.OpenPorts("tcp", 30000, 32767)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEnvironPorts(c, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 30000, 32767, "0.0.0.0/0"),
+	})
+
+	err = u.ClosePorts("tcp", 30000, 32767)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEnvironPorts(c, nil)
+}
+
 func (s *GlobalModeSuite) TestStartWithUnexposedApplication(c *gc.C) {
 	m, err := s.State.AddMachine("quantal", state.JobHostUnits)
 	c.Assert(err, jc.ErrorIsNil)
@@ -1315,6 +2822,70 @@ func (s *GlobalModeSuite) TestRestartPortCount(c *gc.C) {
 	s.assertEnvironPorts(c, nil)
 }
 
+func (s *GlobalModeSuite) TestMovedRuleNotClosed(c *gc.C) {
+	app1 := s.AddTestingApplication(c, "wordpress", s.charm)
+	err := app1.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u1, m1 := s.addUnit(c, app1)
+	s.startInstance(c, m1)
+	app2 := s.AddTestingApplication(c, "moinmoin", s.charm)
+	err = app2.SetExposed()
+	c.Assert(err, jc.ErrorIsNil)
+	u2, m2 := s.addUnit(c, app2)
+	s.startInstance(c, m2)
+	err = u1.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+
+	envFirewaller := &recordingFirewaller{EnvironFirewaller: s.Environ}
+	cfg := s.firewallerConfig(c)
+	cfg.EnvironFirewaller = envFirewaller
+	cfg.FlushDelay = time.Second
+	cfg.Clock = clock.WallClock
+	fw, err := firewaller.NewFirewaller(cfg)
+	c.Assert(err, jc.ErrorIsNil)
+	defer statetesting.AssertKillAndWait(c, fw)
+	s.assertEnvironPorts(c, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+	})
+
+	// Move the port from the first machine to the second, which are
+	// flushed together; the port is opened on the second before it is
+	// closed on the first, so it is never closed in the environment.
+	err = u1.ClosePort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u2.OpenPort("tcp", 80)
+	c.Assert(err, jc.ErrorIsNil)
+	err = u2.OpenPort("tcp", 8080)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertEnvironPorts(c, []network.IngressRule{
+		network.MustNewIngressRule("tcp", 80, 80, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 8080, 8080, "0.0.0.0/0"),
+	})
+	c.Assert(envFirewaller.closedRules(), gc.HasLen, 0)
+}
+
+// recordingFirewaller wraps an EnvironFirewaller so that the rules
+// closed in the environment are recorded.
+type recordingFirewaller struct {
+	firewaller.EnvironFirewaller
+
+	mu     sync.Mutex
+	closed []network.IngressRule
+}
+
+func (r *recordingFirewaller) ClosePorts(rules []network.IngressRule) error {
+	r.mu.Lock()
+	r.closed = append(r.closed, rules...)
+	r.mu.Unlock()
+	return r.EnvironFirewaller.ClosePorts(rules)
+}
+
+func (r *recordingFirewaller) closedRules() []network.IngressRule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
 type NoneModeSuite struct {
 	firewallerBaseSuite
 }
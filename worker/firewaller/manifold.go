@@ -102,13 +102,17 @@ func (cfg ManifoldConfig) start(context dependency.Context) (worker.Worker, erro
 	}
 
 	w, err := cfg.NewFirewallerWorker(Config{
-		ModelUUID:          agent.CurrentConfig().Model().Id(),
-		RemoteRelationsApi: remoteRelationsAPI,
-		FirewallerAPI:      firewallerAPI,
-		EnvironFirewaller:  environ,
-		EnvironInstances:   environ,
-		Mode:               mode,
-		NewCrossModelFacadeFunc: crossmodelFirewallerFacadeFunc(cfg.NewControllerConnection),
+		ModelUUID:                  agent.CurrentConfig().Model().Id(),
+		RemoteRelationsApi:         remoteRelationsAPI,
+		FirewallerAPI:              firewallerAPI,
+		EnvironFirewaller:          environ,
+		EnvironInstances:           environ,
+		Mode:                       mode,
+		NewCrossModelFacadeFunc:    crossmodelFirewallerFacadeFunc(cfg.NewControllerConnection),
+		InstanceStatusPollInterval: DefaultInstanceStatusPollInterval,
+		FlushDelay:                 DefaultFlushDelay,
+		ExposedSpacesPollInterval:  DefaultExposedSpacesPollInterval,
+		OpenedPortsSnapshot:        true,
 	})
 	if err != nil {
 		return nil, errors.Trace(err)
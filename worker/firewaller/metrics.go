@@ -0,0 +1,69 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"sync/atomic"
+)
+
+// Keys used in the firewaller's report. The ports counters count port
+// ranges, rather than the provider calls that open or close them.
+const (
+	KeyPortsOpened        = "ports-opened"
+	KeyPortsOpenFailures  = "ports-open-failures"
+	KeyPortsClosed        = "ports-closed"
+	KeyPortsCloseFailures = "ports-close-failures"
+	KeyMachines           = "machines"
+	KeyUnits              = "units"
+)
+
+// firewallMetrics holds counters and gauges describing the firewaller's
+// activity. All fields are accessed atomically, so a report can be taken
+// at any time without involving the firewaller's loop goroutine.
+type firewallMetrics struct {
+	portsOpened        int64
+	portsOpenFailures  int64
+	portsClosed        int64
+	portsCloseFailures int64
+	machines           int64
+	units              int64
+}
+
+// recordOpenPorts counts the outcome of a provider OpenPorts call for
+// the given number of port ranges.
+func (m *firewallMetrics) recordOpenPorts(ranges int, err error) {
+	if err != nil {
+		atomic.AddInt64(&m.portsOpenFailures, int64(ranges))
+		return
+	}
+	atomic.AddInt64(&m.portsOpened, int64(ranges))
+}
+
+// recordClosePorts counts the outcome of a provider ClosePorts call for
+// the given number of port ranges.
+func (m *firewallMetrics) recordClosePorts(ranges int, err error) {
+	if err != nil {
+		atomic.AddInt64(&m.portsCloseFailures, int64(ranges))
+		return
+	}
+	atomic.AddInt64(&m.portsClosed, int64(ranges))
+}
+
+// setTracked records the number of machines and units being tracked.
+func (m *firewallMetrics) setTracked(machines, units int) {
+	atomic.StoreInt64(&m.machines, int64(machines))
+	atomic.StoreInt64(&m.units, int64(units))
+}
+
+// report returns a snapshot of the metrics.
+func (m *firewallMetrics) report() map[string]interface{} {
+	return map[string]interface{}{
+		KeyPortsOpened:        atomic.LoadInt64(&m.portsOpened),
+		KeyPortsOpenFailures:  atomic.LoadInt64(&m.portsOpenFailures),
+		KeyPortsClosed:        atomic.LoadInt64(&m.portsClosed),
+		KeyPortsCloseFailures: atomic.LoadInt64(&m.portsCloseFailures),
+		KeyMachines:           atomic.LoadInt64(&m.machines),
+		KeyUnits:              atomic.LoadInt64(&m.units),
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type MetricsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) TestReport(c *gc.C) {
+	var m firewallMetrics
+	m.recordOpenPorts(1, nil)
+	m.recordOpenPorts(3, nil)
+	m.recordOpenPorts(2, errors.New("boom"))
+	m.recordClosePorts(1, nil)
+	m.recordClosePorts(1, errors.New("boom"))
+	m.recordClosePorts(4, errors.New("boom"))
+	m.setTracked(3, 5)
+
+	// Port ranges are counted, rather than calls.
+	c.Assert(m.report(), jc.DeepEquals, map[string]interface{}{
+		KeyPortsOpened:        int64(4),
+		KeyPortsOpenFailures:  int64(2),
+		KeyPortsClosed:        int64(1),
+		KeyPortsCloseFailures: int64(5),
+		KeyMachines:           int64(3),
+		KeyUnits:              int64(5),
+	})
+}
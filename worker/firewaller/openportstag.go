@@ -0,0 +1,199 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/juju/names.v2"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/network"
+)
+
+const (
+	// openPortsTagInterval is the shortest time between the batches
+	// in which the open ports tags of instances are written, so that
+	// the provider's tagging API does not throttle the firewaller.
+	openPortsTagInterval = 10 * time.Second
+
+	// openPortsTagBatchSize is the largest number of instances whose
+	// open ports tags are written in each batch.
+	openPortsTagBatchSize = 20
+)
+
+// instanceTag identifies a tag of an instance.
+type instanceTag struct {
+	instanceId instance.Id
+	name       string
+}
+
+// openPortsTagged records the open ports tag last queued to be written
+// for a machine.
+type openPortsTagged struct {
+	tag   instanceTag
+	value string
+}
+
+// openPortsTagValue returns the value of the open ports tag summarising
+// the given ingress rules: their distinct port ranges, sorted and
+// comma-separated.
+func openPortsTagValue(rules []network.IngressRule) string {
+	var portRanges []network.PortRange
+	seen := make(map[network.PortRange]bool)
+	for _, rule := range rules {
+		if !seen[rule.PortRange] {
+			seen[rule.PortRange] = true
+			portRanges = append(portRanges, rule.PortRange)
+		}
+	}
+	network.SortPortRanges(portRanges)
+	values := make([]string, len(portRanges))
+	for i, portRange := range portRanges {
+		values[i] = portRange.String()
+	}
+	return strings.Join(values, ",")
+}
+
+// setOpenPortsTag changes the name of the open ports tag maintained on
+// machines' instances, removing the tags with the old name, if any, and
+// queueing those with the new name to be written.
+func (fw *Firewaller) setOpenPortsTag(name string) {
+	if name != "" {
+		if _, ok := fw.environFirewaller.(environs.InstanceTagger); !ok {
+			logger.Warningf("%s is set, but the provider cannot tag instances", config.FirewallOpenPortsTag)
+			name = ""
+		}
+	}
+	if name == fw.openPortsTag {
+		return
+	}
+	for tag := range fw.openPortsTags {
+		fw.removeOpenPortsTag(tag)
+	}
+	fw.openPortsTag = name
+	for _, machined := range fw.machineds {
+		fw.updateOpenPortsTag(machined)
+	}
+}
+
+// updateOpenPortsTag queues the open ports tag of the machine's instance
+// to be written, if the ports open on it have changed since it was last
+// written. The tag is removed if no ports are open.
+func (fw *Firewaller) updateOpenPortsTag(machined *machineData) {
+	if fw.openPortsTag == "" || machined.instanceId == "" || machined.removed || machined.starting {
+		return
+	}
+	tagged := openPortsTagged{
+		tag:   instanceTag{machined.instanceId, fw.openPortsTag},
+		value: openPortsTagValue(machined.ingressRules),
+	}
+	// The tag of a machine that has not been tagged since the worker
+	// started is written even if no ports are open, as it may have
+	// been left over from before.
+	if last, ok := fw.openPortsTags[machined.tag]; ok && last == tagged {
+		return
+	}
+	if fw.openPortsTags == nil {
+		fw.openPortsTags = make(map[names.MachineTag]openPortsTagged)
+	}
+	fw.openPortsTags[machined.tag] = tagged
+	fw.queueInstanceTag(tagged.tag, tagged.value)
+}
+
+// removeOpenPortsTag queues the open ports tag of the machine's
+// instance to be removed, if it was written.
+func (fw *Firewaller) removeOpenPortsTag(tag names.MachineTag) {
+	last, ok := fw.openPortsTags[tag]
+	if !ok {
+		return
+	}
+	delete(fw.openPortsTags, tag)
+	if last.value != "" {
+		fw.queueInstanceTag(last.tag, "")
+	}
+}
+
+// queueInstanceTag queues the given value, or the tag's removal if it
+// is empty, to be written to the instance's tag in the next batch.
+func (fw *Firewaller) queueInstanceTag(tag instanceTag, value string) {
+	if fw.pendingTags == nil {
+		fw.pendingTags = make(map[instanceTag]string)
+	}
+	fw.pendingTags[tag] = value
+	fw.scheduleTagWrites()
+}
+
+// scheduleTagWrites writes the next batch of pending tags now, if the
+// last was written at least openPortsTagInterval ago, and otherwise
+// arranges for tagWrite to fire when it may be written.
+func (fw *Firewaller) scheduleTagWrites() {
+	if fw.tagWrite != nil || len(fw.pendingTags) == 0 {
+		return
+	}
+	delay := fw.lastTagWrite.Add(openPortsTagInterval).Sub(fw.pollClock.Now())
+	if delay <= 0 {
+		fw.writeInstanceTags()
+		return
+	}
+	fw.tagWrite = fw.pollClock.After(delay)
+}
+
+// writeInstanceTags writes the next batch of pending tags to their
+// instances. Writing tags is best-effort: a tag that cannot be written
+// is logged, and written again the next time its machine's ports are
+// flushed.
+func (fw *Firewaller) writeInstanceTags() {
+	fw.tagWrite = nil
+	fw.lastTagWrite = fw.pollClock.Now()
+	tags := make([]instanceTag, 0, len(fw.pendingTags))
+	for tag := range fw.pendingTags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].instanceId != tags[j].instanceId {
+			return tags[i].instanceId < tags[j].instanceId
+		}
+		return tags[i].name < tags[j].name
+	})
+	if len(tags) > openPortsTagBatchSize {
+		tags = tags[:openPortsTagBatchSize]
+	}
+	for _, tag := range tags {
+		value := fw.pendingTags[tag]
+		delete(fw.pendingTags, tag)
+		if err := fw.writeInstanceTag(tag, value); err != nil {
+			logger.Warningf("cannot update tag %q of instance %v: %v", tag.name, tag.instanceId, err)
+			for machineTag, tagged := range fw.openPortsTags {
+				if tagged.tag == tag {
+					delete(fw.openPortsTags, machineTag)
+				}
+			}
+			continue
+		}
+		logger.Debugf("set tag %q of instance %v to %q", tag.name, tag.instanceId, value)
+	}
+	fw.scheduleTagWrites()
+}
+
+// writeInstanceTag sets the instance's tag to the given value, or
+// removes it if the value is empty. If the provider cannot remove tags,
+// the tag is set to the empty value instead.
+func (fw *Firewaller) writeInstanceTag(tag instanceTag, value string) error {
+	tagger, ok := fw.environFirewaller.(environs.InstanceTagger)
+	if !ok {
+		return errors.NotSupportedf("tagging instances")
+	}
+	if value == "" {
+		if untagger, ok := fw.environFirewaller.(environs.InstanceUntagger); ok {
+			return errors.Trace(untagger.UntagInstance(tag.instanceId, []string{tag.name}))
+		}
+	}
+	return errors.Trace(tagger.TagInstance(tag.instanceId, map[string]string{tag.name: value}))
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/juju/names.v2"
+
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/network"
+)
+
+type OpenPortsTagSuite struct {
+	testing.IsolationSuite
+
+	clock  *testing.Clock
+	tagger *fakeTagger
+	fw     *Firewaller
+}
+
+var _ = gc.Suite(&OpenPortsTagSuite{})
+
+func (s *OpenPortsTagSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.clock = testing.NewClock(time.Date(2017, 6, 5, 10, 0, 0, 0, time.UTC))
+	s.tagger = &fakeTagger{}
+	s.fw = &Firewaller{
+		environFirewaller: s.tagger,
+		machineds:         make(map[names.MachineTag]*machineData),
+		pollClock:         s.clock,
+	}
+	s.fw.setOpenPortsTag("juju-open-ports")
+}
+
+func (s *OpenPortsTagSuite) TestOpenPortsTagValue(c *gc.C) {
+	c.Assert(openPortsTagValue(nil), gc.Equals, "")
+	c.Assert(openPortsTagValue([]network.IngressRule{
+		network.MustNewIngressRule("udp", 53, 53, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0"),
+		network.MustNewIngressRule("tcp", 80, 80, "10.0.0.0/8"),
+		network.MustNewIngressRule("tcp", 80, 80, "192.168.0.0/16"),
+		network.MustNewIngressRule("tcp", 8000, 8099, "0.0.0.0/0"),
+	}), gc.Equals, "80/tcp,443/tcp,8000-8099/tcp,53/udp")
+}
+
+func (s *OpenPortsTagSuite) machine(id string, ports ...int) *machineData {
+	machined := &machineData{
+		tag:        names.NewMachineTag(id),
+		instanceId: instance.Id("i-" + id),
+	}
+	for _, port := range ports {
+		machined.ingressRules = append(machined.ingressRules, network.MustNewIngressRule("tcp", port, port, "0.0.0.0/0"))
+	}
+	s.fw.machineds[machined.tag] = machined
+	return machined
+}
+
+func (s *OpenPortsTagSuite) advance(c *gc.C) {
+	c.Assert(s.fw.tagWrite, gc.NotNil)
+	s.clock.Advance(openPortsTagInterval)
+	select {
+	case <-s.fw.tagWrite:
+	default:
+		c.Fatalf("tags not written after %v", openPortsTagInterval)
+	}
+	s.fw.writeInstanceTags()
+}
+
+func (s *OpenPortsTagSuite) TestTagsWrittenInBatches(c *gc.C) {
+	var machineds []*machineData
+	for i := 0; i < openPortsTagBatchSize+5; i++ {
+		machineds = append(machineds, s.machine(fmt.Sprint(i), 80))
+	}
+	// The first tag is written at once, and the rest wait until the
+	// interval has passed.
+	for _, machined := range machineds {
+		s.fw.updateOpenPortsTag(machined)
+	}
+	c.Assert(s.tagger.writes, jc.DeepEquals, []string{"i-0 juju-open-ports=80/tcp"})
+	s.advance(c)
+	c.Assert(s.tagger.writes, gc.HasLen, 1+openPortsTagBatchSize)
+	s.advance(c)
+	c.Assert(s.tagger.writes, gc.HasLen, len(machineds))
+	c.Assert(s.fw.tagWrite, gc.IsNil)
+
+	// Unchanged tags are not written again, and only the latest value
+	// of a pending tag is written.
+	s.fw.updateOpenPortsTag(machineds[0])
+	machineds[1].ingressRules = nil
+	s.fw.updateOpenPortsTag(machineds[1])
+	machineds[1].ingressRules = []network.IngressRule{network.MustNewIngressRule("tcp", 443, 443, "0.0.0.0/0")}
+	s.fw.updateOpenPortsTag(machineds[1])
+	s.tagger.writes = nil
+	s.advance(c)
+	c.Assert(s.tagger.writes, jc.DeepEquals, []string{"i-1 juju-open-ports=443/tcp"})
+}
+
+func (s *OpenPortsTagSuite) TestTagRemoved(c *gc.C) {
+	machined := s.machine("0", 80)
+	s.fw.updateOpenPortsTag(machined)
+	machined.ingressRules = nil
+	s.fw.updateOpenPortsTag(machined)
+	s.advance(c)
+
+	machined.ingressRules = []network.IngressRule{network.MustNewIngressRule("tcp", 22, 22, "0.0.0.0/0")}
+	s.fw.updateOpenPortsTag(machined)
+	s.advance(c)
+	// The tag is removed when the machine is forgotten.
+	s.fw.removeOpenPortsTag(machined.tag)
+	s.advance(c)
+	c.Assert(s.tagger.writes, jc.DeepEquals, []string{
+		"i-0 juju-open-ports=80/tcp",
+		"i-0 -juju-open-ports",
+		"i-0 juju-open-ports=22/tcp",
+		"i-0 -juju-open-ports",
+	})
+}
+
+func (s *OpenPortsTagSuite) TestTagRenamed(c *gc.C) {
+	machined := s.machine("0", 80)
+	s.fw.updateOpenPortsTag(machined)
+	s.fw.setOpenPortsTag("open-ports")
+	s.advance(c)
+	c.Assert(s.tagger.writes, jc.DeepEquals, []string{
+		"i-0 juju-open-ports=80/tcp",
+		"i-0 -juju-open-ports",
+		"i-0 open-ports=80/tcp",
+	})
+}
+
+func (s *OpenPortsTagSuite) TestTagWriteFailure(c *gc.C) {
+	s.tagger.err = errors.New("throttled")
+	machined := s.machine("0", 80)
+	s.fw.updateOpenPortsTag(machined)
+	c.Assert(c.GetTestLog(), jc.Contains, `cannot update tag "juju-open-ports" of instance i-0: throttled`)
+
+	// The tag is written again the next time the machine is flushed.
+	s.tagger.err = nil
+	s.fw.updateOpenPortsTag(machined)
+	s.advance(c)
+	c.Assert(s.tagger.writes, jc.DeepEquals, []string{"i-0 juju-open-ports=80/tcp"})
+}
+
+func (s *OpenPortsTagSuite) TestProviderCannotTag(c *gc.C) {
+	fw := &Firewaller{
+		environFirewaller: struct{ EnvironFirewaller }{},
+		pollClock:         s.clock,
+	}
+	fw.setOpenPortsTag("juju-open-ports")
+	c.Assert(fw.openPortsTag, gc.Equals, "")
+	c.Assert(c.GetTestLog(), jc.Contains, "firewall-open-ports-tag is set, but the provider cannot tag instances")
+}
+
+// fakeTagger is an EnvironFirewaller whose provider can tag instances,
+// recording the tags written.
+type fakeTagger struct {
+	EnvironFirewaller
+
+	writes []string
+	err    error
+}
+
+func (t *fakeTagger) TagInstance(id instance.Id, tags map[string]string) error {
+	if t.err != nil {
+		return t.err
+	}
+	for name, value := range tags {
+		t.writes = append(t.writes, fmt.Sprintf("%s %s=%s", id, name, value))
+	}
+	return nil
+}
+
+func (t *fakeTagger) UntagInstance(id instance.Id, names []string) error {
+	if t.err != nil {
+		return t.err
+	}
+	for _, name := range names {
+		t.writes = append(t.writes, fmt.Sprintf("%s -%s", id, name))
+	}
+	return nil
+}
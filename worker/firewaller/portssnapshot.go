@@ -0,0 +1,81 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"gopkg.in/juju/names.v2"
+
+	"github.com/juju/juju/api/firewaller"
+	"github.com/juju/juju/network"
+)
+
+// openedPortsKey identifies the ports opened on a machine for a
+// subnet. The subnet is empty for ports opened on the machine's
+// default subnet.
+type openedPortsKey struct {
+	machine names.MachineTag
+	subnet  names.SubnetTag
+}
+
+// openedPortsSnapshot holds the ports opened on every machine in the
+// model, loaded in a single API call when the firewaller starts, so
+// that the ports of each machine need not be fetched separately.
+// Once the ports of a machine change, the snapshot is no longer used
+// for that machine.
+//
+// A nil *openedPortsSnapshot holds nothing, so that the ports of
+// every machine are fetched separately.
+type openedPortsSnapshot struct {
+	ports       map[openedPortsKey]firewaller.MachineSubnetPorts
+	subnets     map[names.MachineTag][]names.SubnetTag
+	invalidated map[names.MachineTag]bool
+}
+
+// newOpenedPortsSnapshot returns a snapshot holding the given ports.
+func newOpenedPortsSnapshot(all []firewaller.MachineSubnetPorts) *openedPortsSnapshot {
+	s := &openedPortsSnapshot{
+		ports:       make(map[openedPortsKey]firewaller.MachineSubnetPorts),
+		subnets:     make(map[names.MachineTag][]names.SubnetTag),
+		invalidated: make(map[names.MachineTag]bool),
+	}
+	for _, ports := range all {
+		s.ports[openedPortsKey{ports.Machine, ports.Subnet}] = ports
+		s.subnets[ports.Machine] = append(s.subnets[ports.Machine], ports.Subnet)
+	}
+	return s
+}
+
+// invalidate records that the ports of the machine have changed since
+// the snapshot was loaded.
+func (s *openedPortsSnapshot) invalidate(machineTag names.MachineTag) {
+	if s != nil {
+		s.invalidated[machineTag] = true
+	}
+}
+
+// valid reports whether the snapshot holds the current ports of the
+// machine.
+func (s *openedPortsSnapshot) valid(machineTag names.MachineTag) bool {
+	return s != nil && !s.invalidated[machineTag]
+}
+
+// activeSubnets returns the subnets for which ports are opened on the
+// machine, and whether the snapshot holds them.
+func (s *openedPortsSnapshot) activeSubnets(machineTag names.MachineTag) ([]names.SubnetTag, bool) {
+	if !s.valid(machineTag) {
+		return nil, false
+	}
+	return s.subnets[machineTag], true
+}
+
+// openedPorts returns the ingress and egress port ranges opened by
+// units on the machine for the subnet, and whether the snapshot holds
+// them.
+func (s *openedPortsSnapshot) openedPorts(machineTag names.MachineTag, subnetTag names.SubnetTag) (ingress, egress map[network.PortRange]names.UnitTag, ok bool) {
+	if !s.valid(machineTag) {
+		return nil, nil, false
+	}
+	ports := s.ports[openedPortsKey{machineTag, subnetTag}]
+	return ports.Ports, ports.EgressPorts, true
+}
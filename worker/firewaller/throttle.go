@@ -0,0 +1,55 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"sync"
+)
+
+// DefaultReconcileConcurrency is the number of machines whose
+// instance firewall rules are reconciled concurrently when the
+// firewaller starts, if not otherwise configured.
+const DefaultReconcileConcurrency = 10
+
+// runLimited calls each of the given functions, running no more than
+// limit of them at once. It waits for all started functions to finish,
+// and returns the first error encountered. Once an error has occurred
+// no further functions are started.
+func runLimited(limit int, funcs []func() error) error {
+	if limit <= 0 {
+		limit = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+	sem := make(chan struct{}, limit)
+	for _, f := range funcs {
+		sem <- struct{}{}
+		if failed() {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(f func() error) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := f(); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(f)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package firewaller
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type ThrottleSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ThrottleSuite{})
+
+func (s *ThrottleSuite) TestRunLimitedConcurrency(c *gc.C) {
+	const limit = 3
+	var (
+		mu       sync.Mutex
+		running  int
+		maxSeen  int
+		finished int32
+	)
+	funcs := make([]func() error, 20)
+	for i := range funcs {
+		funcs[i] = func() error {
+			mu.Lock()
+			running++
+			if running > maxSeen {
+				maxSeen = running
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			atomic.AddInt32(&finished, 1)
+			return nil
+		}
+	}
+	err := runLimited(limit, funcs)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(atomic.LoadInt32(&finished), gc.Equals, int32(len(funcs)))
+	c.Assert(maxSeen, gc.Equals, limit)
+}
+
+func (s *ThrottleSuite) TestRunLimitedError(c *gc.C) {
+	var calls int32
+	funcs := make([]func() error, 10)
+	for i := range funcs {
+		i := i
+		funcs[i] = func() error {
+			atomic.AddInt32(&calls, 1)
+			if i == 0 {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	err := runLimited(1, funcs)
+	c.Assert(err, gc.ErrorMatches, "boom")
+	c.Assert(atomic.LoadInt32(&calls), gc.Equals, int32(1))
+}
+
+func (s *ThrottleSuite) TestRunLimitedNoFuncs(c *gc.C) {
+	c.Assert(runLimited(5, nil), jc.ErrorIsNil)
+}
@@ -154,10 +154,14 @@ func (*aggregator) instInfo(id instance.Id, inst instance.Instance) (instanceInf
 	if err != nil {
 		return instanceInfo{}, err
 	}
-	return instanceInfo{
-		addr,
-		inst.Status(),
-	}, nil
+	info := instanceInfo{
+		addresses: addr,
+		status:    inst.Status(),
+	}
+	if inst, ok := inst.(instance.Interruptible); ok {
+		info.interruptible = inst.Interruptible()
+	}
+	return info, nil
 }
 
 func (a *aggregator) Kill() {
@@ -29,10 +29,11 @@ var _ = gc.Suite(&aggregateSuite{})
 
 type testInstance struct {
 	instance.Instance
-	id        instance.Id
-	addresses []network.Address
-	status    string
-	err       error
+	id            instance.Id
+	addresses     []network.Address
+	status        string
+	interruptible bool
+	err           error
 }
 
 var _ instance.Instance = (*testInstance)(nil)
@@ -52,6 +53,10 @@ func (t *testInstance) Status() instance.InstanceStatus {
 	return instance.InstanceStatus{Status: status.Unknown, Message: t.status}
 }
 
+func (t *testInstance) Interruptible() bool {
+	return t.interruptible
+}
+
 type testInstanceGetter struct {
 	sync.RWMutex
 	// ids is set when the Instances method is called.
@@ -132,6 +137,19 @@ func (s *aggregateSuite) TestSingleRequest(c *gc.C) {
 	c.Assert(ids, gc.DeepEquals, []instance.Id{"foo"})
 }
 
+func (s *aggregateSuite) TestInstanceInfoInterruptible(c *gc.C) {
+	inst := &testInstance{id: "foo", status: "running"}
+	info, err := new(aggregator).instInfo("foo", inst)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.interruptible, jc.IsFalse)
+
+	inst.interruptible = true
+	info, err = new(aggregator).instInfo("foo", inst)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.interruptible, jc.IsTrue)
+	c.Assert(info.status.Message, gc.Equals, "running")
+}
+
 // Test several requests in a short space of time get batched.
 func (s *aggregateSuite) TestMultipleResponseHandling(c *gc.C) {
 	// We setup a couple variables here so that we can use them locally without
@@ -116,7 +116,7 @@ func (s *machineSuite) TestNoPollWhenNotProvisioned(c *gc.C) {
 		case polled <- struct{}{}:
 		default:
 		}
-		return instanceInfo{testAddrs, instance.InstanceStatus{Status: status.Unknown, Message: "pending"}}, nil
+		return instanceInfo{addresses: testAddrs, status: instance.InstanceStatus{Status: status.Unknown, Message: "pending"}}, nil
 	}
 	context := &testMachineContext{
 		getInstanceInfo: getInstanceInfo,
@@ -193,6 +193,76 @@ func (s *machineSuite) TestLongPollIntervalWhenHasAllInstanceInfo(c *gc.C) {
 	clock.CheckCall(c, 0, "After", LongPoll)
 }
 
+func (s *machineSuite) TestInterruptiblePollInterval(c *gc.C) {
+	context := &testMachineContext{
+		getInstanceInfo: func(id instance.Id) (instanceInfo, error) {
+			return instanceInfo{
+				addresses:     testAddrs,
+				status:        instance.InstanceStatus{Status: status.Running, Message: "running"},
+				interruptible: true,
+			}, nil
+		},
+		dyingc: make(chan struct{}),
+	}
+	m := &testMachine{
+		tag:        names.NewMachineTag("99"),
+		instanceId: "i1234",
+		refresh:    func() error { return nil },
+		addresses:  testAddrs,
+		life:       params.Alive,
+		status:     status.Started,
+	}
+	died := make(chan machine)
+
+	clock := newTestClock()
+	go runMachine(context, m, nil, died, clock)
+	c.Assert(clock.WaitAdvance(InterruptiblePoll, 0, 1), jc.ErrorIsNil)
+	c.Assert(clock.WaitAdvance(InterruptiblePoll, 0, 1), jc.ErrorIsNil)
+
+	killMachineLoop(c, m, context.dyingc, died)
+	c.Assert(context.killErr, gc.Equals, nil)
+	clock.CheckCall(c, 0, "After", InterruptiblePoll)
+	clock.CheckCall(c, 1, "After", InterruptiblePoll)
+}
+
+func (s *machineSuite) TestSetsInterruptingInstanceStatus(c *gc.C) {
+	interrupting := instance.InstanceStatus{
+		Status:  status.Interrupting,
+		Message: "running: spot interruption notice marked-for-termination since 2017-05-10T12:30:00Z",
+	}
+	context := &testMachineContext{
+		getInstanceInfo: func(id instance.Id) (instanceInfo, error) {
+			return instanceInfo{
+				addresses:     testAddrs,
+				status:        interrupting,
+				interruptible: true,
+			}, nil
+		},
+		dyingc: make(chan struct{}),
+	}
+	m := &testMachine{
+		tag:        names.NewMachineTag("99"),
+		instanceId: "i1234",
+		refresh:    func() error { return nil },
+		addresses:  testAddrs,
+		life:       params.Alive,
+		status:     status.Started,
+		instStatus: status.Running,
+	}
+	died := make(chan machine)
+
+	clock := newTestClock()
+	go runMachine(context, m, nil, died, clock)
+	c.Assert(clock.WaitAdvance(InterruptiblePoll, 0, 1), jc.ErrorIsNil)
+
+	killMachineLoop(c, m, context.dyingc, died)
+	c.Assert(context.killErr, gc.Equals, nil)
+	// The interruption is reported in the instance status itself, not
+	// only in its message.
+	c.Assert(m.instStatus, gc.Equals, status.Interrupting)
+	c.Assert(m.instStatusInfo, gc.Equals, interrupting.Message)
+}
+
 func testRunMachine(
 	c *gc.C,
 	addrs []network.Address,
@@ -206,7 +276,7 @@ func testRunMachine(
 		if addrs == nil {
 			return instanceInfo{}, fmt.Errorf("no instance addresses available")
 		}
-		return instanceInfo{addrs, instance.InstanceStatus{Status: status.Unknown, Message: instStatus}}, nil
+		return instanceInfo{addresses: addrs, status: instance.InstanceStatus{Status: status.Unknown, Message: instStatus}}, nil
 	}
 	context := &testMachineContext{
 		getInstanceInfo: getInstanceInfo,
@@ -353,7 +423,7 @@ func instanceInfoGetter(
 
 	return func(id instance.Id) (instanceInfo, error) {
 		c.Check(id, gc.Equals, expectId)
-		return instanceInfo{addrs, instance.InstanceStatus{Status: status.Unknown, Message: instanceStatus}}, err
+		return instanceInfo{addresses: addrs, status: instance.InstanceStatus{Status: status.Unknown, Message: instanceStatus}}, err
 	}
 }
 
@@ -174,6 +174,10 @@ type HookContext struct {
 	// opened each range and the relevant relation.
 	machinePorts map[network.PortRange]params.RelationUnit
 
+	// pendingEgressPorts contains egress port ranges to be opened
+	// (true) or closed (false) when the current hook is committed.
+	pendingEgressPorts map[network.PortRange]bool
+
 	// assignedMachineTag contains the tag of the unit's assigned
 	// machine.
 	assignedMachineTag names.MachineTag
@@ -455,6 +459,26 @@ func (ctx *HookContext) ClosePorts(protocol string, fromPort, toPort int) error
 	)
 }
 
+func (ctx *HookContext) OpenEgressPorts(protocol string, fromPort, toPort int) error {
+	return ctx.setPendingEgressPorts(protocol, fromPort, toPort, true)
+}
+
+func (ctx *HookContext) CloseEgressPorts(protocol string, fromPort, toPort int) error {
+	return ctx.setPendingEgressPorts(protocol, fromPort, toPort, false)
+}
+
+func (ctx *HookContext) setPendingEgressPorts(protocol string, fromPort, toPort int, open bool) error {
+	portRange, err := validatePortRange(protocol, fromPort, toPort)
+	if err != nil {
+		return err
+	}
+	if ctx.pendingEgressPorts == nil {
+		ctx.pendingEgressPorts = make(map[network.PortRange]bool)
+	}
+	ctx.pendingEgressPorts[portRange] = open
+	return nil
+}
+
 func (ctx *HookContext) OpenedPorts() []network.PortRange {
 	var unitRanges []network.PortRange
 	for portRange, relUnit := range ctx.machinePorts {
@@ -709,6 +733,27 @@ func (ctx *HookContext) Flush(process string, ctxErr error) (err error) {
 		}
 	}
 
+	for portRange, open := range ctx.pendingEgressPorts {
+		if writeChanges {
+			var e error
+			var op string
+			if open {
+				e = ctx.unit.OpenEgressPorts(portRange.Protocol, portRange.FromPort, portRange.ToPort)
+				op = "open"
+			} else {
+				e = ctx.unit.CloseEgressPorts(portRange.Protocol, portRange.FromPort, portRange.ToPort)
+				op = "close"
+			}
+			if e != nil {
+				e = errors.Annotatef(e, "cannot %s egress %v", op, portRange)
+				logger.Errorf("%v", e)
+				if ctxErr == nil {
+					ctxErr = e
+				}
+			}
+		}
+	}
+
 	// add storage to unit dynamically
 	if len(ctx.storageAddConstraints) > 0 && writeChanges {
 		err := ctx.unit.AddStorage(ctx.storageAddConstraints)
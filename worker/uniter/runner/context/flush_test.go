@@ -169,6 +169,40 @@ func (s *FlushContextSuite) TestRunHookOpensAndClosesPendingPorts(c *gc.C) {
 	c.Assert(unitRanges, jc.DeepEquals, expectUnitRanges)
 }
 
+func (s *FlushContextSuite) TestRunHookOpensAndClosesPendingEgressPorts(c *gc.C) {
+	err := s.unit.OpenEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+
+	ctx := s.context(c)
+	err = ctx.OpenEgressPorts("udp", 53, 53)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ctx.CloseEgressPorts("tcp", 443, 443)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ctx.OpenEgressPorts("udp", 0, 53)
+	c.Assert(err, gc.ErrorMatches, `invalid port range 0-53/udp`)
+
+	// Egress ports are not changed until the context is flushed.
+	egressRanges, err := s.unit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(egressRanges, jc.DeepEquals, []network.PortRange{
+		{FromPort: 443, ToPort: 443, Protocol: "tcp"},
+	})
+
+	err = ctx.Flush("some badge", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	egressRanges, err = s.unit.OpenedEgressPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(egressRanges, jc.DeepEquals, []network.PortRange{
+		{FromPort: 53, ToPort: 53, Protocol: "udp"},
+	})
+
+	// Ingress ports are unaffected.
+	unitRanges, err := s.unit.OpenedPorts()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(unitRanges, gc.HasLen, 0)
+}
+
 func (s *FlushContextSuite) TestRunHookAddStorageOnFailure(c *gc.C) {
 	ctx := s.context(c)
 	c.Assert(ctx.UnitName(), gc.Equals, "u/0")
@@ -159,6 +159,15 @@ type ContextNetworking interface {
 	// protocol, then by number.
 	OpenedPorts() []network.PortRange
 
+	// OpenEgressPorts marks the supplied port range for opening to
+	// outbound traffic from the executing unit's machine, where the
+	// provider supports egress rules.
+	OpenEgressPorts(protocol string, fromPort, toPort int) error
+
+	// CloseEgressPorts ensures the supplied egress port range is
+	// closed.
+	CloseEgressPorts(protocol string, fromPort, toPort int) error
+
 	// NetworkInfo returns detailed information about interfaces for specified bindings
 	NetworkInfo(bindingNames []string) (map[string]params.NetworkInfoResult, error)
 }
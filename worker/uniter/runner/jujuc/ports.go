@@ -12,6 +12,8 @@ import (
 	"github.com/juju/cmd"
 	"github.com/juju/errors"
 	"github.com/juju/gnuflag"
+
+	"github.com/juju/juju/network"
 )
 
 const (
@@ -69,6 +71,10 @@ func (pr portRange) validate() error {
 
 func parseArguments(args []string) (portRange, error) {
 	arg := strings.ToLower(args[0])
+	if _, ok := network.IPProtocolNumber(arg); ok {
+		// IP protocols such as "esp" have no ports.
+		return portRange{protocol: arg}, nil
+	}
 	if !validPortOrRange.MatchString(arg) {
 		return portRange{}, errors.Errorf("expected %s; got %q", portFormat, args[0])
 	}
@@ -110,6 +116,7 @@ type portCommand struct {
 	Protocol   string
 	FromPort   int
 	ToPort     int
+	Egress     bool
 	formatFlag string // deprecated
 }
 
@@ -119,6 +126,7 @@ func (c *portCommand) Info() *cmd.Info {
 
 func (c *portCommand) SetFlags(f *gnuflag.FlagSet) {
 	f.StringVar(&c.formatFlag, "format", "", "deprecated format flag")
+	f.BoolVar(&c.Egress, "egress", false, "apply to outbound traffic from the unit's machine")
 }
 
 func (c *portCommand) Init(args []string) error {
@@ -148,13 +156,26 @@ var openPortInfo = &cmd.Info{
 	Name:    "open-port",
 	Args:    portFormat,
 	Purpose: "register a port or range to open",
-	Doc:     "The port range will only be open while the application is exposed.",
+	Doc: `
+The port range will only be open while the application is exposed.
+
+Instead of a port or range, an IP protocol without ports may be given:
+"esp", "ah" or "gre". All of the protocol's traffic is then allowed,
+where the provider supports it.
+
+With --egress, the port range is instead opened to outbound traffic from
+the unit's machine, where the provider supports egress rules. Egress port
+ranges do not depend on the application being exposed.
+`,
 }
 
 func NewOpenPortCommand(ctx Context) (cmd.Command, error) {
 	return &portCommand{
 		info: openPortInfo,
 		action: func(c *portCommand) error {
+			if c.Egress {
+				return ctx.OpenEgressPorts(c.Protocol, c.FromPort, c.ToPort)
+			}
 			return ctx.OpenPorts(c.Protocol, c.FromPort, c.ToPort)
 		},
 	}, nil
@@ -164,12 +185,19 @@ var closePortInfo = &cmd.Info{
 	Name:    "close-port",
 	Args:    portFormat,
 	Purpose: "ensure a port or range is always closed",
+	Doc: `
+With --egress, the port range is closed to outbound traffic from the
+unit's machine instead.
+`,
 }
 
 func NewClosePortCommand(ctx Context) (cmd.Command, error) {
 	return &portCommand{
 		info: closePortInfo,
 		action: func(c *portCommand) error {
+			if c.Egress {
+				return ctx.CloseEgressPorts(c.Protocol, c.FromPort, c.ToPort)
+			}
 			return ctx.ClosePorts(c.Protocol, c.FromPort, c.ToPort)
 		},
 	}, nil
@@ -37,12 +37,16 @@ var portsTests = []struct {
 	{[]string{"close-port", "443/udp"}, makeRanges("99/tcp")},
 	{[]string{"open-port", "123/udp"}, makeRanges("99/tcp", "123/udp")},
 	{[]string{"close-port", "9999/UDP"}, makeRanges("99/tcp", "123/udp")},
+	{[]string{"open-port", "ESP"}, makeRanges("99/tcp", "123/udp", "esp")},
+	{[]string{"close-port", "esp"}, makeRanges("99/tcp", "123/udp")},
 }
 
 func makeRanges(stringRanges ...string) []network.PortRange {
 	var results []network.PortRange
 	for _, s := range stringRanges {
-		if strings.Contains(s, "-") {
+		if !strings.Contains(s, "/") {
+			results = append(results, network.PortRange{Protocol: s})
+		} else if strings.Contains(s, "-") {
 			parts := strings.Split(s, "-")
 			fromPort, _ := strconv.Atoi(parts[0])
 			parts = strings.Split(parts[1], "/")
@@ -82,6 +86,28 @@ func (s *PortsSuite) TestOpenClose(c *gc.C) {
 	}
 }
 
+func (s *PortsSuite) TestOpenCloseEgress(c *gc.C) {
+	hctx := s.GetHookContext(c, -1, "")
+	for _, t := range []struct {
+		cmd    []string
+		expect []network.PortRange
+	}{
+		{[]string{"open-port", "--egress", "443"}, makeRanges("443/tcp")},
+		{[]string{"open-port", "--egress", "53/udp"}, makeRanges("443/tcp", "53/udp")},
+		{[]string{"close-port", "--egress", "443/tcp"}, makeRanges("53/udp")},
+	} {
+		com, err := jujuc.NewCommand(hctx, cmdString(t.cmd[0]))
+		c.Assert(err, jc.ErrorIsNil)
+		ctx := cmdtesting.Context(c)
+		code := cmd.Main(com, ctx, t.cmd[1:])
+		c.Assert(code, gc.Equals, 0)
+		c.Assert(bufferString(ctx.Stderr), gc.Equals, "")
+		c.Check(hctx.info.EgressPorts, jc.DeepEquals, t.expect)
+	}
+	// Ingress ports are unaffected.
+	hctx.info.CheckPorts(c, nil)
+}
+
 var badPortsTests = []struct {
 	args []string
 	err  string
@@ -126,6 +152,10 @@ register a port or range to open
 
 Details:
 The port range will only be open while the application is exposed.
+
+With --egress, the port range is instead opened to outbound traffic from
+the unit's machine, where the provider supports egress rules. Egress port
+ranges do not depend on the application being exposed.
 `[1:])
 
 	close, err := jujuc.NewCommand(hctx, cmdString("close-port"))
@@ -135,6 +165,10 @@ Usage: close-port <port>[/<protocol>] or <from>-<to>[/<protocol>]
 
 Summary:
 ensure a port or range is always closed
+
+Details:
+With --egress, the port range is closed to outbound traffic from the
+unit's machine instead.
 `[1:])
 }
 
@@ -64,6 +64,16 @@ func (*RestrictedContext) ClosePorts(protocol string, fromPort, toPort int) erro
 // OpenedPorts implements jujuc.Context.
 func (*RestrictedContext) OpenedPorts() []network.PortRange { return nil }
 
+// OpenEgressPorts implements jujuc.Context.
+func (*RestrictedContext) OpenEgressPorts(protocol string, fromPort, toPort int) error {
+	return ErrRestrictedContext
+}
+
+// CloseEgressPorts implements jujuc.Context.
+func (*RestrictedContext) CloseEgressPorts(protocol string, fromPort, toPort int) error {
+	return ErrRestrictedContext
+}
+
 // NetworkInfo implements jujuc.Context.
 func (*RestrictedContext) NetworkInfo(bindingNames []string) (map[string]params.NetworkInfoResult, error) {
 	return map[string]params.NetworkInfoResult{}, ErrRestrictedContext
@@ -17,6 +17,7 @@ type NetworkInterface struct {
 	PublicAddress      string
 	PrivateAddress     string
 	Ports              []network.PortRange
+	EgressPorts        []network.PortRange
 	NetworkInfoResults map[string]params.NetworkInfoResult
 }
 
@@ -103,6 +104,43 @@ func (c *ContextNetworking) OpenedPorts() []network.PortRange {
 	return c.info.Ports
 }
 
+// OpenEgressPorts implements jujuc.ContextNetworking.
+func (c *ContextNetworking) OpenEgressPorts(protocol string, from, to int) error {
+	c.stub.AddCall("OpenEgressPorts", protocol, from, to)
+	if err := c.stub.NextErr(); err != nil {
+		return errors.Trace(err)
+	}
+
+	c.info.EgressPorts = append(c.info.EgressPorts, network.PortRange{
+		Protocol: protocol,
+		FromPort: from,
+		ToPort:   to,
+	})
+	network.SortPortRanges(c.info.EgressPorts)
+	return nil
+}
+
+// CloseEgressPorts implements jujuc.ContextNetworking.
+func (c *ContextNetworking) CloseEgressPorts(protocol string, from, to int) error {
+	c.stub.AddCall("CloseEgressPorts", protocol, from, to)
+	if err := c.stub.NextErr(); err != nil {
+		return errors.Trace(err)
+	}
+
+	portRange := network.PortRange{
+		Protocol: protocol,
+		FromPort: from,
+		ToPort:   to,
+	}
+	for i, port := range c.info.EgressPorts {
+		if port == portRange {
+			c.info.EgressPorts = append(c.info.EgressPorts[:i], c.info.EgressPorts[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
 // NetworkInfo implements jujuc.ContextNetworking.
 func (c *ContextNetworking) NetworkInfo(bindingNames []string) (map[string]params.NetworkInfoResult, error) {
 	c.stub.AddCall("NetworkInfo", bindingNames)